@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// credentials are what pair saves: the device key, its session tokens and
+// the bridge it paired with. PrivateKey is the base64 ed25519 seed.
+type credentials struct {
+	Bridge            string    `json:"bridge"`
+	TLSPin            string    `json:"tls_pin,omitempty"`
+	BridgeFingerprint string    `json:"bridge_fingerprint"`
+	Address           string    `json:"address"`
+	DeviceName        string    `json:"device_name"`
+	PrivateKey        string    `json:"private_key"`
+	Scopes            []string  `json:"scopes"`
+	AccessToken       string    `json:"access_token"`
+	RefreshToken      string    `json:"refresh_token"`
+	ExpiresAt         time.Time `json:"expires_at"`
+	RefreshExpiresAt  time.Time `json:"refresh_expires_at"`
+}
+
+func loadCredentials(path string) (*credentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c credentials
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// saveCredentials writes c readable by the owner only, replacing the file
+// atomically so a failed write keeps the previous tokens.
+func saveCredentials(path string, c *credentials) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".credentials-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// apiError is the bridge's {"error": {"code", "message"}} body.
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *apiError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("bridge returned %d", e.Status)
+	}
+	return fmt.Sprintf("%s (%d): %s", e.Code, e.Status, e.Message)
+}
+
+// client calls the bridge API with either a fixed token or the paired
+// credentials, whose tokens it refreshes and saves when the access token
+// is rejected.
+type client struct {
+	base      string
+	http      *http.Client
+	token     string
+	creds     *credentials
+	credsPath string
+}
+
+// newClient resolves the bridge and token from the flags, falling back to
+// the saved credentials.
+func (a *app) newClient() (*client, error) {
+	c := &client{base: strings.TrimRight(a.bridge, "/"), token: a.token, credsPath: a.configPath}
+	pin := ""
+	if c.token == "" || c.base == "" {
+		creds, err := loadCredentials(a.configPath)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("not paired: run elixctl pair, or set -bridge and -token")
+			}
+			return nil, err
+		}
+		if c.base == "" {
+			c.base = strings.TrimRight(creds.Bridge, "/")
+		}
+		if c.token == "" {
+			c.creds = creds
+		}
+		if strings.TrimRight(creds.Bridge, "/") == c.base {
+			pin = creds.TLSPin
+		}
+	}
+	c.http = httpClient(pin)
+	return c, nil
+}
+
+// httpClient returns a client that, given a pin from the pair URI, accepts
+// the bridge's self-signed certificate when its fingerprint matches.
+func httpClient(pin string) *http.Client {
+	if pin == "" {
+		return &http.Client{}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("bridge sent no certificate")
+			}
+			sum := sha256.Sum256(rawCerts[0])
+			if got := "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]); got != pin {
+				return fmt.Errorf("bridge certificate %s does not match pinned %s", got, pin)
+			}
+			return nil
+		},
+	}}}
+}
+
+func (c *client) accessToken() string {
+	if c.creds != nil {
+		return c.creds.AccessToken
+	}
+	return c.token
+}
+
+// call sends body as JSON and decodes the response into out (when not nil).
+func (c *client) call(ctx context.Context, method, path string, body, out any) error {
+	resp, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// send returns a successful response, refreshing the session once when
+// the access token is rejected.
+func (c *client) send(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := c.do(ctx, method, path, c.accessToken(), payload)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && c.creds != nil && c.creds.RefreshToken != "" {
+		resp.Body.Close()
+		if err := c.refresh(ctx); err != nil {
+			return nil, fmt.Errorf("refresh session: %w", err)
+		}
+		if resp, err = c.do(ctx, method, path, c.accessToken(), payload); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, readAPIError(resp)
+	}
+	return resp, nil
+}
+
+func (c *client) do(ctx context.Context, method, path, token string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c.http.Do(req)
+}
+
+func (c *client) refresh(ctx context.Context) error {
+	payload, _ := json.Marshal(map[string]string{"refresh_token": c.creds.RefreshToken})
+	resp, err := c.do(ctx, http.MethodPost, "/api/v3/session/refresh", "", payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return readAPIError(resp)
+	}
+	var out struct {
+		Scopes           []string  `json:"scopes"`
+		AccessToken      string    `json:"access_token"`
+		RefreshToken     string    `json:"refresh_token"`
+		ExpiresAt        time.Time `json:"expires_at"`
+		RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return err
+	}
+	c.creds.Scopes = out.Scopes
+	c.creds.AccessToken = out.AccessToken
+	c.creds.RefreshToken = out.RefreshToken
+	c.creds.ExpiresAt = out.ExpiresAt
+	c.creds.RefreshExpiresAt = out.RefreshExpiresAt
+	return saveCredentials(c.credsPath, c.creds)
+}
+
+// dialEvents opens an event WebSocket, refreshing the session once when
+// the access token is rejected.
+func (c *client) dialEvents(ctx context.Context, path string) (*websocket.Conn, error) {
+	target := "ws" + strings.TrimPrefix(c.base, "http") + path
+	conn, resp, err := c.dial(ctx, target)
+	if resp != nil && resp.StatusCode == http.StatusUnauthorized && c.creds != nil && c.creds.RefreshToken != "" {
+		if err := c.refresh(ctx); err != nil {
+			return nil, fmt.Errorf("refresh session: %w", err)
+		}
+		conn, resp, err = c.dial(ctx, target)
+	}
+	if err != nil {
+		if resp != nil && resp.StatusCode >= 300 {
+			return nil, readAPIError(resp)
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (c *client) dial(ctx context.Context, target string) (*websocket.Conn, *http.Response, error) {
+	dialer := websocket.Dialer{HandshakeTimeout: 30 * time.Second, Proxy: http.ProxyFromEnvironment}
+	if t, ok := c.http.Transport.(*http.Transport); ok {
+		dialer.TLSClientConfig = t.TLSClientConfig
+	}
+	header := http.Header{}
+	if token := c.accessToken(); token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	return dialer.DialContext(ctx, target, header)
+}
+
+func readAPIError(resp *http.Response) error {
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	e := &apiError{Status: resp.StatusCode}
+	if json.Unmarshal(data, &body) == nil && body.Error.Code != "" {
+		e.Code, e.Message = body.Error.Code, body.Error.Message
+	} else {
+		e.Message = strings.TrimSpace(string(data))
+	}
+	return e
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
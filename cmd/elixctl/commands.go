@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"echohelix/internal/events"
+	"echohelix/internal/run"
+)
+
+func cmdSubmit(ctx context.Context, a *app, args []string) error {
+	fs := subFlags(a, "submit")
+	workspace := fs.String("workspace", "", "workspace path (default: current directory)")
+	workspaceID := fs.String("workspace-id", "", "workspace id (default: workspace directory name)")
+	backend := fs.String("backend", "codex", "backend")
+	model := fs.String("model", "", "model")
+	profile := fs.String("profile", "", "profile")
+	sandbox := fs.String("sandbox", "", "sandbox mode")
+	follow := fs.Bool("follow", false, "tail the run's events until it finishes")
+	asJSON := fs.Bool("json", false, "with -follow, print events as JSON lines")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	prompt := strings.Join(fs.Args(), " ")
+	if prompt == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		prompt = string(data)
+	}
+	if strings.TrimSpace(prompt) == "" {
+		return errUsage
+	}
+	if *workspace == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		*workspace = wd
+	}
+	if *workspaceID == "" {
+		*workspaceID = filepath.Base(*workspace)
+	}
+	c, err := a.newClient()
+	if err != nil {
+		return err
+	}
+	var res map[string]any
+	if err := c.call(ctx, http.MethodPost, "/api/v3/runs", run.SubmitRequest{
+		WorkspaceID:   *workspaceID,
+		WorkspacePath: *workspace,
+		Backend:       *backend,
+		Prompt:        prompt,
+		Options:       run.RunOptions{Model: *model, Profile: *profile, Sandbox: *sandbox},
+	}, &res); err != nil {
+		return err
+	}
+	if !*follow {
+		return printJSON(a.stdout, res)
+	}
+	runID, _ := res["run_id"].(string)
+	fmt.Fprintln(a.stderr, "run", runID)
+	return tail(ctx, c, a.stdout, runID, 0, *asJSON)
+}
+
+func cmdTail(ctx context.Context, a *app, args []string) error {
+	fs := subFlags(a, "tail")
+	from := fs.Int64("from", 0, "replay from this seq")
+	asJSON := fs.Bool("json", false, "print events as JSON lines")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+	c, err := a.newClient()
+	if err != nil {
+		return err
+	}
+	return tail(ctx, c, a.stdout, fs.Arg(0), *from, *asJSON)
+}
+
+// statusCheckInterval is how often tail checks whether the run finished
+// without a done event reaching the stream.
+var statusCheckInterval = 5 * time.Second
+
+// tail streams the run's events over the WebSocket, replaying from seq
+// from, until a done event arrives or the run reaches a final status.
+func tail(ctx context.Context, c *client, w io.Writer, runID string, from int64, asJSON bool) error {
+	base := "/api/v3/runs/" + url.PathEscape(runID)
+	if _, err := runStatus(ctx, c, base); err != nil {
+		return err
+	}
+	path := base + "/events"
+	if from > 0 {
+		path += "?from_seq=" + strconv.FormatInt(from, 10)
+	}
+	conn, err := c.dialEvents(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		ticker := time.NewTicker(statusCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-finished:
+				return
+			case <-ticker.C:
+				if status, err := runStatus(ctx, c, base); err == nil && isFinal(status) {
+					// Let the last events arrive, then end the read below.
+					_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+					return
+				}
+			}
+		}
+	}()
+	for {
+		var ev events.Event
+		if err := conn.ReadJSON(&ev); err != nil {
+			var netErr net.Error
+			if ctx.Err() != nil || (errors.As(err, &netErr) && netErr.Timeout()) {
+				return nil
+			}
+			return err
+		}
+		if err := printEvent(w, ev, asJSON); err != nil {
+			return err
+		}
+		if ev.Type == events.TypeDone {
+			return nil
+		}
+	}
+}
+
+func runStatus(ctx context.Context, c *client, base string) (string, error) {
+	var obj struct {
+		Status string `json:"status"`
+	}
+	err := c.call(ctx, http.MethodGet, base, nil, &obj)
+	return obj.Status, err
+}
+
+func isFinal(status string) bool {
+	switch status {
+	case run.StatusCompleted, run.StatusFailed, run.StatusCancelled:
+		return true
+	}
+	return false
+}
+
+func printEvent(w io.Writer, ev events.Event, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(w).Encode(ev)
+	}
+	if text, ok := ev.Payload["text"].(string); ok && ev.Type == events.TypeToken {
+		_, err := fmt.Fprintln(w, text)
+		return err
+	}
+	payload, _ := json.Marshal(ev.Payload)
+	_, err := fmt.Fprintf(w, "[%s] %s\n", ev.Type, payload)
+	return err
+}
+
+func cmdApprovals(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	fs := subFlags(a, "approvals "+args[0])
+	forSession := fs.Bool("for-session", false, "accept similar requests for the rest of the session")
+	if err := fs.Parse(args[1:]); err != nil {
+		return errUsage
+	}
+	rest := fs.Args()
+	c, err := a.newClient()
+	if err != nil {
+		return err
+	}
+	switch {
+	case args[0] == "list" && len(rest) == 1:
+		var out map[string]any
+		if err := c.call(ctx, http.MethodGet, "/api/v3/sessions/"+url.PathEscape(rest[0])+"/approvals", nil, &out); err != nil {
+			return err
+		}
+		return printJSON(a.stdout, out)
+	case (args[0] == "approve" || args[0] == "decline") && len(rest) == 2:
+		decision := "accept"
+		if args[0] == "decline" {
+			decision = "decline"
+		}
+		var out map[string]any
+		path := "/api/v3/sessions/" + url.PathEscape(rest[0]) + "/approvals/" + url.PathEscape(rest[1])
+		if err := c.call(ctx, http.MethodPost, path, map[string]any{"decision": decision, "for_session": *forSession}, &out); err != nil {
+			return err
+		}
+		return printJSON(a.stdout, out)
+	}
+	return errUsage
+}
+
+func cmdDevices(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	c, err := a.newClient()
+	if err != nil {
+		return err
+	}
+	var (
+		out  map[string]any
+		rest = args[1:]
+	)
+	switch {
+	case args[0] == "list" && len(rest) == 0:
+		err = c.call(ctx, http.MethodGet, "/api/v3/devices", nil, &out)
+	case args[0] == "rename" && len(rest) == 2:
+		err = c.call(ctx, http.MethodPost, "/api/v3/devices/"+url.PathEscape(rest[0])+"/rename", map[string]string{"name": rest[1]}, &out)
+	case args[0] == "revoke" && (len(rest) == 1 || len(rest) == 2):
+		reason := ""
+		if len(rest) == 2 {
+			reason = rest[1]
+		}
+		err = c.call(ctx, http.MethodPost, "/api/v3/devices/"+url.PathEscape(rest[0])+"/revoke", map[string]string{"reason": reason}, &out)
+	default:
+		return errUsage
+	}
+	if err != nil {
+		return err
+	}
+	return printJSON(a.stdout, out)
+}
+
+func cmdEmergency(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	c, err := a.newClient()
+	if err != nil {
+		return err
+	}
+	var out map[string]any
+	switch {
+	case args[0] == "stop":
+		err = c.call(ctx, http.MethodPost, "/api/v3/emergency/stop", map[string]string{"reason": strings.Join(args[1:], " ")}, &out)
+	case args[0] == "resume" && len(args) == 1:
+		err = c.call(ctx, http.MethodPost, "/api/v3/emergency/resume", map[string]string{}, &out)
+	case args[0] == "status" && len(args) == 1:
+		err = c.call(ctx, http.MethodGet, "/api/v3/emergency/status", nil, &out)
+	default:
+		return errUsage
+	}
+	if err != nil {
+		return err
+	}
+	return printJSON(a.stdout, out)
+}
@@ -0,0 +1,116 @@
+// Command elixctl is a command-line client for the bridge API. It pairs
+// with a bridge as a device, submits runs, follows their events, resolves
+// approvals, manages devices and triggers the emergency stop, for headless
+// servers and scripts.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+const usage = `Usage: elixctl [-bridge URL] [-token TOKEN] [-config FILE] <command> [args]
+
+Commands:
+  pair <elix://... URI>          Pair as a new device (or -bridge with -code and -challenge)
+  submit -workspace PATH PROMPT  Submit a run; -follow tails its events
+  tail RUN_ID                    Print a run's events until it finishes
+  approvals list SESSION_ID      List pending approvals of a session
+  approvals approve|decline SESSION_ID REQUEST_ID
+  devices list
+  devices rename ADDRESS NAME
+  devices revoke ADDRESS [REASON]
+  emergency stop [REASON]        Cancel active runs and block submits
+  emergency resume|status
+
+The bridge URL and token default to ELIX_BRIDGE_URL and ELIX_TOKEN, then to
+the credentials saved by pair (ELIXCTL_CONFIG, default
+$XDG_CONFIG_HOME/elixctl/credentials.json).
+`
+
+// errUsage makes realMain print the usage and exit with status 2.
+var errUsage = errors.New("usage")
+
+type command func(ctx context.Context, app *app, args []string) error
+
+var commands = map[string]command{
+	"pair":      cmdPair,
+	"submit":    cmdSubmit,
+	"tail":      cmdTail,
+	"approvals": cmdApprovals,
+	"devices":   cmdDevices,
+	"emergency": cmdEmergency,
+}
+
+// app holds the global flags and output streams of one invocation.
+type app struct {
+	stdout, stderr io.Writer
+	bridge         string
+	token          string
+	configPath     string
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := realMain(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+func realMain(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("elixctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, usage) }
+	a := &app{stdout: stdout, stderr: stderr}
+	fs.StringVar(&a.bridge, "bridge", os.Getenv("ELIX_BRIDGE_URL"), "bridge base URL")
+	fs.StringVar(&a.token, "token", os.Getenv("ELIX_TOKEN"), "bearer token, used instead of the paired credentials")
+	fs.StringVar(&a.configPath, "config", defaultConfigPath(), "credentials file")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "elixctl: unknown command %q\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+	if err := cmd(ctx, a, fs.Args()[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			fs.Usage()
+			return 2
+		}
+		fmt.Fprintln(stderr, "elixctl:", err)
+		return 1
+	}
+	return 0
+}
+
+func defaultConfigPath() string {
+	if p := os.Getenv("ELIXCTL_CONFIG"); p != "" {
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "elixctl-credentials.json"
+	}
+	return filepath.Join(dir, "elixctl", "credentials.json")
+}
+
+// subFlags is a flag set for a subcommand that reports errors through the
+// command instead of exiting.
+func subFlags(a *app, name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("elixctl "+name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	return fs
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"echohelix/internal/api"
+	"echohelix/internal/auth"
+	"echohelix/internal/driver"
+	"echohelix/internal/events"
+	"echohelix/internal/ledger"
+	"echohelix/internal/policy"
+	"echohelix/internal/run"
+)
+
+type fakeDriver struct{}
+
+func (fakeDriver) Name() string { return "codex" }
+
+func (fakeDriver) StartRun(context.Context, driver.StartRequest) (*driver.Stream, error) {
+	eventsCh := make(chan events.Event, 2)
+	doneCh := make(chan error, 1)
+	eventsCh <- events.Event{Type: events.TypeToken, Payload: map[string]any{"text": "hello from codex"}, TS: time.Now().UTC()}
+	eventsCh <- events.Event{Type: events.TypeDone, Payload: map[string]any{"status": "completed"}, TS: time.Now().UTC()}
+	close(eventsCh)
+	doneCh <- nil
+	close(doneCh)
+	return &driver.Stream{Events: eventsCh, Done: doneCh}, nil
+}
+
+func (fakeDriver) Cancel(context.Context, string) error { return nil }
+
+func (fakeDriver) Health(context.Context) (driver.Health, error) {
+	return driver.Health{OK: true}, nil
+}
+
+func (fakeDriver) Capabilities(context.Context) (driver.CapabilitySet, error) {
+	return driver.CapabilitySet{Backend: "codex", SupportsCancel: true}, nil
+}
+
+// startBridge runs a bridge on a free local port and returns its URL.
+func startBridge(t *testing.T, workspaceRoot string) string {
+	t.Helper()
+	store, err := ledger.Open(filepath.Join(t.TempDir(), "bridge.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	reg := driver.NewRegistry()
+	reg.Register(fakeDriver{})
+	runSvc := run.NewService(store, reg, run.NewHub(), policy.New([]string{workspaceRoot}), 30*time.Second, 4)
+	authSvc := auth.New(store, auth.Config{AccessTokenTTL: time.Minute, RefreshTokenTTL: 10 * time.Minute, PairCodeTTL: time.Minute})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	s := api.New(addr, "admin-token", runSvc, nil, authSvc)
+	go func() { _ = s.Start() }()
+	t.Cleanup(func() { _ = s.Shutdown(context.Background()) })
+	base := "http://" + addr
+	for i := 0; i < 100; i++ {
+		if resp, err := http.Get(base + "/healthz"); err == nil {
+			resp.Body.Close()
+			return base
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("bridge did not start")
+	return ""
+}
+
+func elixctl(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := realMain(context.Background(), args, &stdout, &stderr)
+	return stdout.String() + stderr.String(), code
+}
+
+func TestElixctlPairsSubmitsAndStops(t *testing.T) {
+	workspace := t.TempDir()
+	base := startBridge(t, workspace)
+	config := filepath.Join(t.TempDir(), "elixctl", "credentials.json")
+
+	uri := startPair(t, base)
+	if out, code := elixctl(t, "-config", config, "pair", "-name", "ci", "-fingerprint", "SHA256:other", uri); code != 1 || !strings.Contains(out, "does not match") {
+		t.Fatalf("pair with wrong fingerprint: %d %s", code, out)
+	}
+	if _, err := os.Stat(config); !os.IsNotExist(err) {
+		t.Fatalf("credentials saved after a failed pairing: %v", err)
+	}
+	// The rejected attempt used up the pair code.
+	out, code := elixctl(t, "-config", config, "pair", "-name", "ci", startPair(t, base))
+	if code != 0 {
+		t.Fatalf("pair: %d %s", code, out)
+	}
+	info, err := os.Stat(config)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("credentials file: %v %v", info, err)
+	}
+	creds, err := loadCredentials(config)
+	if err != nil || creds.Bridge != base || creds.AccessToken == "" {
+		t.Fatalf("credentials = %+v, %v", creds, err)
+	}
+
+	if out, code := elixctl(t, "-config", config, "submit", "-workspace", workspace, "-follow", "say hello"); code != 0 || !strings.Contains(out, "hello from codex") {
+		t.Fatalf("submit: %d %s", code, out)
+	}
+
+	// An expired access token is refreshed and the new tokens are saved.
+	creds.AccessToken = "stale"
+	if err := saveCredentials(config, creds); err != nil {
+		t.Fatal(err)
+	}
+	if out, code := elixctl(t, "-config", config, "devices", "list"); code != 0 || !strings.Contains(out, creds.Address) {
+		t.Fatalf("devices list: %d %s", code, out)
+	}
+	if refreshed, _ := loadCredentials(config); refreshed.AccessToken == "stale" || refreshed.RefreshToken == creds.RefreshToken {
+		t.Fatalf("tokens were not refreshed: %+v", refreshed)
+	}
+
+	if out, code := elixctl(t, "-config", config, "emergency", "stop", "drill"); code != 1 || !strings.Contains(out, "403") {
+		t.Fatalf("device emergency stop: %d %s", code, out)
+	}
+	if out, code := elixctl(t, "-config", config, "-token", "admin-token", "emergency", "stop", "drill"); code != 0 || !strings.Contains(out, `"drill"`) {
+		t.Fatalf("emergency stop: %d %s", code, out)
+	}
+	if out, code := elixctl(t, "-config", config, "submit", "-workspace", workspace, "again"); code != 1 || !strings.Contains(out, "emergency_stop_active") {
+		t.Fatalf("submit during emergency stop: %d %s", code, out)
+	}
+}
+
+// startPair starts a pairing with the admin token and returns its URI.
+func startPair(t *testing.T, base string) string {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, base+"/api/v3/pair/start", strings.NewReader(`{"permissions":["runs:submit","runs:read","devices:read","devices:write"]}`))
+	req.Header.Set("Authorization", "Bearer admin-token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var start struct {
+		URI string `json:"elix_uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&start); err != nil || start.URI == "" {
+		t.Fatalf("pair/start: status %d, %v", resp.StatusCode, err)
+	}
+	return start.URI
+}
+
+func TestParsePairURI(t *testing.T) {
+	got, err := parsePairURI("elix://bridge.local:8765/pair#code=ABC&challenge=xyz&fp=SHA256%3Aab%2Bc&tls=SHA256%3Apin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := pairTarget{Bridge: "https://bridge.local:8765", Code: "ABC", Challenge: "xyz", Fingerprint: "SHA256:ab+c", TLSPin: "SHA256:pin"}
+	if got != want {
+		t.Fatalf("target = %+v, want %+v", got, want)
+	}
+	if got, _ := parsePairURI("elix://127.0.0.1:8765/pair#code=A&challenge=b"); got.Bridge != "http://127.0.0.1:8765" {
+		t.Fatalf("bridge = %q", got.Bridge)
+	}
+	for _, bad := range []string{"https://x/pair#code=a&challenge=b", "elix://x/pair#code=a", "elix:///pair#code=a&challenge=b"} {
+		if _, err := parsePairURI(bad); err == nil {
+			t.Fatalf("%s: expected an error", bad)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"echohelix/internal/auth"
+	"echohelix/internal/wallet"
+)
+
+// pairTarget is what a pair code tells the device: where the bridge is,
+// the code and challenge to answer, and the identity and certificate
+// fingerprints to expect.
+type pairTarget struct {
+	Bridge      string
+	Code        string
+	Challenge   string
+	Fingerprint string
+	TLSPin      string
+}
+
+// parsePairURI reads an elix://host/pair#code=...&challenge=...&fp=...&tls=...
+// URI as shown by the bridge's pair QR code. The bridge is reached over
+// https when the URI pins a certificate.
+func parsePairURI(raw string) (pairTarget, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return pairTarget{}, err
+	}
+	if u.Scheme != "elix" || u.Host == "" {
+		return pairTarget{}, fmt.Errorf("not an elix:// pair URI")
+	}
+	frag, err := url.ParseQuery(u.EscapedFragment())
+	if err != nil {
+		return pairTarget{}, fmt.Errorf("pair URI fragment: %w", err)
+	}
+	t := pairTarget{
+		Code:        frag.Get("code"),
+		Challenge:   frag.Get("challenge"),
+		Fingerprint: frag.Get("fp"),
+		TLSPin:      frag.Get("tls"),
+	}
+	if t.Code == "" || t.Challenge == "" {
+		return pairTarget{}, fmt.Errorf("pair URI has no code or challenge")
+	}
+	scheme := "http"
+	if t.TLSPin != "" {
+		scheme = "https"
+	}
+	t.Bridge = scheme + "://" + u.Host
+	return t, nil
+}
+
+func cmdPair(ctx context.Context, a *app, args []string) error {
+	fs := subFlags(a, "pair")
+	code := fs.String("code", "", "pair code, when not pairing from a URI")
+	challenge := fs.String("challenge", "", "pair challenge, when not pairing from a URI")
+	fp := fs.String("fingerprint", "", "expected bridge identity fingerprint")
+	name := fs.String("name", "", "device name (default: host name)")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	var t pairTarget
+	switch {
+	case fs.NArg() == 1:
+		var err error
+		if t, err = parsePairURI(fs.Arg(0)); err != nil {
+			return err
+		}
+		if a.bridge != "" {
+			t.Bridge = a.bridge
+		}
+	case fs.NArg() == 0 && *code != "" && *challenge != "" && a.bridge != "":
+		t = pairTarget{Bridge: a.bridge, Code: *code, Challenge: *challenge}
+	default:
+		return errUsage
+	}
+	if *fp != "" {
+		t.Fingerprint = *fp
+	}
+	if t.Fingerprint == "" {
+		fmt.Fprintln(a.stderr, "elixctl: warning: no bridge fingerprint given, the bridge identity is not checked")
+	}
+	if *name == "" {
+		*name, _ = os.Hostname()
+	}
+
+	creds, err := pair(ctx, httpClient(t.TLSPin), t, *name)
+	if err != nil {
+		return err
+	}
+	if err := saveCredentials(a.configPath, creds); err != nil {
+		return err
+	}
+	return printJSON(a.stdout, map[string]any{
+		"address":            creds.Address,
+		"device_name":        creds.DeviceName,
+		"scopes":             creds.Scopes,
+		"bridge":             creds.Bridge,
+		"bridge_fingerprint": creds.BridgeFingerprint,
+		"credentials":        a.configPath,
+	})
+}
+
+// pair generates a device key, completes the pairing and checks the
+// bridge's signature over the challenge, so a relayed or spoofed response
+// is rejected before any token is kept.
+func pair(ctx context.Context, hc *http.Client, t pairTarget, name string) (*credentials, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	pubKey := base64.RawURLEncoding.EncodeToString(pub)
+	c := &client{base: strings.TrimRight(t.Bridge, "/"), http: hc}
+	var res auth.CompletePairResult
+	if err := c.call(ctx, http.MethodPost, "/api/v3/pair/complete", auth.CompletePairRequest{
+		PairCode:   t.Code,
+		PublicKey:  pubKey,
+		Signature:  base64.RawURLEncoding.EncodeToString(ed25519.Sign(priv, []byte(t.Challenge))),
+		DeviceName: name,
+	}, &res); err != nil {
+		return nil, err
+	}
+	if res.Address != wallet.AddressFromPublicKey(pub) {
+		return nil, fmt.Errorf("bridge paired address %s, not this device's key", res.Address)
+	}
+	if err := verifyBridge(t, pubKey, res); err != nil {
+		return nil, err
+	}
+	return &credentials{
+		Bridge:            c.base,
+		TLSPin:            t.TLSPin,
+		BridgeFingerprint: res.BridgeFingerprint,
+		Address:           res.Address,
+		DeviceName:        res.DeviceName,
+		PrivateKey:        base64.RawURLEncoding.EncodeToString(priv.Seed()),
+		Scopes:            res.Scopes,
+		AccessToken:       res.AccessToken,
+		RefreshToken:      res.RefreshToken,
+		ExpiresAt:         res.ExpiresAt,
+		RefreshExpiresAt:  res.RefreshExpiresAt,
+	}, nil
+}
+
+func verifyBridge(t pairTarget, devicePublicKey string, res auth.CompletePairResult) error {
+	bridgePub, err := base64.RawURLEncoding.DecodeString(res.BridgePublicKey)
+	if err != nil || len(bridgePub) != ed25519.PublicKeySize {
+		return errors.New("bridge sent no valid identity key")
+	}
+	fingerprint := auth.IdentityFingerprint(bridgePub)
+	if fingerprint != res.BridgeFingerprint {
+		return fmt.Errorf("bridge fingerprint %s does not match its key", res.BridgeFingerprint)
+	}
+	if t.Fingerprint != "" && fingerprint != t.Fingerprint {
+		return fmt.Errorf("bridge identity %s does not match the pair code's %s", fingerprint, t.Fingerprint)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(res.BridgeSignature)
+	if err != nil || !ed25519.Verify(bridgePub, auth.PairCompleteSignedMessage(t.Challenge, devicePublicKey, res.Address), sig) {
+		return errors.New("bridge signature over the pairing does not verify")
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+// Package aider adapts the Aider CLI (aider.chat) to the adapter runtime.
+package aider
+
+import (
+	"echohelix/internal/adapter/runtime"
+	"echohelix/internal/events"
+	adapterrpc "echohelix/internal/rpc/adapter"
+)
+
+const Backend = "aider"
+
+// CLI modes, set with AIDER_CLI_MODE. ModeYes (default) runs one --message
+// turn with --yes-always, so aider applies edits and commits without asking.
+// ModeMessage leaves aider's confirmations to the caller, who answers them
+// with run input on interactive runs. "stdin" writes the prompt to aider's
+// chat on stdin instead.
+const (
+	ModeYes     = "yes"
+	ModeMessage = "message"
+)
+
+// SandboxReadOnly runs aider with --dry-run, so edits are shown as patches
+// but not written or committed.
+const SandboxReadOnly = "read-only"
+
+// Config is the runtime config of the aider adapter.
+func Config() runtime.Config {
+	return runtime.Config{
+		Backend:        Backend,
+		NewMapper:      NewMapper,
+		ApplyRunOption: applyRunOptions,
+		ApplyPromptArg: applyPromptArg,
+		Downgrade:      runtime.DowngradeLegacyTooling,
+
+		CLIBinEnv:      "AIDER_CLI_BIN",
+		CLIBinDefault:  "aider",
+		CLIArgsEnv:     "AIDER_CLI_ARGS",
+		CLIArgsDefault: "--no-pretty --no-check-update --no-show-release-notes",
+		CLIModeEnv:     "AIDER_CLI_MODE",
+		CLIModeDefault: ModeYes,
+
+		SupportsCancel:         true,
+		SchemaVersions:         []string{events.SchemaVersionV1, events.SchemaVersionV2, events.SchemaVersionV3},
+		PreferredSchemaVersion: events.SchemaVersionV2,
+		Tools:                  []string{"editor", "git"},
+		ToolsEnv:               "AIDER_TOOLS",
+		ModelsEnv:              "AIDER_MODELS",
+		SandboxModes:           []string{SandboxReadOnly, "workspace-write"},
+		SandboxModesEnv:        "AIDER_SANDBOX_MODES",
+		StripANSI:              true,
+	}
+}
+
+func applyRunOptions(args []string, req *adapterrpc.StartRunRequest) []string {
+	if req.Model != "" {
+		args = append(args, "--model", req.Model)
+	}
+	if req.Sandbox == SandboxReadOnly {
+		args = append(args, "--dry-run", "--no-auto-commits")
+	}
+	return args
+}
+
+func applyPromptArg(args []string, mode, prompt string) []string {
+	switch mode {
+	case "stdin":
+		return args
+	case ModeMessage:
+		return append(args, "--message", prompt)
+	default:
+		return append(args, "--yes-always", "--message", prompt)
+	}
+}
@@ -0,0 +1,104 @@
+package aider
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	adapterrpc "echohelix/internal/rpc/adapter"
+)
+
+func TestMapperConvertsAiderTranscript(t *testing.T) {
+	transcript := []string{
+		"Aider v0.82.0",
+		"Main model: gpt-4o with diff edit format",
+		"Added app.py to the chat.",
+		"",
+		"I'll rename the greeting.",
+		"",
+		"app.py",
+		"```python",
+		"<<<<<<< SEARCH",
+		`print("hi")`,
+		"=======",
+		`print("hello")`,
+		">>>>>>> REPLACE",
+		"```",
+		"",
+		"Usage:",
+		"```bash",
+		"python app.py",
+		"```",
+		"```diff",
+		"--- a/util.py",
+		"+++ b/util.py",
+		"@@ -1 +1 @@",
+		"-x = 1",
+		"+x = 2",
+		"```",
+		"Tokens: 2.1k sent, 120 received. Cost: $0.01 message, $0.01 session.",
+		"Applied edit to app.py",
+		"Commit 1a2b3c4 refactor: Rename greeting",
+	}
+	m := NewMapper()
+	type got struct {
+		Type, Channel, Format string
+		Payload               map[string]any
+	}
+	var out []got
+	for _, line := range transcript {
+		if ev, ok := m(line, "stdout"); ok {
+			out = append(out, got{ev.Type, ev.Channel, ev.Format, ev.Payload})
+		}
+	}
+	if ev, ok := m("litellm warning", "stderr"); !ok || ev.Channel != "working" {
+		t.Fatalf("stderr = %+v, %v", ev, ok)
+	}
+
+	want := []got{
+		{"token", "working", "plain", map[string]any{"text": "Aider v0.82.0"}},
+		{"token", "working", "plain", map[string]any{"text": "Main model: gpt-4o with diff edit format"}},
+		{"token", "working", "plain", map[string]any{"text": "Added app.py to the chat."}},
+		{"token", "final", "markdown", map[string]any{"text": "I'll rename the greeting."}},
+		{"token", "final", "markdown", map[string]any{"text": "app.py"}},
+		{"patch", "working", "diff", map[string]any{"diff": "--- a/app.py\n+++ b/app.py\n-print(\"hi\")\n+print(\"hello\")\n", "files": []string{"app.py"}}},
+		{"token", "final", "markdown", map[string]any{"text": "Usage:"}},
+		{"token", "final", "markdown", map[string]any{"text": "```bash\npython app.py"}},
+		{"token", "final", "markdown", map[string]any{"text": "```"}},
+		{"patch", "working", "diff", map[string]any{"diff": "--- a/util.py\n+++ b/util.py\n@@ -1 +1 @@\n-x = 1\n+x = 2\n", "files": []string{"util.py"}}},
+		{"status", "system", "plain", map[string]any{"status": "running", "reason": "usage", "message": "Tokens: 2.1k sent, 120 received. Cost: $0.01 message, $0.01 session."}},
+		{"tool_result", "working", "plain", map[string]any{"name": "edit", "output": "Applied edit to app.py"}},
+		{"tool_call", "working", "json", map[string]any{"name": "git_commit", "arguments": map[string]any{"hash": "1a2b3c4", "message": "refactor: Rename greeting"}}},
+	}
+	if len(out) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(out), len(want), out)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(out[i], want[i]) {
+			t.Fatalf("event %d = %+v, want %+v", i, out[i], want[i])
+		}
+	}
+}
+
+func TestConfigBuildsAiderArguments(t *testing.T) {
+	cfg := Config()
+	if cfg.Backend != "aider" || cfg.NewMapper == nil {
+		t.Fatalf("config = %+v", cfg)
+	}
+	cases := []struct {
+		mode string
+		req  adapterrpc.StartRunRequest
+		want string
+	}{
+		{ModeYes, adapterrpc.StartRunRequest{Prompt: "fix it", Model: "sonnet"}, "--model sonnet --yes-always --message fix it"},
+		{ModeMessage, adapterrpc.StartRunRequest{Prompt: "fix it", Sandbox: SandboxReadOnly}, "--dry-run --no-auto-commits --message fix it"},
+		{"stdin", adapterrpc.StartRunRequest{Prompt: "fix it"}, ""},
+	}
+	for _, tc := range cases {
+		args := cfg.ApplyRunOption(nil, &tc.req)
+		args = cfg.ApplyPromptArg(args, tc.mode, tc.req.Prompt)
+		if got := strings.Join(args, " "); got != tc.want {
+			t.Fatalf("mode %s: args = %q, want %q", tc.mode, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package aider
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"echohelix/internal/adapter/runtime"
+	"echohelix/internal/events"
+)
+
+var (
+	appliedEditLine = regexp.MustCompile(`^Applied edit to (.+)$`)
+	commitLine      = regexp.MustCompile(`^Commit ([0-9a-f]{7,40}) (.+)$`)
+	// infoPrefixes start aider's banner and bookkeeping lines, which go to
+	// the working channel instead of the answer.
+	infoPrefixes = []string{
+		"Aider v", "Main model:", "Weak model:", "Editor model:", "Model:", "Git repo:",
+		"Repo-map:", "Added ", "Dropping ", "Use /help", "Creating empty file", "Cost:",
+	}
+)
+
+type blockKind int
+
+const (
+	blockNone blockKind = iota
+	blockSearch
+	blockReplace
+	blockDiff
+	// blockEditFence is the code fence around SEARCH/REPLACE blocks, up to
+	// its closing line.
+	blockEditFence
+)
+
+// mapper turns one run's aider output into events. Aider prints edits as
+// SEARCH/REPLACE blocks (or ```diff fences with --edit-format udiff) after
+// the file name, each of which becomes one patch event; chat is markdown.
+type mapper struct {
+	mu sync.Mutex
+
+	block   blockKind
+	fenced  bool
+	file    string
+	search  []string
+	replace []string
+	diff    []string
+	// pending holds a fence opener until the next line shows whether it
+	// opens an edit block or a code sample.
+	pending     string
+	inCodeFence bool
+	prev        string
+}
+
+// NewMapper returns a mapper for one run.
+func NewMapper() runtime.Mapper {
+	m := &mapper{}
+	return m.mapLine
+}
+
+func (m *mapper) mapLine(line, source string) (runtime.NormalizedEvent, bool) {
+	if source == "stderr" {
+		if strings.TrimSpace(line) == "" {
+			return runtime.NormalizedEvent{}, false
+		}
+		return working(line), true
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ev, ok := m.stdout(line)
+	if trimmed := strings.TrimSpace(line); trimmed != "" && !isFence(trimmed) {
+		m.prev = trimmed
+	}
+	return ev, ok
+}
+
+func (m *mapper) stdout(line string) (runtime.NormalizedEvent, bool) {
+	trimmed := strings.TrimSpace(line)
+	switch m.block {
+	case blockSearch:
+		if trimmed == "=======" {
+			m.block = blockReplace
+		} else {
+			m.search = append(m.search, line)
+		}
+		return runtime.NormalizedEvent{}, false
+	case blockReplace:
+		if strings.HasPrefix(trimmed, ">>>>>>> REPLACE") {
+			ev := m.editPatch()
+			m.block = blockNone
+			if m.fenced {
+				m.block = blockEditFence
+			}
+			return ev, true
+		}
+		m.replace = append(m.replace, line)
+		return runtime.NormalizedEvent{}, false
+	case blockDiff:
+		if isFence(trimmed) {
+			m.block = blockNone
+			return diffPatch(m.diff), len(m.diff) > 0
+		}
+		m.diff = append(m.diff, line)
+		return runtime.NormalizedEvent{}, false
+	case blockEditFence:
+		if isFence(trimmed) {
+			m.block = blockNone
+			return runtime.NormalizedEvent{}, false
+		}
+		if isSearch(trimmed) {
+			m.startSearch(m.file, true)
+			return runtime.NormalizedEvent{}, false
+		}
+		m.block = blockNone
+	}
+
+	if m.pending != "" {
+		opener := m.pending
+		m.pending = ""
+		if isSearch(trimmed) {
+			m.startSearch(m.file, true)
+			return runtime.NormalizedEvent{}, false
+		}
+		m.inCodeFence = !isFence(trimmed)
+		return answer(opener + "\n" + line), true
+	}
+	if isSearch(trimmed) {
+		m.startSearch(m.prev, false)
+		return runtime.NormalizedEvent{}, false
+	}
+	if isFence(trimmed) {
+		if m.inCodeFence {
+			m.inCodeFence = false
+			return answer(line), true
+		}
+		if strings.TrimLeft(trimmed, "`") == "diff" {
+			m.block, m.diff = blockDiff, nil
+			return runtime.NormalizedEvent{}, false
+		}
+		m.file, m.pending = m.prev, line
+		return runtime.NormalizedEvent{}, false
+	}
+	if m.inCodeFence {
+		return answer(line), true
+	}
+	if trimmed == "" {
+		return runtime.NormalizedEvent{}, false
+	}
+
+	if match := appliedEditLine.FindStringSubmatch(trimmed); match != nil {
+		return runtime.NormalizedEvent{
+			Type:    events.TypeToolResult,
+			Channel: events.ChannelWorking,
+			Format:  events.FormatPlain,
+			Role:    events.RoleAssistant,
+			Payload: map[string]any{"name": "edit", "output": trimmed},
+		}, true
+	}
+	if match := commitLine.FindStringSubmatch(trimmed); match != nil {
+		return runtime.NormalizedEvent{
+			Type:    events.TypeToolCall,
+			Channel: events.ChannelWorking,
+			Format:  events.FormatJSON,
+			Role:    events.RoleAssistant,
+			Payload: map[string]any{
+				"name":      "git_commit",
+				"arguments": map[string]any{"hash": match[1], "message": match[2]},
+			},
+		}, true
+	}
+	if strings.HasPrefix(trimmed, "Tokens:") {
+		return runtime.NormalizedEvent{
+			Type:    events.TypeStatus,
+			Channel: events.ChannelSystem,
+			Format:  events.FormatPlain,
+			Role:    events.RoleSystem,
+			Payload: map[string]any{"status": "running", "reason": "usage", "message": trimmed},
+		}, true
+	}
+	for _, prefix := range infoPrefixes {
+		if strings.HasPrefix(trimmed, prefix) {
+			return working(line), true
+		}
+	}
+	return answer(line), true
+}
+
+func (m *mapper) startSearch(file string, fenced bool) {
+	m.block, m.fenced, m.file = blockSearch, fenced, file
+	m.search, m.replace = nil, nil
+}
+
+// editPatch renders the SEARCH/REPLACE block as a diff of the replaced
+// lines; aider does not print line numbers, so it has no hunk headers.
+func (m *mapper) editPatch() runtime.NormalizedEvent {
+	var b strings.Builder
+	payload := map[string]any{}
+	if m.file != "" {
+		b.WriteString("--- a/" + m.file + "\n+++ b/" + m.file + "\n")
+		payload["files"] = []string{m.file}
+	}
+	for _, l := range m.search {
+		b.WriteString("-" + l + "\n")
+	}
+	for _, l := range m.replace {
+		b.WriteString("+" + l + "\n")
+	}
+	payload["diff"] = b.String()
+	return patch(payload)
+}
+
+func diffPatch(lines []string) runtime.NormalizedEvent {
+	var files []string
+	for _, l := range lines {
+		if name, ok := strings.CutPrefix(l, "+++ "); ok {
+			name = strings.TrimPrefix(strings.TrimSpace(name), "b/")
+			if name != "/dev/null" {
+				files = append(files, name)
+			}
+		}
+	}
+	payload := map[string]any{"diff": strings.Join(lines, "\n") + "\n"}
+	if len(files) > 0 {
+		payload["files"] = files
+	}
+	return patch(payload)
+}
+
+func patch(payload map[string]any) runtime.NormalizedEvent {
+	return runtime.NormalizedEvent{
+		Type:    events.TypePatch,
+		Channel: events.ChannelWorking,
+		Format:  events.FormatDiff,
+		Role:    events.RoleAssistant,
+		Payload: payload,
+	}
+}
+
+func answer(text string) runtime.NormalizedEvent {
+	return runtime.NormalizedEvent{
+		Type:    events.TypeToken,
+		Channel: events.ChannelFinal,
+		Format:  events.FormatMarkdown,
+		Role:    events.RoleAssistant,
+		Payload: map[string]any{"text": text},
+	}
+}
+
+func working(text string) runtime.NormalizedEvent {
+	return runtime.NormalizedEvent{
+		Type:    events.TypeToken,
+		Channel: events.ChannelWorking,
+		Format:  events.FormatPlain,
+		Role:    events.RoleAssistant,
+		Payload: map[string]any{"text": text},
+	}
+}
+
+func isFence(trimmed string) bool {
+	return strings.HasPrefix(trimmed, "```")
+}
+
+func isSearch(trimmed string) bool {
+	return strings.HasPrefix(trimmed, "<<<<<<< SEARCH")
+}
@@ -0,0 +1,133 @@
+// Package generic builds an adapter runtime.Config from a JSON spec, so an
+// arbitrary agent CLI can be bridged by describing its command line and how
+// its output lines map to events instead of writing a Mapper in Go.
+package generic
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"echohelix/internal/adapter/runtime"
+	adapterrpc "echohelix/internal/rpc/adapter"
+)
+
+// Spec is the generic adapter's config file.
+type Spec struct {
+	Backend string  `json:"backend"`
+	CLI     CLISpec `json:"cli"`
+	// StripANSI removes terminal escapes before lines are matched.
+	StripANSI    bool     `json:"strip_ansi,omitempty"`
+	Tools        []string `json:"tools,omitempty"`
+	Models       []string `json:"models,omitempty"`
+	Profiles     []string `json:"profiles,omitempty"`
+	SandboxModes []string `json:"sandbox_modes,omitempty"`
+	// Rules map output lines to events; the first match wins and lines no
+	// rule matches are dropped.
+	Rules []Rule `json:"rules"`
+}
+
+// CLISpec is how the CLI is started. Mode "arg" (default) passes the prompt
+// as the last argument, after PromptFlag when set; "stdin" writes it to
+// stdin. The *Flag fields pass the run's model, profile and sandbox when the
+// run sets them. <BACKEND>_CLI_BIN, _CLI_ARGS and _CLI_MODE override Bin,
+// Args and Mode like they do for the bundled adapters.
+type CLISpec struct {
+	Bin         string `json:"bin"`
+	Args        string `json:"args,omitempty"`
+	Mode        string `json:"mode,omitempty"`
+	PromptFlag  string `json:"prompt_flag,omitempty"`
+	ModelFlag   string `json:"model_flag,omitempty"`
+	ProfileFlag string `json:"profile_flag,omitempty"`
+	SandboxFlag string `json:"sandbox_flag,omitempty"`
+}
+
+// LoadSpec reads and validates a generic adapter spec.
+func LoadSpec(path string) (Spec, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Spec{}, fmt.Errorf("read adapter spec: %w", err)
+	}
+	var spec Spec
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		return Spec{}, fmt.Errorf("parse adapter spec: %w", err)
+	}
+	return spec, nil
+}
+
+// LoadConfig reads the spec at path and returns the runtime config for it.
+func LoadConfig(path string) (runtime.Config, error) {
+	spec, err := LoadSpec(path)
+	if err != nil {
+		return runtime.Config{}, err
+	}
+	return spec.Config()
+}
+
+// Config validates the spec and compiles its rules into a runtime config.
+func (s Spec) Config() (runtime.Config, error) {
+	backend := strings.TrimSpace(s.Backend)
+	if backend == "" {
+		return runtime.Config{}, fmt.Errorf("adapter spec: backend is required")
+	}
+	if strings.TrimSpace(s.CLI.Bin) == "" {
+		return runtime.Config{}, fmt.Errorf("adapter spec: cli.bin is required")
+	}
+	switch s.CLI.Mode {
+	case "", "arg", "stdin":
+	default:
+		return runtime.Config{}, fmt.Errorf("adapter spec: cli.mode must be arg or stdin")
+	}
+	mapper, err := NewMapper(s.Rules)
+	if err != nil {
+		return runtime.Config{}, fmt.Errorf("adapter spec: %w", err)
+	}
+	mode := s.CLI.Mode
+	if mode == "" {
+		mode = "arg"
+	}
+	prefix := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(backend))
+	cli := s.CLI
+	return runtime.Config{
+		Backend:        backend,
+		Mapper:         mapper,
+		ApplyRunOption: cli.applyRunOptions,
+		ApplyPromptArg: cli.applyPrompt,
+		CLIBinEnv:      prefix + "_CLI_BIN",
+		CLIBinDefault:  cli.Bin,
+		CLIArgsEnv:     prefix + "_CLI_ARGS",
+		CLIArgsDefault: cli.Args,
+		CLIModeEnv:     prefix + "_CLI_MODE",
+		CLIModeDefault: mode,
+		SupportsCancel: true,
+		Tools:          s.Tools,
+		Models:         s.Models,
+		Profiles:       s.Profiles,
+		SandboxModes:   s.SandboxModes,
+		StripANSI:      s.StripANSI,
+	}, nil
+}
+
+func (c CLISpec) applyRunOptions(args []string, req *adapterrpc.StartRunRequest) []string {
+	for _, opt := range []struct{ flag, value string }{
+		{c.ModelFlag, req.Model},
+		{c.ProfileFlag, req.Profile},
+		{c.SandboxFlag, req.Sandbox},
+	} {
+		if opt.flag != "" && opt.value != "" {
+			args = append(args, opt.flag, opt.value)
+		}
+	}
+	return args
+}
+
+func (c CLISpec) applyPrompt(args []string, mode, prompt string) []string {
+	if mode == "stdin" {
+		return args
+	}
+	if c.PromptFlag != "" {
+		args = append(args, c.PromptFlag)
+	}
+	return append(args, prompt)
+}
@@ -0,0 +1,133 @@
+package generic
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"echohelix/internal/adapter/runtime"
+	adapterrpc "echohelix/internal/rpc/adapter"
+)
+
+func TestMapperAppliesFirstMatchingRule(t *testing.T) {
+	mapper, err := NewMapper([]Rule{
+		{JSON: map[string]string{"$.type": "debug"}, Drop: true},
+		{JSON: map[string]string{"$.type": "text"}, Type: "token", Format: "markdown", Payload: map[string]string{"text": "$.part.text"}},
+		{JSON: map[string]string{"$.type": "tool_use"}, Type: "tool_call", Channel: "working", Payload: map[string]string{"name": "$.tool", "arguments": "$.input", "summary": "${$.tool} on ${$.input.path}"}},
+		{Source: "stdout", Regex: `^Applied edit to (?P<path>\S+)$`, Type: "patch", Payload: map[string]string{"path": "${path}", "note": "${source}: ${0}"}},
+		{Source: "stderr", Type: "error", Payload: map[string]string{"message": "${line}"}},
+		{Source: "stdout", Type: "token"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		line, source string
+		want         runtime.NormalizedEvent
+		ok           bool
+	}{
+		{`{"type":"debug","msg":"x"}`, "stdout", runtime.NormalizedEvent{}, false},
+		{`{"type":"text","part":{"text":"**hi**"}}`, "stdout", runtime.NormalizedEvent{Type: "token", Channel: "final", Format: "markdown", Role: "assistant", Payload: map[string]any{"text": "**hi**"}}, true},
+		{`{"type":"tool_use","tool":"read","input":{"path":"a.go"}}`, "stdout", runtime.NormalizedEvent{Type: "tool_call", Channel: "working", Format: "plain", Role: "system", Payload: map[string]any{"name": "read", "arguments": map[string]any{"path": "a.go"}, "summary": "read on a.go"}}, true},
+		{"Applied edit to main.go", "stdout", runtime.NormalizedEvent{Type: "patch", Channel: "system", Format: "plain", Role: "system", Payload: map[string]any{"path": "main.go", "note": "stdout: Applied edit to main.go"}}, true},
+		{"boom", "stderr", runtime.NormalizedEvent{Type: "error", Channel: "system", Format: "plain", Role: "system", Payload: map[string]any{"message": "boom"}}, true},
+		{"plain text", "stdout", runtime.NormalizedEvent{Type: "token", Channel: "final", Format: "plain", Role: "assistant", Payload: map[string]any{"text": "plain text"}}, true},
+		{`{"type":"unknown"}`, "stderr", runtime.NormalizedEvent{Type: "error", Channel: "system", Format: "plain", Role: "system", Payload: map[string]any{"message": `{"type":"unknown"}`}}, true},
+	}
+	for _, tc := range cases {
+		got, ok := mapper(tc.line, tc.source)
+		if ok != tc.ok || (ok && !reflect.DeepEqual(got, tc.want)) {
+			t.Fatalf("map(%q, %s) = %+v, %v; want %+v, %v", tc.line, tc.source, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestSpecRejectsInvalidRules(t *testing.T) {
+	base := Spec{Backend: "aider", CLI: CLISpec{Bin: "aider"}}
+	for name, rules := range map[string][]Rule{
+		"none":         nil,
+		"type":         {{Type: "message"}},
+		"source":       {{Source: "pty", Type: "token"}},
+		"regex":        {{Regex: "(", Type: "token"}},
+		"json path":    {{JSON: map[string]string{"type": "x"}, Type: "token"}},
+		"payload path": {{Type: "token", Payload: map[string]string{"text": "$.a[x]"}}},
+		"group":        {{Regex: `^(\w+)$`, Type: "token", Payload: map[string]string{"text": "${name}"}}},
+		"no regex":     {{Type: "token", Payload: map[string]string{"text": "${1}"}}},
+		"channel":      {{Type: "tool_call", Channel: "tool"}},
+		"format":       {{Type: "token", Format: "html"}},
+		"role":         {{Type: "token", Role: "user"}},
+	} {
+		spec := base
+		spec.Rules = rules
+		if _, err := spec.Config(); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+	if _, err := (Spec{CLI: CLISpec{Bin: "x"}, Rules: []Rule{{Type: "token"}}}).Config(); err == nil {
+		t.Fatal("expected missing backend to be rejected")
+	}
+}
+
+func TestLoadConfigRunsCLIThroughRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "adapter.json")
+	spec := `{
+		"backend": "my-cli",
+		"cli": {"bin": "sh", "args": "-c", "model_flag": "--model"},
+		"models": ["small"],
+		"rules": [
+			{"json": {"$.kind": "say"}, "type": "token", "payload": {"text": "$.text"}},
+			{"json": {}, "drop": true}
+		]
+	}`
+	if err := os.WriteFile(path, []byte(spec), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Backend != "my-cli" || cfg.CLIBinEnv != "MY_CLI_CLI_BIN" || cfg.CLIModeDefault != "arg" {
+		t.Fatalf("config = %+v", cfg)
+	}
+	if got := cfg.ApplyRunOption([]string{"-c"}, &adapterrpc.StartRunRequest{Model: "small"}); !reflect.DeepEqual(got, []string{"-c", "--model", "small"}) {
+		t.Fatalf("run options = %v", got)
+	}
+
+	s := runtime.NewServer(cfg)
+	prompt := `echo '{"kind":"say","text":"hello"}'; echo '{"kind":"noise"}'; sleep 0.2`
+	if res, err := s.StartRun(context.Background(), &adapterrpc.StartRunRequest{RunID: "r1", WorkspacePath: t.TempDir(), Prompt: prompt}); err != nil || !res.Accepted {
+		t.Fatalf("start: %v %+v", err, res)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream := &collectStream{ctx: ctx}
+	if err := s.StreamEvents(&adapterrpc.StreamEventsRequest{RunID: "r1"}, stream); err != nil {
+		t.Fatal(err)
+	}
+	var tokens []string
+	for _, ev := range stream.events {
+		if ev.Type == "token" {
+			tokens = append(tokens, ev.Payload["text"].(string))
+		}
+	}
+	if strings.Join(tokens, "|") != "hello" {
+		t.Fatalf("tokens = %v, events = %+v", tokens, stream.events)
+	}
+}
+
+type collectStream struct {
+	adapterrpc.AdapterStreamEventsServer
+	ctx    context.Context
+	events []*adapterrpc.AgentEvent
+}
+
+func (c *collectStream) Context() context.Context { return c.ctx }
+
+func (c *collectStream) Send(ev *adapterrpc.AgentEvent) error {
+	c.events = append(c.events, ev)
+	return nil
+}
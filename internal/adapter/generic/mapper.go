@@ -0,0 +1,357 @@
+package generic
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"echohelix/internal/adapter/runtime"
+	"echohelix/internal/events"
+)
+
+var eventTypes = []string{"token", "tool_call", "tool_result", "patch", "status", "done", "error"}
+
+// Rule maps the output lines it matches to one event. A line matches when
+// it comes from Source (stdout, stderr, or either when empty), matches
+// Regex when set, and, when JSON is set, parses as JSON with every listed
+// JSONPath holding the given value ("*" only requires the path to exist).
+// An empty JSON object just requires a JSON line.
+//
+// Payload values that are a bare JSONPath ("$.content") copy that value
+// from the line; other values are templates where ${line}, ${source}, regex
+// groups (${1}, ${name}) and ${$.path} are replaced; a rule that reads a
+// JSONPath only matches JSON lines. Without Payload the event carries
+// {"text": line}. Drop discards matching lines instead.
+type Rule struct {
+	Source  string            `json:"source,omitempty"`
+	Regex   string            `json:"regex,omitempty"`
+	JSON    map[string]string `json:"json,omitempty"`
+	Drop    bool              `json:"drop,omitempty"`
+	Type    string            `json:"type,omitempty"`
+	Channel string            `json:"channel,omitempty"`
+	Format  string            `json:"format,omitempty"`
+	Role    string            `json:"role,omitempty"`
+	Payload map[string]string `json:"payload,omitempty"`
+}
+
+type compiledRule struct {
+	Rule
+	re      *regexp.Regexp
+	json    bool
+	when    []jsonCond
+	payload []payloadField
+}
+
+type jsonCond struct {
+	path jsonPath
+	want string
+}
+
+type payloadField struct {
+	key      string
+	path     jsonPath // set when the value is a bare JSONPath
+	template string
+}
+
+var templateVar = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// NewMapper compiles rules into a runtime.Mapper.
+func NewMapper(rules []Rule) (runtime.Mapper, error) {
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("at least one rule is required")
+	}
+	compiled := make([]compiledRule, 0, len(rules))
+	for i, r := range rules {
+		c, err := compileRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		compiled = append(compiled, c)
+	}
+	return func(line, source string) (runtime.NormalizedEvent, bool) {
+		var doc any
+		parsed, isJSON := false, false
+		for _, r := range compiled {
+			if r.Source != "" && r.Source != source {
+				continue
+			}
+			var groups []string
+			if r.re != nil {
+				if groups = r.re.FindStringSubmatch(line); groups == nil {
+					continue
+				}
+			}
+			if r.json {
+				if !parsed {
+					parsed = true
+					isJSON = json.Unmarshal([]byte(line), &doc) == nil
+				}
+				if !isJSON || !r.matchJSON(doc) {
+					continue
+				}
+			}
+			if r.Drop {
+				return runtime.NormalizedEvent{}, false
+			}
+			return r.event(line, source, doc, groups), true
+		}
+		return runtime.NormalizedEvent{}, false
+	}, nil
+}
+
+func compileRule(r Rule) (compiledRule, error) {
+	c := compiledRule{Rule: r, json: r.JSON != nil}
+	switch r.Source {
+	case "", "stdout", "stderr":
+	default:
+		return c, fmt.Errorf("source must be stdout or stderr")
+	}
+	if r.Regex != "" {
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return c, fmt.Errorf("regex: %w", err)
+		}
+		c.re = re
+	}
+	for p, want := range r.JSON {
+		path, err := parseJSONPath(p)
+		if err != nil {
+			return c, err
+		}
+		c.when = append(c.when, jsonCond{path: path, want: want})
+	}
+	if r.Drop {
+		return c, nil
+	}
+	if !contains(eventTypes, r.Type) {
+		return c, fmt.Errorf("type must be one of %s", strings.Join(eventTypes, ", "))
+	}
+	for _, f := range []struct {
+		name, value string
+		allowed     []string
+	}{
+		{"channel", r.Channel, events.AllowedChannels()},
+		{"format", r.Format, events.AllowedFormats()},
+		{"role", r.Role, events.AllowedRoles()},
+	} {
+		if f.value != "" && !contains(f.allowed, f.value) {
+			return c, fmt.Errorf("%s must be one of %s", f.name, strings.Join(f.allowed, ", "))
+		}
+	}
+	for key, value := range r.Payload {
+		f := payloadField{key: key}
+		if strings.HasPrefix(value, "$") && !strings.HasPrefix(value, "${") {
+			path, err := parseJSONPath(value)
+			if err != nil {
+				return c, fmt.Errorf("payload %s: %w", key, err)
+			}
+			f.path = path
+			c.json = true
+		} else {
+			for _, m := range templateVar.FindAllStringSubmatch(value, -1) {
+				if strings.HasPrefix(m[1], "$") {
+					if _, err := parseJSONPath(m[1]); err != nil {
+						return c, fmt.Errorf("payload %s: %w", key, err)
+					}
+					c.json = true
+				} else if err := checkTemplateName(m[1], c.re); err != nil {
+					return c, fmt.Errorf("payload %s: %w", key, err)
+				}
+			}
+			f.template = value
+		}
+		c.payload = append(c.payload, f)
+	}
+	return c, nil
+}
+
+func checkTemplateName(name string, re *regexp.Regexp) error {
+	if name == "line" || name == "source" {
+		return nil
+	}
+	if re == nil {
+		return fmt.Errorf("${%s} needs a regex", name)
+	}
+	if n, err := strconv.Atoi(name); err == nil {
+		if n < 0 || n > re.NumSubexp() {
+			return fmt.Errorf("regex has no group %d", n)
+		}
+		return nil
+	}
+	if re.SubexpIndex(name) < 0 {
+		return fmt.Errorf("regex has no group %q", name)
+	}
+	return nil
+}
+
+func (r compiledRule) matchJSON(doc any) bool {
+	for _, c := range r.when {
+		v, ok := c.path.get(doc)
+		if !ok {
+			return false
+		}
+		if c.want != "*" && jsonString(v) != c.want {
+			return false
+		}
+	}
+	return true
+}
+
+func (r compiledRule) event(line, source string, doc any, groups []string) runtime.NormalizedEvent {
+	ev := runtime.NormalizedEvent{
+		Type:    r.Type,
+		Channel: r.Channel,
+		Format:  r.Format,
+		Role:    r.Role,
+		Payload: map[string]any{},
+	}
+	if ev.Channel == "" {
+		ev.Channel = "system"
+		if ev.Type == "token" {
+			ev.Channel = "final"
+		}
+	}
+	if ev.Format == "" {
+		ev.Format = "plain"
+	}
+	if ev.Role == "" {
+		ev.Role = "system"
+		if ev.Type == "token" {
+			ev.Role = "assistant"
+		}
+	}
+	if len(r.payload) == 0 {
+		ev.Payload["text"] = line
+		return ev
+	}
+	for _, f := range r.payload {
+		if f.path != nil {
+			if v, ok := f.path.get(doc); ok {
+				ev.Payload[f.key] = v
+			}
+			continue
+		}
+		ev.Payload[f.key] = templateVar.ReplaceAllStringFunc(f.template, func(m string) string {
+			name := m[2 : len(m)-1]
+			switch {
+			case name == "line":
+				return line
+			case name == "source":
+				return source
+			case strings.HasPrefix(name, "$"):
+				path, _ := parseJSONPath(name)
+				v, _ := path.get(doc)
+				return jsonString(v)
+			}
+			if n, err := strconv.Atoi(name); err == nil {
+				if n < len(groups) {
+					return groups[n]
+				}
+				return ""
+			}
+			if i := r.re.SubexpIndex(name); i >= 0 && i < len(groups) {
+				return groups[i]
+			}
+			return ""
+		})
+	}
+	return ev
+}
+
+// jsonPath is the JSONPath subset rules use: $ followed by .name, ['name']
+// and [index] steps.
+type jsonPath []jsonStep
+
+type jsonStep struct {
+	key   string
+	index int
+	isIdx bool
+}
+
+func parseJSONPath(p string) (jsonPath, error) {
+	if !strings.HasPrefix(p, "$") {
+		return nil, fmt.Errorf("JSONPath %q must start with $", p)
+	}
+	path := jsonPath{}
+	rest := p[1:]
+	for rest != "" {
+		switch {
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			if key == "" {
+				return nil, fmt.Errorf("JSONPath %q has an empty key", p)
+			}
+			path = append(path, jsonStep{key: key})
+			rest = rest[end+1:]
+		case strings.HasPrefix(rest, "['"):
+			end := strings.Index(rest, "']")
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath %q has an unterminated ['", p)
+			}
+			path = append(path, jsonStep{key: rest[2:end]})
+			rest = rest[end+2:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath %q has an unterminated [", p)
+			}
+			n, err := strconv.Atoi(rest[1:end])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("JSONPath %q has an invalid index", p)
+			}
+			path = append(path, jsonStep{index: n, isIdx: true})
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("JSONPath %q is not supported", p)
+		}
+	}
+	return path, nil
+}
+
+func (p jsonPath) get(doc any) (any, bool) {
+	cur := doc
+	for _, step := range p {
+		if step.isIdx {
+			arr, ok := cur.([]any)
+			if !ok || step.index >= len(arr) {
+				return nil, false
+			}
+			cur = arr[step.index]
+			continue
+		}
+		obj, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = obj[step.key]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func jsonString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
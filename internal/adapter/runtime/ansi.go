@@ -0,0 +1,22 @@
+package runtime
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ansiPattern matches CSI sequences, OSC sequences (BEL or ST terminated)
+// and other two-byte escapes.
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[0-Z\\-_]`)
+
+// stripANSI removes terminal escapes from line and keeps only the text after
+// the last carriage return, which is what a terminal would show for
+// progress-bar style rewrites.
+func stripANSI(line string) string {
+	line = ansiPattern.ReplaceAllString(line, "")
+	line = strings.TrimRight(line, "\r")
+	if i := strings.LastIndexByte(line, '\r'); i >= 0 {
+		line = line[i+1:]
+	}
+	return line
+}
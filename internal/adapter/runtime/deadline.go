@@ -0,0 +1,36 @@
+package runtime
+
+import (
+	"context"
+	"time"
+
+	adapterrpc "echohelix/internal/rpc/adapter"
+)
+
+// ExtendRun moves a running run's timeout to TimeoutSec seconds from now.
+// Runs started without a timeout have nothing to move and report success.
+func (s *Server) ExtendRun(ctx context.Context, req *adapterrpc.ExtendRunRequest) (*adapterrpc.ExtendRunResponse, error) {
+	if req.TimeoutSec <= 0 {
+		return &adapterrpc.ExtendRunResponse{Extended: false, Error: "timeout_sec must be positive"}, nil
+	}
+	rs, err := s.getRun(req.RunID)
+	if err != nil {
+		return &adapterrpc.ExtendRunResponse{Extended: false, Error: err.Error()}, nil
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.closed {
+		return &adapterrpc.ExtendRunResponse{Extended: false, Error: "run has finished"}, nil
+	}
+	if rs.paused {
+		return &adapterrpc.ExtendRunResponse{Extended: false, Error: "run is paused"}, nil
+	}
+	if rs.deadline != nil {
+		if !rs.deadline.Stop() {
+			return &adapterrpc.ExtendRunResponse{Extended: false, Error: "run deadline has passed"}, nil
+		}
+		rs.deadlineAt = time.Now().Add(time.Duration(req.TimeoutSec) * time.Second)
+		rs.deadline.Reset(time.Duration(req.TimeoutSec) * time.Second)
+	}
+	return &adapterrpc.ExtendRunResponse{Extended: true}, nil
+}
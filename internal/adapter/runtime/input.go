@@ -0,0 +1,165 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	adapterrpc "echohelix/internal/rpc/adapter"
+)
+
+// Interactive runs keep the CLI's stdin open after the prompt. When the CLI
+// stops writing with an unanswered prompt on stdout, a needs_input status is
+// published so clients know to send a line with StreamInput.
+
+const (
+	defaultInputIdle = 2 * time.Second
+	maxPromptTail    = 512
+)
+
+type inputState struct {
+	mu      sync.Mutex
+	w       io.WriteCloser
+	closed  bool
+	tail    []byte
+	lastOut time.Time
+	waiting bool
+}
+
+func newInputState(w io.WriteCloser) *inputState {
+	return &inputState{w: w, lastOut: time.Now()}
+}
+
+// Write records the end of the CLI's stdout; it is teed from the pipe.
+func (in *inputState) Write(p []byte) (int, error) {
+	in.mu.Lock()
+	in.tail = append(in.tail, p...)
+	if len(in.tail) > maxPromptTail {
+		in.tail = in.tail[len(in.tail)-maxPromptTail:]
+	}
+	in.lastOut = time.Now()
+	in.waiting = false
+	in.mu.Unlock()
+	return len(p), nil
+}
+
+func (in *inputState) send(data string, eof bool) error {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.closed {
+		return fmt.Errorf("stdin is closed")
+	}
+	if data != "" {
+		if !strings.HasSuffix(data, "\n") {
+			data += "\n"
+		}
+		if _, err := io.WriteString(in.w, data); err != nil {
+			return err
+		}
+	}
+	if eof {
+		in.closed = true
+		if err := in.w.Close(); err != nil {
+			return err
+		}
+	}
+	in.waiting = false
+	in.lastOut = time.Now()
+	return nil
+}
+
+func (in *inputState) close() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if !in.closed {
+		in.closed = true
+		_ = in.w.Close()
+	}
+}
+
+// pendingPrompt returns the prompt the CLI seems to be waiting on once
+// stdout has been quiet for idle: an unterminated last line, or a last line
+// ending in "?" or ":". It reports each quiet period once.
+func (in *inputState) pendingPrompt(idle time.Duration, now time.Time) (string, bool) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.closed || in.waiting || len(in.tail) == 0 || now.Sub(in.lastOut) < idle {
+		return "", false
+	}
+	tail := in.tail
+	partial := tail[len(tail)-1] != '\n'
+	tail = bytes.TrimRight(tail, "\r\n")
+	if i := bytes.LastIndexByte(tail, '\n'); i >= 0 {
+		tail = tail[i+1:]
+	}
+	prompt := strings.TrimSpace(string(tail))
+	if prompt == "" {
+		return "", false
+	}
+	if !partial && !strings.HasSuffix(prompt, "?") && !strings.HasSuffix(prompt, ":") {
+		return "", false
+	}
+	in.waiting = true
+	return prompt, true
+}
+
+// watchInput publishes needs_input while the run's CLI is alive.
+func (s *Server) watchInput(rs *runState, in *inputState, done <-chan struct{}) {
+	idle := s.cfg.InputIdle
+	if idle <= 0 {
+		idle = defaultInputIdle
+	}
+	ticker := time.NewTicker(idle / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			// A paused CLI is quiet because it is stopped, not waiting.
+			if rs.isPaused() {
+				continue
+			}
+			if prompt, ok := in.pendingPrompt(idle, now); ok {
+				rs.publish(NormalizedEvent{
+					Type:    "status",
+					Channel: "system",
+					Format:  "json",
+					Role:    "system",
+					Payload: map[string]any{"status": "needs_input", "reason": "stdin_prompt", "message": prompt},
+				}, "adapter")
+			}
+		}
+	}
+}
+
+func (s *Server) StreamInput(ctx context.Context, req *adapterrpc.StreamInputRequest) (*adapterrpc.StreamInputResponse, error) {
+	rs, err := s.getRun(req.RunID)
+	if err != nil {
+		return &adapterrpc.StreamInputResponse{Accepted: false, Error: err.Error()}, nil
+	}
+	rs.mu.RLock()
+	in, closed := rs.input, rs.closed
+	rs.mu.RUnlock()
+	if closed {
+		return &adapterrpc.StreamInputResponse{Accepted: false, Error: "run has finished"}, nil
+	}
+	if in == nil {
+		return &adapterrpc.StreamInputResponse{Accepted: false, Error: "run is not interactive"}, nil
+	}
+	if err := in.send(req.Data, req.EOF); err != nil {
+		return &adapterrpc.StreamInputResponse{Accepted: false, Error: err.Error()}, nil
+	}
+	rs.publish(NormalizedEvent{
+		Type:    "status",
+		Channel: "system",
+		Format:  "json",
+		Role:    "system",
+		Payload: map[string]any{"status": "running", "reason": "input_received"},
+	}, "adapter")
+	return &adapterrpc.StreamInputResponse{Accepted: true}, nil
+}
@@ -0,0 +1,186 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"echohelix/internal/events"
+	adapterrpc "echohelix/internal/rpc/adapter"
+	"echohelix/internal/rpc/transport"
+	"echohelix/internal/tracing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MethodMetrics counts the calls to one adapter RPC.
+type MethodMetrics struct {
+	Method        string        `json:"method"`
+	Calls         int64         `json:"calls"`
+	Errors        int64         `json:"errors"`
+	Panics        int64         `json:"panics"`
+	TotalDuration time.Duration `json:"total_duration"`
+}
+
+type rpcMetrics struct {
+	mu      sync.Mutex
+	methods map[string]*MethodMetrics
+}
+
+func (m *rpcMetrics) observe(method string, d time.Duration, err error, panicked bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.methods == nil {
+		m.methods = map[string]*MethodMetrics{}
+	}
+	mm, ok := m.methods[method]
+	if !ok {
+		mm = &MethodMetrics{Method: method}
+		m.methods[method] = mm
+	}
+	mm.Calls++
+	mm.TotalDuration += d
+	if err != nil {
+		mm.Errors++
+	}
+	if panicked {
+		mm.Panics++
+	}
+}
+
+// Metrics returns per-method RPC counters, sorted by method.
+func (s *Server) Metrics() []MethodMetrics {
+	s.metrics.mu.Lock()
+	defer s.metrics.mu.Unlock()
+	out := make([]MethodMetrics, 0, len(s.metrics.methods))
+	for _, mm := range s.metrics.methods {
+		out = append(out, *mm)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Method < out[j].Method })
+	return out
+}
+
+// StreamStats reports dropped events, gap markers and evicted subscribers
+// across the adapter's event streams.
+func (s *Server) StreamStats() events.BackpressureStats {
+	n := 0
+	s.mu.RLock()
+	for _, rs := range s.runs {
+		rs.mu.RLock()
+		n += len(rs.subs)
+		rs.mu.RUnlock()
+	}
+	s.mu.RUnlock()
+	return s.backpressure.Stats(n)
+}
+
+// ServerOptions returns GRPCServerOptions plus the server's tracing,
+// logging, metrics and panic recovery interceptors. Adapter mains pass them to
+// grpc.NewServer.
+func (s *Server) ServerOptions() ([]grpc.ServerOption, error) {
+	opts, err := transport.FromEnv().ServerOptions()
+	if err != nil {
+		return nil, err
+	}
+	return append(opts,
+		grpc.ChainUnaryInterceptor(tracing.UnaryServerInterceptor, s.UnaryInterceptor),
+		grpc.ChainStreamInterceptor(tracing.StreamServerInterceptor, s.StreamInterceptor),
+	), nil
+}
+
+// UnaryInterceptor logs and counts each call and turns a handler panic into
+// codes.Internal plus an error event on the run named in the request.
+func (s *Server) UnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	start := time.Now()
+	runID := requestRunID(req)
+	panicked := false
+	defer func() {
+		if p := recover(); p != nil {
+			panicked = true
+			err = s.recoverRPC(info.FullMethod, runID, p)
+		}
+		s.observeRPC(info.FullMethod, runID, start, err, panicked)
+	}()
+	return handler(ctx, req)
+}
+
+// StreamInterceptor is UnaryInterceptor for streaming calls. The run ID is
+// taken from the first message the handler receives.
+func (s *Server) StreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	start := time.Now()
+	wrapped := &recordingStream{ServerStream: ss}
+	panicked := false
+	defer func() {
+		if p := recover(); p != nil {
+			panicked = true
+			err = s.recoverRPC(info.FullMethod, wrapped.runID, p)
+		}
+		s.observeRPC(info.FullMethod, wrapped.runID, start, err, panicked)
+	}()
+	return handler(srv, wrapped)
+}
+
+func (s *Server) recoverRPC(method, runID string, p any) error {
+	log.Printf("adapter panic method=%s run_id=%s: %v\n%s", method, runID, p, debug.Stack())
+	if runID != "" {
+		if rs, err := s.getRun(runID); err == nil {
+			rs.publish(NormalizedEvent{
+				Type:    "error",
+				Channel: "system",
+				Format:  "plain",
+				Role:    "system",
+				Payload: map[string]any{"message": fmt.Sprintf("adapter panic in %s: %v", method, p)},
+			}, "adapter")
+		}
+	}
+	return status.Errorf(codes.Internal, "adapter panic in %s", method)
+}
+
+func (s *Server) observeRPC(method, runID string, start time.Time, err error, panicked bool) {
+	d := time.Since(start)
+	s.metrics.observe(method, d, err, panicked)
+	// Health is polled by the bridge; only log it when it fails.
+	if method == adapterrpc.MethodHealth && err == nil {
+		return
+	}
+	log.Printf("adapter rpc method=%s run_id=%s code=%s duration=%s", method, runID, status.Code(err), d.Round(time.Microsecond))
+}
+
+type recordingStream struct {
+	grpc.ServerStream
+	runID string
+}
+
+func (r *recordingStream) RecvMsg(m any) error {
+	err := r.ServerStream.RecvMsg(m)
+	if err == nil && r.runID == "" {
+		r.runID = requestRunID(m)
+	}
+	return err
+}
+
+func requestRunID(req any) string {
+	switch r := req.(type) {
+	case *adapterrpc.StartRunRequest:
+		return r.RunID
+	case *adapterrpc.StreamEventsRequest:
+		return r.RunID
+	case *adapterrpc.CancelRunRequest:
+		return r.RunID
+	case *adapterrpc.StreamInputRequest:
+		return r.RunID
+	case *adapterrpc.ExtendRunRequest:
+		return r.RunID
+	case *adapterrpc.PauseRunRequest:
+		return r.RunID
+	case *adapterrpc.ResumeRunRequest:
+		return r.RunID
+	}
+	return ""
+}
@@ -0,0 +1,76 @@
+package runtime
+
+import (
+	"context"
+	"time"
+
+	adapterrpc "echohelix/internal/rpc/adapter"
+)
+
+// PauseRun stops the run's process group and its timeout clock. The CLI
+// keeps its state and continues where it was on ResumeRun.
+func (s *Server) PauseRun(ctx context.Context, req *adapterrpc.PauseRunRequest) (*adapterrpc.PauseRunResponse, error) {
+	if !pauseSupported {
+		return &adapterrpc.PauseRunResponse{Paused: false, Error: "pause is not supported on this platform"}, nil
+	}
+	rs, err := s.getRun(req.RunID)
+	if err != nil {
+		return &adapterrpc.PauseRunResponse{Paused: false, Error: err.Error()}, nil
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	switch {
+	case rs.closed:
+		return &adapterrpc.PauseRunResponse{Paused: false, Error: "run has finished"}, nil
+	case rs.paused:
+		return &adapterrpc.PauseRunResponse{Paused: false, Error: "run is already paused"}, nil
+	case rs.cmd == nil || rs.cmd.Process == nil:
+		return &adapterrpc.PauseRunResponse{Paused: false, Error: "run has not started"}, nil
+	}
+	if rs.deadline != nil {
+		if !rs.deadline.Stop() {
+			return &adapterrpc.PauseRunResponse{Paused: false, Error: "run deadline has passed"}, nil
+		}
+		rs.remaining = time.Until(rs.deadlineAt)
+	}
+	if err := signalRun(rs.cmd.Process.Pid, true); err != nil {
+		if rs.deadline != nil {
+			rs.deadline.Reset(rs.remaining)
+		}
+		return &adapterrpc.PauseRunResponse{Paused: false, Error: err.Error()}, nil
+	}
+	rs.paused = true
+	return &adapterrpc.PauseRunResponse{Paused: true}, nil
+}
+
+// ResumeRun continues a paused run; its timeout restarts with the time it
+// had left when paused.
+func (s *Server) ResumeRun(ctx context.Context, req *adapterrpc.ResumeRunRequest) (*adapterrpc.ResumeRunResponse, error) {
+	rs, err := s.getRun(req.RunID)
+	if err != nil {
+		return &adapterrpc.ResumeRunResponse{Resumed: false, Error: err.Error()}, nil
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.closed {
+		return &adapterrpc.ResumeRunResponse{Resumed: false, Error: "run has finished"}, nil
+	}
+	if !rs.paused {
+		return &adapterrpc.ResumeRunResponse{Resumed: false, Error: "run is not paused"}, nil
+	}
+	if err := signalRun(rs.cmd.Process.Pid, false); err != nil {
+		return &adapterrpc.ResumeRunResponse{Resumed: false, Error: err.Error()}, nil
+	}
+	rs.paused = false
+	if rs.deadline != nil {
+		rs.deadlineAt = time.Now().Add(rs.remaining)
+		rs.deadline.Reset(rs.remaining)
+	}
+	return &adapterrpc.ResumeRunResponse{Resumed: true}, nil
+}
+
+func (r *runState) isPaused() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.paused
+}
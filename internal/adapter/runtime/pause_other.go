@@ -0,0 +1,16 @@
+//go:build !unix
+
+package runtime
+
+import (
+	"errors"
+	"os/exec"
+)
+
+const pauseSupported = false
+
+func preparePausable(*exec.Cmd) {}
+
+func signalRun(int, bool) error {
+	return errors.New("pause is not supported on this platform")
+}
@@ -0,0 +1,27 @@
+//go:build unix
+
+package runtime
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+const pauseSupported = true
+
+// preparePausable puts the CLI in its own process group so pausing also
+// stops the processes it spawns. PTY runs get a new session instead.
+func preparePausable(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+func signalRun(pid int, pause bool) error {
+	sig := syscall.SIGCONT
+	if pause {
+		sig = syscall.SIGSTOP
+	}
+	return syscall.Kill(-pid, sig)
+}
@@ -0,0 +1,125 @@
+package runtime
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	adapterrpc "echohelix/internal/rpc/adapter"
+
+	"github.com/creack/pty"
+)
+
+const (
+	defaultPTYCols = 120
+	defaultPTYRows = 40
+	maxPTYSize     = 1000
+	// ptyDrainTimeout bounds how long output is read after the CLI exits,
+	// in case a child it left behind still holds the terminal.
+	ptyDrainTimeout = 2 * time.Second
+)
+
+func ptySize(req *adapterrpc.StartRunRequest) *pty.Winsize {
+	size := &pty.Winsize{Cols: defaultPTYCols, Rows: defaultPTYRows}
+	if req.PTYCols > 0 {
+		size.Cols = uint16(min(req.PTYCols, maxPTYSize))
+	}
+	if req.PTYRows > 0 {
+		size.Rows = uint16(min(req.PTYRows, maxPTYSize))
+	}
+	return size
+}
+
+// executePTY runs cmd on a pseudo-terminal. Stdout and stderr share the
+// terminal, so every line is mapped as stdout.
+func (s *Server) executePTY(rs *runState, cmd *exec.Cmd, req *adapterrpc.StartRunRequest, mode string) {
+	tty, err := pty.StartWithSize(cmd, ptySize(req))
+	if err != nil {
+		rs.fail(err)
+		return
+	}
+	rs.setCmd(cmd)
+
+	if mode == "stdin" {
+		_, _ = io.WriteString(tty, req.Prompt+"\n")
+	}
+	var out io.Reader = tty
+	if req.RawOutput {
+		out = io.TeeReader(out, &rawChunkWriter{rs: rs})
+	}
+	watchDone := make(chan struct{})
+	if req.Interactive {
+		in := newInputState(ptyInput{tty})
+		rs.setInput(in)
+		out = io.TeeReader(out, in)
+		go s.watchInput(rs, in, watchDone)
+		defer in.close()
+	}
+
+	sink := s.newLineSink(rs, true)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go scanPipe(out, sink.stdout, &wg)
+
+	waitErr := cmd.Wait()
+	close(watchDone)
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(ptyDrainTimeout):
+	}
+	_ = tty.Close()
+	<-drained
+	sink.finish(waitErr)
+}
+
+// ptyInput writes to the terminal; closing it sends EOF (Ctrl-D) rather
+// than closing the terminal, which the output reader still uses.
+type ptyInput struct {
+	f *os.File
+}
+
+func (p ptyInput) Write(b []byte) (int, error) { return p.f.Write(b) }
+
+func (p ptyInput) Close() error {
+	_, err := p.f.Write([]byte{0x04})
+	return err
+}
+
+// rawChunkWriter publishes terminal output as it arrives, holding back a
+// trailing partial UTF-8 sequence until the rest of it is read.
+type rawChunkWriter struct {
+	rs      *runState
+	pending []byte
+}
+
+func (w *rawChunkWriter) Write(p []byte) (int, error) {
+	buf := append(w.pending, p...)
+	cut := len(buf)
+	for i := len(buf) - 1; i >= 0 && i >= len(buf)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(buf[i]) {
+			if !utf8.FullRune(buf[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	w.pending = append([]byte(nil), buf[cut:]...)
+	if cut > 0 {
+		w.rs.publish(NormalizedEvent{
+			Type:    "token",
+			Channel: "working",
+			Format:  "plain",
+			Role:    "assistant",
+			Payload: map[string]any{"text": string(buf[:cut])},
+		}, "pty")
+	}
+	return len(p), nil
+}
@@ -0,0 +1,190 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	adapterrpc "echohelix/internal/rpc/adapter"
+)
+
+const (
+	defaultRawLogMaxBytes  = 10 << 20
+	defaultRawLogRetention = 72 * time.Hour
+	defaultRawLogRead      = 1 << 20
+	// maxRawLogRead keeps GetRawLog replies well under the gRPC message
+	// limit once JSON-escaped.
+	maxRawLogRead = 2 << 20
+)
+
+func applyRawLogEnv(cfg *Config) {
+	cfg.RawLogDir = env("ADAPTER_RAW_LOG_DIR", cfg.RawLogDir)
+	if n, err := strconv.ParseInt(os.Getenv("ADAPTER_RAW_LOG_MAX_BYTES"), 10, 64); err == nil && n > 0 {
+		cfg.RawLogMaxBytes = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("ADAPTER_RAW_LOG_RETENTION_HOURS")); err == nil && n > 0 {
+		cfg.RawLogRetention = time.Duration(n) * time.Hour
+	}
+	if cfg.RawLogMaxBytes <= 0 {
+		cfg.RawLogMaxBytes = defaultRawLogMaxBytes
+	}
+	if cfg.RawLogRetention <= 0 {
+		cfg.RawLogRetention = defaultRawLogRetention
+	}
+}
+
+// rawLog is the on-disk copy of one run's CLI output, one
+// "<ts> <source> <line>" record per line. A nil rawLog drops writes.
+type rawLog struct {
+	mu   sync.Mutex
+	path string
+	max  int64
+	f    *os.File
+	size int64
+}
+
+// openRawLog starts the run's raw log, pruning expired logs first. It
+// returns nil when capture is off or the log cannot be created.
+func (s *Server) openRawLog(runID string) *rawLog {
+	dir := s.cfg.RawLogDir
+	if dir == "" {
+		return nil
+	}
+	path, err := rawLogPath(dir, runID)
+	if err != nil {
+		log.Printf("warn: raw log for run %s: %v", runID, err)
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		log.Printf("warn: raw log dir: %v", err)
+		return nil
+	}
+	pruneRawLogs(dir, s.cfg.RawLogRetention, time.Now())
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		log.Printf("warn: raw log for run %s: %v", runID, err)
+		return nil
+	}
+	_ = os.Remove(path + ".1")
+	return &rawLog{path: path, max: s.cfg.RawLogMaxBytes, f: f}
+}
+
+func (l *rawLog) write(source, line string) {
+	if l == nil {
+		return
+	}
+	rec := time.Now().UTC().Format(time.RFC3339Nano) + " " + source + " " + line + "\n"
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return
+	}
+	if l.size > 0 && l.size+int64(len(rec)) > l.max {
+		l.rotate()
+		if l.f == nil {
+			return
+		}
+	}
+	n, _ := io.WriteString(l.f, rec)
+	l.size += int64(n)
+}
+
+// rotate keeps the current log as <path>.1, replacing the previous one.
+func (l *rawLog) rotate() {
+	_ = l.f.Close()
+	l.f = nil
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		log.Printf("warn: rotate raw log %s: %v", l.path, err)
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		log.Printf("warn: rotate raw log %s: %v", l.path, err)
+		return
+	}
+	l.f, l.size = f, 0
+}
+
+func (l *rawLog) close() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f != nil {
+		_ = l.f.Close()
+		l.f = nil
+	}
+}
+
+// rawLogPath refuses run IDs that would leave dir.
+func rawLogPath(dir, runID string) (string, error) {
+	if runID == "" || runID == "." || runID == ".." || strings.ContainsAny(runID, `/\`) {
+		return "", fmt.Errorf("invalid run id %q", runID)
+	}
+	return filepath.Join(dir, runID+".log"), nil
+}
+
+func pruneRawLogs(dir string, retention time.Duration, now time.Time) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !(strings.HasSuffix(name, ".log") || strings.HasSuffix(name, ".log.1")) {
+			continue
+		}
+		info, err := e.Info()
+		if err == nil && now.Sub(info.ModTime()) > retention {
+			_ = os.Remove(filepath.Join(dir, name))
+		}
+	}
+}
+
+// GetRawLog returns the end of a run's raw log: the rotated file followed
+// by the current one, cut to MaxBytes.
+func (s *Server) GetRawLog(ctx context.Context, req *adapterrpc.GetRawLogRequest) (*adapterrpc.GetRawLogResponse, error) {
+	if s.cfg.RawLogDir == "" {
+		return &adapterrpc.GetRawLogResponse{Disabled: true, Error: "raw log capture is disabled"}, nil
+	}
+	path, err := rawLogPath(s.cfg.RawLogDir, req.RunID)
+	if err != nil {
+		return &adapterrpc.GetRawLogResponse{Error: err.Error()}, nil
+	}
+	limit := req.MaxBytes
+	if limit <= 0 {
+		limit = defaultRawLogRead
+	}
+	limit = min(limit, maxRawLogRead)
+	var data []byte
+	found := false
+	for _, p := range []string{path + ".1", path} {
+		b, err := os.ReadFile(p)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return &adapterrpc.GetRawLogResponse{Error: err.Error()}, nil
+		}
+		found = true
+		data = append(data, b...)
+	}
+	if !found {
+		return &adapterrpc.GetRawLogResponse{Found: false}, nil
+	}
+	out := &adapterrpc.GetRawLogResponse{Found: true, Size: int64(len(data))}
+	if int64(len(data)) > limit {
+		data = data[int64(len(data))-limit:]
+		out.Truncated = true
+	}
+	out.Data = string(data)
+	return out, nil
+}
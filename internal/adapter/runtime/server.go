@@ -5,15 +5,24 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"os/exec"
+	"runtime/debug"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"echohelix/internal/envprofile"
 	"echohelix/internal/events"
 	adapterrpc "echohelix/internal/rpc/adapter"
+	"echohelix/internal/rpc/transport"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const maxScanTokenSize = 4 * 1024 * 1024
@@ -40,6 +49,10 @@ type Config struct {
 	ApplyRunOption RunOptionsApplier
 	ApplyPromptArg PromptArgApplier
 	Downgrade      EventDowngrader
+	// NewMapper, when set, is called once per run and replaces Mapper, for
+	// CLIs whose output spans lines (e.g. multi-line edit blocks). The
+	// stdout and stderr readers call the returned Mapper concurrently.
+	NewMapper func() Mapper
 
 	CLIBinEnv      string
 	CLIBinDefault  string
@@ -54,6 +67,43 @@ type Config struct {
 	SchemaVersions         []string
 	PreferredSchemaVersion string
 	CompatFields           []string
+
+	// Tools is the tool inventory the CLI can invoke (shell, web, editor),
+	// reported in Capabilities. ToolsEnv names a comma-separated override.
+	Tools    []string
+	ToolsEnv string
+
+	// Models, Profiles and SandboxModes are the option values the CLI
+	// accepts, reported in Capabilities so the bridge rejects others at
+	// submit. Empty means any value; the *Env fields name comma-separated
+	// overrides.
+	Models          []string
+	ModelsEnv       string
+	Profiles        []string
+	ProfilesEnv     string
+	SandboxModes    []string
+	SandboxModesEnv string
+	// ModelInfo adds context windows and the default flag to the models
+	// returned by ListModels; models without an entry are listed by ID.
+	ModelInfo []adapterrpc.ModelInfo
+
+	// StripANSI removes terminal escape sequences (and text overwritten by
+	// carriage returns) from output lines before they reach Mapper.
+	StripANSI bool
+
+	// InputIdle is how long an interactive run's stdout must be quiet before
+	// a pending prompt is reported as needs_input. Zero means 2s.
+	InputIdle time.Duration
+
+	// RawLogDir, when set, tees every run's raw CLI output lines to
+	// <run_id>.log there for GetRawLog. A log rotates to <run_id>.log.1 past
+	// RawLogMaxBytes (default 10 MiB) and is removed RawLogRetention after
+	// its last write (default 72h). ADAPTER_RAW_LOG_DIR,
+	// ADAPTER_RAW_LOG_MAX_BYTES and ADAPTER_RAW_LOG_RETENTION_HOURS override
+	// them.
+	RawLogDir       string
+	RawLogMaxBytes  int64
+	RawLogRetention time.Duration
 }
 
 type Server struct {
@@ -61,6 +111,9 @@ type Server struct {
 
 	mu   sync.RWMutex
 	runs map[string]*runState
+
+	metrics      rpcMetrics
+	backpressure events.BackpressureCounters
 }
 
 type runState struct {
@@ -72,11 +125,22 @@ type runState struct {
 	mu      sync.RWMutex
 	seq     int64
 	history []*adapterrpc.AgentEvent
-	subs    map[chan *adapterrpc.AgentEvent]struct{}
+	subs    map[chan *adapterrpc.AgentEvent]*events.Backlog
 	closed  bool
-
-	cancel context.CancelFunc
-	cmd    *exec.Cmd
+	// evicted remembers subscribers closed for staying saturated so their
+	// stream ends with an error instead of a clean EOF.
+	evicted  map[<-chan *adapterrpc.AgentEvent]struct{}
+	counters *events.BackpressureCounters
+
+	cancel     context.CancelFunc
+	deadline   *time.Timer
+	deadlineAt time.Time
+	// A paused run keeps the timeout it had left in remaining.
+	paused    bool
+	remaining time.Duration
+	cmd       *exec.Cmd
+	input     *inputState
+	raw       *rawLog
 }
 
 func NewServer(cfg Config) *Server {
@@ -101,6 +165,7 @@ func NewServer(cfg Config) *Server {
 	if cfg.EventTypes == nil {
 		cfg.EventTypes = []string{"token", "tool_call", "tool_result", "patch", "status", "done", "error"}
 	}
+	applyRawLogEnv(&cfg)
 
 	return &Server{
 		cfg:  cfg,
@@ -108,6 +173,14 @@ func NewServer(cfg Config) *Server {
 	}
 }
 
+// GRPCServerOptions returns the TLS/mTLS and token options for the adapter's
+// gRPC server, read from ADAPTER_TLS_CERT, ADAPTER_TLS_KEY,
+// ADAPTER_TLS_CLIENT_CA and ADAPTER_AUTH_TOKEN. Server.ServerOptions adds
+// the request interceptors.
+func GRPCServerOptions() ([]grpc.ServerOption, error) {
+	return transport.FromEnv().ServerOptions()
+}
+
 func (s *Server) StartRun(ctx context.Context, req *adapterrpc.StartRunRequest) (*adapterrpc.StartRunResponse, error) {
 	if req.RunID == "" || req.WorkspacePath == "" || req.Prompt == "" {
 		return &adapterrpc.StartRunResponse{Accepted: false, Error: "run_id/workspace_path/prompt are required"}, nil
@@ -116,9 +189,12 @@ func (s *Server) StartRun(ctx context.Context, req *adapterrpc.StartRunRequest)
 	if schemaVersion == "" {
 		schemaVersion = events.SchemaVersionV2
 	}
-	if schemaVersion != events.SchemaVersionV1 && schemaVersion != events.SchemaVersionV2 {
+	if !slices.Contains(s.cfg.SchemaVersions, schemaVersion) {
 		return &adapterrpc.StartRunResponse{Accepted: false, Error: "unsupported schema_version"}, nil
 	}
+	if req.PTY && !s.cfg.SupportsPTY {
+		return &adapterrpc.StartRunResponse{Accepted: false, Error: "pty is not supported by this adapter"}, nil
+	}
 
 	s.mu.Lock()
 	if _, exists := s.runs[req.RunID]; exists {
@@ -126,23 +202,23 @@ func (s *Server) StartRun(ctx context.Context, req *adapterrpc.StartRunRequest)
 		return &adapterrpc.StartRunResponse{Accepted: false, Error: "run already exists"}, nil
 	}
 
-	runCtx := context.Background()
-	var cancel context.CancelFunc
-	if req.TimeoutSec > 0 {
-		runCtx, cancel = context.WithTimeout(runCtx, time.Duration(req.TimeoutSec)*time.Second)
-	} else {
-		runCtx, cancel = context.WithCancel(runCtx)
-	}
-
+	// The timeout is a timer rather than a context deadline so ExtendRun
+	// can move it.
+	runCtx, cancel := context.WithCancel(context.Background())
 	rs := &runState{
 		runID:         req.RunID,
 		schemaVersion: schemaVersion,
 		backend:       s.cfg.Backend,
 		downgrade:     s.cfg.Downgrade,
-		subs:          map[chan *adapterrpc.AgentEvent]struct{}{},
+		subs:          map[chan *adapterrpc.AgentEvent]*events.Backlog{},
 		history:       make([]*adapterrpc.AgentEvent, 0, 128),
+		counters:      &s.backpressure,
 		cancel:        cancel,
 	}
+	if req.TimeoutSec > 0 {
+		rs.deadlineAt = time.Now().Add(time.Duration(req.TimeoutSec) * time.Second)
+		rs.deadline = time.AfterFunc(time.Duration(req.TimeoutSec)*time.Second, cancel)
+	}
 	s.runs[req.RunID] = rs
 	s.mu.Unlock()
 
@@ -169,6 +245,9 @@ func (s *Server) StreamEvents(req *adapterrpc.StreamEventsRequest, stream adapte
 			return err
 		}
 	}
+	if rs.wasEvicted(ch) {
+		return status.Error(codes.ResourceExhausted, "event stream evicted: subscriber stayed saturated")
+	}
 	return nil
 }
 
@@ -191,12 +270,58 @@ func (s *Server) Capabilities(context.Context, *adapterrpc.CapabilitiesRequest)
 		EventTypes:             s.cfg.EventTypes,
 		SupportsCancel:         s.cfg.SupportsCancel,
 		SupportsPTY:            s.cfg.SupportsPTY,
+		SupportsInput:          true,
+		SupportsPause:          pauseSupported,
 		SchemaVersions:         s.cfg.SchemaVersions,
 		PreferredSchemaVersion: s.cfg.PreferredSchemaVersion,
 		CompatFields:           s.cfg.CompatFields,
+		Tools:                  s.tools(),
+		Models:                 envList(s.cfg.ModelsEnv, s.cfg.Models),
+		Profiles:               envList(s.cfg.ProfilesEnv, s.cfg.Profiles),
+		SandboxModes:           envList(s.cfg.SandboxModesEnv, s.cfg.SandboxModes),
 	}, nil
 }
 
+func (s *Server) ListModels(context.Context, *adapterrpc.ListModelsRequest) (*adapterrpc.ListModelsResponse, error) {
+	info := make(map[string]adapterrpc.ModelInfo, len(s.cfg.ModelInfo))
+	for _, m := range s.cfg.ModelInfo {
+		info[m.ID] = m
+	}
+	ids := envList(s.cfg.ModelsEnv, s.cfg.Models)
+	if len(ids) == 0 {
+		return &adapterrpc.ListModelsResponse{Models: append([]adapterrpc.ModelInfo{}, s.cfg.ModelInfo...)}, nil
+	}
+	out := make([]adapterrpc.ModelInfo, 0, len(ids))
+	for _, id := range ids {
+		m, ok := info[id]
+		if !ok {
+			m = adapterrpc.ModelInfo{ID: id}
+		}
+		out = append(out, m)
+	}
+	return &adapterrpc.ListModelsResponse{Models: out}, nil
+}
+
+func envList(key string, defaults []string) []string {
+	var out []string
+	for _, item := range strings.Split(env(key, strings.Join(defaults, ",")), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func (s *Server) tools() []string {
+	var out []string
+	for _, tool := range strings.Split(env(s.cfg.ToolsEnv, strings.Join(s.cfg.Tools, ",")), ",") {
+		if tool = strings.ToLower(strings.TrimSpace(tool)); tool != "" {
+			out = append(out, tool)
+		}
+	}
+	return out
+}
+
 func (s *Server) getRun(runID string) (*runState, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -216,7 +341,13 @@ func (s *Server) execute(ctx context.Context, rs *runState, req *adapterrpc.Star
 			s.mu.Unlock()
 		})
 	}()
+	defer func() {
+		if p := recover(); p != nil {
+			rs.abort(fmt.Errorf("adapter panic in run: %v", p), debug.Stack())
+		}
+	}()
 
+	rs.raw = s.openRawLog(req.RunID)
 	rs.publish(NormalizedEvent{
 		Type:    "status",
 		Channel: "system",
@@ -237,46 +368,34 @@ func (s *Server) execute(ctx context.Context, rs *runState, req *adapterrpc.Star
 		args = append(args, req.Prompt)
 	}
 
-	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd := envprofile.Command(ctx, bin, args, envprofile.Profile{
+		Env:         req.Env,
+		PathPrepend: req.PathPrepend,
+		ShellInit:   req.ShellInit,
+	})
 	cmd.Dir = req.WorkspacePath
+	if req.PTY {
+		s.executePTY(rs, cmd, req, mode)
+		return
+	}
 
+	preparePausable(cmd)
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		rs.publish(NormalizedEvent{
-			Type:    "error",
-			Channel: "system",
-			Format:  "plain",
-			Role:    "system",
-			Payload: map[string]any{"message": err.Error()},
-		}, "adapter")
-		rs.finish()
+		rs.fail(err)
 		return
 	}
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		rs.publish(NormalizedEvent{
-			Type:    "error",
-			Channel: "system",
-			Format:  "plain",
-			Role:    "system",
-			Payload: map[string]any{"message": err.Error()},
-		}, "adapter")
-		rs.finish()
+		rs.fail(err)
 		return
 	}
 
 	var stdin io.WriteCloser
-	if mode == "stdin" {
+	if mode == "stdin" || req.Interactive {
 		in, err := cmd.StdinPipe()
 		if err != nil {
-			rs.publish(NormalizedEvent{
-				Type:    "error",
-				Channel: "system",
-				Format:  "plain",
-				Role:    "system",
-				Payload: map[string]any{"message": err.Error()},
-			}, "adapter")
-			rs.finish()
+			rs.fail(err)
 			return
 		}
 		stdin = in
@@ -286,60 +405,131 @@ func (s *Server) execute(ctx context.Context, rs *runState, req *adapterrpc.Star
 	}
 
 	if err := cmd.Start(); err != nil {
-		rs.publish(NormalizedEvent{
-			Type:    "error",
-			Channel: "system",
-			Format:  "plain",
-			Role:    "system",
-			Payload: map[string]any{"message": err.Error()},
-		}, "adapter")
-		rs.finish()
+		rs.fail(err)
 		return
 	}
 	rs.setCmd(cmd)
 
-	if stdin != nil {
+	if stdin != nil && mode == "stdin" {
 		_, _ = stdin.Write([]byte(req.Prompt))
 		_, _ = stdin.Write([]byte("\n"))
+	}
+	var stdoutReader io.Reader = stdout
+	watchDone := make(chan struct{})
+	if req.Interactive {
+		in := newInputState(stdin)
+		rs.setInput(in)
+		stdoutReader = io.TeeReader(stdout, in)
+		go s.watchInput(rs, in, watchDone)
+		defer in.close()
+	} else if stdin != nil {
 		_ = stdin.Close()
 	}
 
+	sink := s.newLineSink(rs, false)
 	var wg sync.WaitGroup
-	var sawDone atomic.Bool
-	mdAssembler := &markdownAssembler{}
 	wg.Add(2)
-	go scanPipe(stdout, func(line string) {
-		ev, ok := s.cfg.Mapper(line, "stdout")
-		if !ok {
-			return
-		}
-		if ev.Type == "token" && ev.Channel == "final" && ev.Format == "markdown" {
-			text, _ := ev.Payload["text"].(string)
-			merged, ready := mdAssembler.Push(text)
-			if !ready {
-				return
+	go scanPipe(stdoutReader, sink.stdout, &wg)
+	go scanPipe(stderr, sink.stderr, &wg)
+
+	waitErr := cmd.Wait()
+	close(watchDone)
+	wg.Wait()
+	sink.finish(waitErr)
+}
+
+// lineSink maps CLI output lines to events and closes the run when the
+// process is gone.
+type lineSink struct {
+	s       *Server
+	rs      *runState
+	mapper  Mapper
+	pty     bool
+	md      markdownAssembler
+	sawDone atomic.Bool
+	// panicked holds the first mapper panic; the CLI is stopped and the
+	// run ends failed.
+	panicked atomic.Pointer[error]
+}
+
+func (s *Server) newLineSink(rs *runState, pty bool) *lineSink {
+	mapper := s.cfg.Mapper
+	if s.cfg.NewMapper != nil {
+		mapper = s.cfg.NewMapper()
+	}
+	return &lineSink{s: s, rs: rs, mapper: mapper, pty: pty}
+}
+
+func (k *lineSink) clean(line string) string {
+	if k.pty {
+		line = strings.TrimRight(line, "\r")
+	}
+	if k.s.cfg.StripANSI {
+		line = stripANSI(line)
+	}
+	return line
+}
+
+// mapLine runs the mapper, turning a panic into a stopped, failed run
+// instead of a crashed adapter.
+func (k *lineSink) mapLine(line, source string) (ev NormalizedEvent, ok bool) {
+	if k.panicked.Load() != nil {
+		return NormalizedEvent{}, false
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err := fmt.Errorf("adapter panic in mapper: %v", p)
+			if k.panicked.CompareAndSwap(nil, &err) {
+				log.Printf("adapter panic run_id=%s: %v\n%s", k.rs.runID, p, debug.Stack())
+				if k.rs.cancel != nil {
+					k.rs.cancel()
+				}
 			}
-			ev.Payload["text"] = merged
+			ev, ok = NormalizedEvent{}, false
 		}
-		if ev.Type == "done" {
-			sawDone.Store(true)
-		}
-		rs.publish(ev, "stdout")
-	}, &wg)
-	go scanPipe(stderr, func(line string) {
-		ev, ok := s.cfg.Mapper(line, "stderr")
-		if !ok {
+	}()
+	return k.mapper(k.clean(line), source)
+}
+
+func (k *lineSink) stdout(line string) {
+	k.rs.raw.write("stdout", line)
+	ev, ok := k.mapLine(line, "stdout")
+	if !ok {
+		return
+	}
+	if ev.Type == "token" && ev.Channel == "final" && ev.Format == "markdown" {
+		text, _ := ev.Payload["text"].(string)
+		merged, ready := k.md.Push(text)
+		if !ready {
 			return
 		}
-		if ev.Type == "done" {
-			sawDone.Store(true)
-		}
-		rs.publish(ev, "stderr")
-	}, &wg)
+		ev.Payload["text"] = merged
+	}
+	if ev.Type == "done" {
+		k.sawDone.Store(true)
+	}
+	k.rs.publish(ev, "stdout")
+}
 
-	waitErr := cmd.Wait()
-	wg.Wait()
-	if merged, ok := mdAssembler.Flush(); ok {
+func (k *lineSink) stderr(line string) {
+	k.rs.raw.write("stderr", line)
+	ev, ok := k.mapLine(line, "stderr")
+	if !ok {
+		return
+	}
+	if ev.Type == "done" {
+		k.sawDone.Store(true)
+	}
+	k.rs.publish(ev, "stderr")
+}
+
+func (k *lineSink) finish(waitErr error) {
+	rs := k.rs
+	if err := k.panicked.Load(); err != nil {
+		rs.abort(*err, nil)
+		return
+	}
+	if merged, ok := k.md.Flush(); ok {
 		rs.publish(NormalizedEvent{
 			Type:    "token",
 			Channel: "final",
@@ -358,7 +548,7 @@ func (s *Server) execute(ctx context.Context, rs *runState, req *adapterrpc.Star
 			Payload: map[string]any{"message": waitErr.Error()},
 		}, "adapter")
 	}
-	if !sawDone.Load() {
+	if !k.sawDone.Load() {
 		rs.publish(NormalizedEvent{
 			Type:    "done",
 			Channel: "system",
@@ -370,12 +560,56 @@ func (s *Server) execute(ctx context.Context, rs *runState, req *adapterrpc.Star
 	rs.finish()
 }
 
+// fail ends a run whose CLI could not be started.
+func (r *runState) fail(err error) {
+	r.publish(NormalizedEvent{
+		Type:    "error",
+		Channel: "system",
+		Format:  "plain",
+		Role:    "system",
+		Payload: map[string]any{"message": err.Error()},
+	}, "adapter")
+	r.finish()
+}
+
+// abort ends a run after an adapter panic with an error and a failed done
+// event. stack is logged when given.
+func (r *runState) abort(err error, stack []byte) {
+	if stack != nil {
+		log.Printf("adapter panic run_id=%s: %v\n%s", r.runID, err, stack)
+	}
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.publish(NormalizedEvent{
+		Type:    "error",
+		Channel: "system",
+		Format:  "plain",
+		Role:    "system",
+		Payload: map[string]any{"message": err.Error()},
+	}, "adapter")
+	r.publish(NormalizedEvent{
+		Type:    "done",
+		Channel: "system",
+		Format:  "json",
+		Role:    "system",
+		Payload: map[string]any{"status": "failed"},
+	}, "adapter")
+	r.finish()
+}
+
 func (r *runState) setCmd(cmd *exec.Cmd) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	r.cmd = cmd
 }
 
+func (r *runState) setInput(in *inputState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.input = in
+}
+
 func (r *runState) subscribe() ([]*adapterrpc.AgentEvent, <-chan *adapterrpc.AgentEvent, func()) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -388,7 +622,7 @@ func (r *runState) subscribe() ([]*adapterrpc.AgentEvent, <-chan *adapterrpc.Age
 		close(ch)
 		return history, ch, func() {}
 	}
-	r.subs[ch] = struct{}{}
+	r.subs[ch] = &events.Backlog{}
 	unsub := func() {
 		r.mu.Lock()
 		defer r.mu.Unlock()
@@ -449,16 +683,70 @@ func (r *runState) publish(ne NormalizedEvent, source string) {
 		if len(r.history) > 2048 {
 			r.history = r.history[len(r.history)-2048:]
 		}
-		for sub := range r.subs {
-			select {
-			case sub <- ev:
-			default:
+		now := time.Now()
+		for sub, backlog := range r.subs {
+			if r.deliver(sub, backlog, ev) {
+				continue
+			}
+			r.count(func(c *events.BackpressureCounters) { c.Dropped.Add(1) })
+			if backlog.Drop(ev.Seq, now, events.DefaultEvictAfter) {
+				r.count(func(c *events.BackpressureCounters) { c.Evicted.Add(1) })
+				if r.evicted == nil {
+					r.evicted = map[<-chan *adapterrpc.AgentEvent]struct{}{}
+				}
+				r.evicted[sub] = struct{}{}
+				delete(r.subs, sub)
+				close(sub)
 			}
 		}
 	}
 	r.mu.Unlock()
 }
 
+// deliver sends the subscriber's pending gap marker, if any, ahead of ev.
+func (r *runState) deliver(sub chan *adapterrpc.AgentEvent, backlog *events.Backlog, ev *adapterrpc.AgentEvent) bool {
+	if payload, ok := backlog.PendingGap(); ok {
+		marker := &adapterrpc.AgentEvent{
+			RunID:         r.runID,
+			TsUnix:        time.Now().Unix(),
+			SchemaVersion: ev.SchemaVersion,
+			Type:          events.TypeGap,
+			Channel:       events.ChannelSystem,
+			Format:        events.FormatJSON,
+			Role:          events.RoleSystem,
+			Payload:       payload,
+			Source:        "adapter",
+		}
+		select {
+		case sub <- marker:
+			backlog.ClearGap()
+			r.count(func(c *events.BackpressureCounters) { c.GapMarkers.Add(1) })
+		default:
+			return false
+		}
+	}
+	select {
+	case sub <- ev:
+		backlog.Delivered()
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *runState) count(fn func(*events.BackpressureCounters)) {
+	if r.counters != nil {
+		fn(r.counters)
+	}
+}
+
+func (r *runState) wasEvicted(ch <-chan *adapterrpc.AgentEvent) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.evicted[ch]
+	return ok
+}
+
 func (r *runState) finish() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -470,6 +758,7 @@ func (r *runState) finish() {
 		close(sub)
 		delete(r.subs, sub)
 	}
+	r.raw.close()
 }
 
 func scanPipe(reader io.Reader, onLine func(string), wg *sync.WaitGroup) {
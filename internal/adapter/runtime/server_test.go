@@ -1,9 +1,20 @@
 package runtime
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
+	"time"
+
+	"echohelix/internal/events"
+	adapterrpc "echohelix/internal/rpc/adapter"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestScanPipeHandlesLongLines(t *testing.T) {
@@ -26,3 +37,405 @@ func TestScanPipeHandlesLongLines(t *testing.T) {
 	}
 }
 
+func TestInteractiveRunReportsNeedsInputAndAcceptsInput(t *testing.T) {
+	script := `printf 'Proceed? [y/N] '; read answer; echo "answer=$answer"; sleep 0.3`
+	s := NewServer(Config{
+		Backend:       "test",
+		CLIBinDefault: "sh",
+		InputIdle:     100 * time.Millisecond,
+		Mapper: func(line, source string) (NormalizedEvent, bool) {
+			return NormalizedEvent{Type: "token", Channel: "final", Format: "plain", Role: "assistant", Payload: map[string]any{"text": line}}, true
+		},
+		ApplyPromptArg: func(args []string, mode, prompt string) []string {
+			return []string{"-c", script}
+		},
+	})
+	res, err := s.StartRun(context.Background(), &adapterrpc.StartRunRequest{
+		RunID: "r1", WorkspacePath: t.TempDir(), Prompt: "go", TimeoutSec: 10, Interactive: true,
+	})
+	if err != nil || !res.Accepted {
+		t.Fatalf("start: %v %+v", err, res)
+	}
+	rs, err := s.getRun("r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, ch, unsub := rs.subscribe()
+	defer unsub()
+	next := func() *adapterrpc.AgentEvent {
+		if len(history) > 0 {
+			ev := history[0]
+			history = history[1:]
+			return ev
+		}
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				t.Fatal("stream closed")
+			}
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for event")
+		}
+		return nil
+	}
+
+	for {
+		ev := next()
+		if ev.Type == "status" && ev.Payload["status"] == "needs_input" {
+			if ev.Payload["message"] != "Proceed? [y/N]" {
+				t.Fatalf("prompt = %v", ev.Payload["message"])
+			}
+			break
+		}
+	}
+	in, err := s.StreamInput(context.Background(), &adapterrpc.StreamInputRequest{RunID: "r1", Data: "yes"})
+	if err != nil || !in.Accepted {
+		t.Fatalf("input: %v %+v", err, in)
+	}
+	for {
+		ev := next()
+		if ev.Type == "token" && strings.HasSuffix(ev.Payload["text"].(string), "answer=yes") {
+			break
+		}
+		if ev.Type == "done" {
+			t.Fatal("run finished without echoing input")
+		}
+	}
+
+	rejected, _ := s.StreamInput(context.Background(), &adapterrpc.StreamInputRequest{RunID: "missing", Data: "x"})
+	if rejected.Accepted {
+		t.Fatal("input to unknown run accepted")
+	}
+}
+
+func TestExtendRunOutlivesOriginalTimeout(t *testing.T) {
+	s := NewServer(Config{
+		Backend:       "test",
+		CLIBinDefault: "sh",
+		Mapper: func(line, source string) (NormalizedEvent, bool) {
+			return NormalizedEvent{Type: "token", Channel: "final", Format: "plain", Role: "assistant", Payload: map[string]any{"text": line}}, true
+		},
+		ApplyPromptArg: func(args []string, mode, prompt string) []string {
+			return []string{"-c", "sleep 1.5; echo finished; sleep 0.3"}
+		},
+	})
+	res, err := s.StartRun(context.Background(), &adapterrpc.StartRunRequest{
+		RunID: "r1", WorkspacePath: t.TempDir(), Prompt: "go", TimeoutSec: 1,
+	})
+	if err != nil || !res.Accepted {
+		t.Fatalf("start: %v %+v", err, res)
+	}
+	if ext, err := s.ExtendRun(context.Background(), &adapterrpc.ExtendRunRequest{RunID: "r1", TimeoutSec: 5}); err != nil || !ext.Extended {
+		t.Fatalf("extend: %v %+v", err, ext)
+	}
+	rs, err := s.getRun("r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, ch, unsub := rs.subscribe()
+	defer unsub()
+	timeout := time.After(5 * time.Second)
+	for finished := false; !finished; {
+		var ev *adapterrpc.AgentEvent
+		if len(history) > 0 {
+			ev, history = history[0], history[1:]
+		} else {
+			select {
+			case e, ok := <-ch:
+				if !ok {
+					t.Fatal("stream closed before the CLI finished")
+				}
+				ev = e
+			case <-timeout:
+				t.Fatal("timed out waiting for event")
+			}
+		}
+		finished = ev.Type == "token" && ev.Payload["text"] == "finished"
+	}
+
+	if ext, _ := s.ExtendRun(context.Background(), &adapterrpc.ExtendRunRequest{RunID: "missing", TimeoutSec: 5}); ext.Extended {
+		t.Fatal("extension of unknown run accepted")
+	}
+}
+
+func TestPTYRunMapsStrippedLinesAndRawChunks(t *testing.T) {
+	script := `if [ -t 1 ]; then echo tty; else echo notty; fi; stty size; printf '\033[32mgreen\033[0m\n'`
+	var mu sync.Mutex
+	var mapped []string
+	s := NewServer(Config{
+		Backend:       "test",
+		CLIBinDefault: "sh",
+		SupportsPTY:   true,
+		StripANSI:     true,
+		Mapper: func(line, source string) (NormalizedEvent, bool) {
+			mu.Lock()
+			mapped = append(mapped, line)
+			mu.Unlock()
+			return NormalizedEvent{}, false
+		},
+		ApplyPromptArg: func(args []string, mode, prompt string) []string {
+			return []string{"-c", script}
+		},
+	})
+	res, err := s.StartRun(context.Background(), &adapterrpc.StartRunRequest{
+		RunID: "r1", WorkspacePath: t.TempDir(), Prompt: "go", TimeoutSec: 10,
+		PTY: true, PTYCols: 100, PTYRows: 30, RawOutput: true,
+	})
+	if err != nil || !res.Accepted {
+		t.Fatalf("start: %v %+v", err, res)
+	}
+	rs, err := s.getRun("r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, ch, unsub := rs.subscribe()
+	defer unsub()
+	var raw strings.Builder
+	for _, ev := range history {
+		if ev.Source == "pty" {
+			raw.WriteString(ev.Payload["text"].(string))
+		}
+	}
+	timeout := time.After(5 * time.Second)
+wait:
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				break wait
+			}
+			if ev.Source == "pty" {
+				raw.WriteString(ev.Payload["text"].(string))
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for pty run")
+		}
+	}
+
+	mu.Lock()
+	got := strings.Join(mapped, "|")
+	mu.Unlock()
+	if got != "tty|30 100|green" {
+		t.Fatalf("mapped lines = %q", got)
+	}
+	if !strings.Contains(raw.String(), "\x1b[32mgreen") {
+		t.Fatalf("raw output missing escapes: %q", raw.String())
+	}
+
+	rejected, _ := NewServer(Config{Backend: "test"}).StartRun(context.Background(), &adapterrpc.StartRunRequest{
+		RunID: "r2", WorkspacePath: t.TempDir(), Prompt: "go", PTY: true,
+	})
+	if rejected.Accepted {
+		t.Fatal("pty run accepted by adapter without pty support")
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	cases := map[string]string{
+		"\x1b[1;31merror\x1b[0m: bad":  "error: bad",
+		"\x1b]0;title\x07done":         "done",
+		"10%\r50%\r100%\r":             "100%",
+		"plain text":                   "plain text",
+		"\x1b[2K\x1b[1Gspinner \x1b=x": "spinner x",
+	}
+	for in, want := range cases {
+		if got := stripANSI(in); got != want {
+			t.Errorf("stripANSI(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestUnaryInterceptorRecoversPanicIntoRunErrorEvent(t *testing.T) {
+	s := NewServer(Config{Backend: "test", Mapper: func(string, string) (NormalizedEvent, bool) { return NormalizedEvent{}, false }})
+	rs := &runState{runID: "run-1", schemaVersion: "v2", backend: "test", subs: map[chan *adapterrpc.AgentEvent]*events.Backlog{}}
+	s.runs["run-1"] = rs
+
+	info := &grpc.UnaryServerInfo{FullMethod: adapterrpc.MethodExtendRun}
+	_, err := s.UnaryInterceptor(context.Background(), &adapterrpc.ExtendRunRequest{RunID: "run-1"}, info, func(context.Context, any) (any, error) {
+		panic("boom")
+	})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("expected Internal, got %v", err)
+	}
+	history, _, unsub := rs.subscribe()
+	unsub()
+	if len(history) != 1 || history[0].Type != "error" || !strings.Contains(history[0].Payload["message"].(string), "boom") {
+		t.Fatalf("expected one error event about the panic, got %+v", history)
+	}
+
+	if _, err := s.UnaryInterceptor(context.Background(), &adapterrpc.HealthRequest{}, &grpc.UnaryServerInfo{FullMethod: adapterrpc.MethodHealth}, func(ctx context.Context, req any) (any, error) {
+		return s.Health(ctx, req.(*adapterrpc.HealthRequest))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	metrics := s.Metrics()
+	if len(metrics) != 2 || metrics[0].Method != adapterrpc.MethodExtendRun || metrics[0].Panics != 1 || metrics[0].Errors != 1 || metrics[1].Calls != 1 || metrics[1].Errors != 0 {
+		t.Fatalf("unexpected metrics: %+v", metrics)
+	}
+}
+
+func TestPauseRunStopsProcessAndDeadline(t *testing.T) {
+	if !pauseSupported {
+		t.Skip("pause is not supported on this platform")
+	}
+	s := NewServer(Config{
+		Backend:       "test",
+		CLIBinDefault: "sh",
+		Mapper: func(line, source string) (NormalizedEvent, bool) {
+			return NormalizedEvent{Type: "token", Channel: "final", Format: "plain", Role: "assistant", Payload: map[string]any{"text": line}}, true
+		},
+		ApplyPromptArg: func(args []string, mode, prompt string) []string {
+			return []string{"-c", "echo started; sleep 0.8; echo finished; sleep 0.3"}
+		},
+	})
+	res, err := s.StartRun(context.Background(), &adapterrpc.StartRunRequest{
+		RunID: "r1", WorkspacePath: t.TempDir(), Prompt: "go", TimeoutSec: 2,
+	})
+	if err != nil || !res.Accepted {
+		t.Fatalf("start: %v %+v", err, res)
+	}
+	rs, err := s.getRun("r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, ch, unsub := rs.subscribe()
+	defer unsub()
+	next := func(timeout time.Duration) *adapterrpc.AgentEvent {
+		if len(history) > 0 {
+			ev := history[0]
+			history = history[1:]
+			return ev
+		}
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				t.Fatal("stream closed")
+			}
+			return ev
+		case <-time.After(timeout):
+			return nil
+		}
+	}
+	for ev := next(2 * time.Second); ev == nil || ev.Payload["text"] != "started"; ev = next(2 * time.Second) {
+		if ev == nil {
+			t.Fatal("timed out waiting for start")
+		}
+	}
+
+	if p, err := s.PauseRun(context.Background(), &adapterrpc.PauseRunRequest{RunID: "r1"}); err != nil || !p.Paused {
+		t.Fatalf("pause: %v %+v", err, p)
+	}
+	if p, _ := s.PauseRun(context.Background(), &adapterrpc.PauseRunRequest{RunID: "r1"}); p.Paused {
+		t.Fatal("second pause accepted")
+	}
+	// Stopped for longer than both the sleep and the 2s timeout.
+	if ev := next(2500 * time.Millisecond); ev != nil {
+		t.Fatalf("paused run produced %v", ev.Payload)
+	}
+	if r, err := s.ResumeRun(context.Background(), &adapterrpc.ResumeRunRequest{RunID: "r1"}); err != nil || !r.Resumed {
+		t.Fatalf("resume: %v %+v", err, r)
+	}
+	for ev := next(3 * time.Second); ev == nil || ev.Payload["text"] != "finished"; ev = next(3 * time.Second) {
+		if ev == nil {
+			t.Fatal("resumed run did not finish")
+		}
+	}
+}
+
+func TestRawLogCapturesAndRotatesCLIOutput(t *testing.T) {
+	dir := t.TempDir()
+	s := NewServer(Config{
+		Backend:        "test",
+		CLIBinDefault:  "sh",
+		RawLogDir:      dir,
+		RawLogMaxBytes: 200,
+		Mapper: func(line, source string) (NormalizedEvent, bool) {
+			return NormalizedEvent{}, false
+		},
+		ApplyPromptArg: func(args []string, mode, prompt string) []string {
+			return []string{"-c", "for i in 1 2 3 4 5 6; do echo out-$i; done; echo oops >&2; sleep 0.2"}
+		},
+	})
+	if res, _ := s.GetRawLog(context.Background(), &adapterrpc.GetRawLogRequest{RunID: "r1"}); res.Found || res.Disabled {
+		t.Fatalf("raw log before run = %+v", res)
+	}
+	if res, err := s.StartRun(context.Background(), &adapterrpc.StartRunRequest{RunID: "r1", WorkspacePath: t.TempDir(), Prompt: "go"}); err != nil || !res.Accepted {
+		t.Fatalf("start: %v %+v", err, res)
+	}
+	var res *adapterrpc.GetRawLogResponse
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		res, _ = s.GetRawLog(context.Background(), &adapterrpc.GetRawLogRequest{RunID: "r1"})
+		if strings.Contains(res.Data, "stderr oops") && strings.Contains(res.Data, "stdout out-6") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("raw log = %+v", res)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "r1.log.1")); err != nil {
+		t.Fatalf("expected a rotated log: %v", err)
+	}
+	tail, _ := s.GetRawLog(context.Background(), &adapterrpc.GetRawLogRequest{RunID: "r1", MaxBytes: 10})
+	if !tail.Truncated || len(tail.Data) != 10 || tail.Size != res.Size {
+		t.Fatalf("tail = %+v", tail)
+	}
+	if bad, _ := s.GetRawLog(context.Background(), &adapterrpc.GetRawLogRequest{RunID: "../r1"}); bad.Found || bad.Error == "" {
+		t.Fatalf("escaping run id = %+v", bad)
+	}
+	if off, _ := NewServer(Config{Backend: "test"}).GetRawLog(context.Background(), &adapterrpc.GetRawLogRequest{RunID: "r1"}); !off.Disabled {
+		t.Fatalf("capture without dir = %+v", off)
+	}
+}
+
+func TestMapperPanicFailsRunInsteadOfCrashing(t *testing.T) {
+	s := NewServer(Config{
+		Backend:       "test",
+		CLIBinDefault: "sh",
+		Mapper: func(line, source string) (NormalizedEvent, bool) {
+			if line == "boom" {
+				panic("bad line")
+			}
+			return NormalizedEvent{Type: "token", Channel: "final", Format: "plain", Role: "assistant", Payload: map[string]any{"text": line}}, true
+		},
+		ApplyPromptArg: func(args []string, mode, prompt string) []string {
+			return []string{"-c", "echo ok; echo boom; sleep 10"}
+		},
+	})
+	res, err := s.StartRun(context.Background(), &adapterrpc.StartRunRequest{RunID: "r1", WorkspacePath: t.TempDir(), Prompt: "go"})
+	if err != nil || !res.Accepted {
+		t.Fatalf("start: %v %+v", err, res)
+	}
+	rs, err := s.getRun("r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, ch, unsub := rs.subscribe()
+	defer unsub()
+	timeout := time.After(5 * time.Second)
+	var got []*adapterrpc.AgentEvent
+	for done := false; !done; {
+		var ev *adapterrpc.AgentEvent
+		if len(history) > 0 {
+			ev, history = history[0], history[1:]
+		} else {
+			select {
+			case e, ok := <-ch:
+				if !ok {
+					t.Fatalf("stream closed without a done event: %+v", got)
+				}
+				ev = e
+			case <-timeout:
+				t.Fatal("timed out: the CLI was not stopped after the mapper panic")
+			}
+		}
+		got = append(got, ev)
+		done = ev.Type == "done"
+	}
+	last, errEv := got[len(got)-1], got[len(got)-2]
+	if last.Payload["status"] != "failed" || errEv.Type != "error" || !strings.Contains(errEv.Payload["message"].(string), "bad line") {
+		t.Fatalf("expected an error then a failed done event, got %+v", got)
+	}
+}
@@ -0,0 +1,247 @@
+package supervisor
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// HealthPolicy controls the background health monitor and crash-loop backoff.
+type HealthPolicy struct {
+	// Interval between Health probes while the adapter is in use.
+	Interval time.Duration
+	Timeout  time.Duration
+	// FailureThreshold consecutive failed probes mark the adapter unhealthy
+	// and force a restart.
+	FailureThreshold int
+	BackoffBase      time.Duration
+	BackoffMax       time.Duration
+	// A process that stays up for StableAfter resets the crash streak.
+	StableAfter time.Duration
+	// CrashLoopThreshold consecutive short-lived exits flag a crash loop.
+	CrashLoopThreshold int
+}
+
+func DefaultHealthPolicy() HealthPolicy {
+	return HealthPolicy{
+		Interval:           10 * time.Second,
+		Timeout:            3 * time.Second,
+		FailureThreshold:   3,
+		BackoffBase:        time.Second,
+		BackoffMax:         2 * time.Minute,
+		StableAfter:        30 * time.Second,
+		CrashLoopThreshold: 3,
+	}
+}
+
+// Probe checks a running adapter, typically through its Health RPC.
+type Probe func(ctx context.Context) error
+
+// StateListener is notified when the adapter turns unhealthy or recovers.
+type StateListener func(healthy bool, message string)
+
+// Status is a point-in-time view of the supervised adapter.
+type Status struct {
+	Running             bool       `json:"running"`
+	Healthy             bool       `json:"healthy"`
+	Restarts            int64      `json:"restarts"`
+	Crashes             int64      `json:"crashes"`
+	ConsecutiveCrashes  int        `json:"consecutive_crashes"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	CrashLoop           bool       `json:"crash_loop"`
+	BackoffUntil        *time.Time `json:"backoff_until,omitempty"`
+	LastExit            string     `json:"last_exit,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastCheckAt         *time.Time `json:"last_check_at,omitempty"`
+}
+
+type healthState struct {
+	wanted              bool
+	healthy             bool
+	starts              int64
+	restarts            int64
+	crashes             int64
+	consecutiveCrashes  int
+	consecutiveFailures int
+	startedAt           time.Time
+	backoffUntil        time.Time
+	lastExit            string
+	lastError           string
+	lastCheckAt         time.Time
+}
+
+func (s *Supervisor) SetHealthPolicy(p HealthPolicy) {
+	def := DefaultHealthPolicy()
+	if p.Interval <= 0 {
+		p.Interval = def.Interval
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = def.Timeout
+	}
+	if p.FailureThreshold <= 0 {
+		p.FailureThreshold = def.FailureThreshold
+	}
+	if p.BackoffBase <= 0 {
+		p.BackoffBase = def.BackoffBase
+	}
+	if p.BackoffMax < p.BackoffBase {
+		p.BackoffMax = p.BackoffBase
+	}
+	if p.StableAfter <= 0 {
+		p.StableAfter = def.StableAfter
+	}
+	if p.CrashLoopThreshold <= 0 {
+		p.CrashLoopThreshold = def.CrashLoopThreshold
+	}
+	s.mu.Lock()
+	s.policy = p
+	s.mu.Unlock()
+}
+
+func (s *Supervisor) SetStateListener(fn StateListener) {
+	s.mu.Lock()
+	s.listener = fn
+	s.mu.Unlock()
+}
+
+func (s *Supervisor) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.health
+	st := Status{
+		Running:             s.cmd != nil,
+		Healthy:             h.healthy,
+		Restarts:            h.restarts,
+		Crashes:             h.crashes,
+		ConsecutiveCrashes:  h.consecutiveCrashes,
+		ConsecutiveFailures: h.consecutiveFailures,
+		CrashLoop:           h.consecutiveCrashes >= s.policy.CrashLoopThreshold,
+		LastExit:            h.lastExit,
+		LastError:           h.lastError,
+	}
+	if time.Now().Before(h.backoffUntil) {
+		until := h.backoffUntil.UTC()
+		st.BackoffUntil = &until
+	}
+	if !h.lastCheckAt.IsZero() {
+		at := h.lastCheckAt.UTC()
+		st.LastCheckAt = &at
+	}
+	return st
+}
+
+// Monitor probes the adapter every policy interval until ctx is done. Adapters
+// that were never started (or were stopped explicitly) are left alone; crashed
+// or unresponsive ones are restarted, subject to exponential backoff.
+func (s *Supervisor) Monitor(ctx context.Context, probe Probe) {
+	s.mu.Lock()
+	interval := s.policy.Interval
+	s.mu.Unlock()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.CheckHealth(ctx, probe)
+		}
+	}
+}
+
+// CheckHealth runs a single monitor iteration.
+func (s *Supervisor) CheckHealth(ctx context.Context, probe Probe) {
+	s.mu.Lock()
+	wanted := s.health.wanted
+	running := s.cmd != nil
+	timeout := s.policy.Timeout
+	s.mu.Unlock()
+	if !wanted {
+		return
+	}
+	if !running {
+		if err := s.EnsureRunning(ctx); err != nil {
+			s.observe(false, err.Error(), false)
+			return
+		}
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	err := probe(probeCtx)
+	cancel()
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		s.observe(false, err.Error(), true)
+		return
+	}
+	s.observe(true, "", true)
+}
+
+func (s *Supervisor) observe(ok bool, message string, probed bool) {
+	s.mu.Lock()
+	h := &s.health
+	h.lastCheckAt = time.Now()
+	wasHealthy := h.healthy
+	if ok {
+		h.consecutiveFailures = 0
+		h.lastError = ""
+		h.healthy = true
+		if time.Since(h.startedAt) >= s.policy.StableAfter {
+			h.consecutiveCrashes = 0
+		}
+	} else {
+		h.lastError = message
+		if probed {
+			h.consecutiveFailures++
+		}
+		if !probed || h.consecutiveFailures >= s.policy.FailureThreshold {
+			h.healthy = false
+		}
+		if probed && h.consecutiveFailures >= s.policy.FailureThreshold && s.cmd != nil && s.cmd.Process != nil {
+			// Kill the unresponsive process; waitProcess records it as a
+			// crash and the next check restarts it after backoff.
+			log.Printf("%s unhealthy after %d failed checks, restarting", s.name(), h.consecutiveFailures)
+			h.consecutiveFailures = 0
+			_ = s.cmd.Process.Kill()
+		}
+	}
+	changed := wasHealthy != h.healthy
+	listener := s.listener
+	healthy := h.healthy
+	s.mu.Unlock()
+	if changed && listener != nil {
+		listener(healthy, message)
+	}
+}
+
+// recordCrashLocked accounts for an unexpected exit and schedules the next
+// allowed restart. Callers must hold s.mu.
+func (s *Supervisor) recordCrashLocked(exit string) {
+	h := &s.health
+	h.crashes++
+	h.lastExit = exit
+	if time.Since(h.startedAt) >= s.policy.StableAfter {
+		h.consecutiveCrashes = 0
+	}
+	h.consecutiveCrashes++
+	backoff := s.policy.BackoffMax
+	if shift := h.consecutiveCrashes - 1; shift < 20 {
+		backoff = s.policy.BackoffBase << shift
+	}
+	if backoff > s.policy.BackoffMax {
+		backoff = s.policy.BackoffMax
+	}
+	h.backoffUntil = time.Now().Add(backoff)
+	if h.consecutiveCrashes >= s.policy.CrashLoopThreshold {
+		log.Printf("%s crash loop detected (%d consecutive exits), next restart in %s", s.name(), h.consecutiveCrashes, backoff)
+	}
+}
+
+func (s *Supervisor) name() string {
+	if s.cfg.Name == "" {
+		return "adapter"
+	}
+	return s.cfg.Name
+}
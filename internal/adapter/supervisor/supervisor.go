@@ -16,32 +16,44 @@ type Config struct {
 	Name       string
 	BinaryPath string
 	GRPCAddr   string
+	// Env is appended to the bridge environment when starting the adapter.
+	Env []string
 }
 
 type Supervisor struct {
 	cfg Config
 
-	mu  sync.Mutex
-	cmd *exec.Cmd
+	mu       sync.Mutex
+	cmd      *exec.Cmd
+	stopping *exec.Cmd
+	policy   HealthPolicy
+	listener StateListener
+	health   healthState
 }
 
 func New(cfg Config) *Supervisor {
-	return &Supervisor{cfg: cfg}
+	return &Supervisor{cfg: cfg, policy: DefaultHealthPolicy(), health: healthState{healthy: true}}
 }
 
 func (s *Supervisor) EnsureRunning(_ context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.cmd != nil && s.cmd.Process != nil && s.cmd.ProcessState == nil {
+	if s.cmd != nil && s.cmd.Process != nil {
 		return nil
 	}
 
+	if wait := time.Until(s.health.backoffUntil); wait > 0 {
+		return fmt.Errorf("adapter restart backoff: retry in %s", wait.Round(time.Millisecond))
+	}
 	if _, err := os.Stat(s.cfg.BinaryPath); err != nil {
 		return fmt.Errorf("adapter binary missing: %w", err)
 	}
 
 	cmd := exec.Command(s.cfg.BinaryPath, "--listen", s.cfg.GRPCAddr)
+	if len(s.cfg.Env) > 0 {
+		cmd.Env = append(os.Environ(), s.cfg.Env...)
+	}
 	stdout, _ := cmd.StdoutPipe()
 	stderr, _ := cmd.StderrPipe()
 	if err := cmd.Start(); err != nil {
@@ -49,18 +61,23 @@ func (s *Supervisor) EnsureRunning(_ context.Context) error {
 	}
 
 	s.cmd = cmd
-	prefix := s.cfg.Name
-	if prefix == "" {
-		prefix = "adapter"
+	if s.health.starts > 0 {
+		s.health.restarts++
 	}
+	s.health.starts++
+	s.health.wanted = true
+	s.health.startedAt = time.Now()
+	prefix := s.name()
 	go scan(stdout, prefix+":stdout")
 	go scan(stderr, prefix+":stderr")
-	go s.waitProcess(cmd)
+	exited := make(chan struct{})
+	go s.waitProcess(cmd, exited)
 
-	time.Sleep(250 * time.Millisecond)
-	if cmd.ProcessState != nil {
+	select {
+	case <-exited:
 		s.cmd = nil
 		return fmt.Errorf("adapter process exited early: %s", cmd.ProcessState.String())
+	case <-time.After(250 * time.Millisecond):
 	}
 	return nil
 }
@@ -68,11 +85,13 @@ func (s *Supervisor) EnsureRunning(_ context.Context) error {
 func (s *Supervisor) Stop() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.health.wanted = false
 	if s.cmd == nil || s.cmd.Process == nil {
 		return nil
 	}
 	cmd := s.cmd
 	s.cmd = nil
+	s.stopping = cmd
 	if err := cmd.Process.Kill(); err != nil {
 		if err.Error() == "os: process already finished" {
 			return nil
@@ -82,8 +101,10 @@ func (s *Supervisor) Stop() error {
 	return nil
 }
 
-func (s *Supervisor) waitProcess(cmd *exec.Cmd) {
-	if err := cmd.Wait(); err != nil {
+func (s *Supervisor) waitProcess(cmd *exec.Cmd, exited chan<- struct{}) {
+	err := cmd.Wait()
+	close(exited)
+	if err != nil {
 		log.Printf("adapter exited with error: %v", err)
 	} else {
 		log.Printf("adapter exited")
@@ -92,6 +113,12 @@ func (s *Supervisor) waitProcess(cmd *exec.Cmd) {
 	if s.cmd == cmd {
 		s.cmd = nil
 	}
+	if s.stopping == cmd {
+		s.stopping = nil
+		s.mu.Unlock()
+		return
+	}
+	s.recordCrashLocked(cmd.ProcessState.String())
 	s.mu.Unlock()
 }
 
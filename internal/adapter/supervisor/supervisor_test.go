@@ -2,10 +2,13 @@ package supervisor
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestEnsureRunningMissingBinary(t *testing.T) {
@@ -59,3 +62,75 @@ func TestEnsureRunningReturnsErrorWhenProcessExitsImmediately(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestCrashSchedulesBackoffBeforeRestart(t *testing.T) {
+	t.Parallel()
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("resolve current executable: %v", err)
+	}
+	s := New(Config{Name: "crashy", BinaryPath: exe, GRPCAddr: "127.0.0.1:50051"})
+	s.SetHealthPolicy(HealthPolicy{BackoffBase: time.Minute})
+
+	_ = s.EnsureRunning(context.Background())
+	waitFor(t, func() bool { return s.Status().Crashes == 1 })
+
+	st := s.Status()
+	if st.BackoffUntil == nil || st.ConsecutiveCrashes != 1 {
+		t.Fatalf("expected backoff after crash, got %+v", st)
+	}
+	err = s.EnsureRunning(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "restart backoff") {
+		t.Fatalf("expected backoff error, got %v", err)
+	}
+}
+
+func TestCheckHealthRestartsUnhealthyAdapter(t *testing.T) {
+	t.Parallel()
+
+	bin := filepath.Join(t.TempDir(), "adapter.sh")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\nexec sleep 30\n"), 0o755); err != nil {
+		t.Fatalf("write adapter script: %v", err)
+	}
+	s := New(Config{Name: "hung", BinaryPath: bin, GRPCAddr: "127.0.0.1:50051"})
+	s.SetHealthPolicy(HealthPolicy{FailureThreshold: 1, BackoffBase: time.Millisecond})
+	t.Cleanup(func() { _ = s.Stop() })
+
+	var transitions []bool
+	var mu sync.Mutex
+	s.SetStateListener(func(healthy bool, _ string) {
+		mu.Lock()
+		transitions = append(transitions, healthy)
+		mu.Unlock()
+	})
+	if err := s.EnsureRunning(context.Background()); err != nil {
+		t.Fatalf("ensure running: %v", err)
+	}
+
+	s.CheckHealth(context.Background(), func(context.Context) error { return errors.New("deadline exceeded") })
+	waitFor(t, func() bool { return s.Status().Crashes == 1 })
+	time.Sleep(5 * time.Millisecond)
+
+	s.CheckHealth(context.Background(), func(context.Context) error { return nil })
+	st := s.Status()
+	if !st.Running || !st.Healthy || st.Restarts != 1 {
+		t.Fatalf("expected restarted healthy adapter, got %+v", st)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(transitions) != 2 || transitions[0] || !transitions[1] {
+		t.Fatalf("expected unhealthy then healthy transitions, got %v", transitions)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met before deadline")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
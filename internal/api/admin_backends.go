@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"echohelix/internal/apierror"
+	"echohelix/internal/rpc/transport"
+	"echohelix/internal/run"
+)
+
+const adminBackendsPath = "/api/v3/admin/backends"
+
+// handleAdminBackends attaches an external adapter backend at runtime (POST)
+// or detaches one (DELETE .../{name}).
+func (s *Server) handleAdminBackends(w http.ResponseWriter, r *http.Request) {
+	if !s.requireBootstrapOperator(w, r) {
+		return
+	}
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, adminBackendsPath), "/")
+	if name != "" {
+		if r.Method != http.MethodDelete {
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+			return
+		}
+		if err := s.runSvc.UnregisterBackend(r.Context(), name); err != nil {
+			writeServiceError(w, http.StatusInternalServerError, err)
+			return
+		}
+		s.auditf(r, "backend_unregister", "backend="+name)
+		writeJSON(w, http.StatusOK, map[string]any{"name": name, "unregistered": true})
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	var req struct {
+		Name          string   `json:"name"`
+		GRPCAddr      string   `json:"grpc_addr"`
+		BinaryPath    string   `json:"binary_path"`
+		Env           []string `json:"env"`
+		TLSCertFile   string   `json:"tls_cert_file"`
+		TLSKeyFile    string   `json:"tls_key_file"`
+		TLSCAFile     string   `json:"tls_ca_file"`
+		TLSServerName string   `json:"tls_server_name"`
+		AuthToken     string   `json:"auth_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+	cfg := run.ExternalBackend{
+		Name:       strings.TrimSpace(req.Name),
+		GRPCAddr:   strings.TrimSpace(req.GRPCAddr),
+		BinaryPath: strings.TrimSpace(req.BinaryPath),
+		Env:        req.Env,
+		Security: transport.Security{
+			CertFile:   req.TLSCertFile,
+			KeyFile:    req.TLSKeyFile,
+			CAFile:     req.TLSCAFile,
+			ServerName: req.TLSServerName,
+			Token:      req.AuthToken,
+		},
+	}
+	if err := s.runSvc.RegisterBackend(r.Context(), cfg); err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.auditf(r, "backend_register", "backend="+cfg.Name+" grpc_addr="+cfg.GRPCAddr+" supervised="+strconv.FormatBool(cfg.BinaryPath != ""))
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"name":        cfg.Name,
+		"grpc_addr":   cfg.GRPCAddr,
+		"binary_path": cfg.BinaryPath,
+		"supervised":  cfg.BinaryPath != "",
+	})
+}
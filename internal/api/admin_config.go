@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"echohelix/internal/apierror"
+	"echohelix/internal/config"
+)
+
+const adminConfigPath = "/api/v3/admin/config"
+
+// maxConfigReloads bounds how many reload diffs the endpoint keeps.
+const maxConfigReloads = 10
+
+// ConfigReload is the diff recorded when the effective configuration is
+// replaced after start-up.
+type ConfigReload struct {
+	At      time.Time              `json:"at"`
+	Changes []config.SettingChange `json:"changes"`
+}
+
+type effectiveConfig struct {
+	mu       sync.RWMutex
+	settings []config.Setting
+	loadedAt time.Time
+	reloads  []ConfigReload
+}
+
+// SetEffectiveConfig publishes the settings the bridge runs with. Every call
+// after the first is treated as a hot reload and its diff against the
+// previous settings is kept, newest first.
+func (s *Server) SetEffectiveConfig(settings []config.Setting) {
+	c := &s.effectiveConfig
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now().UTC()
+	if !c.loadedAt.IsZero() {
+		reload := ConfigReload{At: now, Changes: config.Diff(c.settings, settings)}
+		c.reloads = append([]ConfigReload{reload}, c.reloads...)
+		if len(c.reloads) > maxConfigReloads {
+			c.reloads = c.reloads[:maxConfigReloads]
+		}
+	}
+	c.settings = append([]config.Setting(nil), settings...)
+	c.loadedAt = now
+}
+
+// handleAdminConfig reports the effective configuration with each value's
+// source. ?view=diff returns only the reload history.
+func (s *Server) handleAdminConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !s.requireBootstrapOperator(w, r) {
+		return
+	}
+	c := &s.effectiveConfig
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.loadedAt.IsZero() {
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "effective configuration is not available")
+		return
+	}
+	reloads := c.reloads
+	if reloads == nil {
+		reloads = []ConfigReload{}
+	}
+	if r.URL.Query().Get("view") == "diff" {
+		writeJSON(w, http.StatusOK, map[string]any{"reloads": reloads})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"loaded_at": c.loadedAt,
+		"settings":  c.settings,
+		"reloads":   reloads,
+	})
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"echohelix/internal/apierror"
+	"echohelix/internal/auth"
+)
+
+const adminTokensPath = "/api/v3/admin/tokens"
+
+// handleAdminTokens manages named static operator tokens: list (GET), create
+// (POST), and POST .../{id}/rotate or .../{id}/revoke.
+func (s *Server) handleAdminTokens(w http.ResponseWriter, r *http.Request) {
+	if !s.requireBootstrapOperator(w, r) {
+		return
+	}
+	if s.authSvc == nil {
+		writeError(w, http.StatusServiceUnavailable, apierror.CodeUnavailable, "auth service unavailable")
+		return
+	}
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, adminTokensPath), "/")
+	if rest == "" {
+		switch r.Method {
+		case http.MethodGet:
+			items, err := s.authSvc.ListAdminTokens(r.Context())
+			if err != nil {
+				writeServiceError(w, http.StatusInternalServerError, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"items": items})
+		case http.MethodPost:
+			s.createAdminToken(w, r)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		}
+		return
+	}
+	id, action, _ := strings.Cut(rest, "/")
+	if r.Method != http.MethodPost || (action != "rotate" && action != "revoke") {
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "not found")
+		return
+	}
+	if action == "revoke" {
+		if err := s.authSvc.RevokeAdminToken(r.Context(), id); err != nil {
+			writeAdminTokenError(w, err)
+			return
+		}
+		s.auditf(r, "admin_token_revoke", "id="+id)
+		writeJSON(w, http.StatusOK, map[string]any{"id": id, "revoked": true})
+		return
+	}
+	var req struct {
+		TTLSeconds   int `json:"ttl_seconds"`
+		GraceSeconds int `json:"grace_seconds"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "invalid json")
+			return
+		}
+	}
+	issued, err := s.authSvc.RotateAdminToken(
+		r.Context(), id,
+		time.Duration(req.TTLSeconds)*time.Second,
+		time.Duration(req.GraceSeconds)*time.Second,
+		s.adminTokenActor(r),
+		s.adminTokenIssuer(r),
+	)
+	if err != nil {
+		writeAdminTokenError(w, err)
+		return
+	}
+	s.auditf(r, "admin_token_rotate", "id="+id+" name="+issued.Name+" replaced_by="+issued.ID)
+	writeJSON(w, http.StatusCreated, issued)
+}
+
+func (s *Server) createAdminToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name       string   `json:"name"`
+		Scopes     []string `json:"scopes"`
+		TTLSeconds int      `json:"ttl_seconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "invalid json")
+		return
+	}
+	issued, err := s.authSvc.CreateAdminToken(r.Context(), req.Name, req.Scopes, time.Duration(req.TTLSeconds)*time.Second, s.adminTokenActor(r), s.adminTokenIssuer(r))
+	if err != nil {
+		writeAdminTokenError(w, err)
+		return
+	}
+	s.auditf(r, "admin_token_create", "id="+issued.ID+" name="+issued.Name)
+	writeJSON(w, http.StatusCreated, issued)
+}
+
+func (s *Server) adminTokenActor(r *http.Request) string {
+	if principal, ok := s.principalFromContext(r.Context()); ok {
+		if principal.Address != "" {
+			return principal.Address
+		}
+		if principal.AuthType == "static" {
+			return auth.BootstrapTokenName
+		}
+	}
+	return "admin"
+}
+
+func (s *Server) adminTokenIssuer(r *http.Request) auth.Principal {
+	principal, _ := s.principalFromContext(r.Context())
+	return principal
+}
+
+func writeAdminTokenError(w http.ResponseWriter, err error) {
+	writeServiceError(w, http.StatusBadRequest, err)
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"echohelix/internal/apierror"
+	"echohelix/internal/auth"
+)
+
+const backendsPath = "/api/v3/backends/"
+
+// handleBackendByName serves GET /api/v3/backends/{name}/models.
+func (s *Server) handleBackendByName(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, backendsPath), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "models" {
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "not found")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	if _, ok := s.requireScope(w, r, auth.ScopeBackendsRead); !ok {
+		return
+	}
+	models, err := s.runSvc.ListModels(r.Context(), parts[0])
+	if err != nil {
+		writeServiceError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, models)
+}
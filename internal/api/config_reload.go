@@ -0,0 +1,166 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"echohelix/internal/apierror"
+	"echohelix/internal/config"
+	"echohelix/internal/policy"
+	"echohelix/internal/run"
+)
+
+const adminConfigReloadPath = adminConfigPath + "/reload"
+
+var errConfigReloadDisabled = errors.New("config reload is not enabled")
+
+// ConfigReloadResult is what one reload did: Applied changes are live,
+// RestartRequired ones were read but only take effect after a restart.
+type ConfigReloadResult struct {
+	Applied         []config.SettingChange `json:"applied"`
+	RestartRequired []config.SettingChange `json:"restart_required"`
+}
+
+type configReloader struct {
+	mu     sync.Mutex
+	policy *policy.Policy
+	load   func() (config.Config, error)
+}
+
+// EnableConfigReload turns on POST /api/v3/admin/config/reload and
+// ReloadOnSIGHUP. load re-reads the configuration (config.Reload when nil)
+// and p is the policy holding the workspace roots and run timeout cap.
+func (s *Server) EnableConfigReload(p *policy.Policy, load func() (config.Config, error)) {
+	if load == nil {
+		load = config.Reload
+	}
+	s.configReload.mu.Lock()
+	s.configReload.policy, s.configReload.load = p, load
+	s.configReload.mu.Unlock()
+}
+
+// ReloadOnSIGHUP reloads the configuration on every SIGHUP until ctx ends.
+func (s *Server) ReloadOnSIGHUP(ctx context.Context) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ch:
+				out, err := s.ReloadConfig()
+				if err != nil {
+					log.Printf("warn: config reload on SIGHUP: %v", err)
+					continue
+				}
+				log.Printf("config reloaded on SIGHUP: applied=%d restart_required=%d", len(out.Applied), len(out.RestartRequired))
+			}
+		}
+	}()
+}
+
+// ReloadConfig re-reads the configuration and applies its reloadable
+// settings. Nothing is applied when the new configuration is invalid. The
+// published effective configuration keeps the running values of settings
+// that need a restart.
+func (s *Server) ReloadConfig() (ConfigReloadResult, error) {
+	rl := &s.configReload
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.load == nil {
+		return ConfigReloadResult{}, errConfigReloadDisabled
+	}
+	cfg, err := rl.load()
+	if err != nil {
+		return ConfigReloadResult{}, err
+	}
+	enforcement, err := run.NormalizeQuotaEnforcement(cfg.QuotaEnforcement)
+	if err != nil {
+		return ConfigReloadResult{}, err
+	}
+
+	if s.runSvc != nil {
+		s.runSvc.SetDailyTokenQuota(cfg.DailyTokenQuota)
+		s.runSvc.SetDeviceDailyTokenQuota(cfg.DeviceDailyTokenQuota)
+		_ = s.runSvc.SetQuotaEnforcement(enforcement)
+	}
+	if s.sessionSvc != nil {
+		s.sessionSvc.SetBlockedMethods(cfg.BackendCallBlockedMethods)
+	}
+	if rl.policy != nil {
+		rl.policy.SetWorkspaceRoots(cfg.WorkspaceRoots)
+		rl.policy.SetMaxRunTimeout(cfg.RunTimeoutMax)
+	}
+	limits := normalizeSecurityConfig(SecurityConfig{PairStartRateLimit: cfg.PairStartRateLimit, PairStartRateWindow: cfg.PairStartRateWindow})
+	s.pairStartLimiter.setLimit(limits.PairStartRateLimit, limits.PairStartRateWindow)
+
+	c := &s.effectiveConfig
+	c.mu.RLock()
+	running := append([]config.Setting(nil), c.settings...)
+	c.mu.RUnlock()
+	out := ConfigReloadResult{Applied: []config.SettingChange{}, RestartRequired: []config.SettingChange{}}
+	for _, ch := range config.Diff(running, cfg.Settings) {
+		if config.Reloadable(ch.Key) {
+			out.Applied = append(out.Applied, ch)
+		} else {
+			out.RestartRequired = append(out.RestartRequired, ch)
+		}
+	}
+	s.SetEffectiveConfig(mergeReloadedSettings(running, cfg.Settings))
+	return out, nil
+}
+
+// mergeReloadedSettings takes the reloadable settings from cur and keeps
+// the rest as they were running.
+func mergeReloadedSettings(running, cur []config.Setting) []config.Setting {
+	if len(running) == 0 {
+		return cur
+	}
+	next := make(map[string]config.Setting, len(cur))
+	for _, st := range cur {
+		next[st.Key] = st
+	}
+	out := make([]config.Setting, 0, len(running))
+	for _, st := range running {
+		if n, ok := next[st.Key]; ok && config.Reloadable(st.Key) {
+			st = n
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// handleAdminConfigReload serves POST /api/v3/admin/config/reload.
+func (s *Server) handleAdminConfigReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !s.requireBootstrapOperator(w, r) {
+		return
+	}
+	out, err := s.ReloadConfig()
+	switch {
+	case errors.Is(err, errConfigReloadDisabled):
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusUnprocessableEntity, apierror.CodeUnprocessable, err.Error())
+		return
+	}
+	keys := make([]string, 0, len(out.Applied))
+	for _, ch := range out.Applied {
+		keys = append(keys, ch.Key)
+	}
+	s.auditf(r, "config_reloaded", "applied="+strings.Join(keys, ","))
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"echohelix/internal/apierror"
+)
+
+// contractFS holds the golden response shapes checked by
+// TestAPIContractGoldenFixtures. Regenerate them with
+// `go test ./internal/api -run TestAPIContractGoldenFixtures -update`.
+//
+//go:embed contract/*.json
+var contractFS embed.FS
+
+const contractFixturesPath = "/api/v3/contract/fixtures"
+
+func (s *Server) handleContractFixtures(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, contractFixturesPath), "/")
+	if name == "" {
+		entries, err := fs.ReadDir(contractFS, "contract")
+		if err != nil {
+			writeServiceError(w, http.StatusInternalServerError, err)
+			return
+		}
+		items := make([]map[string]any, 0, len(entries))
+		for _, e := range entries {
+			data, err := contractFS.ReadFile(path.Join("contract", e.Name()))
+			if err != nil {
+				continue
+			}
+			sum := sha256.Sum256(data)
+			items = append(items, map[string]any{
+				"name":   strings.TrimSuffix(e.Name(), ".json"),
+				"sha256": hex.EncodeToString(sum[:]),
+				"url":    contractFixturesPath + "/" + strings.TrimSuffix(e.Name(), ".json"),
+			})
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i]["name"].(string) < items[j]["name"].(string) })
+		writeJSON(w, http.StatusOK, map[string]any{"items": items})
+		return
+	}
+	if strings.ContainsAny(name, "/\\") {
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "fixture not found")
+		return
+	}
+	data, err := contractFS.ReadFile(path.Join("contract", strings.TrimSuffix(name, ".json")+".json"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "fixture not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
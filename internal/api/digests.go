@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"echohelix/internal/apierror"
+	"echohelix/internal/auth"
+	"echohelix/internal/run"
+)
+
+const (
+	digestsPath         = "/api/v3/digests"
+	defaultDigestsLimit = 30
+	maxDigestsLimit     = 366
+)
+
+// handleDigests serves GET /api/v3/digests and POST /api/v3/digests/generate.
+// Devices only see their own digests; bootstrap operators may filter by
+// principal, including run.DigestAllPrincipals for the bridge-wide digest.
+func (s *Server) handleDigests(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == digestsPath+"/generate" {
+		s.handleDigestsGenerate(w, r)
+		return
+	}
+	if r.URL.Path != digestsPath {
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "not found")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	principal, ok := s.principalFromContext(r.Context())
+	operator := ok && principal.IsOperator()
+	if !operator {
+		if principal, ok = s.requireScope(w, r, auth.ScopeRunsRead); !ok {
+			return
+		}
+	}
+	q := r.URL.Query()
+	query := run.DigestQuery{
+		Principal: strings.TrimSpace(q.Get("principal")),
+		From:      q.Get("from"),
+		To:        q.Get("to"),
+		Limit:     defaultDigestsLimit,
+	}
+	if !operator {
+		if query.Principal != "" && query.Principal != principal.Address {
+			writeError(w, http.StatusForbidden, apierror.CodeForbidden, "devices can only read their own digests")
+			return
+		}
+		query.Principal = principal.Address
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxDigestsLimit {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "limit must be between 1 and 366")
+			return
+		}
+		query.Limit = n
+	}
+	items, err := s.runSvc.ListDigests(r.Context(), query)
+	if err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// handleDigestsGenerate (re)builds and delivers the digests of a past day,
+// yesterday by default.
+func (s *Server) handleDigestsGenerate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !s.requireBootstrapOperator(w, r) {
+		return
+	}
+	var req struct {
+		Day string `json:"day"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+	day := time.Now().UTC().AddDate(0, 0, -1)
+	if req.Day != "" {
+		parsed, err := time.Parse("2006-01-02", req.Day)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "day must be YYYY-MM-DD")
+			return
+		}
+		day = parsed
+	}
+	items, err := s.runSvc.GenerateDailyDigests(r.Context(), day)
+	if err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.auditf(r, "digests_generate", "day="+day.Format("2006-01-02")+" count="+strconv.Itoa(len(items)))
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
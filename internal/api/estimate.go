@@ -0,0 +1,35 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"echohelix/internal/apierror"
+	"echohelix/internal/auth"
+	"echohelix/internal/run"
+)
+
+const estimatePath = "/api/v3/estimate"
+
+// handleEstimate sizes a run request body without submitting it so
+// clients can trim context before spending quota.
+func (s *Server) handleEstimate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	if _, ok := s.requireScope(w, r, auth.ScopeRunsSubmit); !ok {
+		return
+	}
+	var req run.SubmitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+	out, err := s.runSvc.EstimateInput(r.Context(), req)
+	if err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
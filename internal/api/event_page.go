@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"echohelix/internal/apierror"
+	"echohelix/internal/run"
+)
+
+// handleRunEventsPage serves GET /api/v3/runs/{run_id}/events without a
+// WebSocket upgrade: one page of stored events, with the run's event count
+// and highest seq in X-Total-Count and X-Max-Seq.
+func (s *Server) handleRunEventsPage(w http.ResponseWriter, r *http.Request, runID string) {
+	q := r.URL.Query()
+	var req run.EventPageRequest
+	for _, p := range []struct {
+		name string
+		dst  *int64
+	}{{"from_seq", &req.FromSeq}, {"before_seq", &req.BeforeSeq}} {
+		if v := q.Get(p.name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, p.name+" must be a non-negative integer")
+				return
+			}
+			*p.dst = n
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"offset", &req.Offset}, {"limit", &req.Limit}} {
+		if v := q.Get(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, p.name+" must be a non-negative integer")
+				return
+			}
+			*p.dst = n
+		}
+	}
+	switch q.Get("order") {
+	case "", "asc":
+	case "desc":
+		req.Desc = true
+	default:
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "order must be asc or desc")
+		return
+	}
+	page, err := s.runSvc.ListEventsPage(r.Context(), runID, req)
+	if err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.Header().Set("X-Total-Count", strconv.FormatInt(page.Total, 10))
+	w.Header().Set("X-Max-Seq", strconv.FormatInt(page.MaxSeq, 10))
+	writeJSON(w, http.StatusOK, page)
+}
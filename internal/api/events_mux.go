@@ -0,0 +1,250 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"echohelix/internal/apierror"
+	"echohelix/internal/auth"
+	"echohelix/internal/events"
+	"echohelix/internal/session"
+)
+
+const (
+	muxStreamRun     = "run"
+	muxStreamSession = "session"
+)
+
+type muxTarget struct {
+	RunID     string `json:"run_id,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	StreamID  string `json:"stream_id,omitempty"`
+	FromSeq   int64  `json:"from_seq,omitempty"`
+}
+
+type muxControl struct {
+	Subscribe   *muxTarget `json:"subscribe,omitempty"`
+	Unsubscribe *muxTarget `json:"unsubscribe,omitempty"`
+}
+
+type muxFrame struct {
+	Stream   string `json:"stream,omitempty"`
+	ID       string `json:"id,omitempty"`
+	StreamID string `json:"stream_id,omitempty"`
+	Event    any    `json:"event,omitempty"`
+	Ack      string `json:"ack,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func newMuxFrame(stream, id string) muxFrame {
+	return muxFrame{Stream: stream, ID: id, StreamID: streamID(stream, id)}
+}
+
+var errMuxTarget = errors.New("requires exactly one of run_id, session_id or stream_id")
+
+// muxKey resolves a control frame target to its stream kind and ID.
+func (s *Server) muxKey(t muxTarget) (stream, id string, err error) {
+	runID := strings.TrimSpace(t.RunID)
+	sessionID := strings.TrimSpace(t.SessionID)
+	unified := strings.TrimSpace(t.StreamID)
+	switch {
+	case runID != "" && sessionID == "" && unified == "":
+		return muxStreamRun, runID, nil
+	case sessionID != "" && runID == "" && unified == "":
+		return muxStreamSession, sessionID, nil
+	case unified != "" && runID == "" && sessionID == "":
+		stream, id, err := s.resolveStreamID(context.Background(), unified)
+		if err != nil {
+			return "", unified, err
+		}
+		return stream, id, nil
+	default:
+		return "", "", errMuxTarget
+	}
+}
+
+func (s *Server) handleEventsMux(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	if _, ok := s.requireScope(w, r, auth.ScopeRunsRead); !ok {
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	inbox := make(chan []byte)
+	ws := s.newWSStream(conn, inbox)
+	defer ws.close()
+
+	out := make(chan muxFrame, 256)
+	subs := map[string]chan struct{}{}
+	defer func() {
+		for _, stop := range subs {
+			close(stop)
+		}
+	}()
+
+	ticker := time.NewTicker(ws.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ws.closed:
+			return
+		case <-ticker.C:
+			if err := ws.ping(); err != nil {
+				return
+			}
+		case data := <-inbox:
+			for _, frame := range s.applyMuxControl(data, s.actorOf(r), subs, out) {
+				if err := ws.writeJSON(frame); err != nil {
+					return
+				}
+			}
+		case frame := <-out:
+			if err := ws.writeJSON(frame); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) applyMuxControl(data []byte, actor string, subs map[string]chan struct{}, out chan<- muxFrame) []muxFrame {
+	var ctl muxControl
+	if err := json.Unmarshal(data, &ctl); err != nil {
+		return []muxFrame{{Error: "invalid control frame: " + err.Error()}}
+	}
+	if ctl.Subscribe == nil && ctl.Unsubscribe == nil {
+		return []muxFrame{{Error: "control frame requires subscribe or unsubscribe"}}
+	}
+	var replies []muxFrame
+	if t := ctl.Unsubscribe; t != nil {
+		stream, id, err := s.muxKey(*t)
+		switch {
+		case errors.Is(err, errMuxTarget):
+			replies = append(replies, muxFrame{Error: "unsubscribe " + err.Error()})
+		case err != nil:
+			replies = append(replies, muxFrame{ID: id, Error: err.Error()})
+		default:
+			key := streamID(stream, id)
+			if stop, exists := subs[key]; exists {
+				close(stop)
+				delete(subs, key)
+			}
+			frame := newMuxFrame(stream, id)
+			frame.Ack = "unsubscribed"
+			replies = append(replies, frame)
+		}
+	}
+	if t := ctl.Subscribe; t != nil {
+		stream, id, err := s.muxKey(*t)
+		if errors.Is(err, errMuxTarget) {
+			return append(replies, muxFrame{Error: "subscribe " + err.Error()})
+		}
+		if err != nil {
+			return append(replies, muxFrame{ID: id, Error: err.Error()})
+		}
+		key := streamID(stream, id)
+		if stop, exists := subs[key]; exists {
+			close(stop)
+			delete(subs, key)
+		}
+		frame := newMuxFrame(stream, id)
+		stop := make(chan struct{})
+		if err := s.startMuxSubscription(stream, id, actor, t.FromSeq, stop, out); err != nil {
+			frame.Error = err.Error()
+			return append(replies, frame)
+		}
+		subs[key] = stop
+		frame.Ack = "subscribed"
+		replies = append(replies, frame)
+	}
+	return replies
+}
+
+func (s *Server) startMuxSubscription(stream, id, actor string, fromSeq int64, stop chan struct{}, out chan<- muxFrame) error {
+	switch stream {
+	case muxStreamRun:
+		if _, err := s.runSvc.GetRun(context.Background(), id); err != nil {
+			return err
+		}
+		// Subscribe before reading history so no event falls in between.
+		sub, unsub := s.runSvc.Subscribe(id)
+		history, err := s.runSvc.ListEvents(context.Background(), id, fromSeq)
+		if err != nil {
+			unsub()
+			return err
+		}
+		go forwardMux(stream, id, history, sub, unsub, func(ev events.Event) int64 { return ev.Seq }, stop, out)
+	case muxStreamSession:
+		if s.sessionSvc == nil {
+			return errors.New("session service unavailable")
+		}
+		sub, unsub, err := s.sessionSvc.Subscribe(id)
+		if err != nil {
+			return err
+		}
+		history, err := s.sessionSvc.ListEvents(id, fromSeq)
+		if err != nil {
+			unsub()
+			return err
+		}
+		leave, err := s.sessionSvc.Join(id, actor)
+		if err != nil {
+			unsub()
+			return err
+		}
+		stopSession := func() {
+			leave()
+			unsub()
+		}
+		go forwardMux(stream, id, history, sub, stopSession, func(ev session.Event) int64 { return ev.Seq }, stop, out)
+	}
+	return nil
+}
+
+func forwardMux[T any](stream, id string, history []T, sub <-chan T, unsub func(), seqOf func(T) int64, stop <-chan struct{}, out chan<- muxFrame) {
+	defer unsub()
+	lastSeq := int64(0)
+	frame := newMuxFrame(stream, id)
+	send := func(ev T) bool {
+		if seq := seqOf(ev); seq > 0 {
+			if seq <= lastSeq {
+				return true
+			}
+			lastSeq = seq
+		}
+		f := frame
+		f.Event = ev
+		select {
+		case out <- f:
+			return true
+		case <-stop:
+			return false
+		}
+	}
+	for _, ev := range history {
+		if !send(ev) {
+			return
+		}
+	}
+	for {
+		select {
+		case <-stop:
+			return
+		case ev, ok := <-sub:
+			if !ok {
+				return
+			}
+			if !send(ev) {
+				return
+			}
+		}
+	}
+}
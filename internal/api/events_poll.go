@@ -0,0 +1,132 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"echohelix/internal/apierror"
+	"echohelix/internal/events"
+	"echohelix/internal/session"
+)
+
+// maxPollWait caps how long a transport=poll request waits for events.
+const maxPollWait = 30 * time.Second
+
+// isEventPollRequest matches GET .../events?transport=poll, which outlives
+// the handler timeout while it waits.
+func isEventPollRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet && r.URL.Query().Get("transport") == "poll" &&
+		strings.HasSuffix(strings.TrimRight(r.URL.Path, "/"), "/events")
+}
+
+// handleEventsPoll serves run and session events over plain HTTP for
+// clients that cannot hold a WebSocket. It replays from from_seq like the
+// WebSocket path; with wait set and nothing to replay it holds the request
+// until an event arrives or wait passes. Clients poll again from
+// next_from_seq.
+func (s *Server) handleEventsPoll(w http.ResponseWriter, r *http.Request, stream, id string) {
+	q := r.URL.Query()
+	var fromSeq int64
+	if v := q.Get("from_seq"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "from_seq must be a non-negative integer")
+			return
+		}
+		fromSeq = n
+	}
+	var wait time.Duration
+	if v := q.Get("wait"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || time.Duration(n)*time.Second > maxPollWait {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "wait must be between 0 and 30 seconds")
+			return
+		}
+		wait = time.Duration(n) * time.Second
+	}
+
+	switch stream {
+	case muxStreamRun:
+		if _, err := s.runSvc.GetRun(r.Context(), id); err != nil {
+			writeServiceError(w, http.StatusNotFound, err)
+			return
+		}
+		// Subscribe before reading history so no event falls in between.
+		sub, unsub := s.runSvc.Subscribe(id)
+		defer unsub()
+		history, err := s.runSvc.ListEvents(r.Context(), id, fromSeq)
+		if err != nil {
+			writeServiceError(w, http.StatusInternalServerError, err)
+			return
+		}
+		evs := pollEvents(r, history, sub, func(ev events.Event) int64 { return ev.Seq }, fromSeq, wait)
+		writeJSON(w, http.StatusOK, map[string]any{"run_id": id, "events": evs, "next_from_seq": nextFromSeq(evs, fromSeq, func(ev events.Event) int64 { return ev.Seq })})
+	case muxStreamSession:
+		filter := session.ParseEventFilter(q.Get("types"), q.Get("methods"), q.Get("exclude"), q.Get("exclude_methods"))
+		sub, unsub, err := s.sessionSvc.SubscribeFiltered(id, filter)
+		if err != nil {
+			writeServiceError(w, http.StatusNotFound, err)
+			return
+		}
+		defer unsub()
+		history, err := s.sessionSvc.ListEvents(id, fromSeq)
+		if err != nil {
+			writeServiceError(w, http.StatusNotFound, err)
+			return
+		}
+		evs := pollEvents(r, filter.Apply(history), sub, func(ev session.Event) int64 { return ev.Seq }, fromSeq, wait)
+		writeJSON(w, http.StatusOK, map[string]any{"session_id": id, "events": evs, "next_from_seq": nextFromSeq(evs, fromSeq, func(ev session.Event) int64 { return ev.Seq })})
+	}
+}
+
+// pollEvents returns history, or when it is empty waits up to wait for live
+// events and returns the first one with whatever else is already queued.
+func pollEvents[T any](r *http.Request, history []T, sub <-chan T, seqOf func(T) int64, fromSeq int64, wait time.Duration) []T {
+	if len(history) > 0 || wait <= 0 {
+		return history
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	out := []T{}
+	keep := func(ev T) {
+		if seq := seqOf(ev); seq == 0 || seq >= fromSeq {
+			out = append(out, ev)
+		}
+	}
+	for len(out) == 0 {
+		select {
+		case <-r.Context().Done():
+			return out
+		case <-timer.C:
+			return out
+		case ev, ok := <-sub:
+			if !ok {
+				return out
+			}
+			keep(ev)
+		}
+	}
+	for {
+		select {
+		case ev, ok := <-sub:
+			if !ok {
+				return out
+			}
+			keep(ev)
+		default:
+			return out
+		}
+	}
+}
+
+func nextFromSeq[T any](evs []T, fromSeq int64, seqOf func(T) int64) int64 {
+	next := fromSeq
+	for _, ev := range evs {
+		if seq := seqOf(ev); seq >= next {
+			next = seq + 1
+		}
+	}
+	return next
+}
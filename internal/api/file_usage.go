@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"echohelix/internal/apierror"
+	"echohelix/internal/run"
+)
+
+const (
+	defaultFileUsageLimit = 100
+	maxFileUsageLimit     = 1000
+)
+
+// handleFileUsage lists uploads with their run reference counts so orphaned
+// files can be reviewed before cleanup. It spans every uploader, so it is
+// limited to the bootstrap operator.
+func (s *Server) handleFileUsage(w http.ResponseWriter, r *http.Request) {
+	if !s.requireBootstrapOperator(w, r) {
+		return
+	}
+	q := r.URL.Query()
+	query := run.FileUsageQuery{
+		CreatedBy: q.Get("created_by"),
+		Limit:     defaultFileUsageLimit,
+	}
+	if v := q.Get("orphaned"); v != "" {
+		orphaned, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "orphaned must be a boolean")
+			return
+		}
+		query.OrphanedOnly = orphaned
+	}
+	if v := q.Get("older_than_seconds"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "invalid older_than_seconds")
+			return
+		}
+		query.MinAge = time.Duration(n) * time.Second
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxFileUsageLimit {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "limit must be between 1 and 1000")
+			return
+		}
+		query.Limit = n
+	}
+	report, err := s.runSvc.ListFileUsage(r.Context(), query)
+	if err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+
+	"echohelix/internal/apierror"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// applyIdempotencyKey copies the Idempotency-Key header into the body's
+// client_request_id. Both may be sent only if they agree.
+func applyIdempotencyKey(w http.ResponseWriter, r *http.Request, clientRequestID *string) bool {
+	key := r.Header.Get(idempotencyKeyHeader)
+	if key == "" {
+		return true
+	}
+	if *clientRequestID != "" && *clientRequestID != key {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Idempotency-Key header and client_request_id differ")
+		return false
+	}
+	*clientRequestID = key
+	return true
+}
+
+func markReplayed(w http.ResponseWriter, replayed bool) {
+	if replayed {
+		w.Header().Set(idempotentReplayedHeader, "true")
+	}
+}
@@ -0,0 +1,566 @@
+package api
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// renderMarkdownHTML converts agent markdown to HTML for clients without a
+// markdown engine. It covers the subset agents emit (headings, paragraphs,
+// lists, block quotes, tables, rules, fenced code and inline emphasis, code
+// and links). Raw HTML in the source is escaped, never passed through, and
+// only http, https and mailto links are kept, so the output is safe to load
+// into a webview.
+func renderMarkdownHTML(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(src, "\n"), 0)
+	return b.String()
+}
+
+var (
+	mdHeading    = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdRule       = regexp.MustCompile(`^(\*\s*){3,}$|^(-\s*){3,}$|^(_\s*){3,}$`)
+	mdBullet     = regexp.MustCompile(`^(\s*)([-*+])\s+(.*)$`)
+	mdOrdered    = regexp.MustCompile(`^(\s*)(\d{1,9})[.)]\s+(.*)$`)
+	mdTableDelim = regexp.MustCompile(`^\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?$`)
+	mdLangClass  = regexp.MustCompile(`^[A-Za-z0-9_+#.-]{1,32}$`)
+)
+
+// maxBlockDepth bounds block quote and list nesting. Each level rescans its
+// lines, so deeper markers are rendered as text to keep the work linear.
+const maxBlockDepth = 32
+
+func renderBlocks(b *strings.Builder, lines []string, depth int) {
+	for i := 0; i < len(lines); {
+		trimmed := strings.TrimSpace(lines[i])
+		switch {
+		case trimmed == "":
+			i++
+		case fenceMarker(trimmed) != "":
+			i = renderFence(b, lines, i)
+		case mdHeading.MatchString(trimmed):
+			m := mdHeading.FindStringSubmatch(trimmed)
+			level := strconv.Itoa(len(m[1]))
+			b.WriteString("<h" + level + ">" + renderInline(m[2]) + "</h" + level + ">\n")
+			i++
+		case mdRule.MatchString(trimmed):
+			b.WriteString("<hr>\n")
+			i++
+		case depth < maxBlockDepth && strings.HasPrefix(trimmed, ">"):
+			var inner []string
+			for i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">") {
+				line := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				inner = append(inner, strings.TrimPrefix(line, " "))
+				i++
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, inner, depth+1)
+			b.WriteString("</blockquote>\n")
+		case depth < maxBlockDepth && listItem(lines[i]) != nil:
+			i = renderList(b, lines, i, depth)
+		case i+1 < len(lines) && strings.Contains(trimmed, "|") && mdTableDelim.MatchString(strings.TrimSpace(lines[i+1])):
+			i = renderTable(b, lines, i)
+		default:
+			var para []string
+			for i < len(lines) && !startsBlock(lines, i, depth) {
+				para = append(para, strings.TrimSpace(lines[i]))
+				i++
+			}
+			b.WriteString("<p>" + renderInline(strings.Join(para, "\n")) + "</p>\n")
+		}
+	}
+}
+
+// startsBlock reports whether line i ends a running paragraph.
+func startsBlock(lines []string, i, depth int) bool {
+	trimmed := strings.TrimSpace(lines[i])
+	return trimmed == "" ||
+		fenceMarker(trimmed) != "" ||
+		mdHeading.MatchString(trimmed) ||
+		mdRule.MatchString(trimmed) ||
+		depth < maxBlockDepth && (strings.HasPrefix(trimmed, ">") || listItem(lines[i]) != nil)
+}
+
+func fenceMarker(trimmed string) string {
+	for _, marker := range []string{"```", "~~~"} {
+		if strings.HasPrefix(trimmed, marker) {
+			n := len(trimmed) - len(strings.TrimLeft(trimmed, marker[:1]))
+			return strings.Repeat(marker[:1], n)
+		}
+	}
+	return ""
+}
+
+func renderFence(b *strings.Builder, lines []string, i int) int {
+	open := strings.TrimSpace(lines[i])
+	marker := fenceMarker(open)
+	lang := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(open, marker)))
+	if fields := strings.Fields(lang); len(fields) > 0 {
+		lang = fields[0]
+	}
+	var body []string
+	j := i + 1
+	for ; j < len(lines); j++ {
+		if strings.HasPrefix(strings.TrimSpace(lines[j]), marker) && strings.Trim(strings.TrimSpace(lines[j]), marker[:1]) == "" {
+			break
+		}
+		body = append(body, lines[j])
+	}
+	code := strings.Join(body, "\n")
+	class := ""
+	if mdLangClass.MatchString(lang) {
+		class = ` class="language-` + html.EscapeString(lang) + `"`
+	}
+	b.WriteString("<pre><code" + class + ">" + highlightCode(lang, code) + "</code></pre>\n")
+	return j + 1
+}
+
+type mdListItem struct {
+	indent  int
+	ordered bool
+	start   string
+	text    string
+}
+
+func listItem(line string) *mdListItem {
+	if m := mdBullet.FindStringSubmatch(line); m != nil && !mdRule.MatchString(strings.TrimSpace(line)) {
+		return &mdListItem{indent: len(m[1]), text: m[3]}
+	}
+	if m := mdOrdered.FindStringSubmatch(line); m != nil {
+		return &mdListItem{indent: len(m[1]), ordered: true, start: m[2], text: m[3]}
+	}
+	return nil
+}
+
+// renderList renders consecutive items of the same kind. Lines indented past
+// an item's marker belong to that item and are rendered as nested blocks.
+func renderList(b *strings.Builder, lines []string, i, depth int) int {
+	first := listItem(lines[i])
+	tag := "ul"
+	open := "<ul>\n"
+	if first.ordered {
+		tag = "ol"
+		open = "<ol>\n"
+		if strings.TrimLeft(first.start, "0") != "1" {
+			open = `<ol start="` + html.EscapeString(strings.TrimLeft(first.start, "0")) + `">` + "\n"
+		}
+	}
+	b.WriteString(open)
+	for i < len(lines) {
+		item := listItem(lines[i])
+		if item == nil || item.ordered != first.ordered || item.indent != first.indent {
+			break
+		}
+		i++
+		var nested []string
+		for i < len(lines) {
+			line := lines[i]
+			if strings.TrimSpace(line) == "" {
+				if i+1 < len(lines) && indentOf(lines[i+1]) > first.indent {
+					nested = append(nested, "")
+					i++
+					continue
+				}
+				break
+			}
+			if indentOf(line) <= first.indent {
+				break
+			}
+			nested = append(nested, dedent(line, first.indent+2))
+			i++
+		}
+		b.WriteString("<li>" + renderInline(item.text))
+		if len(nested) > 0 {
+			b.WriteString("\n")
+			renderBlocks(b, nested, depth+1)
+		}
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+func dedent(line string, n int) string {
+	for n > 0 && len(line) > 0 && (line[0] == ' ' || line[0] == '\t') {
+		line = line[1:]
+		n--
+	}
+	return line
+}
+
+func renderTable(b *strings.Builder, lines []string, i int) int {
+	header := splitTableRow(lines[i])
+	aligns := make([]string, len(header))
+	for k, cell := range splitTableRow(lines[i+1]) {
+		if k >= len(aligns) {
+			break
+		}
+		left, right := strings.HasPrefix(cell, ":"), strings.HasSuffix(cell, ":")
+		switch {
+		case left && right:
+			aligns[k] = ` style="text-align:center"`
+		case right:
+			aligns[k] = ` style="text-align:right"`
+		case left:
+			aligns[k] = ` style="text-align:left"`
+		}
+	}
+	b.WriteString("<table>\n<thead><tr>")
+	for k, cell := range header {
+		b.WriteString("<th" + aligns[k] + ">" + renderInline(cell) + "</th>")
+	}
+	b.WriteString("</tr></thead>\n<tbody>\n")
+	i += 2
+	for i < len(lines) && strings.Contains(lines[i], "|") && strings.TrimSpace(lines[i]) != "" {
+		row := splitTableRow(lines[i])
+		b.WriteString("<tr>")
+		for k := range header {
+			cell := ""
+			if k < len(row) {
+				cell = row[k]
+			}
+			b.WriteString("<td" + aligns[k] + ">" + renderInline(cell) + "</td>")
+		}
+		b.WriteString("</tr>\n")
+		i++
+	}
+	b.WriteString("</tbody>\n</table>\n")
+	return i
+}
+
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+	var cells []string
+	var cur strings.Builder
+	for k := 0; k < len(line); k++ {
+		switch {
+		case line[k] == '\\' && k+1 < len(line) && line[k+1] == '|':
+			cur.WriteByte('|')
+			k++
+		case line[k] == '|':
+			cells = append(cells, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteByte(line[k])
+		}
+	}
+	return append(cells, strings.TrimSpace(cur.String()))
+}
+
+const mdEscapable = "\\`*_{}[]()#+-.!|~>"
+
+// renderInline renders emphasis, code spans and links. A closing
+// delimiter that is missing from some offset on is missing from every later
+// offset too, so each failed search is remembered and unbalanced input
+// stays linear instead of rescanning the rest of the text per opener.
+func renderInline(text string) string {
+	var b strings.Builder
+	unmatched := map[string]int{}
+	missing := func(delim string, i int) bool {
+		from, ok := unmatched[delim]
+		return ok && i >= from
+	}
+	wrap := func(i int, delim, tag string) (string, int, bool) {
+		if missing(delim, i) || !canOpen(text[i+len(delim):]) {
+			return "", 0, false
+		}
+		out, n, ok := wrapInline(text[i:], delim, tag)
+		if !ok {
+			unmatched[delim] = i
+		}
+		return out, n, ok
+	}
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text) && strings.IndexByte(mdEscapable, text[i+1]) >= 0:
+			b.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+			continue
+		case c == '\n':
+			b.WriteString("\n")
+			i++
+			continue
+		case c == '`':
+			n := len(text[i:]) - len(strings.TrimLeft(text[i:], "`"))
+			ticks := text[i : i+n]
+			if !missing(ticks, i) {
+				if end := strings.Index(text[i+n:], ticks); end >= 0 {
+					code := strings.TrimSpace(text[i+n : i+n+end])
+					b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+					i += n + end + n
+					continue
+				}
+				unmatched[ticks] = i
+			}
+			b.WriteString(ticks)
+			i += n
+			continue
+		case c == '[':
+			if label, dest, n, ok := parseLink(text[i:]); ok {
+				if href, safe := safeHref(dest); safe {
+					b.WriteString(`<a href="` + html.EscapeString(href) + `" rel="noopener noreferrer nofollow" target="_blank">` + renderInline(label) + "</a>")
+				} else {
+					b.WriteString(renderInline(label))
+				}
+				i += n
+				continue
+			}
+		case strings.HasPrefix(text[i:], "**") || strings.HasPrefix(text[i:], "__"):
+			if out, n, ok := wrap(i, text[i:i+2], "strong"); ok {
+				b.WriteString(out)
+				i += n
+				continue
+			}
+		case strings.HasPrefix(text[i:], "~~"):
+			if out, n, ok := wrap(i, "~~", "del"); ok {
+				b.WriteString(out)
+				i += n
+				continue
+			}
+		case c == '*' || (c == '_' && (i == 0 || !isWordByte(text[i-1]))):
+			if out, n, ok := wrap(i, text[i:i+1], "em"); ok {
+				b.WriteString(out)
+				i += n
+				continue
+			}
+		}
+		b.WriteString(html.EscapeString(text[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+// canOpen reports whether a delimiter followed by rest can open emphasis.
+func canOpen(rest string) bool {
+	return rest != "" && rest[0] != ' ' && rest[0] != '\n'
+}
+
+// wrapInline renders text starting with delim up to the matching closing
+// delim inside tag.
+func wrapInline(text, delim, tag string) (string, int, bool) {
+	rest := text[len(delim):]
+	if !canOpen(rest) {
+		return "", 0, false
+	}
+	for k := 1; k+len(delim) <= len(rest); k++ {
+		if rest[k:k+len(delim)] != delim || rest[k-1] == ' ' || rest[k-1] == '\\' {
+			continue
+		}
+		after := k + len(delim)
+		if delim == "_" && after < len(rest) && isWordByte(rest[after]) {
+			continue
+		}
+		if len(delim) == 1 && after < len(rest) && rest[after] == delim[0] {
+			k++
+			continue
+		}
+		return "<" + tag + ">" + renderInline(rest[:k]) + "</" + tag + ">", len(delim) + after, true
+	}
+	return "", 0, false
+}
+
+// parseLink reads [label](destination). Labels cannot nest brackets and
+// neither part spans lines, and the scan stops at the next '[': every
+// opener scans only up to the following one, which keeps a line of
+// unclosed brackets linear.
+func parseLink(text string) (label, dest string, n int, ok bool) {
+	for k := 1; k < len(text); k++ {
+		switch text[k] {
+		case '[', '\n':
+			return "", "", 0, false
+		case ']':
+			if k+1 >= len(text) || text[k+1] != '(' {
+				return "", "", 0, false
+			}
+			end := closingParen(text[k+2:])
+			if end < 0 {
+				return "", "", 0, false
+			}
+			dest = strings.TrimSpace(text[k+2 : k+2+end])
+			if fields := strings.Fields(dest); len(fields) > 0 {
+				dest = strings.Trim(fields[0], "<>")
+			}
+			return text[1:k], dest, k + 3 + end, true
+		}
+	}
+	return "", "", 0, false
+}
+
+// closingParen finds the parenthesis closing a link destination, allowing
+// balanced parentheses inside it. It gives up at a newline or '[', where
+// the next link would start.
+func closingParen(text string) int {
+	depth := 0
+	for k := 0; k < len(text); k++ {
+		switch text[k] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return k
+			}
+			depth--
+		case '\n', '[':
+			return -1
+		}
+	}
+	return -1
+}
+
+func safeHref(dest string) (string, bool) {
+	lower := strings.ToLower(dest)
+	for _, scheme := range []string{"https://", "http://", "mailto:"} {
+		if strings.HasPrefix(lower, scheme) {
+			return dest, true
+		}
+	}
+	return "", false
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// Code highlighting is lexical only: comments, strings, numbers and a shared
+// keyword list, wrapped in tok-* spans. Diffs get one span per line.
+
+var codeKeywords = map[string]struct{}{}
+
+func init() {
+	for _, kw := range strings.Fields(`
+		break case catch class const continue def default defer del do elif else
+		enum except export extends false finally fn for from func function go if
+		impl import in interface let match mod nil none null package pass pub
+		raise return select self static struct super switch this throw true try
+		type typeof use var void while with yield async await lambda new
+		True False None and or not is mut loop where trait then fi done esac
+	`) {
+		codeKeywords[kw] = struct{}{}
+	}
+}
+
+var hashCommentLangs = map[string]bool{
+	"sh": true, "bash": true, "shell": true, "zsh": true, "console": true,
+	"py": true, "python": true, "rb": true, "ruby": true,
+	"yaml": true, "yml": true, "toml": true, "dockerfile": true, "makefile": true,
+}
+
+var dashCommentLangs = map[string]bool{"sql": true, "lua": true, "haskell": true}
+
+func highlightCode(lang, code string) string {
+	switch lang {
+	case "diff", "patch":
+		return highlightDiff(code)
+	case "", "text", "plain", "plaintext", "txt", "markdown", "md":
+		return html.EscapeString(code)
+	}
+	lineComment := "//"
+	switch {
+	case hashCommentLangs[lang]:
+		lineComment = "#"
+	case dashCommentLangs[lang]:
+		lineComment = "--"
+	}
+	var b strings.Builder
+	span := func(class, text string) {
+		b.WriteString(`<span class="tok-` + class + `">` + html.EscapeString(text) + "</span>")
+	}
+	for i := 0; i < len(code); {
+		c := code[i]
+		switch {
+		case strings.HasPrefix(code[i:], lineComment):
+			end := strings.IndexByte(code[i:], '\n')
+			if end < 0 {
+				end = len(code) - i
+			}
+			span("com", code[i:i+end])
+			i += end
+		case lineComment == "//" && strings.HasPrefix(code[i:], "/*"):
+			end := strings.Index(code[i+2:], "*/")
+			if end < 0 {
+				end = len(code) - i
+			} else {
+				end += 4
+			}
+			span("com", code[i:i+end])
+			i += end
+		case c == '"' || c == '\'' || c == '`':
+			end := i + 1
+			for end < len(code) && code[end] != c {
+				if code[end] == '\\' && end+1 < len(code) {
+					end += 2
+					continue
+				}
+				if code[end] == '\n' && c != '`' {
+					break
+				}
+				end++
+			}
+			if end < len(code) && code[end] == c {
+				end++
+			}
+			span("str", code[i:end])
+			i = end
+		case c >= '0' && c <= '9' && (i == 0 || !isWordByte(code[i-1])):
+			end := i
+			for end < len(code) && (isWordByte(code[end]) || code[end] == '.') {
+				end++
+			}
+			span("num", code[i:end])
+			i = end
+		case isWordByte(c) && c < 0x80:
+			end := i
+			for end < len(code) && isWordByte(code[end]) {
+				end++
+			}
+			word := code[i:end]
+			if _, ok := codeKeywords[word]; ok {
+				span("kw", word)
+			} else {
+				b.WriteString(html.EscapeString(word))
+			}
+			i = end
+		default:
+			b.WriteString(html.EscapeString(code[i : i+1]))
+			i++
+		}
+	}
+	return b.String()
+}
+
+func highlightDiff(code string) string {
+	var b strings.Builder
+	for i, line := range strings.Split(code, "\n") {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		class := ""
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"),
+			strings.HasPrefix(line, "diff "), strings.HasPrefix(line, "index "):
+			class = "diff-meta"
+		case strings.HasPrefix(line, "@@"):
+			class = "diff-hunk"
+		case strings.HasPrefix(line, "+"):
+			class = "diff-add"
+		case strings.HasPrefix(line, "-"):
+			class = "diff-del"
+		}
+		if class == "" {
+			b.WriteString(html.EscapeString(line))
+			continue
+		}
+		b.WriteString(`<span class="` + class + `">` + html.EscapeString(line) + "</span>")
+	}
+	return b.String()
+}
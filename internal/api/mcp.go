@@ -0,0 +1,534 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"echohelix/internal/apierror"
+	"echohelix/internal/auth"
+	"echohelix/internal/run"
+	"echohelix/internal/session"
+
+	"github.com/google/uuid"
+)
+
+// The MCP server exposes bridge operations as Model Context Protocol tools
+// and resources, so other agent hosts can drive the bridge. It runs over
+// stdio (ServeMCP) or over the SSE transport below. Every call is checked
+// against the scopes of the caller's token, like the REST API.
+const (
+	mcpSSEPath         = "/api/v3/mcp/sse"
+	mcpMessagesPath    = "/api/v3/mcp/messages"
+	mcpProtocolVersion = "2024-11-05"
+	mcpRunURIPrefix    = "elix://runs/"
+	mcpFileURIPrefix   = "elix://workspaces/"
+)
+
+const (
+	mcpParseError     = -32700
+	mcpInvalidRequest = -32600
+	mcpMethodNotFound = -32601
+	mcpInvalidParams  = -32602
+	mcpInternalError  = -32603
+	mcpNotFound       = -32002
+)
+
+type mcpRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type mcpResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *mcpError       `json:"error,omitempty"`
+}
+
+type mcpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+
+	scope    string
+	mutating bool
+	session  bool // needs the session service
+	call     func(s *Server, r *http.Request, args map[string]any) (any, error)
+}
+
+func mcpObjectSchema(required []string, props map[string]any) map[string]any {
+	schema := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func mcpString(desc string) map[string]any {
+	return map[string]any{"type": "string", "description": desc}
+}
+
+var mcpTools = []mcpTool{
+	{
+		Name:        "submit_run",
+		Description: "Submit a run to a backend in an allowed workspace. Takes the POST /api/v3/runs body.",
+		InputSchema: mcpObjectSchema([]string{"workspace_path", "backend", "prompt"}, map[string]any{
+			"workspace_id":   mcpString("Stable workspace id."),
+			"workspace_path": mcpString("Absolute workspace path inside WORKSPACE_ROOTS."),
+			"backend":        mcpString("codex, claude or gemini."),
+			"prompt":         mcpString("The task for the agent."),
+			"options":        map[string]any{"type": "object", "description": "Run options: model, profile, sandbox, schema_version."},
+		}),
+		scope:    auth.ScopeRunsSubmit,
+		mutating: true,
+		call: func(s *Server, r *http.Request, args map[string]any) (any, error) {
+			var req run.SubmitRequest
+			if err := decodeMCPArgs(args, &req); err != nil {
+				return nil, err
+			}
+			principal, _ := s.principalFromContext(r.Context())
+			req.SubmittedBy = principal.Address
+			obj, err := s.runSvc.Submit(r.Context(), req)
+			if err != nil {
+				return nil, err
+			}
+			s.auditf(r, "mcp_run_submitted", "run_id="+obj.ID)
+			return submitResponse(obj), nil
+		},
+	},
+	{
+		Name:        "list_runs",
+		Description: "List the newest runs, optionally filtered by status or workspace.",
+		InputSchema: mcpObjectSchema(nil, map[string]any{
+			"status":       mcpString("Only runs in this status."),
+			"workspace_id": mcpString("Only runs of this workspace."),
+			"limit":        map[string]any{"type": "integer", "minimum": 1, "maximum": 100},
+		}),
+		scope: auth.ScopeRunsRead,
+		call: func(s *Server, r *http.Request, args map[string]any) (any, error) {
+			var q struct {
+				Status      string `json:"status"`
+				WorkspaceID string `json:"workspace_id"`
+				Limit       int    `json:"limit"`
+			}
+			if err := decodeMCPArgs(args, &q); err != nil {
+				return nil, err
+			}
+			items, err := s.runSvc.ListRuns(r.Context(), run.RunQuery{Status: q.Status, WorkspaceID: q.WorkspaceID, Limit: q.Limit})
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{"items": items}, nil
+		},
+	},
+	{
+		Name:        "get_run",
+		Description: "Get a run's status, usage and terminal info.",
+		InputSchema: mcpObjectSchema([]string{"run_id"}, map[string]any{"run_id": mcpString("Run id.")}),
+		scope:       auth.ScopeRunsRead,
+		call: func(s *Server, r *http.Request, args map[string]any) (any, error) {
+			return s.runSvc.GetRun(r.Context(), mcpArg(args, "run_id"))
+		},
+	},
+	{
+		Name:        "read_workspace_file",
+		Description: "Read a file of a workspace by its relative path. Content over 1 MiB is truncated.",
+		InputSchema: mcpObjectSchema([]string{"workspace_id", "path"}, map[string]any{
+			"workspace_id": mcpString("Workspace id of an earlier run."),
+			"path":         mcpString("Path relative to the workspace root."),
+		}),
+		scope: auth.ScopeRunsRead,
+		call: func(s *Server, r *http.Request, args map[string]any) (any, error) {
+			return s.runSvc.ReadWorkspaceFile(r.Context(), mcpArg(args, "workspace_id"), mcpArg(args, "path"))
+		},
+	},
+	{
+		Name:        "list_approvals",
+		Description: "List the pending and resolved approval requests of a session.",
+		InputSchema: mcpObjectSchema([]string{"session_id"}, map[string]any{"session_id": mcpString("Session id.")}),
+		scope:       auth.ScopeRunsRead,
+		session:     true,
+		call: func(s *Server, r *http.Request, args map[string]any) (any, error) {
+			items, err := s.sessionSvc.ListApprovals(mcpArg(args, "session_id"))
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{"items": items}, nil
+		},
+	},
+	{
+		Name:        "resolve_approval",
+		Description: "Accept or decline a session approval request.",
+		InputSchema: mcpObjectSchema([]string{"session_id", "request_id", "decision"}, map[string]any{
+			"session_id":  mcpString("Session id."),
+			"request_id":  mcpString("Approval request id."),
+			"decision":    map[string]any{"type": "string", "enum": []string{"accept", "decline"}},
+			"for_session": map[string]any{"type": "boolean", "description": "Accept similar requests for the rest of the session."},
+		}),
+		scope:    auth.ScopeRunsCancel,
+		mutating: true,
+		session:  true,
+		call: func(s *Server, r *http.Request, args map[string]any) (any, error) {
+			sessionID, requestID := mcpArg(args, "session_id"), mcpArg(args, "request_id")
+			forSession, _ := args["for_session"].(bool)
+			decision := session.ApprovalDecision{Decision: mcpArg(args, "decision"), ForSession: forSession}
+			ctx := session.WithActor(r.Context(), s.actorOf(r))
+			if err := s.sessionSvc.ResolveApproval(ctx, sessionID, requestID, decision); err != nil {
+				return nil, err
+			}
+			s.auditf(r, "session_approval_resolved", "session_id="+sessionID+" request_id="+requestID+" decision="+decision.Decision)
+			return map[string]any{"session_id": sessionID, "request_id": requestID, "resolved": true}, nil
+		},
+	},
+}
+
+func decodeMCPArgs(args map[string]any, out any) error {
+	raw, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+func mcpArg(args map[string]any, key string) string {
+	v, _ := args[key].(string)
+	return strings.TrimSpace(v)
+}
+
+func mcpAllowed(principal auth.Principal, scope string) bool {
+	return principal.Admin || principal.HasScope(scope)
+}
+
+// handleMCPMessage answers one JSON-RPC message from an MCP client. It
+// returns nil for notifications. r carries the caller's principal.
+func (s *Server) handleMCPMessage(r *http.Request, raw []byte) *mcpResponse {
+	var req mcpRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return &mcpResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &mcpError{Code: mcpParseError, Message: "parse error"}}
+	}
+	if len(req.ID) == 0 {
+		return nil
+	}
+	resp := &mcpResponse{JSONRPC: "2.0", ID: req.ID}
+	if req.Method == "" {
+		resp.Error = &mcpError{Code: mcpInvalidRequest, Message: "method is required"}
+		return resp
+	}
+	result, rpcErr := s.mcpDispatch(r, req.Method, req.Params)
+	if rpcErr != nil {
+		resp.Error = rpcErr
+	} else {
+		resp.Result = result
+	}
+	return resp
+}
+
+func (s *Server) mcpDispatch(r *http.Request, method string, params json.RawMessage) (any, *mcpError) {
+	principal, _ := s.principalFromContext(r.Context())
+	switch method {
+	case "initialize":
+		return map[string]any{
+			"protocolVersion": mcpProtocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{}, "resources": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "elix-bridge", "version": "v3"},
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		tools := make([]mcpTool, 0, len(mcpTools))
+		for _, tool := range mcpTools {
+			if mcpAllowed(principal, tool.scope) {
+				tools = append(tools, tool)
+			}
+		}
+		return map[string]any{"tools": tools}, nil
+	case "tools/call":
+		var in struct {
+			Name      string         `json:"name"`
+			Arguments map[string]any `json:"arguments"`
+		}
+		if err := json.Unmarshal(params, &in); err != nil {
+			return nil, &mcpError{Code: mcpInvalidParams, Message: "invalid params"}
+		}
+		for _, tool := range mcpTools {
+			if tool.Name == in.Name {
+				return s.callMCPTool(r, principal, tool, in.Arguments), nil
+			}
+		}
+		return nil, &mcpError{Code: mcpInvalidParams, Message: "unknown tool: " + in.Name}
+	case "resources/list":
+		resources := []map[string]any{}
+		if mcpAllowed(principal, auth.ScopeRunsRead) {
+			runs, err := s.runSvc.ListRuns(r.Context(), run.RunQuery{})
+			if err != nil {
+				return nil, &mcpError{Code: mcpInternalError, Message: err.Error()}
+			}
+			for _, item := range runs {
+				resources = append(resources, map[string]any{
+					"uri":         mcpRunURIPrefix + item.ID,
+					"name":        "run " + item.ID,
+					"description": item.Backend + " run, " + item.Status,
+					"mimeType":    "application/json",
+				})
+			}
+		}
+		return map[string]any{"resources": resources}, nil
+	case "resources/templates/list":
+		return map[string]any{"resourceTemplates": []map[string]any{
+			{"uriTemplate": mcpRunURIPrefix + "{run_id}", "name": "run", "mimeType": "application/json"},
+			{"uriTemplate": mcpFileURIPrefix + "{workspace_id}/files/{path}", "name": "workspace file"},
+		}}, nil
+	case "resources/read":
+		var in struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(params, &in); err != nil || in.URI == "" {
+			return nil, &mcpError{Code: mcpInvalidParams, Message: "uri is required"}
+		}
+		return s.readMCPResource(r, principal, in.URI)
+	default:
+		return nil, &mcpError{Code: mcpMethodNotFound, Message: "method not found: " + method}
+	}
+}
+
+// callMCPTool runs tool and reports failures as a tool error result with
+// the REST error body, so the model can see why the call failed.
+func (s *Server) callMCPTool(r *http.Request, principal auth.Principal, tool mcpTool, args map[string]any) map[string]any {
+	var (
+		obj any
+		err error
+	)
+	switch {
+	case !mcpAllowed(principal, tool.scope):
+		err = apierror.New(http.StatusForbidden, apierror.CodeForbidden, "missing scope: "+tool.scope)
+	case tool.session && s.sessionSvc == nil:
+		err = apierror.New(http.StatusServiceUnavailable, apierror.CodeUnavailable, "session service unavailable")
+	case tool.mutating && s.readOnly.get().Active:
+		state := s.readOnly.get()
+		err = apierror.New(http.StatusServiceUnavailable, apierror.CodeReadOnly, "bridge is in read-only mode").With("reason", state.Reason)
+	default:
+		obj, err = tool.call(s, r, args)
+	}
+	isError := err != nil
+	if isError {
+		obj = apierror.From(err, http.StatusBadRequest).Body()
+	}
+	text, _ := json.Marshal(obj)
+	return map[string]any{
+		"content": []map[string]any{{"type": "text", "text": string(text)}},
+		"isError": isError,
+	}
+}
+
+func (s *Server) readMCPResource(r *http.Request, principal auth.Principal, uri string) (any, *mcpError) {
+	if !mcpAllowed(principal, auth.ScopeRunsRead) {
+		return nil, &mcpError{Code: mcpInternalError, Message: "missing scope: " + auth.ScopeRunsRead}
+	}
+	var (
+		obj any
+		err error
+	)
+	switch {
+	case strings.HasPrefix(uri, mcpRunURIPrefix):
+		obj, err = s.runSvc.GetRun(r.Context(), strings.TrimPrefix(uri, mcpRunURIPrefix))
+	case strings.HasPrefix(uri, mcpFileURIPrefix):
+		workspaceID, rel, ok := strings.Cut(strings.TrimPrefix(uri, mcpFileURIPrefix), "/files/")
+		if !ok {
+			return nil, &mcpError{Code: mcpInvalidParams, Message: "unknown resource: " + uri}
+		}
+		if unescaped, uerr := url.PathUnescape(rel); uerr == nil {
+			rel = unescaped
+		}
+		var file run.WorkspaceFile
+		file, err = s.runSvc.ReadWorkspaceFile(r.Context(), workspaceID, rel)
+		if err == nil {
+			content := map[string]any{"uri": uri, "mimeType": "text/plain", "text": file.Content}
+			if file.Encoding == "base64" {
+				content = map[string]any{"uri": uri, "mimeType": "application/octet-stream", "blob": file.Content}
+			}
+			return map[string]any{"contents": []map[string]any{content}}, nil
+		}
+	default:
+		return nil, &mcpError{Code: mcpInvalidParams, Message: "unknown resource: " + uri}
+	}
+	if err != nil {
+		apiErr := apierror.From(err, http.StatusInternalServerError)
+		if apiErr.Status == http.StatusNotFound {
+			return nil, &mcpError{Code: mcpNotFound, Message: apiErr.Message}
+		}
+		return nil, &mcpError{Code: mcpInternalError, Message: apiErr.Message}
+	}
+	text, _ := json.Marshal(obj)
+	return map[string]any{"contents": []map[string]any{{"uri": uri, "mimeType": "application/json", "text": string(text)}}}, nil
+}
+
+// ServeMCP runs the MCP server over newline-delimited JSON-RPC on in and
+// out until in is closed or ctx is done. token authenticates every call,
+// as a bearer token would on the REST API.
+func (s *Server) ServeMCP(ctx context.Context, in io.Reader, out io.Writer, token string) error {
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, "stdio:"+mcpMessagesPath, nil)
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+	principal, err := s.authenticate(r)
+	if err != nil {
+		return err
+	}
+	r = r.WithContext(context.WithValue(ctx, principalContextKey{}, principal))
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	enc := json.NewEncoder(out)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if resp := s.handleMCPMessage(r, []byte(line)); resp != nil {
+			if err := enc.Encode(resp); err != nil {
+				return err
+			}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return scanner.Err()
+}
+
+// mcpStreams tracks open SSE transports by their session id.
+type mcpStreams struct {
+	mu      sync.Mutex
+	streams map[string]*mcpStream
+}
+
+type mcpStream struct {
+	principal auth.Principal
+	out       chan *mcpResponse
+}
+
+func (m *mcpStreams) open(principal auth.Principal) (string, *mcpStream) {
+	id := uuid.NewString()
+	st := &mcpStream{principal: principal, out: make(chan *mcpResponse, 32)}
+	m.mu.Lock()
+	if m.streams == nil {
+		m.streams = map[string]*mcpStream{}
+	}
+	m.streams[id] = st
+	m.mu.Unlock()
+	return id, st
+}
+
+func (m *mcpStreams) get(id string) (*mcpStream, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, ok := m.streams[id]
+	return st, ok
+}
+
+func (m *mcpStreams) close(id string) {
+	m.mu.Lock()
+	delete(m.streams, id)
+	m.mu.Unlock()
+}
+
+// handleMCPSSE opens the SSE transport: the first "endpoint" event names
+// the URL to POST messages to, and responses arrive as "message" events.
+func (s *Server) handleMCPSSE(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	principal, ok := s.principalFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+		return
+	}
+	id, stream := s.mcpStreams.open(principal)
+	defer s.mcpStreams.close(id)
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	write := func(event, data string) bool {
+		_ = rc.SetWriteDeadline(time.Now().Add(s.security.WSWriteTimeout))
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+	if !write("endpoint", mcpMessagesPath+"?session_id="+id) {
+		return
+	}
+
+	ticker := time.NewTicker(s.security.WSPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			_ = rc.SetWriteDeadline(time.Now().Add(s.security.WSWriteTimeout))
+			if _, err := w.Write([]byte(": keepalive\n\n")); err != nil || rc.Flush() != nil {
+				return
+			}
+		case resp := <-stream.out:
+			data, err := json.Marshal(resp)
+			if err != nil || !write("message", string(data)) {
+				return
+			}
+		}
+	}
+}
+
+// handleMCPMessages accepts one client message for an open SSE transport
+// of the same principal and queues the response on that stream.
+func (s *Server) handleMCPMessages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	principal, ok := s.principalFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+		return
+	}
+	stream, ok := s.mcpStreams.get(r.URL.Query().Get("session_id"))
+	if !ok || stream.principal.Address != principal.Address || stream.principal.AuthType != principal.AuthType {
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "mcp session not found")
+		return
+	}
+	raw, err := io.ReadAll(io.LimitReader(r.Body, 4*1024*1024))
+	if err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+	resp := s.handleMCPMessage(r, raw)
+	if resp != nil {
+		select {
+		case stream.out <- resp:
+		default:
+			writeAPIError(w, apierror.New(http.StatusServiceUnavailable, apierror.CodeUnavailable, "mcp stream is not draining"))
+			return
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
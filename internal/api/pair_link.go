@@ -0,0 +1,121 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"echohelix/internal/apierror"
+	"echohelix/internal/auth"
+)
+
+const pairLinkPrefix = "/pair/"
+
+var errPairLinkInvalid = errors.New("pair link invalid or expired")
+
+var pairLinkPage = template.Must(template.New("pair").Parse(`<!doctype html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Pair with EchoHelix</title></head>
+<body>
+<p>Pair code <strong>{{.PairCode}}</strong> (expires {{.ExpiresAt}})</p>
+<p>Bridge fingerprint <code>{{.Fingerprint}}</code></p>
+<p><a href="{{.URI}}">Open in Elix</a></p>
+</body></html>
+`))
+
+// pairLinkToken encodes the pair code and expiry with an HMAC so the link can
+// be served without extra state: <code>.<expiry base36>.<signature>.
+func (s *Server) pairLinkToken(code string, expiresAt time.Time) string {
+	payload := code + "." + strconv.FormatInt(expiresAt.Unix(), 36)
+	return payload + "." + s.pairLinkSignature(payload)
+}
+
+func (s *Server) pairLinkSignature(payload string) string {
+	mac := hmac.New(sha256.New, s.security.PairLinkSecret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+func (s *Server) parsePairLinkToken(token string, now time.Time) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errPairLinkInvalid
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(s.pairLinkSignature(payload))) {
+		return "", errPairLinkInvalid
+	}
+	exp, err := strconv.ParseInt(parts[1], 36, 64)
+	if err != nil || now.Unix() > exp {
+		return "", errPairLinkInvalid
+	}
+	return parts[0], nil
+}
+
+func (s *Server) pairLinkURL(r *http.Request, code string, expiresAt time.Time) string {
+	return s.publicBaseURL(r) + pairLinkPrefix + s.pairLinkToken(code, expiresAt)
+}
+
+// publicBaseURL is the origin used in links handed to other devices.
+func (s *Server) publicBaseURL(r *http.Request) string {
+	if base := s.security.PublicBaseURL; base != "" {
+		return base
+	}
+	host := strings.TrimSpace(r.Host)
+	if host == "" {
+		host = "127.0.0.1:8765"
+	}
+	return "https://" + host
+}
+
+func (s *Server) handlePairLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.authSvc == nil {
+		writeError(w, http.StatusServiceUnavailable, apierror.CodeUnavailable, "auth service unavailable")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	code, err := s.parsePairLinkToken(strings.TrimPrefix(r.URL.Path, pairLinkPrefix), time.Now().UTC())
+	var pair auth.PairStartResult
+	if err == nil {
+		pair, err = s.authSvc.LookupPair(r.Context(), code)
+	}
+	if err != nil {
+		s.auditf(r, "pair_link_rejected", err.Error())
+		writeServiceError(w, http.StatusNotFound, errPairLinkInvalid)
+		return
+	}
+	uri := s.pairURI(r, pair)
+	if strings.Contains(r.Header.Get("Accept"), "text/html") {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = pairLinkPage.Execute(w, map[string]any{
+			"PairCode":    pair.PairCode,
+			"ExpiresAt":   pair.ExpiresAt.Format(time.RFC3339),
+			"Fingerprint": pair.BridgeFingerprint,
+			"URI":         template.URL(uri),
+		})
+		return
+	}
+	out := map[string]any{
+		"pair_code":          pair.PairCode,
+		"challenge":          pair.Challenge,
+		"permissions":        pair.Permissions,
+		"expires_at":         pair.ExpiresAt,
+		"bridge_fingerprint": pair.BridgeFingerprint,
+		"elix_uri":           uri,
+		"pair_version":       "v1",
+	}
+	if s.tlsPin != "" {
+		out["tls_fingerprint"] = s.tlsPin
+	}
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"echohelix/internal/apierror"
+	"echohelix/internal/qrcode"
+)
+
+const pairQRPrefix = "/api/v3/pair/"
+
+// handlePairQR serves GET /api/v3/pair/{code}/qr.png (or qr.svg): the pair
+// code's elix:// URI as a QR code, for as long as the code is pending.
+func (s *Server) handlePairQR(w http.ResponseWriter, r *http.Request) {
+	code, file, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, pairQRPrefix), "/")
+	if !ok || code == "" || (file != "qr.png" && file != "qr.svg") {
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "not found")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !s.requireBootstrapOperator(w, r) {
+		return
+	}
+	if s.authSvc == nil {
+		writeError(w, http.StatusServiceUnavailable, apierror.CodeUnavailable, "auth service unavailable")
+		return
+	}
+	pair, err := s.authSvc.LookupPair(r.Context(), code)
+	if err != nil {
+		writeError(w, http.StatusNotFound, apierror.CodePairCodeInvalid, "pair code invalid or expired")
+		return
+	}
+	qr, err := qrcode.Encode(s.pairURI(r, pair))
+	if err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var body []byte
+	if file == "qr.svg" {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		body = qr.SVG()
+	} else {
+		if body, err = qr.PNG(8); err != nil {
+			writeServiceError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Expires", pair.ExpiresAt.UTC().Format(http.TimeFormat))
+	s.auditf(r, "pair_qr", "expires_at="+pair.ExpiresAt.UTC().Format(time.RFC3339))
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"echohelix/internal/apierror"
+	"echohelix/internal/auth"
+	"echohelix/internal/run"
+)
+
+const (
+	pipelinesPath         = "/api/v3/pipelines"
+	defaultPipelinesLimit = 50
+	maxPipelinesLimit     = 500
+)
+
+// handlePipelines serves POST/GET /api/v3/pipelines, GET
+// /api/v3/pipelines/{id} and POST /api/v3/pipelines/{id}/cancel.
+func (s *Server) handlePipelines(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, pipelinesPath), "/")
+	if rest == "" {
+		switch r.Method {
+		case http.MethodPost:
+			s.handlePipelineSubmit(w, r)
+		case http.MethodGet:
+			s.handlePipelineList(w, r)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		}
+		return
+	}
+	parts := strings.Split(rest, "/")
+	pipelineID := parts[0]
+	switch {
+	case len(parts) == 1:
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+			return
+		}
+		if _, ok := s.requireScope(w, r, auth.ScopeRunsRead); !ok {
+			return
+		}
+		p, err := s.runSvc.GetPipeline(r.Context(), pipelineID)
+		if err != nil {
+			writeServiceError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+	case len(parts) == 2 && parts[1] == "cancel":
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+			return
+		}
+		if _, ok := s.requireScope(w, r, auth.ScopeRunsSubmit); !ok {
+			return
+		}
+		p, err := s.runSvc.CancelPipeline(r.Context(), pipelineID)
+		if err != nil {
+			writeServiceError(w, http.StatusNotFound, err)
+			return
+		}
+		s.auditf(r, "pipeline_cancel", "pipeline_id="+pipelineID)
+		writeJSON(w, http.StatusOK, p)
+	default:
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "not found")
+	}
+}
+
+func (s *Server) handlePipelineSubmit(w http.ResponseWriter, r *http.Request) {
+	principal, ok := s.requireScope(w, r, auth.ScopeRunsSubmit)
+	if !ok {
+		return
+	}
+	var req run.PipelineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+	req.SubmittedBy = principal.Address
+	p, err := s.runSvc.SubmitPipeline(r.Context(), req)
+	if err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.auditf(r, "pipeline_submit", "pipeline_id="+p.ID+" steps="+strconv.Itoa(len(p.Steps)))
+	writeJSON(w, http.StatusAccepted, p)
+}
+
+func (s *Server) handlePipelineList(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireScope(w, r, auth.ScopeRunsRead); !ok {
+		return
+	}
+	q := r.URL.Query()
+	query := run.PipelineQuery{
+		Status:      strings.TrimSpace(q.Get("status")),
+		SubmittedBy: strings.TrimSpace(q.Get("submitted_by")),
+		Limit:       defaultPipelinesLimit,
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxPipelinesLimit {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "limit must be between 1 and 500")
+			return
+		}
+		query.Limit = n
+	}
+	items, err := s.runSvc.ListPipelines(r.Context(), query)
+	if err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
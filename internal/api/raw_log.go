@@ -0,0 +1,43 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"echohelix/internal/apierror"
+)
+
+// handleRunRawLog serves GET /api/v3/runs/{run_id}/raw-log: the tail of the
+// CLI output the run's adapter captured to disk, as plain text. It is
+// limited to bootstrap operators because raw output is not redacted.
+func (s *Server) handleRunRawLog(w http.ResponseWriter, r *http.Request, runID string) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !s.requireBootstrapOperator(w, r) {
+		return
+	}
+	var maxBytes int64
+	if v := r.URL.Query().Get("max_bytes"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "max_bytes must be a positive integer")
+			return
+		}
+		maxBytes = n
+	}
+	out, err := s.runSvc.RawLog(r.Context(), runID, maxBytes)
+	if err != nil {
+		writeServiceError(w, http.StatusBadGateway, err)
+		return
+	}
+	s.auditf(r, "run_raw_log_read", "run_id="+runID)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Raw-Log-Size", strconv.FormatInt(out.Size, 10))
+	w.Header().Set("X-Raw-Log-Truncated", strconv.FormatBool(out.Truncated))
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.WriteString(w, out.Data)
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"echohelix/internal/apierror"
+)
+
+const readOnlyPath = "/api/v3/admin/read-only"
+
+// ReadOnlyState is the bridge-wide read-only flag. While active, mutating
+// requests are rejected with code read_only and history stays readable.
+type ReadOnlyState struct {
+	Active bool      `json:"active"`
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since,omitempty"`
+}
+
+type readOnlyFlag struct {
+	mu    sync.RWMutex
+	state ReadOnlyState
+}
+
+func (f *readOnlyFlag) get() ReadOnlyState {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.state
+}
+
+func (f *readOnlyFlag) set(active bool, reason string) ReadOnlyState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case !active:
+		f.state = ReadOnlyState{}
+	case !f.state.Active:
+		f.state = ReadOnlyState{Active: true, Reason: reason, Since: time.Now().UTC()}
+	default:
+		f.state.Reason = reason
+	}
+	return f.state
+}
+
+// SetReadOnly switches read-only mode on or off.
+func (s *Server) SetReadOnly(active bool, reason string) ReadOnlyState {
+	return s.readOnly.set(active, strings.TrimSpace(reason))
+}
+
+func (s *Server) ReadOnly() ReadOnlyState {
+	return s.readOnly.get()
+}
+
+// PairingAvailable reports whether new devices can pair right now; the
+// discovery broadcaster advertises it to LAN clients.
+func (s *Server) PairingAvailable() bool {
+	return s.authSvc != nil && !s.readOnly.get().Active
+}
+
+// withReadOnly rejects mutating requests while read-only mode is active.
+// The toggle endpoint and token refresh stay available so operators can
+// leave the mode and readers keep their sessions; estimates write nothing.
+// MCP messages are checked per tool.
+func (s *Server) withReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := s.readOnly.get()
+		if !state.Active || !isMutatingRequest(r) || r.URL.Path == readOnlyPath || r.URL.Path == adminConfigReloadPath || r.URL.Path == "/api/v3/session/refresh" || r.URL.Path == estimatePath || r.URL.Path == mcpMessagesPath || isRunShareRequest(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		writeAPIError(w, apierror.New(http.StatusServiceUnavailable, apierror.CodeReadOnly, "bridge is in read-only mode").
+			With("reason", state.Reason).
+			With("since", state.Since))
+	})
+}
+
+func isMutatingRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	default:
+		return true
+	}
+}
+
+func (s *Server) handleReadOnly(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if !s.requireBootstrapOperator(w, r) {
+			return
+		}
+		writeJSON(w, http.StatusOK, s.ReadOnly())
+	case http.MethodPost:
+		if !s.requireBootstrapOperator(w, r) {
+			return
+		}
+		var req struct {
+			Active *bool  `json:"active"`
+			Reason string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Active == nil {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "active is required")
+			return
+		}
+		state := s.SetReadOnly(*req.Active, req.Reason)
+		if state.Active {
+			s.auditf(r, "read_only_on", "reason="+state.Reason)
+		} else {
+			s.auditf(r, "read_only_off", "")
+		}
+		writeJSON(w, http.StatusOK, state)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+	}
+}
+
+// isRunShareRequest matches POST /api/v3/runs/{id}/share, which only signs a
+// token and so stays available in read-only mode.
+func isRunShareRequest(path string) bool {
+	return strings.HasPrefix(path, "/api/v3/runs/") && strings.HasSuffix(strings.TrimRight(path, "/"), "/share")
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"html"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// renderCSP forbids scripts, frames and remote loads in rendered output;
+// only the inline stylesheet below is allowed.
+const renderCSP = "default-src 'none'; style-src 'unsafe-inline'; base-uri 'none'; form-action 'none'"
+
+const renderStyle = `body{margin:0;padding:12px;font:15px/1.5 -apple-system,system-ui,sans-serif;color:#1f2328;background:#fff;word-wrap:break-word}
+pre{overflow-x:auto;padding:10px;border-radius:6px;background:#f6f8fa;font:13px/1.45 ui-monospace,Menlo,monospace}
+code{font-family:ui-monospace,Menlo,monospace;background:#f6f8fa;padding:0 3px;border-radius:4px}
+pre code{padding:0;background:none}
+blockquote{margin:0;padding:0 12px;border-left:3px solid #d0d7de;color:#57606a}
+table{border-collapse:collapse;display:block;overflow-x:auto}
+th,td{border:1px solid #d0d7de;padding:4px 8px}
+.tok-kw{color:#cf222e}.tok-str{color:#0a3069}.tok-com{color:#6e7781;font-style:italic}.tok-num{color:#0550ae}
+.diff-add{color:#116329;background:#dafbe1;display:inline-block;min-width:100%}
+.diff-del{color:#82071e;background:#ffebe9;display:inline-block;min-width:100%}
+.diff-hunk{color:#8250df}.diff-meta{color:#57606a;font-weight:600}
+@media (prefers-color-scheme:dark){body{color:#e6edf3;background:#0d1117}pre,code{background:#161b22}
+.tok-kw{color:#ff7b72}.tok-str{color:#a5d6ff}.tok-com{color:#8b949e}.tok-num{color:#79c0ff}
+.diff-add{color:#aff5b4;background:#033a16}.diff-del{color:#ffdcd7;background:#67060c}.diff-hunk{color:#d2a8ff}}`
+
+// maxRenderBytes caps the markdown handleRunRender converts; longer output
+// is cut at a line break and ends with a notice.
+const maxRenderBytes = 1 << 20
+
+// handleRunRender serves the run's final markdown as sanitized HTML. With
+// fragment=1 only the rendered body is returned, for clients that bring
+// their own page shell.
+func (s *Server) handleRunRender(w http.ResponseWriter, r *http.Request, runID string) {
+	obj, err := s.runSvc.GetRun(r.Context(), runID)
+	if err != nil {
+		writeServiceError(w, http.StatusNotFound, err)
+		return
+	}
+	text, err := s.runSvc.FinalOutput(r.Context(), obj.ID)
+	if err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+	truncated := len(text) > maxRenderBytes
+	if truncated {
+		text = truncateRenderSource(text)
+	}
+	body := renderMarkdownHTML(text)
+	if truncated {
+		body += `<p class="render-truncated"><em>Output truncated to its first ` + strconv.Itoa(maxRenderBytes>>20) + " MiB; export the run for the full text.</em></p>\n"
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", renderCSP)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "no-store")
+	fragment := strings.TrimSpace(r.URL.Query().Get("fragment"))
+	if fragment == "1" || strings.EqualFold(fragment, "true") {
+		_, _ = io.WriteString(w, body)
+		return
+	}
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	b.WriteString(`<meta name="viewport" content="width=device-width, initial-scale=1">` + "\n")
+	b.WriteString(`<meta http-equiv="Content-Security-Policy" content="` + renderCSP + `">` + "\n")
+	b.WriteString("<title>Run " + html.EscapeString(obj.ID) + "</title>\n")
+	b.WriteString("<style>\n" + renderStyle + "\n</style>\n</head>\n<body>\n")
+	b.WriteString(`<article class="run-output" data-run-id="` + html.EscapeString(obj.ID) + `" data-status="` + html.EscapeString(obj.Status) + `">` + "\n")
+	b.WriteString(body)
+	b.WriteString("</article>\n</body>\n</html>\n")
+	_, _ = io.WriteString(w, b.String())
+}
+
+func truncateRenderSource(text string) string {
+	text = text[:maxRenderBytes]
+	if k := strings.LastIndexByte(text, '\n'); k > 0 {
+		return text[:k]
+	}
+	return strings.ToValidUTF8(text, "")
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"echohelix/internal/apierror"
+)
+
+// handleRunExtend pushes an active run's timeout back by the requested
+// number of seconds, within the policy caps.
+func (s *Server) handleRunExtend(w http.ResponseWriter, r *http.Request, runID string) {
+	var req struct {
+		Seconds int64 `json:"seconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Seconds <= 0 {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "seconds must be positive")
+		return
+	}
+	out, err := s.runSvc.ExtendRun(r.Context(), runID, time.Duration(req.Seconds)*time.Second)
+	if err != nil {
+		s.auditf(r, "run_extend_rejected", "run_id="+runID+" "+err.Error())
+		writeServiceError(w, http.StatusBadGateway, err)
+		return
+	}
+	s.auditf(r, "run_extend", "run_id="+runID+" deadline="+out.Deadline.Format(time.RFC3339))
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"echohelix/internal/apierror"
+)
+
+const maxRunInputBytes = 64 << 10
+
+// handleRunInput writes a line to an interactive run's stdin. Clients
+// usually send it after a needs_input status event.
+func (s *Server) handleRunInput(w http.ResponseWriter, r *http.Request, runID string) {
+	var req struct {
+		Text string `json:"text"`
+		EOF  bool   `json:"eof"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRunInputBytes)).Decode(&req); err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Text == "" && !req.EOF {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "text or eof is required")
+		return
+	}
+	if err := s.runSvc.SendInput(r.Context(), runID, req.Text, req.EOF); err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.auditf(r, "run_input", "run_id="+runID)
+	writeJSON(w, http.StatusAccepted, map[string]any{"run_id": runID, "accepted": true, "eof": req.EOF})
+}
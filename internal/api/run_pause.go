@@ -0,0 +1,22 @@
+package api
+
+import (
+	"net/http"
+)
+
+// handleRunPause suspends (pause) or continues (!pause) a streaming run.
+func (s *Server) handleRunPause(w http.ResponseWriter, r *http.Request, runID string, pause bool) {
+	event := "run_resume"
+	op := s.runSvc.ResumeRun
+	if pause {
+		event, op = "run_pause", s.runSvc.PauseRun
+	}
+	out, err := op(r.Context(), runID)
+	if err != nil {
+		s.auditf(r, event+"_rejected", "run_id="+runID+" "+err.Error())
+		writeServiceError(w, http.StatusBadGateway, err)
+		return
+	}
+	s.auditf(r, event, "run_id="+runID)
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"echohelix/internal/run"
+)
+
+// handleRunRetry resubmits a finished run's inputs as a new run. The body is
+// optional and may override the backend and model.
+func (s *Server) handleRunRetry(w http.ResponseWriter, r *http.Request, runID, submittedBy string) {
+	var req run.RetryOverrides
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+	obj, err := s.runSvc.RetryRun(r.Context(), runID, req, submittedBy)
+	if err != nil {
+		s.auditf(r, "run_retry_rejected", "run_id="+runID+" "+err.Error())
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.auditf(r, "run_retry", "run_id="+runID+" new_run_id="+obj.ID)
+	writeJSON(w, http.StatusAccepted, submitResponse(obj))
+}
@@ -0,0 +1,20 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// handleRunRollback restores a finished run's workspace to the checkpoint
+// taken before it started (options.checkpoint). force=true also discards
+// what later runs in the same workspace did.
+func (s *Server) handleRunRollback(w http.ResponseWriter, r *http.Request, runID string) {
+	force := r.URL.Query().Get("force") == "true"
+	out, err := s.runSvc.RollbackRun(r.Context(), runID, force)
+	if err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.auditf(r, "run_rollback", "run_id="+runID+" head="+out.HeadCommit+" force="+strconv.FormatBool(force))
+	writeJSON(w, http.StatusOK, map[string]any{"run_id": runID, "checkpoint": out})
+}
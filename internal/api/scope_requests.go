@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"echohelix/internal/apierror"
+	"echohelix/internal/auth"
+)
+
+const scopeRequestsPath = "/api/v3/scope-requests"
+
+// handleScopeRequests lets a paired device ask for more scopes (POST), lists
+// requests (GET), and lets an operator decide one (POST .../{id}/approve or
+// .../{id}/deny).
+func (s *Server) handleScopeRequests(w http.ResponseWriter, r *http.Request) {
+	principal, ok := s.principalFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+		return
+	}
+	if s.authSvc == nil {
+		writeError(w, http.StatusServiceUnavailable, apierror.CodeUnavailable, "auth service unavailable")
+		return
+	}
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, scopeRequestsPath), "/")
+	if rest == "" {
+		switch r.Method {
+		case http.MethodGet:
+			s.listScopeRequests(w, r, principal)
+		case http.MethodPost:
+			s.createScopeRequest(w, r, principal)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		}
+		return
+	}
+	id, action, _ := strings.Cut(rest, "/")
+	if r.Method != http.MethodPost || (action != "approve" && action != "deny") {
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "not found")
+		return
+	}
+	if _, ok := s.requireScope(w, r, auth.ScopeDevicesWrite); !ok {
+		return
+	}
+	var req struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "invalid json")
+			return
+		}
+	}
+	decided, err := s.authSvc.DecideScopeRequest(r.Context(), id, action == "approve", principal, req.Note)
+	if err != nil {
+		writeScopeRequestError(w, err)
+		return
+	}
+	s.auditf(r, "scope_request_"+action, "id="+id+" address="+decided.Address+" scopes="+strings.Join(decided.Scopes, ",")+" trust_level="+decided.TrustLevel)
+	writeJSON(w, http.StatusOK, decided)
+}
+
+func (s *Server) createScopeRequest(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	if principal.AuthType != "session" || principal.Address == "" {
+		writeError(w, http.StatusForbidden, apierror.CodeForbidden, "only paired devices can request scopes")
+		return
+	}
+	var req struct {
+		Scopes []string `json:"scopes"`
+		Reason string   `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "invalid json")
+		return
+	}
+	created, err := s.authSvc.RequestScopes(r.Context(), principal.Address, req.Scopes, req.Reason)
+	if err != nil {
+		writeScopeRequestError(w, err)
+		return
+	}
+	s.auditf(r, "scope_request_create", "id="+created.ID+" scopes="+strings.Join(created.Scopes, ","))
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// listScopeRequests shows operators (devices:write) every request, filtered
+// by ?address=, and other devices only their own.
+func (s *Server) listScopeRequests(w http.ResponseWriter, r *http.Request, principal auth.Principal) {
+	address := strings.TrimSpace(r.URL.Query().Get("address"))
+	if !principal.HasScope(auth.ScopeDevicesWrite) {
+		if principal.Address == "" {
+			writeError(w, http.StatusForbidden, apierror.CodeForbidden, "missing scope: "+auth.ScopeDevicesWrite)
+			return
+		}
+		address = principal.Address
+	}
+	items, err := s.authSvc.ListScopeRequests(r.Context(), address)
+	if err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+func writeScopeRequestError(w http.ResponseWriter, err error) {
+	writeServiceError(w, http.StatusBadRequest, err)
+}
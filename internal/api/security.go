@@ -1,8 +1,14 @@
 package api
 
 import (
+	"context"
+	"crypto/rand"
+	"log"
+	"strings"
 	"sync"
 	"time"
+
+	"echohelix/internal/ledger"
 )
 
 type SecurityConfig struct {
@@ -14,9 +20,42 @@ type SecurityConfig struct {
 	AuthFailureAlertWindow         time.Duration
 	PairCompleteFailureAlertLimit  int
 	PairCompleteFailureAlertWindow time.Duration
-	BackendCallReadMethods         []string
-	BackendCallCancelMethods       []string
-	TrustedProxyCIDRs              []string
+	// RateLimitStore is "ledger" (default) to keep pair start limits and
+	// failure alert counters in the ledger, so they survive restarts and are
+	// shared between bridges, or "memory" for per-process state.
+	RateLimitStore           string
+	BackendCallReadMethods   []string
+	BackendCallCancelMethods []string
+	TrustedProxyCIDRs        []string
+	WSPingInterval           time.Duration
+	WSPongWait               time.Duration
+	WSWriteTimeout           time.Duration
+	// PublicBaseURL is the externally reachable https origin used for pair
+	// links. Defaults to https://<request host>.
+	PublicBaseURL string
+	// PairLinkSecret signs pair links and run share links. A random
+	// per-process key is used when empty, which is enough for pair links
+	// since they never outlive their pair code; share links then stop
+	// working on restart.
+	PairLinkSecret []byte
+	// HTTP server hardening. ReadTimeout/WriteTimeout are the server-wide
+	// connection deadlines; HandlerTimeout bounds each request unless a
+	// RouteTimeouts path prefix overrides it (<= 0 disables for that prefix).
+	HTTPReadTimeout  time.Duration
+	HTTPWriteTimeout time.Duration
+	HTTPIdleTimeout  time.Duration
+	MaxHeaderBytes   int
+	HandlerTimeout   time.Duration
+	RouteTimeouts    map[string]time.Duration
+	// ReadOnly starts the bridge in read-only mode, for example while
+	// restoring a backup. ReadOnlyReason is reported with rejected requests.
+	ReadOnly       bool
+	ReadOnlyReason string
+	TLS            TLSConfig
+	StatusPage     StatusPageConfig
+	// Version is reported by GET /api/v3/system/status; it defaults to the
+	// main module's build version.
+	Version string
 }
 
 func defaultSecurityConfig() SecurityConfig {
@@ -29,8 +68,23 @@ func defaultSecurityConfig() SecurityConfig {
 		AuthFailureAlertWindow:         2 * time.Minute,
 		PairCompleteFailureAlertLimit:  5,
 		PairCompleteFailureAlertWindow: 2 * time.Minute,
+		RateLimitStore:                 rateLimitStoreLedger,
 		BackendCallReadMethods:         []string{"status"},
 		BackendCallCancelMethods:       []string{"turn/interrupt"},
+		WSPingInterval:                 25 * time.Second,
+		WSPongWait:                     60 * time.Second,
+		WSWriteTimeout:                 10 * time.Second,
+		HTTPReadTimeout:                30 * time.Second,
+		HTTPWriteTimeout:               60 * time.Second,
+		HTTPIdleTimeout:                120 * time.Second,
+		MaxHeaderBytes:                 64 * 1024,
+		HandlerTimeout:                 30 * time.Second,
+		RouteTimeouts: map[string]time.Duration{
+			// Uploads are bounded by BRIDGE_MAX_UPLOAD_BYTES rather than time.
+			"/api/v3/files": 5 * time.Minute,
+			// backend/call accepts timeout_ms up to 10 minutes.
+			"/api/v3/sessions/": 11 * time.Minute,
+		},
 	}
 }
 
@@ -60,29 +114,93 @@ func normalizeSecurityConfig(cfg SecurityConfig) SecurityConfig {
 	if cfg.PairCompleteFailureAlertWindow <= 0 {
 		cfg.PairCompleteFailureAlertWindow = def.PairCompleteFailureAlertWindow
 	}
+	cfg.RateLimitStore = strings.ToLower(strings.TrimSpace(cfg.RateLimitStore))
+	if cfg.RateLimitStore != rateLimitStoreMemory {
+		cfg.RateLimitStore = rateLimitStoreLedger
+	}
 	if len(cfg.BackendCallReadMethods) == 0 {
 		cfg.BackendCallReadMethods = append([]string{}, def.BackendCallReadMethods...)
 	}
 	if len(cfg.BackendCallCancelMethods) == 0 {
 		cfg.BackendCallCancelMethods = append([]string{}, def.BackendCallCancelMethods...)
 	}
+	if cfg.WSPingInterval <= 0 {
+		cfg.WSPingInterval = def.WSPingInterval
+	}
+	if cfg.WSPongWait <= 0 {
+		cfg.WSPongWait = def.WSPongWait
+	}
+	if cfg.WSPongWait <= cfg.WSPingInterval {
+		cfg.WSPongWait = cfg.WSPingInterval * 2
+	}
+	if cfg.WSWriteTimeout <= 0 {
+		cfg.WSWriteTimeout = def.WSWriteTimeout
+	}
+	if cfg.HTTPReadTimeout <= 0 {
+		cfg.HTTPReadTimeout = def.HTTPReadTimeout
+	}
+	if cfg.HTTPWriteTimeout <= 0 {
+		cfg.HTTPWriteTimeout = def.HTTPWriteTimeout
+	}
+	if cfg.HTTPIdleTimeout <= 0 {
+		cfg.HTTPIdleTimeout = def.HTTPIdleTimeout
+	}
+	if cfg.MaxHeaderBytes <= 0 {
+		cfg.MaxHeaderBytes = def.MaxHeaderBytes
+	}
+	if cfg.HandlerTimeout <= 0 {
+		cfg.HandlerTimeout = def.HandlerTimeout
+	}
+	routes := make(map[string]time.Duration, len(def.RouteTimeouts)+len(cfg.RouteTimeouts))
+	for prefix, d := range def.RouteTimeouts {
+		routes[prefix] = d
+	}
+	for prefix, d := range cfg.RouteTimeouts {
+		routes[prefix] = d
+	}
+	cfg.RouteTimeouts = routes
+	cfg.PublicBaseURL = strings.TrimRight(strings.TrimSpace(cfg.PublicBaseURL), "/")
+	if len(cfg.PairLinkSecret) == 0 {
+		cfg.PairLinkSecret = make([]byte, 32)
+		if _, err := rand.Read(cfg.PairLinkSecret); err != nil {
+			panic("generate pair link secret: " + err.Error())
+		}
+	}
 	if len(cfg.TrustedProxyCIDRs) > 0 {
 		cfg.TrustedProxyCIDRs = append([]string{}, cfg.TrustedProxyCIDRs...)
 	}
 	return cfg
 }
 
+const (
+	rateLimitStoreLedger = "ledger"
+	rateLimitStoreMemory = "memory"
+)
+
+// rateWindowStore keeps window buckets outside the process. run.Service
+// implements it on the ledger.
+type rateWindowStore interface {
+	HitRateWindow(ctx context.Context, scope, key string, now time.Time, window time.Duration, limit int) (ledger.RateWindow, bool, error)
+	ResetRateWindow(ctx context.Context, scope, key string) error
+}
+
+const rateStoreTimeout = 2 * time.Second
+
 type windowLimiter struct {
 	mu      sync.Mutex
 	limit   int
 	window  time.Duration
 	buckets map[string]*windowBucket
+	scope   string
+	store   rateWindowStore
 }
 
 type windowCounter struct {
 	mu      sync.Mutex
 	window  time.Duration
 	buckets map[string]*windowBucket
+	scope   string
+	store   rateWindowStore
 }
 
 type windowBucket struct {
@@ -98,7 +216,44 @@ func newWindowLimiter(limit int, window time.Duration) *windowLimiter {
 	}
 }
 
+// persistTo moves the limiter's buckets to store under scope. The in-memory
+// buckets stay as a fallback for when the store fails.
+func (l *windowLimiter) persistTo(store rateWindowStore, scope string) *windowLimiter {
+	l.store, l.scope = store, scope
+	return l
+}
+
+// setLimit changes the limit and window, for a config reload. Buckets
+// already open keep their start time.
+func (l *windowLimiter) setLimit(limit int, window time.Duration) {
+	l.mu.Lock()
+	l.limit, l.window = limit, window
+	l.mu.Unlock()
+}
+
+func (l *windowLimiter) windowLen() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.window
+}
+
 func (l *windowLimiter) Allow(key string, now time.Time) (bool, int, time.Duration) {
+	if l.store != nil {
+		l.mu.Lock()
+		limit, window := l.limit, l.window
+		l.mu.Unlock()
+		ctx, cancel := context.WithTimeout(context.Background(), rateStoreTimeout)
+		w, ok, err := l.store.HitRateWindow(ctx, l.scope, key, now, window, limit)
+		cancel()
+		if err == nil {
+			if ok {
+				return true, w.Hits, 0
+			}
+			return false, w.Hits, max(window-now.Sub(w.Start), 0)
+		}
+		log.Printf("warn: rate limit store scope=%s: %v", l.scope, err)
+	}
+
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
@@ -125,7 +280,22 @@ func newWindowCounter(window time.Duration) *windowCounter {
 	}
 }
 
+func (c *windowCounter) persistTo(store rateWindowStore, scope string) *windowCounter {
+	c.store, c.scope = store, scope
+	return c
+}
+
 func (c *windowCounter) Inc(key string, now time.Time) int {
+	if c.store != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), rateStoreTimeout)
+		w, _, err := c.store.HitRateWindow(ctx, c.scope, key, now, c.window, 0)
+		cancel()
+		if err == nil {
+			return w.Hits
+		}
+		log.Printf("warn: rate limit store scope=%s: %v", c.scope, err)
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	b := c.buckets[key]
@@ -138,6 +308,13 @@ func (c *windowCounter) Inc(key string, now time.Time) int {
 }
 
 func (c *windowCounter) Reset(key string) {
+	if c.store != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), rateStoreTimeout)
+		if err := c.store.ResetRateWindow(ctx, c.scope, key); err != nil {
+			log.Printf("warn: rate limit store scope=%s: %v", c.scope, err)
+		}
+		cancel()
+	}
 	c.mu.Lock()
 	delete(c.buckets, key)
 	c.mu.Unlock()
@@ -14,7 +14,11 @@ import (
 	"strings"
 	"time"
 
+	"echohelix/internal/apierror"
 	"echohelix/internal/auth"
+	"echohelix/internal/cluster"
+	"echohelix/internal/events"
+	"echohelix/internal/ledger"
 	"echohelix/internal/run"
 	"echohelix/internal/session"
 
@@ -23,12 +27,15 @@ import (
 
 type Server struct {
 	httpServer       *http.Server
+	statusServer     *http.Server
+	tlsPin           string
 	runSvc           *run.Service
 	sessionSvc       *session.Service
 	authToken        string
 	authSvc          *auth.Service
 	security         SecurityConfig
 	trustedProxyNets []*net.IPNet
+	alertNotifier    auth.SecurityAlertNotifier
 
 	pairStartLimiter         *windowLimiter
 	refreshFailureCounter    *windowCounter
@@ -36,6 +43,12 @@ type Server struct {
 	pairCompleteFailureCount *windowCounter
 	backendCallReadSet       map[string]struct{}
 	backendCallCancelSet     map[string]struct{}
+	readOnly                 readOnlyFlag
+	clusterStatus            func() cluster.Status
+	effectiveConfig          effectiveConfig
+	configReload             configReloader
+	mcpStreams               mcpStreams
+	startedAt                time.Time
 }
 
 type principalContextKey struct{}
@@ -62,26 +75,74 @@ func New(addr string, authToken string, runSvc *run.Service, sessionSvc *session
 		pairCompleteFailureCount: newWindowCounter(cfg.PairCompleteFailureAlertWindow),
 		backendCallReadSet:       makeMethodSet(cfg.BackendCallReadMethods),
 		backendCallCancelSet:     makeMethodSet(cfg.BackendCallCancelMethods),
+		startedAt:                time.Now().UTC(),
+	}
+	if runSvc != nil && cfg.RateLimitStore == rateLimitStoreLedger {
+		s.pairStartLimiter.persistTo(runSvc, "pair_start")
+		s.refreshFailureCounter.persistTo(runSvc, "refresh_failure")
+		s.authFailureCounter.persistTo(runSvc, "auth_failure")
+		s.pairCompleteFailureCount.persistTo(runSvc, "pair_complete_failure")
+	}
+	if authSvc != nil && authToken != "" {
+		if err := authSvc.SeedBootstrapToken(context.Background(), authToken); err != nil {
+			log.Printf("warn: record bootstrap token in admin token store: %v", err)
+		}
 	}
 	mux := http.NewServeMux()
 	mux.HandleFunc("/healthz", s.handleHealth)
 	mux.HandleFunc("/api/v3/pair/complete", s.handlePairComplete)
+	mux.HandleFunc(pairLinkPrefix, s.handlePairLink)
+	mux.HandleFunc(shareLinkPrefix, s.handleSharePage)
+	mux.HandleFunc(shareAPIPrefix, s.handleShareAPI)
 	mux.HandleFunc("/api/v3/session/refresh", s.handleSessionRefresh)
 	mux.HandleFunc("/api/v3/pair/start", s.withAuth(s.handlePairStart))
+	mux.HandleFunc("/api/v3/pair/pending", s.withAuth(s.handlePairPending))
+	mux.HandleFunc(pairQRPrefix, s.withAuth(s.handlePairQR))
 	mux.HandleFunc("/api/v3/devices", s.withAuth(s.handleDevices))
 	mux.HandleFunc("/api/v3/devices/", s.withAuth(s.handleDeviceByAddress))
+	mux.HandleFunc(scopeRequestsPath, s.withAuth(s.handleScopeRequests))
+	mux.HandleFunc(scopeRequestsPath+"/", s.withAuth(s.handleScopeRequests))
 	mux.HandleFunc("/api/v3/backends", s.withAuth(s.handleBackends))
+	mux.HandleFunc(backendsPath, s.withAuth(s.handleBackendByName))
 	mux.HandleFunc("/api/v3/usage/tokens", s.withAuth(s.handleUsageTokens))
 	mux.HandleFunc("/api/v3/usage/quota", s.withAuth(s.handleUsageQuota))
+	mux.HandleFunc("/api/v3/analytics/runs", s.withAuth(s.handleRunAnalytics))
+	mux.HandleFunc(digestsPath, s.withAuth(s.handleDigests))
+	mux.HandleFunc(digestsPath+"/", s.withAuth(s.handleDigests))
 	mux.HandleFunc("/api/v3/emergency/stop", s.withAuth(s.handleEmergencyStop))
 	mux.HandleFunc("/api/v3/emergency/resume", s.withAuth(s.handleEmergencyResume))
 	mux.HandleFunc("/api/v3/emergency/status", s.withAuth(s.handleEmergencyStatus))
+	mux.HandleFunc("/api/v3/diagnostics/events", s.withAuth(s.handleEventDiagnostics))
+	mux.HandleFunc("/api/v3/diagnostics/ledger", s.withAuth(s.handleLedgerVerify))
+	mux.HandleFunc("/api/v3/diagnostics/cluster", s.withAuth(s.handleClusterDiagnostics))
+	mux.HandleFunc(systemStatusPath, s.withAuth(s.handleSystemStatus))
+	mux.HandleFunc("/api/v3/admin/ledger/compact", s.withAuth(s.handleLedgerCompact))
+	mux.HandleFunc(readOnlyPath, s.withAuth(s.handleReadOnly))
+	mux.HandleFunc(adminConfigPath, s.withAuth(s.handleAdminConfig))
+	mux.HandleFunc(adminConfigReloadPath, s.withAuth(s.handleAdminConfigReload))
+	mux.HandleFunc(adminTokensPath, s.withAuth(s.handleAdminTokens))
+	mux.HandleFunc(adminTokensPath+"/", s.withAuth(s.handleAdminTokens))
+	mux.HandleFunc(adminBackendsPath, s.withAuth(s.handleAdminBackends))
+	mux.HandleFunc(adminBackendsPath+"/", s.withAuth(s.handleAdminBackends))
+	mux.HandleFunc(warehouseExportsPath, s.withAuth(s.handleWarehouseExports))
+	mux.HandleFunc(warehouseExportsPath+"/", s.withAuth(s.handleWarehouseExports))
 	mux.HandleFunc("/api/v3/files", s.withAuth(s.handleFiles))
 	mux.HandleFunc("/api/v3/files/", s.withAuth(s.handleFileByID))
+	mux.HandleFunc("/api/v3/workspaces/", s.withAuth(s.handleWorkspaceByID))
 	mux.HandleFunc("/api/v3/sessions", s.withAuth(s.handleSessions))
 	mux.HandleFunc("/api/v3/sessions/", s.withAuth(s.handleSessionByID))
+	mux.HandleFunc("/api/v3/tools", s.withAuth(s.handleTools))
+	mux.HandleFunc("/api/v3/tools/", s.withAuth(s.handleToolByName))
+	mux.HandleFunc("/api/v3/events", s.withAuth(s.handleEventsMux))
+	mux.HandleFunc(estimatePath, s.withAuth(s.handleEstimate))
+	mux.HandleFunc(pipelinesPath, s.withAuth(s.handlePipelines))
+	mux.HandleFunc(pipelinesPath+"/", s.withAuth(s.handlePipelines))
 	mux.HandleFunc("/api/v3/runs", s.withAuth(s.handleRuns))
 	mux.HandleFunc("/api/v3/runs/", s.withAuth(s.handleRunByID))
+	mux.HandleFunc(mcpSSEPath, s.withAuth(s.handleMCPSSE))
+	mux.HandleFunc(mcpMessagesPath, s.withAuth(s.handleMCPMessages))
+	mux.HandleFunc(contractFixturesPath, s.withAuth(s.handleContractFixtures))
+	mux.HandleFunc(contractFixturesPath+"/", s.withAuth(s.handleContractFixtures))
 	if h, err := uiHandler(); err == nil {
 		mux.Handle("/ui/", http.StripPrefix("/ui/", h))
 		mux.HandleFunc("/ui", func(w http.ResponseWriter, r *http.Request) {
@@ -89,20 +150,81 @@ func New(addr string, authToken string, runSvc *run.Service, sessionSvc *session
 		})
 	}
 
+	if cfg.ReadOnly {
+		s.readOnly.set(true, cfg.ReadOnlyReason)
+	}
+	if sp := cfg.StatusPage; sp.Addr != "" {
+		statusMux := http.NewServeMux()
+		newStatusPage(sp, runSvc).register(statusMux)
+		s.statusServer = &http.Server{
+			Addr:              sp.Addr,
+			Handler:           statusMux,
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       cfg.HTTPReadTimeout,
+			WriteTimeout:      cfg.HTTPWriteTimeout,
+			IdleTimeout:       cfg.HTTPIdleTimeout,
+		}
+	} else if sp.Enabled {
+		newStatusPage(sp, runSvc).register(mux)
+	}
+
 	s.httpServer = &http.Server{
 		Addr:              addr,
-		Handler:           mux,
+		Handler:           s.withTracing(mux, s.withRouteTimeouts(s.withReadOnly(mux))),
 		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
 	}
 	return s
 }
 
 func (s *Server) Start() error {
+	if s.authSvc != nil {
+		identity, err := s.authSvc.Identity(context.Background())
+		if err != nil {
+			return err
+		}
+		log.Printf("bridge identity fingerprint %s", identity.Fingerprint)
+	}
+	if s.runSvc != nil {
+		n, err := s.runSvc.ResumePipelines(context.Background())
+		if err != nil {
+			return fmt.Errorf("resume pipelines: %w", err)
+		}
+		if n > 0 {
+			log.Printf("resumed %d running pipelines", n)
+		}
+	}
+	tlsCfg, err := s.tlsConfig()
+	if err != nil {
+		return err
+	}
+	if s.statusServer != nil {
+		go func() {
+			log.Printf("status page listening on %s", s.statusServer.Addr)
+			if err := s.statusServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("status page listener: %v", err)
+			}
+		}()
+	}
+	if tlsCfg != nil {
+		s.httpServer.TLSConfig = tlsCfg
+		if s.tlsPin != "" {
+			log.Printf("bridge tls certificate fingerprint %s", s.tlsPin)
+		}
+		log.Printf("bridge listening on %s (https)", s.httpServer.Addr)
+		return s.httpServer.ListenAndServeTLS("", "")
+	}
 	log.Printf("bridge listening on %s", s.httpServer.Addr)
 	return s.httpServer.ListenAndServe()
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
+	if s.statusServer != nil {
+		_ = s.statusServer.Shutdown(ctx)
+	}
 	return s.httpServer.Shutdown(ctx)
 }
 
@@ -112,12 +234,7 @@ func (s *Server) withAuth(next http.HandlerFunc) http.HandlerFunc {
 		if err != nil {
 			s.auditf(r, "auth_failed", "invalid bearer token")
 			s.maybeAlertAuthFailure(r)
-			writeJSON(w, http.StatusUnauthorized, map[string]any{
-				"error": map[string]any{
-					"code":    "unauthorized",
-					"message": err.Error(),
-				},
-			})
+			writeError(w, http.StatusUnauthorized, apierror.CodeUnauthorized, err.Error())
 			return
 		}
 		s.authFailureCounter.Reset(s.clientIP(r))
@@ -150,19 +267,36 @@ func (s *Server) authenticate(r *http.Request) (auth.Principal, error) {
 	if token == "" {
 		return auth.Principal{}, fmt.Errorf("missing or invalid bearer token")
 	}
+	if s.authSvc != nil && (token == s.authToken || auth.LooksLikeAdminToken(token)) {
+		// A bootstrap token the store has retired must not fall through to
+		// the static comparison below.
+		principal, known, err := s.authSvc.AuthenticateAdminToken(r.Context(), token)
+		if err != nil {
+			return auth.Principal{}, fmt.Errorf("missing or invalid bearer token")
+		}
+		if known {
+			return principal, nil
+		}
+	}
 	if s.authToken != "" && token == s.authToken {
 		return auth.StaticBootstrapPrincipal(), nil
 	}
 	if s.authSvc == nil {
 		return auth.Principal{}, fmt.Errorf("missing or invalid bearer token")
 	}
-	principal, err := s.authSvc.AuthenticateToken(r.Context(), token)
+	principal, err := s.authSvc.AuthenticateToken(s.clientContext(r), token)
 	if err != nil {
 		return auth.Principal{}, fmt.Errorf("missing or invalid bearer token")
 	}
 	return principal, nil
 }
 
+// clientContext carries the client IP and user agent into the auth
+// service, which tracks them per session.
+func (s *Server) clientContext(r *http.Request) context.Context {
+	return auth.WithClientInfo(r.Context(), auth.ClientInfo{IP: s.clientIP(r), UserAgent: r.UserAgent()})
+}
+
 func (s *Server) principalFromContext(ctx context.Context) (auth.Principal, bool) {
 	v := ctx.Value(principalContextKey{})
 	if v == nil {
@@ -175,66 +309,76 @@ func (s *Server) principalFromContext(ctx context.Context) (auth.Principal, bool
 func (s *Server) requireScope(w http.ResponseWriter, r *http.Request, scope string) (auth.Principal, bool) {
 	principal, ok := s.principalFromContext(r.Context())
 	if !ok {
-		writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "unauthorized"})
+		writeError(w, http.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
 		return auth.Principal{}, false
 	}
 	if principal.Admin || principal.HasScope(scope) {
 		return principal, true
 	}
-	writeJSON(w, http.StatusForbidden, map[string]any{
-		"error": map[string]any{
-			"code":    "forbidden",
-			"message": "missing scope: " + scope,
-		},
-	})
+	writeError(w, http.StatusForbidden, apierror.CodeForbidden, "missing scope: "+scope)
 	return auth.Principal{}, false
 }
 
 func (s *Server) handleHealth(w http.ResponseWriter, _ *http.Request) {
-	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "read_only": s.readOnly.get().Active})
 }
 
 func (s *Server) handleRuns(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 		return
 	}
-	if _, ok := s.requireScope(w, r, auth.ScopeRunsSubmit); !ok {
+	principal, ok := s.requireScope(w, r, auth.ScopeRunsSubmit)
+	if !ok {
 		return
 	}
 
 	var req run.SubmitRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+	req.SubmittedBy = principal.Address
+	if !applyIdempotencyKey(w, r, &req.ClientRequestID) {
 		return
 	}
 	obj, err := s.runSvc.Submit(r.Context(), req)
 	if err != nil {
-		if errors.Is(err, run.ErrEmergencyStopActive) {
-			writeJSON(w, http.StatusServiceUnavailable, map[string]any{
-				"error": map[string]any{
-					"code":    "emergency_stop_active",
-					"message": err.Error(),
-				},
-			})
-			return
-		}
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		writeServiceError(w, http.StatusBadRequest, err)
 		return
 	}
-	writeJSON(w, http.StatusAccepted, map[string]any{
+	markReplayed(w, obj.Replayed)
+	writeJSON(w, http.StatusAccepted, submitResponse(obj))
+}
+
+func submitResponse(obj run.Run) map[string]any {
+	resp := map[string]any{
 		"run_id":     obj.ID,
 		"status":     obj.Status,
 		"stream_url": "/api/v3/runs/" + obj.ID + "/events",
+		"stream_id":  streamID(muxStreamRun, obj.ID),
 		"created_at": obj.CreatedAt,
-	})
+	}
+	if obj.ParentRunID != "" {
+		resp["parent_run_id"] = obj.ParentRunID
+	}
+	if obj.QuotaWarning != "" {
+		resp["quota_warning"] = obj.QuotaWarning
+	}
+	if len(obj.UnresolvedMentions) > 0 {
+		resp["unresolved_mentions"] = obj.UnresolvedMentions
+	}
+	if obj.Replayed {
+		resp["replayed"] = true
+	}
+	return resp
 }
 
 func (s *Server) handleRunByID(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/api/v3/runs/")
 	path = strings.Trim(path, "/")
 	if path == "" {
-		writeJSON(w, http.StatusNotFound, map[string]any{"error": "run id missing"})
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "run id missing")
 		return
 	}
 	parts := strings.Split(path, "/")
@@ -242,7 +386,7 @@ func (s *Server) handleRunByID(w http.ResponseWriter, r *http.Request) {
 
 	if len(parts) == 1 {
 		if r.Method != http.MethodGet {
-			writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 			return
 		}
 		if _, ok := s.requireScope(w, r, auth.ScopeRunsRead); !ok {
@@ -250,7 +394,7 @@ func (s *Server) handleRunByID(w http.ResponseWriter, r *http.Request) {
 		}
 		obj, err := s.runSvc.GetRun(r.Context(), runID)
 		if err != nil {
-			writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+			writeServiceError(w, http.StatusNotFound, err)
 			return
 		}
 		writeJSON(w, http.StatusOK, obj)
@@ -261,14 +405,14 @@ func (s *Server) handleRunByID(w http.ResponseWriter, r *http.Request) {
 	switch action {
 	case "cancel":
 		if r.Method != http.MethodPost {
-			writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 			return
 		}
 		if _, ok := s.requireScope(w, r, auth.ScopeRunsCancel); !ok {
 			return
 		}
 		if err := s.runSvc.Cancel(r.Context(), runID); err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			writeServiceError(w, http.StatusBadRequest, err)
 			return
 		}
 		writeJSON(w, http.StatusOK, map[string]any{
@@ -277,15 +421,125 @@ func (s *Server) handleRunByID(w http.ResponseWriter, r *http.Request) {
 		})
 	case "events":
 		if r.Method != http.MethodGet {
-			writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 			return
 		}
 		if _, ok := s.requireScope(w, r, auth.ScopeRunsRead); !ok {
 			return
 		}
+		// The run events URL also accepts unified stream IDs, so a session
+		// can be streamed from here without knowing its kind up front.
+		if kind, id, err := s.resolveStreamID(r.Context(), runID); err == nil {
+			if kind == muxStreamSession {
+				if s.sessionSvc == nil {
+					writeError(w, http.StatusServiceUnavailable, apierror.CodeUnavailable, "session service unavailable")
+					return
+				}
+				s.handleSessionEvents(w, r, id)
+				return
+			}
+			runID = id
+		}
+		if isEventPollRequest(r) {
+			s.handleEventsPoll(w, r, muxStreamRun, runID)
+			return
+		}
+		if !websocket.IsWebSocketUpgrade(r) {
+			s.handleRunEventsPage(w, r, runID)
+			return
+		}
 		s.handleRunEvents(w, r, runID)
+	case "input":
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+			return
+		}
+		if _, ok := s.requireScope(w, r, auth.ScopeRunsSubmit); !ok {
+			return
+		}
+		s.handleRunInput(w, r, runID)
+	case "extend":
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+			return
+		}
+		if _, ok := s.requireScope(w, r, auth.ScopeRunsSubmit); !ok {
+			return
+		}
+		s.handleRunExtend(w, r, runID)
+	case "pause", "resume":
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+			return
+		}
+		if _, ok := s.requireScope(w, r, auth.ScopeRunsSubmit); !ok {
+			return
+		}
+		s.handleRunPause(w, r, runID, action == "pause")
+	case "raw-log":
+		s.handleRunRawLog(w, r, runID)
+	case "retry":
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+			return
+		}
+		principal, ok := s.requireScope(w, r, auth.ScopeRunsSubmit)
+		if !ok {
+			return
+		}
+		s.handleRunRetry(w, r, runID, principal.Address)
+	case "rollback":
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+			return
+		}
+		if _, ok := s.requireScope(w, r, auth.ScopeRunsSubmit); !ok {
+			return
+		}
+		s.handleRunRollback(w, r, runID)
+	case "result":
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+			return
+		}
+		if _, ok := s.requireScope(w, r, auth.ScopeRunsRead); !ok {
+			return
+		}
+		out, err := s.runSvc.GetRunResult(r.Context(), runID)
+		if err != nil {
+			writeServiceError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, out)
+	case "render":
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+			return
+		}
+		if _, ok := s.requireScope(w, r, auth.ScopeRunsRead); !ok {
+			return
+		}
+		s.handleRunRender(w, r, runID)
+	case "export":
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+			return
+		}
+		if _, ok := s.requireScope(w, r, auth.ScopeRunsRead); !ok {
+			return
+		}
+		s.handleRunExport(w, r, runID)
+	case "share":
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+			return
+		}
+		if _, ok := s.requireScope(w, r, auth.ScopeRunsRead); !ok {
+			return
+		}
+		s.handleRunShare(w, r, runID)
 	default:
-		writeJSON(w, http.StatusNotFound, map[string]any{"error": "unknown action"})
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "unknown action")
 	}
 }
 
@@ -294,11 +548,18 @@ var upgrader = websocket.Upgrader{
 }
 
 func (s *Server) handleRunEvents(w http.ResponseWriter, r *http.Request, runID string) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	opts, err := parseWSStreamOptions(r.URL.Query())
 	if err != nil {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
-	defer conn.Close()
+	conn, err := opts.upgrade(w, r)
+	if err != nil {
+		return
+	}
+	ws := s.newWSStream(conn, nil)
+	ws.opts = opts
+	defer ws.close()
 
 	fromSeq := int64(0)
 	if v := r.URL.Query().Get("from_seq"); v != "" {
@@ -307,28 +568,68 @@ func (s *Server) handleRunEvents(w http.ResponseWriter, r *http.Request, runID s
 		}
 	}
 
+	// Subscribe before reading history so no event falls in between.
+	sub, unsub := s.runSvc.Subscribe(runID)
+	defer unsub()
+	seqOf := func(ev events.Event) int64 { return ev.Seq }
 	history, err := s.runSvc.ListEvents(r.Context(), runID, fromSeq)
 	if err == nil {
-		for _, ev := range history {
-			if err := conn.WriteJSON(ev); err != nil {
-				return
-			}
+		if err := writeEvents(ws, history); err != nil {
+			return
 		}
 	}
 
-	sub, unsub := s.runSvc.Subscribe(runID)
-	defer unsub()
+	pumpWS(ws, afterSeq(ws, sub, lastSeq(history, seqOf), seqOf))
+}
 
-	for ev := range sub {
-		if err := conn.WriteJSON(ev); err != nil {
+func (s *Server) handleRunExport(w http.ResponseWriter, r *http.Request, runID string) {
+	format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
+	if format == "" || format == "jsonl" {
+		format = "ndjson"
+	}
+	if format != "ndjson" && format != "tar.gz" && format != "events" {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "format must be ndjson, events or tar.gz")
+		return
+	}
+	var fromSeq int64
+	if v := r.URL.Query().Get("from_seq"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "from_seq must be a non-negative integer")
 			return
 		}
+		fromSeq = n
+	}
+	obj, err := s.runSvc.GetRun(r.Context(), runID)
+	if err != nil {
+		writeServiceError(w, http.StatusNotFound, err)
+		return
+	}
+
+	// Headers are sent before streaming starts, so later failures can only
+	// truncate the body; they are logged rather than reported to the client.
+	switch format {
+	case "tar.gz":
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="run-%s.tar.gz"`, obj.ID))
+		err = s.runSvc.ExportRunArchive(r.Context(), obj, w)
+	case "events":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="run-%s-events.ndjson"`, obj.ID))
+		err = s.runSvc.ExportRunEvents(r.Context(), obj, fromSeq, w)
+	default:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="run-%s.ndjson"`, obj.ID))
+		err = s.runSvc.ExportRunNDJSON(r.Context(), obj, w)
+	}
+	if err != nil {
+		log.Printf("export run %s: %v", obj.ID, err)
 	}
 }
 
 func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
 	if s.sessionSvc == nil {
-		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"error": "session service unavailable"})
+		writeError(w, http.StatusServiceUnavailable, apierror.CodeUnavailable, "session service unavailable")
 		return
 	}
 	switch r.Method {
@@ -338,12 +639,12 @@ func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
 		}
 		var req session.CreateRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			writeServiceError(w, http.StatusBadRequest, err)
 			return
 		}
-		obj, err := s.sessionSvc.Create(r.Context(), req)
+		obj, err := s.sessionSvc.Create(session.WithActor(r.Context(), s.actorOf(r)), req)
 		if err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			writeServiceError(w, http.StatusBadRequest, err)
 			return
 		}
 		writeJSON(w, http.StatusCreated, obj)
@@ -353,19 +654,19 @@ func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
 		}
 		writeJSON(w, http.StatusOK, map[string]any{"items": s.sessionSvc.List()})
 	default:
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 	}
 }
 
 func (s *Server) handleSessionByID(w http.ResponseWriter, r *http.Request) {
 	if s.sessionSvc == nil {
-		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"error": "session service unavailable"})
+		writeError(w, http.StatusServiceUnavailable, apierror.CodeUnavailable, "session service unavailable")
 		return
 	}
 	path := strings.TrimPrefix(r.URL.Path, "/api/v3/sessions/")
 	path = strings.Trim(path, "/")
 	if path == "" {
-		writeJSON(w, http.StatusNotFound, map[string]any{"error": "session id missing"})
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "session id missing")
 		return
 	}
 	parts := strings.Split(path, "/")
@@ -378,7 +679,7 @@ func (s *Server) handleSessionByID(w http.ResponseWriter, r *http.Request) {
 			}
 			obj, err := s.sessionSvc.Get(sessionID)
 			if err != nil {
-				writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+				writeServiceError(w, http.StatusNotFound, err)
 				return
 			}
 			writeJSON(w, http.StatusOK, obj)
@@ -387,21 +688,38 @@ func (s *Server) handleSessionByID(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			if err := s.sessionSvc.Close(sessionID); err != nil {
-				writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+				writeServiceError(w, http.StatusNotFound, err)
 				return
 			}
 			writeJSON(w, http.StatusOK, map[string]any{"session_id": sessionID, "closed": true})
 		default:
-			writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 		}
 		return
 	}
 
+	r = r.WithContext(session.WithActor(r.Context(), s.actorOf(r)))
 	action := parts[1]
 	switch action {
+	case "presence":
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+			return
+		}
+		if _, ok := s.requireScope(w, r, auth.ScopeRunsRead); !ok {
+			return
+		}
+		obj, err := s.sessionSvc.Presence(sessionID)
+		if err != nil {
+			writeServiceError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, obj)
+	case "lock":
+		s.handleSessionLock(w, r, sessionID)
 	case "turns":
 		if r.Method != http.MethodPost {
-			writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 			return
 		}
 		if _, ok := s.requireScope(w, r, auth.ScopeRunsSubmit); !ok {
@@ -409,18 +727,26 @@ func (s *Server) handleSessionByID(w http.ResponseWriter, r *http.Request) {
 		}
 		var req session.StartTurnRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			writeServiceError(w, http.StatusBadRequest, err)
+			return
+		}
+		if !applyIdempotencyKey(w, r, &req.ClientRequestID) {
+			return
+		}
+		if isStreamedTurnRequest(r) {
+			s.handleStreamedTurn(w, r, sessionID, req)
 			return
 		}
 		obj, err := s.sessionSvc.StartTurn(r.Context(), sessionID, req)
 		if err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			writeServiceError(w, http.StatusBadRequest, err)
 			return
 		}
+		markReplayed(w, obj.Replayed)
 		writeJSON(w, http.StatusAccepted, obj)
 	case "interrupt":
 		if r.Method != http.MethodPost {
-			writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 			return
 		}
 		if _, ok := s.requireScope(w, r, auth.ScopeRunsCancel); !ok {
@@ -431,19 +757,33 @@ func (s *Server) handleSessionByID(w http.ResponseWriter, r *http.Request) {
 		}
 		_ = json.NewDecoder(r.Body).Decode(&req)
 		if err := s.sessionSvc.InterruptTurn(r.Context(), sessionID, req.TurnID); err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			writeServiceError(w, http.StatusBadRequest, err)
 			return
 		}
 		writeJSON(w, http.StatusOK, map[string]any{"session_id": sessionID, "interrupted": true})
+	case "resume":
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+			return
+		}
+		if _, ok := s.requireScope(w, r, auth.ScopeRunsSubmit); !ok {
+			return
+		}
+		obj, err := s.sessionSvc.Resume(r.Context(), sessionID)
+		if err != nil {
+			writeServiceError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, obj)
 	case "backend":
 		if len(parts) != 3 {
-			writeJSON(w, http.StatusNotFound, map[string]any{"error": "unknown action"})
+			writeError(w, http.StatusNotFound, apierror.CodeNotFound, "unknown action")
 			return
 		}
 		switch parts[2] {
 		case "status":
 			if r.Method != http.MethodGet {
-				writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+				writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 				return
 			}
 			if _, ok := s.requireScope(w, r, auth.ScopeRunsRead); !ok {
@@ -451,18 +791,18 @@ func (s *Server) handleSessionByID(w http.ResponseWriter, r *http.Request) {
 			}
 			obj, err := s.sessionSvc.BackendStatus(r.Context(), sessionID)
 			if err != nil {
-				writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+				writeServiceError(w, http.StatusBadRequest, err)
 				return
 			}
 			writeJSON(w, http.StatusOK, obj)
 		case "call":
 			if r.Method != http.MethodPost {
-				writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+				writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 				return
 			}
 			var req session.BackendCallRequest
 			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+				writeServiceError(w, http.StatusBadRequest, err)
 				return
 			}
 			if _, ok := s.requireScope(w, r, s.backendCallScope(req.Method)); !ok {
@@ -470,26 +810,35 @@ func (s *Server) handleSessionByID(w http.ResponseWriter, r *http.Request) {
 			}
 			obj, err := s.sessionSvc.BackendCall(r.Context(), sessionID, req)
 			if err != nil {
-				writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+				writeServiceError(w, http.StatusBadRequest, err)
 				return
 			}
 			writeJSON(w, http.StatusOK, obj)
 		default:
-			writeJSON(w, http.StatusNotFound, map[string]any{"error": "unknown action"})
+			writeError(w, http.StatusNotFound, apierror.CodeNotFound, "unknown action")
 		}
 	case "events":
 		if r.Method != http.MethodGet {
-			writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 			return
 		}
 		if _, ok := s.requireScope(w, r, auth.ScopeRunsRead); !ok {
 			return
 		}
 		s.handleSessionEvents(w, r, sessionID)
+	case "transcript":
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+			return
+		}
+		if _, ok := s.requireScope(w, r, auth.ScopeRunsRead); !ok {
+			return
+		}
+		s.handleSessionTranscript(w, r, sessionID)
 	case "requests":
 		if len(parts) == 2 {
 			if r.Method != http.MethodGet {
-				writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+				writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 				return
 			}
 			if _, ok := s.requireScope(w, r, auth.ScopeRunsRead); !ok {
@@ -497,14 +846,14 @@ func (s *Server) handleSessionByID(w http.ResponseWriter, r *http.Request) {
 			}
 			items, err := s.sessionSvc.ListPendingRequests(sessionID)
 			if err != nil {
-				writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+				writeServiceError(w, http.StatusNotFound, err)
 				return
 			}
 			writeJSON(w, http.StatusOK, map[string]any{"items": items})
 			return
 		}
 		if len(parts) != 3 || r.Method != http.MethodPost {
-			writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 			return
 		}
 		if _, ok := s.requireScope(w, r, auth.ScopeRunsCancel); !ok {
@@ -512,18 +861,19 @@ func (s *Server) handleSessionByID(w http.ResponseWriter, r *http.Request) {
 		}
 		var in session.ResolveRequestInput
 		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			writeServiceError(w, http.StatusBadRequest, err)
 			return
 		}
 		if err := s.sessionSvc.ResolvePendingRequest(r.Context(), sessionID, parts[2], in); err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			writeServiceError(w, http.StatusBadRequest, err)
 			return
 		}
+		s.auditf(r, "session_request_resolved", "session_id="+sessionID+" request_id="+parts[2])
 		writeJSON(w, http.StatusOK, map[string]any{"session_id": sessionID, "request_id": parts[2], "resolved": true})
 	case "approvals":
 		if len(parts) == 2 {
 			if r.Method != http.MethodGet {
-				writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+				writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 				return
 			}
 			if _, ok := s.requireScope(w, r, auth.ScopeRunsRead); !ok {
@@ -531,14 +881,14 @@ func (s *Server) handleSessionByID(w http.ResponseWriter, r *http.Request) {
 			}
 			items, err := s.sessionSvc.ListApprovals(sessionID)
 			if err != nil {
-				writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+				writeServiceError(w, http.StatusNotFound, err)
 				return
 			}
 			writeJSON(w, http.StatusOK, map[string]any{"items": items})
 			return
 		}
 		if len(parts) != 3 || r.Method != http.MethodPost {
-			writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 			return
 		}
 		if _, ok := s.requireScope(w, r, auth.ScopeRunsCancel); !ok {
@@ -546,25 +896,73 @@ func (s *Server) handleSessionByID(w http.ResponseWriter, r *http.Request) {
 		}
 		var in session.ApprovalDecision
 		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			writeServiceError(w, http.StatusBadRequest, err)
 			return
 		}
 		if err := s.sessionSvc.ResolveApproval(r.Context(), sessionID, parts[2], in); err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			writeServiceError(w, http.StatusBadRequest, err)
 			return
 		}
+		s.auditf(r, "session_approval_resolved", "session_id="+sessionID+" request_id="+parts[2]+" decision="+in.Decision)
 		writeJSON(w, http.StatusOK, map[string]any{"session_id": sessionID, "request_id": parts[2], "resolved": true})
 	default:
-		writeJSON(w, http.StatusNotFound, map[string]any{"error": "unknown action"})
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "unknown action")
+	}
+}
+
+func (s *Server) handleSessionTranscript(w http.ResponseWriter, r *http.Request, sessionID string) {
+	q := r.URL.Query()
+	fromTurn, limit := 0, 0
+	if v := q.Get("from_turn"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "invalid from_turn")
+			return
+		}
+		fromTurn = n
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+	transcript, err := s.sessionSvc.Transcript(sessionID, fromTurn, limit)
+	if err != nil {
+		writeServiceError(w, http.StatusNotFound, err)
+		return
+	}
+	switch q.Get("format") {
+	case "", "json":
+		writeJSON(w, http.StatusOK, transcript)
+	case "markdown":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, session.RenderTranscriptMarkdown(transcript))
+	default:
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "format must be json or markdown")
 	}
 }
 
 func (s *Server) handleSessionEvents(w http.ResponseWriter, r *http.Request, sessionID string) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	if isEventPollRequest(r) {
+		s.handleEventsPoll(w, r, muxStreamSession, sessionID)
+		return
+	}
+	opts, err := parseWSStreamOptions(r.URL.Query())
 	if err != nil {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
-	defer conn.Close()
+	conn, err := opts.upgrade(w, r)
+	if err != nil {
+		return
+	}
+	ws := s.newWSStream(conn, nil)
+	ws.opts = opts
+	defer ws.close()
 
 	fromSeq := int64(0)
 	if v := r.URL.Query().Get("from_seq"); v != "" {
@@ -572,23 +970,80 @@ func (s *Server) handleSessionEvents(w http.ResponseWriter, r *http.Request, ses
 			fromSeq = n
 		}
 	}
+	q := r.URL.Query()
+	filter := session.ParseEventFilter(q.Get("types"), q.Get("methods"), q.Get("exclude"), q.Get("exclude_methods"))
+	sub, unsub, err := s.sessionSvc.SubscribeFiltered(sessionID, filter)
+	if err != nil {
+		return
+	}
+	defer unsub()
+	seqOf := func(ev session.Event) int64 { return ev.Seq }
 	history, err := s.sessionSvc.ListEvents(sessionID, fromSeq)
 	if err == nil {
-		for _, ev := range history {
-			if err := conn.WriteJSON(ev); err != nil {
-				return
-			}
+		if err := writeEvents(ws, filter.Apply(history)); err != nil {
+			return
 		}
 	}
-	sub, unsub, err := s.sessionSvc.Subscribe(sessionID)
-	if err != nil {
-		return
+	if leave, err := s.sessionSvc.Join(sessionID, session.ActorFrom(r.Context())); err == nil {
+		defer leave()
 	}
-	defer unsub()
-	for ev := range sub {
-		if err := conn.WriteJSON(ev); err != nil {
+	pumpWS(ws, afterSeq(ws, sub, lastSeq(history, seqOf), seqOf))
+}
+
+func (s *Server) handleSessionLock(w http.ResponseWriter, r *http.Request, sessionID string) {
+	switch r.Method {
+	case http.MethodGet:
+		if _, ok := s.requireScope(w, r, auth.ScopeRunsRead); !ok {
+			return
+		}
+		obj, err := s.sessionSvc.Presence(sessionID)
+		if err != nil {
+			writeServiceError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"session_id": sessionID, "lock": obj.Lock})
+	case http.MethodPost:
+		if _, ok := s.requireScope(w, r, auth.ScopeRunsSubmit); !ok {
 			return
 		}
+		var req struct {
+			TTLSeconds int `json:"ttl_seconds"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeServiceError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		if req.TTLSeconds < 0 || time.Duration(req.TTLSeconds)*time.Second > session.MaxLockTTL {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, fmt.Sprintf("ttl_seconds must be between 0 and %d", int(session.MaxLockTTL/time.Second)))
+			return
+		}
+		lock, err := s.sessionSvc.AcquireLock(r.Context(), sessionID, time.Duration(req.TTLSeconds)*time.Second)
+		if err != nil {
+			writeServiceError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.auditf(r, "session_lock_acquired", "session_id="+sessionID)
+		writeJSON(w, http.StatusOK, map[string]any{"session_id": sessionID, "lock": lock})
+	case http.MethodDelete:
+		principal, ok := s.requireScope(w, r, auth.ScopeRunsSubmit)
+		if !ok {
+			return
+		}
+		force := r.URL.Query().Get("force") == "true"
+		if force && !principal.IsOperator() {
+			writeError(w, http.StatusForbidden, apierror.CodeForbidden, "force release requires operator access")
+			return
+		}
+		if err := s.sessionSvc.ReleaseLock(r.Context(), sessionID, force); err != nil {
+			writeServiceError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.auditf(r, "session_lock_released", "session_id="+sessionID)
+		writeJSON(w, http.StatusOK, map[string]any{"session_id": sessionID, "released": true})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 	}
 }
 
@@ -619,7 +1074,7 @@ func normalizeMethod(v string) string {
 
 func (s *Server) handleBackends(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 		return
 	}
 	if _, ok := s.requireScope(w, r, auth.ScopeBackendsRead); !ok {
@@ -627,7 +1082,7 @@ func (s *Server) handleBackends(w http.ResponseWriter, r *http.Request) {
 	}
 	backends, err := s.runSvc.ListBackends(r.Context())
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		writeServiceError(w, http.StatusInternalServerError, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, map[string]any{"backends": backends})
@@ -635,57 +1090,90 @@ func (s *Server) handleBackends(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleUsageTokens(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	if _, ok := s.requireScope(w, r, auth.ScopeBackendsRead); !ok {
+		return
+	}
+
+	from, to, err := parseTimeRange(r, 24*time.Hour)
+	if err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	backend := strings.TrimSpace(r.URL.Query().Get("backend"))
+	groupBy := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("group_by")))
+	switch groupBy {
+	case "", "backend", "device", "workspace":
+	default:
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "group_by must be backend, device or workspace")
+		return
+	}
+	summary, err := s.runSvc.TokenUsageBy(r.Context(), from, to, backend, groupBy)
+	if err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+func (s *Server) handleRunAnalytics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 		return
 	}
 	if _, ok := s.requireScope(w, r, auth.ScopeBackendsRead); !ok {
 		return
 	}
+	from, to, err := parseTimeRange(r, 7*24*time.Hour)
+	if err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+	summary, err := s.runSvc.RunAnalytics(r.Context(), from, to, r.URL.Query().Get("group_by"))
+	if err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
 
+func parseTimeRange(r *http.Request, defaultWindow time.Duration) (time.Time, time.Time, error) {
 	now := time.Now().UTC()
-	from := now.Add(-24 * time.Hour)
+	from := now.Add(-defaultWindow)
 	to := now
 	if v := strings.TrimSpace(r.URL.Query().Get("window")); v != "" {
 		dur, err := time.ParseDuration(v)
 		if err != nil || dur <= 0 {
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid window duration"})
-			return
+			return time.Time{}, time.Time{}, errors.New("invalid window duration")
 		}
 		from = now.Add(-dur)
 	}
 	if v := strings.TrimSpace(r.URL.Query().Get("from")); v != "" {
 		ts, err := time.Parse(time.RFC3339, v)
 		if err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid from (expect RFC3339)"})
-			return
+			return time.Time{}, time.Time{}, errors.New("invalid from (expect RFC3339)")
 		}
 		from = ts.UTC()
 	}
 	if v := strings.TrimSpace(r.URL.Query().Get("to")); v != "" {
 		ts, err := time.Parse(time.RFC3339, v)
 		if err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid to (expect RFC3339)"})
-			return
+			return time.Time{}, time.Time{}, errors.New("invalid to (expect RFC3339)")
 		}
 		to = ts.UTC()
 	}
 	if !from.Before(to) {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "from must be before to"})
-		return
-	}
-
-	backend := strings.TrimSpace(r.URL.Query().Get("backend"))
-	summary, err := s.runSvc.TokenUsage(r.Context(), from, to, backend)
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
-		return
+		return time.Time{}, time.Time{}, errors.New("from must be before to")
 	}
-	writeJSON(w, http.StatusOK, summary)
+	return from, to, nil
 }
 
 func (s *Server) handleUsageQuota(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 		return
 	}
 	if _, ok := s.requireScope(w, r, auth.ScopeBackendsRead); !ok {
@@ -694,7 +1182,7 @@ func (s *Server) handleUsageQuota(w http.ResponseWriter, r *http.Request) {
 	backend := strings.TrimSpace(r.URL.Query().Get("backend"))
 	items, err := s.runSvc.TokenQuota(r.Context(), time.Now().UTC(), backend)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		writeServiceError(w, http.StatusInternalServerError, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, map[string]any{"items": items})
@@ -705,21 +1193,16 @@ func (s *Server) requireBootstrapOperator(w http.ResponseWriter, r *http.Request
 	if !ok {
 		return false
 	}
-	if principal.Admin || principal.AuthType == "static" {
+	if principal.IsOperator() {
 		return true
 	}
-	writeJSON(w, http.StatusForbidden, map[string]any{
-		"error": map[string]any{
-			"code":    "forbidden",
-			"message": "requires bootstrap static token",
-		},
-	})
+	writeError(w, http.StatusForbidden, apierror.CodeForbidden, "requires bootstrap static token or an admin token with the admin scope")
 	return false
 }
 
 func (s *Server) handleEmergencyStop(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 		return
 	}
 	if !s.requireBootstrapOperator(w, r) {
@@ -741,7 +1224,7 @@ func (s *Server) handleEmergencyStop(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleEmergencyResume(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 		return
 	}
 	if !s.requireBootstrapOperator(w, r) {
@@ -753,7 +1236,7 @@ func (s *Server) handleEmergencyResume(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleEmergencyStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 		return
 	}
 	if !s.requireBootstrapOperator(w, r) {
@@ -762,18 +1245,130 @@ func (s *Server) handleEmergencyStatus(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, s.runSvc.EmergencyStatus())
 }
 
+func (s *Server) handleEventDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !s.requireBootstrapOperator(w, r) {
+		return
+	}
+	diag := s.runSvc.EventDiagnostics()
+	if s.sessionSvc != nil {
+		stats := s.sessionSvc.HubStats()
+		diag.SessionHub = &stats
+	}
+	writeJSON(w, http.StatusOK, diag)
+}
+
+// SetClusterStatus reports leader election state on the cluster
+// diagnostics endpoint.
+func (s *Server) SetClusterStatus(status func() cluster.Status) {
+	s.clusterStatus = status
+}
+
+func (s *Server) handleClusterDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !s.requireBootstrapOperator(w, r) {
+		return
+	}
+	out := struct {
+		Enabled bool `json:"enabled"`
+		cluster.Status
+	}{Status: cluster.Status{Leader: true}}
+	if s.clusterStatus != nil {
+		out.Enabled = true
+		out.Status = s.clusterStatus()
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleLedgerVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !s.requireBootstrapOperator(w, r) {
+		return
+	}
+	staleAfter := time.Hour
+	if v := strings.TrimSpace(r.URL.Query().Get("stale_after")); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "stale_after must be a positive duration")
+			return
+		}
+		staleAfter = d
+	}
+	report, err := s.runSvc.VerifyLedger(r.Context(), staleAfter)
+	if err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (s *Server) handleLedgerCompact(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !s.requireBootstrapOperator(w, r) {
+		return
+	}
+	var req struct {
+		DryRun          bool `json:"dry_run"`
+		MaxAgeSeconds   int  `json:"max_age_seconds"`
+		MaxEventsPerRun int  `json:"max_events_per_run"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			writeServiceError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if v := strings.TrimSpace(r.URL.Query().Get("dry_run")); v != "" {
+		dry, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "dry_run must be a boolean")
+			return
+		}
+		req.DryRun = dry
+	}
+	if req.MaxAgeSeconds < 0 || req.MaxEventsPerRun < 0 {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "retention limits must not be negative")
+		return
+	}
+	policy := ledger.RetentionPolicy{
+		MaxAge:          time.Duration(req.MaxAgeSeconds) * time.Second,
+		MaxEventsPerRun: req.MaxEventsPerRun,
+	}
+	res, err := s.runSvc.CompactLedger(r.Context(), policy, req.DryRun)
+	if err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.auditf(r, "ledger_compact", fmt.Sprintf("dry_run=%t removed_events=%d", res.DryRun, res.RemovedEvents))
+	writeJSON(w, http.StatusOK, res)
+}
+
 func (s *Server) handleFiles(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
 		s.handleFileUpload(w, r)
+	case http.MethodGet:
+		s.handleFileUsage(w, r)
 	default:
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 	}
 }
 
 func (s *Server) handleFileByID(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 		return
 	}
 	if _, ok := s.requireScope(w, r, auth.ScopeRunsRead); !ok {
@@ -781,16 +1376,12 @@ func (s *Server) handleFileByID(w http.ResponseWriter, r *http.Request) {
 	}
 	fileID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v3/files/"), "/")
 	if fileID == "" {
-		writeJSON(w, http.StatusNotFound, map[string]any{"error": "file id missing"})
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "file id missing")
 		return
 	}
 	obj, err := s.runSvc.GetUploadedFile(r.Context(), fileID)
 	if err != nil {
-		if errors.Is(err, run.ErrFileNotFound) {
-			writeJSON(w, http.StatusNotFound, map[string]any{"error": "file not found"})
-			return
-		}
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		writeServiceError(w, http.StatusBadRequest, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, obj)
@@ -805,12 +1396,12 @@ func (s *Server) handleFileUpload(w http.ResponseWriter, r *http.Request) {
 	limit := s.runSvc.MaxUploadBytes()
 	r.Body = http.MaxBytesReader(w, r.Body, limit+1024)
 	if err := r.ParseMultipartForm(limit); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid multipart form or file too large"})
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "invalid multipart form or file too large")
 		return
 	}
 	file, header, err := r.FormFile("file")
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "multipart field 'file' is required"})
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "multipart field 'file' is required")
 		return
 	}
 	defer file.Close()
@@ -835,11 +1426,7 @@ func (s *Server) handleFileUpload(w http.ResponseWriter, r *http.Request) {
 		CreatedBy:    createdBy,
 	})
 	if err != nil {
-		if errors.Is(err, run.ErrFileTooLarge) {
-			writeJSON(w, http.StatusRequestEntityTooLarge, map[string]any{"error": err.Error()})
-			return
-		}
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		writeServiceError(w, http.StatusBadRequest, err)
 		return
 	}
 	writeJSON(w, http.StatusCreated, obj)
@@ -847,21 +1434,16 @@ func (s *Server) handleFileUpload(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handlePairStart(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 		return
 	}
 	principal, ok := s.requireScope(w, r, auth.ScopePairStart)
 	if !ok {
 		return
 	}
-	if !principal.Admin && principal.AuthType != "static" {
+	if !principal.Admin && principal.AuthType != "static" && principal.AuthType != auth.AuthTypeAdminToken {
 		s.auditf(r, "pair_start_denied", "requires bootstrap static token")
-		writeJSON(w, http.StatusForbidden, map[string]any{
-			"error": map[string]any{
-				"code":    "forbidden",
-				"message": "pair/start requires bootstrap static token",
-			},
-		})
+		writeError(w, http.StatusForbidden, apierror.CodeForbidden, "pair/start requires bootstrap static token")
 		return
 	}
 	ok, attempts, retryAfter := s.pairStartLimiter.Allow(s.clientIP(r), time.Now().UTC())
@@ -872,22 +1454,18 @@ func (s *Server) handlePairStart(w http.ResponseWriter, r *http.Request) {
 		}
 		w.Header().Set("Retry-After", strconv.Itoa(retrySec))
 		s.auditf(r, "pair_start_rate_limited", fmt.Sprintf("attempts=%d retry_after=%ds", attempts, retrySec))
-		log.Printf("security_alert event=pair_start_burst ip=%s attempts=%d window_sec=%d", s.clientIP(r), attempts, int(s.security.PairStartRateWindow.Seconds()))
-		writeJSON(w, http.StatusTooManyRequests, map[string]any{
-			"error": map[string]any{
-				"code":    "rate_limited",
-				"message": "too many pair/start requests",
-			},
-		})
+		s.securityAlert("pair_start_burst", s.clientIP(r), fmt.Sprintf("ip=%s attempts=%d window_sec=%d", s.clientIP(r), attempts, int(s.pairStartLimiter.windowLen().Seconds())))
+		writeError(w, http.StatusTooManyRequests, apierror.CodeRateLimited, "too many pair/start requests")
 		return
 	}
 	if s.authSvc == nil {
-		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"error": "auth service unavailable"})
+		writeError(w, http.StatusServiceUnavailable, apierror.CodeUnavailable, "auth service unavailable")
 		return
 	}
 
 	var req struct {
 		Permissions []string `json:"permissions"`
+		TrustLevel  string   `json:"trust_level"`
 		TTLSeconds  int      `json:"ttl_seconds"`
 	}
 	_ = json.NewDecoder(r.Body).Decode(&req)
@@ -896,42 +1474,69 @@ func (s *Server) handlePairStart(w http.ResponseWriter, r *http.Request) {
 	if createdBy == "" {
 		createdBy = "admin"
 	}
-	resp, err := s.authSvc.StartPair(r.Context(), createdBy, req.Permissions, ttl)
+	resp, err := s.authSvc.StartPairAs(r.Context(), createdBy, strings.TrimSpace(req.TrustLevel), req.Permissions, ttl)
 	if err != nil {
 		s.auditf(r, "pair_start_failed", err.Error())
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		writeServiceError(w, http.StatusBadRequest, err)
 		return
 	}
 	s.auditf(r, "pair_start_ok", "pair code issued")
-	writeJSON(w, http.StatusOK, map[string]any{
-		"pair_code":    resp.PairCode,
-		"challenge":    resp.Challenge,
-		"permissions":  resp.Permissions,
-		"expires_at":   resp.ExpiresAt,
-		"elix_uri":     pairURI(r, resp.PairCode, resp.Challenge),
-		"pair_version": "v1",
-	})
+	out := map[string]any{
+		"pair_code":          resp.PairCode,
+		"challenge":          resp.Challenge,
+		"permissions":        resp.Permissions,
+		"trust_level":        resp.TrustLevel,
+		"expires_at":         resp.ExpiresAt,
+		"bridge_fingerprint": resp.BridgeFingerprint,
+		"elix_uri":           s.pairURI(r, resp),
+		"pair_url":           s.pairLinkURL(r, resp.PairCode, resp.ExpiresAt),
+		"pair_version":       "v1",
+	}
+	if s.tlsPin != "" {
+		out["tls_fingerprint"] = s.tlsPin
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handlePairPending(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !s.requireBootstrapOperator(w, r) {
+		return
+	}
+	if s.authSvc == nil {
+		writeError(w, http.StatusServiceUnavailable, apierror.CodeUnavailable, "auth service unavailable")
+		return
+	}
+	items, err := s.authSvc.ListPendingPairs(r.Context(), time.Now().UTC())
+	if err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
 }
 
 func (s *Server) handlePairComplete(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 		return
 	}
 	if s.authSvc == nil {
-		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"error": "auth service unavailable"})
+		writeError(w, http.StatusServiceUnavailable, apierror.CodeUnavailable, "auth service unavailable")
 		return
 	}
 	var req auth.CompletePairRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		writeServiceError(w, http.StatusBadRequest, err)
 		return
 	}
-	resp, err := s.authSvc.CompletePair(r.Context(), req)
+	resp, err := s.authSvc.CompletePair(s.clientContext(r), req)
 	if err != nil {
 		s.auditf(r, "pair_complete_failed", err.Error())
 		s.maybeAlertPairCompleteFailure(r)
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		writeServiceError(w, http.StatusBadRequest, err)
 		return
 	}
 	s.pairCompleteFailureCount.Reset(s.clientIP(r))
@@ -941,25 +1546,25 @@ func (s *Server) handlePairComplete(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleSessionRefresh(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 		return
 	}
 	if s.authSvc == nil {
-		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"error": "auth service unavailable"})
+		writeError(w, http.StatusServiceUnavailable, apierror.CodeUnavailable, "auth service unavailable")
 		return
 	}
 	var req struct {
 		RefreshToken string `json:"refresh_token"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		writeServiceError(w, http.StatusBadRequest, err)
 		return
 	}
-	resp, err := s.authSvc.RefreshSession(r.Context(), req.RefreshToken)
+	resp, err := s.authSvc.RefreshSession(s.clientContext(r), req.RefreshToken)
 	if err != nil {
 		s.auditf(r, "session_refresh_failed", err.Error())
 		s.maybeAlertRefreshFailure(r)
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		writeServiceError(w, http.StatusBadRequest, err)
 		return
 	}
 	s.refreshFailureCounter.Reset(s.clientIP(r))
@@ -969,19 +1574,19 @@ func (s *Server) handleSessionRefresh(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleDevices(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 		return
 	}
 	if _, ok := s.requireScope(w, r, auth.ScopeDevicesRead); !ok {
 		return
 	}
 	if s.authSvc == nil {
-		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"error": "auth service unavailable"})
+		writeError(w, http.StatusServiceUnavailable, apierror.CodeUnavailable, "auth service unavailable")
 		return
 	}
 	devices, err := s.authSvc.ListDevices(r.Context())
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		writeServiceError(w, http.StatusInternalServerError, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, map[string]any{"devices": devices})
@@ -991,84 +1596,127 @@ func (s *Server) handleDeviceByAddress(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/api/v3/devices/")
 	path = strings.Trim(path, "/")
 	if path == "" {
-		writeJSON(w, http.StatusNotFound, map[string]any{"error": "device address missing"})
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "device address missing")
 		return
 	}
 	parts := strings.Split(path, "/")
 	address := parts[0]
 	if len(parts) < 2 {
-		writeJSON(w, http.StatusNotFound, map[string]any{"error": "unknown action"})
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "unknown action")
 		return
 	}
 	action := parts[1]
 
-	principal, ok := s.requireScope(w, r, auth.ScopeDevicesWrite)
+	scope := auth.ScopeDevicesWrite
+	if action == "sessions" && r.Method == http.MethodGet {
+		scope = auth.ScopeDevicesRead
+	}
+	principal, ok := s.requireScope(w, r, scope)
 	if !ok {
 		return
 	}
 	if !principal.Admin && principal.Address != address {
-		writeJSON(w, http.StatusForbidden, map[string]any{"error": "can only manage current device"})
+		writeError(w, http.StatusForbidden, apierror.CodeForbidden, "can only manage current device")
 		return
 	}
 	if s.authSvc == nil {
-		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"error": "auth service unavailable"})
+		writeError(w, http.StatusServiceUnavailable, apierror.CodeUnavailable, "auth service unavailable")
 		return
 	}
 
 	switch action {
 	case "rename":
 		if r.Method != http.MethodPost {
-			writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 			return
 		}
 		var req struct {
 			Name string `json:"name"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			writeServiceError(w, http.StatusBadRequest, err)
 			return
 		}
 		if err := s.authSvc.RenameDevice(r.Context(), address, req.Name); err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			writeServiceError(w, http.StatusBadRequest, err)
 			return
 		}
 		writeJSON(w, http.StatusOK, map[string]any{"address": address, "renamed": true})
 	case "revoke":
 		if r.Method != http.MethodPost {
-			writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
 			return
 		}
 		var req struct {
 			Reason string `json:"reason"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			writeServiceError(w, http.StatusBadRequest, err)
 			return
 		}
 		if err := s.authSvc.RevokeDevice(r.Context(), address, req.Reason); err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			writeServiceError(w, http.StatusBadRequest, err)
 			return
 		}
 		writeJSON(w, http.StatusOK, map[string]any{"address": address, "revoked": true})
+	case "sessions":
+		s.handleDeviceSessions(w, r, principal, address, parts[2:])
 	default:
-		writeJSON(w, http.StatusNotFound, map[string]any{"error": "unknown action"})
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "unknown action")
 	}
 }
 
-func pairURI(r *http.Request, code, challenge string) string {
+// handleDeviceSessions lists a device's auth sessions (GET) or signs one of
+// them out (DELETE .../sessions/{session_id}).
+func (s *Server) handleDeviceSessions(w http.ResponseWriter, r *http.Request, principal auth.Principal, address string, rest []string) {
+	switch {
+	case len(rest) == 0 && r.Method == http.MethodGet:
+		items, err := s.authSvc.ListDeviceSessions(r.Context(), address)
+		if err != nil {
+			writeServiceError(w, http.StatusBadRequest, err)
+			return
+		}
+		for i := range items {
+			items[i].Current = items[i].SessionID == principal.SessionID
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"address": address, "sessions": items})
+	case len(rest) == 1 && r.Method == http.MethodDelete:
+		sessionID := rest[0]
+		if err := s.authSvc.RevokeSession(r.Context(), address, sessionID); err != nil {
+			writeServiceError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.auditf(r, "device_session_revoked", "address="+address+" session_id="+sessionID)
+		writeJSON(w, http.StatusOK, map[string]any{"address": address, "session_id": sessionID, "revoked": true})
+	case len(rest) > 1:
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "unknown action")
+	default:
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) pairURI(r *http.Request, pair auth.PairStartResult) string {
 	host := strings.TrimSpace(r.Host)
 	if host == "" {
 		host = "127.0.0.1:8765"
 	}
 	frag := url.Values{}
-	frag.Set("code", code)
-	frag.Set("challenge", challenge)
+	frag.Set("code", pair.PairCode)
+	frag.Set("challenge", pair.Challenge)
+	if pair.BridgeFingerprint != "" {
+		frag.Set("fp", pair.BridgeFingerprint)
+	}
+	if s.tlsPin != "" {
+		frag.Set("tls", s.tlsPin)
+	}
 	u := url.URL{
-		Scheme:   "elix",
-		Host:     host,
-		Path:     "/pair",
-		Fragment: frag.Encode(),
+		Scheme:      "elix",
+		Host:        host,
+		Path:        "/pair",
+		RawFragment: frag.Encode(),
 	}
+	// Fragment must match RawFragment or String re-escapes the encoding.
+	u.Fragment, _ = url.PathUnescape(u.RawFragment)
 	return u.String()
 }
 
@@ -1155,21 +1803,53 @@ func parseIP(value string) net.IP {
 	return net.ParseIP(candidate)
 }
 
+// actorOf names the caller for audit records, session presence, locks and
+// ownership: the device address, the named admin token, or the bootstrap
+// token. Auth types are shared by many callers and never name one.
+func (s *Server) actorOf(r *http.Request) string {
+	principal, ok := s.principalFromContext(r.Context())
+	if !ok {
+		return ""
+	}
+	if principal.Address != "" {
+		return principal.Address
+	}
+	if principal.AuthType == "static" {
+		return "admin-token:" + auth.BootstrapTokenName
+	}
+	return ""
+}
+
 func (s *Server) auditf(r *http.Request, event, detail string) {
+	ip := s.clientIP(r)
 	log.Printf(
 		"audit event=%s ip=%s method=%s path=%s detail=%q",
-		event, s.clientIP(r), r.Method, r.URL.Path, detail,
+		event, ip, r.Method, r.URL.Path, detail,
 	)
+	if s.runSvc == nil {
+		return
+	}
+	rec := ledger.AuditRecord{Event: event, IP: ip, Method: r.Method, Path: r.URL.Path, Detail: detail, Actor: s.actorOf(r)}
+	if err := s.runSvc.RecordAudit(r.Context(), rec); err != nil {
+		log.Printf("warn: persist audit event=%s: %v", event, err)
+	}
+}
+
+// SetSecurityAlertNotifier receives the API's security alerts (auth,
+// refresh and pairing failure bursts) in addition to the log line.
+func (s *Server) SetSecurityAlertNotifier(fn auth.SecurityAlertNotifier) {
+	s.alertNotifier = fn
+}
+
+func (s *Server) securityAlert(event, ip, detail string) {
+	auth.DispatchSecurityAlert(s.alertNotifier, auth.SecurityAlert{Event: event, IP: ip, Detail: detail})
 }
 
 func (s *Server) maybeAlertRefreshFailure(r *http.Request) {
 	ip := s.clientIP(r)
 	n := s.refreshFailureCounter.Inc(ip, time.Now().UTC())
 	if n >= s.security.RefreshFailureAlertLimit {
-		log.Printf(
-			"security_alert event=refresh_fail_burst ip=%s failures=%d window_sec=%d",
-			ip, n, int(s.security.RefreshFailureAlertWindow.Seconds()),
-		)
+		s.securityAlert("refresh_fail_burst", ip, fmt.Sprintf("ip=%s failures=%d window_sec=%d", ip, n, int(s.security.RefreshFailureAlertWindow.Seconds())))
 	}
 }
 
@@ -1177,10 +1857,7 @@ func (s *Server) maybeAlertAuthFailure(r *http.Request) {
 	ip := s.clientIP(r)
 	n := s.authFailureCounter.Inc(ip, time.Now().UTC())
 	if n >= s.security.AuthFailureAlertLimit {
-		log.Printf(
-			"security_alert event=auth_fail_burst ip=%s failures=%d window_sec=%d",
-			ip, n, int(s.security.AuthFailureAlertWindow.Seconds()),
-		)
+		s.securityAlert("auth_fail_burst", ip, fmt.Sprintf("ip=%s failures=%d window_sec=%d", ip, n, int(s.security.AuthFailureAlertWindow.Seconds())))
 	}
 }
 
@@ -1188,10 +1865,7 @@ func (s *Server) maybeAlertPairCompleteFailure(r *http.Request) {
 	ip := s.clientIP(r)
 	n := s.pairCompleteFailureCount.Inc(ip, time.Now().UTC())
 	if n >= s.security.PairCompleteFailureAlertLimit {
-		log.Printf(
-			"security_alert event=pair_complete_fail_burst ip=%s failures=%d window_sec=%d",
-			ip, n, int(s.security.PairCompleteFailureAlertWindow.Seconds()),
-		)
+		s.securityAlert("pair_complete_fail_burst", ip, fmt.Sprintf("ip=%s failures=%d window_sec=%d", ip, n, int(s.security.PairCompleteFailureAlertWindow.Seconds())))
 	}
 }
 
@@ -1200,3 +1874,20 @@ func writeJSON(w http.ResponseWriter, status int, obj any) {
 	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(obj)
 }
+
+func writeError(w http.ResponseWriter, status int, code apierror.Code, message string) {
+	writeAPIError(w, apierror.New(status, code, message))
+}
+
+// writeServiceError writes err with the status and code the apierror
+// catalog gives it, or fallback for errors it does not know.
+func writeServiceError(w http.ResponseWriter, fallback int, err error) {
+	writeAPIError(w, apierror.From(err, fallback))
+}
+
+func writeAPIError(w http.ResponseWriter, e *apierror.Error) {
+	if e.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(e.RetryAfter))
+	}
+	writeJSON(w, e.Status, e.Body())
+}
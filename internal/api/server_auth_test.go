@@ -1,32 +1,40 @@
 package api
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/ed25519"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
+	"image/png"
 	"io"
 	"log"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"echohelix/internal/auth"
+	"echohelix/internal/config"
 	"echohelix/internal/driver"
 	"echohelix/internal/events"
 	"echohelix/internal/ledger"
 	"echohelix/internal/policy"
 	"echohelix/internal/run"
 	"echohelix/internal/session"
+
+	"github.com/gorilla/websocket"
 )
 
 type fakeAPIDriver struct{}
@@ -156,6 +164,8 @@ func testSessionConfig(codexBin string) session.Config {
 		CodexBin:       codexBin,
 		GeminiBin:      codexBin,
 		ClaudeBin:      codexBin,
+		GeminiProtocol: session.ProtocolAppServer,
+		ClaudeProtocol: session.ProtocolAppServer,
 		StartTimeout:   3 * time.Second,
 		RequestTimeout: 3 * time.Second,
 	}
@@ -430,6 +440,65 @@ func TestFileUploadAndRunAttachments(t *testing.T) {
 	if !strings.Contains(runObj.Prompt, ".elix/attachments/spec.md") {
 		t.Fatalf("expected prompt mention rewrite, got prompt=%q", runObj.Prompt)
 	}
+
+	orphanStatus, orphanBody := doMultipart(t, ts, "/api/v3/files", completeResp.AccessToken, "file", "unused.txt", []byte("unused"))
+	if orphanStatus != http.StatusCreated {
+		t.Fatalf("upload status=%d body=%s", orphanStatus, string(orphanBody))
+	}
+	if status, _ := doJSON(t, ts, "GET", "/api/v3/files", completeResp.AccessToken, nil); status != http.StatusForbidden {
+		t.Fatalf("device file listing expected 403, got %d", status)
+	}
+	var usage struct {
+		Files []struct {
+			FileID    string `json:"file_id"`
+			CreatedBy string `json:"created_by"`
+			RefCount  int64  `json:"ref_count"`
+			Orphaned  bool   `json:"orphaned"`
+		} `json:"files"`
+		Summary struct {
+			FileCount     int64 `json:"file_count"`
+			TotalBytes    int64 `json:"total_bytes"`
+			OrphanedCount int64 `json:"orphaned_count"`
+			OrphanedBytes int64 `json:"orphaned_bytes"`
+		} `json:"summary"`
+	}
+	listStatus, listBody := doJSON(t, ts, "GET", "/api/v3/files", "admin-token", nil)
+	if listStatus != http.StatusOK {
+		t.Fatalf("file listing status=%d body=%s", listStatus, string(listBody))
+	}
+	if err := json.Unmarshal(listBody, &usage); err != nil {
+		t.Fatalf("decode file listing: %v", err)
+	}
+	if len(usage.Files) != 2 || usage.Summary.FileCount != 2 || usage.Summary.TotalBytes != int64(len("# spec\nhello")+len("unused")) {
+		t.Fatalf("unexpected file listing: %s", string(listBody))
+	}
+	for _, f := range usage.Files {
+		wantRefs := int64(0)
+		if f.FileID == uploadResp.FileID {
+			wantRefs = 1
+		}
+		if f.RefCount != wantRefs || f.Orphaned != (wantRefs == 0) || f.CreatedBy == "" {
+			t.Fatalf("unexpected usage for %s: %+v", f.FileID, f)
+		}
+	}
+	if usage.Summary.OrphanedCount != 1 || usage.Summary.OrphanedBytes != int64(len("unused")) {
+		t.Fatalf("unexpected orphan summary: %+v", usage.Summary)
+	}
+
+	_, listBody = doJSON(t, ts, "GET", "/api/v3/files?orphaned=1", "admin-token", nil)
+	if err := json.Unmarshal(listBody, &usage); err != nil {
+		t.Fatalf("decode orphan listing: %v", err)
+	}
+	if len(usage.Files) != 1 || usage.Files[0].FileID == uploadResp.FileID {
+		t.Fatalf("orphaned filter returned %s", string(listBody))
+	}
+	_, listBody = doJSON(t, ts, "GET", "/api/v3/files?older_than_seconds=3600", "admin-token", nil)
+	if err := json.Unmarshal(listBody, &usage); err != nil {
+		t.Fatalf("decode aged listing: %v", err)
+	}
+	if len(usage.Files) != 0 {
+		t.Fatalf("older_than_seconds filter returned %s", string(listBody))
+	}
 }
 
 func TestEmergencyStopResumeEndpoints(t *testing.T) {
@@ -785,6 +854,12 @@ func main() {
 			writef("{\"method\":\"thread/started\",\"params\":{\"thread\":{\"id\":\"thr_api\"}}}")
 		case strings.Contains(line, "\"method\":\"status\""):
 			writef("{\"id\":\"%s\",\"result\":{\"state\":\"ready\",\"source\":\"fake-api\"}}", id)
+		case strings.Contains(line, "\"method\":\"turn/start\""):
+			writef("{\"id\":\"%s\",\"result\":{\"turn\":{\"id\":\"turn_1\",\"status\":\"inProgress\",\"threadId\":\"thr_api\"}}}", id)
+			writef("{\"method\":\"turn/started\",\"params\":{\"turn\":{\"id\":\"turn_1\",\"status\":\"inProgress\"}}}")
+			writef("{\"method\":\"item/agentMessage/delta\",\"params\":{\"turnId\":\"turn_0\",\"itemId\":\"itm_0\",\"delta\":\"other turn\"}}")
+			writef("{\"method\":\"item/agentMessage/delta\",\"params\":{\"turnId\":\"turn_1\",\"itemId\":\"itm_1\",\"delta\":\"streamed\"}}")
+			writef("{\"method\":\"turn/completed\",\"params\":{\"turn\":{\"id\":\"turn_1\",\"status\":\"completed\"}}}")
 		}
 	}
 }
@@ -903,3 +978,1464 @@ func doMultipart(
 	respBody, _ := io.ReadAll(resp.Body)
 	return resp.StatusCode, respBody
 }
+
+func TestPairLinkServesPayloadUntilPairCodeUsed(t *testing.T) {
+	ts := newTestServer(t, SecurityConfig{PublicBaseURL: "https://bridge.example/"})
+
+	startStatus, startBody := doJSON(t, ts, "POST", "/api/v3/pair/start", "admin-token", map[string]any{
+		"permissions": []string{auth.ScopeRunsRead},
+	})
+	if startStatus != http.StatusOK {
+		t.Fatalf("pair start status=%d body=%s", startStatus, string(startBody))
+	}
+	var startResp struct {
+		PairCode  string `json:"pair_code"`
+		Challenge string `json:"challenge"`
+		PairURL   string `json:"pair_url"`
+	}
+	if err := json.Unmarshal(startBody, &startResp); err != nil {
+		t.Fatalf("decode pair start: %v", err)
+	}
+	if !strings.HasPrefix(startResp.PairURL, "https://bridge.example/pair/") {
+		t.Fatalf("unexpected pair_url: %q", startResp.PairURL)
+	}
+	linkPath := strings.TrimPrefix(startResp.PairURL, "https://bridge.example")
+
+	linkStatus, linkBody := doJSON(t, ts, "GET", linkPath, "", nil)
+	if linkStatus != http.StatusOK {
+		t.Fatalf("pair link status=%d body=%s", linkStatus, string(linkBody))
+	}
+	var linkResp struct {
+		PairCode          string `json:"pair_code"`
+		Challenge         string `json:"challenge"`
+		BridgeFingerprint string `json:"bridge_fingerprint"`
+		ElixURI           string `json:"elix_uri"`
+	}
+	if err := json.Unmarshal(linkBody, &linkResp); err != nil {
+		t.Fatalf("decode pair link: %v", err)
+	}
+	if linkResp.PairCode != startResp.PairCode || linkResp.Challenge != startResp.Challenge || !strings.HasPrefix(linkResp.ElixURI, "elix://") {
+		t.Fatalf("unexpected pair link payload: %s", string(linkBody))
+	}
+	if !strings.HasPrefix(linkResp.BridgeFingerprint, "SHA256:") || !strings.Contains(linkResp.ElixURI, "fp="+url.QueryEscape(linkResp.BridgeFingerprint)) {
+		t.Fatalf("expected bridge fingerprint in pair link payload: %s", string(linkBody))
+	}
+
+	tampered := linkPath[:len(linkPath)-1] + "A"
+	if strings.HasSuffix(linkPath, "A") {
+		tampered = linkPath[:len(linkPath)-1] + "B"
+	}
+	if status, _ := doJSON(t, ts, "GET", tampered, "", nil); status != http.StatusNotFound {
+		t.Fatalf("expected tampered link to be rejected, got %d", status)
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	completeStatus, completeBody := doJSON(t, ts, "POST", "/api/v3/pair/complete", "", map[string]any{
+		"pair_code":  startResp.PairCode,
+		"public_key": base64.RawURLEncoding.EncodeToString(pub),
+		"signature":  base64.RawURLEncoding.EncodeToString(ed25519.Sign(priv, []byte(startResp.Challenge))),
+	})
+	if completeStatus != http.StatusOK {
+		t.Fatalf("pair complete status=%d body=%s", completeStatus, string(completeBody))
+	}
+	if status, _ := doJSON(t, ts, "GET", linkPath, "", nil); status != http.StatusNotFound {
+		t.Fatalf("expected link to stop working after pairing, got %d", status)
+	}
+}
+
+func TestPairQRServesPendingPairCodeImage(t *testing.T) {
+	ts := newTestServer(t, SecurityConfig{})
+
+	startStatus, startBody := doJSON(t, ts, "POST", "/api/v3/pair/start", "admin-token", map[string]any{
+		"permissions": []string{auth.ScopeRunsRead},
+	})
+	if startStatus != http.StatusOK {
+		t.Fatalf("pair start status=%d body=%s", startStatus, string(startBody))
+	}
+	var startResp struct {
+		PairCode string `json:"pair_code"`
+	}
+	if err := json.Unmarshal(startBody, &startResp); err != nil {
+		t.Fatalf("decode pair start: %v", err)
+	}
+	qrPath := "/api/v3/pair/" + startResp.PairCode + "/qr.png"
+
+	if status, _ := doJSON(t, ts, "GET", qrPath, "", nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected unauthenticated qr request to fail, got %d", status)
+	}
+	status, body := doJSON(t, ts, "GET", qrPath, "admin-token", nil)
+	if status != http.StatusOK {
+		t.Fatalf("qr status=%d body=%s", status, string(body))
+	}
+	if _, err := png.Decode(bytes.NewReader(body)); err != nil {
+		t.Fatalf("decode qr png: %v", err)
+	}
+	status, body = doJSON(t, ts, "GET", "/api/v3/pair/"+startResp.PairCode+"/qr.svg", "admin-token", nil)
+	if status != http.StatusOK || !strings.HasPrefix(string(body), "<svg") {
+		t.Fatalf("qr svg status=%d body=%.80s", status, string(body))
+	}
+	if status, _ := doJSON(t, ts, "GET", "/api/v3/pair/NOPE/qr.png", "admin-token", nil); status != http.StatusNotFound {
+		t.Fatalf("expected unknown pair code to 404, got %d", status)
+	}
+}
+
+func TestReadOnlyModeRejectsWrites(t *testing.T) {
+	startup := newTestServer(t, SecurityConfig{ReadOnly: true})
+	if status, body := doJSON(t, startup, "GET", "/healthz", "", nil); status != http.StatusOK || !strings.Contains(string(body), `"read_only":true`) {
+		t.Fatalf("expected BRIDGE_READ_ONLY to apply at startup, got %d %s", status, body)
+	}
+
+	ts := newTestServer(t)
+	token := issueAccessTokenForScopes(t, ts, []string{auth.ScopeRunsSubmit, auth.ScopeRunsRead, auth.ScopeDevicesRead})
+
+	status, body := doJSON(t, ts, "POST", "/api/v3/admin/read-only", "admin-token", map[string]any{"active": true, "reason": "restore"})
+	if status != http.StatusOK {
+		t.Fatalf("enable read-only status=%d body=%s", status, body)
+	}
+
+	status, body = doJSON(t, ts, "POST", "/api/v3/runs", token, map[string]any{
+		"workspace_id": "ws", "workspace_path": "/tmp", "backend": "codex", "prompt": "hi",
+	})
+	var rejected struct {
+		Error struct {
+			Code   string `json:"code"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(body, &rejected)
+	if status != http.StatusServiceUnavailable || rejected.Error.Code != "read_only" || rejected.Error.Reason != "restore" {
+		t.Fatalf("expected read_only rejection, got %d %s", status, body)
+	}
+	if status, body := doJSON(t, ts, "POST", "/api/v3/pair/start", "admin-token", map[string]any{}); status != http.StatusServiceUnavailable {
+		t.Fatalf("expected pair start rejected, got %d %s", status, body)
+	}
+	if status, body := doJSON(t, ts, "GET", "/api/v3/devices", token, nil); status != http.StatusOK {
+		t.Fatalf("expected reads to work, got %d %s", status, body)
+	}
+	if status, body := doJSON(t, ts, "GET", "/healthz", "", nil); status != http.StatusOK || !strings.Contains(string(body), `"read_only":true`) {
+		t.Fatalf("expected healthz to report read-only, got %d %s", status, body)
+	}
+
+	if status, body := doJSON(t, ts, "POST", "/api/v3/admin/read-only", token, map[string]any{"active": false}); status != http.StatusForbidden {
+		t.Fatalf("expected device token to be forbidden, got %d %s", status, body)
+	}
+	if status, body := doJSON(t, ts, "POST", "/api/v3/admin/read-only", "admin-token", map[string]any{"active": false}); status != http.StatusOK {
+		t.Fatalf("disable read-only status=%d body=%s", status, body)
+	}
+	if status, body := doJSON(t, ts, "POST", "/api/v3/runs", token, map[string]any{
+		"workspace_id": "ws", "workspace_path": "/tmp", "backend": "codex", "prompt": "hi",
+	}); status != http.StatusAccepted {
+		t.Fatalf("expected submit after read-only, got %d %s", status, body)
+	}
+}
+
+func TestPairStartRateLimitSurvivesRestart(t *testing.T) {
+	store, err := ledger.Open(filepath.Join(t.TempDir(), "api.db"))
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatalf("init ledger: %v", err)
+	}
+	reg := driver.NewRegistry()
+	reg.Register(&fakeAPIDriver{})
+	runSvc := run.NewService(store, reg, run.NewHub(), policy.New([]string{"/tmp"}), 30*time.Second, 4)
+	authSvc := auth.New(store, auth.Config{
+		AccessTokenTTL:  2 * time.Minute,
+		RefreshTokenTTL: 10 * time.Minute,
+		PairCodeTTL:     2 * time.Minute,
+	})
+	cfg := SecurityConfig{PairStartRateLimit: 1, PairStartRateWindow: 30 * time.Second}
+	payload := map[string]any{"permissions": []string{auth.ScopeRunsSubmit}}
+
+	first := httptest.NewServer(New("127.0.0.1:0", "admin-token", runSvc, nil, authSvc, cfg).httpServer.Handler)
+	defer first.Close()
+	if status, body := doJSON(t, first, "POST", "/api/v3/pair/start", "admin-token", payload); status != http.StatusOK {
+		t.Fatalf("first pair/start expected 200, got %d body=%s", status, string(body))
+	}
+
+	// A fresh server on the same ledger stands in for a restarted bridge.
+	restarted := httptest.NewServer(New("127.0.0.1:0", "admin-token", runSvc, nil, authSvc, cfg).httpServer.Handler)
+	defer restarted.Close()
+	if status, body := doJSON(t, restarted, "POST", "/api/v3/pair/start", "admin-token", payload); status != http.StatusTooManyRequests {
+		t.Fatalf("pair/start after restart expected 429, got %d body=%s", status, string(body))
+	}
+
+	cfg.RateLimitStore = "memory"
+	inMemory := httptest.NewServer(New("127.0.0.1:0", "admin-token", runSvc, nil, authSvc, cfg).httpServer.Handler)
+	defer inMemory.Close()
+	if status, body := doJSON(t, inMemory, "POST", "/api/v3/pair/start", "admin-token", payload); status != http.StatusOK {
+		t.Fatalf("memory store pair/start expected 200, got %d body=%s", status, string(body))
+	}
+}
+
+func TestSessionTurnStreamsOnlyItsEvents(t *testing.T) {
+	root := t.TempDir()
+	workspace := filepath.Join(root, "ws")
+	if err := os.MkdirAll(workspace, 0o755); err != nil {
+		t.Fatalf("mkdir workspace: %v", err)
+	}
+	ts := newTestServerWithSession(t, root, testSessionConfig(writeFakeCodexForAPI(t, root)))
+	accessToken := issueAccessTokenForScopes(t, ts, []string{auth.ScopeRunsSubmit, auth.ScopeRunsRead})
+	status, body := doJSON(t, ts, "POST", "/api/v3/sessions", accessToken, map[string]any{
+		"workspace_path": workspace,
+		"backend":        "codex",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("create session status=%d body=%s", status, string(body))
+	}
+	var created struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := json.Unmarshal(body, &created); err != nil {
+		t.Fatalf("decode session: %v", err)
+	}
+
+	status, body = doJSON(t, ts, "POST", "/api/v3/sessions/"+created.SessionID+"/turns?stream=true", accessToken, map[string]any{"prompt": "hi"})
+	if status != http.StatusOK {
+		t.Fatalf("streamed turn status=%d body=%s", status, string(body))
+	}
+	var names []string
+	var datas []string
+	for _, line := range strings.Split(string(body), "\n") {
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			names = append(names, strings.TrimPrefix(line, "event: "))
+		case strings.HasPrefix(line, "data: "):
+			datas = append(datas, strings.TrimPrefix(line, "data: "))
+		}
+	}
+	if len(names) < 3 || names[0] != "turn" || names[len(names)-1] != "done" {
+		t.Fatalf("unexpected event sequence %v:\n%s", names, string(body))
+	}
+	if !strings.Contains(datas[0], `"turn_id":"turn_1"`) || !strings.Contains(datas[len(datas)-1], `"status":"completed"`) {
+		t.Fatalf("unexpected turn/done payloads:\n%s", string(body))
+	}
+	if !strings.Contains(string(body), "streamed") || strings.Contains(string(body), "other turn") {
+		t.Fatalf("stream should carry only turn_1 events:\n%s", string(body))
+	}
+}
+
+func TestAdminTokensRotateBootstrapWithoutRestart(t *testing.T) {
+	ts := newTestServer(t)
+
+	status, body := doJSON(t, ts, "POST", "/api/v3/admin/tokens", "admin-token", map[string]any{
+		"name": "ci", "scopes": []string{auth.ScopeAdmin, auth.ScopePairStart, auth.ScopeBackendsRead, auth.ScopeDevicesRead, auth.ScopeDevicesWrite},
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("create admin token status=%d body=%s", status, body)
+	}
+	var ci auth.IssuedAdminToken
+	_ = json.Unmarshal(body, &ci)
+	if !strings.HasPrefix(ci.Token, "elixadm_") || ci.Status != "active" {
+		t.Fatalf("unexpected issued token: %s", body)
+	}
+	if status, body := doJSON(t, ts, "POST", "/api/v3/admin/tokens", "admin-token", map[string]any{"name": "ci"}); status != http.StatusConflict {
+		t.Fatalf("expected duplicate name rejected, got %d %s", status, body)
+	}
+	if status, body := doJSON(t, ts, "POST", "/api/v3/admin/tokens", ci.Token, map[string]any{"name": "bad", "scopes": []string{"root"}}); status != http.StatusBadRequest {
+		t.Fatalf("expected unknown scope rejected, got %d %s", status, body)
+	}
+
+	status, body = doJSON(t, ts, "GET", "/api/v3/admin/tokens", ci.Token, nil)
+	var list struct {
+		Items []auth.AdminToken `json:"items"`
+	}
+	_ = json.Unmarshal(body, &list)
+	bootstrapID := ""
+	for _, item := range list.Items {
+		if item.Name == auth.BootstrapTokenName && item.Status == "active" {
+			bootstrapID = item.ID
+		}
+	}
+	if status != http.StatusOK || bootstrapID == "" {
+		t.Fatalf("expected seeded bootstrap token in list, got %d %s", status, body)
+	}
+
+	status, body = doJSON(t, ts, "POST", "/api/v3/admin/tokens/"+bootstrapID+"/rotate", ci.Token, map[string]any{})
+	if status != http.StatusCreated {
+		t.Fatalf("rotate bootstrap status=%d body=%s", status, body)
+	}
+	var rotated auth.IssuedAdminToken
+	_ = json.Unmarshal(body, &rotated)
+	if status, body := doJSON(t, ts, "GET", "/api/v3/admin/tokens", "admin-token", nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected configured bootstrap token retired, got %d %s", status, body)
+	}
+	if status, body := doJSON(t, ts, "GET", "/api/v3/admin/tokens", rotated.Token, nil); status != http.StatusOK {
+		t.Fatalf("expected rotated bootstrap token accepted, got %d %s", status, body)
+	}
+
+	// A token without the admin scope may start pairings but is no
+	// operator, and tokens never get scopes their issuer lacks.
+	status, body = doJSON(t, ts, "POST", "/api/v3/admin/tokens", ci.Token, map[string]any{"name": "pairing", "scopes": []string{auth.ScopePairStart, auth.ScopeRunsSubmit}})
+	var pairing auth.IssuedAdminToken
+	_ = json.Unmarshal(body, &pairing)
+	if status != http.StatusCreated || strings.Join(pairing.Scopes, ",") != auth.ScopePairStart {
+		t.Fatalf("create pairing token: %d %s", status, body)
+	}
+	if status, body := doJSON(t, ts, "POST", "/api/v3/admin/tokens", ci.Token, map[string]any{"name": "runner", "scopes": []string{auth.ScopeRunsSubmit}}); status != http.StatusForbidden || !strings.Contains(string(body), "admin_token_scopes") {
+		t.Fatalf("expected scopes beyond the issuer's rejected, got %d %s", status, body)
+	}
+	if status, body := doJSON(t, ts, "POST", "/api/v3/pair/start", pairing.Token, map[string]any{}); status != http.StatusOK {
+		t.Fatalf("pairing token pair/start: %d %s", status, body)
+	}
+	for _, path := range []string{"/api/v3/admin/tokens", "/api/v3/admin/config", "/api/v3/emergency/status"} {
+		if status, body := doJSON(t, ts, "GET", path, pairing.Token, nil); status != http.StatusForbidden {
+			t.Fatalf("%s with pairing token: expected 403, got %d %s", path, status, body)
+		}
+	}
+	if status, body := doJSON(t, ts, "POST", "/api/v3/admin/tokens/"+ci.ID+"/rotate", pairing.Token, map[string]any{}); status != http.StatusForbidden {
+		t.Fatalf("expected rotate by non-operator rejected, got %d %s", status, body)
+	}
+
+	if status, body := doJSON(t, ts, "POST", "/api/v3/admin/tokens/"+ci.ID+"/revoke", rotated.Token, nil); status != http.StatusOK {
+		t.Fatalf("revoke status=%d body=%s", status, body)
+	}
+	if status, body := doJSON(t, ts, "GET", "/api/v3/devices", ci.Token, nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected revoked token rejected, got %d %s", status, body)
+	}
+}
+
+func TestScopeEscalationRequiresApprovalFromTrustedOperator(t *testing.T) {
+	ts := newTestServer(t)
+
+	if status, body := doJSON(t, ts, "POST", "/api/v3/pair/start", "admin-token", map[string]any{
+		"trust_level": auth.TrustObserver, "permissions": []string{auth.ScopeRunsSubmit},
+	}); status != http.StatusBadRequest {
+		t.Fatalf("expected scope beyond trust level rejected, got %d %s", status, body)
+	}
+
+	observer := issueAccessTokenForScopes(t, ts, auth.TrustScopes(auth.TrustObserver))
+	operator := issueAccessTokenForScopes(t, ts, auth.TrustScopes(auth.TrustOperator))
+	submit := map[string]any{"workspace_id": "ws", "workspace_path": "/tmp", "backend": "codex", "prompt": "hi"}
+	if status, body := doJSON(t, ts, "POST", "/api/v3/runs", observer, submit); status != http.StatusForbidden {
+		t.Fatalf("expected observer submit forbidden, got %d %s", status, body)
+	}
+
+	status, body := doJSON(t, ts, "POST", "/api/v3/scope-requests", observer, map[string]any{
+		"scopes": []string{auth.ScopeRunsSubmit}, "reason": "needs to run tasks",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("create scope request status=%d body=%s", status, body)
+	}
+	var pending auth.ScopeRequest
+	_ = json.Unmarshal(body, &pending)
+	if pending.Status != "pending" || pending.TrustLevel != auth.TrustOperator {
+		t.Fatalf("unexpected pending request: %s", body)
+	}
+	if status, body := doJSON(t, ts, "POST", "/api/v3/scope-requests", observer, map[string]any{"scopes": []string{auth.ScopePairStart}}); status != http.StatusConflict {
+		t.Fatalf("expected second pending request rejected, got %d %s", status, body)
+	}
+	if status, body := doJSON(t, ts, "POST", "/api/v3/scope-requests/"+pending.ID+"/approve", observer, nil); status != http.StatusForbidden {
+		t.Fatalf("expected self approval forbidden, got %d %s", status, body)
+	}
+
+	status, body = doJSON(t, ts, "POST", "/api/v3/scope-requests/"+pending.ID+"/approve", operator, map[string]any{"note": "ok"})
+	if status != http.StatusOK {
+		t.Fatalf("approve status=%d body=%s", status, body)
+	}
+	if status, body := doJSON(t, ts, "POST", "/api/v3/runs", observer, submit); status == http.StatusForbidden {
+		t.Fatalf("expected approved scope to apply to the active session, got %d %s", status, body)
+	}
+
+	status, body = doJSON(t, ts, "POST", "/api/v3/scope-requests", observer, map[string]any{"scopes": []string{auth.ScopePairStart}})
+	if status != http.StatusCreated {
+		t.Fatalf("create admin scope request status=%d body=%s", status, body)
+	}
+	var adminReq auth.ScopeRequest
+	_ = json.Unmarshal(body, &adminReq)
+	if status, body := doJSON(t, ts, "POST", "/api/v3/scope-requests/"+adminReq.ID+"/approve", operator, nil); status != http.StatusForbidden {
+		t.Fatalf("expected operator unable to grant admin tier, got %d %s", status, body)
+	}
+	if status, body := doJSON(t, ts, "POST", "/api/v3/scope-requests/"+adminReq.ID+"/deny", "admin-token", map[string]any{"note": "no"}); status != http.StatusOK {
+		t.Fatalf("deny status=%d body=%s", status, body)
+	}
+
+	status, body = doJSON(t, ts, "GET", "/api/v3/devices", "admin-token", nil)
+	var devices struct {
+		Devices []auth.DeviceView `json:"devices"`
+	}
+	_ = json.Unmarshal(body, &devices)
+	levels := map[string]int{}
+	for _, dev := range devices.Devices {
+		levels[dev.TrustLevel]++
+	}
+	if status != http.StatusOK || levels[auth.TrustOperator] != 2 {
+		t.Fatalf("expected both devices at operator tier, got %d %s", status, body)
+	}
+}
+
+func TestDigestsGenerateAndList(t *testing.T) {
+	ts := newTestServer(t, SecurityConfig{})
+	day := time.Now().UTC().Format("2006-01-02")
+
+	status, body := doJSON(t, ts, "POST", "/api/v3/digests/generate", "admin-token", map[string]any{"day": day})
+	if status != http.StatusOK {
+		t.Fatalf("generate status=%d body=%s", status, string(body))
+	}
+	status, body = doJSON(t, ts, "GET", "/api/v3/digests?principal=*&from="+day, "admin-token", nil)
+	if status != http.StatusOK {
+		t.Fatalf("list status=%d body=%s", status, string(body))
+	}
+	var resp struct {
+		Items []struct {
+			Day       string `json:"day"`
+			Principal string `json:"principal"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("decode digests: %v", err)
+	}
+	if len(resp.Items) != 1 || resp.Items[0].Principal != "*" || resp.Items[0].Day != day {
+		t.Fatalf("unexpected digests: %s", string(body))
+	}
+	if status, _ := doJSON(t, ts, "GET", "/api/v3/digests?from=today", "admin-token", nil); status != http.StatusBadRequest {
+		t.Fatalf("expected malformed day to be rejected, got %d", status)
+	}
+}
+
+func TestStatusPageShowsOnlyConfiguredFields(t *testing.T) {
+	ts := newTestServer(t)
+	if status, _ := doJSON(t, ts, "GET", "/status.json", "", nil); status != http.StatusNotFound {
+		t.Fatalf("expected status page to be off by default, got %d", status)
+	}
+
+	ts = newTestServer(t, SecurityConfig{StatusPage: StatusPageConfig{Enabled: true, Fields: []string{"backends", "queue"}}})
+	status, body := doJSON(t, ts, "GET", "/status.json", "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("expected unauthenticated status page, got %d: %s", status, body)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(body, &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out["status"] != "operational" || out["queue"] == nil {
+		t.Fatalf("unexpected status: %s", body)
+	}
+	if _, ok := out["emergency_stop"]; ok {
+		t.Fatalf("emergency_stop should be hidden: %s", body)
+	}
+	backends, _ := out["backends"].([]any)
+	if len(backends) != 1 || backends[0].(map[string]any)["state"] != "healthy" {
+		t.Fatalf("unexpected backends: %s", body)
+	}
+	if strings.Contains(string(body), "message") {
+		t.Fatalf("status page leaked health detail: %s", body)
+	}
+
+	status, body = doJSON(t, ts, "GET", "/status", "", nil)
+	if status != http.StatusOK || !strings.Contains(string(body), "Backend codex: healthy") {
+		t.Fatalf("unexpected html page %d: %s", status, body)
+	}
+}
+
+func TestPipelineEndpoints(t *testing.T) {
+	ts := newTestServer(t)
+	token := issueAccessTokenForScopes(t, ts, []string{auth.ScopeRunsSubmit, auth.ScopeRunsRead})
+	status, body := doJSON(t, ts, "POST", "/api/v3/pipelines", token, map[string]any{
+		"workspace_path": "/tmp",
+		"steps": []map[string]any{
+			{"id": "plan", "prompt": "plan"},
+			{"id": "build", "prompt": "build {{steps.plan.final_text}}"},
+		},
+	})
+	if status != http.StatusAccepted {
+		t.Fatalf("submit pipeline: %d %s", status, body)
+	}
+	var p run.Pipeline
+	if err := json.Unmarshal(body, &p); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(p.Steps) != 2 || p.Steps[0].RunID == "" || p.Steps[1].Status != run.StepPending {
+		t.Fatalf("unexpected pipeline: %s", body)
+	}
+	if status, body := doJSON(t, ts, "GET", "/api/v3/pipelines/"+p.ID, token, nil); status != http.StatusOK || !strings.Contains(string(body), p.Steps[0].RunID) {
+		t.Fatalf("get pipeline: %d %s", status, body)
+	}
+	status, body = doJSON(t, ts, "GET", "/api/v3/pipelines/missing", token, nil)
+	if status != http.StatusNotFound || !strings.Contains(string(body), "pipeline_not_found") {
+		t.Fatalf("expected pipeline_not_found, got %d %s", status, body)
+	}
+	status, body = doJSON(t, ts, "POST", "/api/v3/pipelines", token, map[string]any{
+		"workspace_path": "/tmp",
+		"steps":          []map[string]any{{"id": "a", "prompt": "{{steps.nope.output}}"}},
+	})
+	if status != http.StatusBadRequest {
+		t.Fatalf("expected unknown step reference to be rejected, got %d %s", status, body)
+	}
+}
+
+func TestMCPOverSSESubmitsRunAndReadsWorkspaceFile(t *testing.T) {
+	ts := newTestServer(t)
+	workspace, err := os.MkdirTemp("/tmp", "elix-mcp-")
+	if err != nil {
+		t.Fatalf("mkdir workspace: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(workspace) })
+	if err := os.WriteFile(filepath.Join(workspace, "hello.txt"), []byte("hi from mcp"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	token := issueAccessTokenForScopes(t, ts, []string{"runs:submit", "runs:read"})
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+mcpSSEPath, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("open sse: %v", err)
+	}
+	defer resp.Body.Close()
+	events := bufio.NewReader(resp.Body)
+	nextEvent := func() (string, string) {
+		t.Helper()
+		var event, data string
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatalf("read sse: %v", err)
+			}
+			line = strings.TrimRight(line, "\n")
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			case line == "" && event != "":
+				return event, data
+			}
+		}
+	}
+	event, endpoint := nextEvent()
+	if event != "endpoint" || !strings.HasPrefix(endpoint, mcpMessagesPath+"?session_id=") {
+		t.Fatalf("unexpected first event %q %q", event, endpoint)
+	}
+	callTool := func(bearer string, id int, name string, args map[string]any) (int, map[string]any) {
+		t.Helper()
+		status, _ := doJSON(t, ts, "POST", endpoint, bearer, map[string]any{
+			"jsonrpc": "2.0", "id": id, "method": "tools/call",
+			"params": map[string]any{"name": name, "arguments": args},
+		})
+		if status != http.StatusAccepted {
+			return status, nil
+		}
+		_, data := nextEvent()
+		var msg struct {
+			Result struct {
+				Content []struct {
+					Text string `json:"text"`
+				} `json:"content"`
+				IsError bool `json:"isError"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal([]byte(data), &msg); err != nil || len(msg.Result.Content) != 1 || msg.Result.IsError {
+			t.Fatalf("unexpected tool result for %s: %s", name, data)
+		}
+		var out map[string]any
+		_ = json.Unmarshal([]byte(msg.Result.Content[0].Text), &out)
+		return status, out
+	}
+
+	_, submitted := callTool(token, 1, "submit_run", map[string]any{
+		"workspace_id": "mcp-ws", "workspace_path": workspace, "backend": "codex", "prompt": "hello",
+	})
+	if submitted["run_id"] == "" || submitted["run_id"] == nil {
+		t.Fatalf("expected run id, got %#v", submitted)
+	}
+	_, file := callTool(token, 2, "read_workspace_file", map[string]any{"workspace_id": "mcp-ws", "path": "hello.txt"})
+	if file["content"] != "hi from mcp" || file["encoding"] != "utf-8" {
+		t.Fatalf("unexpected file: %#v", file)
+	}
+
+	readToken := issueAccessTokenForScopes(t, ts, []string{"runs:read"})
+	if status, _ := callTool(readToken, 3, "list_runs", nil); status != http.StatusNotFound {
+		t.Fatalf("expected another principal's stream to be hidden, got %d", status)
+	}
+}
+
+func TestRunShareLinkGrantsReadOnlyAccess(t *testing.T) {
+	ts := newTestServer(t, SecurityConfig{PublicBaseURL: "https://bridge.example"})
+	workspace, err := os.MkdirTemp("/tmp", "elix-share-")
+	if err != nil {
+		t.Fatalf("mkdir workspace: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(workspace) })
+
+	token := issueAccessTokenForScopes(t, ts, []string{"runs:submit", "runs:read"})
+	runStatus, runBody := doJSON(t, ts, "POST", "/api/v3/runs", token, map[string]any{
+		"workspace_id": "share-ws", "workspace_path": workspace, "backend": "codex", "prompt": "hello",
+	})
+	if runStatus != http.StatusAccepted {
+		t.Fatalf("submit status=%d body=%s", runStatus, string(runBody))
+	}
+	var submitted struct {
+		RunID string `json:"run_id"`
+	}
+	_ = json.Unmarshal(runBody, &submitted)
+
+	if status, _ := doJSON(t, ts, "POST", "/api/v3/runs/"+submitted.RunID+"/share", token, map[string]any{"ttl_seconds": 30 * 24 * 3600}); status != http.StatusBadRequest {
+		t.Fatalf("expected ttl over the maximum to be rejected, got %d", status)
+	}
+	status, body := doJSON(t, ts, "POST", "/api/v3/runs/"+submitted.RunID+"/share", token, map[string]any{"ttl_seconds": 600})
+	if status != http.StatusOK {
+		t.Fatalf("share status=%d body=%s", status, string(body))
+	}
+	var shared struct {
+		Token    string `json:"token"`
+		ShareURL string `json:"share_url"`
+	}
+	_ = json.Unmarshal(body, &shared)
+	if shared.ShareURL != "https://bridge.example/share/"+shared.Token {
+		t.Fatalf("unexpected share_url %q", shared.ShareURL)
+	}
+
+	status, body = doJSON(t, ts, "GET", "/api/v3/share/"+shared.Token, "", nil)
+	if status != http.StatusOK || !strings.Contains(string(body), submitted.RunID) {
+		t.Fatalf("shared run status=%d body=%s", status, string(body))
+	}
+	resp, err := http.Get(ts.URL + "/share/" + shared.Token)
+	if err != nil {
+		t.Fatalf("share page: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Fatalf("share page status=%d type=%q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/api/v3/share/"+shared.Token+"/events", nil)
+	if err != nil {
+		t.Fatalf("dial shared events: %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var ev map[string]any
+	if err := conn.ReadJSON(&ev); err != nil || ev["run_id"] != submitted.RunID {
+		t.Fatalf("expected shared run event, got %#v err=%v", ev, err)
+	}
+	conn.Close()
+
+	tampered := shared.Token[:len(shared.Token)-2] + "xx"
+	if status, _ := doJSON(t, ts, "GET", "/api/v3/share/"+tampered, "", nil); status != http.StatusNotFound {
+		t.Fatalf("expected tampered token to be rejected, got %d", status)
+	}
+	if status, _ := doJSON(t, ts, "GET", "/api/v3/runs/"+submitted.RunID, shared.Token, nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected share token to be refused by the API, got %d", status)
+	}
+}
+
+func TestDeviceSessionsCanBeRevokedIndividually(t *testing.T) {
+	ts := newTestServer(t)
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	pair := func() (string, string) {
+		t.Helper()
+		_, startBody := doJSON(t, ts, "POST", "/api/v3/pair/start", "admin-token", map[string]any{
+			"permissions": []string{auth.ScopeDevicesRead, auth.ScopeDevicesWrite},
+		})
+		var startResp struct {
+			PairCode  string `json:"pair_code"`
+			Challenge string `json:"challenge"`
+		}
+		_ = json.Unmarshal(startBody, &startResp)
+		status, body := doJSON(t, ts, "POST", "/api/v3/pair/complete", "", map[string]any{
+			"pair_code":  startResp.PairCode,
+			"public_key": base64.RawURLEncoding.EncodeToString(pub),
+			"signature":  base64.RawURLEncoding.EncodeToString(ed25519.Sign(priv, []byte(startResp.Challenge))),
+		})
+		if status != http.StatusOK {
+			t.Fatalf("pair complete status=%d body=%s", status, string(body))
+		}
+		var resp struct {
+			Address     string `json:"address"`
+			AccessToken string `json:"access_token"`
+		}
+		_ = json.Unmarshal(body, &resp)
+		return resp.Address, resp.AccessToken
+	}
+	address, phone := pair()
+	_, tablet := pair()
+
+	type sessionList struct {
+		Sessions []struct {
+			SessionID  string    `json:"session_id"`
+			ExpiresAt  time.Time `json:"expires_at"`
+			LastUsedAt time.Time `json:"last_used_at"`
+			Current    bool      `json:"current"`
+		} `json:"sessions"`
+	}
+	status, body := doJSON(t, ts, "GET", "/api/v3/devices/"+address+"/sessions", phone, nil)
+	if status != http.StatusOK {
+		t.Fatalf("list sessions status=%d body=%s", status, string(body))
+	}
+	var list sessionList
+	_ = json.Unmarshal(body, &list)
+	if len(list.Sessions) != 2 {
+		t.Fatalf("expected two sessions, got %s", string(body))
+	}
+	var tabletSession string
+	for _, sess := range list.Sessions {
+		if sess.ExpiresAt.IsZero() {
+			t.Fatalf("expected session expiry, got %s", string(body))
+		}
+		if sess.Current {
+			if sess.LastUsedAt.IsZero() {
+				t.Fatalf("expected current session to record its last use, got %s", string(body))
+			}
+		} else {
+			tabletSession = sess.SessionID
+		}
+	}
+	if tabletSession == "" {
+		t.Fatalf("expected one session to be marked current, got %s", string(body))
+	}
+
+	if status, _ := doJSON(t, ts, "DELETE", "/api/v3/devices/"+address+"/sessions/unknown", phone, nil); status != http.StatusNotFound {
+		t.Fatalf("expected unknown session to be 404, got %d", status)
+	}
+	if status, body := doJSON(t, ts, "DELETE", "/api/v3/devices/"+address+"/sessions/"+tabletSession, phone, nil); status != http.StatusOK {
+		t.Fatalf("revoke session status=%d body=%s", status, string(body))
+	}
+	if status, _ := doJSON(t, ts, "GET", "/api/v3/devices/"+address+"/sessions", tablet, nil); status != http.StatusUnauthorized {
+		t.Fatalf("expected revoked session to be signed out, got %d", status)
+	}
+	status, body = doJSON(t, ts, "GET", "/api/v3/devices/"+address+"/sessions", phone, nil)
+	list = sessionList{}
+	_ = json.Unmarshal(body, &list)
+	if status != http.StatusOK || len(list.Sessions) != 1 || !list.Sessions[0].Current {
+		t.Fatalf("expected the phone session to stay active, status=%d body=%s", status, string(body))
+	}
+}
+
+func TestRunSubmitIdempotencyKeyReplaysOriginalRun(t *testing.T) {
+	ts := newTestServer(t)
+	workspace, err := os.MkdirTemp("/tmp", "elix-idem-")
+	if err != nil {
+		t.Fatalf("mkdir workspace: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(workspace) })
+	token := issueAccessTokenForScopes(t, ts, []string{"runs:submit", "runs:read"})
+	submit := map[string]any{"workspace_id": "idem-ws", "workspace_path": workspace, "backend": "codex", "prompt": "hello"}
+	raw, _ := json.Marshal(submit)
+
+	type result struct {
+		status   int
+		runID    string
+		replayed bool
+		err      error
+	}
+	results := make(chan result, 8)
+	for i := 0; i < cap(results); i++ {
+		go func() {
+			req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/v3/runs", bytes.NewReader(raw))
+			req.Header.Set("Authorization", "Bearer "+token)
+			req.Header.Set("Idempotency-Key", "retry-1")
+			resp, err := ts.Client().Do(req)
+			if err != nil {
+				results <- result{err: err}
+				return
+			}
+			defer resp.Body.Close()
+			var body struct {
+				RunID    string `json:"run_id"`
+				Replayed bool   `json:"replayed"`
+			}
+			_ = json.NewDecoder(resp.Body).Decode(&body)
+			results <- result{status: resp.StatusCode, runID: body.RunID, replayed: body.Replayed}
+		}()
+	}
+	runIDs := map[string]bool{}
+	fresh := 0
+	for i := 0; i < cap(results); i++ {
+		res := <-results
+		switch {
+		case res.err != nil:
+			t.Fatalf("submit: %v", res.err)
+		case res.status == http.StatusAccepted:
+			runIDs[res.runID] = true
+			if !res.replayed {
+				fresh++
+			}
+		case res.status != http.StatusConflict:
+			t.Fatalf("unexpected concurrent submit status %d", res.status)
+		}
+	}
+	if len(runIDs) != 1 || fresh != 1 {
+		t.Fatalf("expected one run from concurrent retries, got runs=%v fresh=%d", runIDs, fresh)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/v3/runs", bytes.NewReader(raw))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Idempotency-Key", "retry-1")
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	var replay struct {
+		RunID    string `json:"run_id"`
+		Replayed bool   `json:"replayed"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&replay)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || !replay.Replayed || !runIDs[replay.RunID] || resp.Header.Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("expected replay of the original run, status=%d body=%+v", resp.StatusCode, replay)
+	}
+
+	submit["client_request_id"] = "retry-1"
+	submit["prompt"] = "something else"
+	if status, body := doJSON(t, ts, "POST", "/api/v3/runs", token, submit); status != http.StatusUnprocessableEntity || !strings.Contains(string(body), "idempotency_key_reused") {
+		t.Fatalf("expected reused key to be rejected, status=%d body=%s", status, string(body))
+	}
+}
+
+func TestSystemStatusRequiresOperatorAndReportsBridgeState(t *testing.T) {
+	ts := newTestServer(t, SecurityConfig{Version: "v9.9.9"})
+	token := issueAccessTokenForScopes(t, ts, []string{"runs:read", "backends:read"})
+	if status, _ := doJSON(t, ts, "GET", "/api/v3/system/status", token, nil); status != http.StatusForbidden {
+		t.Fatalf("expected paired device to be refused, got %d", status)
+	}
+	status, body := doJSON(t, ts, "GET", "/api/v3/system/status", "admin-token", nil)
+	if status != http.StatusOK {
+		t.Fatalf("system status=%d body=%s", status, string(body))
+	}
+	var out struct {
+		Bridge struct {
+			Version string `json:"version"`
+			Leader  bool   `json:"leader"`
+		} `json:"bridge"`
+		Backends []struct {
+			Name  string `json:"name"`
+			State string `json:"state"`
+		} `json:"backends"`
+		Emergency struct {
+			Active bool `json:"active"`
+		} `json:"emergency"`
+		Quotas struct {
+			Enforcement string `json:"enforcement"`
+		} `json:"quotas"`
+		Ledger struct {
+			Backend   string `json:"backend"`
+			SizeBytes int64  `json:"size_bytes"`
+		} `json:"ledger"`
+		Storage struct {
+			FileStoreDir string `json:"file_store_dir"`
+		} `json:"storage"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.Bridge.Version != "v9.9.9" || !out.Bridge.Leader || out.Ledger.Backend != "sqlite" || out.Ledger.SizeBytes <= 0 {
+		t.Fatalf("unexpected bridge/ledger status: %s", string(body))
+	}
+	if len(out.Backends) != 1 || out.Backends[0].Name != "codex" || out.Backends[0].State == "" {
+		t.Fatalf("unexpected backends: %s", string(body))
+	}
+	if out.Emergency.Active || out.Quotas.Enforcement != "off" || out.Storage.FileStoreDir == "" {
+		t.Fatalf("unexpected emergency/quota/storage status: %s", string(body))
+	}
+}
+
+func TestAdminConfigReloadAppliesReloadableSettingsFromFile(t *testing.T) {
+	store, err := ledger.Open(filepath.Join(t.TempDir(), "api.db"))
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatalf("init ledger: %v", err)
+	}
+	p := policy.New([]string{"/tmp"})
+	runSvc := run.NewService(store, driver.NewRegistry(), run.NewHub(), p, 30*time.Second, 4)
+	s := New("127.0.0.1:0", "admin-token", runSvc, nil, nil)
+	ts := httptest.NewServer(s.httpServer.Handler)
+	t.Cleanup(ts.Close)
+
+	file := filepath.Join(t.TempDir(), "bridge.yaml")
+	writeFile := func(body string) {
+		if err := os.WriteFile(file, []byte(body), 0o600); err != nil {
+			t.Fatalf("write config file: %v", err)
+		}
+	}
+	writeFile("workspace_roots: [/tmp]\n")
+	t.Setenv("BRIDGE_CONFIG_FILE", file)
+	t.Setenv("WORKSPACE_ROOTS", "")
+	t.Setenv("BRIDGE_HTTP_ADDR", "")
+	t.Setenv("BRIDGE_AUTH_TOKEN", "")
+	if status, _ := doJSON(t, ts, "POST", "/api/v3/admin/config/reload", "admin-token", nil); status != http.StatusNotFound {
+		t.Fatalf("expected reload to be unavailable before it is enabled, got %d", status)
+	}
+	s.SetEffectiveConfig(config.Load().Settings)
+	s.EnableConfigReload(p, nil)
+
+	roots := t.TempDir()
+	writeFile("# reloaded\nworkspace_roots: [/tmp, \"" + roots + "\"]\nBRIDGE_HTTP_ADDR = \":9999\"\nBRIDGE_AUTH_TOKEN: 'file-secret'\n")
+	status, body := doJSON(t, ts, "POST", "/api/v3/admin/config/reload", "admin-token", nil)
+	if status != http.StatusOK {
+		t.Fatalf("reload status=%d body=%s", status, string(body))
+	}
+	var out ConfigReloadResult
+	if err := json.Unmarshal(body, &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(out.Applied) != 1 || out.Applied[0].Key != "WORKSPACE_ROOTS" {
+		t.Fatalf("unexpected applied changes: %s", string(body))
+	}
+	if len(out.RestartRequired) != 2 || out.RestartRequired[0].Key != "BRIDGE_AUTH_TOKEN" || out.RestartRequired[0].To != "********" {
+		t.Fatalf("unexpected restart-required changes: %s", string(body))
+	}
+	if got := p.Roots(); len(got) != 2 || got[1] != roots {
+		t.Fatalf("workspace roots not reloaded: %#v", got)
+	}
+
+	status, body = doJSON(t, ts, "GET", "/api/v3/admin/config", "admin-token", nil)
+	if status != http.StatusOK {
+		t.Fatalf("config status=%d body=%s", status, string(body))
+	}
+	var effective struct {
+		Settings []config.Setting `json:"settings"`
+	}
+	if err := json.Unmarshal(body, &effective); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	for _, st := range effective.Settings {
+		switch st.Key {
+		case "WORKSPACE_ROOTS":
+			if st.Source != config.SourceFile || !st.Reloadable {
+				t.Fatalf("unexpected WORKSPACE_ROOTS setting: %#v", st)
+			}
+		case "BRIDGE_HTTP_ADDR":
+			if st.Value != ":8765" {
+				t.Fatalf("expected the running listen address to be reported, got %#v", st)
+			}
+		}
+	}
+
+	writeFile("workspace_roots: [/tmp]\nworkspace_rots: [/]\n")
+	if status, body := doJSON(t, ts, "POST", "/api/v3/admin/config/reload", "admin-token", nil); status != http.StatusUnprocessableEntity || !strings.Contains(string(body), "WORKSPACE_ROTS") {
+		t.Fatalf("expected a typo in the file to fail the reload, got %d %s", status, string(body))
+	}
+	if got := p.Roots(); len(got) != 2 {
+		t.Fatalf("failed reload changed workspace roots: %#v", got)
+	}
+}
+
+func TestWorkspacePolicyIsEnforcedOnRunSubmit(t *testing.T) {
+	store, err := ledger.Open(filepath.Join(t.TempDir(), "api.db"))
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatalf("init ledger: %v", err)
+	}
+	reg := driver.NewRegistry()
+	reg.Register(&fakeAPIDriver{})
+	p := policy.New([]string{"/tmp"})
+	runSvc := run.NewService(store, reg, run.NewHub(), p, 30*time.Second, 4)
+	p.SetWorkspaceRuleLookup(runSvc.StoredWorkspaceRules)
+	authSvc := auth.New(store, auth.Config{AccessTokenTTL: 2 * time.Minute, RefreshTokenTTL: 10 * time.Minute, PairCodeTTL: 2 * time.Minute})
+	ts := httptest.NewServer(New("127.0.0.1:0", "admin-token", runSvc, nil, authSvc).httpServer.Handler)
+	t.Cleanup(ts.Close)
+	token := issueAccessTokenForScopes(t, ts, []string{"runs:submit", "runs:read"})
+
+	if status, _ := doJSON(t, ts, "PUT", "/api/v3/workspaces/ws-locked/policy", token, map[string]any{"read_only": true}); status != http.StatusForbidden {
+		t.Fatalf("expected paired device to be refused, got %d", status)
+	}
+	if status, body := doJSON(t, ts, "PUT", "/api/v3/workspaces/ws-locked/policy", "admin-token", map[string]any{"sandboxes": []string{"root"}}); status != http.StatusBadRequest {
+		t.Fatalf("expected invalid sandbox to be rejected, got %d %s", status, string(body))
+	}
+	if status, body := doJSON(t, ts, "PUT", "/api/v3/workspaces/ws-locked/policy", "admin-token", map[string]any{"read_only": true}); status != http.StatusBadRequest || !strings.Contains(string(body), "workspace_path is required") {
+		t.Fatalf("expected a policy for a workspace without runs to need a path, got %d %s", status, string(body))
+	}
+	if status, body := doJSON(t, ts, "PUT", "/api/v3/workspaces/ws-locked/policy", "admin-token", map[string]any{"workspace_path": "/tmp", "read_only": true, "readonly": false}); status != http.StatusBadRequest {
+		t.Fatalf("expected an unknown field to be rejected, got %d %s", status, string(body))
+	}
+	status, body := doJSON(t, ts, "PUT", "/api/v3/workspaces/ws-locked/policy", "admin-token", map[string]any{"workspace_path": "/tmp", "read_only": true, "backends": []string{"codex"}})
+	if status != http.StatusOK || !strings.Contains(string(body), `"read_only":true`) || !strings.Contains(string(body), `"workspace_path":"`+policy.CanonicalPath("/tmp")+`"`) {
+		t.Fatalf("put policy status=%d body=%s", status, string(body))
+	}
+
+	submit := func(sandbox string) (int, []byte) {
+		return doJSON(t, ts, "POST", "/api/v3/runs", token, map[string]any{
+			"workspace_id":   "ws-locked",
+			"workspace_path": "/tmp",
+			"backend":        "codex",
+			"prompt":         "hello",
+			"options":        map[string]any{"sandbox": sandbox},
+		})
+	}
+	if status, body := submit("workspace-write"); status != http.StatusBadRequest || !strings.Contains(string(body), "policy_violation") {
+		t.Fatalf("expected workspace-write to be refused, got %d %s", status, string(body))
+	}
+	// The rule follows the path, so another workspace ID does not escape it.
+	if status, body := doJSON(t, ts, "POST", "/api/v3/runs", token, map[string]any{
+		"workspace_id":   "ws-renamed",
+		"workspace_path": "/tmp",
+		"backend":        "codex",
+		"prompt":         "hello",
+		"options":        map[string]any{"sandbox": "workspace-write"},
+	}); status != http.StatusBadRequest || !strings.Contains(string(body), "policy_violation") {
+		t.Fatalf("expected a renamed workspace ID to stay under the rule, got %d %s", status, string(body))
+	}
+	status, body = submit("")
+	if status != http.StatusAccepted && status != http.StatusOK {
+		t.Fatalf("submit status=%d body=%s", status, string(body))
+	}
+	var created struct {
+		RunID string `json:"run_id"`
+	}
+	_ = json.Unmarshal(body, &created)
+	obj, err := runSvc.GetRun(context.Background(), created.RunID)
+	if err != nil || obj.Options.Sandbox != policy.SandboxReadOnly {
+		t.Fatalf("expected the read-only sandbox to be applied, got %#v err=%v", obj.Options, err)
+	}
+
+	if status, _ := doJSON(t, ts, "DELETE", "/api/v3/workspaces/ws-locked/policy", "admin-token", nil); status != http.StatusOK {
+		t.Fatalf("delete policy status=%d", status)
+	}
+	if status, body := doJSON(t, ts, "GET", "/api/v3/workspaces/ws-locked/policy", "admin-token", nil); status != http.StatusNotFound || !strings.Contains(string(body), "workspace_policy_not_found") {
+		t.Fatalf("expected deleted policy to be gone, got %d %s", status, string(body))
+	}
+}
+
+func TestRunEventsPagesOverPlainHTTP(t *testing.T) {
+	store, err := ledger.Open(filepath.Join(t.TempDir(), "api.db"))
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatalf("init ledger: %v", err)
+	}
+	reg := driver.NewRegistry()
+	reg.Register(&fakeAPIDriver{})
+	runSvc := run.NewService(store, reg, run.NewHub(), policy.New([]string{"/tmp"}), 30*time.Second, 4)
+	authSvc := auth.New(store, auth.Config{AccessTokenTTL: 2 * time.Minute, RefreshTokenTTL: 10 * time.Minute, PairCodeTTL: 2 * time.Minute})
+	ts := httptest.NewServer(New("127.0.0.1:0", "admin-token", runSvc, nil, authSvc).httpServer.Handler)
+	t.Cleanup(ts.Close)
+	token := issueAccessTokenForScopes(t, ts, []string{"runs:submit", "runs:read"})
+
+	status, body := doJSON(t, ts, "POST", "/api/v3/runs", token, map[string]any{
+		"workspace_id":   "ws-pages",
+		"workspace_path": "/tmp",
+		"backend":        "codex",
+		"prompt":         "hello",
+	})
+	if status != http.StatusAccepted {
+		t.Fatalf("submit status=%d body=%s", status, string(body))
+	}
+	var created struct {
+		RunID string `json:"run_id"`
+	}
+	_ = json.Unmarshal(body, &created)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		obj, _ := runSvc.GetRun(context.Background(), created.RunID)
+		if obj.Status == run.StatusCompleted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("run did not complete: %#v", obj)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	next, err := store.NextSeq(context.Background(), created.RunID)
+	if err != nil {
+		t.Fatalf("next seq: %v", err)
+	}
+	var extra []events.Event
+	for i := 0; i < 10; i++ {
+		ev := events.NewEvent(events.TokenPayload{Text: "x"})
+		ev.RunID, ev.Seq, ev.TS = created.RunID, next+int64(i), time.Now().UTC()
+		extra = append(extra, ev)
+	}
+	if err := store.AppendEvents(context.Background(), extra); err != nil {
+		t.Fatalf("append events: %v", err)
+	}
+	maxSeq := next + 9
+
+	get := func(query string) (*http.Response, run.EventPage) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/v3/runs/"+created.RunID+"/events"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("get events: %v", err)
+		}
+		defer resp.Body.Close()
+		var page run.EventPage
+		_ = json.NewDecoder(resp.Body).Decode(&page)
+		return resp, page
+	}
+
+	resp, page := get("?limit=4")
+	if resp.StatusCode != http.StatusOK || len(page.Events) != 4 || page.Events[0].Seq != 1 || page.NextFromSeq != 5 {
+		t.Fatalf("unexpected first page: %d %#v", resp.StatusCode, page)
+	}
+	if resp.Header.Get("X-Max-Seq") != strconv.FormatInt(maxSeq, 10) || resp.Header.Get("X-Total-Count") != strconv.FormatInt(page.Total, 10) || page.Total != maxSeq {
+		t.Fatalf("unexpected totals: %v %#v", resp.Header, page)
+	}
+	if _, page := get("?limit=4&from_seq=5&offset=2"); len(page.Events) != 4 || page.Events[0].Seq != 7 {
+		t.Fatalf("unexpected offset page: %#v", page)
+	}
+	_, page = get("?order=desc&limit=3")
+	if len(page.Events) != 3 || page.Events[0].Seq != maxSeq || page.NextBeforeSeq != maxSeq-2 {
+		t.Fatalf("unexpected newest page: %#v", page)
+	}
+	if _, older := get("?order=desc&limit=3&before_seq=" + strconv.FormatInt(page.NextBeforeSeq, 10)); len(older.Events) != 3 || older.Events[0].Seq != maxSeq-3 {
+		t.Fatalf("unexpected older page: %#v", older)
+	}
+	if _, last := get("?from_seq=" + strconv.FormatInt(maxSeq, 10)); len(last.Events) != 1 || last.NextFromSeq != 0 {
+		t.Fatalf("expected final page without cursor: %#v", last)
+	}
+	for _, bad := range []string{"?order=sideways", "?limit=5000", "?before_seq=3", "?offset=-1"} {
+		if resp, _ := get(bad); resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", bad, resp.StatusCode)
+		}
+	}
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/v3/runs/missing/events", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for missing run, got %v %v", resp, err)
+	}
+}
+
+func TestRunEventsLongPollOverHTTP(t *testing.T) {
+	store, err := ledger.Open(filepath.Join(t.TempDir(), "api.db"))
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatalf("init ledger: %v", err)
+	}
+	reg := driver.NewRegistry()
+	reg.Register(&fakeAPIDriver{})
+	hub := run.NewHub()
+	runSvc := run.NewService(store, reg, hub, policy.New([]string{"/tmp"}), 30*time.Second, 4)
+	authSvc := auth.New(store, auth.Config{AccessTokenTTL: 2 * time.Minute, RefreshTokenTTL: 10 * time.Minute, PairCodeTTL: 2 * time.Minute})
+	ts := httptest.NewServer(New("127.0.0.1:0", "admin-token", runSvc, nil, authSvc).httpServer.Handler)
+	t.Cleanup(ts.Close)
+	token := issueAccessTokenForScopes(t, ts, []string{"runs:submit", "runs:read"})
+
+	status, body := doJSON(t, ts, "POST", "/api/v3/runs", token, map[string]any{
+		"workspace_id":   "ws-poll",
+		"workspace_path": "/tmp",
+		"backend":        "codex",
+		"prompt":         "hello",
+	})
+	if status != http.StatusAccepted {
+		t.Fatalf("submit status=%d body=%s", status, string(body))
+	}
+	var created struct {
+		RunID string `json:"run_id"`
+	}
+	_ = json.Unmarshal(body, &created)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		obj, _ := runSvc.GetRun(context.Background(), created.RunID)
+		if obj.Status == run.StatusCompleted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("run did not complete: %#v", obj)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	type pollResp struct {
+		Events      []events.Event `json:"events"`
+		NextFromSeq int64          `json:"next_from_seq"`
+	}
+	poll := func(query string) (int, pollResp) {
+		t.Helper()
+		status, body := doJSON(t, ts, "GET", "/api/v3/runs/"+created.RunID+"/events?transport=poll"+query, token, nil)
+		var out pollResp
+		_ = json.Unmarshal(body, &out)
+		return status, out
+	}
+	status, first := poll("")
+	if status != http.StatusOK || len(first.Events) == 0 || first.NextFromSeq != first.Events[len(first.Events)-1].Seq+1 {
+		t.Fatalf("unexpected replay: %d %#v", status, first)
+	}
+	next := strconv.FormatInt(first.NextFromSeq, 10)
+	if _, empty := poll("&from_seq=" + next); len(empty.Events) != 0 || empty.NextFromSeq != first.NextFromSeq {
+		t.Fatalf("expected an empty batch without wait, got %#v", empty)
+	}
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		ev := events.NewEvent(events.TokenPayload{Text: "late"})
+		ev.RunID, ev.Seq, ev.TS = created.RunID, first.NextFromSeq, time.Now().UTC()
+		hub.Publish(ev)
+	}()
+	started := time.Now()
+	_, waited := poll("&from_seq=" + next + "&wait=5")
+	if len(waited.Events) != 1 || waited.Events[0].Seq != first.NextFromSeq || waited.NextFromSeq != first.NextFromSeq+1 {
+		t.Fatalf("expected the late event, got %#v", waited)
+	}
+	if elapsed := time.Since(started); elapsed < 150*time.Millisecond || elapsed > 4*time.Second {
+		t.Fatalf("expected the poll to return on the event, took %v", elapsed)
+	}
+	for _, bad := range []string{"&wait=31", "&from_seq=-1"} {
+		if status, _ := poll(bad); status != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", bad, status)
+		}
+	}
+}
+
+func TestRunRawLogRequiresOperatorAndCapturingBackend(t *testing.T) {
+	store, err := ledger.Open(filepath.Join(t.TempDir(), "api.db"))
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatalf("init ledger: %v", err)
+	}
+	reg := driver.NewRegistry()
+	reg.Register(&fakeAPIDriver{})
+	runSvc := run.NewService(store, reg, run.NewHub(), policy.New([]string{"/tmp"}), 30*time.Second, 4)
+	authSvc := auth.New(store, auth.Config{AccessTokenTTL: 2 * time.Minute, RefreshTokenTTL: 10 * time.Minute, PairCodeTTL: 2 * time.Minute})
+	ts := httptest.NewServer(New("127.0.0.1:0", "admin-token", runSvc, nil, authSvc).httpServer.Handler)
+	t.Cleanup(ts.Close)
+	token := issueAccessTokenForScopes(t, ts, []string{"runs:submit", "runs:read"})
+
+	status, body := doJSON(t, ts, "POST", "/api/v3/runs", token, map[string]any{
+		"workspace_id":   "ws-raw",
+		"workspace_path": "/tmp",
+		"backend":        "codex",
+		"prompt":         "hello",
+	})
+	if status != http.StatusAccepted {
+		t.Fatalf("submit status=%d body=%s", status, string(body))
+	}
+	var created struct {
+		RunID string `json:"run_id"`
+	}
+	_ = json.Unmarshal(body, &created)
+
+	if status, body := doJSON(t, ts, "GET", "/api/v3/runs/"+created.RunID+"/raw-log", token, nil); status != http.StatusForbidden {
+		t.Fatalf("device raw-log status=%d body=%s", status, string(body))
+	}
+	status, body = doJSON(t, ts, "GET", "/api/v3/runs/"+created.RunID+"/raw-log?max_bytes=1024", "admin-token", nil)
+	if status != http.StatusNotImplemented || !strings.Contains(string(body), "raw_log_unsupported") {
+		t.Fatalf("raw-log status=%d body=%s", status, string(body))
+	}
+	if status, body := doJSON(t, ts, "GET", "/api/v3/runs/missing/raw-log", "admin-token", nil); status != http.StatusNotFound {
+		t.Fatalf("missing run raw-log status=%d body=%s", status, string(body))
+	}
+	if status, body := doJSON(t, ts, "GET", "/api/v3/runs/"+created.RunID+"/raw-log?max_bytes=0", "admin-token", nil); status != http.StatusBadRequest {
+		t.Fatalf("bad max_bytes status=%d body=%s", status, string(body))
+	}
+}
+
+func TestToolRegistrationIsLimitedToOwnSessions(t *testing.T) {
+	root := t.TempDir()
+	workspace := filepath.Join(root, "ws")
+	if err := os.MkdirAll(workspace, 0o755); err != nil {
+		t.Fatalf("mkdir workspace: %v", err)
+	}
+	ts := newTestServerWithSession(t, root, testSessionConfig(writeFakeCodexForAPI(t, root)))
+	owner := issueAccessTokenForScopes(t, ts, []string{auth.ScopeRunsSubmit, auth.ScopeRunsRead})
+	other := issueAccessTokenForScopes(t, ts, []string{auth.ScopeRunsSubmit, auth.ScopeRunsRead})
+
+	status, body := doJSON(t, ts, "POST", "/api/v3/sessions", owner, map[string]any{"workspace_path": workspace, "backend": "codex"})
+	if status != http.StatusCreated {
+		t.Fatalf("create session status=%d body=%s", status, body)
+	}
+	var sess struct {
+		SessionID string `json:"session_id"`
+		CreatedBy string `json:"created_by"`
+	}
+	if err := json.Unmarshal(body, &sess); err != nil || sess.CreatedBy == "" {
+		t.Fatalf("session = %s, %v", body, err)
+	}
+	tool := func(sessionID string) map[string]any {
+		return map[string]any{"name": "lookup", "callback_url": "https://tools.example/lookup", "session_id": sessionID}
+	}
+
+	if status, body := doJSON(t, ts, "POST", "/api/v3/tools", owner, tool("")); status != http.StatusForbidden {
+		t.Fatalf("device registering a global tool: status=%d body=%s", status, body)
+	}
+	if status, body := doJSON(t, ts, "POST", "/api/v3/tools", other, tool(sess.SessionID)); status != http.StatusForbidden {
+		t.Fatalf("tool for another device's session: status=%d body=%s", status, body)
+	}
+	if status, body := doJSON(t, ts, "POST", "/api/v3/tools", owner, tool(sess.SessionID)); status != http.StatusCreated {
+		t.Fatalf("tool for own session: status=%d body=%s", status, body)
+	}
+
+	if status, body := doJSON(t, ts, "POST", "/api/v3/tools", "admin-token", tool("")); status != http.StatusCreated {
+		t.Fatalf("operator global tool: status=%d body=%s", status, body)
+	}
+	if status, body := doJSON(t, ts, "DELETE", "/api/v3/tools/lookup", owner, nil); status != http.StatusForbidden {
+		t.Fatalf("device deleting the global tool: status=%d body=%s", status, body)
+	}
+	if status, body := doJSON(t, ts, "DELETE", "/api/v3/tools/lookup?session_id="+sess.SessionID, owner, nil); status != http.StatusOK {
+		t.Fatalf("owner deleting its tool: status=%d body=%s", status, body)
+	}
+}
+
+func TestSessionLockBoundsTTLAndGuardsBackendCalls(t *testing.T) {
+	root := t.TempDir()
+	workspace := filepath.Join(root, "ws")
+	if err := os.MkdirAll(workspace, 0o755); err != nil {
+		t.Fatalf("mkdir workspace: %v", err)
+	}
+	ts := newTestServerWithSession(t, root, testSessionConfig(writeFakeCodexForAPI(t, root)))
+	holder := issueAccessTokenForScopes(t, ts, []string{auth.ScopeRunsSubmit, auth.ScopeRunsRead})
+	other := issueAccessTokenForScopes(t, ts, []string{auth.ScopeRunsSubmit, auth.ScopeRunsRead})
+
+	status, body := doJSON(t, ts, "POST", "/api/v3/sessions", holder, map[string]any{"workspace_path": workspace, "backend": "codex"})
+	if status != http.StatusCreated {
+		t.Fatalf("create session status=%d body=%s", status, body)
+	}
+	var sess struct {
+		SessionID string `json:"session_id"`
+	}
+	_ = json.Unmarshal(body, &sess)
+	lockPath := "/api/v3/sessions/" + sess.SessionID + "/lock"
+
+	if status, body := doJSON(t, ts, "POST", lockPath, holder, map[string]any{"ttl_seconds": 30 * 24 * 3600}); status != http.StatusBadRequest {
+		t.Fatalf("month-long lock: status=%d body=%s", status, body)
+	}
+	if status, body := doJSON(t, ts, "POST", lockPath, holder, map[string]any{"ttl_seconds": 60}); status != http.StatusOK {
+		t.Fatalf("acquire lock: status=%d body=%s", status, body)
+	}
+	if status, body := doJSON(t, ts, "POST", "/api/v3/sessions/"+sess.SessionID+"/backend/call", other, map[string]any{"method": "status"}); status != http.StatusConflict || !strings.Contains(string(body), "session_locked") {
+		t.Fatalf("backend call by non-holder: status=%d body=%s", status, body)
+	}
+	if status, body := doJSON(t, ts, "POST", "/api/v3/sessions/"+sess.SessionID+"/backend/call", holder, map[string]any{"method": "status"}); status != http.StatusOK {
+		t.Fatalf("backend call by holder: status=%d body=%s", status, body)
+	}
+}
+
+type slowHealthDriver struct {
+	fakeAPIDriver
+	entered chan struct{}
+	release chan struct{}
+	ctxErr  chan error
+}
+
+func (d *slowHealthDriver) Health(ctx context.Context) (driver.Health, error) {
+	d.entered <- struct{}{}
+	<-d.release
+	d.ctxErr <- ctx.Err()
+	return driver.Health{OK: true}, nil
+}
+
+func TestStatusPageProbesOutsideTheLockOnItsOwnDeadline(t *testing.T) {
+	store, err := ledger.Open(filepath.Join(t.TempDir(), "api.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	d := &slowHealthDriver{entered: make(chan struct{}, 2), release: make(chan struct{}), ctxErr: make(chan error, 2)}
+	reg := driver.NewRegistry()
+	reg.Register(d)
+	p := newStatusPage(StatusPageConfig{Enabled: true}, run.NewService(store, reg, run.NewHub(), policy.New([]string{"/tmp"}), 30*time.Second, 4))
+
+	// A request that went away does not cancel the probe it started.
+	reqCtx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/status.json", nil).WithContext(reqCtx)
+	first := make(chan struct{})
+	go func() {
+		p.handle(httptest.NewRecorder(), req)
+		close(first)
+	}()
+	<-d.entered
+	cancel()
+	close(d.release)
+	<-first
+	if err := <-d.ctxErr; err != nil {
+		t.Fatalf("probe saw %v after the request was cancelled", err)
+	}
+
+	// While a stale snapshot is refreshed, other requests get the stale one.
+	p.mu.Lock()
+	p.cachedAt = time.Time{}
+	p.mu.Unlock()
+	d.release = make(chan struct{})
+	refreshed := make(chan map[string]any)
+	go func() { refreshed <- p.snapshot() }()
+	<-d.entered
+	done := make(chan map[string]any)
+	go func() { done <- p.snapshot() }()
+	select {
+	case out := <-done:
+		if out["status"] != "operational" {
+			t.Fatalf("unexpected stale snapshot: %v", out)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("snapshot blocked on the running probe")
+	}
+	close(d.release)
+	<-refreshed
+	<-d.ctxErr
+}
+
+func TestMCPSessionToolsWithoutSessionService(t *testing.T) {
+	s := New("127.0.0.1:0", "admin-token", nil, nil, nil)
+	r := httptest.NewRequest(http.MethodPost, mcpMessagesPath, nil)
+	r = r.WithContext(context.WithValue(r.Context(), principalContextKey{}, auth.AdminPrincipal()))
+	for _, call := range []string{
+		`{"name":"list_approvals","arguments":{"session_id":"s1"}}`,
+		`{"name":"resolve_approval","arguments":{"session_id":"s1","request_id":"r1","decision":"accept"}}`,
+	} {
+		resp := s.handleMCPMessage(r, []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":`+call+`}`))
+		if resp == nil || resp.Error != nil {
+			t.Fatalf("%s: unexpected response %+v", call, resp)
+		}
+		out, _ := json.Marshal(resp.Result)
+		if !strings.Contains(string(out), `"isError":true`) || !strings.Contains(string(out), `unavailable`) {
+			t.Fatalf("%s: expected an unavailable tool error, got %s", call, out)
+		}
+	}
+}
+
+func TestStartResumesPipelinesLeftRunning(t *testing.T) {
+	store, err := ledger.Open(filepath.Join(t.TempDir(), "api.db"))
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	ctx := context.Background()
+	if err := store.Init(ctx); err != nil {
+		t.Fatalf("init ledger: %v", err)
+	}
+	reg := driver.NewRegistry()
+	reg.Register(&fakeAPIDriver{})
+	runSvc := run.NewService(store, reg, run.NewHub(), policy.New([]string{"/tmp"}), 30*time.Second, 4)
+
+	now := time.Now().UTC()
+	payload, _ := json.Marshal(run.Pipeline{
+		ID:            "p-stale",
+		WorkspacePath: "/tmp",
+		Status:        run.PipelineRunning,
+		Steps:         []run.PipelineStep{{ID: "only", Backend: "codex", Prompt: "x", Status: run.StepRunning, RunID: "gone"}},
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	})
+	if err := store.UpsertPipeline(ctx, ledger.PipelineRecord{ID: "p-stale", Status: run.PipelineRunning, Payload: string(payload), CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("seed pipeline: %v", err)
+	}
+
+	// Hold the address so Start returns once it reaches the listener.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	if err := New(ln.Addr().String(), "admin-token", runSvc, nil, nil).Start(); err == nil {
+		t.Fatal("expected start to fail on the held address")
+	}
+	p, err := runSvc.GetPipeline(ctx, "p-stale")
+	if err != nil || p.Status != run.PipelineFailed || p.Steps[0].Error != "interrupted by bridge restart" {
+		t.Fatalf("pipeline not resumed by Start: %+v %v", p, err)
+	}
+}
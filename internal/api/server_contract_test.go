@@ -0,0 +1,224 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"echohelix/internal/auth"
+)
+
+var updateContract = flag.Bool("update", false, "rewrite golden contract fixtures")
+
+// TestAPIContractGoldenFixtures records the JSON shape of representative
+// responses, with every scalar replaced by its JSON type, and compares them
+// with the fixtures in contract/. Shape changes must be deliberate: rerun
+// with -update and review the fixture diff.
+func TestAPIContractGoldenFixtures(t *testing.T) {
+	root := t.TempDir()
+	workspace := filepath.Join(root, "ws-contract")
+	if err := os.MkdirAll(workspace, 0o755); err != nil {
+		t.Fatalf("mkdir workspace: %v", err)
+	}
+	ts := newTestServerWithSession(t, root, testSessionConfig(writeFakeCodexForAPI(t, root)))
+	got := map[string]any{}
+	capture := func(name string, wantStatus int, method, path, bearer string, payload any) []byte {
+		t.Helper()
+		status, body := doJSON(t, ts, method, path, bearer, payload)
+		if status != wantStatus {
+			t.Fatalf("%s: status=%d want=%d body=%s", name, status, wantStatus, string(body))
+		}
+		var v any
+		if err := json.Unmarshal(body, &v); err != nil {
+			t.Fatalf("%s: decode: %v body=%s", name, err, string(body))
+		}
+		got[name] = jsonShape(v)
+		return body
+	}
+
+	capture("healthz", http.StatusOK, "GET", "/healthz", "", nil)
+	capture("error_unauthorized", http.StatusUnauthorized, "GET", "/api/v3/backends", "", nil)
+	startBody := capture("pair_start", http.StatusOK, "POST", "/api/v3/pair/start", "admin-token", map[string]any{
+		"permissions": []string{auth.ScopeRunsSubmit, auth.ScopeRunsRead, auth.ScopeBackendsRead, auth.ScopeDevicesRead},
+	})
+	capture("pair_pending", http.StatusOK, "GET", "/api/v3/pair/pending", "admin-token", nil)
+
+	var start struct {
+		PairCode  string `json:"pair_code"`
+		Challenge string `json:"challenge"`
+	}
+	if err := json.Unmarshal(startBody, &start); err != nil {
+		t.Fatalf("decode pair start: %v", err)
+	}
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	completeBody := capture("pair_complete", http.StatusOK, "POST", "/api/v3/pair/complete", "", map[string]any{
+		"pair_code":   start.PairCode,
+		"public_key":  base64.RawURLEncoding.EncodeToString(pub),
+		"signature":   base64.RawURLEncoding.EncodeToString(ed25519.Sign(priv, []byte(start.Challenge))),
+		"device_name": "contract",
+	})
+	var complete struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(completeBody, &complete); err != nil {
+		t.Fatalf("decode pair complete: %v", err)
+	}
+	token := complete.AccessToken
+
+	capture("devices", http.StatusOK, "GET", "/api/v3/devices", token, nil)
+	capture("backends", http.StatusOK, "GET", "/api/v3/backends", token, nil)
+	capture("error_forbidden", http.StatusForbidden, "POST", "/api/v3/emergency/stop", token, nil)
+
+	submitBody := capture("run_submit", http.StatusAccepted, "POST", "/api/v3/runs", token, map[string]any{
+		"workspace_id":   "ws-contract",
+		"workspace_path": workspace,
+		"backend":        "codex",
+		"prompt":         "contract",
+	})
+	var submitted struct {
+		RunID string `json:"run_id"`
+	}
+	if err := json.Unmarshal(submitBody, &submitted); err != nil {
+		t.Fatalf("decode submit: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, body := doJSON(t, ts, "GET", "/api/v3/runs/"+submitted.RunID, token, nil)
+		var obj struct {
+			Status string `json:"status"`
+		}
+		_ = json.Unmarshal(body, &obj)
+		if obj.Status == "completed" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("run did not complete: %s", string(body))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	capture("run", http.StatusOK, "GET", "/api/v3/runs/"+submitted.RunID, token, nil)
+	capture("error_not_found", http.StatusNotFound, "GET", "/api/v3/runs/missing", token, nil)
+	capture("error_method_not_allowed", http.StatusMethodNotAllowed, "DELETE", "/api/v3/runs", token, nil)
+
+	_, exportBody := doJSON(t, ts, "GET", "/api/v3/runs/"+submitted.RunID+"/export", token, nil)
+	scanner := bufio.NewScanner(bytes.NewReader(exportBody))
+	for scanner.Scan() {
+		var rec map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("decode export line: %v", err)
+		}
+		if rec["record"] == "event" {
+			ev := rec["event"].(map[string]any)
+			got["event_"+ev["type"].(string)] = jsonShape(ev)
+		}
+	}
+
+	capture("usage_tokens", http.StatusOK, "GET", "/api/v3/usage/tokens", token, nil)
+	capture("usage_quota", http.StatusOK, "GET", "/api/v3/usage/quota", token, nil)
+	capture("analytics_runs", http.StatusOK, "GET", "/api/v3/analytics/runs", token, nil)
+	capture("emergency_status", http.StatusOK, "GET", "/api/v3/emergency/status", "admin-token", nil)
+	capture("diagnostics_ledger", http.StatusOK, "GET", "/api/v3/diagnostics/ledger", "admin-token", nil)
+
+	sessionToken := issueAccessTokenForScopes(t, ts, []string{auth.ScopeRunsSubmit, auth.ScopeRunsRead})
+	capture("session", http.StatusCreated, "POST", "/api/v3/sessions", sessionToken, map[string]any{
+		"workspace_id":   "ws-contract",
+		"workspace_path": workspace,
+		"backend":        "codex",
+	})
+
+	dir := filepath.Join("contract")
+	if *updateContract {
+		old, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+		for _, f := range old {
+			_ = os.Remove(f)
+		}
+		for name, shape := range got {
+			data, _ := json.MarshalIndent(shape, "", "  ")
+			if err := os.WriteFile(filepath.Join(dir, name+".json"), append(data, '\n'), 0o644); err != nil {
+				t.Fatalf("write fixture: %v", err)
+			}
+		}
+		return
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	onDisk := make(map[string]bool, len(files))
+	for _, f := range files {
+		onDisk[filepath.Base(f[:len(f)-len(".json")])] = true
+	}
+	names := make([]string, 0, len(got))
+	for name := range got {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		want, err := os.ReadFile(filepath.Join(dir, name+".json"))
+		if err != nil {
+			t.Errorf("missing fixture %s.json; run with -update", name)
+			continue
+		}
+		data, _ := json.MarshalIndent(got[name], "", "  ")
+		if !bytes.Equal(bytes.TrimSpace(want), data) {
+			t.Errorf("contract %s changed:\nwant %s\ngot  %s", name, bytes.TrimSpace(want), data)
+		}
+		delete(onDisk, name)
+	}
+	for name := range onDisk {
+		t.Errorf("fixture %s.json is no longer produced; run with -update", name)
+	}
+
+	status, body := doJSON(t, ts, "GET", contractFixturesPath, token, nil)
+	var index struct {
+		Items []struct {
+			Name string `json:"name"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &index); status != http.StatusOK || err != nil || len(index.Items) != len(names) {
+		t.Fatalf("fixture index status=%d body=%s", status, string(body))
+	}
+	status, body = doJSON(t, ts, "GET", contractFixturesPath+"/run", token, nil)
+	if want, _ := os.ReadFile(filepath.Join(dir, "run.json")); status != http.StatusOK || !bytes.Equal(body, want) {
+		t.Fatalf("fixture run status=%d body=%s", status, string(body))
+	}
+}
+
+// jsonShape replaces scalars with their JSON type name and keeps the first
+// element of arrays, so fixtures capture structure rather than values.
+func jsonShape(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k, val := range x {
+			out[k] = jsonShape(val)
+		}
+		return out
+	case []any:
+		if len(x) == 0 {
+			return []any{}
+		}
+		return []any{jsonShape(x[0])}
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	default:
+		return "unknown"
+	}
+}
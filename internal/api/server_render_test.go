@@ -0,0 +1,167 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"echohelix/internal/auth"
+)
+
+func TestRenderMarkdownHTMLSanitizes(t *testing.T) {
+	src := strings.Join([]string{
+		"# Result <script>alert(1)</script>",
+		"",
+		"See [docs](https://example.com/a?b=1&c=2) and [bad](javascript:alert(1)) with **bold** and `x < y`.",
+		"",
+		"- one",
+		"- two",
+		"  1. nested",
+		"",
+		"| Name | Count |",
+		"| :--- | ---: |",
+		"| a | 1 |",
+		"",
+		"```go",
+		`func f() string { return "<b>" } // done`,
+		"```",
+		"",
+		"```diff",
+		"@@ -1 +1 @@",
+		"-old",
+		"+new",
+		"```",
+		"",
+		`<img src=x onerror="alert(1)">`,
+	}, "\n")
+	out := renderMarkdownHTML(src)
+
+	for _, want := range []string{
+		"<h1>Result &lt;script&gt;alert(1)&lt;/script&gt;</h1>",
+		`<a href="https://example.com/a?b=1&amp;c=2" rel="noopener noreferrer nofollow" target="_blank">docs</a>`,
+		"and bad with <strong>bold</strong>",
+		"<code>x &lt; y</code>",
+		"<ul>\n<li>one</li>\n<li>two\n<ol>\n<li>nested</li>\n</ol>\n</li>\n</ul>",
+		`<th style="text-align:left">Name</th><th style="text-align:right">Count</th>`,
+		`<pre><code class="language-go"><span class="tok-kw">func</span> f() string { <span class="tok-kw">return</span> <span class="tok-str">&#34;&lt;b&gt;&#34;</span> } <span class="tok-com">// done</span></code></pre>`,
+		`<span class="diff-hunk">@@ -1 +1 @@</span>` + "\n" + `<span class="diff-del">-old</span>` + "\n" + `<span class="diff-add">+new</span>`,
+		"<p>&lt;img src=x onerror=&#34;alert(1)&#34;&gt;</p>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("rendered html missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "javascript:") || strings.Contains(out, "<script") || strings.Contains(out, "<img") {
+		t.Fatalf("rendered html contains unsafe markup:\n%s", out)
+	}
+}
+
+func TestRunRenderEndpoint(t *testing.T) {
+	ts := newTestServer(t)
+	accessToken := issueAccessTokenForScopes(t, ts, []string{auth.ScopeRunsSubmit, auth.ScopeRunsRead})
+	status, body := doJSON(t, ts, "POST", "/api/v3/runs", accessToken, map[string]any{
+		"workspace_id":   "ws-render",
+		"workspace_path": "/tmp",
+		"backend":        "codex",
+		"prompt":         "hello",
+	})
+	if status != http.StatusAccepted {
+		t.Fatalf("run submit status=%d body=%s", status, string(body))
+	}
+	var submit struct {
+		ID string `json:"run_id"`
+	}
+	if err := json.Unmarshal(body, &submit); err != nil {
+		t.Fatalf("decode submit: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, body = doJSON(t, ts, "GET", "/api/v3/runs/"+submit.ID, accessToken, nil)
+		var obj struct {
+			Status string `json:"status"`
+		}
+		_ = json.Unmarshal(body, &obj)
+		if obj.Status == "completed" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("run did not complete: %s", string(body))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/v3/runs/"+submit.ID+"/render", nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("render request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("render status=%d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	if csp := resp.Header.Get("Content-Security-Policy"); !strings.Contains(csp, "default-src 'none'") {
+		t.Fatalf("unexpected csp %q", csp)
+	}
+	page, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read render body: %v", err)
+	}
+	if !strings.Contains(string(page), `data-status="completed"`) || !strings.Contains(string(page), "<p>ok</p>") {
+		t.Fatalf("unexpected rendered page:\n%s", page)
+	}
+
+	status, _ = doJSON(t, ts, "GET", "/api/v3/runs/missing/render", accessToken, nil)
+	if status != http.StatusNotFound {
+		t.Fatalf("expected 404 for missing run, got %d", status)
+	}
+}
+
+func TestRenderMarkdownHTMLStaysLinearOnUnbalancedInput(t *testing.T) {
+	src := strings.Repeat("[", 100000) + strings.Repeat("[a](", 50000) + strings.Repeat("*a ", 50000) + strings.Repeat("``` `", 20000)
+	start := time.Now()
+	out := renderMarkdownHTML(src)
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("rendering %d bytes of unbalanced markup took %s", len(src), d)
+	}
+	if strings.Contains(out, "<a ") || strings.Contains(out, "<em>") {
+		t.Fatalf("unbalanced markup rendered as links or emphasis: %.200s", out)
+	}
+	if got := renderInline("[a [b](https://x.test)"); got != `[a <a href="https://x.test" rel="noopener noreferrer nofollow" target="_blank">b</a>` {
+		t.Fatalf("inner link after an unclosed bracket = %s", got)
+	}
+
+	long := strings.Repeat("line\n", maxRenderBytes/5+10)
+	if cut := truncateRenderSource(long); len(cut) > maxRenderBytes || !strings.HasSuffix(cut, "line") {
+		t.Fatalf("truncated source has %d bytes ending %q", len(cut), cut[len(cut)-8:])
+	}
+}
+
+func TestRenderMarkdownHTMLBoundsNesting(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString(strings.Repeat(">", 200000) + " deep\n")
+	for i := 0; i < 500; i++ {
+		sb.WriteString(strings.Repeat(" ", 2*i) + "- nested\n")
+	}
+	src := sb.String()
+	start := time.Now()
+	out := renderMarkdownHTML(src)
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("rendering deeply nested markup took %s", d)
+	}
+	if n := strings.Count(out, "<blockquote>"); n != maxBlockDepth {
+		t.Fatalf("expected %d nested block quotes, got %d", maxBlockDepth, n)
+	}
+	if !strings.Contains(out, "&gt;&gt;&gt; deep") {
+		t.Fatalf("expected markers past the limit as text: %.200s", out[strings.LastIndex(out, "<blockquote>"):])
+	}
+	if n := strings.Count(out, "<ul>"); n > maxBlockDepth+1 {
+		t.Fatalf("expected list nesting to be capped, got %d lists", n)
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRouteTimeoutsBoundSlowHandlers(t *testing.T) {
+	s := New("127.0.0.1:0", "admin-token", nil, nil, nil, SecurityConfig{
+		HandlerTimeout: 50 * time.Millisecond,
+		RouteTimeouts:  map[string]time.Duration{"/slow/ok": 10 * time.Second},
+	})
+	if s.httpServer.ReadTimeout <= 0 || s.httpServer.WriteTimeout <= 0 || s.httpServer.IdleTimeout <= 0 || s.httpServer.MaxHeaderBytes <= 0 {
+		t.Fatalf("expected hardened server defaults, got %+v", s.httpServer)
+	}
+	if got := s.routeTimeout("/api/v3/files/abc"); got != 5*time.Minute {
+		t.Fatalf("expected default upload route timeout, got %s", got)
+	}
+
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(200 * time.Millisecond):
+			w.WriteHeader(http.StatusOK)
+		case <-r.Context().Done():
+		}
+	})
+	ts := httptest.NewServer(s.withRouteTimeouts(slow))
+	t.Cleanup(ts.Close)
+
+	res, err := http.Get(ts.URL + "/slow")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable || string(body) != routeTimeoutBody {
+		t.Fatalf("expected handler timeout, got %d %s", res.StatusCode, body)
+	}
+
+	res, err = http.Get(ts.URL + "/slow/ok")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected route override to allow slow handler, got %d", res.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/slow", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("upgrade request: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected websocket upgrades to bypass handler timeout, got %d", res.StatusCode)
+	}
+}
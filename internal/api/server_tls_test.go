@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"echohelix/internal/auth"
+	"echohelix/internal/ledger"
+)
+
+func TestSelfSignedTLSIsPinnedThroughPairing(t *testing.T) {
+	store, err := ledger.Open(filepath.Join(t.TempDir(), "api.db"))
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatalf("init ledger: %v", err)
+	}
+	authSvc := auth.New(store, auth.Config{AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Minute, PairCodeTTL: time.Minute})
+	certDir := filepath.Join(t.TempDir(), "tls")
+	s := New("127.0.0.1:0", "admin-token", nil, nil, authSvc, SecurityConfig{
+		TLS: TLSConfig{SelfSigned: true, SelfSignedDir: certDir, SelfSignedHosts: []string{"bridge.lan"}},
+	})
+	tlsCfg, err := s.tlsConfig()
+	if err != nil {
+		t.Fatalf("tls config: %v", err)
+	}
+	pin := s.TLSCertFingerprint()
+	if !strings.HasPrefix(pin, "SHA256:") {
+		t.Fatalf("expected certificate pin, got %q", pin)
+	}
+	leaf, err := x509.ParseCertificate(tlsCfg.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	if err := leaf.VerifyHostname("bridge.lan"); err != nil {
+		t.Fatalf("expected extra host in certificate: %v", err)
+	}
+
+	// A restart reuses the stored certificate, so paired devices keep their pin.
+	again := New("127.0.0.1:0", "admin-token", nil, nil, authSvc, SecurityConfig{TLS: TLSConfig{SelfSigned: true, SelfSignedDir: certDir}})
+	if _, err := again.tlsConfig(); err != nil || again.TLSCertFingerprint() != pin {
+		t.Fatalf("expected stable pin across restarts: %v %q != %q", err, again.TLSCertFingerprint(), pin)
+	}
+
+	ts := httptest.NewUnstartedServer(s.httpServer.Handler)
+	ts.TLS = tlsCfg
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(raw [][]byte, _ [][]*x509.Certificate) error {
+			if certFingerprint(raw[0]) != pin {
+				t.Errorf("served certificate does not match pin")
+			}
+			return nil
+		},
+	}}}
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/v3/pair/start", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer admin-token")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("pair start over tls: %v", err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		TLSFingerprint string `json:"tls_fingerprint"`
+		ElixURI        string `json:"elix_uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("pair start status=%d err=%v", resp.StatusCode, err)
+	}
+	if body.TLSFingerprint != pin || !strings.Contains(body.ElixURI, "tls="+url.QueryEscape(pin)) {
+		t.Fatalf("expected pin in pairing payload, got %+v", body)
+	}
+}
+
+func TestTLSConfigRequiresCertAndKey(t *testing.T) {
+	s := New("127.0.0.1:0", "admin-token", nil, nil, nil, SecurityConfig{TLS: TLSConfig{CertFile: "/tmp/only.crt"}})
+	if _, err := s.tlsConfig(); err == nil {
+		t.Fatalf("expected missing key file to be rejected")
+	}
+	plain := New("127.0.0.1:0", "admin-token", nil, nil, nil, SecurityConfig{})
+	if cfg, err := plain.tlsConfig(); err != nil || cfg != nil {
+		t.Fatalf("expected plain http without tls settings, got %v %v", cfg, err)
+	}
+}
@@ -2,14 +2,19 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"echohelix/internal/auth"
+	"echohelix/internal/events"
 
 	"github.com/gorilla/websocket"
 )
@@ -106,3 +111,398 @@ func TestSessionEventsWebSocketRejectsInvalidAccessTokenQuery(t *testing.T) {
 		t.Fatalf("expected 401 unauthorized, got status=%d err=%v", status, err)
 	}
 }
+
+func dialRunEvents(t *testing.T, ts *httptest.Server, runID string) *websocket.Conn {
+	t.Helper()
+	wsURL := strings.Replace(ts.URL, "http://", "ws://", 1) + "/api/v3/runs/" + url.PathEscape(runID) + "/events"
+	accessToken := issueAccessTokenForScopes(t, ts, []string{auth.ScopeRunsRead})
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+accessToken)
+	conn, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+	if err != nil {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		t.Fatalf("websocket dial failed status=%d err=%v", status, err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestRunEventsWebSocketSendsPings(t *testing.T) {
+	ts := newTestServer(t, SecurityConfig{
+		WSPingInterval: 50 * time.Millisecond,
+		WSPongWait:     time.Second,
+	})
+	conn := dialRunEvents(t, ts, "run-keepalive")
+
+	pings := make(chan struct{}, 8)
+	conn.SetPingHandler(func(data string) error {
+		select {
+		case pings <- struct{}{}:
+		default:
+		}
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-pings:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for ping %d", i+1)
+		}
+	}
+}
+
+func TestRunEventsWebSocketClosesStaleConnection(t *testing.T) {
+	ts := newTestServer(t, SecurityConfig{
+		WSPingInterval: 50 * time.Millisecond,
+		WSPongWait:     200 * time.Millisecond,
+	})
+	conn := dialRunEvents(t, ts, "run-stale")
+
+	// Swallow pings without answering, like a dead mobile client.
+	conn.SetPingHandler(func(string) error { return nil })
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		var netErr interface{ Timeout() bool }
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			t.Fatalf("server did not close stale connection")
+		}
+		return
+	}
+}
+
+func TestEventsMuxSubscribesToRuns(t *testing.T) {
+	ts := newTestServer(t)
+	accessToken := issueAccessTokenForScopes(t, ts, []string{auth.ScopeRunsSubmit, auth.ScopeRunsRead})
+
+	runIDs := make([]string, 0, 2)
+	for i := 0; i < 2; i++ {
+		status, body := doJSON(t, ts, "POST", "/api/v3/runs", accessToken, map[string]any{
+			"workspace_id":   "ws-mux",
+			"workspace_path": "/tmp",
+			"backend":        "codex",
+			"prompt":         "hello",
+		})
+		if status != http.StatusAccepted {
+			t.Fatalf("run submit status=%d body=%s", status, string(body))
+		}
+		var resp struct {
+			ID string `json:"run_id"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil || resp.ID == "" {
+			t.Fatalf("decode run submit response: %v body=%s", err, string(body))
+		}
+		runIDs = append(runIDs, resp.ID)
+	}
+
+	wsURL := strings.Replace(ts.URL, "http://", "ws://", 1) + "/api/v3/events?access_token=" + url.QueryEscape(accessToken)
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("websocket dial failed: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	for _, id := range runIDs {
+		if err := conn.WriteJSON(map[string]any{"subscribe": map[string]any{"run_id": id}}); err != nil {
+			t.Fatalf("write subscribe: %v", err)
+		}
+	}
+	if err := conn.WriteJSON(map[string]any{"subscribe": map[string]any{"run_id": "missing-run"}}); err != nil {
+		t.Fatalf("write subscribe: %v", err)
+	}
+
+	acks := map[string]bool{}
+	done := map[string]bool{}
+	sawMissingErr := false
+	for len(done) < len(runIDs) || !sawMissingErr {
+		var frame struct {
+			Stream string         `json:"stream"`
+			ID     string         `json:"id"`
+			Ack    string         `json:"ack"`
+			Error  string         `json:"error"`
+			Event  map[string]any `json:"event"`
+		}
+		if err := conn.ReadJSON(&frame); err != nil {
+			t.Fatalf("read frame: %v (acks=%v done=%v)", err, acks, done)
+		}
+		switch {
+		case frame.ID == "missing-run":
+			if frame.Error == "" {
+				t.Fatalf("expected error frame for missing run, got %+v", frame)
+			}
+			sawMissingErr = true
+		case frame.Ack == "subscribed":
+			acks[frame.ID] = true
+		case frame.Event != nil:
+			if frame.Stream != "run" || frame.Event["run_id"] != frame.ID {
+				t.Fatalf("unexpected event frame: %+v", frame)
+			}
+			if !acks[frame.ID] {
+				t.Fatalf("event delivered before subscribe ack: %+v", frame)
+			}
+			if frame.Event["type"] == events.TypeDone {
+				done[frame.ID] = true
+			}
+		}
+	}
+}
+
+func TestUnifiedStreamIDs(t *testing.T) {
+	root := t.TempDir()
+	workspace := filepath.Join(root, "ws-stream")
+	if err := os.MkdirAll(workspace, 0o755); err != nil {
+		t.Fatalf("mkdir workspace: %v", err)
+	}
+	codexBin := writeFakeCodexForAPI(t, root)
+	ts := newTestServerWithSession(t, root, testSessionConfig(codexBin))
+	accessToken := issueAccessTokenForScopes(t, ts, []string{auth.ScopeRunsSubmit, auth.ScopeRunsRead})
+
+	status, body := doJSON(t, ts, "POST", "/api/v3/sessions", accessToken, map[string]any{
+		"workspace_id":   "ws-stream",
+		"workspace_path": workspace,
+		"backend":        "codex",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("create session status=%d body=%s", status, string(body))
+	}
+	var sess struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := json.Unmarshal(body, &sess); err != nil || sess.SessionID == "" {
+		t.Fatalf("decode session: %v body=%s", err, string(body))
+	}
+
+	status, body = doJSON(t, ts, "POST", "/api/v3/runs", accessToken, map[string]any{
+		"workspace_id":   "ws-stream",
+		"workspace_path": workspace,
+		"backend":        "codex",
+		"prompt":         "hello",
+	})
+	if status != http.StatusAccepted {
+		t.Fatalf("run submit status=%d body=%s", status, string(body))
+	}
+	var runResp struct {
+		ID       string `json:"run_id"`
+		StreamID string `json:"stream_id"`
+	}
+	if err := json.Unmarshal(body, &runResp); err != nil || runResp.StreamID != "run:"+runResp.ID {
+		t.Fatalf("unexpected run submit response: %s", string(body))
+	}
+
+	// A session streamed through the run events URL.
+	conn := dialRunEvents(t, ts, sess.SessionID)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var ev map[string]any
+	if err := conn.ReadJSON(&ev); err != nil {
+		t.Fatalf("read session event: %v", err)
+	}
+	if ev["session_id"] != sess.SessionID {
+		t.Fatalf("expected session event, got %v", ev)
+	}
+
+	wsURL := strings.Replace(ts.URL, "http://", "ws://", 1) + "/api/v3/events?access_token=" + url.QueryEscape(accessToken)
+	mux, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("websocket dial failed: %v", err)
+	}
+	defer mux.Close()
+	_ = mux.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for _, id := range []string{runResp.ID, "session:" + sess.SessionID, "missing"} {
+		if err := mux.WriteJSON(map[string]any{"subscribe": map[string]any{"stream_id": id}}); err != nil {
+			t.Fatalf("write subscribe: %v", err)
+		}
+	}
+	want := map[string]string{
+		"run:" + runResp.ID:         "run",
+		"session:" + sess.SessionID: "session",
+	}
+	acked := map[string]bool{}
+	sawMissing := false
+	for len(acked) < len(want) || !sawMissing {
+		var frame struct {
+			Stream   string `json:"stream"`
+			ID       string `json:"id"`
+			StreamID string `json:"stream_id"`
+			Ack      string `json:"ack"`
+			Error    string `json:"error"`
+		}
+		if err := mux.ReadJSON(&frame); err != nil {
+			t.Fatalf("read frame: %v (acked=%v)", err, acked)
+		}
+		switch {
+		case frame.ID == "missing":
+			if frame.Error == "" {
+				t.Fatalf("expected error for unknown stream, got %+v", frame)
+			}
+			sawMissing = true
+		case frame.Ack == "subscribed":
+			if want[frame.StreamID] != frame.Stream {
+				t.Fatalf("unexpected ack: %+v", frame)
+			}
+			acked[frame.StreamID] = true
+		}
+	}
+}
+
+func TestSessionEventsWebSocketCompressesAndBatches(t *testing.T) {
+	root := t.TempDir()
+	workspace := filepath.Join(root, "ws")
+	if err := os.MkdirAll(workspace, 0o755); err != nil {
+		t.Fatalf("mkdir workspace: %v", err)
+	}
+	codexBin := writeFakeCodexForAPI(t, root)
+	ts := newTestServerWithSession(t, root, testSessionConfig(codexBin))
+
+	accessToken := issueAccessTokenForScopes(t, ts, []string{auth.ScopeRunsSubmit, auth.ScopeRunsRead})
+	createStatus, createBody := doJSON(t, ts, "POST", "/api/v3/sessions", accessToken, map[string]any{
+		"workspace_id":   "ws-api",
+		"workspace_path": workspace,
+		"backend":        "codex",
+	})
+	if createStatus != http.StatusCreated {
+		t.Fatalf("create session status=%d body=%s", createStatus, string(createBody))
+	}
+	var createResp struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := json.Unmarshal(createBody, &createResp); err != nil {
+		t.Fatalf("decode create session response: %v", err)
+	}
+
+	base := strings.Replace(ts.URL, "http://", "ws://", 1) +
+		"/api/v3/sessions/" + url.PathEscape(createResp.SessionID) + "/events?access_token=" + url.QueryEscape(accessToken)
+	if _, resp, err := websocket.DefaultDialer.Dial(base+"&batch_ms=99999", nil); err == nil || resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid batch_ms, err=%v", err)
+	}
+
+	dialer := websocket.Dialer{EnableCompression: true}
+	conn, resp, err := dialer.Dial(base+"&compress=1&batch_max=50", nil)
+	if err != nil {
+		t.Fatalf("websocket dial failed: %v", err)
+	}
+	defer conn.Close()
+	if !strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate") {
+		t.Fatalf("expected permessage-deflate, got %q", resp.Header.Get("Sec-WebSocket-Extensions"))
+	}
+	var batch []map[string]any
+	if err := conn.ReadJSON(&batch); err != nil {
+		t.Fatalf("read batch frame: %v", err)
+	}
+	if len(batch) == 0 || batch[0]["session_id"] != createResp.SessionID {
+		t.Fatalf("unexpected batch frame: %#v", batch)
+	}
+}
+
+func TestSessionEventsWebSocketFiltersByTypeAndMethod(t *testing.T) {
+	root := t.TempDir()
+	workspace := filepath.Join(root, "ws")
+	if err := os.MkdirAll(workspace, 0o755); err != nil {
+		t.Fatalf("mkdir workspace: %v", err)
+	}
+	codexBin := writeFakeCodexForAPI(t, root)
+	ts := newTestServerWithSession(t, root, testSessionConfig(codexBin))
+
+	accessToken := issueAccessTokenForScopes(t, ts, []string{auth.ScopeRunsSubmit, auth.ScopeRunsRead})
+	createStatus, createBody := doJSON(t, ts, "POST", "/api/v3/sessions", accessToken, map[string]any{
+		"workspace_id":   "ws-api",
+		"workspace_path": workspace,
+		"backend":        "codex",
+	})
+	if createStatus != http.StatusCreated {
+		t.Fatalf("create session status=%d body=%s", createStatus, string(createBody))
+	}
+	var createResp struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := json.Unmarshal(createBody, &createResp); err != nil {
+		t.Fatalf("decode create session response: %v", err)
+	}
+
+	base := strings.Replace(ts.URL, "http://", "ws://", 1) +
+		"/api/v3/sessions/" + url.PathEscape(createResp.SessionID) + "/events?access_token=" + url.QueryEscape(accessToken)
+	readAll := func(query string) []map[string]any {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial(base+query, nil)
+		if err != nil {
+			t.Fatalf("websocket dial %s: %v", query, err)
+		}
+		defer conn.Close()
+		var out []map[string]any
+		for {
+			_ = conn.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+			var msg map[string]any
+			if err := conn.ReadJSON(&msg); err != nil {
+				return out
+			}
+			out = append(out, msg)
+		}
+	}
+
+	all := readAll("")
+	var statuses int
+	for _, ev := range all {
+		if ev["type"] == "status" {
+			statuses++
+		}
+	}
+	if statuses == 0 || statuses == len(all) {
+		t.Fatalf("expected a mix of status and other events, got %#v", all)
+	}
+	if got := readAll("&types=status"); len(got) != statuses {
+		t.Fatalf("expected %d status events, got %#v", statuses, got)
+	}
+	for _, ev := range readAll("&exclude=status") {
+		if ev["type"] == "status" {
+			t.Fatalf("expected status events to be excluded, got %#v", ev)
+		}
+	}
+	pollStatus, pollBody := doJSON(t, ts, "GET", "/api/v3/sessions/"+createResp.SessionID+"/events?transport=poll&types=status", accessToken, nil)
+	var polled struct {
+		Events []map[string]any `json:"events"`
+	}
+	if err := json.Unmarshal(pollBody, &polled); err != nil || pollStatus != http.StatusOK || len(polled.Events) != statuses {
+		t.Fatalf("expected %d polled status events, got %d %s", statuses, pollStatus, string(pollBody))
+	}
+	threadEvents := readAll("&methods=thread/*")
+	if len(threadEvents) == 0 {
+		t.Fatalf("expected thread/ events, got none of %#v", all)
+	}
+	for _, ev := range threadEvents {
+		if method, _ := ev["method"].(string); !strings.HasPrefix(method, "thread/") {
+			t.Fatalf("expected only thread/ methods, got %#v", ev)
+		}
+	}
+}
+
+func TestAfterSeqSkipsEventsTheReplaySent(t *testing.T) {
+	ws := &wsStream{closed: make(chan struct{})}
+	sub := make(chan events.Event, 5)
+	for _, seq := range []int64{2, 3, 3, 0, 4} {
+		sub <- events.Event{Seq: seq, Type: events.TypeToken}
+	}
+	close(sub)
+	history := []events.Event{{Seq: 1}, {Seq: 2}}
+	seqOf := func(ev events.Event) int64 { return ev.Seq }
+	var got []int64
+	for ev := range afterSeq(ws, sub, lastSeq(history, seqOf), seqOf) {
+		got = append(got, ev.Seq)
+	}
+	if fmt.Sprint(got) != "[3 0 4]" {
+		t.Fatalf("forwarded seqs = %v, want [3 0 4]", got)
+	}
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"echohelix/internal/apierror"
+)
+
+const (
+	shareLinkPrefix = "/share/"
+	shareAPIPrefix  = "/api/v3/share/"
+
+	defaultShareTTL = time.Hour
+	maxShareTTL     = 7 * 24 * time.Hour
+)
+
+var errShareLinkInvalid = errors.New("share link invalid or expired")
+
+// shareLinkToken grants read-only access to one run until expiresAt without
+// server-side state: <run id base64>.<expiry base36>.<signature>. It is
+// signed with the pair link secret under its own prefix so neither token
+// kind verifies as the other.
+func (s *Server) shareLinkToken(runID string, expiresAt time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(runID)) + "." + strconv.FormatInt(expiresAt.Unix(), 36)
+	return payload + "." + s.shareLinkSignature(payload)
+}
+
+func (s *Server) shareLinkSignature(payload string) string {
+	mac := hmac.New(sha256.New, s.security.PairLinkSecret)
+	mac.Write([]byte("share." + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+func (s *Server) parseShareLinkToken(token string, now time.Time) (string, time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", time.Time{}, errShareLinkInvalid
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(s.shareLinkSignature(payload))) {
+		return "", time.Time{}, errShareLinkInvalid
+	}
+	exp, err := strconv.ParseInt(parts[1], 36, 64)
+	if err != nil || now.Unix() > exp {
+		return "", time.Time{}, errShareLinkInvalid
+	}
+	runID, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || len(runID) == 0 {
+		return "", time.Time{}, errShareLinkInvalid
+	}
+	return string(runID), time.Unix(exp, 0).UTC(), nil
+}
+
+// handleRunShare mints a share link for POST /api/v3/runs/{id}/share.
+func (s *Server) handleRunShare(w http.ResponseWriter, r *http.Request, runID string) {
+	var req struct {
+		TTLSeconds int `json:"ttl_seconds"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeServiceError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	switch {
+	case req.TTLSeconds < 0 || ttl > maxShareTTL:
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "ttl_seconds must be between 0 and "+strconv.Itoa(int(maxShareTTL/time.Second)))
+		return
+	case ttl == 0:
+		ttl = defaultShareTTL
+	}
+	if _, err := s.runSvc.GetRun(r.Context(), runID); err != nil {
+		writeServiceError(w, http.StatusNotFound, err)
+		return
+	}
+	expiresAt := time.Now().UTC().Add(ttl).Truncate(time.Second)
+	token := s.shareLinkToken(runID, expiresAt)
+	s.auditf(r, "run_shared", "run_id="+runID+" expires_at="+expiresAt.Format(time.RFC3339))
+	writeJSON(w, http.StatusOK, map[string]any{
+		"run_id":     runID,
+		"token":      token,
+		"share_url":  s.publicBaseURL(r) + shareLinkPrefix + token,
+		"expires_at": expiresAt,
+	})
+}
+
+// handleSharePage serves the read-only viewer at GET /share/{token}. The
+// token is checked by the API calls the page makes.
+func (s *Server) handleSharePage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	page, err := fs.ReadFile(webFS, "web/share.html")
+	if err != nil {
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "share viewer unavailable")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(page)
+}
+
+// handleShareAPI serves the run behind a share token without a paired
+// device: GET /api/v3/share/{token}, .../result and .../events.
+func (s *Server) handleShareAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, shareAPIPrefix), "/"), "/")
+	runID, expiresAt, err := s.parseShareLinkToken(parts[0], time.Now().UTC())
+	if err != nil || len(parts) > 2 {
+		writeServiceError(w, http.StatusNotFound, errShareLinkInvalid)
+		return
+	}
+	ctx := r.Context()
+	if len(parts) == 1 {
+		obj, err := s.runSvc.GetRun(ctx, runID)
+		if err != nil {
+			writeServiceError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"run": obj, "expires_at": expiresAt})
+		return
+	}
+	switch parts[1] {
+	case "result":
+		out, err := s.runSvc.GetRunResult(ctx, runID)
+		if err != nil {
+			writeServiceError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, out)
+	case "events":
+		s.handleRunEvents(w, r, runID)
+	default:
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "unknown action")
+	}
+}
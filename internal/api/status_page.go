@@ -0,0 +1,197 @@
+package api
+
+import (
+	"context"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"echohelix/internal/apierror"
+	"echohelix/internal/run"
+)
+
+const (
+	StatusFieldBridge        = "bridge"
+	StatusFieldBackends      = "backends"
+	StatusFieldEmergencyStop = "emergency_stop"
+	StatusFieldQueue         = "queue"
+
+	statusPageCacheTTL     = 10 * time.Second
+	statusPageProbeTimeout = 5 * time.Second
+)
+
+var statusPageFields = []string{StatusFieldBridge, StatusFieldBackends, StatusFieldEmergencyStop, StatusFieldQueue}
+
+// StatusPageConfig serves an unauthenticated, anonymized status page at
+// /status (HTML) and /status.json. With Addr set it gets its own plain-HTTP
+// listener and stays off the API listener; otherwise it is mounted on the
+// API listener when Enabled. Fields limits what is shown (default: all).
+type StatusPageConfig struct {
+	Enabled bool
+	Addr    string
+	Fields  []string
+	Title   string
+}
+
+type statusPage struct {
+	title  string
+	fields map[string]bool
+	runSvc *run.Service
+
+	mu         sync.Mutex
+	cached     map[string]any
+	cachedAt   time.Time
+	refreshing chan struct{} // closed when the running refresh finishes
+}
+
+var statusPageTemplate = template.Must(template.New("status").Parse(`<!doctype html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="30">
+<title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Status: <strong>{{.Status.status}}</strong></p>
+<ul>
+{{- with .Status.bridge}}
+<li>Bridge: {{.}}</li>
+{{- end}}
+{{- if .ShowEmergency}}
+<li>Emergency stop: {{if .Status.emergency_stop}}active{{else}}inactive{{end}}</li>
+{{- end}}
+{{- with .Status.queue}}
+<li>Queue: {{.queued}} queued, {{.active}} running</li>
+{{- end}}
+{{- range .Status.backends}}
+<li>Backend {{.name}}: {{.state}}</li>
+{{- end}}
+</ul>
+<p><small>Updated {{.Status.updated_at}}</small></p>
+</body></html>
+`))
+
+func newStatusPage(cfg StatusPageConfig, runSvc *run.Service) *statusPage {
+	p := &statusPage{title: strings.TrimSpace(cfg.Title), fields: map[string]bool{}, runSvc: runSvc}
+	if p.title == "" {
+		p.title = "EchoHelix status"
+	}
+	for _, f := range cfg.Fields {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" {
+			continue
+		}
+		if !containsString(statusPageFields, f) {
+			log.Printf("warn: ignore unknown status page field %q", f)
+			continue
+		}
+		p.fields[f] = true
+	}
+	if len(p.fields) == 0 {
+		for _, f := range statusPageFields {
+			p.fields[f] = true
+		}
+	}
+	return p
+}
+
+func (p *statusPage) register(mux *http.ServeMux) {
+	mux.HandleFunc("/status", p.handle)
+	mux.HandleFunc("/status.json", p.handle)
+}
+
+func (p *statusPage) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	status := p.snapshot()
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.URL.Path == "/status" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = statusPageTemplate.Execute(w, map[string]any{
+			"Title":         p.title,
+			"Status":        status,
+			"ShowEmergency": p.fields[StatusFieldEmergencyStop],
+		})
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	writeJSON(w, http.StatusOK, status)
+}
+
+// snapshot reuses a recent result so the unauthenticated page cannot be used
+// to hammer backend health checks. Only one request refreshes it, outside
+// the lock and on its own deadline so a client going away cannot cut the
+// probes short; others get the stale result meanwhile, or wait for the
+// first one.
+func (p *statusPage) snapshot() map[string]any {
+	p.mu.Lock()
+	if p.cached != nil && (p.refreshing != nil || time.Since(p.cachedAt) < statusPageCacheTTL) {
+		defer p.mu.Unlock()
+		return p.cached
+	}
+	if done := p.refreshing; done != nil {
+		p.mu.Unlock()
+		<-done
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.cached
+	}
+	done := make(chan struct{})
+	p.refreshing = done
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), statusPageProbeTimeout)
+	defer cancel()
+	out := p.probe(ctx)
+
+	p.mu.Lock()
+	p.cached, p.cachedAt, p.refreshing = out, time.Now(), nil
+	p.mu.Unlock()
+	close(done)
+	return out
+}
+
+func (p *statusPage) probe(ctx context.Context) map[string]any {
+	var sum run.StatusSummary
+	if p.runSvc != nil {
+		sum = p.runSvc.StatusSummary(ctx)
+	}
+	overall := "operational"
+	out := map[string]any{"updated_at": time.Now().UTC().Format(time.RFC3339)}
+	if p.fields[StatusFieldBridge] {
+		out["bridge"] = "up"
+	}
+	if p.fields[StatusFieldBackends] {
+		backends := make([]map[string]any, 0, len(sum.Backends))
+		for _, b := range sum.Backends {
+			backends = append(backends, map[string]any{"name": b.Name, "state": b.State})
+			if b.State != run.BackendHealthy {
+				overall = "degraded"
+			}
+		}
+		out["backends"] = backends
+	}
+	if p.fields[StatusFieldEmergencyStop] {
+		out["emergency_stop"] = sum.EmergencyStop
+		if sum.EmergencyStop {
+			overall = "stopped"
+		}
+	}
+	if p.fields[StatusFieldQueue] {
+		out["queue"] = map[string]any{"queued": sum.QueuedRuns, "active": sum.ActiveRuns}
+	}
+	out["status"] = overall
+	return out
+}
+
+func containsString(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Unified stream IDs name a run or session event stream without a separate
+// URL namespace: "run:<run_id>" or "session:<session_id>". A bare ID is
+// looked up as a run first, then as a session.
+
+var errUnknownStream = errors.New("unknown stream")
+
+func streamID(kind, id string) string {
+	return kind + ":" + id
+}
+
+func (s *Server) resolveStreamID(ctx context.Context, raw string) (kind, id string, err error) {
+	raw = strings.TrimSpace(raw)
+	if rest, ok := strings.CutPrefix(raw, muxStreamRun+":"); ok {
+		return muxStreamRun, strings.TrimSpace(rest), nil
+	}
+	if rest, ok := strings.CutPrefix(raw, muxStreamSession+":"); ok {
+		return muxStreamSession, strings.TrimSpace(rest), nil
+	}
+	if raw == "" {
+		return "", "", errUnknownStream
+	}
+	if _, err := s.runSvc.GetRun(ctx, raw); err == nil {
+		return muxStreamRun, raw, nil
+	}
+	if s.sessionSvc != nil {
+		if _, err := s.sessionSvc.Get(raw); err == nil {
+			return muxStreamSession, raw, nil
+		}
+	}
+	return "", "", errUnknownStream
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const routeTimeoutBody = `{"error":{"code":"timeout","message":"request timed out"}}`
+
+// exportTimeout bounds streamed run exports, which cannot go through
+// http.TimeoutHandler because it buffers the whole response.
+const exportTimeout = 10 * time.Minute
+
+// withRouteTimeouts bounds each non-streaming request by its route timeout:
+// the handler runs under http.TimeoutHandler and the connection deadlines are
+// moved to match, so long uploads can outlive the server-wide ReadTimeout.
+// WebSocket upgrades and streamed turns are exempt; they set a write deadline
+// per message.
+func (s *Server) withRouteTimeouts(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		if websocket.IsWebSocketUpgrade(r) || isStreamedTurnRequest(r) || r.URL.Path == mcpSSEPath {
+			_ = rc.SetReadDeadline(time.Time{})
+			_ = rc.SetWriteDeadline(time.Time{})
+			next.ServeHTTP(w, r)
+			return
+		}
+		if isRunExportPath(r.URL.Path) {
+			deadline := time.Now().Add(exportTimeout)
+			_ = rc.SetReadDeadline(deadline)
+			_ = rc.SetWriteDeadline(deadline)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if isEventPollRequest(r) {
+			deadline := time.Now().Add(maxPollWait + 10*time.Second)
+			_ = rc.SetReadDeadline(deadline)
+			_ = rc.SetWriteDeadline(deadline)
+			next.ServeHTTP(w, r)
+			return
+		}
+		d := s.routeTimeout(r.URL.Path)
+		if d <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		deadline := time.Now().Add(d)
+		_ = rc.SetReadDeadline(deadline)
+		// Leave room to write the timeout response itself.
+		_ = rc.SetWriteDeadline(deadline.Add(5 * time.Second))
+		http.TimeoutHandler(next, d, routeTimeoutBody).ServeHTTP(w, r)
+	})
+}
+
+// routeTimeout returns the longest-prefix match from RouteTimeouts, falling
+// back to HandlerTimeout.
+func (s *Server) routeTimeout(path string) time.Duration {
+	best, d := -1, s.security.HandlerTimeout
+	for prefix, timeout := range s.security.RouteTimeouts {
+		if strings.HasPrefix(path, prefix) && len(prefix) > best {
+			best, d = len(prefix), timeout
+		}
+	}
+	return d
+}
+
+func isRunExportPath(path string) bool {
+	return strings.HasPrefix(path, "/api/v3/runs/") && strings.HasSuffix(strings.TrimRight(path, "/"), "/export")
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const (
+	selfSignedCertFile = "self-signed.crt"
+	selfSignedKeyFile  = "self-signed.key"
+	selfSignedValidity = 10 * 365 * 24 * time.Hour
+)
+
+// TLSConfig enables HTTPS on the bridge listener. The first configured
+// source wins: CertFile/KeyFile, then ACME for ACMEHosts (TLS-ALPN-01, so
+// the listener must be reachable on :443), then a self-signed certificate
+// kept in SelfSignedDir whose fingerprint is pinned through pairing.
+type TLSConfig struct {
+	CertFile     string
+	KeyFile      string
+	ACMEHosts    []string
+	ACMECacheDir string
+	ACMEEmail    string
+	SelfSigned   bool
+	// SelfSignedDir keeps the generated certificate across restarts so
+	// paired devices keep a valid pin.
+	SelfSignedDir string
+	// SelfSignedHosts are extra DNS names or IPs for the certificate, on top
+	// of localhost and the machine's hostname.
+	SelfSignedHosts []string
+}
+
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || len(c.ACMEHosts) > 0 || c.SelfSigned
+}
+
+// TLSCertFingerprint is the pin of the self-signed certificate, or "" when
+// the bridge serves a CA-issued certificate or plain HTTP.
+func (s *Server) TLSCertFingerprint() string {
+	return s.tlsPin
+}
+
+// tlsConfig builds the listener's TLS settings, or nil for plain HTTP.
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	cfg := s.security.TLS
+	switch {
+	case cfg.CertFile != "" || cfg.KeyFile != "":
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, errors.New("tls: both cert and key files are required")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: load certificate: %w", err)
+		}
+		return &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}, nil
+	case len(cfg.ACMEHosts) > 0:
+		if cfg.ACMECacheDir == "" {
+			return nil, errors.New("tls: ACME needs a cache directory")
+		}
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.ACMEHosts...),
+			Cache:      autocert.DirCache(cfg.ACMECacheDir),
+			Email:      cfg.ACMEEmail,
+		}
+		out := m.TLSConfig()
+		out.MinVersion = tls.VersionTLS12
+		return out, nil
+	case cfg.SelfSigned:
+		cert, err := loadOrCreateSelfSigned(cfg.SelfSignedDir, cfg.SelfSignedHosts)
+		if err != nil {
+			return nil, err
+		}
+		s.tlsPin = certFingerprint(cert.Certificate[0])
+		return &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}, nil
+	}
+	return nil, nil
+}
+
+// certFingerprint formats a DER certificate like auth.IdentityFingerprint.
+func certFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// loadOrCreateSelfSigned returns the certificate stored in dir, generating
+// an ECDSA P-256 one on first use.
+func loadOrCreateSelfSigned(dir string, hosts []string) (tls.Certificate, error) {
+	if dir == "" {
+		return tls.Certificate{}, errors.New("tls: self-signed certificate needs a directory")
+	}
+	certPath := filepath.Join(dir, selfSignedCertFile)
+	keyPath := filepath.Join(dir, selfSignedKeyFile)
+	if cert, err := tls.LoadX509KeyPair(certPath, keyPath); err == nil {
+		return cert, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return tls.Certificate{}, fmt.Errorf("tls: load self-signed certificate: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	hostname, _ := os.Hostname()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "elix-bridge"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, h := range append([]string{"localhost", "127.0.0.1", "::1", hostname}, hosts...) {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("tls: create self-signed certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return tls.Certificate{}, fmt.Errorf("tls: create certificate dir: %w", err)
+	}
+	if err := os.WriteFile(keyPath, keyPEM, 0o600); err != nil {
+		return tls.Certificate{}, fmt.Errorf("tls: write key: %w", err)
+	}
+	if err := os.WriteFile(certPath, certPEM, 0o644); err != nil {
+		return tls.Certificate{}, fmt.Errorf("tls: write certificate: %w", err)
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"echohelix/internal/apierror"
+	"echohelix/internal/auth"
+	"echohelix/internal/session"
+)
+
+func (s *Server) handleTools(w http.ResponseWriter, r *http.Request) {
+	if s.sessionSvc == nil {
+		writeError(w, http.StatusServiceUnavailable, apierror.CodeUnavailable, "session service unavailable")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		if _, ok := s.requireScope(w, r, auth.ScopeRunsRead); !ok {
+			return
+		}
+		sessionID := strings.TrimSpace(r.URL.Query().Get("session_id"))
+		writeJSON(w, http.StatusOK, map[string]any{"items": s.sessionSvc.ListTools(sessionID)})
+	case http.MethodPost:
+		var req session.RegisteredTool
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeServiceError(w, http.StatusBadRequest, err)
+			return
+		}
+		// Inline scripts run on the host, so like env profiles they are
+		// reserved for the bootstrap operator, and so are global tools,
+		// which answer every session's calls. Other callers only register
+		// tools for sessions they created.
+		if strings.TrimSpace(req.Script) != "" || strings.TrimSpace(req.SessionID) == "" {
+			if !s.requireBootstrapOperator(w, r) {
+				return
+			}
+		} else {
+			principal, ok := s.requireScope(w, r, auth.ScopeRunsSubmit)
+			if !ok {
+				return
+			}
+			if !principal.IsOperator() && !s.ownsSession(w, r, strings.TrimSpace(req.SessionID)) {
+				return
+			}
+		}
+		req.RegisteredBy = s.actorOf(r)
+		obj, err := s.sessionSvc.RegisterTool(req)
+		if err != nil {
+			writeServiceError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.auditf(r, "session_tool_registered", "name="+obj.Name+" via="+obj.Via())
+		writeJSON(w, http.StatusCreated, obj)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleToolByName(w http.ResponseWriter, r *http.Request) {
+	if s.sessionSvc == nil {
+		writeError(w, http.StatusServiceUnavailable, apierror.CodeUnavailable, "session service unavailable")
+		return
+	}
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v3/tools/"), "/")
+	if name == "" || strings.Contains(name, "/") {
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "tool name missing")
+		return
+	}
+	if r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	sessionID := strings.TrimSpace(r.URL.Query().Get("session_id"))
+	by := ""
+	if sessionID == "" {
+		if !s.requireBootstrapOperator(w, r) {
+			return
+		}
+	} else {
+		principal, ok := s.requireScope(w, r, auth.ScopeRunsSubmit)
+		if !ok {
+			return
+		}
+		if !principal.IsOperator() {
+			by = s.actorOf(r)
+		}
+	}
+	if err := s.sessionSvc.UnregisterTool(name, sessionID, by); err != nil {
+		writeServiceError(w, http.StatusNotFound, err)
+		return
+	}
+	s.auditf(r, "session_tool_unregistered", "name="+name)
+	writeJSON(w, http.StatusOK, map[string]any{"name": name, "session_id": sessionID, "deleted": true})
+}
+
+// ownsSession writes 403 unless sessionID names a session the caller
+// created.
+func (s *Server) ownsSession(w http.ResponseWriter, r *http.Request, sessionID string) bool {
+	sess, err := s.sessionSvc.Get(sessionID)
+	if err != nil {
+		writeServiceError(w, http.StatusNotFound, err)
+		return false
+	}
+	if actor := s.actorOf(r); actor == "" || sess.CreatedBy != actor {
+		writeError(w, http.StatusForbidden, apierror.CodeForbidden, "session was created by another caller")
+		return false
+	}
+	return true
+}
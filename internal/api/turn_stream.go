@@ -0,0 +1,160 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"echohelix/internal/session"
+)
+
+// maxTurnStreamBacklog bounds the events held while a queued turn waits
+// for its turn id.
+const maxTurnStreamBacklog = 1000
+
+func isStreamedTurnRequest(r *http.Request) bool {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.URL.Path, "/api/v3/sessions/") {
+		return false
+	}
+	if !strings.HasSuffix(strings.TrimRight(r.URL.Path, "/"), "/turns") {
+		return false
+	}
+	stream, _ := strconv.ParseBool(r.URL.Query().Get("stream"))
+	return stream
+}
+
+// handleStreamedTurn starts a turn and answers with Server-Sent Events
+// carrying only that turn's session events, ending after turn/completed.
+// The first event ("turn") is the usual StartTurn result; a queued turn is
+// followed once it is dequeued.
+func (s *Server) handleStreamedTurn(w http.ResponseWriter, r *http.Request, sessionID string, req session.StartTurnRequest) {
+	// Subscribe before starting so no output of the turn is missed.
+	sub, unsub, err := s.sessionSvc.Subscribe(sessionID)
+	if err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+	defer unsub()
+	obj, err := s.sessionSvc.StartTurn(r.Context(), sessionID, req)
+	if err != nil {
+		writeServiceError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	markReplayed(w, obj.Replayed)
+	w.WriteHeader(http.StatusOK)
+	write := func(id int64, event string, v any) bool {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return false
+		}
+		var b strings.Builder
+		if id > 0 {
+			fmt.Fprintf(&b, "id: %d\n", id)
+		}
+		fmt.Fprintf(&b, "event: %s\ndata: %s\n\n", event, data)
+		_ = rc.SetWriteDeadline(time.Now().Add(s.security.WSWriteTimeout))
+		if _, err := w.Write([]byte(b.String())); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+	if !write(0, "turn", obj) {
+		return
+	}
+	if obj.Replayed {
+		// The original request already streamed this turn; its events
+		// remain available from the session event stream.
+		write(0, "done", map[string]any{"turn_id": obj.TurnID, "queue_id": obj.QueueID, "replayed": true})
+		return
+	}
+
+	var filter *session.TurnFilter
+	var backlog []session.Event
+	if obj.TurnID != "" {
+		filter = session.NewTurnFilter(obj.TurnID)
+	}
+	// handle writes ev if it belongs to the turn and reports whether the
+	// stream is finished.
+	var handle func(ev session.Event) (done bool, ok bool)
+	handle = func(ev session.Event) (bool, bool) {
+		if filter == nil {
+			switch {
+			case ev.Type == "status" && ev.Method == "turn/dequeued" && ev.Payload["queue_id"] == obj.QueueID:
+				turnID, _ := ev.Payload["turn_id"].(string)
+				filter = session.NewTurnFilter(turnID)
+				if !write(ev.Seq, ev.Type, ev) {
+					return true, false
+				}
+				pending := backlog
+				backlog = nil
+				for _, p := range pending {
+					if done, ok := handle(p); done || !ok {
+						return done, ok
+					}
+				}
+				return false, true
+			case ev.Type == "status" && ev.Method == "turn/queue_failed" && ev.Payload["queue_id"] == obj.QueueID:
+				ok := write(ev.Seq, ev.Type, ev) && write(0, "done", map[string]any{"queue_id": obj.QueueID, "status": "failed"})
+				return true, ok
+			}
+			if len(backlog) < maxTurnStreamBacklog {
+				backlog = append(backlog, ev)
+			}
+			return false, true
+		}
+		if !filter.Match(ev) {
+			return false, true
+		}
+		if !write(ev.Seq, ev.Type, ev) {
+			return true, false
+		}
+		if turnID, status, ok := session.TurnCompletion(ev); ok && turnID == filter.TurnID {
+			return true, write(0, "done", map[string]any{"turn_id": turnID, "status": status})
+		}
+		return false, true
+	}
+
+	ticker := time.NewTicker(s.security.WSPingInterval)
+	defer ticker.Stop()
+	var lastSeq int64
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			_ = rc.SetWriteDeadline(time.Now().Add(s.security.WSWriteTimeout))
+			if _, err := w.Write([]byte(": keepalive\n\n")); err != nil || rc.Flush() != nil {
+				return
+			}
+		case ev, open := <-sub:
+			if !open {
+				write(0, "error", map[string]any{"error": "session event stream closed"})
+				return
+			}
+			evs := []session.Event{ev}
+			if lastSeq > 0 && ev.Seq > lastSeq+1 {
+				// The subscription dropped events; refill from history.
+				if history, err := s.sessionSvc.ListEvents(sessionID, lastSeq+1); err == nil {
+					evs = history
+				}
+			}
+			for _, e := range evs {
+				if e.Seq <= lastSeq || e.Seq > ev.Seq {
+					continue
+				}
+				lastSeq = e.Seq
+				if done, _ := handle(e); done {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"echohelix/internal/apierror"
+)
+
+const warehouseExportsPath = "/api/v3/admin/exports"
+
+// handleWarehouseExports starts a Parquet export job (POST, range from the
+// from/to/window query like the usage endpoints) or lists recent jobs (GET).
+func (s *Server) handleWarehouseExports(w http.ResponseWriter, r *http.Request) {
+	if !s.requireBootstrapOperator(w, r) {
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, warehouseExportsPath), "/")
+	if id != "" {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+			return
+		}
+		job, ok := s.runSvc.GetWarehouseExport(id)
+		if !ok {
+			writeError(w, http.StatusNotFound, apierror.CodeNotFound, "export not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]any{"items": s.runSvc.ListWarehouseExports()})
+	case http.MethodPost:
+		from, to, err := parseTimeRange(r, 24*time.Hour)
+		if err != nil {
+			writeServiceError(w, http.StatusBadRequest, err)
+			return
+		}
+		job, err := s.runSvc.StartWarehouseExport(from, to)
+		if err != nil {
+			writeServiceError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.auditf(r, "warehouse_export", "export_id="+job.ID+" from="+from.Format(time.RFC3339)+" to="+to.Format(time.RFC3339))
+		writeJSON(w, http.StatusAccepted, job)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+	}
+}
@@ -59,6 +59,16 @@ document.getElementById("backendsBtn").addEventListener("click", async () => {
   }
 });
 
+document.getElementById("systemStatusBtn").addEventListener("click", async () => {
+  try {
+    const token = els.bootstrapToken.value.trim();
+    const data = await request("/api/v3/system/status", { headers: { ...authHeader(token) } });
+    logLine("system status", data);
+  } catch (e) {
+    logLine(`system status failed: ${e.message}`);
+  }
+});
+
 document.getElementById("pairStartBtn").addEventListener("click", async () => {
   try {
     const token = els.bootstrapToken.value.trim();
@@ -124,5 +134,21 @@ document.getElementById("pollRunBtn").addEventListener("click", async () => {
   }
 });
 
+document.getElementById("shareRunBtn").addEventListener("click", async () => {
+  try {
+    const runId = els.runId.value.trim();
+    if (!runId) throw new Error("run_id is empty");
+    const token = els.sessionToken.value.trim();
+    const data = await request(`/api/v3/runs/${runId}/share`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json", ...authHeader(token) },
+      body: JSON.stringify({}),
+    });
+    logLine("share link", { share_url: data.share_url, expires_at: data.expires_at });
+  } catch (e) {
+    logLine(`share failed: ${e.message}`);
+  }
+});
+
 logLine("ui ready", { ui: "/ui/", api: baseUrl() });
 
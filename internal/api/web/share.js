@@ -0,0 +1,54 @@
+const token = location.pathname.replace(/^\/share\//, "").replace(/\/+$/, "");
+const api = `/api/v3/share/${encodeURIComponent(token)}`;
+const els = {
+  sub: document.getElementById("shareSub"),
+  run: document.getElementById("runOut"),
+  events: document.getElementById("eventsOut"),
+  result: document.getElementById("resultOut"),
+};
+
+async function getJSON(path) {
+  const res = await fetch(path);
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) {
+    throw new Error((data.error && data.error.message) || `${res.status}`);
+  }
+  return data;
+}
+
+function appendEvent(ev) {
+  const line = `#${ev.seq} ${ev.type}${ev.payload ? " " + JSON.stringify(ev.payload) : ""}`;
+  els.events.textContent = `${els.events.textContent}${line}\n`.slice(-20000);
+}
+
+async function refresh() {
+  const data = await getJSON(api);
+  els.run.textContent = JSON.stringify(data.run, null, 2);
+  els.sub.textContent = `Read-only view, link expires ${new Date(data.expires_at).toLocaleString()}.`;
+  if (data.run.terminal && data.run.terminal.is_terminal) {
+    getJSON(`${api}/result`)
+      .then((res) => { els.result.textContent = JSON.stringify(res, null, 2); })
+      .catch((e) => { els.result.textContent = e.message; });
+  }
+  return data.run;
+}
+
+function follow() {
+  const scheme = location.protocol === "https:" ? "wss" : "ws";
+  const ws = new WebSocket(`${scheme}://${location.host}${api}/events`);
+  ws.onmessage = (msg) => {
+    const data = JSON.parse(msg.data);
+    for (const ev of Array.isArray(data) ? data : [data]) {
+      appendEvent(ev);
+      if (ev.type === "done" || ev.type === "error") {
+        refresh().catch(() => {});
+      }
+    }
+  };
+}
+
+refresh()
+  .then(follow)
+  .catch((e) => {
+    els.sub.textContent = `This link is invalid or has expired (${e.message}).`;
+  });
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultWSBatchEvery = 250 * time.Millisecond
+	defaultWSBatchMax   = 100
+	maxWSBatchEvery     = 5 * time.Second
+	maxWSBatchMax       = 1000
+)
+
+// compressingUpgrader offers permessage-deflate; it is only used for
+// clients that ask for it with compress=1.
+var compressingUpgrader = websocket.Upgrader{
+	CheckOrigin:       func(*http.Request) bool { return true },
+	EnableCompression: true,
+}
+
+// wsStreamOptions are the event stream query options: compress=1 negotiates
+// permessage-deflate, and batch_ms / batch_max switch to array frames holding
+// the events gathered over batch_ms or up to batch_max events.
+type wsStreamOptions struct {
+	compress   bool
+	batchEvery time.Duration
+	batchMax   int
+}
+
+func parseWSStreamOptions(q url.Values) (wsStreamOptions, error) {
+	var opts wsStreamOptions
+	if v := q.Get("compress"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, errors.New("compress must be a boolean")
+		}
+		opts.compress = enabled
+	}
+	if v := q.Get("batch_ms"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || time.Duration(n)*time.Millisecond > maxWSBatchEvery {
+			return opts, errors.New("batch_ms must be between 0 and 5000")
+		}
+		opts.batchEvery = time.Duration(n) * time.Millisecond
+	}
+	if v := q.Get("batch_max"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxWSBatchMax {
+			return opts, errors.New("batch_max must be between 0 and 1000")
+		}
+		opts.batchMax = n
+	}
+	if opts.batchEvery > 0 || opts.batchMax > 0 {
+		if opts.batchEvery == 0 {
+			opts.batchEvery = defaultWSBatchEvery
+		}
+		if opts.batchMax == 0 {
+			opts.batchMax = defaultWSBatchMax
+		}
+	}
+	return opts, nil
+}
+
+func (o wsStreamOptions) batching() bool {
+	return o.batchMax > 0
+}
+
+func (o wsStreamOptions) upgrade(w http.ResponseWriter, r *http.Request) (*websocket.Conn, error) {
+	if o.compress {
+		return compressingUpgrader.Upgrade(w, r, nil)
+	}
+	return upgrader.Upgrade(w, r, nil)
+}
+
+// wsStream wraps an event WebSocket with write deadlines and a ping/pong
+// keepalive. Connections that stop answering pings are treated as closed.
+type wsStream struct {
+	conn         *websocket.Conn
+	pingInterval time.Duration
+	writeTimeout time.Duration
+	closed       chan struct{}
+	done         chan struct{}
+	doneOnce     sync.Once
+	opts         wsStreamOptions
+}
+
+// newWSStream starts the keepalive reader. Text frames from the client are
+// delivered to inbox when it is non-nil and discarded otherwise.
+func (s *Server) newWSStream(conn *websocket.Conn, inbox chan<- []byte) *wsStream {
+	ws := &wsStream{
+		conn:         conn,
+		pingInterval: s.security.WSPingInterval,
+		writeTimeout: s.security.WSWriteTimeout,
+		closed:       make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	pongWait := s.security.WSPongWait
+	_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	go func() {
+		defer close(ws.closed)
+		for {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if inbox == nil || typ != websocket.TextMessage {
+				continue
+			}
+			select {
+			case inbox <- data:
+			case <-ws.done:
+				return
+			}
+		}
+	}()
+	return ws
+}
+
+// close releases the reader goroutine and closes the connection.
+func (ws *wsStream) close() {
+	ws.doneOnce.Do(func() {
+		close(ws.done)
+		_ = ws.conn.Close()
+	})
+}
+
+func (ws *wsStream) writeJSON(v any) error {
+	_ = ws.conn.SetWriteDeadline(time.Now().Add(ws.writeTimeout))
+	return ws.conn.WriteJSON(v)
+}
+
+func (ws *wsStream) ping() error {
+	return ws.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(ws.writeTimeout))
+}
+
+// writeEvents sends replayed events one per frame, or in batch_max sized
+// array frames when batching.
+func writeEvents[T any](ws *wsStream, evs []T) error {
+	if !ws.opts.batching() {
+		for _, ev := range evs {
+			if err := ws.writeJSON(ev); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for len(evs) > 0 {
+		n := min(len(evs), ws.opts.batchMax)
+		if err := ws.writeJSON(evs[:n]); err != nil {
+			return err
+		}
+		evs = evs[n:]
+	}
+	return nil
+}
+
+// afterSeq forwards the events of sub that come after seq, so live events
+// the history replay already sent are not repeated. Events without a seq
+// are always forwarded.
+func afterSeq[T any](ws *wsStream, sub <-chan T, seq int64, seqOf func(T) int64) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for ev := range sub {
+			if n := seqOf(ev); n > 0 {
+				if n <= seq {
+					continue
+				}
+				seq = n
+			}
+			select {
+			case out <- ev:
+			case <-ws.closed:
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func lastSeq[T any](evs []T, seqOf func(T) int64) int64 {
+	var last int64
+	for _, ev := range evs {
+		last = max(last, seqOf(ev))
+	}
+	return last
+}
+
+// pumpWS forwards sub to the client until the subscription closes, a write
+// fails, or the client goes away or stops answering pings. When batching,
+// events are held until batch_ms passes or batch_max are pending.
+func pumpWS[T any](ws *wsStream, sub <-chan T) {
+	ticker := time.NewTicker(ws.pingInterval)
+	defer ticker.Stop()
+	var pending []T
+	var flush <-chan time.Time
+	if ws.opts.batching() {
+		flushTicker := time.NewTicker(ws.opts.batchEvery)
+		defer flushTicker.Stop()
+		flush = flushTicker.C
+	}
+	writePending := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		err := ws.writeJSON(pending)
+		pending = pending[:0]
+		return err
+	}
+	for {
+		select {
+		case <-ws.closed:
+			return
+		case <-ticker.C:
+			if err := ws.ping(); err != nil {
+				return
+			}
+		case <-flush:
+			if err := writePending(); err != nil {
+				return
+			}
+		case ev, ok := <-sub:
+			if !ok {
+				_ = writePending()
+				return
+			}
+			if !ws.opts.batching() {
+				if err := ws.writeJSON(ev); err != nil {
+					return
+				}
+				continue
+			}
+			pending = append(pending, ev)
+			if len(pending) >= ws.opts.batchMax {
+				if err := writePending(); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
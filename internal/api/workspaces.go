@@ -0,0 +1,158 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"echohelix/internal/apierror"
+	"echohelix/internal/auth"
+	"echohelix/internal/envprofile"
+	"echohelix/internal/policy"
+	"echohelix/internal/run"
+)
+
+func (s *Server) handleWorkspaceByID(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v3/workspaces/"), "/")
+	parts := strings.Split(path, "/")
+	if len(parts) < 2 || parts[0] == "" {
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "unknown action")
+		return
+	}
+	workspaceID := parts[0]
+	switch {
+	case len(parts) == 2 && parts[1] == "env":
+		s.handleWorkspaceEnv(w, r, workspaceID)
+	case len(parts) == 2 && parts[1] == "policy":
+		s.handleWorkspacePolicy(w, r, workspaceID)
+	case len(parts) == 3 && parts[1] == "git":
+		s.handleWorkspaceGit(w, r, workspaceID, parts[2])
+	default:
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "unknown action")
+	}
+}
+
+func (s *Server) handleWorkspaceEnv(w http.ResponseWriter, r *http.Request, workspaceID string) {
+	// Env profiles can run shell init snippets on the host, so they stay
+	// behind the bootstrap operator just like emergency controls.
+	if !s.requireBootstrapOperator(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		obj, err := s.runSvc.GetEnvProfile(r.Context(), workspaceID)
+		if err != nil {
+			writeServiceError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, obj)
+	case http.MethodPut:
+		var req envprofile.Profile
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeServiceError(w, http.StatusBadRequest, err)
+			return
+		}
+		principal, _ := s.principalFromContext(r.Context())
+		updatedBy := principal.Address
+		if updatedBy == "" {
+			updatedBy = "admin"
+		}
+		obj, err := s.runSvc.PutEnvProfile(r.Context(), workspaceID, req, updatedBy)
+		if err != nil {
+			writeServiceError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.auditf(r, "workspace_env_updated", "workspace_id="+workspaceID)
+		writeJSON(w, http.StatusOK, obj)
+	case http.MethodDelete:
+		if err := s.runSvc.DeleteEnvProfile(r.Context(), workspaceID); err != nil {
+			writeServiceError(w, http.StatusInternalServerError, err)
+			return
+		}
+		s.auditf(r, "workspace_env_deleted", "workspace_id="+workspaceID)
+		writeJSON(w, http.StatusOK, map[string]any{"workspace_id": workspaceID, "deleted": true})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleWorkspacePolicy(w http.ResponseWriter, r *http.Request, workspaceID string) {
+	if !s.requireBootstrapOperator(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		obj, err := s.runSvc.GetWorkspacePolicy(r.Context(), workspaceID)
+		if err != nil {
+			writeServiceError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, obj)
+	case http.MethodPut:
+		var req struct {
+			policy.WorkspaceRule
+			WorkspacePath string `json:"workspace_path"`
+		}
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			writeServiceError(w, http.StatusBadRequest, err)
+			return
+		}
+		obj, err := s.runSvc.PutWorkspacePolicy(r.Context(), workspaceID, req.WorkspacePath, req.WorkspaceRule, s.actorOf(r))
+		if err != nil {
+			writeServiceError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.auditf(r, "workspace_policy_updated", "workspace_id="+workspaceID+" workspace_path="+obj.WorkspacePath)
+		writeJSON(w, http.StatusOK, obj)
+	case http.MethodDelete:
+		if err := s.runSvc.DeleteWorkspacePolicy(r.Context(), workspaceID); err != nil {
+			writeServiceError(w, http.StatusInternalServerError, err)
+			return
+		}
+		s.auditf(r, "workspace_policy_deleted", "workspace_id="+workspaceID)
+		writeJSON(w, http.StatusOK, map[string]any{"workspace_id": workspaceID, "deleted": true})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleWorkspaceGit(w http.ResponseWriter, r *http.Request, workspaceID, action string) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	if _, ok := s.requireScope(w, r, auth.ScopeRunsRead); !ok {
+		return
+	}
+	var (
+		obj any
+		err error
+	)
+	switch action {
+	case "status":
+		obj, err = s.runSvc.WorkspaceGitStatus(r.Context(), workspaceID)
+	case "diff":
+		q := r.URL.Query()
+		staged, _ := strconv.ParseBool(q.Get("staged"))
+		untracked := true
+		if v := q.Get("untracked"); v != "" {
+			untracked, _ = strconv.ParseBool(v)
+		}
+		obj, err = s.runSvc.WorkspaceGitDiff(r.Context(), workspaceID, run.GitDiffQuery{
+			Path:      q.Get("path"),
+			Staged:    staged,
+			Untracked: untracked,
+		})
+	default:
+		writeError(w, http.StatusNotFound, apierror.CodeNotFound, "unknown action")
+		return
+	}
+	if err != nil {
+		writeServiceError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, obj)
+}
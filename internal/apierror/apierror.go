@@ -0,0 +1,190 @@
+// Package apierror defines the bridge's HTTP error envelope and the catalog
+// of stable error codes clients branch on. Every error response has the form
+//
+//	{"error": {"code": "<code>", "message": "<text>", ...details}}
+//
+// where message is for humans and may change, and code never does.
+package apierror
+
+import (
+	"errors"
+	"net/http"
+)
+
+type Code string
+
+// Generic codes, used when no more specific code applies.
+const (
+	CodeInvalidRequest   Code = "invalid_request"
+	CodeUnauthorized     Code = "unauthorized"
+	CodeForbidden        Code = "forbidden"
+	CodeNotFound         Code = "not_found"
+	CodeMethodNotAllowed Code = "method_not_allowed"
+	CodeConflict         Code = "conflict"
+	CodePayloadTooLarge  Code = "payload_too_large"
+	CodeUnprocessable    Code = "unprocessable"
+	CodeRateLimited      Code = "rate_limited"
+	CodeInternal         Code = "internal_error"
+	CodeNotImplemented   Code = "not_implemented"
+	CodeUpstream         Code = "upstream_error"
+	CodeUnavailable      Code = "unavailable"
+	CodeTimeout          Code = "timeout"
+)
+
+// Domain codes for service errors.
+const (
+	CodeRunNotFound           Code = "run_not_found"
+	CodeRunNotActive          Code = "run_not_active"
+	CodeRunStillActive        Code = "run_still_active"
+	CodeCheckpointNotFound    Code = "checkpoint_not_found"
+	CodeWorkspaceHasLaterRuns Code = "workspace_has_later_runs"
+	CodeInputUnsupported      Code = "input_unsupported"
+	CodeExtensionDenied       Code = "extension_denied"
+	CodeExtensionUnsupported  Code = "extension_unsupported"
+	CodePauseUnsupported      Code = "pause_unsupported"
+	CodeRunPaused             Code = "run_paused"
+	CodeRawLogUnsupported     Code = "raw_log_unsupported"
+	CodeRawLogNotFound        Code = "raw_log_not_found"
+	CodeRunNotPaused          Code = "run_not_paused"
+	CodeRetryUnavailable      Code = "retry_unavailable"
+	CodeUnsupportedOption     Code = "unsupported_option"
+	CodePipelineNotFound      Code = "pipeline_not_found"
+	CodeSessionNotFound       Code = "session_not_found"
+	CodeSessionClosed         Code = "session_closed"
+	CodeSessionDetached       Code = "session_detached"
+	CodeSessionResourceLimit  Code = "session_resource_limit"
+	CodeTurnConflict          Code = "turn_conflict"
+	CodeSessionLocked         Code = "session_locked"
+	CodeIdempotencyInProgress Code = "idempotency_in_progress"
+	CodeIdempotencyKeyReused  Code = "idempotency_key_reused"
+	CodeMethodNotSupported    Code = "method_not_supported"
+	CodeToolNotFound          Code = "tool_not_found"
+	CodeToolNotOwned          Code = "tool_not_owned"
+	CodePolicyViolation       Code = "policy_violation"
+	CodeQuotaExceeded         Code = "quota_exceeded"
+	CodeEmergencyStopActive   Code = "emergency_stop_active"
+	CodeReadOnly              Code = "read_only"
+	CodeUnresolvedMentions    Code = "unresolved_mentions"
+	CodeFileNotFound          Code = "file_not_found"
+	CodeFileTooLarge          Code = "file_too_large"
+	CodeBackendNotFound       Code = "backend_not_found"
+	CodeBackendExists         Code = "backend_exists"
+	CodeBackendNotExternal    Code = "backend_not_external"
+	CodeBackendBusy           Code = "backend_busy"
+	CodeBackendUnhealthy      Code = "backend_unhealthy"
+	CodeWorkspaceNotFound     Code = "workspace_not_found"
+	CodeWorkspaceNotAllowed   Code = "workspace_not_allowed"
+	CodeNotGitRepository      Code = "not_git_repository"
+	CodeGitPathOutside        Code = "git_path_outside_workspace"
+	CodeEnvProfileNotFound    Code = "env_profile_not_found"
+	CodePolicyNotFound        Code = "workspace_policy_not_found"
+	CodeDeviceNotFound        Code = "device_not_found"
+	CodeDeviceRevoked         Code = "device_revoked"
+	CodePairCodeInvalid       Code = "pair_code_invalid"
+	CodeSessionInvalid        Code = "auth_session_invalid"
+	CodeAuthSessionNotFound   Code = "auth_session_not_found"
+	CodeTokenRevoked          Code = "token_revoked"
+	CodeAdminTokenNotFound    Code = "admin_token_not_found"
+	CodeAdminTokenExists      Code = "admin_token_exists"
+	CodeAdminTokenInactive    Code = "admin_token_inactive"
+	CodeAdminTokenScopes      Code = "admin_token_scopes"
+	CodeScopeRequestNotFound  Code = "scope_request_not_found"
+	CodeScopeRequestPending   Code = "scope_request_pending"
+	CodeScopeRequestDecided   Code = "scope_request_decided"
+	CodeScopeApprovalRejected Code = "scope_approval_forbidden"
+)
+
+// Error is an API error ready to be written as the envelope. Details are
+// merged into the error object next to code and message.
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	Details map[string]any
+	// RetryAfter, in seconds, is sent as the Retry-After header when set.
+	RetryAfter int
+}
+
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// With adds a detail field and returns e.
+func (e *Error) With(key string, value any) *Error {
+	if e.Details == nil {
+		e.Details = map[string]any{}
+	}
+	e.Details[key] = value
+	return e
+}
+
+// Body is the JSON envelope for e.
+func (e *Error) Body() map[string]any {
+	obj := make(map[string]any, len(e.Details)+2)
+	for k, v := range e.Details {
+		obj[k] = v
+	}
+	obj["code"] = e.Code
+	obj["message"] = e.Message
+	return map[string]any{"error": obj}
+}
+
+// From maps a service error to an API error through the catalog. Errors the
+// catalog does not know keep fallback as status and get its generic code.
+func From(err error, fallback int) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	if out := fromTyped(err); out != nil {
+		return out
+	}
+	for _, entry := range catalog {
+		if errors.Is(err, entry.err) {
+			return New(entry.status, entry.code, err.Error())
+		}
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return New(http.StatusRequestEntityTooLarge, CodePayloadTooLarge, err.Error())
+	}
+	return New(fallback, CodeForStatus(fallback), err.Error())
+}
+
+// CodeForStatus is the generic code for an HTTP status.
+func CodeForStatus(status int) Code {
+	switch status {
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusUnprocessableEntity:
+		return CodeUnprocessable
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusNotImplemented:
+		return CodeNotImplemented
+	case http.StatusBadGateway:
+		return CodeUpstream
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeTimeout
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeInvalidRequest
+}
@@ -0,0 +1,50 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"echohelix/internal/ledger"
+	"echohelix/internal/policy"
+	"echohelix/internal/run"
+	"echohelix/internal/session"
+)
+
+func TestFromMapsServiceErrorsToCodes(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   Code
+	}{
+		{fmt.Errorf("get run: %w", ledger.ErrRunNotFound), http.StatusNotFound, CodeRunNotFound},
+		{policy.New(nil).ValidateWorkspace(""), http.StatusBadRequest, CodePolicyViolation},
+		{session.ErrSessionClosed, http.StatusConflict, CodeSessionClosed},
+		{&run.QuotaExceededError{Scope: "device", Key: "d1", UsedTokens: 10, Limit: 5, ResetAt: time.Now().Add(time.Minute)}, http.StatusTooManyRequests, CodeQuotaExceeded},
+		{errors.New("boom"), http.StatusBadGateway, CodeUpstream},
+	}
+	for _, tc := range cases {
+		got := From(tc.err, http.StatusBadGateway)
+		if got.Status != tc.status || got.Code != tc.code || got.Message != tc.err.Error() {
+			t.Fatalf("From(%v) = %d %s %q, want %d %s", tc.err, got.Status, got.Code, got.Message, tc.status, tc.code)
+		}
+	}
+
+	quota := From(&run.QuotaExceededError{Scope: "device", ResetAt: time.Now().Add(time.Minute)}, http.StatusBadRequest)
+	if quota.RetryAfter <= 0 || quota.Details["scope"] != "device" {
+		t.Fatalf("quota error lost details: %+v", quota)
+	}
+}
+
+func TestBodyKeepsCodeAndMessageOverDetails(t *testing.T) {
+	body := New(http.StatusServiceUnavailable, CodeReadOnly, "read only").With("code", "x").With("reason", "maintenance").Body()
+	obj, ok := body["error"].(map[string]any)
+	if !ok {
+		t.Fatalf("unexpected body: %#v", body)
+	}
+	if obj["code"] != CodeReadOnly || obj["message"] != "read only" || obj["reason"] != "maintenance" {
+		t.Fatalf("unexpected error object: %#v", obj)
+	}
+}
@@ -0,0 +1,137 @@
+package apierror
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"echohelix/internal/auth"
+	"echohelix/internal/ledger"
+	"echohelix/internal/policy"
+	"echohelix/internal/run"
+	"echohelix/internal/session"
+)
+
+type catalogEntry struct {
+	err    error
+	status int
+	code   Code
+}
+
+// catalog maps service sentinels to their code and status. The first match
+// wins, so more specific errors come before ones they may wrap.
+var catalog = []catalogEntry{
+	{ledger.ErrRunNotFound, http.StatusNotFound, CodeRunNotFound},
+	{ledger.ErrPipelineNotFound, http.StatusNotFound, CodePipelineNotFound},
+	{run.ErrRunNotActive, http.StatusConflict, CodeRunNotActive},
+	{run.ErrRunStillActive, http.StatusConflict, CodeRunStillActive},
+	{run.ErrCheckpointNotFound, http.StatusNotFound, CodeCheckpointNotFound},
+	{run.ErrWorkspaceHasLaterRuns, http.StatusConflict, CodeWorkspaceHasLaterRuns},
+	{ledger.ErrCheckpointNotFound, http.StatusNotFound, CodeCheckpointNotFound},
+	{run.ErrInputUnsupported, http.StatusNotImplemented, CodeInputUnsupported},
+	{run.ErrExtensionDenied, http.StatusForbidden, CodeExtensionDenied},
+	{run.ErrExtensionUnsupported, http.StatusNotImplemented, CodeExtensionUnsupported},
+	{run.ErrPauseUnsupported, http.StatusNotImplemented, CodePauseUnsupported},
+	{run.ErrRunPaused, http.StatusConflict, CodeRunPaused},
+	{run.ErrRawLogUnsupported, http.StatusNotImplemented, CodeRawLogUnsupported},
+	{run.ErrRawLogNotFound, http.StatusNotFound, CodeRawLogNotFound},
+	{run.ErrRunNotPaused, http.StatusConflict, CodeRunNotPaused},
+	{run.ErrRetryUnavailable, http.StatusConflict, CodeRetryUnavailable},
+	{run.ErrEmergencyStopActive, http.StatusServiceUnavailable, CodeEmergencyStopActive},
+	{run.ErrQuotaExceeded, http.StatusTooManyRequests, CodeQuotaExceeded},
+	{run.ErrFileNotFound, http.StatusNotFound, CodeFileNotFound},
+	{ledger.ErrFileNotFound, http.StatusNotFound, CodeFileNotFound},
+	{run.ErrFileTooLarge, http.StatusRequestEntityTooLarge, CodeFileTooLarge},
+	{run.ErrBackendNotFound, http.StatusNotFound, CodeBackendNotFound},
+	{run.ErrBackendExists, http.StatusConflict, CodeBackendExists},
+	{run.ErrBackendNotExternal, http.StatusForbidden, CodeBackendNotExternal},
+	{run.ErrBackendBusy, http.StatusConflict, CodeBackendBusy},
+	{run.ErrBackendUnhealthy, http.StatusBadGateway, CodeBackendUnhealthy},
+	{run.ErrWorkspaceNotFound, http.StatusNotFound, CodeWorkspaceNotFound},
+	{ledger.ErrWorkspaceNotFound, http.StatusNotFound, CodeWorkspaceNotFound},
+	{run.ErrWorkspaceNotAllowed, http.StatusForbidden, CodeWorkspaceNotAllowed},
+	{run.ErrNotGitRepository, http.StatusConflict, CodeNotGitRepository},
+	{run.ErrGitPathOutsideWorkspace, http.StatusBadRequest, CodeGitPathOutside},
+	{run.ErrWorkspaceFileNotFound, http.StatusNotFound, CodeNotFound},
+	{run.ErrEnvProfileNotFound, http.StatusNotFound, CodeEnvProfileNotFound},
+	{ledger.ErrEnvProfileNotFound, http.StatusNotFound, CodeEnvProfileNotFound},
+	{run.ErrWorkspacePolicyNotFound, http.StatusNotFound, CodePolicyNotFound},
+	{ledger.ErrWorkspacePolicyNotFound, http.StatusNotFound, CodePolicyNotFound},
+	{session.ErrSessionNotFound, http.StatusNotFound, CodeSessionNotFound},
+	{ledger.ErrAgentSessionNotFound, http.StatusNotFound, CodeSessionNotFound},
+	{session.ErrSessionClosed, http.StatusConflict, CodeSessionClosed},
+	{session.ErrSessionDetached, http.StatusConflict, CodeSessionDetached},
+	{session.ErrResourceLimit, http.StatusConflict, CodeSessionResourceLimit},
+	{session.ErrToolNotFound, http.StatusNotFound, CodeToolNotFound},
+	{session.ErrToolNotOwned, http.StatusForbidden, CodeToolNotOwned},
+	{policy.ErrViolation, http.StatusBadRequest, CodePolicyViolation},
+	{ledger.ErrDeviceNotFound, http.StatusNotFound, CodeDeviceNotFound},
+	{ledger.ErrDeviceRevoked, http.StatusForbidden, CodeDeviceRevoked},
+	{ledger.ErrPairCodeInvalid, http.StatusBadRequest, CodePairCodeInvalid},
+	{ledger.ErrSessionInvalid, http.StatusBadRequest, CodeSessionInvalid},
+	{ledger.ErrAuthSessionNotFound, http.StatusNotFound, CodeAuthSessionNotFound},
+	{ledger.ErrIdempotencyKeyInProgress, http.StatusConflict, CodeIdempotencyInProgress},
+	{ledger.ErrIdempotencyKeyReused, http.StatusUnprocessableEntity, CodeIdempotencyKeyReused},
+	{auth.ErrTokenRevoked, http.StatusUnauthorized, CodeTokenRevoked},
+	{ledger.ErrAdminTokenNotFound, http.StatusNotFound, CodeAdminTokenNotFound},
+	{ledger.ErrAdminTokenExists, http.StatusConflict, CodeAdminTokenExists},
+	{ledger.ErrAdminTokenInactive, http.StatusConflict, CodeAdminTokenInactive},
+	{auth.ErrAdminTokenScopes, http.StatusForbidden, CodeAdminTokenScopes},
+	{ledger.ErrScopeRequestNotFound, http.StatusNotFound, CodeScopeRequestNotFound},
+	{ledger.ErrScopeRequestPending, http.StatusConflict, CodeScopeRequestPending},
+	{ledger.ErrScopeRequestDecided, http.StatusConflict, CodeScopeRequestDecided},
+	{auth.ErrScopeApprovalForbidden, http.StatusForbidden, CodeScopeApprovalRejected},
+}
+
+// fromTyped maps the service errors that carry details for the client.
+func fromTyped(err error) *Error {
+	var quotaErr *run.QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		out := New(http.StatusTooManyRequests, CodeQuotaExceeded, err.Error()).
+			With("scope", quotaErr.Scope).
+			With("key", quotaErr.Key).
+			With("used_tokens", quotaErr.UsedTokens).
+			With("limit", quotaErr.Limit).
+			With("reset_at", quotaErr.ResetAt)
+		out.RetryAfter = int(time.Until(quotaErr.ResetAt)/time.Second) + 1
+		return out
+	}
+	var mentionErr *run.UnresolvedMentionsError
+	if errors.As(err, &mentionErr) {
+		return New(http.StatusUnprocessableEntity, CodeUnresolvedMentions, err.Error()).
+			With("mentions", mentionErr.Mentions)
+	}
+	var optionErr *run.UnsupportedOptionError
+	if errors.As(err, &optionErr) {
+		return New(http.StatusBadRequest, CodeUnsupportedOption, err.Error()).
+			With("backend", optionErr.Backend).
+			With("option", optionErr.Option).
+			With("value", optionErr.Value).
+			With("supported", optionErr.Supported)
+	}
+	var conflict *session.TurnConflictError
+	if errors.As(err, &conflict) {
+		return New(http.StatusConflict, CodeTurnConflict, err.Error()).
+			With("active_turn_id", conflict.ActiveTurnID).
+			With("queued_turns", conflict.QueuedTurns).
+			With("max_queue", conflict.MaxQueue)
+	}
+	var locked *session.SessionLockedError
+	if errors.As(err, &locked) {
+		return New(http.StatusConflict, CodeSessionLocked, err.Error()).
+			With("holder", locked.Holder).
+			With("expires_at", locked.ExpiresAt)
+	}
+	var unsupported *session.MethodNotSupportedError
+	if errors.As(err, &unsupported) {
+		supported := unsupported.Supported
+		if supported == nil {
+			supported = []string{}
+		}
+		return New(http.StatusBadRequest, CodeMethodNotSupported, err.Error()).
+			With("method", unsupported.Method).
+			With("backend", unsupported.Backend).
+			With("supported_methods", supported)
+	}
+	return nil
+}
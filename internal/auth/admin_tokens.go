@@ -0,0 +1,269 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"echohelix/internal/ledger"
+
+	"github.com/google/uuid"
+)
+
+// BootstrapTokenName names the admin token seeded from BRIDGE_AUTH_TOKEN.
+// Rotating or revoking it retires the configured token without a restart.
+const BootstrapTokenName = "bootstrap"
+
+const adminTokenPrefix = "elixadm_"
+
+var adminTokenNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+
+// ErrAdminTokenScopes rejects issuing or rotating a token with scopes the
+// caller does not hold.
+var ErrAdminTokenScopes = errors.New("admin token scopes exceed the caller's")
+
+// AdminToken describes a named static token without its secret. Status is
+// active, rotated (replaced, within or past its grace period), revoked or
+// expired.
+type AdminToken struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	Status     string     `json:"status"`
+	CreatedBy  string     `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	ReplacedBy string     `json:"replaced_by,omitempty"`
+}
+
+// IssuedAdminToken carries the secret, which is only returned once.
+type IssuedAdminToken struct {
+	AdminToken
+	Token string `json:"token"`
+}
+
+// SeedBootstrapToken records the configured bootstrap token as the
+// "bootstrap" admin token. A token already known to the store, active or
+// not, is left alone, so a retired bootstrap token stays retired across
+// restarts; configuring a different token replaces the active one.
+func (s *Service) SeedBootstrapToken(ctx context.Context, token string) error {
+	if token == "" {
+		return nil
+	}
+	hash := hashToken(token)
+	if _, err := s.store.GetAdminTokenByHash(ctx, hash); err == nil {
+		return nil
+	} else if !errors.Is(err, ledger.ErrAdminTokenNotFound) {
+		return err
+	}
+	recs, err := s.store.ListAdminTokens(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	rec := ledger.AdminTokenRecord{
+		ID:        uuid.NewString(),
+		Name:      BootstrapTokenName,
+		TokenHash: hash,
+		Scopes:    staticBootstrapScopes(),
+		CreatedBy: "config",
+		CreatedAt: now,
+	}
+	for _, prev := range recs {
+		if prev.Name == BootstrapTokenName && prev.Active(now) {
+			return s.store.RotateAdminToken(ctx, prev.ID, rec, now, now)
+		}
+	}
+	return s.store.CreateAdminToken(ctx, rec, now)
+}
+
+// LooksLikeAdminToken tells tokens issued by CreateAdminToken from session
+// tokens, so only those are looked up in the admin token store.
+func LooksLikeAdminToken(token string) bool {
+	return strings.HasPrefix(token, adminTokenPrefix)
+}
+
+// AuthenticateAdminToken checks token against the admin token store. known
+// reports whether the store holds the token at all, active or not, so the
+// caller can tell a retired bootstrap token from an unseeded one.
+func (s *Service) AuthenticateAdminToken(ctx context.Context, token string) (p Principal, known bool, err error) {
+	rec, err := s.store.GetAdminTokenByHash(ctx, hashToken(token))
+	if errors.Is(err, ledger.ErrAdminTokenNotFound) {
+		return Principal{}, false, nil
+	}
+	if err != nil {
+		return Principal{}, false, err
+	}
+	if !rec.Active(time.Now().UTC()) {
+		return Principal{}, true, ledger.ErrAdminTokenInactive
+	}
+	p = Principal{AuthType: "static", Scopes: append([]string{}, rec.Scopes...)}
+	if rec.Name != BootstrapTokenName {
+		p.AuthType = AuthTypeAdminToken
+		p.Address = "admin-token:" + rec.Name
+	}
+	return p, true, nil
+}
+
+// CreateAdminToken issues a named static token. Scopes default to the
+// bootstrap scopes and are cut down to those the issuer holds; ttl zero
+// means no expiry.
+func (s *Service) CreateAdminToken(ctx context.Context, name string, scopes []string, ttl time.Duration, createdBy string, issuer Principal) (IssuedAdminToken, error) {
+	name = strings.TrimSpace(name)
+	if !adminTokenNamePattern.MatchString(name) || name == BootstrapTokenName {
+		return IssuedAdminToken{}, fmt.Errorf("invalid admin token name %q", name)
+	}
+	scopes, err := adminTokenScopes(scopes)
+	if err != nil {
+		return IssuedAdminToken{}, err
+	}
+	if scopes = grantableScopes(issuer, scopes); len(scopes) == 0 {
+		return IssuedAdminToken{}, ErrAdminTokenScopes
+	}
+	if ttl < 0 {
+		return IssuedAdminToken{}, errors.New("ttl must not be negative")
+	}
+	now := time.Now().UTC()
+	token, rec, err := newAdminTokenRecord(name, scopes, ttl, createdBy, now)
+	if err != nil {
+		return IssuedAdminToken{}, err
+	}
+	if err := s.store.CreateAdminToken(ctx, rec, now); err != nil {
+		return IssuedAdminToken{}, err
+	}
+	return IssuedAdminToken{AdminToken: adminTokenView(rec, now), Token: token}, nil
+}
+
+// RotateAdminToken replaces an active token with a new secret under the same
+// name and scopes. The old secret keeps working for grace, so clients can be
+// updated first. Only an issuer holding all of the token's scopes may
+// rotate it, since the new secret is returned to them.
+func (s *Service) RotateAdminToken(ctx context.Context, id string, ttl, grace time.Duration, rotatedBy string, issuer Principal) (IssuedAdminToken, error) {
+	if ttl < 0 || grace < 0 {
+		return IssuedAdminToken{}, errors.New("ttl and grace must not be negative")
+	}
+	old, err := s.store.GetAdminToken(ctx, id)
+	if err != nil {
+		return IssuedAdminToken{}, err
+	}
+	if len(grantableScopes(issuer, old.Scopes)) != len(old.Scopes) {
+		return IssuedAdminToken{}, ErrAdminTokenScopes
+	}
+	now := time.Now().UTC()
+	token, rec, err := newAdminTokenRecord(old.Name, old.Scopes, ttl, rotatedBy, now)
+	if err != nil {
+		return IssuedAdminToken{}, err
+	}
+	if err := s.store.RotateAdminToken(ctx, id, rec, now.Add(grace), now); err != nil {
+		return IssuedAdminToken{}, err
+	}
+	return IssuedAdminToken{AdminToken: adminTokenView(rec, now), Token: token}, nil
+}
+
+func (s *Service) RevokeAdminToken(ctx context.Context, id string) error {
+	return s.store.RevokeAdminToken(ctx, id, time.Now().UTC())
+}
+
+func (s *Service) ListAdminTokens(ctx context.Context) ([]AdminToken, error) {
+	recs, err := s.store.ListAdminTokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	out := make([]AdminToken, 0, len(recs))
+	for _, rec := range recs {
+		out = append(out, adminTokenView(rec, now))
+	}
+	return out, nil
+}
+
+func newAdminTokenRecord(name string, scopes []string, ttl time.Duration, createdBy string, now time.Time) (string, ledger.AdminTokenRecord, error) {
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", ledger.AdminTokenRecord{}, err
+	}
+	token := adminTokenPrefix + secret
+	rec := ledger.AdminTokenRecord{
+		ID:        uuid.NewString(),
+		Name:      name,
+		TokenHash: hashToken(token),
+		Scopes:    scopes,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+	}
+	if ttl > 0 {
+		rec.ExpiresAt = now.Add(ttl)
+	}
+	return token, rec, nil
+}
+
+// grantableScopes keeps the scopes issuer may hand out: any for the
+// bootstrap token and admin principals, otherwise only those it holds.
+func grantableScopes(issuer Principal, scopes []string) []string {
+	if issuer.Admin || issuer.AuthType == "static" {
+		return scopes
+	}
+	out := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if issuer.HasScope(scope) {
+			out = append(out, scope)
+		}
+	}
+	return out
+}
+
+// adminTokenScopes validates requested scopes strictly: unlike pairing, an
+// unknown scope is an error rather than dropped.
+func adminTokenScopes(in []string) ([]string, error) {
+	if len(in) == 0 {
+		return staticBootstrapScopes(), nil
+	}
+	seen := map[string]struct{}{}
+	out := make([]string, 0, len(in))
+	for _, scope := range in {
+		scope = strings.TrimSpace(scope)
+		if _, ok := allScopes[scope]; !ok && scope != ScopeAdmin {
+			return nil, fmt.Errorf("invalid scope %q", scope)
+		}
+		if _, ok := seen[scope]; ok {
+			continue
+		}
+		seen[scope] = struct{}{}
+		out = append(out, scope)
+	}
+	return out, nil
+}
+
+func adminTokenView(rec ledger.AdminTokenRecord, now time.Time) AdminToken {
+	view := AdminToken{
+		ID:         rec.ID,
+		Name:       rec.Name,
+		Scopes:     rec.Scopes,
+		CreatedBy:  rec.CreatedBy,
+		CreatedAt:  rec.CreatedAt,
+		ReplacedBy: rec.ReplacedBy,
+	}
+	if !rec.ExpiresAt.IsZero() {
+		t := rec.ExpiresAt
+		view.ExpiresAt = &t
+	}
+	if !rec.RevokedAt.IsZero() {
+		t := rec.RevokedAt
+		view.RevokedAt = &t
+	}
+	switch {
+	case !rec.RevokedAt.IsZero():
+		view.Status = "revoked"
+	case rec.ReplacedBy != "":
+		view.Status = "rotated"
+	case !rec.Active(now):
+		view.Status = "expired"
+	default:
+		view.Status = "active"
+	}
+	return view
+}
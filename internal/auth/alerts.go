@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"echohelix/internal/notify"
+)
+
+// SecurityAlert is a suspicious-activity signal such as an auth failure
+// burst or a drastic session IP change. Detail holds the key=value fields
+// of its security_alert log line.
+type SecurityAlert struct {
+	Event  string    `json:"event"`
+	IP     string    `json:"ip,omitempty"`
+	Detail string    `json:"detail"`
+	TS     time.Time `json:"ts"`
+}
+
+type SecurityAlertNotifier func(ctx context.Context, alert SecurityAlert)
+
+// SetSecurityAlertNotifier receives every alert in addition to the
+// security_alert log line.
+func (s *Service) SetSecurityAlertNotifier(fn SecurityAlertNotifier) {
+	s.mu.Lock()
+	s.alertNotifier = fn
+	s.mu.Unlock()
+}
+
+// RaiseSecurityAlert logs alert and hands it to the notifier, if any.
+func (s *Service) RaiseSecurityAlert(alert SecurityAlert) {
+	s.mu.Lock()
+	fn := s.alertNotifier
+	s.mu.Unlock()
+	DispatchSecurityAlert(fn, alert)
+}
+
+// DispatchSecurityAlert logs alert and calls fn, when set, in the
+// background.
+func DispatchSecurityAlert(fn SecurityAlertNotifier, alert SecurityAlert) {
+	if alert.TS.IsZero() {
+		alert.TS = time.Now().UTC()
+	}
+	log.Printf("security_alert event=%s %s", alert.Event, alert.Detail)
+	if fn == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		fn(ctx, alert)
+	}()
+}
+
+// NewEmailSecurityAlertNotifier mails alerts through mailer. Repeats of an
+// event from the same IP within cooldown are dropped, since a burst alert
+// fires on every request past its threshold.
+func NewEmailSecurityAlertNotifier(mailer *notify.Mailer, cooldown time.Duration) SecurityAlertNotifier {
+	var (
+		mu   sync.Mutex
+		last = map[string]time.Time{}
+	)
+	return func(ctx context.Context, alert SecurityAlert) {
+		key := alert.Event + " " + alert.IP
+		mu.Lock()
+		if prev, ok := last[key]; ok && alert.TS.Sub(prev) < cooldown {
+			mu.Unlock()
+			return
+		}
+		last[key] = alert.TS
+		for k, at := range last {
+			if alert.TS.Sub(at) >= cooldown {
+				delete(last, k)
+			}
+		}
+		mu.Unlock()
+
+		body := fmt.Sprintf("Security alert: %s\n\nTime: %s\nIP: %s\n%s\n", alert.Event, alert.TS.UTC().Format(time.RFC3339), alert.IP, alert.Detail)
+		if err := mailer.Send(ctx, "Security alert: "+alert.Event, body); err != nil {
+			log.Printf("security alert notify: %v", err)
+		}
+	}
+}
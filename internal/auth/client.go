@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/netip"
+	"time"
+
+	"echohelix/internal/ledger"
+)
+
+// ClientInfo identifies where a request came from. The API attaches it to
+// the request context so authentication and refresh can track it per
+// session.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+type clientInfoKey struct{}
+
+func WithClientInfo(ctx context.Context, info ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+func clientInfoFrom(ctx context.Context) ClientInfo {
+	info, _ := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return info
+}
+
+// SessionIPAlert is the latest suspicious IP change seen on a session.
+type SessionIPAlert struct {
+	FromIP string    `json:"from_ip"`
+	ToIP   string    `json:"to_ip"`
+	At     time.Time `json:"at"`
+}
+
+type DeviceSession struct {
+	SessionID string    `json:"session_id"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt is when the session can no longer be refreshed.
+	ExpiresAt time.Time `json:"expires_at"`
+	// LastUsedAt is the last request with an opaque access token, or the
+	// last refresh; JWT access tokens are verified without a ledger write.
+	LastUsedAt    time.Time       `json:"last_used_at,omitempty"`
+	Current       bool            `json:"current,omitempty"`
+	LastIP        string          `json:"last_ip,omitempty"`
+	LastUserAgent string          `json:"last_user_agent,omitempty"`
+	LastIPAt      time.Time       `json:"last_ip_at,omitempty"`
+	IPAlert       *SessionIPAlert `json:"ip_alert,omitempty"`
+}
+
+// IPChangeIsDrastic reports whether a session moving from prev to cur looks
+// like a different location rather than the same client roaming: both
+// addresses are public, of the same family, and outside a common /16 (IPv4)
+// or /32 (IPv6). Private, loopback and link-local addresses never count, so
+// switching between LAN and a tunnel does not alert.
+func IPChangeIsDrastic(prev, cur string) bool {
+	a, errA := netip.ParseAddr(prev)
+	b, errB := netip.ParseAddr(cur)
+	if errA != nil || errB != nil {
+		return false
+	}
+	a, b = a.Unmap(), b.Unmap()
+	if a.Is4() != b.Is4() || !isPublicAddr(a) || !isPublicAddr(b) {
+		return false
+	}
+	bits := 32
+	if a.Is4() {
+		bits = 16
+	}
+	pa, _ := a.Prefix(bits)
+	return !pa.Contains(b)
+}
+
+func isPublicAddr(a netip.Addr) bool {
+	return a.IsGlobalUnicast() && !a.IsPrivate()
+}
+
+// observeClient records the client a session is used from and raises a
+// security alert when its IP changes drastically. The ledger is only
+// written when the client changed.
+func (s *Service) observeClient(ctx context.Context, sess ledger.SessionRecord, kind string, now time.Time) {
+	info := clientInfoFrom(ctx)
+	if info.IP == "" || (info.IP == sess.LastIP && info.UserAgent == sess.LastUserAgent) {
+		return
+	}
+	if sess.LastIP != "" && IPChangeIsDrastic(sess.LastIP, info.IP) {
+		s.RaiseSecurityAlert(SecurityAlert{
+			Event:  "session_ip_change",
+			IP:     info.IP,
+			Detail: fmt.Sprintf("address=%s session_id=%s via=%s from=%s to=%s user_agent=%q", sess.Address, sess.SessionID, kind, sess.LastIP, info.IP, info.UserAgent),
+			TS:     now,
+		})
+		if err := s.store.FlagSessionIPChange(ctx, sess.SessionID, sess.LastIP, info.IP, now); err != nil {
+			log.Printf("flag session ip change: %v", err)
+		}
+	}
+	if err := s.store.RecordSessionClient(ctx, sess.SessionID, info.IP, info.UserAgent, now); err != nil {
+		log.Printf("record session client: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// The bridge identity is an ed25519 keypair generated on first start and
+// kept in the ledger. Its fingerprint travels with the pair code (QR code or
+// elix:// URI) and pair/complete responses are signed with it, so a client
+// can tell it paired with the bridge that issued the code and not with a
+// man in the middle.
+
+const pairCompleteSignatureContext = "elix-pair-complete-v1"
+
+type BridgeIdentity struct {
+	PublicKey   string    `json:"public_key"`
+	Fingerprint string    `json:"fingerprint"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	private ed25519.PrivateKey
+}
+
+// IdentityFingerprint formats an ed25519 public key as "SHA256:" followed by
+// the unpadded base64 SHA-256 digest, like ssh-keygen -l.
+func IdentityFingerprint(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// PairCompleteSignedMessage is the message a bridge signs in pair/complete
+// responses. It binds the pair challenge to the device key the bridge
+// accepted, so a relayed response for another key fails verification.
+func PairCompleteSignedMessage(challenge, devicePublicKey, address string) []byte {
+	return []byte(pairCompleteSignatureContext + "\n" + challenge + "\n" + devicePublicKey + "\n" + address)
+}
+
+// Identity returns the bridge identity, generating and persisting it the
+// first time it is needed.
+func (s *Service) Identity(ctx context.Context) (BridgeIdentity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.identity != nil {
+		return *s.identity, nil
+	}
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return BridgeIdentity{}, err
+	}
+	stored, createdAt, err := s.store.EnsureBridgeIdentity(ctx, base64.RawURLEncoding.EncodeToString(seed), time.Now().UTC())
+	if err != nil {
+		return BridgeIdentity{}, fmt.Errorf("load bridge identity: %w", err)
+	}
+	seed, err = base64.RawURLEncoding.DecodeString(stored)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return BridgeIdentity{}, fmt.Errorf("bridge identity key is corrupt")
+	}
+	priv := ed25519.NewKeyFromSeed(seed)
+	pub := priv.Public().(ed25519.PublicKey)
+	s.identity = &BridgeIdentity{
+		PublicKey:   base64.RawURLEncoding.EncodeToString(pub),
+		Fingerprint: IdentityFingerprint(pub),
+		CreatedAt:   createdAt,
+		private:     priv,
+	}
+	return *s.identity, nil
+}
+
+func (id BridgeIdentity) sign(msg []byte) string {
+	return base64.RawURLEncoding.EncodeToString(ed25519.Sign(id.private, msg))
+}
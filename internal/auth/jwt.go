@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Access token formats. Opaque tokens are looked up in the ledger on every
+// request; JWT access tokens are Ed25519-signed with the bridge identity and
+// verified in-process, with revocations picked up from the ledger at most
+// every RevocationSyncInterval.
+const (
+	AccessTokenOpaque = "opaque"
+	AccessTokenJWT    = "jwt"
+
+	jwtIssuer = "elix-bridge"
+)
+
+var ErrTokenRevoked = errors.New("access token revoked")
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid,omitempty"`
+}
+
+type accessClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	SessionID string   `json:"sid"`
+	Scopes    []string `json:"scp"`
+	IssuedAt  int64    `json:"iat"`
+	ExpiresAt int64    `json:"exp"`
+	ID        string   `json:"jti"`
+}
+
+// looksLikeJWT tells JWT access tokens from opaque ones, which are base64url
+// without dots.
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func (s *Service) signAccessJWT(ctx context.Context, sessionID, address string, scopes []string, now, expiresAt time.Time) (string, error) {
+	identity, err := s.Identity(ctx)
+	if err != nil {
+		return "", err
+	}
+	header, _ := json.Marshal(jwtHeader{Alg: "EdDSA", Typ: "JWT", Kid: identity.Fingerprint})
+	claims, _ := json.Marshal(accessClaims{
+		Issuer:    jwtIssuer,
+		Subject:   address,
+		SessionID: sessionID,
+		Scopes:    scopes,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+		ID:        uuid.NewString(),
+	})
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	sig := ed25519.Sign(identity.private, []byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// verifyAccessJWT checks the signature, issuer and expiry of a JWT access
+// token and that its session has not been revoked or refreshed since.
+func (s *Service) verifyAccessJWT(ctx context.Context, token string, now time.Time) (accessClaims, error) {
+	identity, err := s.Identity(ctx)
+	if err != nil {
+		return accessClaims{}, err
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return accessClaims{}, errors.New("malformed access token")
+	}
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil || header.Alg != "EdDSA" {
+		return accessClaims{}, errors.New("unsupported access token")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !ed25519.Verify(identity.private.Public().(ed25519.PublicKey), []byte(parts[0]+"."+parts[1]), sig) {
+		return accessClaims{}, errors.New("invalid access token signature")
+	}
+	var claims accessClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return accessClaims{}, errors.New("malformed access token")
+	}
+	if claims.Issuer != jwtIssuer || claims.SessionID == "" || claims.Subject == "" {
+		return accessClaims{}, errors.New("invalid access token claims")
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return accessClaims{}, errors.New("access token expired")
+	}
+	revoked, err := s.tokenRevoked(ctx, token, claims, now)
+	if err != nil {
+		return accessClaims{}, err
+	}
+	if revoked {
+		return accessClaims{}, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+func decodeJWTPart(part string, v any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// revocationCache mirrors recently revoked and rotated sessions so JWT
+// verification needs no ledger lookup per request. A rotated session only
+// accepts the access token issued by its latest refresh. Entries older than
+// the access token TTL are pruned, since no token issued before them is
+// still valid.
+type revocationCache struct {
+	mu        sync.Mutex
+	revoked   map[string]time.Time
+	addresses map[string]time.Time
+	current   map[string]currentToken
+	syncedAt  time.Time
+	syncing   chan struct{} // closed when the running sync finishes
+}
+
+type currentToken struct {
+	hash string
+	at   time.Time
+}
+
+func (c *revocationCache) init() {
+	if c.revoked == nil {
+		c.revoked = map[string]time.Time{}
+		c.addresses = map[string]time.Time{}
+		c.current = map[string]currentToken{}
+	}
+}
+
+func (s *Service) tokenRevoked(ctx context.Context, token string, claims accessClaims, now time.Time) (bool, error) {
+	if err := s.syncRevocations(ctx, now); err != nil {
+		return false, err
+	}
+	c := &s.revocations
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.revoked[claims.SessionID]; ok {
+		return true, nil
+	}
+	if _, ok := c.addresses[claims.Subject]; ok {
+		return true, nil
+	}
+	cur, ok := c.current[claims.SessionID]
+	return ok && cur.hash != hashToken(token), nil
+}
+
+// syncRevocations refreshes the cache from the ledger once it is older than
+// the sync interval. The query runs without the lock held, so verifications
+// keep using the cache meanwhile; only before the first sync completes do
+// they wait for it.
+func (s *Service) syncRevocations(ctx context.Context, now time.Time) error {
+	c := &s.revocations
+	c.mu.Lock()
+	c.init()
+	if now.Sub(c.syncedAt) < s.cfg.RevocationSyncInterval {
+		c.mu.Unlock()
+		return nil
+	}
+	if c.syncing != nil {
+		wait, first := c.syncing, c.syncedAt.IsZero()
+		c.mu.Unlock()
+		if first {
+			select {
+			case <-wait:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+	done := make(chan struct{})
+	c.syncing = done
+	horizon := now.Add(-s.cfg.AccessTokenTTL)
+	since := horizon
+	if c.syncedAt.After(since) {
+		// Overlap the previous sync so changes committed during it are
+		// not missed.
+		since = c.syncedAt.Add(-s.cfg.RevocationSyncInterval)
+	}
+	c.mu.Unlock()
+
+	changes, err := s.store.ListSessionChanges(ctx, since)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.syncing = nil
+	close(done)
+	if err != nil {
+		return err
+	}
+	for _, ch := range changes {
+		if ch.Revoked {
+			c.revoked[ch.SessionID] = ch.RevokedAt
+		} else if cur, ok := c.current[ch.SessionID]; !ok || !ch.RotatedAt.Before(cur.at) {
+			// A rotation applied locally while the query ran is newer.
+			c.current[ch.SessionID] = currentToken{hash: ch.AccessHash, at: ch.RotatedAt}
+		}
+	}
+	for id, at := range c.revoked {
+		if at.Before(horizon) {
+			delete(c.revoked, id)
+		}
+	}
+	for addr, at := range c.addresses {
+		if at.Before(horizon) {
+			delete(c.addresses, addr)
+		}
+	}
+	for id, cur := range c.current {
+		if cur.at.Before(horizon) {
+			delete(c.current, id)
+		}
+	}
+	c.syncedAt = now
+	return nil
+}
+
+// revokeAddress, revokeSession and rotated apply this instance's own changes
+// right away; other instances see them at their next sync.
+func (c *revocationCache) revokeAddress(address string, at time.Time) {
+	c.mu.Lock()
+	c.init()
+	c.addresses[address] = at
+	c.mu.Unlock()
+}
+
+func (c *revocationCache) revokeSession(sessionID string, at time.Time) {
+	c.mu.Lock()
+	c.init()
+	c.revoked[sessionID] = at
+	c.mu.Unlock()
+}
+
+func (c *revocationCache) rotated(sessionID, accessHash string, at time.Time) {
+	c.mu.Lock()
+	c.init()
+	c.current[sessionID] = currentToken{hash: accessHash, at: at}
+	c.mu.Unlock()
+}
@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"echohelix/internal/signing"
+)
+
+type PendingPairView struct {
+	PairCode         string    `json:"pair_code"`
+	CreatedBy        string    `json:"created_by"`
+	Permissions      []string  `json:"permissions"`
+	TrustLevel       string    `json:"trust_level"`
+	CreatedAt        time.Time `json:"created_at"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	ExpiresInSeconds int64     `json:"expires_in_seconds"`
+}
+
+// PairExpiredNotice describes a pair code that expired without being used.
+type PairExpiredNotice struct {
+	PairCode    string    `json:"pair_code"`
+	CreatedBy   string    `json:"created_by"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type PairExpiryNotifier func(ctx context.Context, notice PairExpiredNotice)
+
+type PairSweepResult struct {
+	ExpiredUnused int   `json:"expired_unused"`
+	Deleted       int64 `json:"deleted"`
+}
+
+func (s *Service) SetPairExpiryNotifier(fn PairExpiryNotifier) {
+	s.mu.Lock()
+	s.pairExpiryNotifier = fn
+	s.mu.Unlock()
+}
+
+// ListPendingPairs returns unused pair codes that have not expired yet.
+func (s *Service) ListPendingPairs(ctx context.Context, now time.Time) ([]PendingPairView, error) {
+	recs, err := s.store.ListPairCodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]PendingPairView, 0, len(recs))
+	for _, rec := range recs {
+		if rec.Used || !now.Before(rec.ExpiresAt) {
+			continue
+		}
+		out = append(out, PendingPairView{
+			PairCode:         rec.Code,
+			CreatedBy:        rec.CreatedBy,
+			Permissions:      rec.Permissions,
+			TrustLevel:       pairTrustLevel(rec),
+			CreatedAt:        rec.CreatedAt,
+			ExpiresAt:        rec.ExpiresAt,
+			ExpiresInSeconds: int64(rec.ExpiresAt.Sub(now).Seconds()),
+		})
+	}
+	return out, nil
+}
+
+// SweepPairCodes deletes pair codes past their expiry, used or not, after
+// notifying the creator of each one that expired unused.
+func (s *Service) SweepPairCodes(ctx context.Context, now time.Time) (PairSweepResult, error) {
+	recs, err := s.store.ListPairCodes(ctx)
+	if err != nil {
+		return PairSweepResult{}, err
+	}
+	s.mu.Lock()
+	notify := s.pairExpiryNotifier
+	s.mu.Unlock()
+
+	var out PairSweepResult
+	var dead []string
+	for _, rec := range recs {
+		if now.Before(rec.ExpiresAt) {
+			continue
+		}
+		dead = append(dead, rec.Code)
+		if rec.Used {
+			continue
+		}
+		out.ExpiredUnused++
+		log.Printf("audit event=pair_code_expired created_by=%q expires_at=%s", rec.CreatedBy, rec.ExpiresAt.Format(time.RFC3339))
+		if notify != nil {
+			notify(ctx, PairExpiredNotice{
+				PairCode:    rec.Code,
+				CreatedBy:   rec.CreatedBy,
+				Permissions: rec.Permissions,
+				CreatedAt:   rec.CreatedAt,
+				ExpiresAt:   rec.ExpiresAt,
+			})
+		}
+	}
+	out.Deleted, err = s.store.DeletePairCodes(ctx, dead)
+	return out, err
+}
+
+// SetLeaderCheck makes the pair code sweeper run only while leader reports
+// true, so one of several bridges sharing a ledger sends expiry notices.
+func (s *Service) SetLeaderCheck(leader func() bool) {
+	s.mu.Lock()
+	s.leaderCheck = leader
+	s.mu.Unlock()
+}
+
+func (s *Service) isLeader() bool {
+	s.mu.Lock()
+	leader := s.leaderCheck
+	s.mu.Unlock()
+	return leader == nil || leader()
+}
+
+func (s *Service) StartPairCodeSweeper(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if !s.isLeader() {
+				continue
+			}
+			if _, err := s.SweepPairCodes(ctx, time.Now().UTC()); err != nil {
+				log.Printf("sweep pair codes: %v", err)
+			}
+		}
+	}()
+}
+
+// NewWebhookPairExpiryNotifier POSTs each notice as JSON to url, signed with
+// signer when one is configured.
+func NewWebhookPairExpiryNotifier(url string, signer *signing.Signer) PairExpiryNotifier {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return func(ctx context.Context, notice PairExpiredNotice) {
+		if err := signing.PostJSON(ctx, client, url, signer, map[string]any{
+			"event":  "pair_code_expired",
+			"notice": notice,
+		}); err != nil {
+			log.Printf("pair expiry notify: %v", err)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"echohelix/internal/ledger"
+
+	"github.com/google/uuid"
+)
+
+var ErrScopeApprovalForbidden = errors.New("approver may not grant this scope request")
+
+// ScopeRequest is a device's request for additional scopes. TrustLevel is
+// the device's tier, or for a pending request the tier approval would give.
+type ScopeRequest struct {
+	ID         string     `json:"id"`
+	Address    string     `json:"address"`
+	Scopes     []string   `json:"scopes"`
+	Reason     string     `json:"reason,omitempty"`
+	Status     string     `json:"status"`
+	TrustLevel string     `json:"trust_level"`
+	CreatedAt  time.Time  `json:"created_at"`
+	DecidedAt  *time.Time `json:"decided_at,omitempty"`
+	DecidedBy  string     `json:"decided_by,omitempty"`
+	Note       string     `json:"note,omitempty"`
+}
+
+// RequestScopes records a pending request for the scopes the device does not
+// hold yet. A device may have one pending request at a time.
+func (s *Service) RequestScopes(ctx context.Context, address string, scopes []string, reason string) (ScopeRequest, error) {
+	dev, err := s.store.GetDevice(ctx, address)
+	if err != nil {
+		return ScopeRequest{}, err
+	}
+	if dev.Revoked {
+		return ScopeRequest{}, ledger.ErrDeviceRevoked
+	}
+	held := map[string]struct{}{}
+	for _, scope := range dev.Permissions {
+		held[scope] = struct{}{}
+	}
+	missing := []string{}
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if _, ok := allScopes[scope]; !ok {
+			return ScopeRequest{}, fmt.Errorf("invalid scope %q", scope)
+		}
+		if _, ok := held[scope]; !ok {
+			missing = append(missing, scope)
+		}
+	}
+	if len(missing) == 0 {
+		return ScopeRequest{}, errors.New("device already holds the requested scopes")
+	}
+	rec := ledger.ScopeRequestRecord{
+		ID:        uuid.NewString(),
+		Address:   address,
+		Scopes:    sortScopes(missing),
+		Reason:    strings.TrimSpace(reason),
+		Status:    ledger.ScopeRequestPending,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.store.CreateScopeRequest(ctx, rec); err != nil {
+		return ScopeRequest{}, err
+	}
+	return scopeRequestView(rec, dev), nil
+}
+
+// ListScopeRequests returns requests newest first; address limits them to
+// one device.
+func (s *Service) ListScopeRequests(ctx context.Context, address string) ([]ScopeRequest, error) {
+	recs, err := s.store.ListScopeRequests(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := s.store.ListDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byAddress := map[string]ledger.DeviceRecord{}
+	for _, dev := range devices {
+		byAddress[dev.Address] = dev
+	}
+	out := make([]ScopeRequest, 0, len(recs))
+	for _, rec := range recs {
+		out = append(out, scopeRequestView(rec, byAddress[rec.Address]))
+	}
+	return out, nil
+}
+
+// DecideScopeRequest approves or denies a pending request. Approval adds the
+// scopes to the device and its active sessions and raises its trust tier as
+// far as the merged scopes need. Bootstrap operators may decide any request;
+// a device may decide another device's request only if its own tier is at
+// least operator and at least the tier the approval results in.
+func (s *Service) DecideScopeRequest(ctx context.Context, id string, approve bool, approver Principal, note string) (ScopeRequest, error) {
+	rec, err := s.store.GetScopeRequest(ctx, id)
+	if err != nil {
+		return ScopeRequest{}, err
+	}
+	dev, err := s.store.GetDevice(ctx, rec.Address)
+	if err != nil {
+		return ScopeRequest{}, err
+	}
+	merged := sortScopes(append(append([]string{}, dev.Permissions...), rec.Scopes...))
+	level := grantedTrustLevel(dev, rec.Scopes)
+
+	decidedBy := approver.Address
+	if approver.AuthType == AuthTypeAdminToken && !approver.IsOperator() {
+		return ScopeRequest{}, fmt.Errorf("%w: admin token lacks the %s scope", ErrScopeApprovalForbidden, ScopeAdmin)
+	}
+	if !approver.IsOperator() {
+		if approver.Address == rec.Address {
+			return ScopeRequest{}, fmt.Errorf("%w: devices cannot decide their own requests", ErrScopeApprovalForbidden)
+		}
+		approverDev, err := s.store.GetDevice(ctx, approver.Address)
+		if err != nil {
+			return ScopeRequest{}, err
+		}
+		approverLevel := deviceTrustLevel(approverDev)
+		if approverDev.Revoked || trustRank(approverLevel) < trustRank(TrustOperator) || trustRank(approverLevel) < trustRank(level) {
+			return ScopeRequest{}, fmt.Errorf("%w: requires trust level %s", ErrScopeApprovalForbidden, level)
+		}
+	} else if decidedBy == "" {
+		decidedBy = approver.AuthType
+	}
+
+	now := time.Now().UTC()
+	note = strings.TrimSpace(note)
+	if err := s.store.DecideScopeRequest(ctx, id, approve, merged, level, decidedBy, note, now); err != nil {
+		return ScopeRequest{}, err
+	}
+	if approve {
+		dev.Permissions = merged
+		dev.TrustLevel = level
+	}
+	rec.Status = ledger.ScopeRequestDenied
+	if approve {
+		rec.Status = ledger.ScopeRequestApproved
+	}
+	rec.DecidedAt = now
+	rec.DecidedBy = decidedBy
+	rec.DecideNote = note
+	return scopeRequestView(rec, dev), nil
+}
+
+// grantedTrustLevel is the device's tier after gaining scopes: unchanged if
+// the tier covers them, otherwise the lowest tier that covers everything.
+func grantedTrustLevel(dev ledger.DeviceRecord, scopes []string) string {
+	current := deviceTrustLevel(dev)
+	needed := trustLevelFor(append(append([]string{}, dev.Permissions...), scopes...))
+	if trustRank(needed) > trustRank(current) {
+		return needed
+	}
+	return current
+}
+
+func scopeRequestView(rec ledger.ScopeRequestRecord, dev ledger.DeviceRecord) ScopeRequest {
+	view := ScopeRequest{
+		ID:         rec.ID,
+		Address:    rec.Address,
+		Scopes:     rec.Scopes,
+		Reason:     rec.Reason,
+		Status:     rec.Status,
+		TrustLevel: deviceTrustLevel(dev),
+		CreatedAt:  rec.CreatedAt,
+		DecidedBy:  rec.DecidedBy,
+		Note:       rec.DecideNote,
+	}
+	if rec.Status == ledger.ScopeRequestPending {
+		view.TrustLevel = grantedTrustLevel(dev, rec.Scopes)
+	}
+	if !rec.DecidedAt.IsZero() {
+		t := rec.DecidedAt
+		view.DecidedAt = &t
+	}
+	return view
+}
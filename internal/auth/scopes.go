@@ -8,6 +8,10 @@ const (
 	ScopePairStart    = "pair:start"
 	ScopeDevicesRead  = "devices:read"
 	ScopeDevicesWrite = "devices:write"
+	// ScopeAdmin makes a named admin token an operator, allowed on the
+	// admin endpoints. Only admin tokens can hold it; pairing and scope
+	// requests never grant it.
+	ScopeAdmin = "admin"
 )
 
 var allScopes = map[string]struct{}{
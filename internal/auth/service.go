@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"echohelix/internal/ledger"
@@ -22,13 +23,30 @@ type Config struct {
 	AccessTokenTTL  time.Duration
 	RefreshTokenTTL time.Duration
 	PairCodeTTL     time.Duration
+	// AccessTokenFormat is AccessTokenOpaque (default) or AccessTokenJWT.
+	AccessTokenFormat string
+	// RevocationSyncInterval is how stale the JWT revocation cache may
+	// get before it is reloaded from the ledger. Zero means 5s.
+	RevocationSyncInterval time.Duration
 }
 
 type Service struct {
 	store *ledger.Store
 	cfg   Config
+
+	mu                 sync.Mutex
+	pairExpiryNotifier PairExpiryNotifier
+	alertNotifier      SecurityAlertNotifier
+	leaderCheck        func() bool
+	identity           *BridgeIdentity
+
+	revocations revocationCache
 }
 
+// AuthTypeAdminToken marks principals authenticated with a named admin
+// token. The bootstrap token authenticates as "static".
+const AuthTypeAdminToken = "admin_token"
+
 type Principal struct {
 	AuthType  string
 	Admin     bool
@@ -41,7 +59,11 @@ type PairStartResult struct {
 	PairCode    string    `json:"pair_code"`
 	Challenge   string    `json:"challenge"`
 	Permissions []string  `json:"permissions"`
+	TrustLevel  string    `json:"trust_level"`
 	ExpiresAt   time.Time `json:"expires_at"`
+	// BridgeFingerprint identifies the bridge that issued the code; clients
+	// check it against the identity in the pair/complete response.
+	BridgeFingerprint string `json:"bridge_fingerprint"`
 }
 
 type CompletePairRequest struct {
@@ -56,10 +78,16 @@ type CompletePairResult struct {
 	PublicKey        string    `json:"public_key"`
 	DeviceName       string    `json:"device_name"`
 	Scopes           []string  `json:"scopes"`
+	TrustLevel       string    `json:"trust_level"`
 	AccessToken      string    `json:"access_token"`
 	RefreshToken     string    `json:"refresh_token"`
 	ExpiresAt        time.Time `json:"expires_at"`
 	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	// The bridge identity and its signature over
+	// PairCompleteSignedMessage(challenge, public_key, address).
+	BridgePublicKey   string `json:"bridge_public_key"`
+	BridgeFingerprint string `json:"bridge_fingerprint"`
+	BridgeSignature   string `json:"bridge_signature"`
 }
 
 type RefreshResult struct {
@@ -76,11 +104,16 @@ type DeviceView struct {
 	Name         string    `json:"name"`
 	PublicKey    string    `json:"public_key"`
 	Permissions  []string  `json:"permissions"`
+	TrustLevel   string    `json:"trust_level"`
 	CreatedAt    time.Time `json:"created_at"`
 	LastSeenAt   time.Time `json:"last_seen_at"`
 	Revoked      bool      `json:"revoked"`
 	RevokedAt    time.Time `json:"revoked_at,omitempty"`
 	RevokeReason string    `json:"revoke_reason,omitempty"`
+	// Sessions are the device's active sessions; IPAlert is set when any
+	// of them saw a drastic client IP change.
+	Sessions []DeviceSession `json:"sessions"`
+	IPAlert  bool            `json:"ip_alert"`
 }
 
 func New(store *ledger.Store, cfg Config) *Service {
@@ -93,6 +126,12 @@ func New(store *ledger.Store, cfg Config) *Service {
 	if cfg.PairCodeTTL <= 0 {
 		cfg.PairCodeTTL = 60 * time.Second
 	}
+	if cfg.AccessTokenFormat != AccessTokenJWT {
+		cfg.AccessTokenFormat = AccessTokenOpaque
+	}
+	if cfg.RevocationSyncInterval <= 0 {
+		cfg.RevocationSyncInterval = 5 * time.Second
+	}
 	return &Service{
 		store: store,
 		cfg:   cfg,
@@ -114,6 +153,13 @@ func StaticBootstrapPrincipal() Principal {
 	}
 }
 
+// IsOperator reports whether p may use the operator-only endpoints: the
+// bootstrap token, admin principals, and named admin tokens holding
+// ScopeAdmin.
+func (p Principal) IsOperator() bool {
+	return p.Admin || p.AuthType == "static" || (p.AuthType == AuthTypeAdminToken && p.HasScope(ScopeAdmin))
+}
+
 func (p Principal) HasScope(scope string) bool {
 	if p.Admin {
 		return true
@@ -127,9 +173,23 @@ func (p Principal) HasScope(scope string) bool {
 }
 
 func (s *Service) StartPair(ctx context.Context, createdBy string, requestedScopes []string, ttl time.Duration) (PairStartResult, error) {
+	return s.StartPairAs(ctx, createdBy, "", requestedScopes, ttl)
+}
+
+// StartPairAs issues a pair code for a device of the given trust tier. An
+// empty tier is derived from the requested scopes.
+func (s *Service) StartPairAs(ctx context.Context, createdBy, trustLevel string, requestedScopes []string, ttl time.Duration) (PairStartResult, error) {
+	trustLevel, scopes, err := resolvePairTrust(trustLevel, requestedScopes)
+	if err != nil {
+		return PairStartResult{}, err
+	}
 	if ttl <= 0 || ttl > 10*time.Minute {
 		ttl = s.cfg.PairCodeTTL
 	}
+	identity, err := s.Identity(ctx)
+	if err != nil {
+		return PairStartResult{}, err
+	}
 	now := time.Now().UTC()
 	expiresAt := now.Add(ttl)
 	code, err := generatePairCode()
@@ -140,7 +200,6 @@ func (s *Service) StartPair(ctx context.Context, createdBy string, requestedScop
 	if err != nil {
 		return PairStartResult{}, err
 	}
-	scopes := normalizeScopes(requestedScopes)
 	if err := s.store.CreatePairCode(ctx, ledger.PairCodeRecord{
 		Code:        code,
 		Challenge:   challenge,
@@ -148,14 +207,41 @@ func (s *Service) StartPair(ctx context.Context, createdBy string, requestedScop
 		CreatedBy:   createdBy,
 		CreatedAt:   now,
 		ExpiresAt:   expiresAt,
+		TrustLevel:  trustLevel,
 	}); err != nil {
 		return PairStartResult{}, err
 	}
 	return PairStartResult{
-		PairCode:    code,
-		Challenge:   challenge,
-		Permissions: scopes,
-		ExpiresAt:   expiresAt,
+		PairCode:          code,
+		Challenge:         challenge,
+		Permissions:       scopes,
+		TrustLevel:        trustLevel,
+		ExpiresAt:         expiresAt,
+		BridgeFingerprint: identity.Fingerprint,
+	}, nil
+}
+
+// LookupPair returns the pairing payload for a pair code that is still
+// unused and unexpired, without consuming it.
+func (s *Service) LookupPair(ctx context.Context, code string) (PairStartResult, error) {
+	rec, err := s.store.GetPairCode(ctx, strings.TrimSpace(code))
+	if err != nil {
+		return PairStartResult{}, err
+	}
+	if rec.Used || time.Now().UTC().After(rec.ExpiresAt) {
+		return PairStartResult{}, ledger.ErrPairCodeInvalid
+	}
+	identity, err := s.Identity(ctx)
+	if err != nil {
+		return PairStartResult{}, err
+	}
+	return PairStartResult{
+		PairCode:          rec.Code,
+		Challenge:         rec.Challenge,
+		Permissions:       rec.Permissions,
+		TrustLevel:        pairTrustLevel(rec),
+		ExpiresAt:         rec.ExpiresAt,
+		BridgeFingerprint: identity.Fingerprint,
 	}, nil
 }
 
@@ -163,6 +249,10 @@ func (s *Service) CompletePair(ctx context.Context, req CompletePairRequest) (Co
 	if strings.TrimSpace(req.PairCode) == "" || strings.TrimSpace(req.PublicKey) == "" || strings.TrimSpace(req.Signature) == "" {
 		return CompletePairResult{}, errors.New("pair_code/public_key/signature are required")
 	}
+	identity, err := s.Identity(ctx)
+	if err != nil {
+		return CompletePairResult{}, err
+	}
 	now := time.Now().UTC()
 	pairRec, err := s.store.ConsumePairCode(ctx, strings.TrimSpace(req.PairCode), now)
 	if err != nil {
@@ -199,6 +289,7 @@ func (s *Service) CompletePair(ctx context.Context, req CompletePairRequest) (Co
 		Permissions: normalizeScopes(pairRec.Permissions),
 		CreatedAt:   now,
 		LastSeenAt:  now,
+		TrustLevel:  pairTrustLevel(pairRec),
 	})
 	if err != nil {
 		return CompletePairResult{}, err
@@ -209,14 +300,18 @@ func (s *Service) CompletePair(ctx context.Context, req CompletePairRequest) (Co
 		return CompletePairResult{}, err
 	}
 	return CompletePairResult{
-		Address:          device.Address,
-		PublicKey:        device.PublicKey,
-		DeviceName:       device.Name,
-		Scopes:           append([]string{}, device.Permissions...),
-		AccessToken:      tokens.AccessToken,
-		RefreshToken:     tokens.RefreshToken,
-		ExpiresAt:        tokens.ExpiresAt,
-		RefreshExpiresAt: tokens.RefreshExpiresAt,
+		Address:           device.Address,
+		PublicKey:         device.PublicKey,
+		DeviceName:        device.Name,
+		Scopes:            append([]string{}, device.Permissions...),
+		TrustLevel:        deviceTrustLevel(device),
+		AccessToken:       tokens.AccessToken,
+		RefreshToken:      tokens.RefreshToken,
+		ExpiresAt:         tokens.ExpiresAt,
+		RefreshExpiresAt:  tokens.RefreshExpiresAt,
+		BridgePublicKey:   identity.PublicKey,
+		BridgeFingerprint: identity.Fingerprint,
+		BridgeSignature:   identity.sign(PairCompleteSignedMessage(pairRec.Challenge, device.PublicKey, device.Address)),
 	}, nil
 }
 
@@ -225,11 +320,25 @@ func (s *Service) AuthenticateToken(ctx context.Context, accessToken string) (Pr
 		return Principal{}, errors.New("empty access token")
 	}
 	now := time.Now().UTC()
+	if looksLikeJWT(accessToken) {
+		claims, err := s.verifyAccessJWT(ctx, accessToken, now)
+		if err != nil {
+			return Principal{}, err
+		}
+		return Principal{
+			AuthType:  "session",
+			Address:   claims.Subject,
+			SessionID: claims.SessionID,
+			Scopes:    append([]string{}, claims.Scopes...),
+		}, nil
+	}
 	sess, dev, err := s.store.GetSessionByAccessHash(ctx, hashToken(accessToken), now)
 	if err != nil {
 		return Principal{}, err
 	}
 	_ = s.store.TouchDevice(ctx, dev.Address, now)
+	_ = s.store.TouchSession(ctx, sess.SessionID, now)
+	s.observeClient(ctx, sess, "auth", now)
 	return Principal{
 		AuthType:  "session",
 		Address:   dev.Address,
@@ -247,19 +356,22 @@ func (s *Service) RefreshSession(ctx context.Context, refreshToken string) (Refr
 	if err != nil {
 		return RefreshResult{}, err
 	}
-	accessToken, err := randomToken(48)
-	if err != nil {
-		return RefreshResult{}, err
-	}
 	newRefreshToken, err := randomToken(56)
 	if err != nil {
 		return RefreshResult{}, err
 	}
 	expiresAt := now.Add(s.cfg.AccessTokenTTL)
 	refreshExpiresAt := now.Add(s.cfg.RefreshTokenTTL)
-	if err := s.store.RotateSession(ctx, sess.SessionID, hashToken(accessToken), hashToken(newRefreshToken), expiresAt, refreshExpiresAt); err != nil {
+	accessToken, err := s.newAccessToken(ctx, sess.SessionID, dev.Address, sess.Scopes, now, expiresAt)
+	if err != nil {
 		return RefreshResult{}, err
 	}
+	if err := s.store.RotateSession(ctx, sess.SessionID, hashToken(accessToken), hashToken(newRefreshToken), expiresAt, refreshExpiresAt, now); err != nil {
+		return RefreshResult{}, err
+	}
+	s.revocations.rotated(sess.SessionID, hashToken(accessToken), now)
+	_ = s.store.TouchSession(ctx, sess.SessionID, now)
+	s.observeClient(ctx, sess, "refresh", now)
 	return RefreshResult{
 		Address:          dev.Address,
 		Scopes:           append([]string{}, sess.Scopes...),
@@ -275,23 +387,91 @@ func (s *Service) ListDevices(ctx context.Context) ([]DeviceView, error) {
 	if err != nil {
 		return nil, err
 	}
+	sessions, err := s.store.ListActiveSessions(ctx, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	byAddress := map[string][]DeviceSession{}
+	for _, sess := range sessions {
+		byAddress[sess.Address] = append(byAddress[sess.Address], deviceSessionView(sess))
+	}
 	out := make([]DeviceView, 0, len(recs))
 	for _, rec := range recs {
+		devSessions := byAddress[rec.Address]
+		if devSessions == nil {
+			devSessions = []DeviceSession{}
+		}
+		ipAlert := false
+		for _, sess := range devSessions {
+			ipAlert = ipAlert || sess.IPAlert != nil
+		}
 		out = append(out, DeviceView{
 			Address:      rec.Address,
 			Name:         rec.Name,
 			PublicKey:    rec.PublicKey,
 			Permissions:  append([]string{}, rec.Permissions...),
+			TrustLevel:   deviceTrustLevel(rec),
 			CreatedAt:    rec.CreatedAt,
 			LastSeenAt:   rec.LastSeenAt,
 			Revoked:      rec.Revoked,
 			RevokedAt:    rec.RevokedAt,
 			RevokeReason: rec.RevokeReason,
+			Sessions:     devSessions,
+			IPAlert:      ipAlert,
 		})
 	}
 	return out, nil
 }
 
+func deviceSessionView(sess ledger.SessionRecord) DeviceSession {
+	view := DeviceSession{
+		SessionID:     sess.SessionID,
+		CreatedAt:     sess.CreatedAt,
+		ExpiresAt:     sess.RefreshExpiresAt,
+		LastUsedAt:    sess.LastUsedAt,
+		LastIP:        sess.LastIP,
+		LastUserAgent: sess.LastUserAgent,
+		LastIPAt:      sess.LastIPAt,
+	}
+	if !sess.IPAlertAt.IsZero() {
+		view.IPAlert = &SessionIPAlert{FromIP: sess.IPAlertFrom, ToIP: sess.IPAlertTo, At: sess.IPAlertAt}
+	}
+	return view
+}
+
+// ListDeviceSessions returns the active sessions of one device, oldest
+// first.
+func (s *Service) ListDeviceSessions(ctx context.Context, address string) ([]DeviceSession, error) {
+	if _, err := s.store.GetDevice(ctx, address); err != nil {
+		return nil, err
+	}
+	sessions, err := s.store.ListActiveSessions(ctx, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	out := []DeviceSession{}
+	for _, sess := range sessions {
+		if sess.Address == address {
+			out = append(out, deviceSessionView(sess))
+		}
+	}
+	return out, nil
+}
+
+// RevokeSession signs out one session of a device and leaves the device and
+// its other sessions paired.
+func (s *Service) RevokeSession(ctx context.Context, address, sessionID string) error {
+	if strings.TrimSpace(address) == "" || strings.TrimSpace(sessionID) == "" {
+		return errors.New("address and session_id are required")
+	}
+	now := time.Now().UTC()
+	if err := s.store.RevokeSession(ctx, address, sessionID, now); err != nil {
+		return err
+	}
+	s.revocations.revokeSession(sessionID, now)
+	return nil
+}
+
 func (s *Service) RenameDevice(ctx context.Context, address, name string) error {
 	name = strings.TrimSpace(name)
 	if address == "" || name == "" {
@@ -308,6 +488,7 @@ func (s *Service) RevokeDevice(ctx context.Context, address, reason string) erro
 	if err := s.store.RevokeDevice(ctx, address, strings.TrimSpace(reason), now); err != nil {
 		return err
 	}
+	s.revocations.revokeAddress(address, now)
 	return s.store.RevokeSessionsByAddress(ctx, address, now)
 }
 
@@ -320,10 +501,6 @@ type issuedSession struct {
 
 func (s *Service) issueSession(ctx context.Context, address string, scopes []string) (issuedSession, error) {
 	now := time.Now().UTC()
-	accessToken, err := randomToken(48)
-	if err != nil {
-		return issuedSession{}, err
-	}
 	refreshToken, err := randomToken(56)
 	if err != nil {
 		return issuedSession{}, err
@@ -331,12 +508,18 @@ func (s *Service) issueSession(ctx context.Context, address string, scopes []str
 	expiresAt := now.Add(s.cfg.AccessTokenTTL)
 	refreshExpiresAt := now.Add(s.cfg.RefreshTokenTTL)
 
+	sessionID := uuid.NewString()
+	scopes = normalizeScopes(scopes)
+	accessToken, err := s.newAccessToken(ctx, sessionID, address, scopes, now, expiresAt)
+	if err != nil {
+		return issuedSession{}, err
+	}
 	err = s.store.CreateSession(ctx, ledger.SessionRecord{
-		SessionID:        uuid.NewString(),
+		SessionID:        sessionID,
 		AccessHash:       hashToken(accessToken),
 		RefreshHash:      hashToken(refreshToken),
 		Address:          address,
-		Scopes:           normalizeScopes(scopes),
+		Scopes:           scopes,
 		CreatedAt:        now,
 		ExpiresAt:        expiresAt,
 		RefreshExpiresAt: refreshExpiresAt,
@@ -344,6 +527,7 @@ func (s *Service) issueSession(ctx context.Context, address string, scopes []str
 	if err != nil {
 		return issuedSession{}, err
 	}
+	s.observeClient(ctx, ledger.SessionRecord{SessionID: sessionID, Address: address}, "pair", now)
 	return issuedSession{
 		AccessToken:      accessToken,
 		RefreshToken:     refreshToken,
@@ -352,6 +536,16 @@ func (s *Service) issueSession(ctx context.Context, address string, scopes []str
 	}, nil
 }
 
+// newAccessToken issues an access token in the configured format. Both are
+// stored hashed on the session, so either can also be checked against the
+// ledger.
+func (s *Service) newAccessToken(ctx context.Context, sessionID, address string, scopes []string, now, expiresAt time.Time) (string, error) {
+	if s.cfg.AccessTokenFormat == AccessTokenJWT {
+		return s.signAccessJWT(ctx, sessionID, address, scopes, now, expiresAt)
+	}
+	return randomToken(48)
+}
+
 func hashToken(token string) string {
 	sum := sha256.Sum256([]byte(token))
 	return hex.EncodeToString(sum[:])
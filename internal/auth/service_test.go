@@ -86,6 +86,72 @@ func TestPairCompleteAuthenticateRefresh(t *testing.T) {
 	}
 }
 
+func TestPairCompleteSignedByPersistentBridgeIdentity(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "auth.db")
+	openService := func() *Service {
+		store, err := ledger.Open(dbPath)
+		if err != nil {
+			t.Fatalf("open ledger: %v", err)
+		}
+		t.Cleanup(func() { _ = store.Close() })
+		if err := store.Init(context.Background()); err != nil {
+			t.Fatalf("init: %v", err)
+		}
+		return New(store, Config{})
+	}
+	svc := openService()
+	identity, err := svc.Identity(context.Background())
+	if err != nil {
+		t.Fatalf("identity: %v", err)
+	}
+	start, err := svc.StartPair(context.Background(), "admin", nil, 0)
+	if err != nil {
+		t.Fatalf("start pair: %v", err)
+	}
+	if start.BridgeFingerprint == "" || start.BridgeFingerprint != identity.Fingerprint {
+		t.Fatalf("unexpected fingerprint %q want %q", start.BridgeFingerprint, identity.Fingerprint)
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	complete, err := svc.CompletePair(context.Background(), CompletePairRequest{
+		PairCode:  start.PairCode,
+		PublicKey: base64.RawURLEncoding.EncodeToString(pub),
+		Signature: base64.RawURLEncoding.EncodeToString(ed25519.Sign(priv, []byte(start.Challenge))),
+	})
+	if err != nil {
+		t.Fatalf("complete pair: %v", err)
+	}
+	bridgePub, err := base64.RawURLEncoding.DecodeString(complete.BridgePublicKey)
+	if err != nil {
+		t.Fatalf("decode bridge public key: %v", err)
+	}
+	if IdentityFingerprint(bridgePub) != start.BridgeFingerprint {
+		t.Fatalf("bridge public key does not match the pairing fingerprint")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(complete.BridgeSignature)
+	if err != nil {
+		t.Fatalf("decode bridge signature: %v", err)
+	}
+	msg := PairCompleteSignedMessage(start.Challenge, complete.PublicKey, complete.Address)
+	if !ed25519.Verify(bridgePub, msg, sig) {
+		t.Fatalf("bridge signature does not verify")
+	}
+	if ed25519.Verify(bridgePub, PairCompleteSignedMessage(start.Challenge, "other-key", complete.Address), sig) {
+		t.Fatalf("signature must bind the device public key")
+	}
+
+	restarted, err := openService().Identity(context.Background())
+	if err != nil {
+		t.Fatalf("identity after restart: %v", err)
+	}
+	if restarted.Fingerprint != identity.Fingerprint || restarted.PublicKey != identity.PublicKey {
+		t.Fatalf("bridge identity changed across restart: %#v vs %#v", restarted, identity)
+	}
+}
+
 func TestDeviceListRenameRevoke(t *testing.T) {
 	svc := newAuthService(t)
 	start, err := svc.StartPair(context.Background(), "admin", nil, 0)
@@ -133,3 +199,168 @@ func TestDeviceListRenameRevoke(t *testing.T) {
 		t.Fatalf("expected access token invalid after revoke")
 	}
 }
+
+func TestPairCodeSweepNotifiesUnusedAndDeletes(t *testing.T) {
+	svc := newAuthService(t)
+	ctx := context.Background()
+	unused, err := svc.StartPair(ctx, "operator-a", []string{ScopeRunsRead}, 0)
+	if err != nil {
+		t.Fatalf("start pair: %v", err)
+	}
+	used, err := svc.StartPair(ctx, "operator-b", []string{ScopeRunsRead}, 0)
+	if err != nil {
+		t.Fatalf("start pair: %v", err)
+	}
+	if _, err := svc.store.ConsumePairCode(ctx, used.PairCode, time.Now().UTC()); err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+
+	pending, err := svc.ListPendingPairs(ctx, time.Now().UTC())
+	if err != nil {
+		t.Fatalf("list pending: %v", err)
+	}
+	if len(pending) != 1 || pending[0].PairCode != unused.PairCode || pending[0].ExpiresInSeconds <= 0 {
+		t.Fatalf("unexpected pending pairs: %#v", pending)
+	}
+
+	var notices []PairExpiredNotice
+	svc.SetPairExpiryNotifier(func(_ context.Context, n PairExpiredNotice) {
+		notices = append(notices, n)
+	})
+	// Nothing has expired yet.
+	res, err := svc.SweepPairCodes(ctx, time.Now().UTC())
+	if err != nil || res.Deleted != 0 || len(notices) != 0 {
+		t.Fatalf("unexpected early sweep: %#v %v", res, err)
+	}
+
+	res, err = svc.SweepPairCodes(ctx, time.Now().UTC().Add(3*time.Minute))
+	if err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+	if res.ExpiredUnused != 1 || res.Deleted != 2 {
+		t.Fatalf("unexpected sweep result: %#v", res)
+	}
+	if len(notices) != 1 || notices[0].CreatedBy != "operator-a" {
+		t.Fatalf("expected one notice for operator-a, got %#v", notices)
+	}
+	if _, err := svc.store.GetPairCode(ctx, unused.PairCode); err == nil {
+		t.Fatalf("expected expired pair code to be deleted")
+	}
+}
+
+func TestRefreshFromDistantIPFlagsSession(t *testing.T) {
+	svc := newAuthService(t)
+	start, err := svc.StartPair(context.Background(), "admin", []string{ScopeRunsRead}, 0)
+	if err != nil {
+		t.Fatalf("start pair: %v", err)
+	}
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	home := WithClientInfo(context.Background(), ClientInfo{IP: "203.0.113.10", UserAgent: "elix-ios/1.0"})
+	complete, err := svc.CompletePair(home, CompletePairRequest{
+		PairCode:  start.PairCode,
+		PublicKey: base64.RawURLEncoding.EncodeToString(pub),
+		Signature: base64.RawURLEncoding.EncodeToString(ed25519.Sign(priv, []byte(start.Challenge))),
+	})
+	if err != nil {
+		t.Fatalf("complete pair: %v", err)
+	}
+
+	// Same /16 and LAN addresses are roaming, not an alert.
+	nearby := WithClientInfo(context.Background(), ClientInfo{IP: "203.0.200.1", UserAgent: "elix-ios/1.0"})
+	if _, err := svc.AuthenticateToken(nearby, complete.AccessToken); err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	lan := WithClientInfo(context.Background(), ClientInfo{IP: "192.168.1.20", UserAgent: "elix-ios/1.0"})
+	if _, err := svc.AuthenticateToken(lan, complete.AccessToken); err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	devices, err := svc.ListDevices(context.Background())
+	if err != nil || len(devices) != 1 || len(devices[0].Sessions) != 1 {
+		t.Fatalf("list devices: %#v %v", devices, err)
+	}
+	if devices[0].IPAlert || devices[0].Sessions[0].LastIP != "192.168.1.20" {
+		t.Fatalf("unexpected session state: %#v", devices[0])
+	}
+
+	if _, err := svc.AuthenticateToken(nearby, complete.AccessToken); err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	far := WithClientInfo(context.Background(), ClientInfo{IP: "198.51.100.7", UserAgent: "curl/8.0"})
+	if _, err := svc.RefreshSession(far, complete.RefreshToken); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	devices, _ = svc.ListDevices(context.Background())
+	sess := devices[0].Sessions[0]
+	if !devices[0].IPAlert || sess.IPAlert == nil || sess.IPAlert.FromIP != "203.0.200.1" || sess.IPAlert.ToIP != "198.51.100.7" {
+		t.Fatalf("expected ip alert: %#v", devices[0])
+	}
+	if sess.LastUserAgent != "curl/8.0" {
+		t.Fatalf("expected user agent to be tracked, got %q", sess.LastUserAgent)
+	}
+}
+
+func TestJWTAccessTokensVerifyLocallyAndHonourRevocation(t *testing.T) {
+	svc := newAuthService(t)
+	svc.cfg.AccessTokenFormat = AccessTokenJWT
+	// A second bridge on the same ledger verifies with the shared identity
+	// and learns about revocations on its next sync.
+	peer := New(svc.store, Config{AccessTokenTTL: 2 * time.Minute, AccessTokenFormat: AccessTokenJWT, RevocationSyncInterval: time.Millisecond})
+	ctx := context.Background()
+
+	start, err := svc.StartPair(ctx, "admin", []string{ScopeRunsRead}, 0)
+	if err != nil {
+		t.Fatalf("start pair: %v", err)
+	}
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	complete, err := svc.CompletePair(ctx, CompletePairRequest{
+		PairCode:  start.PairCode,
+		PublicKey: base64.RawURLEncoding.EncodeToString(pub),
+		Signature: base64.RawURLEncoding.EncodeToString(ed25519.Sign(priv, []byte(start.Challenge))),
+	})
+	if err != nil {
+		t.Fatalf("complete pair: %v", err)
+	}
+	if !looksLikeJWT(complete.AccessToken) {
+		t.Fatalf("expected a JWT access token, got %q", complete.AccessToken)
+	}
+	for _, s := range []*Service{svc, peer} {
+		p, err := s.AuthenticateToken(ctx, complete.AccessToken)
+		if err != nil || p.Address != complete.Address || !p.HasScope(ScopeRunsRead) {
+			t.Fatalf("authenticate jwt: %#v %v", p, err)
+		}
+	}
+	forged := complete.AccessToken[:len(complete.AccessToken)-4] + "AAAA"
+	if _, err := svc.AuthenticateToken(ctx, forged); err == nil {
+		t.Fatalf("expected tampered token to be rejected")
+	}
+
+	refreshed, err := svc.RefreshSession(ctx, complete.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	for _, s := range []*Service{svc, peer} {
+		if _, err := s.AuthenticateToken(ctx, complete.AccessToken); err != ErrTokenRevoked {
+			t.Fatalf("expected superseded token to be rejected, got %v", err)
+		}
+		if _, err := s.AuthenticateToken(ctx, refreshed.AccessToken); err != nil {
+			t.Fatalf("refreshed token should be valid: %v", err)
+		}
+	}
+
+	if err := svc.RevokeDevice(ctx, complete.Address, "lost"); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	for _, s := range []*Service{svc, peer} {
+		if _, err := s.AuthenticateToken(ctx, refreshed.AccessToken); err != ErrTokenRevoked {
+			t.Fatalf("expected revoked device token to be rejected, got %v", err)
+		}
+	}
+}
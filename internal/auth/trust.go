@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"fmt"
+
+	"echohelix/internal/ledger"
+)
+
+// Device trust tiers. Each tier has a fixed scope set, which is both what a
+// device of that tier gets by default and the most it may hold; scopes
+// beyond it require a higher tier, granted through an approved scope
+// request.
+const (
+	TrustObserver = "observer"
+	TrustOperator = "operator"
+	TrustAdmin    = "admin"
+)
+
+var trustTiers = []string{TrustObserver, TrustOperator, TrustAdmin}
+
+// scopeOrder lists every scope in the order used for merged scope sets.
+var scopeOrder = []string{
+	ScopeRunsSubmit,
+	ScopeRunsRead,
+	ScopeRunsCancel,
+	ScopeBackendsRead,
+	ScopePairStart,
+	ScopeDevicesRead,
+	ScopeDevicesWrite,
+}
+
+func ValidTrustLevel(level string) bool {
+	return trustRank(level) > 0
+}
+
+// TrustScopes returns the scope set of a tier.
+func TrustScopes(level string) []string {
+	switch level {
+	case TrustObserver:
+		return []string{ScopeRunsRead, ScopeBackendsRead, ScopeDevicesRead}
+	case TrustOperator:
+		return defaultScopes()
+	case TrustAdmin:
+		return sortScopes(append(defaultScopes(), ScopePairStart))
+	}
+	return nil
+}
+
+func trustRank(level string) int {
+	for i, tier := range trustTiers {
+		if tier == level {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// trustLevelFor is the lowest tier whose scope set covers scopes.
+func trustLevelFor(scopes []string) string {
+	for _, tier := range trustTiers {
+		if scopesWithin(scopes, TrustScopes(tier)) {
+			return tier
+		}
+	}
+	return TrustAdmin
+}
+
+// deviceTrustLevel is the stored tier, or for devices paired before tiers
+// existed the tier implied by their scopes.
+func deviceTrustLevel(rec ledger.DeviceRecord) string {
+	if ValidTrustLevel(rec.TrustLevel) {
+		return rec.TrustLevel
+	}
+	return trustLevelFor(rec.Permissions)
+}
+
+func pairTrustLevel(rec ledger.PairCodeRecord) string {
+	if ValidTrustLevel(rec.TrustLevel) {
+		return rec.TrustLevel
+	}
+	return trustLevelFor(rec.Permissions)
+}
+
+// resolvePairTrust picks the tier and scopes for a pair code. Without a
+// tier the requested scopes decide it, as before tiers existed; with one,
+// an empty request gets the tier's scopes and anything beyond it is
+// rejected.
+func resolvePairTrust(level string, requested []string) (string, []string, error) {
+	if level == "" {
+		scopes := normalizeScopes(requested)
+		return trustLevelFor(scopes), scopes, nil
+	}
+	if !ValidTrustLevel(level) {
+		return "", nil, fmt.Errorf("invalid trust_level %q", level)
+	}
+	if len(requested) == 0 {
+		return level, TrustScopes(level), nil
+	}
+	scopes := normalizeScopes(requested)
+	ceiling := TrustScopes(level)
+	for _, scope := range scopes {
+		if !scopesWithin([]string{scope}, ceiling) {
+			return "", nil, fmt.Errorf("scope %s exceeds trust level %s", scope, level)
+		}
+	}
+	return level, scopes, nil
+}
+
+func scopesWithin(scopes, set []string) bool {
+	allowed := map[string]struct{}{}
+	for _, scope := range set {
+		allowed[scope] = struct{}{}
+	}
+	for _, scope := range scopes {
+		if _, ok := allowed[scope]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func sortScopes(in []string) []string {
+	have := map[string]struct{}{}
+	for _, scope := range in {
+		have[scope] = struct{}{}
+	}
+	out := make([]string, 0, len(have))
+	for _, scope := range scopeOrder {
+		if _, ok := have[scope]; ok {
+			out = append(out, scope)
+		}
+	}
+	return out
+}
@@ -0,0 +1,150 @@
+// Package cluster elects one bridge instance to run singleton background
+// jobs when several instances share a ledger.
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LeaseStore is the shared lock backend, implemented by ledger.Store.
+type LeaseStore interface {
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+	ReleaseLease(ctx context.Context, name, holder string) error
+}
+
+type Status struct {
+	Lease  string    `json:"lease,omitempty"`
+	NodeID string    `json:"node_id,omitempty"`
+	Leader bool      `json:"leader"`
+	Since  time.Time `json:"since,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// Elector holds a lease while it can renew it. Leadership lapses on its
+// own once a renewal is overdue, before the lease can pass to another node.
+type Elector struct {
+	store  LeaseStore
+	lease  string
+	nodeID string
+	ttl    time.Duration
+
+	mu          sync.Mutex
+	leaderUntil time.Time
+	since       time.Time
+	lastErr     error
+}
+
+func NewElector(store LeaseStore, lease, nodeID string, ttl time.Duration) *Elector {
+	if nodeID == "" {
+		nodeID = DefaultNodeID()
+	}
+	if ttl <= 0 {
+		ttl = 15 * time.Second
+	}
+	return &Elector{store: store, lease: lease, nodeID: nodeID, ttl: ttl}
+}
+
+// DefaultNodeID is hostname-pid plus a random suffix, unique per process.
+func DefaultNodeID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "bridge"
+	}
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.NewString()[:8])
+}
+
+func (e *Elector) NodeID() string {
+	return e.nodeID
+}
+
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return time.Now().Before(e.leaderUntil)
+}
+
+func (e *Elector) Status() Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	st := Status{Lease: e.lease, NodeID: e.nodeID, Leader: time.Now().Before(e.leaderUntil)}
+	if st.Leader {
+		st.Since = e.since
+	}
+	if e.lastErr != nil {
+		st.Error = e.lastErr.Error()
+	}
+	return st
+}
+
+// Tick makes one attempt to acquire or renew the lease and reports whether
+// this node leads afterwards.
+func (e *Elector) Tick(ctx context.Context) bool {
+	was := e.IsLeader()
+	start := time.Now()
+	callCtx, cancel := context.WithTimeout(ctx, e.ttl/3)
+	ok, err := e.store.AcquireLease(callCtx, e.lease, e.nodeID, e.ttl)
+	cancel()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lastErr = err
+	switch {
+	case err != nil:
+		// Keep leading until the current lease runs out; another node
+		// cannot take it before then.
+	case ok:
+		// Count from before the call so this node steps down no later
+		// than the lease expires in the store.
+		e.leaderUntil = start.Add(e.ttl)
+		if !was {
+			e.since = time.Now().UTC()
+		}
+	default:
+		e.leaderUntil = time.Time{}
+	}
+	is := time.Now().Before(e.leaderUntil)
+	if is != was {
+		if is {
+			log.Printf("cluster lease=%s node=%s: became leader", e.lease, e.nodeID)
+		} else {
+			log.Printf("cluster lease=%s node=%s: lost leadership", e.lease, e.nodeID)
+		}
+	}
+	return is
+}
+
+// Run renews the lease every ttl/3 until ctx is done, then releases it so
+// another node can take over without waiting for expiry.
+func (e *Elector) Run(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(e.ttl / 3)
+		defer ticker.Stop()
+		for {
+			e.Tick(ctx)
+			select {
+			case <-ctx.Done():
+				e.release()
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (e *Elector) release() {
+	e.mu.Lock()
+	e.leaderUntil = time.Time{}
+	e.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), e.ttl/3)
+	defer cancel()
+	if err := e.store.ReleaseLease(ctx, e.lease, e.nodeID); err != nil {
+		log.Printf("cluster lease=%s node=%s: release: %v", e.lease, e.nodeID, err)
+	}
+}
@@ -0,0 +1,59 @@
+package cluster
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"echohelix/internal/ledger"
+)
+
+func TestElectorSingleLeaderWithFailover(t *testing.T) {
+	store, err := ledger.Open(filepath.Join(t.TempDir(), "bridge.db"))
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	defer store.Close()
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatalf("init ledger: %v", err)
+	}
+
+	ctx := context.Background()
+	a := NewElector(store, "scheduler", "node-a", 300*time.Millisecond)
+	b := NewElector(store, "scheduler", "node-b", 300*time.Millisecond)
+	if !a.Tick(ctx) || b.Tick(ctx) {
+		t.Fatalf("expected node-a to lead alone: a=%v b=%v", a.IsLeader(), b.IsLeader())
+	}
+	if !a.Tick(ctx) || b.Tick(ctx) {
+		t.Fatalf("expected node-a to renew its lease")
+	}
+	if lease, ok, err := store.GetLease(ctx, "scheduler"); err != nil || !ok || lease.Holder != "node-a" {
+		t.Fatalf("unexpected lease: %#v ok=%v err=%v", lease, ok, err)
+	}
+
+	// node-a stops renewing: its leadership lapses and node-b takes over
+	// once the lease expires.
+	time.Sleep(350 * time.Millisecond)
+	if a.IsLeader() {
+		t.Fatalf("expected node-a leadership to lapse without renewal")
+	}
+	if !b.Tick(ctx) || a.Tick(ctx) {
+		t.Fatalf("expected failover to node-b")
+	}
+
+	// A clean shutdown hands over without waiting for expiry.
+	runCtx, cancel := context.WithCancel(ctx)
+	b.Run(runCtx)
+	cancel()
+	deadline := time.Now().Add(2 * time.Second)
+	for !a.Tick(ctx) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected node-a to take over after node-b released")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if st := a.Status(); !st.Leader || st.NodeID != "node-a" || st.Since.IsZero() {
+		t.Fatalf("unexpected status: %#v", st)
+	}
+}
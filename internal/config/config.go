@@ -1,20 +1,49 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
+
+	"echohelix/internal/adapter/supervisor"
+	"echohelix/internal/notify"
+	"echohelix/internal/rpc/transport"
+	"echohelix/internal/run"
+	"echohelix/internal/session"
+	"echohelix/internal/signing"
+	"echohelix/internal/tracing"
 )
 
 type Config struct {
 	HTTPAddr                       string
 	AuthToken                      string
 	SQLitePath                     string
+	DatabaseDSN                    string
+	ReadOnly                       bool
+	ReadOnlyReason                 string
+	LeaderElection                 bool
+	ClusterNodeID                  string
+	LeaderLeaseTTL                 time.Duration
 	WorkspaceRoots                 []string
+	PromptInjectionsFile           string
+	WorkspaceRetentionFile         string
+	WorkspacePolicyFile            string
+	BackendModelsFile              string
+	DiscoveryMDNSEnabled           bool
+	DiscoveryInstanceName          string
 	RunTimeout                     time.Duration
+	RunExtensionMax                time.Duration
+	RunExtensionMaxTotal           time.Duration
+	RunTimeoutMax                  time.Duration
+	RunStallWarnAfter              time.Duration
+	RunStallCancelAfter            time.Duration
+	RunStallProbeHealth            bool
 	AccessTokenTTL                 time.Duration
+	AccessTokenFormat              string
+	AuthRevocationSync             time.Duration
 	RefreshTokenTTL                time.Duration
 	PairCodeTTL                    time.Duration
 	PairStartRateLimit             int
@@ -25,11 +54,72 @@ type Config struct {
 	AuthFailAlertWindow            time.Duration
 	PairCompleteFailAlertThreshold int
 	PairCompleteFailAlertWindow    time.Duration
+	RateLimitStore                 string
 	TrustedProxyCIDRs              []string
+	PublicBaseURL                  string
+	TLSCertFile                    string
+	TLSKeyFile                     string
+	TLSSelfSigned                  bool
+	TLSSelfSignedDir               string
+	TLSSelfSignedHosts             []string
+	TLSACMEHosts                   []string
+	TLSACMECacheDir                string
+	TLSACMEEmail                   string
+	PairLinkSecret                 string
 	MaxOutputBytes                 int64
+	MaxOutputAction                string
 	MaxConcurrentRun               int
+	ResequenceDuplicateSeq         bool
+	IncludeRunMaxBytes             int
+	IncludeRunsMaxTotalBytes       int
+	IdempotencyWindow              time.Duration
+	OrphanReapInterval             time.Duration
+	OrphanQueuedThreshold          time.Duration
 	DailyTokenQuota                map[string]int64
+	OutboundSigningKeys            string
+	PairCodeSweepInterval          time.Duration
+	LedgerRetentionMaxAge          time.Duration
+	LedgerRetentionMaxEvents       int
+	LedgerCompactInterval          time.Duration
+	EventPersistWorkers            int
+	EventPersistQueueSize          int
+	EventPersistOverflow           string
+	EventPersistBatchSize          int
+	EventPersistFlushInterval      time.Duration
+	PairExpiryWebhookURL           string
+	FirstEventSLO                  map[string]time.Duration
+	SLOWindow                      time.Duration
+	SLOAlertBreachPercent          int
+	SLOAlertMinSamples             int
+	SLOAlertWebhookURL             string
+	DailyDigestEnabled             bool
+	DailyDigestCheckInterval       time.Duration
+	DailyDigestWebhookURL          string
+	SMTPHost                       string
+	SMTPPort                       int
+	SMTPUsername                   string
+	SMTPPassword                   string
+	SMTPFrom                       string
+	SMTPTo                         []string
+	SMTPTLS                        string
+	SMTPSubjectPrefix              string
+	EmailNotifyRunFailures         bool
+	EmailNotifyApprovalAfter       time.Duration
+	EmailNotifySecurityAlerts      bool
+	EmailSecurityAlertCooldown     time.Duration
+	StatusPageEnabled              bool
+	StatusPageAddr                 string
+	StatusPageFields               []string
+	StatusPageTitle                string
+	TracesExporter                 string
+	TracingServiceName             string
+	DeviceDailyTokenQuota          map[string]int64
+	QuotaEnforcement               string
+	TokenPricing                   string
 	FileStoreDir                   string
+	WarehouseExportDir             string
+	WarehouseExportUploadURL       string
+	WarehouseExportUploadToken     string
 	MaxUploadBytes                 int64
 	CodexSessionEnabled            bool
 	CodexAppServerBin              string
@@ -38,51 +128,246 @@ type Config struct {
 	GeminiSessionArgs              []string
 	ClaudeSessionBin               string
 	ClaudeSessionArgs              []string
+	GeminiSessionProtocol          string
+	ClaudeSessionProtocol          string
 	CodexSessionStartTimeout       time.Duration
 	CodexSessionRequestTimeout     time.Duration
 	SessionRetention               time.Duration
 	SessionCleanupPeriod           time.Duration
+	SessionHeartbeatInterval       time.Duration
+	SessionHeartbeatTimeout        time.Duration
+	SessionHeartbeatFailures       int
+	SessionHeartbeatMethod         string
+	SessionAutoRestart             bool
+	SessionIdleSuspend             time.Duration
+	SessionMaxRSSBytes             int64
+	SessionNice                    int
+	SessionMaxProcesses            int
+	SessionMaxLifetime             time.Duration
+	SessionTurnQueueDepth          int
 	BackendCallReadMethods         []string
 	BackendCallCancelMethods       []string
 	BackendCallBlockedMethods      []string
+	CommandGuardrails              bool
+	CommandDenylistFile            string
+	CommandGuardWorkspaceOnly      bool
+	WSPingInterval                 time.Duration
+	WSPongWait                     time.Duration
+	WSWriteTimeout                 time.Duration
+	HTTPReadTimeout                time.Duration
+	HTTPWriteTimeout               time.Duration
+	HTTPIdleTimeout                time.Duration
+	HTTPMaxHeaderBytes             int
+	HTTPHandlerTimeout             time.Duration
+	HTTPRouteTimeouts              map[string]time.Duration
+	AdapterHealthInterval          time.Duration
+	AdapterHealthFailureThreshold  int
+	AdapterRestartBackoffMax       time.Duration
 
 	CodexAdapter  AdapterConfig
 	GeminiAdapter AdapterConfig
 	ClaudeAdapter AdapterConfig
+	AiderAdapter  AdapterConfig
+
+	// ConfigFile is the settings file named by BRIDGE_CONFIG_FILE, if any.
+	ConfigFile string
+	// Settings records every variable Load read, with its source and
+	// secrets masked, for the effective-config endpoint.
+	Settings []Setting
 }
 
 type AdapterConfig struct {
 	Enabled    bool
 	GRPCAddr   string
 	BinaryPath string
+
+	TLSCertFile   string
+	TLSKeyFile    string
+	TLSCAFile     string
+	TLSServerName string
+	AuthToken     string
+}
+
+func (c AdapterConfig) Security() transport.Security {
+	return transport.Security{
+		CertFile:   c.TLSCertFile,
+		KeyFile:    c.TLSKeyFile,
+		CAFile:     c.TLSCAFile,
+		ServerName: c.TLSServerName,
+		Token:      c.AuthToken,
+	}
+}
+
+func (c Config) AdapterHealthPolicy() supervisor.HealthPolicy {
+	p := supervisor.DefaultHealthPolicy()
+	p.Interval = c.AdapterHealthInterval
+	p.FailureThreshold = c.AdapterHealthFailureThreshold
+	p.BackoffMax = c.AdapterRestartBackoffMax
+	return p
+}
+
+func (c Config) SessionHeartbeatPolicy() session.HeartbeatPolicy {
+	return session.HeartbeatPolicy{
+		Interval:         c.SessionHeartbeatInterval,
+		Timeout:          c.SessionHeartbeatTimeout,
+		FailureThreshold: c.SessionHeartbeatFailures,
+		Method:           c.SessionHeartbeatMethod,
+		AutoRestart:      c.SessionAutoRestart,
+	}
+}
+
+func (c Config) SessionResourceLimits() session.ResourceLimits {
+	return session.ResourceLimits{
+		MaxRSSBytes:  c.SessionMaxRSSBytes,
+		Nice:         c.SessionNice,
+		MaxProcesses: c.SessionMaxProcesses,
+		MaxLifetime:  c.SessionMaxLifetime,
+	}
+}
+
+// CommandGuard builds the session approval guard: the built-in denylist
+// plus COMMAND_DENYLIST_FILE. It returns nil when COMMAND_GUARDRAILS=false.
+func (c Config) CommandGuard() (*session.CommandGuard, error) {
+	if !c.CommandGuardrails {
+		return nil, nil
+	}
+	rules := append([]session.CommandGuardRule(nil), session.DefaultCommandGuardRules...)
+	if c.CommandDenylistFile != "" {
+		extra, err := session.LoadCommandGuardRules(c.CommandDenylistFile)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, extra...)
+	}
+	return session.NewCommandGuard(rules, c.CommandGuardWorkspaceOnly)
+}
+
+func (c Config) RunSLO() run.SLOConfig {
+	return run.SLOConfig{
+		FirstEvent:      c.FirstEventSLO,
+		Window:          c.SLOWindow,
+		AlertBreachRate: float64(c.SLOAlertBreachPercent) / 100,
+		AlertMinSamples: c.SLOAlertMinSamples,
+	}
+}
+
+// SMTP is the relay for notification email; it is disabled unless
+// SMTP_HOST, SMTP_FROM and SMTP_TO are set.
+func (c Config) SMTP() notify.SMTPConfig {
+	return notify.SMTPConfig{
+		Host:          c.SMTPHost,
+		Port:          c.SMTPPort,
+		Username:      c.SMTPUsername,
+		Password:      c.SMTPPassword,
+		From:          c.SMTPFrom,
+		To:            c.SMTPTo,
+		TLS:           c.SMTPTLS,
+		SubjectPrefix: c.SMTPSubjectPrefix,
+	}
+}
+
+// OutboundSigner returns nil when no outbound signing keys are configured.
+// Tracing enables OTLP span export when OTEL_TRACES_EXPORTER=otlp. The
+// exporter reads OTEL_EXPORTER_OTLP_* itself.
+func (c Config) Tracing() tracing.Config {
+	return tracing.Config{
+		Enabled:     strings.EqualFold(strings.TrimSpace(c.TracesExporter), "otlp"),
+		ServiceName: c.TracingServiceName,
+	}
+}
+
+func (c Config) OutboundSigner() (*signing.Signer, error) {
+	keys, err := signing.ParseKeys(c.OutboundSigningKeys)
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+	return signing.NewSigner(keys...)
+}
+
+// LedgerDSN is the value for ledger.Open: BRIDGE_DB_DSN when set (for
+// example a postgres:// URL), otherwise the SQLite path.
+func (c Config) LedgerDSN() string {
+	if c.DatabaseDSN != "" {
+		return c.DatabaseDSN
+	}
+	return c.SQLitePath
 }
 
+// Load reads the configuration from the environment and, when
+// BRIDGE_CONFIG_FILE names one, a settings file. A file that cannot be read
+// is skipped and shows up as the ignored value of BRIDGE_CONFIG_FILE.
 func Load() Config {
-	timeoutSec := envInt("RUN_TIMEOUT_SECONDS", 1800)
-	accessTokenTTLSec := envInt("AUTH_ACCESS_TOKEN_TTL_SECONDS", 900)
-	refreshTokenTTLSec := envInt("AUTH_REFRESH_TOKEN_TTL_SECONDS", 86400)
-	pairCodeTTLSec := envInt("AUTH_PAIR_CODE_TTL_SECONDS", 60)
-	pairStartRateLimit := envInt("AUTH_PAIR_START_RATE_LIMIT", 6)
-	pairStartRateWindowSec := envInt("AUTH_PAIR_START_RATE_WINDOW_SECONDS", 60)
-	refreshFailAlertThreshold := envInt("AUTH_REFRESH_FAIL_ALERT_THRESHOLD", 5)
-	refreshFailAlertWindowSec := envInt("AUTH_REFRESH_FAIL_ALERT_WINDOW_SECONDS", 120)
-	authFailAlertThreshold := envInt("AUTH_AUTH_FAIL_ALERT_THRESHOLD", 8)
-	authFailAlertWindowSec := envInt("AUTH_AUTH_FAIL_ALERT_WINDOW_SECONDS", 120)
-	pairCompleteFailAlertThreshold := envInt("AUTH_PAIR_COMPLETE_FAIL_ALERT_THRESHOLD", 5)
-	pairCompleteFailAlertWindowSec := envInt("AUTH_PAIR_COMPLETE_FAIL_ALERT_WINDOW_SECONDS", 120)
-	codexSessionStartTimeoutSec := envInt("CODEX_SESSION_START_TIMEOUT_SECONDS", 20)
-	codexSessionRequestTimeoutSec := envInt("CODEX_SESSION_REQUEST_TIMEOUT_SECONDS", 30)
-	sessionRetentionSec := envInt("SESSION_RETENTION_SECONDS", 21600)
-	sessionCleanupSec := envInt("SESSION_CLEANUP_INTERVAL_SECONDS", 300)
+	cfg, _ := load()
+	return cfg
+}
+
+// Reload is Load for a running bridge. It fails when the config file cannot
+// be read or names an unknown setting, so a bad edit does not replace a
+// working configuration.
+func Reload() (Config, error) {
+	return load()
+}
+
+func load() (Config, error) {
 	baseDir := executableDir()
-	codexBin := env("CODEX_CLI_BIN", "codex")
-	return Config{
-		HTTPAddr:                       env("BRIDGE_HTTP_ADDR", ":8765"),
-		AuthToken:                      env("BRIDGE_AUTH_TOKEN", "echohelix-dev-token"),
-		SQLitePath:                     envPath("BRIDGE_SQLITE_PATH", filepath.Join(baseDir, "bridge.db"), baseDir),
-		WorkspaceRoots:                 splitCSV(env("WORKSPACE_ROOTS", "/tmp")),
+	configFile := envPath("BRIDGE_CONFIG_FILE", "", baseDir)
+	file, fileErr := readConfigFile(configFile)
+	l := newLoader(file)
+	timeoutSec := l.envInt("RUN_TIMEOUT_SECONDS", 1800)
+	accessTokenTTLSec := l.envInt("AUTH_ACCESS_TOKEN_TTL_SECONDS", 900)
+	refreshTokenTTLSec := l.envInt("AUTH_REFRESH_TOKEN_TTL_SECONDS", 86400)
+	pairCodeTTLSec := l.envInt("AUTH_PAIR_CODE_TTL_SECONDS", 60)
+	pairStartRateLimit := l.envInt("AUTH_PAIR_START_RATE_LIMIT", 6)
+	pairStartRateWindowSec := l.envInt("AUTH_PAIR_START_RATE_WINDOW_SECONDS", 60)
+	refreshFailAlertThreshold := l.envInt("AUTH_REFRESH_FAIL_ALERT_THRESHOLD", 5)
+	refreshFailAlertWindowSec := l.envInt("AUTH_REFRESH_FAIL_ALERT_WINDOW_SECONDS", 120)
+	authFailAlertThreshold := l.envInt("AUTH_AUTH_FAIL_ALERT_THRESHOLD", 8)
+	authFailAlertWindowSec := l.envInt("AUTH_AUTH_FAIL_ALERT_WINDOW_SECONDS", 120)
+	pairCompleteFailAlertThreshold := l.envInt("AUTH_PAIR_COMPLETE_FAIL_ALERT_THRESHOLD", 5)
+	pairCompleteFailAlertWindowSec := l.envInt("AUTH_PAIR_COMPLETE_FAIL_ALERT_WINDOW_SECONDS", 120)
+	codexSessionStartTimeoutSec := l.envInt("CODEX_SESSION_START_TIMEOUT_SECONDS", 20)
+	codexSessionRequestTimeoutSec := l.envInt("CODEX_SESSION_REQUEST_TIMEOUT_SECONDS", 30)
+	sessionRetentionSec := l.envInt("SESSION_RETENTION_SECONDS", 21600)
+	sessionCleanupSec := l.envInt("SESSION_CLEANUP_INTERVAL_SECONDS", 300)
+	wsPingIntervalSec := l.envInt("WS_PING_INTERVAL_SECONDS", 25)
+	wsPongWaitSec := l.envInt("WS_PONG_WAIT_SECONDS", 60)
+	wsWriteTimeoutSec := l.envInt("WS_WRITE_TIMEOUT_SECONDS", 10)
+	httpReadTimeoutSec := l.envInt("HTTP_READ_TIMEOUT_SECONDS", 30)
+	httpWriteTimeoutSec := l.envInt("HTTP_WRITE_TIMEOUT_SECONDS", 60)
+	httpIdleTimeoutSec := l.envInt("HTTP_IDLE_TIMEOUT_SECONDS", 120)
+	httpHandlerTimeoutSec := l.envInt("HTTP_HANDLER_TIMEOUT_SECONDS", 30)
+	orphanReapIntervalSec := l.envInt("RUN_ORPHAN_REAP_INTERVAL_SECONDS", 60)
+	orphanQueuedThresholdSec := l.envInt("RUN_ORPHAN_QUEUED_THRESHOLD_SECONDS", 600)
+	adapterHealthIntervalSec := l.envInt("ADAPTER_HEALTH_INTERVAL_SECONDS", 10)
+	adapterRestartBackoffMaxSec := l.envInt("ADAPTER_RESTART_BACKOFF_MAX_SECONDS", 120)
+	codexBin := l.env("CODEX_CLI_BIN", "codex")
+	cfg := Config{
+		HTTPAddr:                       l.env("BRIDGE_HTTP_ADDR", ":8765"),
+		AuthToken:                      l.env("BRIDGE_AUTH_TOKEN", "echohelix-dev-token"),
+		SQLitePath:                     l.envPath("BRIDGE_SQLITE_PATH", filepath.Join(baseDir, "bridge.db"), baseDir),
+		DatabaseDSN:                    l.env("BRIDGE_DB_DSN", ""),
+		ReadOnly:                       l.envBool("BRIDGE_READ_ONLY", false),
+		ReadOnlyReason:                 l.env("BRIDGE_READ_ONLY_REASON", ""),
+		LeaderElection:                 l.envBool("CLUSTER_LEADER_ELECTION", false),
+		ClusterNodeID:                  l.env("CLUSTER_NODE_ID", ""),
+		LeaderLeaseTTL:                 time.Duration(l.envInt("CLUSTER_LEASE_TTL_SECONDS", 15)) * time.Second,
+		WorkspaceRoots:                 splitCSV(l.env("WORKSPACE_ROOTS", "/tmp")),
+		PromptInjectionsFile:           l.envPath("PROMPT_INJECTIONS_FILE", "", baseDir),
+		WorkspaceRetentionFile:         l.envPath("WORKSPACE_RETENTION_FILE", "", baseDir),
+		WorkspacePolicyFile:            l.envPath("WORKSPACE_POLICY_FILE", "", baseDir),
+		BackendModelsFile:              l.envPath("BACKEND_MODELS_FILE", "", baseDir),
+		DiscoveryMDNSEnabled:           l.envBool("DISCOVERY_MDNS_ENABLED", false),
+		DiscoveryInstanceName:          l.env("DISCOVERY_INSTANCE_NAME", ""),
 		RunTimeout:                     time.Duration(timeoutSec) * time.Second,
+		RunExtensionMax:                time.Duration(l.envInt("RUN_EXTENSION_MAX_SECONDS", 1800)) * time.Second,
+		RunExtensionMaxTotal:           time.Duration(l.envInt("RUN_EXTENSION_MAX_TOTAL_SECONDS", 7200)) * time.Second,
+		RunTimeoutMax:                  time.Duration(l.envInt("RUN_TIMEOUT_MAX_SECONDS", 7200)) * time.Second,
+		RunStallWarnAfter:              time.Duration(l.envInt("RUN_STALL_WARN_SECONDS", 300)) * time.Second,
+		RunStallCancelAfter:            time.Duration(l.envInt("RUN_STALL_CANCEL_SECONDS", 0)) * time.Second,
+		RunStallProbeHealth:            l.envBool("RUN_STALL_PROBE_HEALTH", true),
 		AccessTokenTTL:                 time.Duration(accessTokenTTLSec) * time.Second,
+		AccessTokenFormat:              strings.ToLower(l.env("AUTH_ACCESS_TOKEN_FORMAT", "opaque")),
+		AuthRevocationSync:             time.Duration(l.envInt("AUTH_JWT_REVOCATION_SYNC_SECONDS", 5)) * time.Second,
 		RefreshTokenTTL:                time.Duration(refreshTokenTTLSec) * time.Second,
 		PairCodeTTL:                    time.Duration(pairCodeTTLSec) * time.Second,
 		PairStartRateLimit:             pairStartRateLimit,
@@ -93,53 +378,163 @@ func Load() Config {
 		AuthFailAlertWindow:            time.Duration(authFailAlertWindowSec) * time.Second,
 		PairCompleteFailAlertThreshold: pairCompleteFailAlertThreshold,
 		PairCompleteFailAlertWindow:    time.Duration(pairCompleteFailAlertWindowSec) * time.Second,
-		TrustedProxyCIDRs:              splitCSV(env("TRUSTED_PROXY_CIDRS", "")),
-		MaxOutputBytes:                 int64(envInt("RUN_MAX_OUTPUT_BYTES", 4*1024*1024)),
-		MaxConcurrentRun:               envInt("MAX_CONCURRENT_RUNS", 32),
-		DailyTokenQuota:                parseKVInt64CSV(env("DAILY_TOKEN_QUOTA", "")),
-		FileStoreDir:                   envPath("BRIDGE_FILE_STORE_DIR", filepath.Join(baseDir, "files"), baseDir),
-		MaxUploadBytes:                 int64(envInt("BRIDGE_MAX_UPLOAD_BYTES", 20*1024*1024)),
-		CodexSessionEnabled:            envBool("CODEX_SESSION_ENABLED", true),
+		RateLimitStore:                 l.env("AUTH_RATE_LIMIT_STORE", "ledger"),
+		TrustedProxyCIDRs:              splitCSV(l.env("TRUSTED_PROXY_CIDRS", "")),
+		PublicBaseURL:                  l.env("BRIDGE_PUBLIC_BASE_URL", ""),
+		TLSCertFile:                    l.envPath("TLS_CERT_FILE", "", baseDir),
+		TLSKeyFile:                     l.envPath("TLS_KEY_FILE", "", baseDir),
+		TLSSelfSigned:                  l.envBool("TLS_SELF_SIGNED", false),
+		TLSSelfSignedDir:               l.envPath("TLS_SELF_SIGNED_DIR", filepath.Join(baseDir, "tls"), baseDir),
+		TLSSelfSignedHosts:             splitCSV(l.env("TLS_SELF_SIGNED_HOSTS", "")),
+		TLSACMEHosts:                   splitCSV(l.env("TLS_ACME_HOSTS", "")),
+		TLSACMECacheDir:                l.envPath("TLS_ACME_CACHE_DIR", filepath.Join(baseDir, "acme"), baseDir),
+		TLSACMEEmail:                   l.env("TLS_ACME_EMAIL", ""),
+		PairLinkSecret:                 l.env("BRIDGE_PAIR_LINK_SECRET", ""),
+		MaxOutputBytes:                 int64(l.envInt("RUN_MAX_OUTPUT_BYTES", 4*1024*1024)),
+		MaxOutputAction:                l.env("RUN_MAX_OUTPUT_ACTION", "truncate"),
+		MaxConcurrentRun:               l.envInt("MAX_CONCURRENT_RUNS", 32),
+		ResequenceDuplicateSeq:         l.envBool("RUN_RESEQUENCE_DUPLICATE_SEQ", true),
+		IncludeRunMaxBytes:             l.envInt("RUN_INCLUDE_RUN_MAX_BYTES", 16*1024),
+		IncludeRunsMaxTotalBytes:       l.envInt("RUN_INCLUDE_RUNS_MAX_TOTAL_BYTES", 48*1024),
+		IdempotencyWindow:              time.Duration(l.envInt("IDEMPOTENCY_WINDOW_SECONDS", 86400)) * time.Second,
+		OrphanReapInterval:             time.Duration(orphanReapIntervalSec) * time.Second,
+		OrphanQueuedThreshold:          time.Duration(orphanQueuedThresholdSec) * time.Second,
+		DailyTokenQuota:                parseKVInt64CSV(l.env("DAILY_TOKEN_QUOTA", "")),
+		OutboundSigningKeys:            l.env("OUTBOUND_SIGNING_KEYS", ""),
+		PairCodeSweepInterval:          time.Duration(l.envInt("PAIR_CODE_SWEEP_INTERVAL_SECONDS", 60)) * time.Second,
+		LedgerRetentionMaxAge:          time.Duration(l.envInt("LEDGER_RETENTION_MAX_AGE_HOURS", 0)) * time.Hour,
+		LedgerRetentionMaxEvents:       l.envInt("LEDGER_RETENTION_MAX_EVENTS_PER_RUN", 0),
+		LedgerCompactInterval:          time.Duration(l.envInt("LEDGER_COMPACT_INTERVAL_SECONDS", 3600)) * time.Second,
+		EventPersistWorkers:            l.envInt("EVENT_PERSIST_WORKERS", 0),
+		EventPersistQueueSize:          l.envInt("EVENT_PERSIST_QUEUE_SIZE", 1024),
+		EventPersistOverflow:           l.env("EVENT_PERSIST_OVERFLOW", "block"),
+		EventPersistBatchSize:          l.envInt("EVENT_PERSIST_BATCH_SIZE", 64),
+		EventPersistFlushInterval:      time.Duration(l.envInt("EVENT_PERSIST_FLUSH_MS", 10)) * time.Millisecond,
+		PairExpiryWebhookURL:           l.env("PAIR_EXPIRY_WEBHOOK_URL", ""),
+		FirstEventSLO:                  secondsMap(parseKVInt64CSV(l.env("RUN_FIRST_EVENT_SLO", ""))),
+		SLOWindow:                      time.Duration(l.envInt("RUN_SLO_WINDOW_SECONDS", 900)) * time.Second,
+		SLOAlertBreachPercent:          l.envInt("RUN_SLO_ALERT_BREACH_PERCENT", 10),
+		SLOAlertMinSamples:             l.envInt("RUN_SLO_ALERT_MIN_SAMPLES", 10),
+		SLOAlertWebhookURL:             l.env("RUN_SLO_ALERT_WEBHOOK_URL", ""),
+		DailyDigestEnabled:             l.envBool("DAILY_DIGEST_ENABLED", false),
+		DailyDigestCheckInterval:       time.Duration(l.envInt("DAILY_DIGEST_CHECK_INTERVAL_SECONDS", 900)) * time.Second,
+		DailyDigestWebhookURL:          l.env("DAILY_DIGEST_WEBHOOK_URL", ""),
+		SMTPHost:                       l.env("SMTP_HOST", ""),
+		SMTPPort:                       l.envInt("SMTP_PORT", 0),
+		SMTPUsername:                   l.env("SMTP_USERNAME", ""),
+		SMTPPassword:                   l.env("SMTP_PASSWORD", ""),
+		SMTPFrom:                       l.env("SMTP_FROM", ""),
+		SMTPTo:                         splitCSV(l.env("SMTP_TO", "")),
+		SMTPTLS:                        l.env("SMTP_TLS", "starttls"),
+		SMTPSubjectPrefix:              l.env("SMTP_SUBJECT_PREFIX", "[elix]"),
+		EmailNotifyRunFailures:         l.envBool("EMAIL_NOTIFY_RUN_FAILURES", true),
+		EmailNotifyApprovalAfter:       time.Duration(l.envInt("EMAIL_NOTIFY_APPROVAL_AFTER_MINUTES", 15)) * time.Minute,
+		EmailNotifySecurityAlerts:      l.envBool("EMAIL_NOTIFY_SECURITY_ALERTS", true),
+		EmailSecurityAlertCooldown:     time.Duration(l.envInt("EMAIL_SECURITY_ALERT_COOLDOWN_MINUTES", 15)) * time.Minute,
+		StatusPageEnabled:              l.envBool("STATUS_PAGE_ENABLED", false),
+		StatusPageAddr:                 l.env("STATUS_PAGE_ADDR", ""),
+		StatusPageFields:               splitCSV(l.env("STATUS_PAGE_FIELDS", "")),
+		StatusPageTitle:                l.env("STATUS_PAGE_TITLE", ""),
+		TracesExporter:                 l.env("OTEL_TRACES_EXPORTER", "none"),
+		TracingServiceName:             l.env("OTEL_SERVICE_NAME", "elix-bridge"),
+		DeviceDailyTokenQuota:          parseKVInt64CSV(l.env("DEVICE_DAILY_TOKEN_QUOTA", "")),
+		QuotaEnforcement:               l.env("QUOTA_ENFORCEMENT", "off"),
+		TokenPricing:                   l.env("TOKEN_PRICING", ""),
+		FileStoreDir:                   l.envPath("BRIDGE_FILE_STORE_DIR", filepath.Join(baseDir, "files"), baseDir),
+		WarehouseExportDir:             l.envPath("WAREHOUSE_EXPORT_DIR", filepath.Join(baseDir, "exports"), baseDir),
+		WarehouseExportUploadURL:       l.env("WAREHOUSE_EXPORT_UPLOAD_URL", ""),
+		WarehouseExportUploadToken:     l.env("WAREHOUSE_EXPORT_UPLOAD_TOKEN", ""),
+		MaxUploadBytes:                 int64(l.envInt("BRIDGE_MAX_UPLOAD_BYTES", 20*1024*1024)),
+		CodexSessionEnabled:            l.envBool("CODEX_SESSION_ENABLED", true),
 		CodexAppServerBin:              codexBin,
-		CodexAppServerArgs:             strings.Fields(env("CODEX_APP_SERVER_ARGS", "")),
-		GeminiSessionBin:               env("GEMINI_CLI_BIN", "gemini"),
-		GeminiSessionArgs:              strings.Fields(env("GEMINI_SESSION_ARGS", "")),
-		ClaudeSessionBin:               env("CLAUDE_CLI_BIN", "claude"),
-		ClaudeSessionArgs:              strings.Fields(env("CLAUDE_SESSION_ARGS", "")),
+		CodexAppServerArgs:             strings.Fields(l.env("CODEX_APP_SERVER_ARGS", "")),
+		GeminiSessionBin:               l.env("GEMINI_CLI_BIN", "gemini"),
+		GeminiSessionArgs:              strings.Fields(l.env("GEMINI_SESSION_ARGS", "")),
+		ClaudeSessionBin:               l.env("CLAUDE_CLI_BIN", "claude"),
+		ClaudeSessionArgs:              strings.Fields(l.env("CLAUDE_SESSION_ARGS", "")),
+		GeminiSessionProtocol:          l.env("GEMINI_SESSION_PROTOCOL", "acp"),
+		ClaudeSessionProtocol:          l.env("CLAUDE_SESSION_PROTOCOL", "stream-json"),
 		CodexSessionStartTimeout:       time.Duration(codexSessionStartTimeoutSec) * time.Second,
 		CodexSessionRequestTimeout:     time.Duration(codexSessionRequestTimeoutSec) * time.Second,
 		SessionRetention:               time.Duration(sessionRetentionSec) * time.Second,
 		SessionCleanupPeriod:           time.Duration(sessionCleanupSec) * time.Second,
-		BackendCallReadMethods:         splitCSV(env("BACKEND_CALL_READ_METHODS", "status")),
-		BackendCallCancelMethods:       splitCSV(env("BACKEND_CALL_CANCEL_METHODS", "turn/interrupt")),
-		BackendCallBlockedMethods:      splitCSV(env("BACKEND_CALL_BLOCKED_METHODS", "initialize,initialized")),
-		CodexAdapter: AdapterConfig{
-			Enabled:    envBool("CODEX_ADAPTER_ENABLED", true),
-			GRPCAddr:   env("CODEX_ADAPTER_ADDR", "127.0.0.1:50051"),
-			BinaryPath: envPath("CODEX_ADAPTER_BIN", filepath.Join(baseDir, "codex-adapter"), baseDir),
-		},
-		GeminiAdapter: AdapterConfig{
-			Enabled:    envBool("GEMINI_ADAPTER_ENABLED", true),
-			GRPCAddr:   env("GEMINI_ADAPTER_ADDR", "127.0.0.1:50052"),
-			BinaryPath: envPath("GEMINI_ADAPTER_BIN", filepath.Join(baseDir, "gemini-adapter"), baseDir),
-		},
-		ClaudeAdapter: AdapterConfig{
-			Enabled:    envBool("CLAUDE_ADAPTER_ENABLED", false),
-			GRPCAddr:   env("CLAUDE_ADAPTER_ADDR", "127.0.0.1:50053"),
-			BinaryPath: envPath("CLAUDE_ADAPTER_BIN", filepath.Join(baseDir, "claude-adapter"), baseDir),
-		},
+		SessionHeartbeatInterval:       time.Duration(l.envInt("SESSION_HEARTBEAT_INTERVAL_SECONDS", 0)) * time.Second,
+		SessionHeartbeatTimeout:        time.Duration(l.envInt("SESSION_HEARTBEAT_TIMEOUT_SECONDS", 5)) * time.Second,
+		SessionHeartbeatFailures:       l.envInt("SESSION_HEARTBEAT_FAILURE_THRESHOLD", 3),
+		SessionHeartbeatMethod:         l.env("SESSION_HEARTBEAT_METHOD", "status"),
+		SessionAutoRestart:             l.envBool("SESSION_AUTO_RESTART", false),
+		SessionIdleSuspend:             time.Duration(l.envInt("SESSION_IDLE_SUSPEND_MINUTES", 0)) * time.Minute,
+		SessionMaxRSSBytes:             int64(l.envInt("SESSION_MAX_RSS_MB", 0)) << 20,
+		SessionNice:                    l.envInt("SESSION_NICE", 0),
+		SessionMaxProcesses:            l.envInt("SESSION_MAX_CHILD_PROCESSES", 0),
+		SessionMaxLifetime:             time.Duration(l.envInt("SESSION_MAX_LIFETIME_MINUTES", 0)) * time.Minute,
+		SessionTurnQueueDepth:          l.envInt("SESSION_TURN_QUEUE_DEPTH", 0),
+		BackendCallReadMethods:         splitCSV(l.env("BACKEND_CALL_READ_METHODS", "status")),
+		BackendCallCancelMethods:       splitCSV(l.env("BACKEND_CALL_CANCEL_METHODS", "turn/interrupt")),
+		BackendCallBlockedMethods:      splitCSV(l.env("BACKEND_CALL_BLOCKED_METHODS", "initialize,initialized")),
+		CommandGuardrails:              l.envBool("COMMAND_GUARDRAILS", true),
+		CommandDenylistFile:            l.envPath("COMMAND_DENYLIST_FILE", "", baseDir),
+		CommandGuardWorkspaceOnly:      l.envBool("COMMAND_GUARD_WORKSPACE_ONLY", true),
+		WSPingInterval:                 time.Duration(wsPingIntervalSec) * time.Second,
+		WSPongWait:                     time.Duration(wsPongWaitSec) * time.Second,
+		WSWriteTimeout:                 time.Duration(wsWriteTimeoutSec) * time.Second,
+		HTTPReadTimeout:                time.Duration(httpReadTimeoutSec) * time.Second,
+		HTTPWriteTimeout:               time.Duration(httpWriteTimeoutSec) * time.Second,
+		HTTPIdleTimeout:                time.Duration(httpIdleTimeoutSec) * time.Second,
+		HTTPMaxHeaderBytes:             l.envInt("HTTP_MAX_HEADER_BYTES", 64*1024),
+		HTTPHandlerTimeout:             time.Duration(httpHandlerTimeoutSec) * time.Second,
+		HTTPRouteTimeouts:              secondsMap(parseKVInt64CSV(l.env("HTTP_ROUTE_TIMEOUTS", ""))),
+		AdapterHealthInterval:          time.Duration(adapterHealthIntervalSec) * time.Second,
+		AdapterHealthFailureThreshold:  l.envInt("ADAPTER_HEALTH_FAILURE_THRESHOLD", 3),
+		AdapterRestartBackoffMax:       time.Duration(adapterRestartBackoffMaxSec) * time.Second,
+		CodexAdapter: withAdapterSecurity(l, "CODEX", baseDir, AdapterConfig{
+			Enabled:    l.envBool("CODEX_ADAPTER_ENABLED", true),
+			GRPCAddr:   l.env("CODEX_ADAPTER_ADDR", "127.0.0.1:50051"),
+			BinaryPath: l.envPath("CODEX_ADAPTER_BIN", filepath.Join(baseDir, "codex-adapter"), baseDir),
+		}),
+		GeminiAdapter: withAdapterSecurity(l, "GEMINI", baseDir, AdapterConfig{
+			Enabled:    l.envBool("GEMINI_ADAPTER_ENABLED", true),
+			GRPCAddr:   l.env("GEMINI_ADAPTER_ADDR", "127.0.0.1:50052"),
+			BinaryPath: l.envPath("GEMINI_ADAPTER_BIN", filepath.Join(baseDir, "gemini-adapter"), baseDir),
+		}),
+		ClaudeAdapter: withAdapterSecurity(l, "CLAUDE", baseDir, AdapterConfig{
+			Enabled:    l.envBool("CLAUDE_ADAPTER_ENABLED", false),
+			GRPCAddr:   l.env("CLAUDE_ADAPTER_ADDR", "127.0.0.1:50053"),
+			BinaryPath: l.envPath("CLAUDE_ADAPTER_BIN", filepath.Join(baseDir, "claude-adapter"), baseDir),
+		}),
+		AiderAdapter: withAdapterSecurity(l, "AIDER", baseDir, AdapterConfig{
+			Enabled:    l.envBool("AIDER_ADAPTER_ENABLED", false),
+			GRPCAddr:   l.env("AIDER_ADAPTER_ADDR", "127.0.0.1:50054"),
+			BinaryPath: l.envPath("AIDER_ADAPTER_BIN", filepath.Join(baseDir, "aider-adapter"), baseDir),
+		}),
 	}
+	l.record("BRIDGE_CONFIG_FILE", configFile, fileErr == nil)
+	if fileErr == nil {
+		if unknown := l.unknownFileKeys(); len(unknown) > 0 {
+			fileErr = fmt.Errorf("config file %s: unknown settings %s", configFile, strings.Join(unknown, ", "))
+		}
+	}
+	cfg.ConfigFile = configFile
+	cfg.Settings = l.list()
+	return cfg, fileErr
 }
 
 func env(k, def string) string {
-	if v := os.Getenv(k); v != "" {
+	return valueOr(os.Getenv(k), def)
+}
+
+func valueOr(v, def string) string {
+	if v != "" {
 		return v
 	}
 	return def
 }
 
 func envInt(k string, def int) int {
-	v := os.Getenv(k)
+	return parseInt(os.Getenv(k), def)
+}
+
+func parseInt(v string, def int) int {
 	if v == "" {
 		return def
 	}
@@ -151,7 +546,11 @@ func envInt(k string, def int) int {
 }
 
 func envBool(k string, def bool) bool {
-	v := strings.TrimSpace(strings.ToLower(os.Getenv(k)))
+	return parseBool(os.Getenv(k), def)
+}
+
+func parseBool(raw string, def bool) bool {
+	v := strings.TrimSpace(strings.ToLower(raw))
 	if v == "" {
 		return def
 	}
@@ -178,8 +577,22 @@ func splitCSV(v string) []string {
 	return out
 }
 
+// withAdapterSecurity fills the optional TLS/mTLS and shared-token settings
+// from <PREFIX>_ADAPTER_TLS_CERT, _TLS_KEY, _TLS_CA, _TLS_SERVER_NAME and _TOKEN.
+func withAdapterSecurity(l *loader, prefix, baseDir string, cfg AdapterConfig) AdapterConfig {
+	cfg.TLSCertFile = l.envPath(prefix+"_ADAPTER_TLS_CERT", "", baseDir)
+	cfg.TLSKeyFile = l.envPath(prefix+"_ADAPTER_TLS_KEY", "", baseDir)
+	cfg.TLSCAFile = l.envPath(prefix+"_ADAPTER_TLS_CA", "", baseDir)
+	cfg.TLSServerName = l.env(prefix+"_ADAPTER_TLS_SERVER_NAME", "")
+	cfg.AuthToken = l.env(prefix+"_ADAPTER_TOKEN", "")
+	return cfg
+}
+
 func envPath(k, def, baseDir string) string {
-	v := env(k, def)
+	return resolvePath(env(k, def), baseDir)
+}
+
+func resolvePath(v, baseDir string) string {
 	if v == "" {
 		return v
 	}
@@ -207,6 +620,14 @@ func executableDir() string {
 	return dir
 }
 
+func secondsMap(in map[string]int64) map[string]time.Duration {
+	out := make(map[string]time.Duration, len(in))
+	for k, v := range in {
+		out[k] = time.Duration(v) * time.Second
+	}
+	return out
+}
+
 func parseKVInt64CSV(v string) map[string]int64 {
 	out := map[string]int64{}
 	for _, part := range strings.Split(v, ",") {
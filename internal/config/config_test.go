@@ -1,6 +1,7 @@
 package config
 
 import (
+	"os"
 	"path/filepath"
 	"reflect"
 	"testing"
@@ -109,3 +110,88 @@ func TestLoadSessionCleanupDefaultsAndOverrides(t *testing.T) {
 		t.Fatalf("expected SessionCleanupPeriod=7s, got %s", cfg.SessionCleanupPeriod)
 	}
 }
+
+func TestLoadRecordsSettingProvenanceAndMasksSecrets(t *testing.T) {
+	t.Setenv("BRIDGE_HTTP_ADDR", "")
+	t.Setenv("MAX_CONCURRENT_RUNS", "8")
+	t.Setenv("SESSION_NICE", "high")
+	t.Setenv("BRIDGE_AUTH_TOKEN", "s3cret-token")
+	t.Setenv("BRIDGE_DB_DSN", "postgres://elix:hunter2@db:5432/elix")
+
+	before := Load()
+	settings := map[string]Setting{}
+	for _, s := range before.Settings {
+		settings[s.Key] = s
+	}
+	if s := settings["BRIDGE_HTTP_ADDR"]; s.Source != SourceDefault || s.Value != ":8765" {
+		t.Fatalf("unexpected BRIDGE_HTTP_ADDR setting: %#v", s)
+	}
+	if s := settings["MAX_CONCURRENT_RUNS"]; s.Source != SourceEnv || s.Value != "8" {
+		t.Fatalf("unexpected MAX_CONCURRENT_RUNS setting: %#v", s)
+	}
+	if s := settings["SESSION_NICE"]; s.Source != SourceDefault || s.Ignored != "high" {
+		t.Fatalf("expected unparsable SESSION_NICE to be ignored, got %#v", s)
+	}
+	if s := settings["BRIDGE_AUTH_TOKEN"]; !s.Secret || s.Value != maskedValue {
+		t.Fatalf("expected masked auth token, got %#v", s)
+	}
+	if s := settings["BRIDGE_DB_DSN"]; s.Value != "postgres://elix:xxxxx@db:5432/elix" {
+		t.Fatalf("expected DSN password redacted, got %q", s.Value)
+	}
+
+	t.Setenv("BRIDGE_AUTH_TOKEN", "rotated-token")
+	t.Setenv("MAX_CONCURRENT_RUNS", "")
+	changes := Diff(before.Settings, Load().Settings)
+	if len(changes) != 2 || changes[0].Key != "BRIDGE_AUTH_TOKEN" || changes[1].Key != "MAX_CONCURRENT_RUNS" {
+		t.Fatalf("unexpected diff: %#v", changes)
+	}
+	if changes[0].From != maskedValue || changes[0].To != maskedValue {
+		t.Fatalf("expected secret diff to stay masked, got %#v", changes[0])
+	}
+	if changes[1].To != "32" || changes[1].ToSource != SourceDefault {
+		t.Fatalf("unexpected MAX_CONCURRENT_RUNS change: %#v", changes[1])
+	}
+}
+
+func TestLoadReadsConfigFileBelowEnvironment(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "bridge.toml")
+	body := "# bridge settings\nmax_concurrent_runs = 12\nWORKSPACE_ROOTS = [\"/srv/a\", '/srv/b']\nBRIDGE_HTTP_ADDR: \":9000\" # listen\n"
+	if err := os.WriteFile(file, []byte(body), 0o600); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+	t.Setenv("BRIDGE_CONFIG_FILE", file)
+	t.Setenv("MAX_CONCURRENT_RUNS", "")
+	t.Setenv("WORKSPACE_ROOTS", "")
+	t.Setenv("BRIDGE_HTTP_ADDR", ":7000")
+
+	cfg, err := Reload()
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if cfg.MaxConcurrentRun != 12 || !reflect.DeepEqual(cfg.WorkspaceRoots, []string{"/srv/a", "/srv/b"}) {
+		t.Fatalf("file values not applied: runs=%d roots=%#v", cfg.MaxConcurrentRun, cfg.WorkspaceRoots)
+	}
+	if cfg.HTTPAddr != ":7000" {
+		t.Fatalf("expected the environment to win over the file, got %q", cfg.HTTPAddr)
+	}
+	settings := map[string]Setting{}
+	for _, s := range cfg.Settings {
+		settings[s.Key] = s
+	}
+	if s := settings["WORKSPACE_ROOTS"]; s.Source != SourceFile || !s.Reloadable {
+		t.Fatalf("unexpected WORKSPACE_ROOTS setting: %#v", s)
+	}
+	if s := settings["BRIDGE_HTTP_ADDR"]; s.Source != SourceEnv || s.Reloadable {
+		t.Fatalf("unexpected BRIDGE_HTTP_ADDR setting: %#v", s)
+	}
+
+	if err := os.WriteFile(file, []byte("workspace_roots: [/srv/a\n"), 0o600); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+	if _, err := Reload(); err == nil {
+		t.Fatalf("expected an unterminated list to fail the reload")
+	}
+	if cfg := Load(); len(cfg.WorkspaceRoots) != 1 || cfg.WorkspaceRoots[0] != "/tmp" {
+		t.Fatalf("expected Load to fall back to the environment, got %#v", cfg.WorkspaceRoots)
+	}
+}
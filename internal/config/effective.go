@@ -0,0 +1,196 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Setting is one environment variable as Load resolved it. Source is
+// "env" when the variable was set and used, "file" when it came from
+// BRIDGE_CONFIG_FILE, "default" otherwise; Ignored holds a set value that
+// could not be parsed, so the default applied. Reloadable settings take
+// effect on a config reload, the rest need a restart. Secrets are masked.
+type Setting struct {
+	Key        string `json:"key"`
+	Value      string `json:"value"`
+	Source     string `json:"source"`
+	Secret     bool   `json:"secret,omitempty"`
+	Reloadable bool   `json:"reloadable,omitempty"`
+	Ignored    string `json:"ignored,omitempty"`
+
+	// digest identifies a masked value so Diff can tell it changed.
+	digest string
+}
+
+const (
+	SourceEnv     = "env"
+	SourceFile    = "file"
+	SourceDefault = "default"
+
+	maskedValue = "********"
+)
+
+// SettingChange is a setting whose value or source differs between two
+// loads. From or To is empty when the setting only exists on one side.
+type SettingChange struct {
+	Key        string `json:"key"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	FromSource string `json:"from_source,omitempty"`
+	ToSource   string `json:"to_source,omitempty"`
+}
+
+// Diff lists the settings that changed from old to cur, sorted by key.
+func Diff(old, cur []Setting) []SettingChange {
+	before := make(map[string]Setting, len(old))
+	for _, s := range old {
+		before[s.Key] = s
+	}
+	out := []SettingChange{}
+	seen := map[string]bool{}
+	for _, s := range cur {
+		seen[s.Key] = true
+		prev, ok := before[s.Key]
+		if ok && prev.digest == s.digest && prev.Source == s.Source {
+			continue
+		}
+		out = append(out, SettingChange{Key: s.Key, From: prev.Value, To: s.Value, FromSource: prev.Source, ToSource: s.Source})
+	}
+	for _, s := range old {
+		if !seen[s.Key] {
+			out = append(out, SettingChange{Key: s.Key, From: s.Value, FromSource: s.Source})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// loader wraps the env helpers and records where each value came from.
+// Values from the config file apply to variables the environment leaves
+// unset.
+type loader struct {
+	settings map[string]Setting
+	file     map[string]string
+}
+
+func newLoader(file map[string]string) *loader {
+	return &loader{settings: map[string]Setting{}, file: file}
+}
+
+func (l *loader) lookup(k string) (string, string) {
+	if v := os.Getenv(k); v != "" {
+		return v, SourceEnv
+	}
+	if v := l.file[k]; v != "" {
+		return v, SourceFile
+	}
+	return "", SourceDefault
+}
+
+func (l *loader) record(k, value string, used bool) {
+	raw, source := l.lookup(k)
+	s := Setting{Key: k, Value: value, Source: SourceDefault, Secret: isSecretSetting(k), Reloadable: reloadableSettings[k]}
+	if raw != "" {
+		if used {
+			s.Source = source
+		} else {
+			s.Ignored = raw
+		}
+	}
+	sum := sha256.Sum256([]byte(value))
+	s.digest = hex.EncodeToString(sum[:])
+	if s.Secret {
+		s.Value = maskSecret(k, s.Value)
+		if s.Ignored != "" {
+			s.Ignored = maskedValue
+		}
+	}
+	l.settings[k] = s
+}
+
+func (l *loader) env(k, def string) string {
+	raw, _ := l.lookup(k)
+	v := valueOr(raw, def)
+	l.record(k, v, true)
+	return v
+}
+
+func (l *loader) envInt(k string, def int) int {
+	raw, _ := l.lookup(k)
+	n := parseInt(raw, def)
+	_, err := strconv.Atoi(raw)
+	l.record(k, strconv.Itoa(n), err == nil)
+	return n
+}
+
+func (l *loader) envBool(k string, def bool) bool {
+	raw, _ := l.lookup(k)
+	b := parseBool(raw, def)
+	used := false
+	switch strings.TrimSpace(strings.ToLower(raw)) {
+	case "1", "true", "yes", "on", "0", "false", "no", "off":
+		used = true
+	}
+	l.record(k, strconv.FormatBool(b), used)
+	return b
+}
+
+func (l *loader) envPath(k, def, baseDir string) string {
+	raw, _ := l.lookup(k)
+	v := resolvePath(valueOr(raw, def), baseDir)
+	l.record(k, v, true)
+	return v
+}
+
+// unknownFileKeys lists config file keys no setting read, which are
+// usually typos.
+func (l *loader) unknownFileKeys() []string {
+	var out []string
+	for k := range l.file {
+		if _, ok := l.settings[k]; !ok {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (l *loader) list() []Setting {
+	out := make([]Setting, 0, len(l.settings))
+	for _, s := range l.settings {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+func isSecretSetting(k string) bool {
+	for _, suffix := range []string{"_TOKEN", "_SECRET", "_PASSWORD", "_SIGNING_KEYS", "_API_KEY", "_DSN"} {
+		if strings.HasSuffix(k, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskSecret hides a secret value. Database DSNs keep everything but the
+// password, since the host and database are useful to verify.
+func maskSecret(k, v string) string {
+	if v == "" {
+		return ""
+	}
+	if strings.HasSuffix(k, "DSN") {
+		if u, err := url.Parse(v); err == nil && u.Scheme != "" {
+			if _, ok := u.User.Password(); ok {
+				u.User = url.UserPassword(u.User.Username(), "xxxxx")
+			}
+			return u.Redacted()
+		}
+	}
+	return maskedValue
+}
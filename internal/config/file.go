@@ -0,0 +1,120 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// reloadableSettings are the variables a running bridge picks up on a
+// config reload; everything else is read once at start-up.
+var reloadableSettings = map[string]bool{
+	"DAILY_TOKEN_QUOTA":                   true,
+	"DEVICE_DAILY_TOKEN_QUOTA":            true,
+	"QUOTA_ENFORCEMENT":                   true,
+	"AUTH_PAIR_START_RATE_LIMIT":          true,
+	"AUTH_PAIR_START_RATE_WINDOW_SECONDS": true,
+	"BACKEND_CALL_BLOCKED_METHODS":        true,
+	"WORKSPACE_ROOTS":                     true,
+	"RUN_TIMEOUT_MAX_SECONDS":             true,
+}
+
+// Reloadable reports whether a config reload applies setting k.
+func Reloadable(k string) bool {
+	return reloadableSettings[k]
+}
+
+// readConfigFile parses the flat settings file named by BRIDGE_CONFIG_FILE.
+// Each line is "KEY: value" (YAML) or "KEY = value" (TOML), where KEY is the
+// environment variable name in either case. Values may be quoted, and an
+// inline list [a, b] becomes the comma-separated form the variable takes.
+// Blank lines and # comments are skipped; sections and nesting are not
+// supported.
+func readConfigFile(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	defer f.Close()
+	out := map[string]string{}
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(sc.Text()))
+		if line == "" || line == "---" {
+			continue
+		}
+		i := strings.IndexAny(line, ":=")
+		if i <= 0 {
+			return nil, fmt.Errorf("config file %s:%d: expected KEY: value", path, n)
+		}
+		key := strings.ToUpper(strings.TrimSpace(line[:i]))
+		if strings.ContainsAny(key, " \t[]") {
+			return nil, fmt.Errorf("config file %s:%d: invalid key %q", path, n, key)
+		}
+		value, err := parseFileValue(strings.TrimSpace(line[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("config file %s:%d: %s: %w", path, n, key, err)
+		}
+		if _, dup := out[key]; dup {
+			return nil, fmt.Errorf("config file %s:%d: %s is set twice", path, n, key)
+		}
+		out[key] = value
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return out, nil
+}
+
+// stripComment drops a # comment that is not inside a quoted value.
+func stripComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func parseFileValue(v string) (string, error) {
+	if strings.HasPrefix(v, "[") {
+		if !strings.HasSuffix(v, "]") {
+			return "", fmt.Errorf("unterminated list")
+		}
+		var items []string
+		for _, item := range strings.Split(v[1:len(v)-1], ",") {
+			item, err := unquote(strings.TrimSpace(item))
+			if err != nil {
+				return "", err
+			}
+			if item != "" {
+				items = append(items, item)
+			}
+		}
+		return strings.Join(items, ","), nil
+	}
+	return unquote(v)
+}
+
+func unquote(v string) (string, error) {
+	if len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'' {
+		return v[1 : len(v)-1], nil
+	}
+	if strings.HasPrefix(v, `"`) {
+		return strconv.Unquote(v)
+	}
+	return v, nil
+}
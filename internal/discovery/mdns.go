@@ -0,0 +1,358 @@
+// Package discovery advertises the bridge on the local network with
+// mDNS/DNS-SD so apps on the same LAN can find it without an address.
+package discovery
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ServiceType is the DNS-SD service the bridge registers.
+const ServiceType = "_elix._tcp"
+
+const (
+	hostTTL    = 120
+	serviceTTL = 4500
+	// cacheFlush marks unique records in the class field (RFC 6762 10.2).
+	cacheFlush = 0x8000
+)
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// Config describes the advertised service. Zero Instance and Host default
+// to the machine's hostname; a zero Interval means 30s.
+type Config struct {
+	Instance string
+	Host     string
+	Port     int
+	Version  string
+	// Pairing reports whether the bridge currently accepts pairing; it is
+	// advertised as pairing=open or pairing=closed.
+	Pairing func() bool
+	// Interval is how often the TXT record is checked for changes, which
+	// are announced right away.
+	Interval time.Duration
+}
+
+// Broadcaster answers mDNS queries for the bridge service and announces it
+// on start, on TXT changes and with a goodbye on shutdown.
+type Broadcaster struct {
+	cfg      Config
+	service  dnsmessage.Name
+	instance dnsmessage.Name
+	host     dnsmessage.Name
+
+	mu    sync.Mutex
+	conn  *net.UDPConn
+	addrs func() []net.IP
+}
+
+func New(cfg Config) (*Broadcaster, error) {
+	if cfg.Port <= 0 || cfg.Port > 65535 {
+		return nil, errors.New("discovery: invalid port")
+	}
+	hostname, _ := os.Hostname()
+	hostname = strings.SplitN(hostname, ".", 2)[0]
+	if hostname == "" {
+		hostname = "elix-bridge"
+	}
+	if cfg.Instance == "" {
+		cfg.Instance = hostname
+	}
+	if cfg.Host == "" {
+		cfg.Host = hostname
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	b := &Broadcaster{cfg: cfg, addrs: interfaceIPv4s}
+	var err error
+	if b.service, err = dnsmessage.NewName(ServiceType + ".local."); err != nil {
+		return nil, err
+	}
+	if b.instance, err = dnsmessage.NewName(label(cfg.Instance) + "." + ServiceType + ".local."); err != nil {
+		return nil, err
+	}
+	if b.host, err = dnsmessage.NewName(label(cfg.Host) + ".local."); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// label makes s usable as a single DNS label.
+func label(s string) string {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ".", "-")
+	if len(s) > 63 {
+		s = s[:63]
+	}
+	return s
+}
+
+// Start joins the mDNS multicast group and serves until ctx is done.
+func (b *Broadcaster) Start(ctx context.Context) error {
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.conn = conn
+	b.mu.Unlock()
+
+	go b.serve(conn)
+	go func() {
+		txt := b.txt()
+		b.announce(hostTTL, serviceTTL)
+		timer := time.NewTimer(time.Second)
+		ticker := time.NewTicker(b.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				b.announce(0, 0)
+				_ = conn.Close()
+				return
+			case <-timer.C:
+				// RFC 6762 8.3: announce at least twice, a second apart.
+				b.announce(hostTTL, serviceTTL)
+			case <-ticker.C:
+				if cur := b.txt(); strings.Join(cur, ",") != strings.Join(txt, ",") {
+					txt = cur
+					b.announce(hostTTL, serviceTTL)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (b *Broadcaster) serve(conn *net.UDPConn) {
+	buf := make([]byte, 9000)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("mdns read: %v", err)
+			}
+			return
+		}
+		var query dnsmessage.Message
+		if err := query.Unpack(buf[:n]); err != nil || query.Header.Response {
+			continue
+		}
+		resp, unicast := b.answer(query, src.Port != mdnsGroup.Port)
+		if resp == nil {
+			continue
+		}
+		dst := mdnsGroup
+		if unicast {
+			dst = src
+		}
+		if _, err := conn.WriteToUDP(resp, dst); err != nil {
+			log.Printf("mdns reply: %v", err)
+		}
+	}
+}
+
+func (b *Broadcaster) announce(hostTTL, serviceTTL uint32) {
+	b.mu.Lock()
+	conn := b.conn
+	b.mu.Unlock()
+	msg, err := b.build(dnsmessage.Header{Response: true, Authoritative: true}, nil, b.serviceRecords(hostTTL, serviceTTL), nil)
+	if err != nil {
+		log.Printf("mdns announce: %v", err)
+		return
+	}
+	if _, err := conn.WriteToUDP(msg, mdnsGroup); err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Printf("mdns announce: %v", err)
+	}
+}
+
+// answer builds the response to query, or nil when nothing matches. Legacy
+// (non-5353) queries and questions with the unicast-response bit get a
+// unicast reply that echoes the query ID and questions.
+func (b *Broadcaster) answer(query dnsmessage.Message, legacy bool) ([]byte, bool) {
+	var answers, additionals []dnsmessage.Resource
+	unicast := legacy
+	for _, q := range query.Questions {
+		if uint16(q.Class)&cacheFlush != 0 {
+			unicast = true
+		}
+		name := strings.ToLower(q.Name.String())
+		switch {
+		case name == "_services._dns-sd._udp.local." && matchType(q.Type, dnsmessage.TypePTR):
+			answers = append(answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName("_services._dns-sd._udp.local."), Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET, TTL: serviceTTL},
+				Body:   &dnsmessage.PTRResource{PTR: b.service},
+			})
+		case name == strings.ToLower(b.service.String()) && matchType(q.Type, dnsmessage.TypePTR):
+			records := b.serviceRecords(hostTTL, serviceTTL)
+			answers = append(answers, records[0])
+			additionals = append(additionals, records[1:]...)
+		case name == strings.ToLower(b.instance.String()):
+			records := b.serviceRecords(hostTTL, serviceTTL)[1:]
+			for _, rr := range records[:2] {
+				if matchType(q.Type, rr.Header.Type) {
+					answers = append(answers, rr)
+				}
+			}
+			additionals = append(additionals, records[2:]...)
+		case name == strings.ToLower(b.host.String()) && matchType(q.Type, dnsmessage.TypeA):
+			answers = append(answers, b.hostRecords(hostTTL)...)
+		}
+	}
+	if len(answers) == 0 {
+		return nil, false
+	}
+	hdr := dnsmessage.Header{Response: true, Authoritative: true}
+	var questions []dnsmessage.Question
+	if legacy {
+		hdr.ID = query.Header.ID
+		questions = query.Questions
+		for i := range answers {
+			answers[i].Header.TTL = min(answers[i].Header.TTL, 10)
+		}
+	}
+	msg, err := b.build(hdr, questions, answers, additionals)
+	if err != nil {
+		log.Printf("mdns answer: %v", err)
+		return nil, false
+	}
+	return msg, unicast
+}
+
+func matchType(asked, have dnsmessage.Type) bool {
+	return asked == have || asked == dnsmessage.TypeALL
+}
+
+// serviceRecords is PTR, SRV, TXT and the host's A records.
+func (b *Broadcaster) serviceRecords(hostTTL, serviceTTL uint32) []dnsmessage.Resource {
+	unique := dnsmessage.Class(uint16(dnsmessage.ClassINET) | cacheFlush)
+	records := []dnsmessage.Resource{
+		{
+			Header: dnsmessage.ResourceHeader{Name: b.service, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET, TTL: serviceTTL},
+			Body:   &dnsmessage.PTRResource{PTR: b.instance},
+		},
+		{
+			Header: dnsmessage.ResourceHeader{Name: b.instance, Type: dnsmessage.TypeSRV, Class: unique, TTL: hostTTL},
+			Body:   &dnsmessage.SRVResource{Target: b.host, Port: uint16(b.cfg.Port)},
+		},
+		{
+			Header: dnsmessage.ResourceHeader{Name: b.instance, Type: dnsmessage.TypeTXT, Class: unique, TTL: serviceTTL},
+			Body:   &dnsmessage.TXTResource{TXT: b.txt()},
+		},
+	}
+	return append(records, b.hostRecords(hostTTL)...)
+}
+
+func (b *Broadcaster) hostRecords(ttl uint32) []dnsmessage.Resource {
+	unique := dnsmessage.Class(uint16(dnsmessage.ClassINET) | cacheFlush)
+	var out []dnsmessage.Resource
+	for _, ip := range b.addrs() {
+		var a [4]byte
+		copy(a[:], ip.To4())
+		out = append(out, dnsmessage.Resource{
+			Header: dnsmessage.ResourceHeader{Name: b.host, Type: dnsmessage.TypeA, Class: unique, TTL: ttl},
+			Body:   &dnsmessage.AResource{A: a},
+		})
+	}
+	return out
+}
+
+func (b *Broadcaster) txt() []string {
+	pairing := "closed"
+	if b.cfg.Pairing != nil && b.cfg.Pairing() {
+		pairing = "open"
+	}
+	txt := []string{"txtvers=1", "api=v3", "pairing=" + pairing, "port=" + strconv.Itoa(b.cfg.Port)}
+	if b.cfg.Version != "" {
+		txt = append(txt, "version="+b.cfg.Version)
+	}
+	return txt
+}
+
+func (b *Broadcaster) build(hdr dnsmessage.Header, questions []dnsmessage.Question, answers, additionals []dnsmessage.Resource) ([]byte, error) {
+	builder := dnsmessage.NewBuilder(nil, hdr)
+	builder.EnableCompression()
+	if err := builder.StartQuestions(); err != nil {
+		return nil, err
+	}
+	for _, q := range questions {
+		if err := builder.Question(q); err != nil {
+			return nil, err
+		}
+	}
+	if err := builder.StartAnswers(); err != nil {
+		return nil, err
+	}
+	for _, rr := range answers {
+		if err := addResource(&builder, rr); err != nil {
+			return nil, err
+		}
+	}
+	if err := builder.StartAdditionals(); err != nil {
+		return nil, err
+	}
+	for _, rr := range additionals {
+		if err := addResource(&builder, rr); err != nil {
+			return nil, err
+		}
+	}
+	return builder.Finish()
+}
+
+func addResource(builder *dnsmessage.Builder, rr dnsmessage.Resource) error {
+	switch body := rr.Body.(type) {
+	case *dnsmessage.PTRResource:
+		return builder.PTRResource(rr.Header, *body)
+	case *dnsmessage.SRVResource:
+		return builder.SRVResource(rr.Header, *body)
+	case *dnsmessage.TXTResource:
+		return builder.TXTResource(rr.Header, *body)
+	case *dnsmessage.AResource:
+		return builder.AResource(rr.Header, *body)
+	}
+	return errors.New("discovery: unsupported record")
+}
+
+// interfaceIPv4s lists the IPv4 addresses of up, non-loopback interfaces.
+func interfaceIPv4s() []net.IP {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var out []net.IP
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+				out = append(out, ipnet.IP.To4())
+			}
+		}
+	}
+	return out
+}
+
+// PortFromAddr returns the port of a listen address such as ":8765".
+func PortFromAddr(addr string) (int, error) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(port)
+}
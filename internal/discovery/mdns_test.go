@@ -0,0 +1,96 @@
+package discovery
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestAnswerBrowseQueryAdvertisesServiceAndPairing(t *testing.T) {
+	pairing := true
+	b, err := New(Config{Instance: "desk", Host: "desk", Port: 8765, Version: "1.2.0", Pairing: func() bool { return pairing }})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	b.addrs = func() []net.IP { return []net.IP{net.IPv4(192, 168, 1, 20)} }
+
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: 7},
+		Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName("_elix._tcp.local."), Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET}},
+	}
+	raw, unicast := b.answer(query, false)
+	if raw == nil || unicast {
+		t.Fatalf("expected multicast answer, got raw=%v unicast=%v", raw != nil, unicast)
+	}
+	var msg dnsmessage.Message
+	if err := msg.Unpack(raw); err != nil {
+		t.Fatalf("unpack: %v", err)
+	}
+	if msg.Header.ID != 0 || !msg.Header.Response || len(msg.Questions) != 0 {
+		t.Fatalf("unexpected header: %+v questions=%d", msg.Header, len(msg.Questions))
+	}
+	if len(msg.Answers) != 1 {
+		t.Fatalf("expected one PTR answer, got %d", len(msg.Answers))
+	}
+	if ptr, ok := msg.Answers[0].Body.(*dnsmessage.PTRResource); !ok || ptr.PTR.String() != "desk._elix._tcp.local." {
+		t.Fatalf("unexpected PTR: %+v", msg.Answers[0].Body)
+	}
+
+	var gotSRV, gotA bool
+	txt := map[string]bool{}
+	for _, rr := range msg.Additionals {
+		switch body := rr.Body.(type) {
+		case *dnsmessage.SRVResource:
+			gotSRV = body.Port == 8765 && body.Target.String() == "desk.local."
+		case *dnsmessage.TXTResource:
+			for _, kv := range body.TXT {
+				txt[kv] = true
+			}
+		case *dnsmessage.AResource:
+			gotA = body.A == [4]byte{192, 168, 1, 20}
+		}
+	}
+	if !gotSRV || !gotA {
+		t.Fatalf("missing SRV or A record: srv=%v a=%v", gotSRV, gotA)
+	}
+	for _, want := range []string{"api=v3", "pairing=open", "port=8765", "version=1.2.0"} {
+		if !txt[want] {
+			t.Fatalf("TXT missing %q: %v", want, txt)
+		}
+	}
+
+	pairing = false
+	if got := b.txt(); got[2] != "pairing=closed" {
+		t.Fatalf("expected pairing=closed, got %v", got)
+	}
+}
+
+func TestAnswerLegacyQueryIsUnicastAndEchoesID(t *testing.T) {
+	b, err := New(Config{Instance: "desk", Host: "desk", Port: 8765})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	b.addrs = func() []net.IP { return []net.IP{net.IPv4(10, 0, 0, 5)} }
+
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: 42},
+		Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName("desk.local."), Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}},
+	}
+	raw, unicast := b.answer(query, true)
+	if raw == nil || !unicast {
+		t.Fatalf("expected unicast answer")
+	}
+	var msg dnsmessage.Message
+	if err := msg.Unpack(raw); err != nil {
+		t.Fatalf("unpack: %v", err)
+	}
+	if msg.Header.ID != 42 || len(msg.Questions) != 1 || len(msg.Answers) != 1 || msg.Answers[0].Header.TTL > 10 {
+		t.Fatalf("unexpected legacy reply: %+v", msg)
+	}
+
+	query.Questions[0].Name = dnsmessage.MustNewName("other.local.")
+	if raw, _ := b.answer(query, true); raw != nil {
+		t.Fatalf("expected no answer for unknown host")
+	}
+}
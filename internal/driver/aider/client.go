@@ -0,0 +1,14 @@
+// Package aider drives the Aider adapter. It speaks the shared adapter RPC,
+// so the driver is the external one under the backend name "aider".
+package aider
+
+import (
+	"echohelix/internal/adapter/supervisor"
+	"echohelix/internal/driver/external"
+)
+
+// New returns the driver for the Aider adapter at addr, started and
+// restarted by sup.
+func New(addr string, sup *supervisor.Supervisor) *external.Driver {
+	return external.New("aider", addr, sup)
+}
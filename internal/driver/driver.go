@@ -2,7 +2,10 @@ package driver
 
 import (
 	"context"
+	"time"
 
+	"echohelix/internal/adapter/supervisor"
+	"echohelix/internal/envprofile"
 	"echohelix/internal/events"
 )
 
@@ -13,6 +16,10 @@ type StartRequest struct {
 	Prompt        string
 	Context       map[string]any
 	Options       RunOptions
+	EnvProfile    envprofile.Profile
+	// Deadline is when the run times out. It overrides the context
+	// deadline, which cannot express a deadline that may later be extended.
+	Deadline time.Time
 }
 
 type RunOptions struct {
@@ -20,6 +27,11 @@ type RunOptions struct {
 	Profile       string
 	Sandbox       string
 	SchemaVersion string
+	Interactive   bool
+	PTY           bool
+	PTYCols       int
+	PTYRows       int
+	RawOutput     bool
 }
 
 type Stream struct {
@@ -37,9 +49,19 @@ type CapabilitySet struct {
 	EventTypes             []string `json:"event_types"`
 	SupportsCancel         bool     `json:"supports_cancel"`
 	SupportsPTY            bool     `json:"supports_pty"`
+	SupportsInput          bool     `json:"supports_input"`
+	SupportsPause          bool     `json:"supports_pause"`
 	SchemaVersions         []string `json:"schema_versions,omitempty"`
 	PreferredSchemaVersion string   `json:"preferred_schema_version,omitempty"`
 	CompatFields           []string `json:"compat_fields,omitempty"`
+	// Tools is the tool inventory the backend declares (shell, web,
+	// editor); nil means the backend does not declare one.
+	Tools []string `json:"tools,omitempty"`
+	// Models, Profiles and SandboxModes list the option values the backend
+	// accepts; nil means it does not restrict them.
+	Models       []string `json:"models,omitempty"`
+	Profiles     []string `json:"profiles,omitempty"`
+	SandboxModes []string `json:"sandbox_modes,omitempty"`
 }
 
 type Driver interface {
@@ -49,3 +71,56 @@ type Driver interface {
 	Health(ctx context.Context) (Health, error)
 	Capabilities(ctx context.Context) (CapabilitySet, error)
 }
+
+// Supervised is implemented by drivers backed by a locally supervised
+// adapter process.
+type Supervised interface {
+	Supervisor() *supervisor.Supervisor
+}
+
+// InputSender is implemented by drivers that can write to the stdin of an
+// interactive run.
+type InputSender interface {
+	SendInput(ctx context.Context, runID, data string, eof bool) error
+}
+
+// DeadlineExtender is implemented by drivers that can move the timeout of a
+// running run to timeout from now.
+type DeadlineExtender interface {
+	ExtendRun(ctx context.Context, runID string, timeout time.Duration) error
+}
+
+// ModelInfo is one model a backend accepts. ContextWindow is in tokens, zero
+// when unknown.
+type ModelInfo struct {
+	ID            string `json:"id"`
+	ContextWindow int64  `json:"context_window,omitempty"`
+	Default       bool   `json:"default"`
+}
+
+// ModelLister is implemented by drivers whose adapter can list its models.
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]ModelInfo, error)
+}
+
+// Pauser is implemented by drivers that can suspend and continue a running
+// run. Whether a given adapter can is reported by SupportsPause.
+type Pauser interface {
+	PauseRun(ctx context.Context, runID string) error
+	ResumeRun(ctx context.Context, runID string) error
+}
+
+// RawLog is the tail of a run's captured CLI output. Found is false when the
+// adapter kept no log for the run, Disabled when it captures none at all.
+type RawLog struct {
+	Found     bool
+	Disabled  bool
+	Data      string
+	Size      int64
+	Truncated bool
+}
+
+// RawLogReader is implemented by drivers whose adapter keeps raw run logs.
+type RawLogReader interface {
+	RawLog(ctx context.Context, runID string, maxBytes int64) (RawLog, error)
+}
@@ -0,0 +1,349 @@
+// Package external drives adapters attached to the bridge at runtime. It
+// speaks the same adapter RPC as the built-in backends but takes its backend
+// name, and the adapter process is supervised only when a binary is given.
+package external
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"echohelix/internal/adapter/supervisor"
+	"echohelix/internal/driver"
+	"echohelix/internal/events"
+	adapterrpc "echohelix/internal/rpc/adapter"
+	"echohelix/internal/rpc/codec"
+	"echohelix/internal/rpc/transport"
+
+	"google.golang.org/grpc"
+)
+
+type Driver struct {
+	name       string
+	addr       string
+	supervisor *supervisor.Supervisor
+	security   transport.Security
+
+	mu     sync.Mutex
+	conn   *grpc.ClientConn
+	client adapterrpc.AdapterClient
+}
+
+// New returns a driver for the adapter at addr. sup may be nil for adapters
+// that are managed outside the bridge.
+func New(name, addr string, sup *supervisor.Supervisor) *Driver {
+	return &Driver{
+		name:       name,
+		addr:       addr,
+		supervisor: sup,
+	}
+}
+
+// SetTransportSecurity configures TLS/mTLS and the shared token used to reach
+// the adapter. It must be called before the first RPC.
+func (d *Driver) SetTransportSecurity(sec transport.Security) {
+	d.mu.Lock()
+	d.security = sec
+	d.mu.Unlock()
+}
+
+// Supervisor exposes the adapter process supervisor for health monitoring.
+func (d *Driver) Supervisor() *supervisor.Supervisor {
+	return d.supervisor
+}
+
+func (d *Driver) Name() string {
+	return d.name
+}
+
+// Close drops the adapter connection and stops a supervised adapter process.
+func (d *Driver) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var err error
+	if d.conn != nil {
+		err = d.conn.Close()
+		d.conn, d.client = nil, nil
+	}
+	if d.supervisor != nil {
+		if stopErr := d.supervisor.Stop(); err == nil {
+			err = stopErr
+		}
+	}
+	return err
+}
+
+func (d *Driver) StartRun(ctx context.Context, req driver.StartRequest) (*driver.Stream, error) {
+	client, err := d.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	timeoutSec := int32(1800)
+	deadline, ok := ctx.Deadline()
+	if !req.Deadline.IsZero() {
+		deadline, ok = req.Deadline, true
+	}
+	if ok {
+		timeoutSec = int32(time.Until(deadline).Seconds())
+		if timeoutSec <= 0 {
+			timeoutSec = 1
+		}
+	}
+
+	res, err := client.StartRun(ctx, &adapterrpc.StartRunRequest{
+		RunID:         req.RunID,
+		WorkspacePath: req.WorkspacePath,
+		Prompt:        req.Prompt,
+		Context:       req.Context,
+		Model:         req.Options.Model,
+		Profile:       req.Options.Profile,
+		Sandbox:       req.Options.Sandbox,
+		SchemaVersion: req.Options.SchemaVersion,
+		TimeoutSec:    timeoutSec,
+		Env:           req.EnvProfile.Env,
+		PathPrepend:   req.EnvProfile.PathPrepend,
+		ShellInit:     req.EnvProfile.ShellInit,
+		Interactive:   req.Options.Interactive,
+		PTY:           req.Options.PTY,
+		PTYCols:       uint32(max(req.Options.PTYCols, 0)),
+		PTYRows:       uint32(max(req.Options.PTYRows, 0)),
+		RawOutput:     req.Options.RawOutput,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !res.Accepted {
+		return nil, fmt.Errorf("adapter rejected run: %s", res.Error)
+	}
+
+	eventsCh := make(chan events.Event, 128)
+	doneCh := make(chan error, 1)
+	go d.consumeEvents(ctx, req, client, eventsCh, doneCh)
+	return &driver.Stream{Events: eventsCh, Done: doneCh}, nil
+}
+
+func (d *Driver) consumeEvents(
+	ctx context.Context,
+	req driver.StartRequest,
+	client adapterrpc.AdapterClient,
+	eventsCh chan<- events.Event,
+	doneCh chan<- error,
+) {
+	defer close(eventsCh)
+	defer close(doneCh)
+
+	stream, err := client.StreamEvents(ctx, &adapterrpc.StreamEventsRequest{RunID: req.RunID})
+	if err != nil {
+		doneCh <- driver.StreamError(ctx, err)
+		return
+	}
+	for {
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			doneCh <- nil
+			return
+		}
+		if err != nil {
+			doneCh <- driver.StreamError(ctx, err)
+			return
+		}
+
+		e := events.Event{
+			RunID:         ev.RunID,
+			TS:            time.Unix(ev.TsUnix, 0).UTC(),
+			SchemaVersion: ev.SchemaVersion,
+			Type:          ev.Type,
+			Channel:       ev.Channel,
+			Format:        ev.Format,
+			Role:          ev.Role,
+			Compat: &events.CompatFields{
+				Text:    ev.CompatText,
+				Status:  ev.CompatStatus,
+				IsError: ev.CompatIsError,
+			},
+			Payload: ev.Payload,
+			Backend: d.Name(),
+			Source:  ev.Source,
+		}
+		select {
+		case eventsCh <- e:
+		case <-ctx.Done():
+			doneCh <- driver.StreamError(ctx, ctx.Err())
+			return
+		}
+	}
+}
+
+func (d *Driver) Cancel(ctx context.Context, runID string) error {
+	client, err := d.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	res, err := client.CancelRun(ctx, &adapterrpc.CancelRunRequest{RunID: runID})
+	if err != nil {
+		return err
+	}
+	if !res.Cancelled {
+		return fmt.Errorf("adapter refused cancel: %s", res.Error)
+	}
+	return nil
+}
+
+func (d *Driver) SendInput(ctx context.Context, runID, data string, eof bool) error {
+	client, err := d.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	res, err := client.StreamInput(ctx, &adapterrpc.StreamInputRequest{RunID: runID, Data: data, EOF: eof})
+	if err != nil {
+		return err
+	}
+	if !res.Accepted {
+		return fmt.Errorf("adapter rejected input: %s", res.Error)
+	}
+	return nil
+}
+
+func (d *Driver) ExtendRun(ctx context.Context, runID string, timeout time.Duration) error {
+	client, err := d.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	res, err := client.ExtendRun(ctx, &adapterrpc.ExtendRunRequest{RunID: runID, TimeoutSec: int32(max(timeout.Seconds(), 1))})
+	if err != nil {
+		return err
+	}
+	if !res.Extended {
+		return fmt.Errorf("adapter rejected deadline extension: %s", res.Error)
+	}
+	return nil
+}
+
+func (d *Driver) PauseRun(ctx context.Context, runID string) error {
+	client, err := d.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	res, err := client.PauseRun(ctx, &adapterrpc.PauseRunRequest{RunID: runID})
+	if err != nil {
+		return err
+	}
+	if !res.Paused {
+		return fmt.Errorf("adapter rejected pause: %s", res.Error)
+	}
+	return nil
+}
+
+func (d *Driver) ResumeRun(ctx context.Context, runID string) error {
+	client, err := d.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	res, err := client.ResumeRun(ctx, &adapterrpc.ResumeRunRequest{RunID: runID})
+	if err != nil {
+		return err
+	}
+	if !res.Resumed {
+		return fmt.Errorf("adapter rejected resume: %s", res.Error)
+	}
+	return nil
+}
+
+func (d *Driver) Health(ctx context.Context) (driver.Health, error) {
+	client, err := d.getClient(ctx)
+	if err != nil {
+		return driver.Health{OK: false, Message: err.Error()}, err
+	}
+	res, err := client.Health(ctx, &adapterrpc.HealthRequest{})
+	if err != nil {
+		return driver.Health{OK: false, Message: err.Error()}, err
+	}
+	return driver.Health{OK: res.OK, Message: res.Message}, nil
+}
+
+func (d *Driver) Capabilities(ctx context.Context) (driver.CapabilitySet, error) {
+	client, err := d.getClient(ctx)
+	if err != nil {
+		return driver.CapabilitySet{}, err
+	}
+	res, err := client.Capabilities(ctx, &adapterrpc.CapabilitiesRequest{})
+	if err != nil {
+		return driver.CapabilitySet{}, err
+	}
+	return driver.CapabilitySet{
+		Backend:                res.Backend,
+		EventTypes:             res.EventTypes,
+		SupportsCancel:         res.SupportsCancel,
+		SupportsPTY:            res.SupportsPTY,
+		SupportsInput:          res.SupportsInput,
+		SupportsPause:          res.SupportsPause,
+		SchemaVersions:         res.SchemaVersions,
+		PreferredSchemaVersion: res.PreferredSchemaVersion,
+		CompatFields:           res.CompatFields,
+		Tools:                  res.Tools,
+		Models:                 res.Models,
+		Profiles:               res.Profiles,
+		SandboxModes:           res.SandboxModes,
+	}, nil
+}
+
+func (d *Driver) ListModels(ctx context.Context) ([]driver.ModelInfo, error) {
+	client, err := d.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.ListModels(ctx, &adapterrpc.ListModelsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]driver.ModelInfo, 0, len(res.Models))
+	for _, m := range res.Models {
+		out = append(out, driver.ModelInfo{ID: m.ID, ContextWindow: m.ContextWindow, Default: m.Default})
+	}
+	return out, nil
+}
+
+func (d *Driver) RawLog(ctx context.Context, runID string, maxBytes int64) (driver.RawLog, error) {
+	client, err := d.getClient(ctx)
+	if err != nil {
+		return driver.RawLog{}, err
+	}
+	res, err := client.GetRawLog(ctx, &adapterrpc.GetRawLogRequest{RunID: runID, MaxBytes: maxBytes})
+	if err != nil {
+		return driver.RawLog{}, err
+	}
+	if res.Error != "" && !res.Found && !res.Disabled {
+		return driver.RawLog{}, fmt.Errorf("adapter raw log: %s", res.Error)
+	}
+	return driver.RawLog{Found: res.Found, Disabled: res.Disabled, Data: res.Data, Size: res.Size, Truncated: res.Truncated}, nil
+}
+
+func (d *Driver) getClient(ctx context.Context) (adapterrpc.AdapterClient, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.supervisor != nil {
+		if err := d.supervisor.EnsureRunning(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if d.client != nil {
+		return d.client, nil
+	}
+
+	opts, err := d.security.DialOptions()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, grpc.WithDefaultCallOptions(grpc.ForceCodec(codec.JSONCodec{})))
+	conn, err := grpc.DialContext(ctx, d.addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	d.conn = conn
+	d.client = adapterrpc.NewAdapterClient(conn)
+	return d.client, nil
+}
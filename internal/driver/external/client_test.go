@@ -0,0 +1,73 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"echohelix/internal/adapter/runtime"
+	"echohelix/internal/driver"
+	adapterrpc "echohelix/internal/rpc/adapter"
+	"echohelix/internal/rpc/codec"
+
+	"google.golang.org/grpc"
+)
+
+func TestStreamDoneErrorDistinguishesCancelFromDisconnect(t *testing.T) {
+	start := func(t *testing.T) (*Driver, *grpc.Server) {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv := grpc.NewServer(grpc.ForceServerCodec(codec.JSONCodec{}))
+		adapterrpc.RegisterAdapterServer(srv, runtime.NewServer(runtime.Config{
+			Backend:       "sleeper",
+			CLIBinDefault: "sleep",
+			Mapper:        func(string, string) (runtime.NormalizedEvent, bool) { return runtime.NormalizedEvent{}, false },
+			ApplyPromptArg: func([]string, string, string) []string {
+				return []string{"5"}
+			},
+		}))
+		go srv.Serve(lis)
+		t.Cleanup(srv.Stop)
+		return New("sleeper", lis.Addr().String(), nil), srv
+	}
+	waitDone := func(t *testing.T, stream *driver.Stream) error {
+		go func() {
+			for range stream.Events {
+			}
+		}()
+		select {
+		case err := <-stream.Done:
+			return err
+		case <-time.After(2 * time.Second):
+			t.Fatal("stream did not end promptly")
+			return nil
+		}
+	}
+
+	drv, _ := start(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := drv.StartRun(ctx, driver.StartRequest{RunID: "r1", WorkspacePath: t.TempDir(), Prompt: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	if err := waitDone(t, stream); !errors.Is(err, driver.ErrRunCancelled) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected bridge cancel, got %v", err)
+	}
+
+	drv, srv := start(t)
+	stream, err = drv.StartRun(context.Background(), driver.StartRequest{RunID: "r2", WorkspacePath: t.TempDir(), Prompt: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	srv.Stop()
+	if err := waitDone(t, stream); !errors.Is(err, driver.ErrAdapterDisconnected) {
+		t.Fatalf("expected disconnect, got %v", err)
+	}
+}
@@ -1,8 +1,12 @@
 package driver
 
-import "fmt"
+import (
+	"fmt"
+	"sync"
+)
 
 type Registry struct {
+	mu      sync.RWMutex
 	drivers map[string]Driver
 }
 
@@ -13,11 +17,37 @@ func NewRegistry() *Registry {
 }
 
 func (r *Registry) Register(d Driver) {
+	r.mu.Lock()
 	r.drivers[d.Name()] = d
+	r.mu.Unlock()
+}
+
+// RegisterNew adds d unless a backend with its name is already registered.
+func (r *Registry) RegisterNew(d Driver) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.drivers[d.Name()]; ok {
+		return false
+	}
+	r.drivers[d.Name()] = d
+	return true
+}
+
+// Unregister removes the backend name and returns its driver.
+func (r *Registry) Unregister(name string) (Driver, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.drivers[name]
+	if ok {
+		delete(r.drivers, name)
+	}
+	return d, ok
 }
 
 func (r *Registry) Get(name string) (Driver, error) {
+	r.mu.RLock()
 	d, ok := r.drivers[name]
+	r.mu.RUnlock()
 	if !ok {
 		return nil, fmt.Errorf("backend %q is not registered", name)
 	}
@@ -25,6 +55,8 @@ func (r *Registry) Get(name string) (Driver, error) {
 }
 
 func (r *Registry) All() []Driver {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	out := make([]Driver, 0, len(r.drivers))
 	for _, d := range r.drivers {
 		out = append(out, d)
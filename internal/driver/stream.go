@@ -0,0 +1,35 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrRunCancelled ends a stream whose run context the bridge cancelled,
+	// either by a cancel request or by the run timeout.
+	ErrRunCancelled = errors.New("run cancelled by bridge")
+	// ErrAdapterDisconnected ends a stream the adapter connection dropped.
+	ErrAdapterDisconnected = errors.New("adapter stream disconnected")
+)
+
+// StreamError classifies the error that ended a run's event stream for the
+// Stream's Done channel. The result wraps ErrRunCancelled and the context's
+// cause when ctx is done, or ErrAdapterDisconnected for transport failures.
+func StreamError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w: %w", ErrRunCancelled, context.Cause(ctx))
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Canceled, codes.Aborted:
+		return fmt.Errorf("%w: %w", ErrAdapterDisconnected, err)
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package envprofile
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type Profile struct {
+	Env         map[string]string `json:"env,omitempty"`
+	PathPrepend []string          `json:"path_prepend,omitempty"`
+	ShellInit   string            `json:"shell_init,omitempty"`
+}
+
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,127}$`)
+
+func (p Profile) IsZero() bool {
+	return len(p.Env) == 0 && len(p.PathPrepend) == 0 && strings.TrimSpace(p.ShellInit) == ""
+}
+
+func (p Profile) Validate() error {
+	for k := range p.Env {
+		if !envKeyPattern.MatchString(k) {
+			return fmt.Errorf("invalid env key %q", k)
+		}
+		if strings.EqualFold(k, "PATH") {
+			return fmt.Errorf("PATH must be set via path_prepend")
+		}
+	}
+	for _, dir := range p.PathPrepend {
+		dir = strings.TrimSpace(dir)
+		if dir == "" || strings.ContainsRune(dir, os.PathListSeparator) {
+			return fmt.Errorf("invalid path_prepend entry %q", dir)
+		}
+		if !filepath.IsAbs(dir) && !strings.HasPrefix(dir, "~/") {
+			return fmt.Errorf("path_prepend entry %q must be absolute", dir)
+		}
+	}
+	if len(p.ShellInit) > 4096 {
+		return fmt.Errorf("shell_init exceeds 4096 bytes")
+	}
+	return nil
+}
+
+// Environ merges the profile onto base (typically os.Environ()).
+func Environ(base []string, p Profile) []string {
+	vars := map[string]string{}
+	order := make([]string, 0, len(base)+len(p.Env))
+	for _, kv := range base {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		if _, seen := vars[k]; !seen {
+			order = append(order, k)
+		}
+		vars[k] = v
+	}
+	extra := make([]string, 0, len(p.Env))
+	for k := range p.Env {
+		extra = append(extra, k)
+	}
+	sort.Strings(extra)
+	for _, k := range extra {
+		if _, seen := vars[k]; !seen {
+			order = append(order, k)
+		}
+		vars[k] = p.Env[k]
+	}
+	if len(p.PathPrepend) > 0 {
+		home, _ := os.UserHomeDir()
+		dirs := make([]string, 0, len(p.PathPrepend)+1)
+		for _, dir := range p.PathPrepend {
+			dir = strings.TrimSpace(dir)
+			if strings.HasPrefix(dir, "~/") && home != "" {
+				dir = filepath.Join(home, dir[2:])
+			}
+			dirs = append(dirs, dir)
+		}
+		if cur := vars["PATH"]; cur != "" {
+			dirs = append(dirs, cur)
+		}
+		if _, seen := vars["PATH"]; !seen {
+			order = append(order, "PATH")
+		}
+		vars["PATH"] = strings.Join(dirs, string(os.PathListSeparator))
+	}
+	out := make([]string, 0, len(order))
+	for _, k := range order {
+		out = append(out, k+"="+vars[k])
+	}
+	return out
+}
+
+// Command builds the process for bin/args with the profile applied. When the
+// profile carries a shell init snippet (nvm, pyenv, asdf ...), the binary is
+// exec'd from a login shell after the snippet has run.
+func Command(ctx context.Context, bin string, args []string, p Profile) *exec.Cmd {
+	var cmd *exec.Cmd
+	if init := strings.TrimSpace(p.ShellInit); init != "" {
+		shellArgs := append([]string{"-lc", init + "\nexec \"$0\" \"$@\"", bin}, args...)
+		cmd = exec.CommandContext(ctx, "/bin/bash", shellArgs...)
+	} else {
+		cmd = exec.CommandContext(ctx, bin, args...)
+	}
+	if !p.IsZero() {
+		cmd.Env = Environ(os.Environ(), p)
+	}
+	return cmd
+}
@@ -0,0 +1,67 @@
+package envprofile
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestEnvironOverridesAndPrependsPath(t *testing.T) {
+	base := []string{"HOME=/home/dev", "PATH=/usr/bin", "FOO=old"}
+	got := Environ(base, Profile{
+		Env:         map[string]string{"FOO": "new", "NODE_ENV": "development"},
+		PathPrepend: []string{"/opt/node/bin"},
+	})
+	want := map[string]string{
+		"HOME":     "/home/dev",
+		"FOO":      "new",
+		"NODE_ENV": "development",
+		"PATH":     "/opt/node/bin" + string(os.PathListSeparator) + "/usr/bin",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected env length: %#v", got)
+	}
+	for _, kv := range got {
+		k, v, _ := strings.Cut(kv, "=")
+		if want[k] != v {
+			t.Fatalf("unexpected %s=%q, want %q", k, v, want[k])
+		}
+	}
+}
+
+func TestValidateRejectsBadProfiles(t *testing.T) {
+	cases := []Profile{
+		{Env: map[string]string{"BAD KEY": "x"}},
+		{Env: map[string]string{"PATH": "/tmp"}},
+		{PathPrepend: []string{"relative/bin"}},
+	}
+	for _, p := range cases {
+		if err := p.Validate(); err == nil {
+			t.Fatalf("expected validation error for %#v", p)
+		}
+	}
+	if err := (Profile{Env: map[string]string{"GOFLAGS": "-mod=mod"}, PathPrepend: []string{"~/.local/bin"}}).Validate(); err != nil {
+		t.Fatalf("expected valid profile, got %v", err)
+	}
+}
+
+func TestCommandRunsShellInitBeforeExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell init requires bash")
+	}
+	if _, err := os.Stat("/bin/bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	cmd := Command(context.Background(), "sh", []string{"-c", "printf '%s' \"$ELIX_PROFILE_MARK\""}, Profile{
+		ShellInit: "export ELIX_PROFILE_MARK=from-init",
+	})
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("run command: %v", err)
+	}
+	if string(out) != "from-init" {
+		t.Fatalf("expected init snippet to run first, got %q", string(out))
+	}
+}
@@ -0,0 +1,86 @@
+package events
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DefaultEvictAfter is how long a subscriber may stay saturated, dropping
+// every event offered to it, before it is evicted.
+const DefaultEvictAfter = 30 * time.Second
+
+// Backlog tracks the delivery state of one live subscriber: the seq range
+// it missed since its last gap marker and since when it is saturated.
+// Callers serialize access with their own lock.
+type Backlog struct {
+	Dropped int64
+
+	gapFrom        int64
+	gapTo          int64
+	gapDropped     int64
+	saturatedSince time.Time
+}
+
+// Drop records that the event with seq was not delivered and reports whether
+// the subscriber has been saturated for at least evictAfter.
+func (b *Backlog) Drop(seq int64, now time.Time, evictAfter time.Duration) bool {
+	b.Dropped++
+	b.gapDropped++
+	if b.gapFrom == 0 || (seq > 0 && seq < b.gapFrom) {
+		b.gapFrom = seq
+	}
+	if seq > b.gapTo {
+		b.gapTo = seq
+	}
+	if b.saturatedSince.IsZero() {
+		b.saturatedSince = now
+	}
+	return evictAfter > 0 && now.Sub(b.saturatedSince) >= evictAfter
+}
+
+// Delivered clears the saturation clock after a successful send.
+func (b *Backlog) Delivered() {
+	b.saturatedSince = time.Time{}
+}
+
+// PendingGap returns the payload of the TypeGap marker owed to the
+// subscriber, if any. It matches GapPayload.
+func (b *Backlog) PendingGap() (map[string]any, bool) {
+	if b.gapDropped == 0 {
+		return nil, false
+	}
+	return map[string]any{
+		"from_seq": b.gapFrom,
+		"to_seq":   b.gapTo,
+		"dropped":  b.gapDropped,
+	}, true
+}
+
+// ClearGap forgets the pending gap once its marker was delivered.
+func (b *Backlog) ClearGap() {
+	b.gapFrom, b.gapTo, b.gapDropped = 0, 0, 0
+}
+
+// BackpressureStats reports the drops, gap markers and evictions of a fan-out.
+type BackpressureStats struct {
+	Subscribers int64 `json:"subscribers"`
+	Dropped     int64 `json:"dropped"`
+	GapMarkers  int64 `json:"gap_markers"`
+	Evicted     int64 `json:"evicted"`
+}
+
+// BackpressureCounters are the atomic totals behind BackpressureStats.
+type BackpressureCounters struct {
+	Dropped    atomic.Int64
+	GapMarkers atomic.Int64
+	Evicted    atomic.Int64
+}
+
+func (c *BackpressureCounters) Stats(subscribers int) BackpressureStats {
+	return BackpressureStats{
+		Subscribers: int64(subscribers),
+		Dropped:     c.Dropped.Load(),
+		GapMarkers:  c.GapMarkers.Load(),
+		Evicted:     c.Evicted.Load(),
+	}
+}
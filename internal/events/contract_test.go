@@ -47,10 +47,60 @@ func TestValidateEventRejectsInvalidEnum(t *testing.T) {
 	}
 }
 
+func TestV3PayloadValidation(t *testing.T) {
+	ev := NewEvent(DonePayload{
+		Status: "completed",
+		Usage:  &TokenUsage{InputTokens: 10, OutputTokens: 4, TotalTokens: 14},
+	})
+	ev.RunID = "r1"
+	ev.Seq = 1
+	ev.TS = time.Now().UTC()
+	ev.SchemaVersion = SchemaVersionV3
+	ev.Backend = "codex"
+	NormalizeEvent(&ev)
+	if err := ValidateEvent(ev); err != nil {
+		t.Fatalf("expected valid v3 done event, got err=%v", err)
+	}
+	done, err := DecodePayload[DonePayload](ev.Payload)
+	if err != nil || done.Usage == nil || done.Usage.TotalTokens != 14 {
+		t.Fatalf("unexpected decoded payload: %#v err=%v", done, err)
+	}
+
+	ev.Payload = map[string]any{"status": "completed", "tokens": 14}
+	if err := ValidateEvent(ev); err == nil {
+		t.Fatalf("expected unknown payload key to be rejected under v3")
+	}
+	ev.Payload = map[string]any{"message": "finished"}
+	if err := ValidateEvent(ev); err == nil {
+		t.Fatalf("expected missing status to be rejected under v3")
+	}
+	ev.SchemaVersion = SchemaVersionV2
+	if err := ValidateEvent(ev); err != nil {
+		t.Fatalf("expected free-form payload under v2, got err=%v", err)
+	}
+
+	if err := ValidatePayload(TypePatch, map[string]any{"diff": 1}); err == nil {
+		t.Fatalf("expected mistyped diff to be rejected")
+	}
+	if err := ValidatePayload(TypeToolResult, map[string]any{"call_id": "c1", "output": "ok", "exit_code": 0}); err != nil {
+		t.Fatalf("expected valid tool_result payload, got err=%v", err)
+	}
+	var b Backlog
+	b.Drop(3, time.Now(), 0)
+	b.Drop(5, time.Now(), 0)
+	gap, _ := b.PendingGap()
+	if err := ValidatePayload(TypeGap, gap); err != nil {
+		t.Fatalf("expected gap marker payload to be valid, got err=%v", err)
+	}
+	if err := ValidatePayload(TypeGap, map[string]any{"from_seq": 5, "to_seq": 3, "dropped": 1}); err == nil {
+		t.Fatalf("expected inverted gap range to be rejected")
+	}
+}
+
 func TestSchemaEnumsMatchCode(t *testing.T) {
 	_, thisFile, _, _ := runtime.Caller(0)
 	root := filepath.Clean(filepath.Join(filepath.Dir(thisFile), "..", ".."))
-	schemaPath := filepath.Join(root, "schema", "event.v2.schema.json")
+	schemaPath := filepath.Join(root, "schema", "event.v3.schema.json")
 	data, err := os.ReadFile(schemaPath)
 	if err != nil {
 		t.Fatalf("read schema: %v", err)
@@ -10,11 +10,15 @@ const (
 	TypeStatus     = "status"
 	TypeDone       = "done"
 	TypeError      = "error"
+	// TypeGap is the live-only marker for events a subscriber missed.
+	TypeGap = "gap"
 )
 
 const (
 	SchemaVersionV1 = "v1"
 	SchemaVersionV2 = "v2"
+	// SchemaVersionV3 adds strict typed payloads, see payload.go.
+	SchemaVersionV3 = "v3"
 )
 
 type CompatFields struct {
@@ -0,0 +1,224 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Typed payloads define the payload of each event type. Schema v3 events
+// must match them exactly; v1 and v2 payloads stay free-form.
+
+type TokenPayload struct {
+	Text    string `json:"text"`
+	Flushed bool   `json:"flushed,omitempty"`
+}
+
+type ToolCallPayload struct {
+	CallID    string         `json:"call_id,omitempty"`
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+}
+
+type ToolResultPayload struct {
+	CallID   string `json:"call_id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Output   string `json:"output"`
+	IsError  bool   `json:"is_error,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+}
+
+type PatchPayload struct {
+	Diff  string   `json:"diff"`
+	Files []string `json:"files,omitempty"`
+}
+
+type StatusPayload struct {
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Adapter string `json:"adapter,omitempty"`
+	Message string `json:"message,omitempty"`
+	// IdleSeconds, CancelAt and AdapterHealth are set on reason=stalled
+	// warnings.
+	IdleSeconds   int64          `json:"idle_seconds,omitempty"`
+	CancelAt      *time.Time     `json:"cancel_at,omitempty"`
+	AdapterHealth *AdapterHealth `json:"adapter_health,omitempty"`
+}
+
+type AdapterHealth struct {
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+type TokenUsage struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+	TotalTokens  int64 `json:"total_tokens"`
+}
+
+type DonePayload struct {
+	Status     string      `json:"status"`
+	ReasonCode string      `json:"reason_code,omitempty"`
+	Message    string      `json:"message,omitempty"`
+	Usage      *TokenUsage `json:"usage,omitempty"`
+}
+
+type ErrorPayload struct {
+	Message string `json:"message"`
+	Code    string `json:"code,omitempty"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// GapPayload is the marker a live subscriber gets once it catches up after
+// its buffer overflowed: it missed FromSeq..ToSeq, Dropped events in all.
+type GapPayload struct {
+	FromSeq int64 `json:"from_seq"`
+	ToSeq   int64 `json:"to_seq"`
+	Dropped int64 `json:"dropped"`
+}
+
+// TypedPayload is implemented by the payload structs above.
+type TypedPayload interface {
+	EventType() string
+	validate() error
+}
+
+func (TokenPayload) EventType() string      { return TypeToken }
+func (ToolCallPayload) EventType() string   { return TypeToolCall }
+func (ToolResultPayload) EventType() string { return TypeToolResult }
+func (PatchPayload) EventType() string      { return TypePatch }
+func (StatusPayload) EventType() string     { return TypeStatus }
+func (DonePayload) EventType() string       { return TypeDone }
+func (ErrorPayload) EventType() string      { return TypeError }
+func (GapPayload) EventType() string        { return TypeGap }
+
+func (p TokenPayload) validate() error {
+	if p.Text == "" {
+		return fmt.Errorf("text is required")
+	}
+	return nil
+}
+
+func (p ToolCallPayload) validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	return nil
+}
+
+func (p ToolResultPayload) validate() error {
+	if p.CallID == "" && p.Name == "" {
+		return fmt.Errorf("call_id or name is required")
+	}
+	return nil
+}
+
+func (p PatchPayload) validate() error {
+	if p.Diff == "" {
+		return fmt.Errorf("diff is required")
+	}
+	return nil
+}
+
+func (p StatusPayload) validate() error {
+	if p.Status == "" {
+		return fmt.Errorf("status is required")
+	}
+	return nil
+}
+
+func (p DonePayload) validate() error {
+	if p.Status == "" {
+		return fmt.Errorf("status is required")
+	}
+	return nil
+}
+
+func (p ErrorPayload) validate() error {
+	if p.Message == "" {
+		return fmt.Errorf("message is required")
+	}
+	return nil
+}
+
+func (p GapPayload) validate() error {
+	if p.Dropped <= 0 {
+		return fmt.Errorf("dropped must be > 0")
+	}
+	if p.FromSeq > p.ToSeq {
+		return fmt.Errorf("from_seq must not exceed to_seq")
+	}
+	return nil
+}
+
+// NewEvent returns an event of the payload's type carrying it as a map.
+// Callers fill in run, seq and presentation fields as before.
+func NewEvent(p TypedPayload) Event {
+	return Event{Type: p.EventType(), Payload: PayloadMap(p)}
+}
+
+// PayloadMap converts a typed payload to the map stored on Event.
+func PayloadMap(p TypedPayload) map[string]any {
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return nil
+	}
+	var out map[string]any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil
+	}
+	return out
+}
+
+// DecodePayload reads an event payload into its typed form, rejecting
+// unknown keys.
+func DecodePayload[T TypedPayload](payload map[string]any) (T, error) {
+	var out T
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return out, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&out); err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
+// ValidatePayload checks payload strictly against the typed payload of
+// eventType.
+func ValidatePayload(eventType string, payload map[string]any) error {
+	var (
+		p   TypedPayload
+		err error
+	)
+	switch eventType {
+	case TypeToken:
+		p, err = DecodePayload[TokenPayload](payload)
+	case TypeToolCall:
+		p, err = DecodePayload[ToolCallPayload](payload)
+	case TypeToolResult:
+		p, err = DecodePayload[ToolResultPayload](payload)
+	case TypePatch:
+		p, err = DecodePayload[PatchPayload](payload)
+	case TypeStatus:
+		p, err = DecodePayload[StatusPayload](payload)
+	case TypeDone:
+		p, err = DecodePayload[DonePayload](payload)
+	case TypeError:
+		p, err = DecodePayload[ErrorPayload](payload)
+	case TypeGap:
+		p, err = DecodePayload[GapPayload](payload)
+	default:
+		return fmt.Errorf("invalid type: %s", eventType)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s payload: %w", eventType, err)
+	}
+	if err := p.validate(); err != nil {
+		return fmt.Errorf("invalid %s payload: %w", eventType, err)
+	}
+	return nil
+}
@@ -28,6 +28,7 @@ var allowedTypes = map[string]struct{}{
 	TypeStatus:     {},
 	TypeDone:       {},
 	TypeError:      {},
+	TypeGap:        {},
 }
 
 var allowedChannels = map[string]struct{}{
@@ -51,6 +52,7 @@ var allowedRoles = map[string]struct{}{
 var allowedSchemaVersions = map[string]struct{}{
 	SchemaVersionV1: {},
 	SchemaVersionV2: {},
+	SchemaVersionV3: {},
 }
 
 func AllowedTypes() []string {
@@ -62,6 +64,7 @@ func AllowedTypes() []string {
 		TypeStatus,
 		TypeDone,
 		TypeError,
+		TypeGap,
 	}
 }
 
@@ -93,6 +96,7 @@ func AllowedSchemaVersions() []string {
 	return []string{
 		SchemaVersionV1,
 		SchemaVersionV2,
+		SchemaVersionV3,
 	}
 }
 
@@ -170,6 +174,9 @@ func ValidateEvent(e Event) error {
 	if _, ok := allowedRoles[e.Role]; !ok {
 		return fmt.Errorf("invalid role: %s", e.Role)
 	}
+	if e.SchemaVersion == SchemaVersionV3 {
+		return ValidatePayload(e.Type, e.Payload)
+	}
 	return nil
 }
 
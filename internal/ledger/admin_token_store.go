@@ -0,0 +1,201 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	ErrAdminTokenNotFound = errors.New("admin token not found")
+	ErrAdminTokenExists   = errors.New("an active admin token with this name exists")
+	ErrAdminTokenInactive = errors.New("admin token is revoked, rotated or expired")
+)
+
+// AdminTokenRecord is a named static operator token. Rotation inserts a new
+// record and retires the old one through ReplacedBy and ExpiresAt; records
+// are never deleted, so a retired token hash stays known and rejected.
+type AdminTokenRecord struct {
+	ID         string
+	Name       string
+	TokenHash  string
+	Scopes     []string
+	CreatedBy  string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	RevokedAt  time.Time
+	ReplacedBy string
+}
+
+// Active reports whether the token is usable at now. A rotated token stays
+// usable until its grace period, recorded as ExpiresAt, ends.
+func (r AdminTokenRecord) Active(now time.Time) bool {
+	if !r.RevokedAt.IsZero() {
+		return false
+	}
+	if !r.ExpiresAt.IsZero() && !now.Before(r.ExpiresAt) {
+		return false
+	}
+	return r.ReplacedBy == "" || !r.ExpiresAt.IsZero()
+}
+
+const adminTokenColumns = `id, name, token_hash, scopes_json, created_by, created_at, expires_at, revoked_at, replaced_by`
+
+// CreateAdminToken stores rec unless another token with the same name is
+// still active.
+func (s *Store) CreateAdminToken(ctx context.Context, rec AdminTokenRecord, now time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.QueryContext(ctx, `SELECT `+adminTokenColumns+` FROM admin_tokens WHERE name=?`, rec.Name)
+	if err != nil {
+		return err
+	}
+	existing, err := scanAdminTokens(rows)
+	if err != nil {
+		return err
+	}
+	for _, other := range existing {
+		if other.Active(now) {
+			return ErrAdminTokenExists
+		}
+	}
+	if err := insertAdminToken(ctx, tx, rec); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetAdminToken looks a token up by id.
+func (s *Store) GetAdminToken(ctx context.Context, id string) (AdminTokenRecord, error) {
+	return s.readAdminToken(ctx, `id=?`, id)
+}
+
+// GetAdminTokenByHash looks a token up by the hash of its secret, whether or
+// not it is still active.
+func (s *Store) GetAdminTokenByHash(ctx context.Context, tokenHash string) (AdminTokenRecord, error) {
+	return s.readAdminToken(ctx, `token_hash=?`, tokenHash)
+}
+
+// ListAdminTokens returns every admin token, newest first.
+func (s *Store) ListAdminTokens(ctx context.Context) ([]AdminTokenRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+adminTokenColumns+` FROM admin_tokens ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	return scanAdminTokens(rows)
+}
+
+// RotateAdminToken inserts next as the successor of the active token id,
+// which stays usable until retireAt.
+func (s *Store) RotateAdminToken(ctx context.Context, id string, next AdminTokenRecord, retireAt, now time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	old, err := readAdminTokenTx(ctx, tx, `id=?`, id)
+	if err != nil {
+		return err
+	}
+	if !old.Active(now) {
+		return ErrAdminTokenInactive
+	}
+	if !old.ExpiresAt.IsZero() && old.ExpiresAt.Before(retireAt) {
+		retireAt = old.ExpiresAt
+	}
+	if _, err := tx.ExecContext(
+		ctx,
+		`UPDATE admin_tokens SET replaced_by=?, expires_at=? WHERE id=?`,
+		next.ID, formatTime(retireAt), id,
+	); err != nil {
+		return err
+	}
+	if err := insertAdminToken(ctx, tx, next); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RevokeAdminToken disables a token immediately.
+func (s *Store) RevokeAdminToken(ctx context.Context, id string, now time.Time) error {
+	res, err := s.db.ExecContext(
+		ctx,
+		`UPDATE admin_tokens SET revoked_at=? WHERE id=? AND revoked_at=''`,
+		formatTime(now), id,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		if _, err := s.GetAdminToken(ctx, id); err != nil {
+			return err
+		}
+		return ErrAdminTokenInactive
+	}
+	return nil
+}
+
+func insertAdminToken(ctx context.Context, tx *sqlTx, rec AdminTokenRecord) error {
+	scopeJSON, _ := json.Marshal(rec.Scopes)
+	_, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO admin_tokens(`+adminTokenColumns+`)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rec.ID,
+		rec.Name,
+		rec.TokenHash,
+		string(scopeJSON),
+		rec.CreatedBy,
+		formatTime(rec.CreatedAt),
+		formatTime(rec.ExpiresAt),
+		formatTime(rec.RevokedAt),
+		rec.ReplacedBy,
+	)
+	return err
+}
+
+func (s *Store) readAdminToken(ctx context.Context, where string, value any) (AdminTokenRecord, error) {
+	return readAdminTokenTx(ctx, s.db, where, value)
+}
+
+func readAdminTokenTx(ctx context.Context, tx rowQuerier, where string, value any) (AdminTokenRecord, error) {
+	row := tx.QueryRowContext(ctx, `SELECT `+adminTokenColumns+` FROM admin_tokens WHERE `+where, value)
+	rec, err := scanAdminToken(row.Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return AdminTokenRecord{}, ErrAdminTokenNotFound
+	}
+	return rec, err
+}
+
+func scanAdminTokens(rows *sql.Rows) ([]AdminTokenRecord, error) {
+	defer rows.Close()
+	out := []AdminTokenRecord{}
+	for rows.Next() {
+		rec, err := scanAdminToken(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+func scanAdminToken(scan func(dest ...any) error) (AdminTokenRecord, error) {
+	var rec AdminTokenRecord
+	var scopesJSON, createdAt, expiresAt, revokedAt string
+	if err := scan(&rec.ID, &rec.Name, &rec.TokenHash, &scopesJSON, &rec.CreatedBy, &createdAt, &expiresAt, &revokedAt, &rec.ReplacedBy); err != nil {
+		return AdminTokenRecord{}, err
+	}
+	rec.Scopes = decodeStringArray(scopesJSON)
+	rec.CreatedAt = parseTime(createdAt)
+	rec.ExpiresAt = parseTime(expiresAt)
+	rec.RevokedAt = parseTime(revokedAt)
+	return rec, nil
+}
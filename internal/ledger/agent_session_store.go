@@ -0,0 +1,144 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrAgentSessionNotFound = errors.New("session not found")
+
+// AgentSessionRecord is the persisted metadata of an interactive session,
+// enough to relaunch its app-server and resume the thread after a restart.
+type AgentSessionRecord struct {
+	ID            string
+	Backend       string
+	WorkspaceID   string
+	WorkspacePath string
+	ThreadID      string
+	Status        string
+	Error         string
+	// CreatedBy is the device address or token that created the session.
+	CreatedBy string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (s *Store) initAgentSessionSchema(ctx context.Context) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS agent_sessions (
+  session_id TEXT PRIMARY KEY,
+  backend TEXT NOT NULL,
+  workspace_id TEXT NOT NULL DEFAULT '',
+  workspace_path TEXT NOT NULL,
+  thread_id TEXT NOT NULL DEFAULT '',
+  status TEXT NOT NULL,
+  error_text TEXT NOT NULL DEFAULT '',
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_agent_sessions_updated_at ON agent_sessions(updated_at);`
+	if _, err := s.db.ExecContext(ctx, s.db.d.ddl(schema)); err != nil {
+		return err
+	}
+	return s.ensureColumn(ctx, "agent_sessions", "created_by", "TEXT")
+}
+
+func (s *Store) UpsertAgentSession(ctx context.Context, rec AgentSessionRecord) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO agent_sessions(session_id, backend, workspace_id, workspace_path, thread_id, status, error_text, created_by, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(session_id) DO UPDATE SET
+		   thread_id=excluded.thread_id,
+		   status=excluded.status,
+		   error_text=excluded.error_text,
+		   updated_at=excluded.updated_at`,
+		rec.ID, rec.Backend, rec.WorkspaceID, rec.WorkspacePath, rec.ThreadID, rec.Status, rec.Error, rec.CreatedBy,
+		rec.CreatedAt.UTC().Format(time.RFC3339Nano), rec.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	return err
+}
+
+func (s *Store) GetAgentSession(ctx context.Context, sessionID string) (AgentSessionRecord, error) {
+	row := s.db.QueryRowContext(
+		ctx,
+		`SELECT session_id, backend, workspace_id, workspace_path, thread_id, status, error_text, created_by, created_at, updated_at
+		   FROM agent_sessions WHERE session_id=?`,
+		sessionID,
+	)
+	rec, err := scanAgentSession(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return AgentSessionRecord{}, ErrAgentSessionNotFound
+	}
+	return rec, err
+}
+
+// ListAgentSessionsUpdatedSince returns sessions last updated at or after since,
+// oldest first.
+func (s *Store) ListAgentSessionsUpdatedSince(ctx context.Context, since time.Time) ([]AgentSessionRecord, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT session_id, backend, workspace_id, workspace_path, thread_id, status, error_text, created_by, created_at, updated_at
+		   FROM agent_sessions WHERE updated_at >= ? ORDER BY created_at ASC`,
+		since.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []AgentSessionRecord{}
+	for rows.Next() {
+		rec, err := scanAgentSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// DeleteAgentSessionsBefore removes sessions in one of statuses last updated
+// before cutoff and returns how many were removed.
+func (s *Store) DeleteAgentSessionsBefore(ctx context.Context, cutoff time.Time, statuses []string) (int64, error) {
+	if len(statuses) == 0 {
+		return 0, nil
+	}
+	args := []any{cutoff.UTC().Format(time.RFC3339Nano)}
+	placeholders := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		placeholders = append(placeholders, "?")
+		args = append(args, status)
+	}
+	res, err := s.db.ExecContext(
+		ctx,
+		`DELETE FROM agent_sessions WHERE updated_at < ? AND status IN (`+strings.Join(placeholders, ", ")+`)`,
+		args...,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *Store) DeleteAgentSession(ctx context.Context, sessionID string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM agent_sessions WHERE session_id=?`, sessionID)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAgentSession(row rowScanner) (AgentSessionRecord, error) {
+	var rec AgentSessionRecord
+	var createdAt, updatedAt string
+	if err := row.Scan(&rec.ID, &rec.Backend, &rec.WorkspaceID, &rec.WorkspacePath, &rec.ThreadID, &rec.Status, &rec.Error, &rec.CreatedBy, &createdAt, &updatedAt); err != nil {
+		return AgentSessionRecord{}, err
+	}
+	rec.CreatedAt = parseTime(createdAt)
+	rec.UpdatedAt = parseTime(updatedAt)
+	return rec, nil
+}
@@ -0,0 +1,65 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type RunOutcomeRecord struct {
+	RunID        string
+	WorkspaceID  string
+	Backend      string
+	Model        string
+	Status       string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	HasUsage     bool
+	InputTokens  int64
+	OutputTokens int64
+	TotalTokens  int64
+	// FirstEventMS is -1 when no first event latency was recorded.
+	FirstEventMS int64
+}
+
+func (s *Store) ListRunOutcomes(ctx context.Context, from, to time.Time) ([]RunOutcomeRecord, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT r.run_id, r.workspace_id, r.backend, r.context_json, r.status, r.created_at, r.updated_at,
+		        COALESCE(f.latency_ms, -1), u.run_id, COALESCE(u.input_tokens, 0), COALESCE(u.output_tokens, 0), COALESCE(u.total_tokens, 0)
+		   FROM runs r
+		   LEFT JOIN run_usage u ON u.run_id = r.run_id
+		   LEFT JOIN run_first_events f ON f.run_id = r.run_id
+		  WHERE r.created_at >= ? AND r.created_at < ?
+		  ORDER BY r.created_at ASC`,
+		from.UTC().Format(time.RFC3339Nano),
+		to.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []RunOutcomeRecord{}
+	for rows.Next() {
+		var rec RunOutcomeRecord
+		var ctxJSON, createdAt, updatedAt string
+		var usageRunID sql.NullString
+		if err := rows.Scan(
+			&rec.RunID, &rec.WorkspaceID, &rec.Backend, &ctxJSON, &rec.Status, &createdAt, &updatedAt,
+			&rec.FirstEventMS, &usageRunID, &rec.InputTokens, &rec.OutputTokens, &rec.TotalTokens,
+		); err != nil {
+			return nil, err
+		}
+		var persisted persistedContext
+		if err := json.Unmarshal([]byte(ctxJSON), &persisted); err == nil {
+			rec.Model = persisted.Options.Model
+		}
+		rec.CreatedAt = parseTime(createdAt)
+		rec.UpdatedAt = parseTime(updatedAt)
+		rec.HasUsage = usageRunID.Valid
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
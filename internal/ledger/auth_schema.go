@@ -45,8 +45,55 @@ CREATE TABLE IF NOT EXISTS sessions (
 );
 CREATE INDEX IF NOT EXISTS idx_sessions_access_hash ON sessions(access_hash);
 CREATE INDEX IF NOT EXISTS idx_sessions_refresh_hash ON sessions(refresh_hash);
-CREATE INDEX IF NOT EXISTS idx_sessions_address ON sessions(address);`
+CREATE INDEX IF NOT EXISTS idx_sessions_address ON sessions(address);
 
-	_, err := s.db.ExecContext(ctx, schema)
-	return err
+CREATE TABLE IF NOT EXISTS admin_tokens (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL,
+  token_hash TEXT NOT NULL UNIQUE,
+  scopes_json TEXT NOT NULL DEFAULT '[]',
+  created_by TEXT NOT NULL DEFAULT '',
+  created_at TEXT NOT NULL,
+  expires_at TEXT NOT NULL DEFAULT '',
+  revoked_at TEXT NOT NULL DEFAULT '',
+  replaced_by TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_admin_tokens_name ON admin_tokens(name);
+
+CREATE TABLE IF NOT EXISTS scope_requests (
+  id TEXT PRIMARY KEY,
+  address TEXT NOT NULL,
+  scopes_json TEXT NOT NULL DEFAULT '[]',
+  reason TEXT NOT NULL DEFAULT '',
+  status TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  decided_at TEXT NOT NULL DEFAULT '',
+  decided_by TEXT NOT NULL DEFAULT '',
+  decide_note TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_scope_requests_address ON scope_requests(address, status);
+
+CREATE TABLE IF NOT EXISTS bridge_identity (
+  id TEXT PRIMARY KEY,
+  private_key TEXT NOT NULL,
+  created_at TEXT NOT NULL
+);`
+
+	if _, err := s.db.ExecContext(ctx, s.db.d.ddl(schema)); err != nil {
+		return err
+	}
+	// Device trust tier, also carried by the pair code that grants it.
+	for _, table := range []string{"devices", "pair_codes"} {
+		if err := s.ensureColumn(ctx, table, "trust_level", "TEXT"); err != nil {
+			return err
+		}
+	}
+	// Last client seen per session, the latest suspicious IP change and when
+	// the session was last used.
+	for _, col := range []string{"last_ip", "last_user_agent", "last_ip_at", "ip_alert_from", "ip_alert_to", "ip_alert_at", "rotated_at", "last_used_at"} {
+		if err := s.ensureColumn(ctx, "sessions", col, "TEXT"); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -14,6 +14,9 @@ var (
 	ErrSessionInvalid  = errors.New("session invalid or expired")
 	ErrDeviceNotFound  = errors.New("device not found")
 	ErrDeviceRevoked   = errors.New("device revoked")
+	// ErrAuthSessionNotFound is an unknown or already revoked session of a
+	// device.
+	ErrAuthSessionNotFound = errors.New("auth session not found")
 )
 
 type PairCodeRecord struct {
@@ -25,6 +28,7 @@ type PairCodeRecord struct {
 	ExpiresAt   time.Time
 	Used        bool
 	UsedAt      time.Time
+	TrustLevel  string
 }
 
 type DeviceRecord struct {
@@ -37,6 +41,7 @@ type DeviceRecord struct {
 	Revoked      bool
 	RevokedAt    time.Time
 	RevokeReason string
+	TrustLevel   string
 }
 
 type SessionRecord struct {
@@ -50,14 +55,21 @@ type SessionRecord struct {
 	RefreshExpiresAt time.Time
 	Revoked          bool
 	RevokedAt        time.Time
+	LastIP           string
+	LastUserAgent    string
+	LastIPAt         time.Time
+	IPAlertFrom      string
+	IPAlertTo        string
+	IPAlertAt        time.Time
+	LastUsedAt       time.Time
 }
 
 func (s *Store) CreatePairCode(ctx context.Context, rec PairCodeRecord) error {
 	permJSON, _ := json.Marshal(rec.Permissions)
 	_, err := s.db.ExecContext(
 		ctx,
-		`INSERT INTO pair_codes(code, challenge, permissions_json, created_by, created_at, expires_at, used, used_at)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		`INSERT INTO pair_codes(`+pairCodeColumns+`)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		rec.Code,
 		rec.Challenge,
 		string(permJSON),
@@ -66,6 +78,7 @@ func (s *Store) CreatePairCode(ctx context.Context, rec PairCodeRecord) error {
 		rec.ExpiresAt.UTC().Format(time.RFC3339Nano),
 		boolToInt(rec.Used),
 		formatTime(rec.UsedAt),
+		rec.TrustLevel,
 	)
 	return err
 }
@@ -104,6 +117,45 @@ func (s *Store) ConsumePairCode(ctx context.Context, code string, now time.Time)
 	return rec, nil
 }
 
+func (s *Store) GetPairCode(ctx context.Context, code string) (PairCodeRecord, error) {
+	return readPairCodeTx(ctx, s.db, code)
+}
+
+// ListPairCodes returns every stored pair code, oldest first.
+func (s *Store) ListPairCodes(ctx context.Context) ([]PairCodeRecord, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT `+pairCodeColumns+` FROM pair_codes ORDER BY created_at ASC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []PairCodeRecord{}
+	for rows.Next() {
+		rec, err := scanPairCode(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) DeletePairCodes(ctx context.Context, codes []string) (int64, error) {
+	var deleted int64
+	for _, code := range codes {
+		res, err := s.db.ExecContext(ctx, `DELETE FROM pair_codes WHERE code=?`, code)
+		if err != nil {
+			return deleted, err
+		}
+		n, _ := res.RowsAffected()
+		deleted += n
+	}
+	return deleted, nil
+}
+
 func (s *Store) UpsertDevice(ctx context.Context, rec DeviceRecord) (DeviceRecord, error) {
 	now := rec.LastSeenAt
 	if now.IsZero() {
@@ -130,14 +182,15 @@ func (s *Store) UpsertDevice(ctx context.Context, rec DeviceRecord) (DeviceRecor
 	if errors.Is(err, ErrDeviceNotFound) {
 		_, err := tx.ExecContext(
 			ctx,
-			`INSERT INTO devices(address, public_key, name, permissions_json, created_at, last_seen_at, revoked, revoked_at, revoke_reason)
-			 VALUES (?, ?, ?, ?, ?, ?, 0, '', '')`,
+			`INSERT INTO devices(address, public_key, name, permissions_json, created_at, last_seen_at, revoked, revoked_at, revoke_reason, trust_level)
+			 VALUES (?, ?, ?, ?, ?, ?, 0, '', '', ?)`,
 			rec.Address,
 			rec.PublicKey,
 			rec.Name,
 			string(permJSON),
 			rec.CreatedAt.UTC().Format(time.RFC3339Nano),
 			now.UTC().Format(time.RFC3339Nano),
+			rec.TrustLevel,
 		)
 		if err != nil {
 			return DeviceRecord{}, err
@@ -157,13 +210,18 @@ func (s *Store) UpsertDevice(ctx context.Context, rec DeviceRecord) (DeviceRecor
 		if len(rec.Permissions) > 0 {
 			perms = rec.Permissions
 		}
+		trust := existing.TrustLevel
+		if rec.TrustLevel != "" {
+			trust = rec.TrustLevel
+		}
 		permsJSON, _ := json.Marshal(perms)
 		_, err := tx.ExecContext(
 			ctx,
-			`UPDATE devices SET name=?, permissions_json=?, last_seen_at=? WHERE address=?`,
+			`UPDATE devices SET name=?, permissions_json=?, last_seen_at=?, trust_level=? WHERE address=?`,
 			name,
 			string(permsJSON),
 			now.UTC().Format(time.RFC3339Nano),
+			trust,
 			rec.Address,
 		)
 		if err != nil {
@@ -194,7 +252,7 @@ func (s *Store) TouchDevice(ctx context.Context, address string, ts time.Time) e
 func (s *Store) ListDevices(ctx context.Context) ([]DeviceRecord, error) {
 	rows, err := s.db.QueryContext(
 		ctx,
-		`SELECT address, public_key, name, permissions_json, created_at, last_seen_at, revoked, revoked_at, revoke_reason
+		`SELECT address, public_key, name, permissions_json, created_at, last_seen_at, revoked, revoked_at, revoke_reason, trust_level
 		 FROM devices ORDER BY created_at ASC`,
 	)
 	if err != nil {
@@ -216,7 +274,7 @@ func (s *Store) ListDevices(ctx context.Context) ([]DeviceRecord, error) {
 func (s *Store) GetDevice(ctx context.Context, address string) (DeviceRecord, error) {
 	row := s.db.QueryRowContext(
 		ctx,
-		`SELECT address, public_key, name, permissions_json, created_at, last_seen_at, revoked, revoked_at, revoke_reason
+		`SELECT address, public_key, name, permissions_json, created_at, last_seen_at, revoked, revoked_at, revoke_reason, trust_level
 		 FROM devices WHERE address=?`,
 		address,
 	)
@@ -307,16 +365,17 @@ func (s *Store) GetSessionByRefreshHash(ctx context.Context, refreshHash string,
 	return sess, dev, nil
 }
 
-func (s *Store) RotateSession(ctx context.Context, sessionID, accessHash, refreshHash string, expiresAt, refreshExpiresAt time.Time) error {
+func (s *Store) RotateSession(ctx context.Context, sessionID, accessHash, refreshHash string, expiresAt, refreshExpiresAt, now time.Time) error {
 	res, err := s.db.ExecContext(
 		ctx,
 		`UPDATE sessions
-		   SET access_hash=?, refresh_hash=?, expires_at=?, refresh_expires_at=?, revoked=0, revoked_at=''
+		   SET access_hash=?, refresh_hash=?, expires_at=?, refresh_expires_at=?, revoked=0, revoked_at='', rotated_at=?
 		 WHERE session_id=?`,
 		accessHash,
 		refreshHash,
 		expiresAt.UTC().Format(time.RFC3339Nano),
 		refreshExpiresAt.UTC().Format(time.RFC3339Nano),
+		formatTime(now),
 		sessionID,
 	)
 	if err != nil {
@@ -339,12 +398,140 @@ func (s *Store) RevokeSessionsByAddress(ctx context.Context, address string, now
 	return err
 }
 
+// RevokeSession revokes one session of address.
+func (s *Store) RevokeSession(ctx context.Context, address, sessionID string, now time.Time) error {
+	res, err := s.db.ExecContext(
+		ctx,
+		`UPDATE sessions SET revoked=1, revoked_at=? WHERE session_id=? AND address=? AND revoked=0`,
+		now.UTC().Format(time.RFC3339Nano),
+		sessionID,
+		address,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrAuthSessionNotFound
+	}
+	return nil
+}
+
+// TouchSession records that a session was just used.
+func (s *Store) TouchSession(ctx context.Context, sessionID string, ts time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE sessions SET last_used_at=? WHERE session_id=?`, formatTime(ts), sessionID)
+	return err
+}
+
+// SessionChange is a revoked or rotated session, as seen by verifiers of
+// self-contained access tokens. AccessHash is the hash of the only access
+// token of a rotated session that is still valid.
+type SessionChange struct {
+	SessionID  string
+	AccessHash string
+	Revoked    bool
+	RevokedAt  time.Time
+	RotatedAt  time.Time
+}
+
+// ListSessionChanges returns sessions revoked or rotated at or after since.
+func (s *Store) ListSessionChanges(ctx context.Context, since time.Time) ([]SessionChange, error) {
+	// RFC3339Nano stamps do not sort as strings within a second, so the
+	// query compares against the second's prefix and the exact bound is
+	// applied below.
+	sinceSecond := since.UTC().Format("2006-01-02T15:04:05")
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT session_id, access_hash, revoked, revoked_at, rotated_at FROM sessions
+		  WHERE (revoked=1 AND revoked_at>=?) OR (revoked=0 AND rotated_at>=?)`,
+		sinceSecond, sinceSecond,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []SessionChange
+	for rows.Next() {
+		var rec SessionChange
+		var revoked int
+		var revokedAt, rotatedAt string
+		if err := rows.Scan(&rec.SessionID, &rec.AccessHash, &revoked, &revokedAt, &rotatedAt); err != nil {
+			return nil, err
+		}
+		rec.Revoked = revoked == 1
+		rec.RevokedAt = parseTime(revokedAt)
+		rec.RotatedAt = parseTime(rotatedAt)
+		if rec.Revoked && !rec.RevokedAt.Before(since) || !rec.Revoked && !rec.RotatedAt.Before(since) {
+			out = append(out, rec)
+		}
+	}
+	return out, rows.Err()
+}
+
+// RecordSessionClient stores the client a session was last used from.
+func (s *Store) RecordSessionClient(ctx context.Context, sessionID, ip, userAgent string, at time.Time) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`UPDATE sessions SET last_ip=?, last_user_agent=?, last_ip_at=? WHERE session_id=?`,
+		ip, userAgent, formatTime(at), sessionID,
+	)
+	return err
+}
+
+// FlagSessionIPChange records a suspicious change of client IP on a session.
+func (s *Store) FlagSessionIPChange(ctx context.Context, sessionID, from, to string, at time.Time) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`UPDATE sessions SET ip_alert_from=?, ip_alert_to=?, ip_alert_at=? WHERE session_id=?`,
+		from, to, formatTime(at), sessionID,
+	)
+	return err
+}
+
+// ListActiveSessions returns sessions that are not revoked and can still be
+// refreshed at now.
+func (s *Store) ListActiveSessions(ctx context.Context, now time.Time) ([]SessionRecord, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT session_id, address, created_at, refresh_expires_at,
+		        last_ip, last_user_agent, last_ip_at, ip_alert_from, ip_alert_to, ip_alert_at, last_used_at
+		   FROM sessions
+		  WHERE revoked=0
+		  ORDER BY created_at ASC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []SessionRecord{}
+	for rows.Next() {
+		var rec SessionRecord
+		var createdAt, refreshExpiresAt, lastIPAt, ipAlertAt, lastUsedAt string
+		if err := rows.Scan(
+			&rec.SessionID, &rec.Address, &createdAt, &refreshExpiresAt,
+			&rec.LastIP, &rec.LastUserAgent, &lastIPAt, &rec.IPAlertFrom, &rec.IPAlertTo, &ipAlertAt, &lastUsedAt,
+		); err != nil {
+			return nil, err
+		}
+		rec.CreatedAt = parseTime(createdAt)
+		rec.RefreshExpiresAt = parseTime(refreshExpiresAt)
+		if !now.Before(rec.RefreshExpiresAt) {
+			continue
+		}
+		rec.LastIPAt = parseTime(lastIPAt)
+		rec.IPAlertAt = parseTime(ipAlertAt)
+		rec.LastUsedAt = parseTime(lastUsedAt)
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
 func (s *Store) readSessionAndDevice(ctx context.Context, whereClause string, value any) (SessionRecord, DeviceRecord, error) {
 	row := s.db.QueryRowContext(
 		ctx,
 		fmt.Sprintf(
 			`SELECT s.session_id, s.access_hash, s.refresh_hash, s.address, s.scopes_json, s.created_at, s.expires_at, s.refresh_expires_at, s.revoked, s.revoked_at,
-			        d.address, d.public_key, d.name, d.permissions_json, d.created_at, d.last_seen_at, d.revoked, d.revoked_at, d.revoke_reason
+			        s.last_ip, s.last_user_agent, s.last_ip_at, s.ip_alert_from, s.ip_alert_to, s.ip_alert_at,
+			        d.address, d.public_key, d.name, d.permissions_json, d.created_at, d.last_seen_at, d.revoked, d.revoked_at, d.revoke_reason, d.trust_level
 			   FROM sessions s
 			   JOIN devices d ON d.address = s.address
 			  WHERE %s`,
@@ -356,6 +543,7 @@ func (s *Store) readSessionAndDevice(ctx context.Context, whereClause string, va
 	var sess SessionRecord
 	var scopesJSON string
 	var sessCreated, sessExpires, sessRefreshExpires, sessRevokedAt string
+	var lastIPAt, ipAlertAt string
 
 	var dev DeviceRecord
 	var permsJSON string
@@ -365,7 +553,8 @@ func (s *Store) readSessionAndDevice(ctx context.Context, whereClause string, va
 	var devRevokedInt int
 	if err := row.Scan(
 		&sess.SessionID, &sess.AccessHash, &sess.RefreshHash, &sess.Address, &scopesJSON, &sessCreated, &sessExpires, &sessRefreshExpires, &sessRevokedInt, &sessRevokedAt,
-		&dev.Address, &dev.PublicKey, &dev.Name, &permsJSON, &devCreated, &devLastSeen, &devRevokedInt, &devRevokedAt, &dev.RevokeReason,
+		&sess.LastIP, &sess.LastUserAgent, &lastIPAt, &sess.IPAlertFrom, &sess.IPAlertTo, &ipAlertAt,
+		&dev.Address, &dev.PublicKey, &dev.Name, &permsJSON, &devCreated, &devLastSeen, &devRevokedInt, &devRevokedAt, &dev.RevokeReason, &dev.TrustLevel,
 	); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return SessionRecord{}, DeviceRecord{}, ErrSessionInvalid
@@ -378,6 +567,8 @@ func (s *Store) readSessionAndDevice(ctx context.Context, whereClause string, va
 	sess.RefreshExpiresAt, _ = time.Parse(time.RFC3339Nano, sessRefreshExpires)
 	sess.Revoked = sessRevokedInt == 1
 	sess.RevokedAt = parseTime(sessRevokedAt)
+	sess.LastIPAt = parseTime(lastIPAt)
+	sess.IPAlertAt = parseTime(ipAlertAt)
 
 	dev.Permissions = decodeStringArray(permsJSON)
 	dev.CreatedAt, _ = time.Parse(time.RFC3339Nano, devCreated)
@@ -388,21 +579,32 @@ func (s *Store) readSessionAndDevice(ctx context.Context, whereClause string, va
 	return sess, dev, nil
 }
 
-func readPairCodeTx(ctx context.Context, tx *sql.Tx, code string) (PairCodeRecord, error) {
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+const pairCodeColumns = `code, challenge, permissions_json, created_by, created_at, expires_at, used, used_at, trust_level`
+
+func readPairCodeTx(ctx context.Context, tx rowQuerier, code string) (PairCodeRecord, error) {
 	row := tx.QueryRowContext(
 		ctx,
-		`SELECT code, challenge, permissions_json, created_by, created_at, expires_at, used, used_at
+		`SELECT `+pairCodeColumns+`
 		 FROM pair_codes WHERE code=?`,
 		code,
 	)
+	rec, err := scanPairCode(row.Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return PairCodeRecord{}, ErrPairCodeInvalid
+	}
+	return rec, err
+}
+
+func scanPairCode(scan func(dest ...any) error) (PairCodeRecord, error) {
 	var rec PairCodeRecord
 	var permsJSON string
 	var createdAt, expiresAt, usedAt string
 	var usedInt int
-	if err := row.Scan(&rec.Code, &rec.Challenge, &permsJSON, &rec.CreatedBy, &createdAt, &expiresAt, &usedInt, &usedAt); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return PairCodeRecord{}, ErrPairCodeInvalid
-		}
+	if err := scan(&rec.Code, &rec.Challenge, &permsJSON, &rec.CreatedBy, &createdAt, &expiresAt, &usedInt, &usedAt, &rec.TrustLevel); err != nil {
 		return PairCodeRecord{}, err
 	}
 	rec.Permissions = decodeStringArray(permsJSON)
@@ -413,10 +615,10 @@ func readPairCodeTx(ctx context.Context, tx *sql.Tx, code string) (PairCodeRecor
 	return rec, nil
 }
 
-func readDeviceTx(ctx context.Context, tx *sql.Tx, address string) (DeviceRecord, error) {
+func readDeviceTx(ctx context.Context, tx rowQuerier, address string) (DeviceRecord, error) {
 	row := tx.QueryRowContext(
 		ctx,
-		`SELECT address, public_key, name, permissions_json, created_at, last_seen_at, revoked, revoked_at, revoke_reason
+		`SELECT address, public_key, name, permissions_json, created_at, last_seen_at, revoked, revoked_at, revoke_reason, trust_level
 		 FROM devices WHERE address=?`,
 		address,
 	)
@@ -436,7 +638,7 @@ func scanDevice(rows *sql.Rows) (DeviceRecord, error) {
 	var createdAt, lastSeenAt, revokedAt string
 	var revokedInt int
 	if err := rows.Scan(
-		&rec.Address, &rec.PublicKey, &rec.Name, &permsJSON, &createdAt, &lastSeenAt, &revokedInt, &revokedAt, &rec.RevokeReason,
+		&rec.Address, &rec.PublicKey, &rec.Name, &permsJSON, &createdAt, &lastSeenAt, &revokedInt, &revokedAt, &rec.RevokeReason, &rec.TrustLevel,
 	); err != nil {
 		return DeviceRecord{}, err
 	}
@@ -454,7 +656,7 @@ func scanDeviceRow(row *sql.Row) (DeviceRecord, error) {
 	var createdAt, lastSeenAt, revokedAt string
 	var revokedInt int
 	if err := row.Scan(
-		&rec.Address, &rec.PublicKey, &rec.Name, &permsJSON, &createdAt, &lastSeenAt, &revokedInt, &revokedAt, &rec.RevokeReason,
+		&rec.Address, &rec.PublicKey, &rec.Name, &permsJSON, &createdAt, &lastSeenAt, &revokedInt, &revokedAt, &rec.RevokeReason, &rec.TrustLevel,
 	); err != nil {
 		return DeviceRecord{}, err
 	}
@@ -501,3 +703,22 @@ func boolToInt(v bool) int {
 	}
 	return 0
 }
+
+// EnsureBridgeIdentity stores privateKey as the bridge identity unless one
+// already exists, and returns the stored key with its creation time. Bridges
+// sharing a database therefore share one identity.
+func (s *Store) EnsureBridgeIdentity(ctx context.Context, privateKey string, now time.Time) (string, time.Time, error) {
+	if _, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO bridge_identity(id, private_key, created_at) VALUES ('default', ?, ?)
+		 ON CONFLICT(id) DO NOTHING`,
+		privateKey, now.UTC().Format(time.RFC3339Nano),
+	); err != nil {
+		return "", time.Time{}, err
+	}
+	var key, createdAt string
+	if err := s.db.QueryRowContext(ctx, `SELECT private_key, created_at FROM bridge_identity WHERE id='default'`).Scan(&key, &createdAt); err != nil {
+		return "", time.Time{}, err
+	}
+	return key, parseTime(createdAt), nil
+}
@@ -84,7 +84,7 @@ func TestSessionLookupAndRotate(t *testing.T) {
 		t.Fatalf("unexpected session/device: %#v %#v", sess, dev)
 	}
 
-	if err := store.RotateSession(context.Background(), "s1", "a2", "r2", now.Add(2*time.Minute), now.Add(20*time.Minute)); err != nil {
+	if err := store.RotateSession(context.Background(), "s1", "a2", "r2", now.Add(2*time.Minute), now.Add(20*time.Minute), now); err != nil {
 		t.Fatalf("rotate session: %v", err)
 	}
 	if _, _, err := store.GetSessionByAccessHash(context.Background(), "a1", now); err == nil {
@@ -131,3 +131,51 @@ func TestRevokeDeviceRevokesSessions(t *testing.T) {
 		t.Fatalf("expected revoked session invalid")
 	}
 }
+
+func TestListSessionChangesFiltersBySince(t *testing.T) {
+	store := newAuthStore(t)
+	ctx := context.Background()
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if _, err := store.UpsertDevice(ctx, DeviceRecord{Address: "elix1chg", PublicKey: "pub", Name: "dev", CreatedAt: base, LastSeenAt: base}); err != nil {
+		t.Fatalf("upsert device: %v", err)
+	}
+	for _, id := range []string{"old", "rotated", "revoked", "untouched"} {
+		if err := store.CreateSession(ctx, SessionRecord{
+			SessionID:        id,
+			AccessHash:       "a-" + id,
+			RefreshHash:      "r-" + id,
+			Address:          "elix1chg",
+			CreatedAt:        base,
+			ExpiresAt:        base.Add(time.Hour),
+			RefreshExpiresAt: base.Add(time.Hour),
+		}); err != nil {
+			t.Fatalf("create session: %v", err)
+		}
+	}
+	if err := store.RotateSession(ctx, "old", "a2-old", "r2-old", base.Add(time.Hour), base.Add(time.Hour), base.Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	// Later than since within the same second, which a plain string
+	// comparison of the stored stamps would miss.
+	if err := store.RotateSession(ctx, "rotated", "a2-rotated", "r2-rotated", base.Add(time.Hour), base.Add(time.Hour), base.Add(500*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeSession(ctx, "elix1chg", "revoked", base.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := store.ListSessionChanges(ctx, base.Add(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("list changes: %v", err)
+	}
+	got := map[string]SessionChange{}
+	for _, ch := range changes {
+		got[ch.SessionID] = ch
+	}
+	if len(got) != 2 || got["rotated"].AccessHash != "a2-rotated" || !got["revoked"].Revoked {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+	if changes, _ := store.ListSessionChanges(ctx, base.Add(600*time.Millisecond)); len(changes) != 1 || changes[0].SessionID != "revoked" {
+		t.Fatalf("expected only the revocation after the rotation, got %+v", changes)
+	}
+}
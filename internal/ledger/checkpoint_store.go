@@ -0,0 +1,75 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+// RunCheckpointRecord is the git state of a workspace taken before a run
+// started. SnapshotCommit holds the work tree, untracked files included, on
+// top of HeadCommit.
+type RunCheckpointRecord struct {
+	RunID          string
+	WorkspacePath  string
+	HeadCommit     string
+	SnapshotCommit string
+	CreatedAt      time.Time
+	RestoredAt     time.Time
+}
+
+func (s *Store) initCheckpointSchema(ctx context.Context) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS run_checkpoints (
+  run_id TEXT PRIMARY KEY,
+  workspace_path TEXT NOT NULL,
+  head_commit TEXT NOT NULL,
+  snapshot_commit TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  restored_at TEXT NOT NULL DEFAULT ''
+);`
+	_, err := s.db.ExecContext(ctx, s.db.d.ddl(schema))
+	return err
+}
+
+func (s *Store) CreateRunCheckpoint(ctx context.Context, rec RunCheckpointRecord) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO run_checkpoints(run_id, workspace_path, head_commit, snapshot_commit, created_at)
+		 VALUES (?, ?, ?, ?, ?)`,
+		rec.RunID,
+		rec.WorkspacePath,
+		rec.HeadCommit,
+		rec.SnapshotCommit,
+		formatTime(rec.CreatedAt),
+	)
+	return err
+}
+
+func (s *Store) GetRunCheckpoint(ctx context.Context, runID string) (RunCheckpointRecord, error) {
+	var rec RunCheckpointRecord
+	var createdAt, restoredAt string
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT run_id, workspace_path, head_commit, snapshot_commit, created_at, restored_at
+		 FROM run_checkpoints WHERE run_id=?`,
+		runID,
+	).Scan(&rec.RunID, &rec.WorkspacePath, &rec.HeadCommit, &rec.SnapshotCommit, &createdAt, &restoredAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return RunCheckpointRecord{}, ErrCheckpointNotFound
+	}
+	if err != nil {
+		return RunCheckpointRecord{}, err
+	}
+	rec.CreatedAt = parseTime(createdAt)
+	rec.RestoredAt = parseTime(restoredAt)
+	return rec, nil
+}
+
+func (s *Store) MarkRunCheckpointRestored(ctx context.Context, runID string, at time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE run_checkpoints SET restored_at=? WHERE run_id=?`, formatTime(at), runID)
+	return err
+}
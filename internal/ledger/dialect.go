@@ -0,0 +1,177 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// dialect hides the SQL differences between ledger backends. Queries are
+// written with SQLite syntax and "?" placeholders; each dialect rewrites
+// them for its driver.
+type dialect interface {
+	name() string
+	driverName() string
+	// rebind rewrites "?" placeholders for the driver.
+	rebind(query string) string
+	// ddl rewrites SQLite column types in CREATE statements.
+	ddl(schema string) string
+	// jsonText returns an expression reading path (dot separated, without
+	// the leading "$.") from the JSON text column col.
+	jsonText(col, path string) string
+	addColumn(ctx context.Context, db *sqlDB, table, name, typ string) error
+	isUniqueViolation(err error) bool
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) name() string               { return "sqlite" }
+func (sqliteDialect) driverName() string         { return "sqlite" }
+func (sqliteDialect) rebind(query string) string { return query }
+func (sqliteDialect) ddl(schema string) string   { return schema }
+func (sqliteDialect) jsonText(col, path string) string {
+	return fmt.Sprintf("json_extract(%s, '$.%s')", col, path)
+}
+
+func (sqliteDialect) addColumn(ctx context.Context, db *sqlDB, table, name, typ string) error {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	has := false
+	for rows.Next() {
+		var cid int
+		var colName string
+		var colType string
+		var notNull int
+		var dflt sql.NullString
+		var pk int
+		if err := rows.Scan(&cid, &colName, &colType, &notNull, &dflt, &pk); err != nil {
+			return err
+		}
+		if colName == name {
+			has = true
+		}
+	}
+	if has {
+		return nil
+	}
+	_, err = db.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s NOT NULL DEFAULT ''`, table, name, typ))
+	return err
+}
+
+func (sqliteDialect) isUniqueViolation(err error) bool {
+	return strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) name() string       { return "postgres" }
+func (postgresDialect) driverName() string { return "pgx" }
+
+func (postgresDialect) rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+	n := 0
+	inQuote := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'':
+			inQuote = !inQuote
+			b.WriteByte(c)
+		case c == '?' && !inQuote:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+var postgresTypes = strings.NewReplacer(
+	"INTEGER PRIMARY KEY AUTOINCREMENT", "BIGSERIAL PRIMARY KEY",
+	"INTEGER", "BIGINT",
+	"REAL", "DOUBLE PRECISION",
+	"BLOB", "BYTEA",
+)
+
+func (postgresDialect) ddl(schema string) string { return postgresTypes.Replace(schema) }
+
+func (postgresDialect) jsonText(col, path string) string {
+	return fmt.Sprintf("(%s::jsonb #>> '{%s}')", col, strings.ReplaceAll(path, ".", ","))
+}
+
+func (d postgresDialect) addColumn(ctx context.Context, db *sqlDB, table, name, typ string) error {
+	_, err := db.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s NOT NULL DEFAULT ''`, table, name, d.ddl(typ)))
+	return err
+}
+
+func (postgresDialect) isUniqueViolation(err error) bool {
+	return strings.Contains(err.Error(), "SQLSTATE 23505")
+}
+
+// dialectFor picks the backend for a ledger DSN: postgres:// and
+// postgresql:// URLs use Postgres, anything else is a SQLite path.
+func dialectFor(dsn string) dialect {
+	lower := strings.ToLower(strings.TrimSpace(dsn))
+	if strings.HasPrefix(lower, "postgres://") || strings.HasPrefix(lower, "postgresql://") {
+		return postgresDialect{}
+	}
+	return sqliteDialect{}
+}
+
+// sqlDB and sqlTx rebind queries for the store's dialect so store methods
+// can keep a single SQLite-flavoured query text.
+type sqlDB struct {
+	*sql.DB
+	d dialect
+}
+
+func (db *sqlDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return db.DB.ExecContext(ctx, db.d.rebind(query), args...)
+}
+
+func (db *sqlDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return db.DB.QueryContext(ctx, db.d.rebind(query), args...)
+}
+
+func (db *sqlDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return db.DB.QueryRowContext(ctx, db.d.rebind(query), args...)
+}
+
+func (db *sqlDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sqlTx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{Tx: tx, d: db.d}, nil
+}
+
+type sqlTx struct {
+	*sql.Tx
+	d dialect
+}
+
+func (tx *sqlTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return tx.Tx.ExecContext(ctx, tx.d.rebind(query), args...)
+}
+
+func (tx *sqlTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return tx.Tx.QueryContext(ctx, tx.d.rebind(query), args...)
+}
+
+func (tx *sqlTx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return tx.Tx.QueryRowContext(ctx, tx.d.rebind(query), args...)
+}
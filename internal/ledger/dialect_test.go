@@ -0,0 +1,96 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"echohelix/internal/events"
+)
+
+func TestPostgresDialectRewritesQueries(t *testing.T) {
+	if _, ok := dialectFor("postgresql://u@h/db").(postgresDialect); !ok {
+		t.Fatalf("expected postgres dialect for postgresql:// dsn")
+	}
+	if _, ok := dialectFor("/var/lib/elix/bridge.db").(sqliteDialect); !ok {
+		t.Fatalf("expected sqlite dialect for file path")
+	}
+
+	d := postgresDialect{}
+	got := d.rebind(`SELECT a FROM t WHERE b=? AND c='?' AND d IN (?, ?)`)
+	if want := `SELECT a FROM t WHERE b=$1 AND c='?' AND d IN ($2, $3)`; got != want {
+		t.Fatalf("rebind:\n got %s\nwant %s", got, want)
+	}
+	ddl := d.ddl(`id INTEGER PRIMARY KEY AUTOINCREMENT, n INTEGER NOT NULL, f REAL`)
+	if want := `id BIGSERIAL PRIMARY KEY, n BIGINT NOT NULL, f DOUBLE PRECISION`; ddl != want {
+		t.Fatalf("ddl:\n got %s\nwant %s", ddl, want)
+	}
+	if got := d.jsonText("r.context_json", "options.Model"); got != `(r.context_json::jsonb #>> '{options,Model}')` {
+		t.Fatalf("jsonText: %s", got)
+	}
+	if !d.isUniqueViolation(errors.New(`ERROR: duplicate key value violates unique constraint "events_run_id_seq_key" (SQLSTATE 23505)`)) {
+		t.Fatalf("expected unique violation to be detected")
+	}
+}
+
+// TestPostgresStoreRoundTrip runs against a real server when
+// ELIX_TEST_POSTGRES_DSN points at a disposable database.
+func TestPostgresStoreRoundTrip(t *testing.T) {
+	dsn := os.Getenv("ELIX_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("ELIX_TEST_POSTGRES_DSN not set")
+	}
+	ctx := context.Background()
+	store, err := Open(dsn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer store.Close()
+	if store.Backend() != "postgres" {
+		t.Fatalf("expected postgres backend, got %s", store.Backend())
+	}
+	if err := store.Init(ctx); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	if err := store.Init(ctx); err != nil {
+		t.Fatalf("second init: %v", err)
+	}
+
+	runID := "pg-" + strings.ReplaceAll(time.Now().UTC().Format("150405.000000000"), ".", "")
+	now := time.Now().UTC()
+	if err := store.CreateRun(ctx, RunRecord{
+		ID: runID, WorkspaceID: "ws", Workspace: "/tmp", Backend: "codex", Prompt: "hi",
+		Options: RunOptionsRecord{Model: "m1"}, SubmittedBy: "dev-1", Status: "completed", CreatedAt: now, UpdatedAt: now,
+	}); err != nil {
+		t.Fatalf("create run: %v", err)
+	}
+	ev := events.Event{RunID: runID, Seq: 1, TS: now, Type: events.TypeToken, Payload: map[string]any{"text": "x"}, Backend: "codex", Source: "test"}
+	if err := store.AppendEvent(ctx, ev); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err := store.AppendEvent(ctx, ev); !errors.Is(err, ErrDuplicateSeq) {
+		t.Fatalf("expected duplicate seq error, got %v", err)
+	}
+	if next, err := store.NextSeq(ctx, runID); err != nil || next != 2 {
+		t.Fatalf("next seq=%d err=%v", next, err)
+	}
+	if err := store.UpsertTokenUsage(ctx, TokenUsageRecord{RunID: runID, Backend: "codex", SubmittedBy: "dev-1", TotalTokens: 7, RecordedAt: now}); err != nil {
+		t.Fatalf("usage: %v", err)
+	}
+	aggs, err := store.AggregateTokenUsageByModel(ctx, now.Add(-time.Minute), now.Add(time.Minute), "codex")
+	if err != nil {
+		t.Fatalf("aggregate: %v", err)
+	}
+	found := false
+	for _, agg := range aggs {
+		if agg.Model == "m1" && agg.TotalTokens >= 7 {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected model aggregate, got %+v", aggs)
+	}
+}
@@ -0,0 +1,99 @@
+package ledger
+
+import (
+	"context"
+	"time"
+)
+
+// DigestRecord is one principal's activity digest for a UTC day
+// (YYYY-MM-DD). Payload is the digest JSON as delivered.
+type DigestRecord struct {
+	Day       string
+	Principal string
+	Payload   string
+	CreatedAt time.Time
+}
+
+type DigestQuery struct {
+	// Principal filters to one principal when set.
+	Principal string
+	// FromDay and ToDay bound the day inclusively when set.
+	FromDay string
+	ToDay   string
+	Limit   int
+}
+
+func (s *Store) initDigestSchema(ctx context.Context) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS daily_digests (
+  day TEXT NOT NULL,
+  principal TEXT NOT NULL,
+  payload_json TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  PRIMARY KEY(day, principal)
+);`
+	_, err := s.db.ExecContext(ctx, s.db.d.ddl(schema))
+	return err
+}
+
+// SaveDigest stores a digest, replacing an earlier one for the same day and
+// principal.
+func (s *Store) SaveDigest(ctx context.Context, rec DigestRecord) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO daily_digests(day, principal, payload_json, created_at)
+		 VALUES (?, ?, ?, ?)
+		 ON CONFLICT(day, principal) DO UPDATE SET
+		   payload_json=excluded.payload_json,
+		   created_at=excluded.created_at`,
+		rec.Day, rec.Principal, rec.Payload, formatTime(rec.CreatedAt),
+	)
+	return err
+}
+
+// HasDigestDay reports whether digests were generated for day.
+func (s *Store) HasDigestDay(ctx context.Context, day string) (bool, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM daily_digests WHERE day=?`, day).Scan(&n)
+	return n > 0, err
+}
+
+// ListDigests returns digests newest day first.
+func (s *Store) ListDigests(ctx context.Context, q DigestQuery) ([]DigestRecord, error) {
+	query := `SELECT day, principal, payload_json, created_at FROM daily_digests WHERE 1=1`
+	var args []any
+	if q.Principal != "" {
+		query += ` AND principal=?`
+		args = append(args, q.Principal)
+	}
+	if q.FromDay != "" {
+		query += ` AND day >= ?`
+		args = append(args, q.FromDay)
+	}
+	if q.ToDay != "" {
+		query += ` AND day <= ?`
+		args = append(args, q.ToDay)
+	}
+	query += ` ORDER BY day DESC, principal ASC`
+	if q.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, q.Limit)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []DigestRecord{}
+	for rows.Next() {
+		var rec DigestRecord
+		var createdAt string
+		if err := rows.Scan(&rec.Day, &rec.Principal, &rec.Payload, &createdAt); err != nil {
+			return nil, err
+		}
+		rec.CreatedAt = parseTime(createdAt)
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
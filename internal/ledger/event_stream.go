@@ -0,0 +1,121 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"echohelix/internal/events"
+)
+
+// StreamEvents calls fn for each event of runID with seq >= fromSeq, in seq
+// order, from a single cursor. The cursor runs in a read-only transaction,
+// so it sees one snapshot: rows pruned or compacted while it runs are still
+// exported, and VACUUM waits for it instead of moving pages underneath it.
+// Memory use does not depend on the number of events.
+//
+// SQLite stores use a separate connection for the cursor so the store's own
+// single connection stays free for event writes during long exports.
+func (s *Store) StreamEvents(ctx context.Context, runID string, fromSeq int64, fn func(ev events.Event) error) error {
+	db := s.db.DB
+	opts := &sql.TxOptions{ReadOnly: true, Isolation: sql.LevelRepeatableRead}
+	if s.db.d.name() == "sqlite" {
+		opts = &sql.TxOptions{ReadOnly: true}
+		if !isMemoryDSN(s.dsn) {
+			own, err := sql.Open(s.db.d.driverName(), s.dsn)
+			if err != nil {
+				return err
+			}
+			defer own.Close()
+			own.SetMaxOpenConns(1)
+			db = own
+		}
+	}
+	tx, err := (&sqlDB{DB: db, d: s.db.d}).BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	// Read-only: nothing to commit.
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT run_id, seq, ts, schema_version, type, channel, format, role, compat_json, payload_json, backend, source
+		 FROM events WHERE run_id=? AND seq>=?
+		 ORDER BY seq ASC`,
+		runID, fromSeq,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		ev, err := scanEvent(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(ev); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func isMemoryDSN(dsn string) bool {
+	return dsn == "" || strings.Contains(dsn, ":memory:") || strings.Contains(dsn, "mode=memory")
+}
+
+// EventPageQuery selects one page of a run's events. In ascending order the
+// page starts at FromSeq; descending, it ends below BeforeSeq when that is
+// set. Offset skips events past the cursor.
+type EventPageQuery struct {
+	FromSeq   int64
+	BeforeSeq int64
+	Offset    int64
+	Limit     int64
+	Desc      bool
+}
+
+func (s *Store) ListEventsPage(ctx context.Context, runID string, q EventPageQuery) ([]events.Event, error) {
+	if q.Limit <= 0 {
+		q.Limit = 1000
+	}
+	query := `SELECT run_id, seq, ts, schema_version, type, channel, format, role, compat_json, payload_json, backend, source
+		 FROM events WHERE run_id=? AND seq>=?`
+	args := []any{runID, q.FromSeq}
+	if q.BeforeSeq > 0 {
+		query += ` AND seq<?`
+		args = append(args, q.BeforeSeq)
+	}
+	if q.Desc {
+		query += ` ORDER BY seq DESC`
+	} else {
+		query += ` ORDER BY seq ASC`
+	}
+	query += ` LIMIT ? OFFSET ?`
+	args = append(args, q.Limit, max(q.Offset, 0))
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []events.Event{}
+	for rows.Next() {
+		ev, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ev)
+	}
+	return out, rows.Err()
+}
+
+// EventStats returns how many events runID has and its highest seq.
+func (s *Store) EventStats(ctx context.Context, runID string) (count, maxSeq int64, err error) {
+	var top sql.NullInt64
+	row := s.db.QueryRowContext(ctx, `SELECT COUNT(*), MAX(seq) FROM events WHERE run_id=?`, runID)
+	if err := row.Scan(&count, &top); err != nil {
+		return 0, 0, err
+	}
+	return count, top.Int64, nil
+}
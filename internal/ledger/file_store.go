@@ -118,3 +118,104 @@ func (s *Store) ListRunAttachments(ctx context.Context, runID string) ([]RunAtta
 	}
 	return out, rows.Err()
 }
+
+// FileUsageRecord is an uploaded file with the number of runs attaching it.
+type FileUsageRecord struct {
+	FileRecord
+	RefCount int64
+}
+
+type FileUsageFilter struct {
+	CreatedBy     string
+	CreatedBefore time.Time
+	OrphanedOnly  bool
+	Limit         int
+}
+
+type FileStorageSummary struct {
+	FileCount     int64
+	TotalBytes    int64
+	OrphanedCount int64
+	OrphanedBytes int64
+}
+
+// ListFileUsage returns uploads matching f, newest first, with how many runs
+// reference each one. Orphaned files are referenced by no run.
+func (s *Store) ListFileUsage(ctx context.Context, f FileUsageFilter) ([]FileUsageRecord, error) {
+	query := `SELECT f.file_id, f.storage_key, f.original_name, f.mime_type, f.size_bytes, f.sha256, f.created_by, f.created_at,
+	                 COUNT(DISTINCT a.run_id)
+	          FROM files f
+	          LEFT JOIN run_attachments a ON a.file_id = f.file_id
+	          WHERE 1=1`
+	args := []any{}
+	if f.CreatedBy != "" {
+		query += ` AND f.created_by = ?`
+		args = append(args, f.CreatedBy)
+	}
+	if !f.CreatedBefore.IsZero() {
+		query += ` AND f.created_at < ?`
+		args = append(args, f.CreatedBefore.UTC().Format(time.RFC3339Nano))
+	}
+	query += ` GROUP BY f.file_id, f.storage_key, f.original_name, f.mime_type, f.size_bytes, f.sha256, f.created_by, f.created_at`
+	if f.OrphanedOnly {
+		query += ` HAVING COUNT(a.run_id) = 0`
+	}
+	query += ` ORDER BY f.created_at DESC, f.file_id ASC`
+	if f.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, f.Limit)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []FileUsageRecord{}
+	for rows.Next() {
+		var item FileUsageRecord
+		var ts string
+		if err := rows.Scan(
+			&item.FileID,
+			&item.StorageKey,
+			&item.OriginalName,
+			&item.MIMEType,
+			&item.SizeBytes,
+			&item.SHA256,
+			&item.CreatedBy,
+			&ts,
+			&item.RefCount,
+		); err != nil {
+			return nil, err
+		}
+		item.CreatedAt, _ = time.Parse(time.RFC3339Nano, ts)
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+// SummarizeFileStorage totals all uploads and the orphaned part of them.
+func (s *Store) SummarizeFileStorage(ctx context.Context) (FileStorageSummary, error) {
+	var out FileStorageSummary
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*), COALESCE(SUM(f.size_bytes), 0),
+		        COALESCE(SUM(CASE WHEN o.file_id IS NULL THEN 1 ELSE 0 END), 0),
+		        COALESCE(SUM(CASE WHEN o.file_id IS NULL THEN f.size_bytes ELSE 0 END), 0)
+		 FROM files f
+		 LEFT JOIN (SELECT DISTINCT file_id FROM run_attachments) o ON o.file_id = f.file_id`,
+	).Scan(&out.FileCount, &out.TotalBytes, &out.OrphanedCount, &out.OrphanedBytes)
+	return out, err
+}
+
+// SummarizeAttachments counts the attachments copied into run workspaces
+// and the bytes of the uploads behind them.
+func (s *Store) SummarizeAttachments(ctx context.Context) (count, bytes int64, err error) {
+	err = s.db.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*), COALESCE(SUM(f.size_bytes), 0)
+		 FROM run_attachments a
+		 JOIN files f ON f.file_id = a.file_id`,
+	).Scan(&count, &bytes)
+	return count, bytes, err
+}
@@ -0,0 +1,116 @@
+package ledger
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultIdempotencyWindow is how long a client request id is remembered.
+const DefaultIdempotencyWindow = 24 * time.Hour
+
+// MaxIdempotencyKeyLen bounds the Idempotency-Key header and
+// client_request_id.
+const MaxIdempotencyKeyLen = 255
+
+var (
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still in progress")
+	ErrIdempotencyKeyReused     = errors.New("idempotency key was already used for a different request")
+)
+
+// IdempotencyRecord is a claimed client request id. Result is empty until
+// the original request completes.
+type IdempotencyRecord struct {
+	Scope       string
+	Key         string
+	Fingerprint string
+	Result      string
+	CreatedAt   time.Time
+}
+
+func (s *Store) initIdempotencySchema(ctx context.Context) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  scope TEXT NOT NULL,
+  idem_key TEXT NOT NULL,
+  fingerprint TEXT NOT NULL,
+  result TEXT NOT NULL DEFAULT '',
+  created_at INTEGER NOT NULL,
+  PRIMARY KEY(scope, idem_key)
+);`
+	if _, err := s.db.ExecContext(ctx, s.db.d.ddl(schema)); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys(created_at)`)
+	return err
+}
+
+// IdempotencyFingerprint hashes a request so a replayed key can be told
+// apart from a key reused for a different request.
+func IdempotencyFingerprint(v any) string {
+	raw, _ := json.Marshal(v)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// ClaimIdempotencyKey records key under scope for the caller. It reports
+// false with the existing record when the key was claimed within window;
+// older claims are dropped first, so the key can be used again.
+func (s *Store) ClaimIdempotencyKey(ctx context.Context, scope, key, fingerprint string, now time.Time, window time.Duration) (IdempotencyRecord, bool, error) {
+	if len(key) > MaxIdempotencyKeyLen {
+		return IdempotencyRecord{}, false, fmt.Errorf("idempotency key must be at most %d characters", MaxIdempotencyKeyLen)
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE created_at < ?`, now.Add(-window).UnixMilli()); err != nil {
+		return IdempotencyRecord{}, false, err
+	}
+	res, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO idempotency_keys(scope, idem_key, fingerprint, result, created_at) VALUES (?, ?, ?, '', ?)
+		 ON CONFLICT(scope, idem_key) DO NOTHING`,
+		scope, key, fingerprint, now.UnixMilli(),
+	)
+	if err != nil {
+		return IdempotencyRecord{}, false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return IdempotencyRecord{}, false, err
+	}
+	if n > 0 {
+		return IdempotencyRecord{}, true, nil
+	}
+	rec := IdempotencyRecord{Scope: scope, Key: key}
+	var createdAt int64
+	err = s.db.QueryRowContext(
+		ctx,
+		`SELECT fingerprint, result, created_at FROM idempotency_keys WHERE scope=? AND idem_key=?`,
+		scope, key,
+	).Scan(&rec.Fingerprint, &rec.Result, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Released between the insert and the read; the caller may retry.
+		return IdempotencyRecord{}, false, ErrIdempotencyKeyInProgress
+	}
+	if err != nil {
+		return IdempotencyRecord{}, false, err
+	}
+	rec.CreatedAt = time.UnixMilli(createdAt).UTC()
+	return rec, false, nil
+}
+
+// CompleteIdempotencyKey stores the result replays of key return.
+func (s *Store) CompleteIdempotencyKey(ctx context.Context, scope, key, result string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE idempotency_keys SET result=? WHERE scope=? AND idem_key=?`, result, scope, key)
+	return err
+}
+
+// ReleaseIdempotencyKey forgets a claim whose request failed, so a retry
+// runs it again.
+func (s *Store) ReleaseIdempotencyKey(ctx context.Context, scope, key string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE scope=? AND idem_key=? AND result=''`, scope, key)
+	return err
+}
@@ -0,0 +1,68 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// LeaseRecord is a named, expiring lock row used for leader election
+// between bridges sharing one ledger.
+type LeaseRecord struct {
+	Name      string
+	Holder    string
+	ExpiresAt time.Time
+}
+
+func (s *Store) initLeaseSchema(ctx context.Context) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS leases (
+  name TEXT PRIMARY KEY,
+  holder TEXT NOT NULL,
+  expires_at INTEGER NOT NULL
+);`
+	_, err := s.db.ExecContext(ctx, s.db.d.ddl(schema))
+	return err
+}
+
+// AcquireLease takes or renews lease name for holder until now+ttl. It
+// reports false when another holder owns an unexpired lease.
+func (s *Store) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	res, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO leases(name, holder, expires_at) VALUES (?, ?, ?)
+		 ON CONFLICT(name) DO UPDATE SET holder=excluded.holder, expires_at=excluded.expires_at
+		 WHERE leases.holder=excluded.holder OR leases.expires_at < ?`,
+		name, holder, now.Add(ttl).UnixMilli(), now.UnixMilli(),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// ReleaseLease gives up lease name if holder still owns it.
+func (s *Store) ReleaseLease(ctx context.Context, name, holder string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM leases WHERE name=? AND holder=?`, name, holder)
+	return err
+}
+
+func (s *Store) GetLease(ctx context.Context, name string) (LeaseRecord, bool, error) {
+	rec := LeaseRecord{Name: name}
+	var expiresAt int64
+	err := s.db.QueryRowContext(ctx, `SELECT holder, expires_at FROM leases WHERE name=?`, name).Scan(&rec.Holder, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return LeaseRecord{}, false, nil
+	}
+	if err != nil {
+		return LeaseRecord{}, false, err
+	}
+	rec.ExpiresAt = time.UnixMilli(expiresAt).UTC()
+	return rec, true, nil
+}
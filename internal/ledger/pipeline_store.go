@@ -0,0 +1,111 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrPipelineNotFound = errors.New("pipeline not found")
+
+// PipelineRecord is a pipeline and its step states. Payload is the
+// pipeline JSON as served by the API; status and submitter are columns so
+// they can be filtered on.
+type PipelineRecord struct {
+	ID          string
+	WorkspaceID string
+	Status      string
+	SubmittedBy string
+	Payload     string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (s *Store) initPipelineSchema(ctx context.Context) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS pipelines (
+  pipeline_id TEXT PRIMARY KEY,
+  workspace_id TEXT NOT NULL DEFAULT '',
+  status TEXT NOT NULL,
+  submitted_by TEXT NOT NULL DEFAULT '',
+  payload_json TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  updated_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_pipelines_created_at ON pipelines(created_at);`
+	_, err := s.db.ExecContext(ctx, s.db.d.ddl(schema))
+	return err
+}
+
+func (s *Store) UpsertPipeline(ctx context.Context, rec PipelineRecord) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO pipelines(pipeline_id, workspace_id, status, submitted_by, payload_json, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(pipeline_id) DO UPDATE SET
+		   status=excluded.status,
+		   payload_json=excluded.payload_json,
+		   updated_at=excluded.updated_at`,
+		rec.ID, rec.WorkspaceID, rec.Status, rec.SubmittedBy, rec.Payload, formatTime(rec.CreatedAt), formatTime(rec.UpdatedAt),
+	)
+	return err
+}
+
+func (s *Store) GetPipeline(ctx context.Context, pipelineID string) (PipelineRecord, error) {
+	var rec PipelineRecord
+	var createdAt, updatedAt string
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT pipeline_id, workspace_id, status, submitted_by, payload_json, created_at, updated_at
+		 FROM pipelines WHERE pipeline_id=?`,
+		pipelineID,
+	).Scan(&rec.ID, &rec.WorkspaceID, &rec.Status, &rec.SubmittedBy, &rec.Payload, &createdAt, &updatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return PipelineRecord{}, ErrPipelineNotFound
+		}
+		return PipelineRecord{}, err
+	}
+	rec.CreatedAt = parseTime(createdAt)
+	rec.UpdatedAt = parseTime(updatedAt)
+	return rec, nil
+}
+
+// ListPipelines returns pipelines newest first, optionally only those with
+// status or submitted by submittedBy.
+func (s *Store) ListPipelines(ctx context.Context, status, submittedBy string, limit int) ([]PipelineRecord, error) {
+	query := `SELECT pipeline_id, workspace_id, status, submitted_by, payload_json, created_at, updated_at FROM pipelines WHERE 1=1`
+	var args []any
+	if status != "" {
+		query += ` AND status=?`
+		args = append(args, status)
+	}
+	if submittedBy != "" {
+		query += ` AND submitted_by=?`
+		args = append(args, submittedBy)
+	}
+	query += ` ORDER BY created_at DESC`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []PipelineRecord{}
+	for rows.Next() {
+		var rec PipelineRecord
+		var createdAt, updatedAt string
+		if err := rows.Scan(&rec.ID, &rec.WorkspaceID, &rec.Status, &rec.SubmittedBy, &rec.Payload, &createdAt, &updatedAt); err != nil {
+			return nil, err
+		}
+		rec.CreatedAt = parseTime(createdAt)
+		rec.UpdatedAt = parseTime(updatedAt)
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,143 @@
+//go:build postgres
+
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+// These tests run the SQLite-flavoured queries through the Postgres
+// dialect against a real server:
+//
+//	ELIX_TEST_POSTGRES_DSN=postgres://... go test -tags postgres ./internal/ledger
+//
+// Each test gets its own schema, dropped afterwards.
+
+func openPostgres(t *testing.T) *Store {
+	t.Helper()
+	dsn := os.Getenv("ELIX_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Fatal("ELIX_TEST_POSTGRES_DSN must point at a disposable database when testing with -tags postgres")
+	}
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = admin.Close() })
+	schema := fmt.Sprintf("elix_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() { _, _ = admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) })
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	store, err := Open(u.String())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	return store
+}
+
+func TestPostgresSchemaUsesRewrittenTypes(t *testing.T) {
+	store := openPostgres(t)
+	ctx := context.Background()
+	// Init is re-run on every start, including the added columns.
+	if err := store.Init(ctx); err != nil {
+		t.Fatalf("second init: %v", err)
+	}
+	for _, c := range []struct{ table, column, want string }{
+		{"events", "id", "bigint"},
+		{"events", "seq", "bigint"},
+		{"audit_events", "id", "bigint"},
+		{"rate_windows", "hits", "bigint"},
+		{"leases", "expires_at", "bigint"},
+		{"sessions", "rotated_at", "text"},
+		{"workspace_policies", "workspace_path", "text"},
+	} {
+		var got string
+		err := store.db.QueryRowContext(ctx,
+			`SELECT data_type FROM information_schema.columns WHERE table_schema=current_schema() AND table_name=? AND column_name=?`,
+			c.table, c.column,
+		).Scan(&got)
+		if err != nil || got != c.want {
+			t.Fatalf("%s.%s: type %q, err %v; want %s", c.table, c.column, got, err, c.want)
+		}
+	}
+	var serial sql.NullString
+	if err := store.db.QueryRowContext(ctx, `SELECT pg_get_serial_sequence('events', 'id')`).Scan(&serial); err != nil || !serial.Valid {
+		t.Fatalf("events.id is not serial: %v %v", serial, err)
+	}
+}
+
+func TestPostgresConditionalUpserts(t *testing.T) {
+	store := openPostgres(t)
+	ctx := context.Background()
+
+	// ON CONFLICT ... DO UPDATE ... WHERE: only the holder or an expired
+	// lease is taken over.
+	if ok, err := store.AcquireLease(ctx, "leader", "a", time.Minute); err != nil || !ok {
+		t.Fatalf("first acquire: %v %v", ok, err)
+	}
+	if ok, err := store.AcquireLease(ctx, "leader", "b", time.Minute); err != nil || ok {
+		t.Fatalf("acquire of a held lease: %v %v", ok, err)
+	}
+	if ok, err := store.AcquireLease(ctx, "leader", "a", -time.Minute); err != nil || !ok {
+		t.Fatalf("renew by holder: %v %v", ok, err)
+	}
+	if ok, err := store.AcquireLease(ctx, "leader", "b", time.Minute); err != nil || !ok {
+		t.Fatalf("takeover of an expired lease: %v %v", ok, err)
+	}
+
+	// "? <= 0 OR hits < ?" with a limit, then without one.
+	now := time.Now().UTC()
+	for i, want := range []bool{true, true, false} {
+		w, allowed, err := store.HitRateWindow(ctx, "ip", "1.2.3.4", now, time.Minute, 2)
+		if err != nil || allowed != want {
+			t.Fatalf("hit %d: allowed=%v err=%v window=%+v", i, allowed, err, w)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if _, allowed, err := store.HitRateWindow(ctx, "ip", "5.6.7.8", now, time.Minute, 0); err != nil || !allowed {
+			t.Fatalf("unlimited hit %d: allowed=%v err=%v", i, allowed, err)
+		}
+	}
+	// The conflict update restarts an expired window.
+	w, allowed, err := store.HitRateWindow(ctx, "ip", "1.2.3.4", now.Add(2*time.Minute), time.Minute, 2)
+	if err != nil || !allowed || w.Hits != 1 {
+		t.Fatalf("hit after expiry: allowed=%v hits=%d err=%v", allowed, w.Hits, err)
+	}
+
+	// DO NOTHING reports the existing claim.
+	if _, claimed, err := store.ClaimIdempotencyKey(ctx, "runs", "k1", "fp", now, time.Hour); err != nil || !claimed {
+		t.Fatalf("first claim: %v %v", claimed, err)
+	}
+	if rec, claimed, err := store.ClaimIdempotencyKey(ctx, "runs", "k1", "fp", now, time.Hour); err != nil || claimed || rec.Fingerprint != "fp" {
+		t.Fatalf("second claim: %+v %v %v", rec, claimed, err)
+	}
+
+	// DO UPDATE SET ... = excluded.*
+	for _, path := range []string{"/srv/a", "/srv/b"} {
+		if err := store.UpsertWorkspacePolicy(ctx, WorkspacePolicyRecord{WorkspaceID: "ws", WorkspacePath: path, RuleJSON: `{}`, UpdatedBy: "admin"}); err != nil {
+			t.Fatalf("upsert policy: %v", err)
+		}
+	}
+	if rec, err := store.GetWorkspacePolicy(ctx, "ws"); err != nil || rec.WorkspacePath != "/srv/b" {
+		t.Fatalf("policy after upsert: %+v %v", rec, err)
+	}
+}
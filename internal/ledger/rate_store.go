@@ -0,0 +1,94 @@
+package ledger
+
+import (
+	"context"
+	"time"
+)
+
+// RateWindow is a fixed-window hit counter for one client key. Rows live in
+// the ledger so rate limits survive restarts and are shared by bridges on
+// the same database; a row is dead once expires_at passes.
+type RateWindow struct {
+	Scope string
+	Key   string
+	Start time.Time
+	Hits  int
+}
+
+func (s *Store) initRateWindowSchema(ctx context.Context) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS rate_windows (
+  scope TEXT NOT NULL,
+  bucket_key TEXT NOT NULL,
+  window_start INTEGER NOT NULL,
+  expires_at INTEGER NOT NULL,
+  hits INTEGER NOT NULL,
+  PRIMARY KEY (scope, bucket_key)
+);
+CREATE INDEX IF NOT EXISTS idx_rate_windows_expires_at ON rate_windows(expires_at);`
+	_, err := s.db.ExecContext(ctx, s.db.d.ddl(schema))
+	return err
+}
+
+// HitRateWindow counts one hit for key in scope. A window that has expired
+// restarts at now. With limit > 0 a hit beyond limit is not counted and
+// allowed is false.
+func (s *Store) HitRateWindow(ctx context.Context, scope, key string, now time.Time, window time.Duration, limit int) (RateWindow, bool, error) {
+	nowMS := now.UTC().UnixMilli()
+	res, err := s.db.ExecContext(
+		ctx,
+		`UPDATE rate_windows SET hits = hits + 1
+		 WHERE scope=? AND bucket_key=? AND expires_at > ? AND (? <= 0 OR hits < ?)`,
+		scope, key, nowMS, limit, limit,
+	)
+	if err != nil {
+		return RateWindow{}, false, err
+	}
+	allowed := false
+	if n, err := res.RowsAffected(); err != nil {
+		return RateWindow{}, false, err
+	} else if n > 0 {
+		allowed = true
+	} else {
+		res, err = s.db.ExecContext(
+			ctx,
+			`INSERT INTO rate_windows(scope, bucket_key, window_start, expires_at, hits) VALUES (?, ?, ?, ?, 1)
+			 ON CONFLICT(scope, bucket_key) DO UPDATE SET
+			   window_start=excluded.window_start, expires_at=excluded.expires_at, hits=1
+			 WHERE rate_windows.expires_at <= ?`,
+			scope, key, nowMS, now.Add(window).UTC().UnixMilli(), nowMS,
+		)
+		if err != nil {
+			return RateWindow{}, false, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return RateWindow{}, false, err
+		}
+		allowed = n > 0
+		if allowed {
+			// A new window started; drop other dead rows while we are here.
+			if _, err := s.db.ExecContext(ctx, `DELETE FROM rate_windows WHERE expires_at <= ?`, nowMS); err != nil {
+				return RateWindow{}, false, err
+			}
+		}
+	}
+
+	out := RateWindow{Scope: scope, Key: key}
+	var start int64
+	if err := s.db.QueryRowContext(
+		ctx,
+		`SELECT window_start, hits FROM rate_windows WHERE scope=? AND bucket_key=?`,
+		scope, key,
+	).Scan(&start, &out.Hits); err != nil {
+		return RateWindow{}, false, err
+	}
+	out.Start = time.UnixMilli(start).UTC()
+	return out, allowed, nil
+}
+
+// ResetRateWindow forgets the hits of key in scope.
+func (s *Store) ResetRateWindow(ctx context.Context, scope, key string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM rate_windows WHERE scope=? AND bucket_key=?`, scope, key)
+	return err
+}
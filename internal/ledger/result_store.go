@@ -0,0 +1,66 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrRunResultNotFound = errors.New("run result not found")
+
+// RunResultRecord caches the final answer of a finished run so it does not
+// have to be reassembled from the event stream.
+type RunResultRecord struct {
+	RunID      string
+	FinalText  string
+	Status     string
+	Error      string
+	ComputedAt time.Time
+}
+
+func (s *Store) initRunResultSchema(ctx context.Context) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS run_results (
+  run_id TEXT PRIMARY KEY,
+  final_text TEXT NOT NULL,
+  status TEXT NOT NULL,
+  error_text TEXT NOT NULL DEFAULT '',
+  computed_at TEXT NOT NULL
+);`
+	_, err := s.db.ExecContext(ctx, s.db.d.ddl(schema))
+	return err
+}
+
+func (s *Store) UpsertRunResult(ctx context.Context, rec RunResultRecord) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO run_results(run_id, final_text, status, error_text, computed_at)
+		 VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT(run_id) DO UPDATE SET
+		   final_text=excluded.final_text,
+		   status=excluded.status,
+		   error_text=excluded.error_text,
+		   computed_at=excluded.computed_at`,
+		rec.RunID, rec.FinalText, rec.Status, rec.Error, formatTime(rec.ComputedAt),
+	)
+	return err
+}
+
+func (s *Store) GetRunResult(ctx context.Context, runID string) (RunResultRecord, error) {
+	var rec RunResultRecord
+	var computedAt string
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT run_id, final_text, status, error_text, computed_at FROM run_results WHERE run_id=?`,
+		runID,
+	).Scan(&rec.RunID, &rec.FinalText, &rec.Status, &rec.Error, &computedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return RunResultRecord{}, ErrRunResultNotFound
+	}
+	if err != nil {
+		return RunResultRecord{}, err
+	}
+	rec.ComputedAt = parseTime(computedAt)
+	return rec, nil
+}
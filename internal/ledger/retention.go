@@ -0,0 +1,167 @@
+package ledger
+
+import (
+	"context"
+	"time"
+)
+
+// RetentionPolicy bounds event history of finished runs. Zero fields are
+// disabled. Usage rows are always kept for accounting.
+type RetentionPolicy struct {
+	// MaxAge drops all events of finished runs last updated before now-MaxAge.
+	MaxAge time.Duration
+	// MaxEventsPerRun keeps only the newest MaxEventsPerRun events of each
+	// finished run.
+	MaxEventsPerRun int
+	// MaxRunAge deletes finished runs last updated before now-MaxRunAge
+	// along with their events, attachment links and checkpoints.
+	MaxRunAge time.Duration
+	// ForWorkspace, when set, returns the policy for a run's workspace in
+	// place of the fields above.
+	ForWorkspace func(workspaceID, workspacePath string) RetentionPolicy
+}
+
+func (p RetentionPolicy) Enabled() bool {
+	return p.MaxAge > 0 || p.MaxEventsPerRun > 0 || p.MaxRunAge > 0 || p.ForWorkspace != nil
+}
+
+type CompactResult struct {
+	DryRun          bool      `json:"dry_run"`
+	CheckedAt       time.Time `json:"checked_at"`
+	Cutoff          time.Time `json:"cutoff,omitempty"`
+	MaxEventsPerRun int       `json:"max_events_per_run,omitempty"`
+	// ExpiredRuns counts finished runs whose events are all past MaxAge.
+	ExpiredRuns   int   `json:"expired_runs"`
+	ExpiredEvents int64 `json:"expired_events"`
+	// TrimmedRuns counts finished runs over MaxEventsPerRun.
+	TrimmedRuns   int   `json:"trimmed_runs"`
+	TrimmedEvents int64 `json:"trimmed_events"`
+	// DeletedRuns counts finished runs past MaxRunAge, removed entirely.
+	DeletedRuns   int   `json:"deleted_runs"`
+	RemovedEvents int64 `json:"removed_events"`
+	Vacuumed      bool  `json:"vacuumed"`
+}
+
+type runEventCount struct {
+	runID         string
+	workspaceID   string
+	workspacePath string
+	updatedAt     time.Time
+	events        int64
+}
+
+// Compact applies policy to the events table. With dryRun it only counts the
+// rows that would be removed; otherwise it deletes them and vacuums the
+// database when anything was removed.
+func (s *Store) Compact(ctx context.Context, policy RetentionPolicy, now time.Time, dryRun bool) (CompactResult, error) {
+	if now.IsZero() {
+		now = time.Now().UTC()
+	}
+	res := CompactResult{DryRun: dryRun, CheckedAt: now, MaxEventsPerRun: policy.MaxEventsPerRun}
+	if policy.MaxAge > 0 {
+		res.Cutoff = now.Add(-policy.MaxAge)
+	}
+	if !policy.Enabled() {
+		return res, nil
+	}
+
+	runs, err := s.finishedRunEventCounts(ctx)
+	if err != nil {
+		return res, err
+	}
+	for _, r := range runs {
+		p := policy
+		if policy.ForWorkspace != nil {
+			p = policy.ForWorkspace(r.workspaceID, r.workspacePath)
+		}
+		if p.MaxRunAge > 0 && r.updatedAt.Before(now.Add(-p.MaxRunAge)) {
+			res.DeletedRuns++
+			res.ExpiredEvents += r.events
+			if !dryRun {
+				if err := s.deleteRun(ctx, r.runID); err != nil {
+					return res, err
+				}
+			}
+			continue
+		}
+		if r.events == 0 {
+			continue
+		}
+		if p.MaxAge > 0 && r.updatedAt.Before(now.Add(-p.MaxAge)) {
+			res.ExpiredRuns++
+			res.ExpiredEvents += r.events
+			if !dryRun {
+				if _, err := s.db.ExecContext(ctx, `DELETE FROM events WHERE run_id=?`, r.runID); err != nil {
+					return res, err
+				}
+			}
+			continue
+		}
+		if p.MaxEventsPerRun > 0 && r.events > int64(p.MaxEventsPerRun) {
+			res.TrimmedRuns++
+			res.TrimmedEvents += r.events - int64(p.MaxEventsPerRun)
+			if !dryRun {
+				if _, err := s.db.ExecContext(
+					ctx,
+					`DELETE FROM events WHERE run_id=? AND seq < (
+					   SELECT seq FROM events WHERE run_id=? ORDER BY seq DESC LIMIT 1 OFFSET ?
+					 )`,
+					r.runID, r.runID, p.MaxEventsPerRun-1,
+				); err != nil {
+					return res, err
+				}
+			}
+		}
+	}
+	res.RemovedEvents = res.ExpiredEvents + res.TrimmedEvents
+	if !dryRun && (res.RemovedEvents > 0 || res.DeletedRuns > 0) {
+		if _, err := s.db.ExecContext(ctx, `VACUUM`); err != nil {
+			return res, err
+		}
+		res.Vacuumed = true
+	}
+	return res, nil
+}
+
+func (s *Store) finishedRunEventCounts(ctx context.Context) ([]runEventCount, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT r.run_id, r.workspace_id, r.workspace_path, r.updated_at, COUNT(e.id)
+		 FROM runs r
+		 LEFT JOIN events e ON e.run_id = r.run_id
+		 WHERE r.status IN ('completed', 'failed', 'cancelled')
+		 GROUP BY r.run_id, r.workspace_id, r.workspace_path, r.updated_at`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []runEventCount
+	for rows.Next() {
+		var rc runEventCount
+		var updatedAt string
+		if err := rows.Scan(&rc.runID, &rc.workspaceID, &rc.workspacePath, &updatedAt, &rc.events); err != nil {
+			return nil, err
+		}
+		rc.updatedAt = parseTime(updatedAt)
+		out = append(out, rc)
+	}
+	return out, rows.Err()
+}
+
+// deleteRun removes a finished run and everything recorded for it except
+// its usage row.
+func (s *Store) deleteRun(ctx context.Context, runID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, table := range []string{"events", "run_attachments", "run_checkpoints", "run_first_events", "run_results", "runs"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE run_id=?`, runID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
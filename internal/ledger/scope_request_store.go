@@ -0,0 +1,177 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	ErrScopeRequestNotFound = errors.New("scope request not found")
+	ErrScopeRequestPending  = errors.New("device already has a pending scope request")
+	ErrScopeRequestDecided  = errors.New("scope request was already decided")
+)
+
+// Scope request statuses.
+const (
+	ScopeRequestPending  = "pending"
+	ScopeRequestApproved = "approved"
+	ScopeRequestDenied   = "denied"
+)
+
+// ScopeRequestRecord is a device's request for scopes beyond its current
+// ones, decided by an operator.
+type ScopeRequestRecord struct {
+	ID         string
+	Address    string
+	Scopes     []string
+	Reason     string
+	Status     string
+	CreatedAt  time.Time
+	DecidedAt  time.Time
+	DecidedBy  string
+	DecideNote string
+}
+
+const scopeRequestColumns = `id, address, scopes_json, reason, status, created_at, decided_at, decided_by, decide_note`
+
+// CreateScopeRequest stores a pending request unless the device already has
+// one.
+func (s *Store) CreateScopeRequest(ctx context.Context, rec ScopeRequestRecord) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var pending int
+	if err := tx.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*) FROM scope_requests WHERE address=? AND status=?`,
+		rec.Address, ScopeRequestPending,
+	).Scan(&pending); err != nil {
+		return err
+	}
+	if pending > 0 {
+		return ErrScopeRequestPending
+	}
+	scopeJSON, _ := json.Marshal(rec.Scopes)
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO scope_requests(`+scopeRequestColumns+`)
+		 VALUES (?, ?, ?, ?, ?, ?, '', '', '')`,
+		rec.ID,
+		rec.Address,
+		string(scopeJSON),
+		rec.Reason,
+		ScopeRequestPending,
+		formatTime(rec.CreatedAt),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) GetScopeRequest(ctx context.Context, id string) (ScopeRequestRecord, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+scopeRequestColumns+` FROM scope_requests WHERE id=?`, id)
+	rec, err := scanScopeRequest(row.Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ScopeRequestRecord{}, ErrScopeRequestNotFound
+	}
+	return rec, err
+}
+
+// ListScopeRequests returns requests newest first, optionally only those of
+// one device.
+func (s *Store) ListScopeRequests(ctx context.Context, address string) ([]ScopeRequestRecord, error) {
+	query := `SELECT ` + scopeRequestColumns + ` FROM scope_requests`
+	args := []any{}
+	if address != "" {
+		query += ` WHERE address=?`
+		args = append(args, address)
+	}
+	rows, err := s.db.QueryContext(ctx, query+` ORDER BY created_at DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []ScopeRequestRecord{}
+	for rows.Next() {
+		rec, err := scanScopeRequest(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// DecideScopeRequest records the decision on a pending request. On approval
+// the device gets scopes and trustLevel, and its active sessions get the
+// same scopes, all in one transaction.
+func (s *Store) DecideScopeRequest(ctx context.Context, id string, approve bool, scopes []string, trustLevel, decidedBy, note string, now time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	row := tx.QueryRowContext(ctx, `SELECT `+scopeRequestColumns+` FROM scope_requests WHERE id=?`, id)
+	req, err := scanScopeRequest(row.Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrScopeRequestNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if req.Status != ScopeRequestPending {
+		return ErrScopeRequestDecided
+	}
+	status := ScopeRequestDenied
+	if approve {
+		status = ScopeRequestApproved
+	}
+	if _, err := tx.ExecContext(
+		ctx,
+		`UPDATE scope_requests SET status=?, decided_at=?, decided_by=?, decide_note=? WHERE id=?`,
+		status, formatTime(now), decidedBy, note, id,
+	); err != nil {
+		return err
+	}
+	if approve {
+		scopeJSON, _ := json.Marshal(scopes)
+		res, err := tx.ExecContext(
+			ctx,
+			`UPDATE devices SET permissions_json=?, trust_level=? WHERE address=? AND revoked=0`,
+			string(scopeJSON), trustLevel, req.Address,
+		)
+		if err != nil {
+			return err
+		}
+		if affected, _ := res.RowsAffected(); affected == 0 {
+			return ErrDeviceNotFound
+		}
+		if _, err := tx.ExecContext(
+			ctx,
+			`UPDATE sessions SET scopes_json=? WHERE address=? AND revoked=0`,
+			string(scopeJSON), req.Address,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func scanScopeRequest(scan func(dest ...any) error) (ScopeRequestRecord, error) {
+	var rec ScopeRequestRecord
+	var scopesJSON, createdAt, decidedAt string
+	if err := scan(&rec.ID, &rec.Address, &scopesJSON, &rec.Reason, &rec.Status, &createdAt, &decidedAt, &rec.DecidedBy, &rec.DecideNote); err != nil {
+		return ScopeRequestRecord{}, err
+	}
+	rec.Scopes = decodeStringArray(scopesJSON)
+	rec.CreatedAt = parseTime(createdAt)
+	rec.DecidedAt = parseTime(decidedAt)
+	return rec, nil
+}
@@ -15,9 +15,15 @@ import (
 )
 
 type Store struct {
-	db *sql.DB
+	db  *sqlDB
+	dsn string
 }
 
+var (
+	ErrDuplicateSeq = errors.New("event seq already exists for run")
+	ErrRunNotFound  = errors.New("run not found")
+)
+
 type RunRecord struct {
 	ID          string
 	WorkspaceID string
@@ -28,8 +34,22 @@ type RunRecord struct {
 	Options     RunOptionsRecord
 	Status      string
 	Error       string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	// SubmittedBy is the principal address that submitted the run, if any.
+	SubmittedBy string
+	// ParentRunID is the run this one retries, if any.
+	ParentRunID string
+	// Input is the prompt and context as submitted, before attachment and
+	// prompt rewriting, so the run can be retried. Nil for older runs.
+	Input     *RunInputRecord
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type RunInputRecord struct {
+	Prompt         string         `json:"prompt"`
+	Context        map[string]any `json:"context,omitempty"`
+	StrictMentions bool           `json:"strict_mentions,omitempty"`
+	RequiredTools  []string       `json:"required_tools,omitempty"`
 }
 
 type RunOptionsRecord struct {
@@ -37,11 +57,22 @@ type RunOptionsRecord struct {
 	Profile       string
 	Sandbox       string
 	SchemaVersion string
+	Interactive   bool
+	PTY           bool
+	PTYCols       int
+	PTYRows       int
+	RawOutput     bool
+	Checkpoint    bool
+	// TimeoutSeconds is the per-run timeout override; zero uses the
+	// bridge's run timeout.
+	TimeoutSeconds int `json:",omitempty"`
 }
 
 type TokenUsageRecord struct {
 	RunID        string
 	Backend      string
+	WorkspaceID  string
+	SubmittedBy  string
 	InputTokens  int64
 	OutputTokens int64
 	TotalTokens  int64
@@ -49,7 +80,10 @@ type TokenUsageRecord struct {
 }
 
 type TokenUsageAggregate struct {
+	// Group is the device address or workspace id for grouped aggregates.
+	Group        string
 	Backend      string
+	Model        string
 	RunCount     int64
 	InputTokens  int64
 	OutputTokens int64
@@ -59,15 +93,60 @@ type TokenUsageAggregate struct {
 type persistedContext struct {
 	Context map[string]any   `json:"context,omitempty"`
 	Options RunOptionsRecord `json:"options,omitempty"`
+	// SubmittedBy, ParentRunID and Input live in context_json so older
+	// databases need no migration.
+	SubmittedBy string          `json:"submitted_by,omitempty"`
+	ParentRunID string          `json:"parent_run_id,omitempty"`
+	Input       *RunInputRecord `json:"input,omitempty"`
 }
 
-func Open(path string) (*Store, error) {
-	db, err := sql.Open("sqlite", path)
+// Open opens the ledger at dsn: a postgres:// or postgresql:// URL selects
+// Postgres, anything else is treated as a SQLite database path.
+func Open(dsn string) (*Store, error) {
+	d := dialectFor(dsn)
+	db, err := sql.Open(d.driverName(), dsn)
 	if err != nil {
 		return nil, err
 	}
-	db.SetMaxOpenConns(1)
-	return &Store{db: db}, nil
+	if d.name() == "sqlite" {
+		db.SetMaxOpenConns(1)
+	}
+	return &Store{db: &sqlDB{DB: db, d: d}, dsn: dsn}, nil
+}
+
+// JournalMode reports the SQLite journal mode ("wal" after Init), or an
+// empty string for other backends.
+func (s *Store) JournalMode(ctx context.Context) (string, error) {
+	if s.db.d.name() != "sqlite" {
+		return "", nil
+	}
+	var mode string
+	err := s.db.QueryRowContext(ctx, `PRAGMA journal_mode`).Scan(&mode)
+	return mode, err
+}
+
+// Backend reports the ledger backend, "sqlite" or "postgres".
+func (s *Store) Backend() string {
+	return s.db.d.name()
+}
+
+// SizeBytes reports the space the ledger database takes: SQLite's page
+// count times page size (the WAL file is not included), or the size of the
+// current Postgres database.
+func (s *Store) SizeBytes(ctx context.Context) (int64, error) {
+	var size int64
+	if s.db.d.name() != "sqlite" {
+		err := s.db.QueryRowContext(ctx, `SELECT pg_database_size(current_database())`).Scan(&size)
+		return size, err
+	}
+	var pages, pageSize int64
+	if err := s.db.QueryRowContext(ctx, `PRAGMA page_count`).Scan(&pages); err != nil {
+		return 0, err
+	}
+	if err := s.db.QueryRowContext(ctx, `PRAGMA page_size`).Scan(&pageSize); err != nil {
+		return 0, err
+	}
+	return pages * pageSize, nil
 }
 
 func (s *Store) Close() error {
@@ -75,6 +154,17 @@ func (s *Store) Close() error {
 }
 
 func (s *Store) Init(ctx context.Context) error {
+	if s.db.d.name() == "sqlite" {
+		// WAL lets readers proceed during event writes; NORMAL sync is
+		// durable across application crashes in WAL mode.
+		var mode string
+		if err := s.db.QueryRowContext(ctx, `PRAGMA journal_mode=WAL`).Scan(&mode); err != nil {
+			return err
+		}
+		if _, err := s.db.ExecContext(ctx, `PRAGMA synchronous=NORMAL`); err != nil {
+			return err
+		}
+	}
 	schema := `
 CREATE TABLE IF NOT EXISTS runs (
   run_id TEXT PRIMARY KEY,
@@ -114,6 +204,11 @@ CREATE TABLE IF NOT EXISTS run_usage (
   recorded_at TEXT NOT NULL
 );
 CREATE INDEX IF NOT EXISTS idx_run_usage_backend_recorded_at ON run_usage(backend, recorded_at);
+CREATE TABLE IF NOT EXISTS run_first_events (
+  run_id TEXT PRIMARY KEY,
+  latency_ms INTEGER NOT NULL,
+  recorded_at TEXT NOT NULL
+);
 CREATE TABLE IF NOT EXISTS files (
   file_id TEXT PRIMARY KEY,
   storage_key TEXT NOT NULL UNIQUE,
@@ -134,34 +229,83 @@ CREATE TABLE IF NOT EXISTS run_attachments (
   PRIMARY KEY(run_id, alias)
 );
 CREATE INDEX IF NOT EXISTS idx_run_attachments_run_id ON run_attachments(run_id);`
-	if _, err := s.db.ExecContext(ctx, schema); err != nil {
+	if _, err := s.db.ExecContext(ctx, s.db.d.ddl(schema)); err != nil {
+		return err
+	}
+	if err := s.ensureColumn(ctx, "events", "channel", "TEXT"); err != nil {
+		return err
+	}
+	if err := s.ensureColumn(ctx, "events", "schema_version", "TEXT"); err != nil {
 		return err
 	}
-	if err := s.ensureEventColumn(ctx, "channel", "TEXT"); err != nil {
+	if err := s.ensureColumn(ctx, "events", "format", "TEXT"); err != nil {
 		return err
 	}
-	if err := s.ensureEventColumn(ctx, "schema_version", "TEXT"); err != nil {
+	if err := s.ensureColumn(ctx, "events", "role", "TEXT"); err != nil {
 		return err
 	}
-	if err := s.ensureEventColumn(ctx, "format", "TEXT"); err != nil {
+	if err := s.ensureColumn(ctx, "events", "compat_json", "TEXT"); err != nil {
 		return err
 	}
-	if err := s.ensureEventColumn(ctx, "role", "TEXT"); err != nil {
+	if err := s.ensureColumn(ctx, "run_usage", "workspace_id", "TEXT"); err != nil {
 		return err
 	}
-	if err := s.ensureEventColumn(ctx, "compat_json", "TEXT"); err != nil {
+	if err := s.ensureColumn(ctx, "run_usage", "submitted_by", "TEXT"); err != nil {
+		return err
+	}
+	// Attribute usage recorded before these columns existed.
+	if _, err := s.db.ExecContext(ctx, `UPDATE run_usage
+		SET workspace_id = COALESCE((SELECT r.workspace_id FROM runs r WHERE r.run_id = run_usage.run_id), ''),
+		    submitted_by = COALESCE((SELECT `+s.db.d.jsonText("r.context_json", "submitted_by")+` FROM runs r WHERE r.run_id = run_usage.run_id), '')
+		WHERE workspace_id = '' AND submitted_by = ''`); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_run_usage_submitted_by_recorded_at ON run_usage(submitted_by, recorded_at)`); err != nil {
 		return err
 	}
 	if err := s.initAuthSchema(ctx); err != nil {
 		return err
 	}
+	if err := s.initWorkspaceSchema(ctx); err != nil {
+		return err
+	}
+	if err := s.initAuditSchema(ctx); err != nil {
+		return err
+	}
+	if err := s.initAgentSessionSchema(ctx); err != nil {
+		return err
+	}
+	if err := s.initLeaseSchema(ctx); err != nil {
+		return err
+	}
+	if err := s.initRateWindowSchema(ctx); err != nil {
+		return err
+	}
+	if err := s.initCheckpointSchema(ctx); err != nil {
+		return err
+	}
+	if err := s.initDigestSchema(ctx); err != nil {
+		return err
+	}
+	if err := s.initPipelineSchema(ctx); err != nil {
+		return err
+	}
+	if err := s.initRunResultSchema(ctx); err != nil {
+		return err
+	}
+	if err := s.initIdempotencySchema(ctx); err != nil {
+		return err
+	}
 	return nil
 }
 
 func (s *Store) CreateRun(ctx context.Context, r RunRecord) error {
 	ctxJSON, _ := json.Marshal(persistedContext{
-		Context: r.Context,
-		Options: r.Options,
+		Context:     r.Context,
+		Options:     r.Options,
+		SubmittedBy: r.SubmittedBy,
+		ParentRunID: r.ParentRunID,
+		Input:       r.Input,
 	})
 	_, err := s.db.ExecContext(
 		ctx,
@@ -181,6 +325,18 @@ func (s *Store) UpdateRunStatus(ctx context.Context, runID, status, errText stri
 	return err
 }
 
+// SetRunFirstEventLatency records how long a run waited for its first
+// backend event.
+func (s *Store) SetRunFirstEventLatency(ctx context.Context, runID string, latency time.Duration) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO run_first_events(run_id, latency_ms, recorded_at) VALUES(?, ?, ?)
+		 ON CONFLICT(run_id) DO UPDATE SET latency_ms=excluded.latency_ms, recorded_at=excluded.recorded_at`,
+		runID, latency.Milliseconds(), time.Now().UTC().Format(time.RFC3339Nano),
+	)
+	return err
+}
+
 func (s *Store) UpdateRunStatusIfNotTerminal(ctx context.Context, runID, status, errText string) (bool, error) {
 	res, err := s.db.ExecContext(
 		ctx,
@@ -220,15 +376,18 @@ func (s *Store) GetRun(ctx context.Context, runID string) (RunRecord, error) {
 		&out.ID, &out.WorkspaceID, &out.Workspace, &out.Backend, &out.Prompt, &ctxJSON, &out.Status, &out.Error, &tsCreated, &tsUpdated,
 	); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return RunRecord{}, fmt.Errorf("run not found")
+			return RunRecord{}, ErrRunNotFound
 		}
 		return RunRecord{}, err
 	}
 	if ctxJSON != "" {
 		var persisted persistedContext
-		if err := json.Unmarshal([]byte(ctxJSON), &persisted); err == nil && (persisted.Context != nil || persisted.Options != (RunOptionsRecord{})) {
+		if err := json.Unmarshal([]byte(ctxJSON), &persisted); err == nil && (persisted.Context != nil || persisted.Options != (RunOptionsRecord{}) || persisted.SubmittedBy != "" || persisted.Input != nil) {
 			out.Context = persisted.Context
 			out.Options = persisted.Options
+			out.SubmittedBy = persisted.SubmittedBy
+			out.ParentRunID = persisted.ParentRunID
+			out.Input = persisted.Input
 		} else {
 			// backward compatible path for older rows storing context only
 			_ = json.Unmarshal([]byte(ctxJSON), &out.Context)
@@ -239,6 +398,77 @@ func (s *Store) GetRun(ctx context.Context, runID string) (RunRecord, error) {
 	return out, nil
 }
 
+// ListStaleRuns returns runs in status that were last updated before cutoff.
+func (s *Store) ListStaleRuns(ctx context.Context, status string, cutoff time.Time) ([]RunRecord, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT run_id, workspace_id, backend, status, created_at, updated_at
+		 FROM runs WHERE status=? AND updated_at < ?
+		 ORDER BY created_at ASC`,
+		status,
+		cutoff.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []RunRecord
+	for rows.Next() {
+		var rec RunRecord
+		var createdAt, updatedAt string
+		if err := rows.Scan(&rec.ID, &rec.WorkspaceID, &rec.Backend, &rec.Status, &createdAt, &updatedAt); err != nil {
+			return nil, err
+		}
+		rec.CreatedAt = parseTime(createdAt)
+		rec.UpdatedAt = parseTime(updatedAt)
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// RunListQuery filters ListRuns. Results are newest first.
+type RunListQuery struct {
+	Status      string
+	WorkspaceID string
+	Limit       int
+}
+
+// ListRuns returns run summaries without context or options.
+func (s *Store) ListRuns(ctx context.Context, q RunListQuery) ([]RunRecord, error) {
+	query := `SELECT run_id, workspace_id, workspace_path, backend, prompt, status, error_text, created_at, updated_at
+		 FROM runs WHERE 1=1`
+	var args []any
+	if q.Status != "" {
+		query += ` AND status=?`
+		args = append(args, q.Status)
+	}
+	if q.WorkspaceID != "" {
+		query += ` AND workspace_id=?`
+		args = append(args, q.WorkspaceID)
+	}
+	query += ` ORDER BY created_at DESC LIMIT ?`
+	args = append(args, q.Limit)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []RunRecord
+	for rows.Next() {
+		var rec RunRecord
+		var createdAt, updatedAt string
+		if err := rows.Scan(&rec.ID, &rec.WorkspaceID, &rec.Workspace, &rec.Backend, &rec.Prompt, &rec.Status, &rec.Error, &createdAt, &updatedAt); err != nil {
+			return nil, err
+		}
+		rec.CreatedAt = parseTime(createdAt)
+		rec.UpdatedAt = parseTime(updatedAt)
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
 func (s *Store) AppendEvent(ctx context.Context, ev events.Event) error {
 	events.NormalizeEvent(&ev)
 	compatJSON, _ := json.Marshal(ev.Compat)
@@ -249,9 +479,48 @@ func (s *Store) AppendEvent(ctx context.Context, ev events.Event) error {
 		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		ev.RunID, ev.Seq, ev.TS.UTC().Format(time.RFC3339Nano), ev.SchemaVersion, ev.Type, ev.Channel, ev.Format, ev.Role, string(compatJSON), string(payloadJSON), ev.Backend, ev.Source,
 	)
+	if err != nil && s.db.d.isUniqueViolation(err) {
+		return fmt.Errorf("%w: run=%s seq=%d", ErrDuplicateSeq, ev.RunID, ev.Seq)
+	}
 	return err
 }
 
+// AppendEvents stores evs in one transaction. On any error, including a
+// duplicate seq, nothing is stored.
+func (s *Store) AppendEvents(ctx context.Context, evs []events.Event) error {
+	if len(evs) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	stmt, err := tx.PrepareContext(ctx, s.db.d.rebind(
+		`INSERT INTO events(run_id, seq, ts, schema_version, type, channel, format, role, compat_json, payload_json, backend, source)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+	))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, ev := range evs {
+		events.NormalizeEvent(&ev)
+		compatJSON, _ := json.Marshal(ev.Compat)
+		payloadJSON, _ := json.Marshal(ev.Payload)
+		if _, err := stmt.ExecContext(
+			ctx,
+			ev.RunID, ev.Seq, ev.TS.UTC().Format(time.RFC3339Nano), ev.SchemaVersion, ev.Type, ev.Channel, ev.Format, ev.Role, string(compatJSON), string(payloadJSON), ev.Backend, ev.Source,
+		); err != nil {
+			if s.db.d.isUniqueViolation(err) {
+				return fmt.Errorf("%w: run=%s seq=%d", ErrDuplicateSeq, ev.RunID, ev.Seq)
+			}
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 func (s *Store) ListEvents(ctx context.Context, runID string, fromSeq, limit int64) ([]events.Event, error) {
 	if limit <= 0 {
 		limit = 1000
@@ -270,54 +539,37 @@ func (s *Store) ListEvents(ctx context.Context, runID string, fromSeq, limit int
 
 	out := []events.Event{}
 	for rows.Next() {
-		var ev events.Event
-		var ts string
-		var compatJSON string
-		var payloadJSON string
-		if err := rows.Scan(&ev.RunID, &ev.Seq, &ts, &ev.SchemaVersion, &ev.Type, &ev.Channel, &ev.Format, &ev.Role, &compatJSON, &payloadJSON, &ev.Backend, &ev.Source); err != nil {
+		ev, err := scanEvent(rows)
+		if err != nil {
 			return nil, err
 		}
-		ev.TS, _ = time.Parse(time.RFC3339Nano, ts)
-		if compatJSON != "" && compatJSON != "null" {
-			var compat events.CompatFields
-			if err := json.Unmarshal([]byte(compatJSON), &compat); err == nil {
-				ev.Compat = &compat
-			}
-		}
-		_ = json.Unmarshal([]byte(payloadJSON), &ev.Payload)
-		events.NormalizeEvent(&ev)
 		out = append(out, ev)
 	}
 	return out, rows.Err()
 }
 
-func (s *Store) ensureEventColumn(ctx context.Context, name, typ string) error {
-	rows, err := s.db.QueryContext(ctx, `PRAGMA table_info(events)`)
-	if err != nil {
-		return err
+func scanEvent(rows *sql.Rows) (events.Event, error) {
+	var ev events.Event
+	var ts string
+	var compatJSON string
+	var payloadJSON string
+	if err := rows.Scan(&ev.RunID, &ev.Seq, &ts, &ev.SchemaVersion, &ev.Type, &ev.Channel, &ev.Format, &ev.Role, &compatJSON, &payloadJSON, &ev.Backend, &ev.Source); err != nil {
+		return events.Event{}, err
 	}
-	defer rows.Close()
-
-	has := false
-	for rows.Next() {
-		var cid int
-		var colName string
-		var colType string
-		var notNull int
-		var dflt sql.NullString
-		var pk int
-		if err := rows.Scan(&cid, &colName, &colType, &notNull, &dflt, &pk); err != nil {
-			return err
-		}
-		if colName == name {
-			has = true
+	ev.TS, _ = time.Parse(time.RFC3339Nano, ts)
+	if compatJSON != "" && compatJSON != "null" {
+		var compat events.CompatFields
+		if err := json.Unmarshal([]byte(compatJSON), &compat); err == nil {
+			ev.Compat = &compat
 		}
 	}
-	if has {
-		return nil
-	}
-	_, err = s.db.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE events ADD COLUMN %s %s NOT NULL DEFAULT ''`, name, typ))
-	return err
+	_ = json.Unmarshal([]byte(payloadJSON), &ev.Payload)
+	events.NormalizeEvent(&ev)
+	return ev, nil
+}
+
+func (s *Store) ensureColumn(ctx context.Context, table, name, typ string) error {
+	return s.db.d.addColumn(ctx, s.db, table, name, typ)
 }
 
 func (s *Store) NextSeq(ctx context.Context, runID string) (int64, error) {
@@ -341,16 +593,20 @@ func (s *Store) UpsertTokenUsage(ctx context.Context, rec TokenUsageRecord) erro
 	}
 	_, err := s.db.ExecContext(
 		ctx,
-		`INSERT INTO run_usage(run_id, backend, input_tokens, output_tokens, total_tokens, recorded_at)
-		 VALUES (?, ?, ?, ?, ?, ?)
+		`INSERT INTO run_usage(run_id, backend, workspace_id, submitted_by, input_tokens, output_tokens, total_tokens, recorded_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 		 ON CONFLICT(run_id) DO UPDATE SET
 		   backend=excluded.backend,
+		   workspace_id=excluded.workspace_id,
+		   submitted_by=excluded.submitted_by,
 		   input_tokens=excluded.input_tokens,
 		   output_tokens=excluded.output_tokens,
 		   total_tokens=excluded.total_tokens,
 		   recorded_at=excluded.recorded_at`,
 		rec.RunID,
 		rec.Backend,
+		rec.WorkspaceID,
+		rec.SubmittedBy,
 		rec.InputTokens,
 		rec.OutputTokens,
 		rec.TotalTokens,
@@ -386,3 +642,110 @@ func (s *Store) AggregateTokenUsage(ctx context.Context, from, to time.Time, bac
 	}
 	return out, rows.Err()
 }
+
+func (s *Store) GetTokenUsage(ctx context.Context, runID string) (TokenUsageRecord, bool, error) {
+	var rec TokenUsageRecord
+	var recordedAt string
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT run_id, backend, workspace_id, submitted_by, input_tokens, output_tokens, total_tokens, recorded_at FROM run_usage WHERE run_id=?`,
+		runID,
+	).Scan(&rec.RunID, &rec.Backend, &rec.WorkspaceID, &rec.SubmittedBy, &rec.InputTokens, &rec.OutputTokens, &rec.TotalTokens, &recordedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TokenUsageRecord{}, false, nil
+	}
+	if err != nil {
+		return TokenUsageRecord{}, false, err
+	}
+	rec.RecordedAt = parseTime(recordedAt)
+	return rec, true, nil
+}
+
+// AggregateTokenUsageByModel is AggregateTokenUsage split further by the
+// model recorded in each run's options (empty when the run used the default).
+func (s *Store) AggregateTokenUsageByModel(ctx context.Context, from, to time.Time, backend string) ([]TokenUsageAggregate, error) {
+	base := `SELECT u.backend, COALESCE(` + s.db.d.jsonText("r.context_json", "options.Model") + `, ''),
+	                COUNT(*), COALESCE(SUM(u.input_tokens), 0), COALESCE(SUM(u.output_tokens), 0), COALESCE(SUM(u.total_tokens), 0)
+	         FROM run_usage u
+	         LEFT JOIN runs r ON r.run_id = u.run_id
+	         WHERE u.recorded_at >= ? AND u.recorded_at < ?`
+	args := []any{from.UTC().Format(time.RFC3339Nano), to.UTC().Format(time.RFC3339Nano)}
+	if strings.TrimSpace(backend) != "" {
+		base += ` AND u.backend = ?`
+		args = append(args, strings.TrimSpace(backend))
+	}
+	base += ` GROUP BY 1, 2 ORDER BY 1 ASC, 2 ASC`
+
+	rows, err := s.db.QueryContext(ctx, base, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]TokenUsageAggregate, 0, 8)
+	for rows.Next() {
+		var agg TokenUsageAggregate
+		if err := rows.Scan(&agg.Backend, &agg.Model, &agg.RunCount, &agg.InputTokens, &agg.OutputTokens, &agg.TotalTokens); err != nil {
+			return nil, err
+		}
+		out = append(out, agg)
+	}
+	return out, rows.Err()
+}
+
+// SumTokenUsageBySubmitter totals tokens recorded in [from, to) for runs
+// submitted by the given principal address.
+func (s *Store) SumTokenUsageBySubmitter(ctx context.Context, from, to time.Time, submitter string) (int64, error) {
+	var total int64
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT COALESCE(SUM(total_tokens), 0)
+		 FROM run_usage
+		 WHERE submitted_by = ? AND recorded_at >= ? AND recorded_at < ?`,
+		submitter,
+		from.UTC().Format(time.RFC3339Nano),
+		to.UTC().Format(time.RFC3339Nano),
+	).Scan(&total)
+	return total, err
+}
+
+// AggregateTokenUsageByGroup splits usage by groupBy ("device" or
+// "workspace"), then backend and model so callers can price each group.
+func (s *Store) AggregateTokenUsageByGroup(ctx context.Context, from, to time.Time, backend, groupBy string) ([]TokenUsageAggregate, error) {
+	var column string
+	switch groupBy {
+	case "device":
+		column = "u.submitted_by"
+	case "workspace":
+		column = "u.workspace_id"
+	default:
+		return nil, fmt.Errorf("unsupported usage group %q", groupBy)
+	}
+	base := `SELECT ` + column + `, u.backend, COALESCE(` + s.db.d.jsonText("r.context_json", "options.Model") + `, ''),
+	                COUNT(*), COALESCE(SUM(u.input_tokens), 0), COALESCE(SUM(u.output_tokens), 0), COALESCE(SUM(u.total_tokens), 0)
+	         FROM run_usage u
+	         LEFT JOIN runs r ON r.run_id = u.run_id
+	         WHERE u.recorded_at >= ? AND u.recorded_at < ?`
+	args := []any{from.UTC().Format(time.RFC3339Nano), to.UTC().Format(time.RFC3339Nano)}
+	if strings.TrimSpace(backend) != "" {
+		base += ` AND u.backend = ?`
+		args = append(args, strings.TrimSpace(backend))
+	}
+	base += ` GROUP BY 1, 2, 3 ORDER BY 1 ASC, 2 ASC, 3 ASC`
+
+	rows, err := s.db.QueryContext(ctx, base, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]TokenUsageAggregate, 0, 8)
+	for rows.Next() {
+		var agg TokenUsageAggregate
+		if err := rows.Scan(&agg.Group, &agg.Backend, &agg.Model, &agg.RunCount, &agg.InputTokens, &agg.OutputTokens, &agg.TotalTokens); err != nil {
+			return nil, err
+		}
+		out = append(out, agg)
+	}
+	return out, rows.Err()
+}
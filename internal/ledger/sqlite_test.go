@@ -3,10 +3,13 @@ package ledger
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"echohelix/internal/events"
+
 	_ "modernc.org/sqlite"
 )
 
@@ -137,3 +140,372 @@ func TestUpdateRunStatusIfNotTerminal(t *testing.T) {
 		t.Fatalf("expected completed to remain, got %s", rec.Status)
 	}
 }
+
+func TestVerifyReportsCrossTableInconsistencies(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "verify.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+	if err := store.Init(ctx); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+
+	now := time.Now().UTC()
+	old := now.Add(-3 * time.Hour)
+	for _, rec := range []RunRecord{
+		{ID: "run-stale", Status: "streaming", CreatedAt: old, UpdatedAt: old},
+		{ID: "run-fresh", Status: "streaming", CreatedAt: now, UpdatedAt: now},
+		{ID: "run-done", Status: "completed", CreatedAt: old, UpdatedAt: old},
+	} {
+		rec.Workspace, rec.Backend, rec.Prompt = "/tmp", "codex", "p"
+		if err := store.CreateRun(ctx, rec); err != nil {
+			t.Fatalf("create run: %v", err)
+		}
+	}
+	stmts := []string{
+		`INSERT INTO events(run_id, seq, ts, type, payload_json, backend, source) VALUES ('run-gone', 1, '` + formatTime(now) + `', 'status', '{}', 'codex', 'bridge')`,
+		`INSERT INTO run_attachments(run_id, file_id, alias, materialized_path, created_at) VALUES ('run-done', 'file-gone', 'a.txt', 'a.txt', '` + formatTime(now) + `')`,
+		`INSERT INTO files(file_id, storage_key, original_name, mime_type, size_bytes, sha256, created_by, created_at) VALUES ('file-1', 'blob-1', 'f', 'text/plain', 1, 'x', 'op', '` + formatTime(now) + `')`,
+		`INSERT INTO devices(address, public_key, created_at, last_seen_at, revoked) VALUES ('dev-revoked', 'pk', '` + formatTime(now) + `', '` + formatTime(now) + `', 1)`,
+		`INSERT INTO sessions(session_id, access_hash, refresh_hash, address, created_at, expires_at, refresh_expires_at) VALUES ('sess-1', 'a', 'r', 'dev-revoked', '` + formatTime(now) + `', '` + formatTime(now.Add(time.Hour)) + `', '` + formatTime(now.Add(time.Hour)) + `')`,
+	}
+	for _, stmt := range stmts {
+		if _, err := store.db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("seed %q: %v", stmt, err)
+		}
+	}
+
+	report, err := store.Verify(ctx, VerifyOptions{
+		StaleAfter: time.Hour,
+		BlobExists: func(string) bool { return false },
+	})
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if report.OK {
+		t.Fatalf("expected report to flag issues")
+	}
+	want := map[string]string{
+		CheckStaleRun:             "run-stale",
+		CheckOrphanEvents:         "run-gone",
+		CheckAttachmentNoFile:     "run-done/a.txt",
+		CheckFileBlobMissing:      "file-1",
+		CheckSessionDeviceRevoked: "sess-1",
+	}
+	for check, subject := range want {
+		if report.Counts[check] != 1 {
+			t.Fatalf("expected one %s issue, counts=%v", check, report.Counts)
+		}
+		found := false
+		for _, issue := range report.Issues {
+			if issue.Check == check && issue.Subject == subject {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("missing %s issue for %s: %#v", check, subject, report.Issues)
+		}
+	}
+	if len(report.Issues) != len(want) {
+		t.Fatalf("unexpected extra issues: %#v", report.Issues)
+	}
+}
+
+func TestCompactAppliesRetentionToFinishedRuns(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "compact.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+	if err := store.Init(ctx); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+
+	now := time.Now().UTC()
+	old := now.Add(-48 * time.Hour)
+	for _, rec := range []RunRecord{
+		{ID: "run-old", Status: "completed", CreatedAt: old, UpdatedAt: old},
+		{ID: "run-long", Status: "failed", CreatedAt: now, UpdatedAt: now},
+		{ID: "run-active", Status: "streaming", CreatedAt: old, UpdatedAt: old},
+	} {
+		rec.Workspace, rec.Backend, rec.Prompt = "/tmp", "codex", "p"
+		if err := store.CreateRun(ctx, rec); err != nil {
+			t.Fatalf("create run: %v", err)
+		}
+		for seq := int64(1); seq <= 5; seq++ {
+			if _, err := store.db.ExecContext(ctx,
+				`INSERT INTO events(run_id, seq, ts, type, payload_json, backend, source) VALUES (?, ?, ?, 'status', '{}', 'codex', 'bridge')`,
+				rec.ID, seq, formatTime(rec.UpdatedAt),
+			); err != nil {
+				t.Fatalf("seed event: %v", err)
+			}
+		}
+	}
+
+	policy := RetentionPolicy{MaxAge: 24 * time.Hour, MaxEventsPerRun: 2}
+	dry, err := store.Compact(ctx, policy, now, true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if dry.ExpiredRuns != 1 || dry.ExpiredEvents != 5 || dry.TrimmedRuns != 1 || dry.TrimmedEvents != 3 || dry.Vacuumed {
+		t.Fatalf("unexpected dry run result: %+v", dry)
+	}
+	if evs, _ := store.ListEvents(ctx, "run-old", 0, 100); len(evs) != 5 {
+		t.Fatalf("dry run must not delete, got %d events", len(evs))
+	}
+
+	res, err := store.Compact(ctx, policy, now, false)
+	if err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if res.RemovedEvents != 8 || !res.Vacuumed {
+		t.Fatalf("unexpected compact result: %+v", res)
+	}
+	for runID, want := range map[string][]int64{"run-old": nil, "run-long": {4, 5}, "run-active": {1, 2, 3, 4, 5}} {
+		evs, err := store.ListEvents(ctx, runID, 0, 100)
+		if err != nil {
+			t.Fatalf("list events: %v", err)
+		}
+		if len(evs) != len(want) {
+			t.Fatalf("%s: expected %d events, got %d", runID, len(want), len(evs))
+		}
+		for i, ev := range evs {
+			if ev.Seq != want[i] {
+				t.Fatalf("%s: expected seq %v, got %d at %d", runID, want, ev.Seq, i)
+			}
+		}
+	}
+	if _, err := store.GetRun(ctx, "run-old"); err != nil {
+		t.Fatalf("run row must be kept: %v", err)
+	}
+}
+
+func TestCompactAppliesWorkspaceOverrides(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "compact-ws.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+	if err := store.Init(ctx); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+
+	now := time.Now().UTC()
+	old := now.Add(-72 * time.Hour)
+	for _, rec := range []RunRecord{
+		{ID: "run-strict", WorkspaceID: "ws-strict", Status: "completed", CreatedAt: old, UpdatedAt: old},
+		{ID: "run-default", WorkspaceID: "ws-default", Status: "completed", CreatedAt: old, UpdatedAt: old},
+	} {
+		rec.Workspace, rec.Backend, rec.Prompt = "/tmp", "codex", "p"
+		if err := store.CreateRun(ctx, rec); err != nil {
+			t.Fatalf("create run: %v", err)
+		}
+		if _, err := store.db.ExecContext(ctx,
+			`INSERT INTO events(run_id, seq, ts, type, payload_json, backend, source) VALUES (?, 1, ?, 'status', '{}', 'codex', 'bridge')`,
+			rec.ID, formatTime(old),
+		); err != nil {
+			t.Fatalf("seed event: %v", err)
+		}
+	}
+
+	policy := RetentionPolicy{
+		MaxEventsPerRun: 100,
+		ForWorkspace: func(workspaceID, _ string) RetentionPolicy {
+			if workspaceID == "ws-strict" {
+				return RetentionPolicy{MaxRunAge: 24 * time.Hour}
+			}
+			return RetentionPolicy{MaxEventsPerRun: 100}
+		},
+	}
+	res, err := store.Compact(ctx, policy, now, false)
+	if err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if res.DeletedRuns != 1 || res.RemovedEvents != 1 || res.ExpiredRuns != 0 {
+		t.Fatalf("unexpected compact result: %+v", res)
+	}
+	if _, err := store.GetRun(ctx, "run-strict"); err == nil {
+		t.Fatalf("expected strict workspace run deleted, got %v", err)
+	}
+	if evs, _ := store.ListEvents(ctx, "run-default", 0, 100); len(evs) != 1 {
+		t.Fatalf("default workspace run must keep its events, got %d", len(evs))
+	}
+}
+
+func TestAppendEventsUsesWALAndIsAtomic(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "batch.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+	if err := store.Init(ctx); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	if mode, err := store.JournalMode(ctx); err != nil || mode != "wal" {
+		t.Fatalf("journal mode=%q err=%v", mode, err)
+	}
+
+	if err := store.AppendEvents(ctx, benchEvents("run-1", 1, 3)); err != nil {
+		t.Fatalf("append batch: %v", err)
+	}
+	// seq 3 already exists, so the whole batch must be rejected.
+	err = store.AppendEvents(ctx, benchEvents("run-1", 3, 2))
+	if !errors.Is(err, ErrDuplicateSeq) {
+		t.Fatalf("expected duplicate seq error, got %v", err)
+	}
+	evs, err := store.ListEvents(ctx, "run-1", 0, 100)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(evs) != 3 || evs[2].Seq != 3 {
+		t.Fatalf("expected only the first batch, got %d events", len(evs))
+	}
+}
+
+func BenchmarkAppendEvent(b *testing.B) {
+	store := openBenchStore(b)
+	ctx := context.Background()
+	evs := benchEvents("bench", 1, b.N)
+	b.ResetTimer()
+	for _, ev := range evs {
+		if err := store.AppendEvent(ctx, ev); err != nil {
+			b.Fatalf("append: %v", err)
+		}
+	}
+}
+
+func BenchmarkAppendEventsBatch64(b *testing.B) {
+	store := openBenchStore(b)
+	ctx := context.Background()
+	evs := benchEvents("bench", 1, b.N)
+	b.ResetTimer()
+	for i := 0; i < len(evs); i += 64 {
+		end := i + 64
+		if end > len(evs) {
+			end = len(evs)
+		}
+		if err := store.AppendEvents(ctx, evs[i:end]); err != nil {
+			b.Fatalf("append batch: %v", err)
+		}
+	}
+}
+
+func openBenchStore(b *testing.B) *Store {
+	b.Helper()
+	store, err := Open(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatalf("open: %v", err)
+	}
+	b.Cleanup(func() { _ = store.Close() })
+	if err := store.Init(context.Background()); err != nil {
+		b.Fatalf("init: %v", err)
+	}
+	return store
+}
+
+// benchEvents returns n token events for runID with seqs starting at from,
+// shaped like a high-token stream.
+func benchEvents(runID string, from int64, n int) []events.Event {
+	out := make([]events.Event, n)
+	now := time.Now().UTC()
+	for i := range out {
+		out[i] = events.Event{
+			RunID:   runID,
+			Seq:     from + int64(i),
+			TS:      now,
+			Type:    events.TypeToken,
+			Payload: map[string]any{"text": "tok"},
+			Backend: "codex",
+			Source:  "bench",
+		}
+	}
+	return out
+}
+
+func TestRateWindowLimitsAndExpires(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "rate.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+	if err := store.Init(ctx); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+
+	now := time.Now().UTC()
+	for i := 1; i <= 2; i++ {
+		w, ok, err := store.HitRateWindow(ctx, "pair_start", "10.0.0.1", now, time.Minute, 2)
+		if err != nil || !ok || w.Hits != i {
+			t.Fatalf("hit %d: ok=%v hits=%d err=%v", i, ok, w.Hits, err)
+		}
+	}
+	w, ok, err := store.HitRateWindow(ctx, "pair_start", "10.0.0.1", now.Add(time.Second), time.Minute, 2)
+	if err != nil || ok || w.Hits != 2 {
+		t.Fatalf("over limit: ok=%v hits=%d err=%v", ok, w.Hits, err)
+	}
+	if !w.Start.Equal(now.Truncate(time.Millisecond)) {
+		t.Fatalf("window start = %v, want %v", w.Start, now)
+	}
+	if _, ok, _ := store.HitRateWindow(ctx, "auth_failure", "10.0.0.1", now, time.Minute, 2); !ok {
+		t.Fatal("scopes should not share windows")
+	}
+
+	w, ok, err = store.HitRateWindow(ctx, "pair_start", "10.0.0.1", now.Add(time.Minute), time.Minute, 2)
+	if err != nil || !ok || w.Hits != 1 {
+		t.Fatalf("after expiry: ok=%v hits=%d err=%v", ok, w.Hits, err)
+	}
+
+	if err := store.ResetRateWindow(ctx, "pair_start", "10.0.0.1"); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	w, _, err = store.HitRateWindow(ctx, "pair_start", "10.0.0.1", now.Add(time.Minute), time.Minute, 0)
+	if err != nil || w.Hits != 1 {
+		t.Fatalf("after reset: hits=%d err=%v", w.Hits, err)
+	}
+}
+
+func TestStreamEventsReadsSnapshotWithoutBlockingWriters(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "stream.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+	if err := store.Init(ctx); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	if err := store.AppendEvents(ctx, benchEvents("run-1", 1, 5000)); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	var got []int64
+	err = store.StreamEvents(ctx, "run-1", 2, func(ev events.Event) error {
+		if ev.Seq == 2 {
+			// The store stays writable while the cursor is open, and the
+			// cursor keeps its snapshot when events are pruned.
+			if err := store.AppendEvents(ctx, benchEvents("run-2", 1, 1)); err != nil {
+				return err
+			}
+			if _, err := store.db.ExecContext(ctx, `DELETE FROM events WHERE run_id='run-1'`); err != nil {
+				return err
+			}
+		}
+		got = append(got, ev.Seq)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if len(got) != 4999 || got[0] != 2 || got[len(got)-1] != 5000 {
+		t.Fatalf("unexpected stream: %d events", len(got))
+	}
+	if evs, _ := store.ListEvents(ctx, "run-1", 0, 10); len(evs) != 0 {
+		t.Fatalf("expected events to be pruned, got %d", len(evs))
+	}
+}
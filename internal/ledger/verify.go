@@ -0,0 +1,241 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	CheckStaleRun             = "stale_run"
+	CheckOrphanEvents         = "orphan_events"
+	CheckOrphanUsage          = "orphan_usage"
+	CheckAttachmentMissingRun = "attachment_missing_run"
+	CheckAttachmentNoFile     = "attachment_missing_file"
+	CheckFileBlobMissing      = "file_blob_missing"
+	CheckSessionDeviceRevoked = "session_device_revoked"
+
+	maxIntegrityIssues = 500
+)
+
+type VerifyOptions struct {
+	// StaleAfter flags non-terminal runs with no event (or status change)
+	// within this window.
+	StaleAfter time.Duration
+	Now        time.Time
+	// BlobExists reports whether a stored file's content is still present;
+	// nil skips the blob check.
+	BlobExists func(storageKey string) bool
+}
+
+type IntegrityIssue struct {
+	Check   string `json:"check"`
+	Subject string `json:"subject"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+type IntegrityReport struct {
+	OK        bool             `json:"ok"`
+	CheckedAt time.Time        `json:"checked_at"`
+	Counts    map[string]int   `json:"counts"`
+	Issues    []IntegrityIssue `json:"issues"`
+	Truncated bool             `json:"truncated,omitempty"`
+}
+
+func (r *IntegrityReport) add(check, subject, detail string) {
+	r.Counts[check]++
+	if len(r.Issues) >= maxIntegrityIssues {
+		r.Truncated = true
+		return
+	}
+	r.Issues = append(r.Issues, IntegrityIssue{Check: check, Subject: subject, Detail: detail})
+}
+
+// Verify checks cross-table consistency of the ledger. It only reads; fixing
+// the reported rows is left to the operator.
+func (s *Store) Verify(ctx context.Context, opts VerifyOptions) (IntegrityReport, error) {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now().UTC()
+	}
+	if opts.StaleAfter <= 0 {
+		opts.StaleAfter = time.Hour
+	}
+	report := IntegrityReport{
+		CheckedAt: opts.Now,
+		Counts: map[string]int{
+			CheckStaleRun:             0,
+			CheckOrphanEvents:         0,
+			CheckOrphanUsage:          0,
+			CheckAttachmentMissingRun: 0,
+			CheckAttachmentNoFile:     0,
+			CheckSessionDeviceRevoked: 0,
+		},
+		Issues: []IntegrityIssue{},
+	}
+	checks := []func(context.Context, VerifyOptions, *IntegrityReport) error{
+		s.verifyStaleRuns,
+		s.verifyOrphanRows,
+		s.verifyAttachments,
+		s.verifySessions,
+	}
+	if opts.BlobExists != nil {
+		report.Counts[CheckFileBlobMissing] = 0
+		checks = append(checks, s.verifyFileBlobs)
+	}
+	for _, check := range checks {
+		if err := check(ctx, opts, &report); err != nil {
+			return IntegrityReport{}, err
+		}
+	}
+	report.OK = len(report.Issues) == 0
+	return report, nil
+}
+
+func (s *Store) verifyStaleRuns(ctx context.Context, opts VerifyOptions, report *IntegrityReport) error {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT r.run_id, r.status, r.updated_at, COALESCE(MAX(e.ts), '')
+		   FROM runs r
+		   LEFT JOIN events e ON e.run_id = r.run_id
+		  WHERE r.status NOT IN ('completed', 'failed', 'cancelled')
+		  GROUP BY r.run_id, r.status, r.updated_at`,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	cutoff := opts.Now.Add(-opts.StaleAfter)
+	for rows.Next() {
+		var runID, status, updatedAt, lastEvent string
+		if err := rows.Scan(&runID, &status, &updatedAt, &lastEvent); err != nil {
+			return err
+		}
+		last := parseTime(updatedAt)
+		if ts := parseTime(lastEvent); ts.After(last) {
+			last = ts
+		}
+		if last.Before(cutoff) {
+			report.add(CheckStaleRun, runID, fmt.Sprintf("status %s with no activity since %s", status, last.UTC().Format(time.RFC3339)))
+		}
+	}
+	return rows.Err()
+}
+
+func (s *Store) verifyOrphanRows(ctx context.Context, _ VerifyOptions, report *IntegrityReport) error {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT run_id, COUNT(*) FROM events
+		  WHERE run_id NOT IN (SELECT run_id FROM runs)
+		  GROUP BY run_id`,
+	)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var runID string
+		var n int
+		if err := rows.Scan(&runID, &n); err != nil {
+			rows.Close()
+			return err
+		}
+		report.add(CheckOrphanEvents, runID, fmt.Sprintf("%d events reference a missing run", n))
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	rows, err = s.db.QueryContext(ctx, `SELECT run_id FROM run_usage WHERE run_id NOT IN (SELECT run_id FROM runs)`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var runID string
+		if err := rows.Scan(&runID); err != nil {
+			return err
+		}
+		report.add(CheckOrphanUsage, runID, "token usage references a missing run")
+	}
+	return rows.Err()
+}
+
+func (s *Store) verifyAttachments(ctx context.Context, _ VerifyOptions, report *IntegrityReport) error {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT a.run_id, a.alias, a.file_id, r.run_id IS NULL, f.file_id IS NULL
+		   FROM run_attachments a
+		   LEFT JOIN runs r ON r.run_id = a.run_id
+		   LEFT JOIN files f ON f.file_id = a.file_id
+		  WHERE r.run_id IS NULL OR f.file_id IS NULL`,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var runID, alias, fileID string
+		var missingRun, missingFile bool
+		if err := rows.Scan(&runID, &alias, &fileID, &missingRun, &missingFile); err != nil {
+			return err
+		}
+		subject := runID + "/" + alias
+		if missingRun {
+			report.add(CheckAttachmentMissingRun, subject, "attachment references a missing run")
+		}
+		if missingFile {
+			report.add(CheckAttachmentNoFile, subject, fmt.Sprintf("attachment references missing file %s", fileID))
+		}
+	}
+	return rows.Err()
+}
+
+func (s *Store) verifyFileBlobs(ctx context.Context, opts VerifyOptions, report *IntegrityReport) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT file_id, storage_key FROM files`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var fileID, storageKey string
+		if err := rows.Scan(&fileID, &storageKey); err != nil {
+			return err
+		}
+		if !opts.BlobExists(storageKey) {
+			report.add(CheckFileBlobMissing, fileID, "stored file content is missing")
+		}
+	}
+	return rows.Err()
+}
+
+func (s *Store) verifySessions(ctx context.Context, opts VerifyOptions, report *IntegrityReport) error {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT s.session_id, s.address, s.refresh_expires_at, d.address IS NULL, COALESCE(d.revoked, 0)
+		   FROM sessions s
+		   LEFT JOIN devices d ON d.address = s.address
+		  WHERE s.revoked = 0 AND (d.address IS NULL OR d.revoked = 1)`,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sessionID, address, refreshExpiresAt string
+		var missing bool
+		var revoked int
+		if err := rows.Scan(&sessionID, &address, &refreshExpiresAt, &missing, &revoked); err != nil {
+			return err
+		}
+		if exp := parseTime(refreshExpiresAt); !exp.IsZero() && exp.Before(opts.Now) {
+			continue
+		}
+		detail := "active session for revoked device " + address
+		if missing {
+			detail = "active session for unknown device " + address
+		}
+		report.add(CheckSessionDeviceRevoked, sessionID, detail)
+	}
+	return rows.Err()
+}
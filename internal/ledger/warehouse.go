@@ -0,0 +1,140 @@
+package ledger
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type AuditRecord struct {
+	TS     time.Time
+	Event  string
+	Actor  string
+	IP     string
+	Method string
+	Path   string
+	Detail string
+}
+
+func (s *Store) initAuditSchema(ctx context.Context) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS audit_events (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  ts TEXT NOT NULL,
+  event TEXT NOT NULL,
+  actor TEXT NOT NULL DEFAULT '',
+  ip TEXT NOT NULL DEFAULT '',
+  method TEXT NOT NULL DEFAULT '',
+  path TEXT NOT NULL DEFAULT '',
+  detail TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_audit_events_ts ON audit_events(ts);`
+	_, err := s.db.ExecContext(ctx, s.db.d.ddl(schema))
+	return err
+}
+
+func (s *Store) AppendAudit(ctx context.Context, rec AuditRecord) error {
+	if rec.TS.IsZero() {
+		rec.TS = time.Now().UTC()
+	}
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO audit_events(ts, event, actor, ip, method, path, detail) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		rec.TS.UTC().Format(time.RFC3339Nano), rec.Event, rec.Actor, rec.IP, rec.Method, rec.Path, rec.Detail,
+	)
+	return err
+}
+
+// ListAudit returns audit events with from <= ts < to in time order.
+func (s *Store) ListAudit(ctx context.Context, from, to time.Time) ([]AuditRecord, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT ts, event, actor, ip, method, path, detail
+		   FROM audit_events
+		  WHERE ts >= ? AND ts < ?
+		  ORDER BY ts ASC, id ASC`,
+		from.UTC().Format(time.RFC3339Nano),
+		to.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []AuditRecord{}
+	for rows.Next() {
+		var rec AuditRecord
+		var ts string
+		if err := rows.Scan(&ts, &rec.Event, &rec.Actor, &rec.IP, &rec.Method, &rec.Path, &rec.Detail); err != nil {
+			return nil, err
+		}
+		rec.TS = parseTime(ts)
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// ListRunsCreatedBetween returns runs created in [from, to) without their
+// prompt context, ordered by creation time.
+func (s *Store) ListRunsCreatedBetween(ctx context.Context, from, to time.Time) ([]RunRecord, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT run_id, workspace_id, workspace_path, backend, context_json, status, error_text, created_at, updated_at
+		   FROM runs
+		  WHERE created_at >= ? AND created_at < ?
+		  ORDER BY created_at ASC`,
+		from.UTC().Format(time.RFC3339Nano),
+		to.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []RunRecord{}
+	for rows.Next() {
+		var rec RunRecord
+		var ctxJSON, createdAt, updatedAt string
+		if err := rows.Scan(&rec.ID, &rec.WorkspaceID, &rec.Workspace, &rec.Backend, &ctxJSON, &rec.Status, &rec.Error, &createdAt, &updatedAt); err != nil {
+			return nil, err
+		}
+		var persisted persistedContext
+		if err := json.Unmarshal([]byte(ctxJSON), &persisted); err == nil {
+			rec.Options = persisted.Options
+			rec.SubmittedBy = persisted.SubmittedBy
+		}
+		rec.CreatedAt = parseTime(createdAt)
+		rec.UpdatedAt = parseTime(updatedAt)
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// ListTokenUsage returns usage rows recorded in [from, to).
+func (s *Store) ListTokenUsage(ctx context.Context, from, to time.Time) ([]TokenUsageRecord, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT run_id, backend, COALESCE(workspace_id, ''), COALESCE(submitted_by, ''), input_tokens, output_tokens, total_tokens, recorded_at
+		   FROM run_usage
+		  WHERE recorded_at >= ? AND recorded_at < ?
+		  ORDER BY recorded_at ASC`,
+		from.UTC().Format(time.RFC3339Nano),
+		to.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []TokenUsageRecord{}
+	for rows.Next() {
+		var rec TokenUsageRecord
+		var recordedAt string
+		if err := rows.Scan(&rec.RunID, &rec.Backend, &rec.WorkspaceID, &rec.SubmittedBy, &rec.InputTokens, &rec.OutputTokens, &rec.TotalTokens, &recordedAt); err != nil {
+			return nil, err
+		}
+		rec.RecordedAt = parseTime(recordedAt)
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,252 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	ErrEnvProfileNotFound      = errors.New("env profile not found")
+	ErrWorkspaceNotFound       = errors.New("workspace not found")
+	ErrWorkspacePolicyNotFound = errors.New("workspace policy not found")
+)
+
+type EnvProfileRecord struct {
+	WorkspaceID string
+	Env         map[string]string
+	PathPrepend []string
+	ShellInit   string
+	UpdatedBy   string
+	UpdatedAt   time.Time
+}
+
+// WorkspacePolicyRecord is an operator-set workspace rule; RuleJSON is the
+// policy.WorkspaceRule encoded as JSON. WorkspacePath is the root the rule
+// is bound to; rows written before it existed have it empty.
+type WorkspacePolicyRecord struct {
+	WorkspaceID   string
+	WorkspacePath string
+	RuleJSON      string
+	UpdatedBy     string
+	UpdatedAt     time.Time
+}
+
+func (s *Store) initWorkspaceSchema(ctx context.Context) error {
+	schema := `
+CREATE TABLE IF NOT EXISTS workspace_env_profiles (
+  workspace_id TEXT PRIMARY KEY,
+  env_json TEXT NOT NULL DEFAULT '{}',
+  path_prepend_json TEXT NOT NULL DEFAULT '[]',
+  shell_init TEXT NOT NULL DEFAULT '',
+  updated_by TEXT NOT NULL DEFAULT '',
+  updated_at TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS workspace_policies (
+  workspace_id TEXT PRIMARY KEY,
+  rule_json TEXT NOT NULL DEFAULT '{}',
+  updated_by TEXT NOT NULL DEFAULT '',
+  updated_at TEXT NOT NULL
+);`
+	if _, err := s.db.ExecContext(ctx, s.db.d.ddl(schema)); err != nil {
+		return err
+	}
+	return s.ensureColumn(ctx, "workspace_policies", "workspace_path", "TEXT")
+}
+
+func (s *Store) UpsertEnvProfile(ctx context.Context, rec EnvProfileRecord) error {
+	if rec.Env == nil {
+		rec.Env = map[string]string{}
+	}
+	if rec.PathPrepend == nil {
+		rec.PathPrepend = []string{}
+	}
+	if rec.UpdatedAt.IsZero() {
+		rec.UpdatedAt = time.Now().UTC()
+	}
+	envJSON, _ := json.Marshal(rec.Env)
+	pathJSON, _ := json.Marshal(rec.PathPrepend)
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO workspace_env_profiles(workspace_id, env_json, path_prepend_json, shell_init, updated_by, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(workspace_id) DO UPDATE SET
+		   env_json=excluded.env_json,
+		   path_prepend_json=excluded.path_prepend_json,
+		   shell_init=excluded.shell_init,
+		   updated_by=excluded.updated_by,
+		   updated_at=excluded.updated_at`,
+		rec.WorkspaceID,
+		string(envJSON),
+		string(pathJSON),
+		rec.ShellInit,
+		rec.UpdatedBy,
+		rec.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	return err
+}
+
+func (s *Store) GetEnvProfile(ctx context.Context, workspaceID string) (EnvProfileRecord, error) {
+	row := s.db.QueryRowContext(
+		ctx,
+		`SELECT workspace_id, env_json, path_prepend_json, shell_init, updated_by, updated_at
+		 FROM workspace_env_profiles WHERE workspace_id=?`,
+		workspaceID,
+	)
+	rec, err := scanEnvProfile(row.Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return EnvProfileRecord{}, ErrEnvProfileNotFound
+		}
+		return EnvProfileRecord{}, err
+	}
+	return rec, nil
+}
+
+func (s *Store) ListEnvProfiles(ctx context.Context) ([]EnvProfileRecord, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT workspace_id, env_json, path_prepend_json, shell_init, updated_by, updated_at
+		 FROM workspace_env_profiles ORDER BY workspace_id ASC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []EnvProfileRecord{}
+	for rows.Next() {
+		rec, err := scanEnvProfile(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) DeleteEnvProfile(ctx context.Context, workspaceID string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM workspace_env_profiles WHERE workspace_id=?`, workspaceID)
+	if err != nil {
+		return err
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return ErrEnvProfileNotFound
+	}
+	return nil
+}
+
+func (s *Store) UpsertWorkspacePolicy(ctx context.Context, rec WorkspacePolicyRecord) error {
+	if rec.UpdatedAt.IsZero() {
+		rec.UpdatedAt = time.Now().UTC()
+	}
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO workspace_policies(workspace_id, workspace_path, rule_json, updated_by, updated_at)
+		 VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT(workspace_id) DO UPDATE SET
+		   workspace_path=excluded.workspace_path,
+		   rule_json=excluded.rule_json,
+		   updated_by=excluded.updated_by,
+		   updated_at=excluded.updated_at`,
+		rec.WorkspaceID,
+		rec.WorkspacePath,
+		rec.RuleJSON,
+		rec.UpdatedBy,
+		rec.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	return err
+}
+
+func (s *Store) GetWorkspacePolicy(ctx context.Context, workspaceID string) (WorkspacePolicyRecord, error) {
+	row := s.db.QueryRowContext(
+		ctx,
+		`SELECT workspace_id, workspace_path, rule_json, updated_by, updated_at
+		 FROM workspace_policies WHERE workspace_id=?`,
+		workspaceID,
+	)
+	rec, err := scanWorkspacePolicy(row.Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return WorkspacePolicyRecord{}, ErrWorkspacePolicyNotFound
+	}
+	if err != nil {
+		return WorkspacePolicyRecord{}, err
+	}
+	return rec, nil
+}
+
+// ListWorkspacePolicies returns every stored rule, oldest update first.
+func (s *Store) ListWorkspacePolicies(ctx context.Context) ([]WorkspacePolicyRecord, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT workspace_id, workspace_path, rule_json, updated_by, updated_at
+		 FROM workspace_policies ORDER BY updated_at ASC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []WorkspacePolicyRecord{}
+	for rows.Next() {
+		rec, err := scanWorkspacePolicy(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) DeleteWorkspacePolicy(ctx context.Context, workspaceID string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM workspace_policies WHERE workspace_id=?`, workspaceID)
+	if err != nil {
+		return err
+	}
+	affected, _ := res.RowsAffected()
+	if affected == 0 {
+		return ErrWorkspacePolicyNotFound
+	}
+	return nil
+}
+
+// WorkspacePath returns the path of the most recent run submitted for
+// workspaceID.
+func (s *Store) WorkspacePath(ctx context.Context, workspaceID string) (string, error) {
+	var path string
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT workspace_path FROM runs WHERE workspace_id=? ORDER BY created_at DESC LIMIT 1`,
+		workspaceID,
+	).Scan(&path)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrWorkspaceNotFound
+	}
+	return path, err
+}
+
+func scanEnvProfile(scan func(dest ...any) error) (EnvProfileRecord, error) {
+	var rec EnvProfileRecord
+	var envJSON, pathJSON, updatedAt string
+	if err := scan(&rec.WorkspaceID, &envJSON, &pathJSON, &rec.ShellInit, &rec.UpdatedBy, &updatedAt); err != nil {
+		return EnvProfileRecord{}, err
+	}
+	rec.Env = map[string]string{}
+	_ = json.Unmarshal([]byte(envJSON), &rec.Env)
+	rec.PathPrepend = decodeStringArray(pathJSON)
+	rec.UpdatedAt = parseTime(updatedAt)
+	return rec, nil
+}
+
+func scanWorkspacePolicy(scan func(dest ...any) error) (WorkspacePolicyRecord, error) {
+	var rec WorkspacePolicyRecord
+	var updatedAt string
+	if err := scan(&rec.WorkspaceID, &rec.WorkspacePath, &rec.RuleJSON, &rec.UpdatedBy, &updatedAt); err != nil {
+		return WorkspacePolicyRecord{}, err
+	}
+	rec.UpdatedAt = parseTime(updatedAt)
+	return rec, nil
+}
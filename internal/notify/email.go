@@ -0,0 +1,168 @@
+// Package notify delivers bridge notifications by email, for teams without
+// a webhook receiver or chat integration.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TLSStartTLS = "starttls"
+	TLSImplicit = "tls"
+	TLSNone     = "none"
+)
+
+const defaultSendTimeout = 30 * time.Second
+
+// SMTPConfig describes the relay used for notification email. A zero Port
+// means 465 with TLS "tls" and 587 otherwise.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	To       []string
+	// TLS is "starttls" (default), "tls" for implicit TLS or "none". Auth
+	// over "none" is only allowed to localhost.
+	TLS string
+	// SubjectPrefix is prepended to every subject, e.g. "[elix]".
+	SubjectPrefix string
+}
+
+func (c SMTPConfig) Enabled() bool {
+	return c.Host != "" && c.From != "" && len(c.To) > 0
+}
+
+// Mailer sends plain-text notification email through an SMTP relay.
+type Mailer struct {
+	cfg  SMTPConfig
+	addr string
+}
+
+func NewMailer(cfg SMTPConfig) (*Mailer, error) {
+	cfg.Host = strings.TrimSpace(cfg.Host)
+	cfg.TLS = strings.ToLower(strings.TrimSpace(cfg.TLS))
+	if cfg.TLS == "" {
+		cfg.TLS = TLSStartTLS
+	}
+	switch cfg.TLS {
+	case TLSStartTLS, TLSImplicit, TLSNone:
+	default:
+		return nil, fmt.Errorf("notify: unknown SMTP TLS mode %q", cfg.TLS)
+	}
+	if !cfg.Enabled() {
+		return nil, errors.New("notify: SMTP host, from and to are required")
+	}
+	for _, addr := range append([]string{cfg.From}, cfg.To...) {
+		if strings.ContainsAny(addr, "\r\n") {
+			return nil, fmt.Errorf("notify: invalid address %q", addr)
+		}
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+		if cfg.TLS == TLSImplicit {
+			cfg.Port = 465
+		}
+	}
+	return &Mailer{cfg: cfg, addr: net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))}, nil
+}
+
+// Send delivers one message to every configured recipient.
+func (m *Mailer) Send(ctx context.Context, subject, body string) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultSendTimeout)
+		defer cancel()
+	}
+	dialer := &net.Dialer{}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if m.cfg.TLS == TLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: m.tlsConfig()}).DialContext(ctx, "tcp", m.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", m.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("notify: dial %s: %w", m.addr, err)
+	}
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("notify: smtp greeting: %w", err)
+	}
+	defer c.Close()
+	if m.cfg.TLS == TLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("notify: SMTP server does not offer STARTTLS")
+		}
+		if err := c.StartTLS(m.tlsConfig()); err != nil {
+			return fmt.Errorf("notify: starttls: %w", err)
+		}
+	}
+	if m.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)); err != nil {
+			return fmt.Errorf("notify: smtp auth: %w", err)
+		}
+	}
+	if err := c.Mail(m.cfg.From); err != nil {
+		return fmt.Errorf("notify: mail from: %w", err)
+	}
+	for _, to := range m.cfg.To {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("notify: rcpt %s: %w", to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("notify: data: %w", err)
+	}
+	if _, err := w.Write(m.message(subject, body, time.Now())); err != nil {
+		w.Close()
+		return fmt.Errorf("notify: write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("notify: send message: %w", err)
+	}
+	return c.Quit()
+}
+
+func (m *Mailer) tlsConfig() *tls.Config {
+	return &tls.Config{ServerName: m.cfg.Host, MinVersion: tls.VersionTLS12}
+}
+
+func (m *Mailer) message(subject, body string, now time.Time) []byte {
+	if m.cfg.SubjectPrefix != "" {
+		subject = m.cfg.SubjectPrefix + " " + subject
+	}
+	subject = strings.Join(strings.Fields(subject), " ")
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", m.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(m.cfg.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	if !strings.HasSuffix(body, "\n") {
+		b.WriteString("\r\n")
+	}
+	return b.Bytes()
+}
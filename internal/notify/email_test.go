@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeSMTP accepts one session and returns the envelope and message data.
+func fakeSMTP(t *testing.T) (addr string, got chan []string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	got = make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		var lines []string
+		_ = tp.PrintfLine("220 fake ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.Fields(line + " ")[0])
+			switch cmd {
+			case "EHLO", "HELO":
+				_ = tp.PrintfLine("250 fake")
+			case "MAIL", "RCPT":
+				lines = append(lines, line)
+				_ = tp.PrintfLine("250 ok")
+			case "DATA":
+				_ = tp.PrintfLine("354 go ahead")
+				data, err := tp.ReadDotLines()
+				if err != nil {
+					return
+				}
+				lines = append(lines, data...)
+				_ = tp.PrintfLine("250 queued")
+			case "QUIT":
+				_ = tp.PrintfLine("221 bye")
+				got <- lines
+				return
+			default:
+				_ = tp.PrintfLine("502 unsupported")
+			}
+		}
+	}()
+	return ln.Addr().String(), got
+}
+
+func TestMailerSendsPlainTextMessage(t *testing.T) {
+	addr, got := fakeSMTP(t)
+	host, port, _ := net.SplitHostPort(addr)
+	portNum, _ := strconv.Atoi(port)
+	m, err := NewMailer(SMTPConfig{Host: host, Port: portNum, From: "bridge@example.com", To: []string{"a@example.com", "b@example.com"}, TLS: TLSNone, SubjectPrefix: "[elix]"})
+	if err != nil {
+		t.Fatalf("new mailer: %v", err)
+	}
+	if err := m.Send(context.Background(), "Run failed:\r\nBcc: x@example.com", "line one\n.dot line\n"); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	lines := <-got
+	joined := strings.Join(lines, "\n")
+	for _, want := range []string{
+		"MAIL FROM:<bridge@example.com>",
+		"RCPT TO:<a@example.com>",
+		"RCPT TO:<b@example.com>",
+		"Subject: [elix] Run failed: Bcc: x@example.com",
+		"Content-Type: text/plain; charset=utf-8",
+		"line one\n.dot line",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("message missing %q:\n%s", want, joined)
+		}
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "Bcc:") {
+			t.Fatalf("subject injected a header: %q", line)
+		}
+	}
+}
+
+func TestNewMailerRejectsIncompleteConfig(t *testing.T) {
+	if _, err := NewMailer(SMTPConfig{Host: "smtp.example.com", From: "bridge@example.com"}); err == nil {
+		t.Fatalf("expected error without recipients")
+	}
+	if _, err := NewMailer(SMTPConfig{Host: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}, TLS: "ssl3"}); err == nil {
+		t.Fatalf("expected error for unknown TLS mode")
+	}
+	m, err := NewMailer(SMTPConfig{Host: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}, TLS: TLSImplicit})
+	if err != nil || m.addr != "smtp.example.com:465" {
+		t.Fatalf("expected implicit TLS default port 465, got %v %v", m, err)
+	}
+}
@@ -0,0 +1,43 @@
+package policy
+
+import (
+	"time"
+)
+
+// RunExtensionLimits caps run deadline extensions: MaxExtension per request
+// and MaxTotal over the life of a run. A zero MaxTotal disables extensions.
+type RunExtensionLimits struct {
+	MaxExtension time.Duration
+	MaxTotal     time.Duration
+}
+
+func (p *Policy) SetRunExtensionLimits(limits RunExtensionLimits) {
+	p.mu.Lock()
+	p.extension = limits
+	p.mu.Unlock()
+}
+
+func (p *Policy) RunExtensionLimits() RunExtensionLimits {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.extension
+}
+
+// ValidateRunExtension checks a request to extend a run by `by` when it has
+// already been extended by `extended` in total.
+func (p *Policy) ValidateRunExtension(by, extended time.Duration) error {
+	limits := p.RunExtensionLimits()
+	if by <= 0 {
+		return violationf("extension must be positive")
+	}
+	if limits.MaxTotal <= 0 {
+		return violationf("run deadline extensions are disabled")
+	}
+	if limits.MaxExtension > 0 && by > limits.MaxExtension {
+		return violationf("extension exceeds the %s per-request limit", limits.MaxExtension)
+	}
+	if extended+by > limits.MaxTotal {
+		return violationf("extension would exceed the %s total limit (already extended %s)", limits.MaxTotal, extended)
+	}
+	return nil
+}
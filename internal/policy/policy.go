@@ -1,14 +1,26 @@
 package policy
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 )
 
 type Policy struct {
 	WorkspaceRoots []string
+
+	mu         sync.RWMutex
+	injections map[string]PromptInjection
+	retention  map[string]WorkspaceRetention
+	extension  RunExtensionLimits
+	// maxRunTimeout bounds RunOptions.TimeoutSeconds on submit.
+	maxRunTimeout  time.Duration
+	workspaceRules map[string]WorkspaceRule
+	ruleLookup     WorkspaceRuleLookup
 }
 
 type RunOptions struct {
@@ -18,21 +30,55 @@ type RunOptions struct {
 	SchemaVersion string
 }
 
+// ErrViolation matches every error for a request the policy rejects, so
+// callers can tell policy decisions from other failures.
+var ErrViolation = errors.New("policy violation")
+
+type violationError struct {
+	msg string
+}
+
+func (e *violationError) Error() string {
+	return e.msg
+}
+
+func (e *violationError) Is(target error) bool {
+	return target == ErrViolation
+}
+
+func violationf(format string, args ...any) error {
+	return &violationError{msg: fmt.Sprintf(format, args...)}
+}
+
 var safeOptionValue = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
 
 func New(roots []string) *Policy {
 	return &Policy{WorkspaceRoots: roots}
 }
 
+// SetWorkspaceRoots replaces the allowed workspace roots, for a config
+// reload.
+func (p *Policy) SetWorkspaceRoots(roots []string) {
+	p.mu.Lock()
+	p.WorkspaceRoots = append([]string(nil), roots...)
+	p.mu.Unlock()
+}
+
+func (p *Policy) Roots() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.WorkspaceRoots
+}
+
 func (p *Policy) ValidateWorkspace(path string) error {
 	if path == "" {
-		return fmt.Errorf("workspace_path is required")
+		return violationf("workspace_path is required")
 	}
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		return fmt.Errorf("resolve workspace path: %w", err)
 	}
-	for _, root := range p.WorkspaceRoots {
+	for _, root := range p.Roots() {
 		absRoot, err := filepath.Abs(root)
 		if err != nil {
 			continue
@@ -41,28 +87,24 @@ func (p *Policy) ValidateWorkspace(path string) error {
 			return nil
 		}
 	}
-	return fmt.Errorf("workspace path %q is outside allowed roots", absPath)
+	return violationf("workspace path %q is outside allowed roots", absPath)
 }
 
 func (p *Policy) ValidateRunOptions(opts RunOptions) error {
 	if opts.Model != "" && !safeOptionValue.MatchString(opts.Model) {
-		return fmt.Errorf("invalid model option")
+		return violationf("invalid model option")
 	}
 	if opts.Profile != "" && !safeOptionValue.MatchString(opts.Profile) {
-		return fmt.Errorf("invalid profile option")
+		return violationf("invalid profile option")
 	}
-	if opts.Sandbox != "" {
-		switch opts.Sandbox {
-		case "read-only", "workspace-write", "danger-full-access":
-		default:
-			return fmt.Errorf("invalid sandbox option")
-		}
+	if opts.Sandbox != "" && !validSandbox(opts.Sandbox) {
+		return violationf("invalid sandbox option")
 	}
 	if opts.SchemaVersion != "" {
 		switch opts.SchemaVersion {
-		case "v1", "v2":
+		case "v1", "v2", "v3":
 		default:
-			return fmt.Errorf("invalid schema_version option")
+			return violationf("invalid schema_version option")
 		}
 	}
 	return nil
@@ -1,6 +1,8 @@
 package policy
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -84,7 +86,7 @@ func TestValidateRunOptions(t *testing.T) {
 		},
 		{
 			name: "invalid schema version",
-			opts: RunOptions{SchemaVersion: "v3"},
+			opts: RunOptions{SchemaVersion: "v4"},
 			msg:  "schema_version",
 		},
 		{
@@ -108,3 +110,123 @@ func TestValidateRunOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestPromptInjectionResolution(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	p := New([]string{root})
+	if got := p.PromptInjectionFor("ws-1", root); !got.Empty() {
+		t.Fatalf("expected no injection without rules, got %#v", got)
+	}
+
+	nested := filepath.Join(root, "team")
+	p.SetPromptInjections(map[string]PromptInjection{
+		"*":    {Prefix: "global"},
+		root:   {Prefix: "root"},
+		nested: {Suffix: "team"},
+		"ws-1": {Prefix: "by id"},
+		"ws-2": {Prefix: "  ", Suffix: ""},
+	})
+
+	cases := []struct {
+		id, path, source string
+	}{
+		{"ws-1", nested, "ws-1"},
+		{"ws-2", filepath.Join(nested, "app"), nested},
+		{"", filepath.Join(root, "other"), root},
+		{"", t.TempDir(), "*"},
+	}
+	for _, tc := range cases {
+		if got := p.PromptInjectionFor(tc.id, tc.path); got.Source != tc.source {
+			t.Fatalf("id=%q path=%q: expected source %q, got %q", tc.id, tc.path, tc.source, got.Source)
+		}
+	}
+
+	got := p.PromptInjectionFor("ws-1", "")
+	if !strings.Contains(got.Prefix, `position="prefix" source="ws-1"`) || !strings.Contains(got.Prefix, "by id") || got.Suffix != "" {
+		t.Fatalf("unexpected marked prefix: %#v", got)
+	}
+	if wrapped := got.Wrap("task"); !strings.HasPrefix(wrapped, got.Prefix) || !strings.HasSuffix(wrapped, "\n\ntask") {
+		t.Fatalf("unexpected wrapped prompt: %q", wrapped)
+	}
+}
+
+func TestEnforceWorkspaceRule(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	p := New([]string{root})
+	p.SetWorkspaceRules(map[string]WorkspaceRule{
+		"*":  {Sandboxes: []string{"workspace-write", "read-only"}},
+		root: {ReadOnly: true, Approval: "on-request"},
+	})
+	stored := filepath.Join(root, "stored")
+	p.SetWorkspaceRuleLookup(func(context.Context) (map[string]WorkspaceRule, error) {
+		return map[string]WorkspaceRule{CanonicalPath(stored): {Backends: []string{"codex"}}}, nil
+	})
+	ctx := context.Background()
+
+	got, err := p.EnforceWorkspaceRule(ctx, WorkspaceRequest{WorkspacePath: filepath.Join(root, "app"), Backend: "codex", Approval: "never"})
+	if err != nil || got.Sandbox != SandboxReadOnly || got.Approval != "on-request" {
+		t.Fatalf("expected read-only default and forced approval, got %#v err=%v", got, err)
+	}
+	if _, err := p.EnforceWorkspaceRule(ctx, WorkspaceRequest{WorkspacePath: root, Sandbox: "workspace-write"}); !errors.Is(err, ErrViolation) {
+		t.Fatalf("expected read-only workspace to reject workspace-write, got %v", err)
+	}
+	got, err = p.EnforceWorkspaceRule(ctx, WorkspaceRequest{WorkspacePath: t.TempDir()})
+	if err != nil || got.Sandbox != "workspace-write" {
+		t.Fatalf("expected the first allowed sandbox as default, got %#v err=%v", got, err)
+	}
+	if _, err := p.EnforceWorkspaceRule(ctx, WorkspaceRequest{WorkspacePath: t.TempDir(), Sandbox: "danger-full-access"}); !errors.Is(err, ErrViolation) {
+		t.Fatalf("expected disallowed sandbox to be rejected, got %v", err)
+	}
+	// The stored rule replaces the file rule below its path, whatever the
+	// workspace ID.
+	got, err = p.EnforceWorkspaceRule(ctx, WorkspaceRequest{WorkspaceID: "anything", WorkspacePath: filepath.Join(stored, "sub"), Backend: "codex", Sandbox: "danger-full-access"})
+	if err != nil || got.Sandbox != "danger-full-access" {
+		t.Fatalf("expected stored rule to apply, got %#v err=%v", got, err)
+	}
+	if _, err := p.EnforceWorkspaceRule(ctx, WorkspaceRequest{WorkspacePath: stored, Backend: "gemini"}); !errors.Is(err, ErrViolation) {
+		t.Fatalf("expected backend outside the stored rule to be rejected, got %v", err)
+	}
+	if err := (WorkspaceRule{Approval: "sometimes"}).Validate(); err == nil {
+		t.Fatalf("expected invalid approval policy to be rejected")
+	}
+}
+
+func TestWorkspacePathRuleWinsOverIDRule(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	restricted := filepath.Join(root, "restricted")
+	if err := os.MkdirAll(filepath.Join(restricted, "app"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(root, "link")
+	if err := os.Symlink(restricted, link); err != nil {
+		t.Fatal(err)
+	}
+	p := New([]string{root})
+	p.SetWorkspaceRules(map[string]WorkspaceRule{
+		"lenient":  {Sandboxes: []string{"danger-full-access"}, Backends: []string{"codex", "gemini"}},
+		restricted: {ReadOnly: true, Backends: []string{"codex"}},
+	})
+	ctx := context.Background()
+
+	for _, path := range []string{filepath.Join(restricted, "app"), filepath.Join(link, "app")} {
+		req := WorkspaceRequest{WorkspaceID: "lenient", WorkspacePath: path, Backend: "codex", Sandbox: "danger-full-access"}
+		if _, err := p.EnforceWorkspaceRule(ctx, req); !errors.Is(err, ErrViolation) {
+			t.Fatalf("%s: expected the read-only path rule to win, got %v", path, err)
+		}
+		req.Sandbox, req.Backend = "", "gemini"
+		if _, err := p.EnforceWorkspaceRule(ctx, req); !errors.Is(err, ErrViolation) {
+			t.Fatalf("%s: expected the path rule's backends to apply, got %v", path, err)
+		}
+	}
+	// Outside any path rule the ID rule still applies.
+	got, err := p.EnforceWorkspaceRule(ctx, WorkspaceRequest{WorkspaceID: "lenient", WorkspacePath: t.TempDir(), Backend: "gemini"})
+	if err != nil || got.Sandbox != "danger-full-access" {
+		t.Fatalf("expected the ID rule outside the root, got %#v err=%v", got, err)
+	}
+}
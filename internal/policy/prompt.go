@@ -0,0 +1,132 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PromptInjection is operator text wrapped around every run prompt and
+// session turn of a workspace.
+type PromptInjection struct {
+	Prefix string `json:"prefix,omitempty"`
+	Suffix string `json:"suffix,omitempty"`
+}
+
+// InjectedPrompt carries the marked blocks to place around a prompt. Source
+// names the rule that matched: a workspace ID, a path root or "*".
+type InjectedPrompt struct {
+	Source string
+	Prefix string
+	Suffix string
+}
+
+func (in InjectedPrompt) Empty() bool {
+	return in.Prefix == "" && in.Suffix == ""
+}
+
+// Wrap returns prompt with the prefix and suffix blocks around it.
+func (in InjectedPrompt) Wrap(prompt string) string {
+	parts := make([]string, 0, 3)
+	if in.Prefix != "" {
+		parts = append(parts, in.Prefix)
+	}
+	parts = append(parts, prompt)
+	if in.Suffix != "" {
+		parts = append(parts, in.Suffix)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// LoadPromptInjections reads a JSON object keyed by workspace ID, absolute
+// workspace root path or "*" for every workspace.
+func LoadPromptInjections(path string) (map[string]PromptInjection, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read prompt injections: %w", err)
+	}
+	var rules map[string]PromptInjection
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return nil, fmt.Errorf("parse prompt injections: %w", err)
+	}
+	for key := range rules {
+		if strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("parse prompt injections: empty workspace key")
+		}
+	}
+	return rules, nil
+}
+
+func (p *Policy) SetPromptInjections(rules map[string]PromptInjection) {
+	cleaned := make(map[string]PromptInjection, len(rules))
+	for key, rule := range rules {
+		rule.Prefix = strings.TrimSpace(rule.Prefix)
+		rule.Suffix = strings.TrimSpace(rule.Suffix)
+		if rule.Prefix == "" && rule.Suffix == "" {
+			continue
+		}
+		cleaned[strings.TrimSpace(key)] = rule
+	}
+	p.mu.Lock()
+	p.injections = cleaned
+	p.mu.Unlock()
+}
+
+// PromptInjectionFor resolves the rule for a workspace: an exact workspace
+// ID match wins, then the longest root path containing workspacePath, then
+// "*". The returned blocks are marked so persisted prompts show exactly
+// what the operator injected.
+func (p *Policy) PromptInjectionFor(workspaceID, workspacePath string) InjectedPrompt {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.injections) == 0 {
+		return InjectedPrompt{}
+	}
+	source, rule, ok := matchWorkspaceRule(p.injections, workspaceID, workspacePath)
+	if !ok {
+		return InjectedPrompt{}
+	}
+	out := InjectedPrompt{Source: source}
+	if rule.Prefix != "" {
+		out.Prefix = markInjection("prefix", source, rule.Prefix)
+	}
+	if rule.Suffix != "" {
+		out.Suffix = markInjection("suffix", source, rule.Suffix)
+	}
+	return out
+}
+
+// matchWorkspaceRule picks the rule keyed by workspaceID, else the longest
+// absolute root containing workspacePath, else "*".
+func matchWorkspaceRule[T any](rules map[string]T, workspaceID, workspacePath string) (string, T, bool) {
+	if workspaceID != "" {
+		if rule, ok := rules[workspaceID]; ok {
+			return workspaceID, rule, true
+		}
+	}
+	var rule T
+	source, ok := "", false
+	if workspacePath != "" {
+		if absPath, err := filepath.Abs(workspacePath); err == nil {
+			for key, candidate := range rules {
+				if !filepath.IsAbs(key) || len(key) <= len(source) {
+					continue
+				}
+				if isWithinRoot(filepath.Clean(key), absPath) || isWithinRoot(CanonicalPath(key), absPath) {
+					source, rule, ok = key, candidate, true
+				}
+			}
+		}
+	}
+	if !ok {
+		rule, ok = rules["*"]
+		source = "*"
+	}
+	return source, rule, ok
+}
+
+func markInjection(position, source, text string) string {
+	return fmt.Sprintf("<policy-injection position=%q source=%q>\n%s\n</policy-injection>", position, source, text)
+}
@@ -0,0 +1,93 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Export redaction levels.
+const (
+	RedactionNone = "none"
+	// RedactionPrompts removes run prompts and context from exports.
+	RedactionPrompts = "prompts"
+	// RedactionContent also strips event payloads down to their status.
+	RedactionContent = "content"
+)
+
+// WorkspaceRetention overrides the global retention settings for a
+// workspace. Zero fields keep the global setting.
+type WorkspaceRetention struct {
+	EventRetentionDays      int    `json:"event_retention_days,omitempty"`
+	MaxEventsPerRun         int    `json:"max_events_per_run,omitempty"`
+	RunRetentionDays        int    `json:"run_retention_days,omitempty"`
+	TranscriptRetentionDays int    `json:"transcript_retention_days,omitempty"`
+	Redaction               string `json:"redaction,omitempty"`
+}
+
+func (r WorkspaceRetention) EventRetention() time.Duration {
+	return time.Duration(r.EventRetentionDays) * 24 * time.Hour
+}
+
+func (r WorkspaceRetention) RunRetention() time.Duration {
+	return time.Duration(r.RunRetentionDays) * 24 * time.Hour
+}
+
+func (r WorkspaceRetention) TranscriptRetention() time.Duration {
+	return time.Duration(r.TranscriptRetentionDays) * 24 * time.Hour
+}
+
+// LoadWorkspaceRetention reads a JSON object keyed like the prompt
+// injections file: workspace ID, absolute workspace root path or "*".
+func LoadWorkspaceRetention(path string) (map[string]WorkspaceRetention, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read workspace retention: %w", err)
+	}
+	var rules map[string]WorkspaceRetention
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return nil, fmt.Errorf("parse workspace retention: %w", err)
+	}
+	for key, rule := range rules {
+		if strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("parse workspace retention: empty workspace key")
+		}
+		if rule.EventRetentionDays < 0 || rule.MaxEventsPerRun < 0 || rule.RunRetentionDays < 0 || rule.TranscriptRetentionDays < 0 {
+			return nil, fmt.Errorf("parse workspace retention: %s: limits must not be negative", key)
+		}
+		switch rule.Redaction {
+		case "", RedactionNone, RedactionPrompts, RedactionContent:
+		default:
+			return nil, fmt.Errorf("parse workspace retention: %s: invalid redaction %q", key, rule.Redaction)
+		}
+	}
+	return rules, nil
+}
+
+func (p *Policy) SetWorkspaceRetention(rules map[string]WorkspaceRetention) {
+	cleaned := make(map[string]WorkspaceRetention, len(rules))
+	for key, rule := range rules {
+		cleaned[strings.TrimSpace(key)] = rule
+	}
+	p.mu.Lock()
+	p.retention = cleaned
+	p.mu.Unlock()
+}
+
+// HasRetentionOverrides reports whether any workspace retention rule is set.
+func (p *Policy) HasRetentionOverrides() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.retention) > 0
+}
+
+// RetentionFor resolves the retention override of a workspace the same way
+// as PromptInjectionFor. It returns the zero value when no rule matches.
+func (p *Policy) RetentionFor(workspaceID, workspacePath string) WorkspaceRetention {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	_, rule, _ := matchWorkspaceRule(p.retention, workspaceID, workspacePath)
+	return rule
+}
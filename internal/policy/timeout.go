@@ -0,0 +1,35 @@
+package policy
+
+import "time"
+
+// SetMaxRunTimeout caps per-run timeout overrides; zero disables them.
+func (p *Policy) SetMaxRunTimeout(limit time.Duration) {
+	p.mu.Lock()
+	p.maxRunTimeout = limit
+	p.mu.Unlock()
+}
+
+func (p *Policy) MaxRunTimeout() time.Duration {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.maxRunTimeout
+}
+
+// ValidateRunTimeout checks a run's requested timeout; zero means the
+// bridge default and is always allowed.
+func (p *Policy) ValidateRunTimeout(timeout time.Duration) error {
+	if timeout == 0 {
+		return nil
+	}
+	if timeout < 0 {
+		return violationf("timeout_seconds must not be negative")
+	}
+	limit := p.MaxRunTimeout()
+	if limit <= 0 {
+		return violationf("per-run timeouts are disabled")
+	}
+	if timeout > limit {
+		return violationf("timeout_seconds exceeds the %s maximum", limit)
+	}
+	return nil
+}
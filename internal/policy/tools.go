@@ -0,0 +1,47 @@
+package policy
+
+import (
+	"strings"
+)
+
+// KnownTools are the tool kinds a run may require from its backend.
+var KnownTools = []string{"shell", "web", "editor"}
+
+// ValidateRequiredTools checks that a backend declaring the available tool
+// inventory can serve a run requiring the given tools. A backend that
+// declares no inventory cannot satisfy any requirement, since the run would
+// otherwise be submitted on a guess.
+func (p *Policy) ValidateRequiredTools(backend string, required, available []string) error {
+	if len(required) == 0 {
+		return nil
+	}
+	have := map[string]bool{}
+	for _, tool := range available {
+		have[strings.ToLower(tool)] = true
+	}
+	var missing []string
+	for _, tool := range required {
+		if !isKnownTool(tool) {
+			return violationf("invalid required tool %q", tool)
+		}
+		if !have[tool] {
+			missing = append(missing, tool)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if available == nil {
+		return violationf("backend %s does not declare its tools", backend)
+	}
+	return violationf("backend %s lacks required tools: %s", backend, strings.Join(missing, ", "))
+}
+
+func isKnownTool(tool string) bool {
+	for _, known := range KnownTools {
+		if tool == known {
+			return true
+		}
+	}
+	return false
+}
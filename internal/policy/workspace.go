@@ -0,0 +1,196 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SandboxReadOnly is the sandbox level a read-only workspace is held to.
+const SandboxReadOnly = "read-only"
+
+// WorkspaceRule restricts runs and sessions in a workspace. Empty fields
+// leave the request alone.
+type WorkspaceRule struct {
+	// Sandboxes lists the allowed sandbox levels; the first one applies
+	// when a request names none.
+	Sandboxes []string `json:"sandboxes,omitempty"`
+	// Approval replaces the approval policy of session threads and turns.
+	Approval string   `json:"approval_policy,omitempty"`
+	Backends []string `json:"backends,omitempty"`
+	// ReadOnly holds every run and session to the read-only sandbox.
+	ReadOnly bool `json:"read_only,omitempty"`
+}
+
+func (r WorkspaceRule) Empty() bool {
+	return len(r.Sandboxes) == 0 && r.Approval == "" && len(r.Backends) == 0 && !r.ReadOnly
+}
+
+// Validate checks the rule's sandbox levels and approval policy.
+func (r WorkspaceRule) Validate() error {
+	for _, sb := range r.Sandboxes {
+		if !validSandbox(sb) {
+			return fmt.Errorf("invalid sandbox %q", sb)
+		}
+	}
+	switch r.Approval {
+	case "", "untrusted", "on-failure", "on-request", "never":
+	default:
+		return fmt.Errorf("invalid approval_policy %q", r.Approval)
+	}
+	for _, b := range r.Backends {
+		if strings.TrimSpace(b) == "" {
+			return fmt.Errorf("backends must not contain empty names")
+		}
+	}
+	return nil
+}
+
+// WorkspaceRuleLookup returns the stored rules keyed by canonical workspace
+// root (see CanonicalPath). Stored rules take precedence over the ones
+// loaded from a file.
+type WorkspaceRuleLookup func(ctx context.Context) (map[string]WorkspaceRule, error)
+
+// WorkspaceRequest is the part of a run submit, session create or turn a
+// workspace rule applies to.
+type WorkspaceRequest struct {
+	WorkspaceID   string
+	WorkspacePath string
+	Backend       string
+	Sandbox       string
+	Approval      string
+}
+
+// LoadWorkspaceRules reads a JSON object keyed like the prompt injections
+// file: workspace ID, absolute workspace root path or "*".
+func LoadWorkspaceRules(path string) (map[string]WorkspaceRule, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read workspace policies: %w", err)
+	}
+	var rules map[string]WorkspaceRule
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return nil, fmt.Errorf("parse workspace policies: %w", err)
+	}
+	for key, rule := range rules {
+		if strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("parse workspace policies: empty workspace key")
+		}
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("parse workspace policies: %s: %w", key, err)
+		}
+	}
+	return rules, nil
+}
+
+func (p *Policy) SetWorkspaceRules(rules map[string]WorkspaceRule) {
+	cleaned := make(map[string]WorkspaceRule, len(rules))
+	for key, rule := range rules {
+		cleaned[strings.TrimSpace(key)] = rule
+	}
+	p.mu.Lock()
+	p.workspaceRules = cleaned
+	p.mu.Unlock()
+}
+
+func (p *Policy) SetWorkspaceRuleLookup(lookup WorkspaceRuleLookup) {
+	p.mu.Lock()
+	p.ruleLookup = lookup
+	p.mu.Unlock()
+}
+
+// CanonicalPath returns path absolute, cleaned and with symlinks resolved
+// where it exists, so a stored rule and the requests it covers compare
+// the same directory.
+func CanonicalPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved
+	}
+	return abs
+}
+
+// WorkspaceRuleFor resolves the rule of a workspace: a stored rule for the
+// deepest root holding the workspace path wins, then the file rule of the
+// deepest such root, then the file rule keyed by workspace ID, then "*".
+// Paths are compared with symlinks resolved. Stored rules are bound to
+// paths, not to the client-supplied workspace ID. source is "stored" for a stored rule,
+// else the matching file key; it is empty when no rule applies.
+func (p *Policy) WorkspaceRuleFor(ctx context.Context, workspaceID, workspacePath string) (string, WorkspaceRule, error) {
+	p.mu.RLock()
+	lookup := p.ruleLookup
+	p.mu.RUnlock()
+	if lookup != nil && workspacePath != "" {
+		stored, err := lookup(ctx)
+		if err != nil {
+			return "", WorkspaceRule{}, fmt.Errorf("load workspace policy: %w", err)
+		}
+		if _, rule, ok := matchWorkspaceRule(stored, "", CanonicalPath(workspacePath)); ok {
+			return "stored", rule, nil
+		}
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	// A path rule wins over one keyed by the client-supplied ID, so naming
+	// a lenient workspace ID cannot lift the restrictions of a root.
+	if workspacePath != "" {
+		if source, rule, ok := matchWorkspaceRule(p.workspaceRules, "", CanonicalPath(workspacePath)); ok && source != "*" {
+			return source, rule, nil
+		}
+	}
+	source, rule, ok := matchWorkspaceRule(p.workspaceRules, workspaceID, "")
+	if !ok {
+		return "", WorkspaceRule{}, nil
+	}
+	return source, rule, nil
+}
+
+// EnforceWorkspaceRule checks req against its workspace's rule and returns
+// it with the rule's sandbox default and forced approval policy applied.
+func (p *Policy) EnforceWorkspaceRule(ctx context.Context, req WorkspaceRequest) (WorkspaceRequest, error) {
+	source, rule, err := p.WorkspaceRuleFor(ctx, req.WorkspaceID, req.WorkspacePath)
+	if err != nil || source == "" {
+		return req, err
+	}
+	if len(rule.Backends) > 0 && !containsFold(rule.Backends, req.Backend) {
+		return req, violationf("backend %s is not allowed in this workspace", req.Backend)
+	}
+	allowed := rule.Sandboxes
+	if rule.ReadOnly {
+		allowed = []string{SandboxReadOnly}
+	}
+	if len(allowed) > 0 {
+		if req.Sandbox == "" {
+			req.Sandbox = allowed[0]
+		} else if !containsFold(allowed, req.Sandbox) {
+			return req, violationf("sandbox %s is not allowed in this workspace (allowed: %s)", req.Sandbox, strings.Join(allowed, ", "))
+		}
+	}
+	if rule.Approval != "" {
+		req.Approval = rule.Approval
+	}
+	return req, nil
+}
+
+func validSandbox(v string) bool {
+	switch v {
+	case SandboxReadOnly, "workspace-write", "danger-full-access":
+		return true
+	}
+	return false
+}
+
+func containsFold(list []string, v string) bool {
+	for _, item := range list {
+		if strings.EqualFold(strings.TrimSpace(item), v) {
+			return true
+		}
+	}
+	return false
+}
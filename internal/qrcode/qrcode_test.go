@@ -0,0 +1,308 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomonMatchesReferenceBlock(t *testing.T) {
+	// Version 1-M "HELLO WORLD" from the ISO/IEC 18004 worked example.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := reedSolomon(data, 10); !bytes.Equal(got, want) {
+		t.Fatalf("ec codewords = %v, want %v", got, want)
+	}
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	if got := formatBits(5); got != 0b100000011001110 {
+		t.Fatalf("format bits M/5 = %015b", got)
+	}
+	if got := versionBits(7); got != 0b000111110010010100 {
+		t.Fatalf("version bits 7 = %018b", got)
+	}
+}
+
+func TestEncodeRoundTripsCodewords(t *testing.T) {
+	for _, text := range []string{"hi", "elix://127.0.0.1:8765/pair#" + strings.Repeat("challenge=abcdef&", 8)} {
+		c, err := Encode(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Size != 17+4*c.Version {
+			t.Fatalf("size %d for version %d", c.Size, c.Version)
+		}
+
+		// Read the format bits back, then undo the mask and read the
+		// codewords in placement order.
+		bits := 0
+		for i := 0; i <= 5; i++ {
+			bits |= b2i(c.Dark(8, i)) << i
+		}
+		bits |= b2i(c.Dark(8, 7))<<6 | b2i(c.Dark(8, 8))<<7 | b2i(c.Dark(7, 8))<<8
+		for i := 9; i < 15; i++ {
+			bits |= b2i(c.Dark(14-i, 8)) << i
+		}
+		mask := -1
+		for m := 0; m < 8; m++ {
+			if formatBits(m) == bits {
+				mask = m
+			}
+		}
+		if mask < 0 {
+			t.Fatalf("unreadable format bits %015b", bits)
+		}
+		c.applyMask(mask)
+		var bb bitBuffer
+		for right := c.Size - 1; right >= 1; right -= 2 {
+			if right == 6 {
+				right = 5
+			}
+			for vert := 0; vert < c.Size; vert++ {
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				for j := 0; j < 2; j++ {
+					if !c.function[y][right-j] {
+						bb.append(b2i(c.Dark(right-j, y)), 1)
+					}
+				}
+			}
+		}
+		c.applyMask(mask)
+		want := interleave(c.Version, dataCodewords(c.Version, []byte(text)))
+		if got := bb.bytes()[:len(want)]; !bytes.Equal(got, want) {
+			t.Fatalf("codewords read back differ for %q", text)
+		}
+	}
+}
+
+func TestRenderPNGAndSVG(t *testing.T) {
+	c, err := Encode("elix://bridge/pair#code=ABC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := c.PNG(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if side := (c.Size + 8) * 4; img.Bounds().Dx() != side {
+		t.Fatalf("png width %d, want %d", img.Bounds().Dx(), side)
+	}
+	if svg := string(c.SVG()); !strings.HasPrefix(svg, "<svg") || !strings.Contains(svg, "M4 4h1v1h-1z") {
+		t.Fatalf("unexpected svg: %.80s", svg)
+	}
+	if _, err := Encode(strings.Repeat("x", 700)); err == nil {
+		t.Fatal("expected oversized text to fail")
+	}
+}
+
+// TestDecodeKnownLayouts reads symbols back with an independent decoder
+// built from the ISO/IEC 18004 tables for versions 1, 2 and 7 at level M:
+// it rebuilds the function patterns, finds the mask from the second copy of
+// the format bits, checks every block's Reed-Solomon syndromes and parses
+// the byte mode segment.
+func TestDecodeKnownLayouts(t *testing.T) {
+	type spec struct {
+		blocks, data, ec int
+		align            []int
+	}
+	specs := map[int]spec{
+		1: {1, 16, 10, nil},
+		2: {1, 28, 16, []int{6, 18}},
+		7: {4, 31, 18, []int{6, 22, 38}},
+	}
+	for version, text := range map[int]string{
+		1: "hi",
+		2: "elix://host/pair#code=AB",
+		7: "elix://192.168.1.20:8765/pair#code=ABCD-EFGH&challenge=" + strings.Repeat("x", 43) + "&fp=SHA256%3Aabc",
+	} {
+		c, err := Encode(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Version != version {
+			t.Fatalf("%q: version %d, want %d", text, c.Version, version)
+		}
+		sp := specs[version]
+		size := 17 + 4*version
+
+		function := make([][]bool, size)
+		for y := range function {
+			function[y] = make([]bool, size)
+		}
+		mark := func(x0, y0, w, h int) {
+			for y := y0; y < y0+h; y++ {
+				for x := x0; x < x0+w; x++ {
+					function[y][x] = true
+				}
+			}
+		}
+		mark(0, 0, 9, 9) // finders, separators and format bits
+		mark(size-8, 0, 8, 9)
+		mark(0, size-8, 9, 8)
+		mark(0, 6, size, 1) // timing
+		mark(6, 0, 1, size)
+		for _, ax := range sp.align {
+			for _, ay := range sp.align {
+				first, last := sp.align[0], sp.align[len(sp.align)-1]
+				if (ax == first && ay == first) || (ax == first && ay == last) || (ax == last && ay == first) {
+					continue
+				}
+				mark(ax-2, ay-2, 5, 5)
+			}
+		}
+		if version >= 7 {
+			mark(size-11, 0, 3, 6)
+			mark(0, size-11, 6, 3)
+		}
+
+		format := 0
+		for i := 0; i < 8; i++ {
+			format |= b2i(c.Dark(size-1-i, 8)) << i
+		}
+		for i := 8; i < 15; i++ {
+			format |= b2i(c.Dark(8, size-15+i)) << i
+		}
+		mask := -1
+		for m := 0; m < 8; m++ {
+			bits := m // level M is 00
+			rem := bits << 10
+			for i := 14; i >= 10; i-- {
+				if rem>>i&1 != 0 {
+					rem ^= 0x537 << (i - 10)
+				}
+			}
+			if (bits<<10|rem)^0x5412 == format {
+				mask = m
+			}
+		}
+		if mask < 0 {
+			t.Fatalf("%q: unreadable format bits %015b", text, format)
+		}
+		masks := []func(row, col int) bool{
+			func(r, c int) bool { return (r+c)%2 == 0 },
+			func(r, c int) bool { return r%2 == 0 },
+			func(r, c int) bool { return c%3 == 0 },
+			func(r, c int) bool { return (r+c)%3 == 0 },
+			func(r, c int) bool { return (r/2+c/3)%2 == 0 },
+			func(r, c int) bool { return r*c%2+r*c%3 == 0 },
+			func(r, c int) bool { return (r*c%2+r*c%3)%2 == 0 },
+			func(r, c int) bool { return ((r+c)%2+r*c%3)%2 == 0 },
+		}
+
+		total := sp.blocks * (sp.data + sp.ec)
+		raw := make([]byte, 0, total)
+		var cur, n int
+		upward := true
+		for right := size - 1; right >= 1; right -= 2 {
+			if right == 6 {
+				right--
+			}
+			for i := 0; i < size; i++ {
+				y := i
+				if upward {
+					y = size - 1 - i
+				}
+				for _, x := range []int{right, right - 1} {
+					if function[y][x] || len(raw) == total {
+						continue
+					}
+					cur = cur<<1 | b2i(c.Dark(x, y) != masks[mask](y, x))
+					if n++; n == 8 {
+						raw = append(raw, byte(cur))
+						cur, n = 0, 0
+					}
+				}
+			}
+			upward = !upward
+		}
+		if len(raw) != total {
+			t.Fatalf("%q: read %d codewords, want %d", text, len(raw), total)
+		}
+
+		exp := make([]byte, 255)
+		exp[0] = 1
+		for i := 1; i < 255; i++ {
+			v := int(exp[i-1]) << 1
+			if v&0x100 != 0 {
+				v ^= 0x11d
+			}
+			exp[i] = byte(v)
+		}
+		mul := func(a, b byte) byte {
+			var p byte
+			for ; b != 0; b >>= 1 {
+				if b&1 != 0 {
+					p ^= a
+				}
+				hi := a & 0x80
+				a <<= 1
+				if hi != 0 {
+					a ^= 0x1d
+				}
+			}
+			return p
+		}
+		var data []byte
+		blocks := make([][]byte, sp.blocks)
+		for i := 0; i < sp.data+sp.ec; i++ {
+			for b := range blocks {
+				blocks[b] = append(blocks[b], raw[i*sp.blocks+b])
+			}
+		}
+		for b, block := range blocks {
+			for i := 0; i < sp.ec; i++ {
+				var syndrome byte
+				for _, cw := range block {
+					syndrome = mul(syndrome, exp[i]) ^ cw
+				}
+				if syndrome != 0 {
+					t.Fatalf("%q: block %d syndrome %d = %d", text, b, i, syndrome)
+				}
+			}
+			data = append(data, block[:sp.data]...)
+		}
+
+		countBits := 8
+		if version >= 10 {
+			countBits = 16
+		}
+		var br bitBuffer
+		for _, b := range data {
+			br.append(int(b), 8)
+		}
+		read := func(from, n int) int {
+			v := 0
+			for _, bit := range br.bits[from : from+n] {
+				v = v<<1 | b2i(bit)
+			}
+			return v
+		}
+		if mode := read(0, 4); mode != 0b0100 {
+			t.Fatalf("%q: mode %04b, want byte mode", text, mode)
+		}
+		length := read(4, countBits)
+		got := make([]byte, length)
+		for i := range got {
+			got[i] = byte(read(4+countBits+8*i, 8))
+		}
+		if string(got) != text {
+			t.Fatalf("decoded %q, want %q", got, text)
+		}
+	}
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
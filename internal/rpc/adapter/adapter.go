@@ -12,20 +12,39 @@ const (
 	MethodStartRun     = "/" + ServiceName + "/StartRun"
 	MethodStreamEvents = "/" + ServiceName + "/StreamEvents"
 	MethodCancelRun    = "/" + ServiceName + "/CancelRun"
+	MethodStreamInput  = "/" + ServiceName + "/StreamInput"
+	MethodExtendRun    = "/" + ServiceName + "/ExtendRun"
+	MethodPauseRun     = "/" + ServiceName + "/PauseRun"
+	MethodResumeRun    = "/" + ServiceName + "/ResumeRun"
 	MethodHealth       = "/" + ServiceName + "/Health"
 	MethodCapabilities = "/" + ServiceName + "/Capabilities"
+	MethodListModels   = "/" + ServiceName + "/ListModels"
+	MethodGetRawLog    = "/" + ServiceName + "/GetRawLog"
 )
 
 type StartRunRequest struct {
-	RunID         string         `json:"run_id"`
-	WorkspacePath string         `json:"workspace_path"`
-	Prompt        string         `json:"prompt"`
-	Context       map[string]any `json:"context,omitempty"`
-	Model         string         `json:"model,omitempty"`
-	Profile       string         `json:"profile,omitempty"`
-	Sandbox       string         `json:"sandbox,omitempty"`
-	SchemaVersion string         `json:"schema_version,omitempty"`
-	TimeoutSec    int32          `json:"timeout_sec"`
+	RunID         string            `json:"run_id"`
+	WorkspacePath string            `json:"workspace_path"`
+	Prompt        string            `json:"prompt"`
+	Context       map[string]any    `json:"context,omitempty"`
+	Model         string            `json:"model,omitempty"`
+	Profile       string            `json:"profile,omitempty"`
+	Sandbox       string            `json:"sandbox,omitempty"`
+	SchemaVersion string            `json:"schema_version,omitempty"`
+	TimeoutSec    int32             `json:"timeout_sec"`
+	Env           map[string]string `json:"env,omitempty"`
+	PathPrepend   []string          `json:"path_prepend,omitempty"`
+	ShellInit     string            `json:"shell_init,omitempty"`
+	// Interactive keeps the CLI's stdin open after the prompt so StreamInput
+	// can write to it.
+	Interactive bool `json:"interactive,omitempty"`
+	// PTY runs the CLI on a pseudo-terminal of PTYCols x PTYRows instead of
+	// pipes. RawOutput adds the terminal output, escapes included, as token
+	// events with source "pty".
+	PTY       bool   `json:"pty,omitempty"`
+	PTYCols   uint32 `json:"pty_cols,omitempty"`
+	PTYRows   uint32 `json:"pty_rows,omitempty"`
+	RawOutput bool   `json:"raw_output,omitempty"`
 }
 
 type StartRunResponse struct {
@@ -46,6 +65,48 @@ type CancelRunResponse struct {
 	Error     string `json:"error,omitempty"`
 }
 
+type StreamInputRequest struct {
+	RunID string `json:"run_id"`
+	Data  string `json:"data,omitempty"`
+	EOF   bool   `json:"eof,omitempty"`
+}
+
+type StreamInputResponse struct {
+	Accepted bool   `json:"accepted"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ExtendRunRequest moves a run's deadline to TimeoutSec seconds from now.
+type ExtendRunRequest struct {
+	RunID      string `json:"run_id"`
+	TimeoutSec int32  `json:"timeout_sec"`
+}
+
+type ExtendRunResponse struct {
+	Extended bool   `json:"extended"`
+	Error    string `json:"error,omitempty"`
+}
+
+// PauseRunRequest suspends a run's CLI and stops its timeout clock.
+type PauseRunRequest struct {
+	RunID string `json:"run_id"`
+}
+
+type PauseRunResponse struct {
+	Paused bool   `json:"paused"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ResumeRunRequest continues a paused run with the timeout it had left.
+type ResumeRunRequest struct {
+	RunID string `json:"run_id"`
+}
+
+type ResumeRunResponse struct {
+	Resumed bool   `json:"resumed"`
+	Error   string `json:"error,omitempty"`
+}
+
 type HealthRequest struct{}
 
 type HealthResponse struct {
@@ -60,9 +121,45 @@ type CapabilitiesResponse struct {
 	EventTypes             []string `json:"event_types"`
 	SupportsCancel         bool     `json:"supports_cancel"`
 	SupportsPTY            bool     `json:"supports_pty"`
+	SupportsInput          bool     `json:"supports_input,omitempty"`
 	SchemaVersions         []string `json:"schema_versions,omitempty"`
 	PreferredSchemaVersion string   `json:"preferred_schema_version,omitempty"`
 	CompatFields           []string `json:"compat_fields,omitempty"`
+	Tools                  []string `json:"tools,omitempty"`
+	SupportsPause          bool     `json:"supports_pause,omitempty"`
+	Models                 []string `json:"models,omitempty"`
+	Profiles               []string `json:"profiles,omitempty"`
+	SandboxModes           []string `json:"sandbox_modes,omitempty"`
+}
+
+type ListModelsRequest struct{}
+
+// ModelInfo describes one model the CLI accepts. ContextWindow is in tokens,
+// zero when unknown.
+type ModelInfo struct {
+	ID            string `json:"id"`
+	ContextWindow int64  `json:"context_window,omitempty"`
+	Default       bool   `json:"default,omitempty"`
+}
+
+type ListModelsResponse struct {
+	Models []ModelInfo `json:"models"`
+}
+
+// GetRawLogRequest reads the raw CLI output an adapter kept for a run. At
+// most MaxBytes from the end of the log are returned.
+type GetRawLogRequest struct {
+	RunID    string `json:"run_id"`
+	MaxBytes int64  `json:"max_bytes,omitempty"`
+}
+
+type GetRawLogResponse struct {
+	Found     bool   `json:"found"`
+	Data      string `json:"data,omitempty"`
+	Size      int64  `json:"size"`
+	Truncated bool   `json:"truncated,omitempty"`
+	Disabled  bool   `json:"disabled,omitempty"`
+	Error     string `json:"error,omitempty"`
 }
 
 type AgentEvent struct {
@@ -85,8 +182,14 @@ type AdapterServer interface {
 	StartRun(context.Context, *StartRunRequest) (*StartRunResponse, error)
 	StreamEvents(*StreamEventsRequest, AdapterStreamEventsServer) error
 	CancelRun(context.Context, *CancelRunRequest) (*CancelRunResponse, error)
+	StreamInput(context.Context, *StreamInputRequest) (*StreamInputResponse, error)
+	ExtendRun(context.Context, *ExtendRunRequest) (*ExtendRunResponse, error)
+	PauseRun(context.Context, *PauseRunRequest) (*PauseRunResponse, error)
+	ResumeRun(context.Context, *ResumeRunRequest) (*ResumeRunResponse, error)
 	Health(context.Context, *HealthRequest) (*HealthResponse, error)
 	Capabilities(context.Context, *CapabilitiesRequest) (*CapabilitiesResponse, error)
+	ListModels(context.Context, *ListModelsRequest) (*ListModelsResponse, error)
+	GetRawLog(context.Context, *GetRawLogRequest) (*GetRawLogResponse, error)
 }
 
 type AdapterStreamEventsServer interface {
@@ -112,8 +215,14 @@ var AdapterServiceDesc = grpc.ServiceDesc{
 	Methods: []grpc.MethodDesc{
 		{MethodName: "StartRun", Handler: _Adapter_StartRun_Handler},
 		{MethodName: "CancelRun", Handler: _Adapter_CancelRun_Handler},
+		{MethodName: "StreamInput", Handler: _Adapter_StreamInput_Handler},
+		{MethodName: "ExtendRun", Handler: _Adapter_ExtendRun_Handler},
+		{MethodName: "PauseRun", Handler: _Adapter_PauseRun_Handler},
+		{MethodName: "ResumeRun", Handler: _Adapter_ResumeRun_Handler},
 		{MethodName: "Health", Handler: _Adapter_Health_Handler},
 		{MethodName: "Capabilities", Handler: _Adapter_Capabilities_Handler},
+		{MethodName: "ListModels", Handler: _Adapter_ListModels_Handler},
+		{MethodName: "GetRawLog", Handler: _Adapter_GetRawLog_Handler},
 	},
 	Streams: []grpc.StreamDesc{
 		{StreamName: "StreamEvents", Handler: _Adapter_StreamEvents_Handler, ServerStreams: true},
@@ -167,6 +276,98 @@ func _Adapter_CancelRun_Handler(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Adapter_StreamInput_Handler(
+	srv any,
+	ctx context.Context,
+	dec func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	in := new(StreamInputRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdapterServer).StreamInput(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MethodStreamInput,
+	}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(AdapterServer).StreamInput(ctx, req.(*StreamInputRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Adapter_ExtendRun_Handler(
+	srv any,
+	ctx context.Context,
+	dec func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	in := new(ExtendRunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdapterServer).ExtendRun(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MethodExtendRun,
+	}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(AdapterServer).ExtendRun(ctx, req.(*ExtendRunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Adapter_PauseRun_Handler(
+	srv any,
+	ctx context.Context,
+	dec func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	in := new(PauseRunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdapterServer).PauseRun(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MethodPauseRun,
+	}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(AdapterServer).PauseRun(ctx, req.(*PauseRunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Adapter_ResumeRun_Handler(
+	srv any,
+	ctx context.Context,
+	dec func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	in := new(ResumeRunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdapterServer).ResumeRun(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MethodResumeRun,
+	}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(AdapterServer).ResumeRun(ctx, req.(*ResumeRunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Adapter_Health_Handler(
 	srv any,
 	ctx context.Context,
@@ -213,6 +414,52 @@ func _Adapter_Capabilities_Handler(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Adapter_ListModels_Handler(
+	srv any,
+	ctx context.Context,
+	dec func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	in := new(ListModelsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdapterServer).ListModels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MethodListModels,
+	}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(AdapterServer).ListModels(ctx, req.(*ListModelsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Adapter_GetRawLog_Handler(
+	srv any,
+	ctx context.Context,
+	dec func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (any, error) {
+	in := new(GetRawLogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdapterServer).GetRawLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MethodGetRawLog,
+	}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(AdapterServer).GetRawLog(ctx, req.(*GetRawLogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Adapter_StreamEvents_Handler(srv any, stream grpc.ServerStream) error {
 	in := new(StreamEventsRequest)
 	if err := stream.RecvMsg(in); err != nil {
@@ -225,8 +472,14 @@ type AdapterClient interface {
 	StartRun(ctx context.Context, in *StartRunRequest, opts ...grpc.CallOption) (*StartRunResponse, error)
 	StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (AdapterStreamEventsClient, error)
 	CancelRun(ctx context.Context, in *CancelRunRequest, opts ...grpc.CallOption) (*CancelRunResponse, error)
+	StreamInput(ctx context.Context, in *StreamInputRequest, opts ...grpc.CallOption) (*StreamInputResponse, error)
+	ExtendRun(ctx context.Context, in *ExtendRunRequest, opts ...grpc.CallOption) (*ExtendRunResponse, error)
+	PauseRun(ctx context.Context, in *PauseRunRequest, opts ...grpc.CallOption) (*PauseRunResponse, error)
+	ResumeRun(ctx context.Context, in *ResumeRunRequest, opts ...grpc.CallOption) (*ResumeRunResponse, error)
 	Health(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error)
 	Capabilities(ctx context.Context, in *CapabilitiesRequest, opts ...grpc.CallOption) (*CapabilitiesResponse, error)
+	ListModels(ctx context.Context, in *ListModelsRequest, opts ...grpc.CallOption) (*ListModelsResponse, error)
+	GetRawLog(ctx context.Context, in *GetRawLogRequest, opts ...grpc.CallOption) (*GetRawLogResponse, error)
 }
 
 type adapterClient struct {
@@ -255,6 +508,42 @@ func (c *adapterClient) CancelRun(ctx context.Context, in *CancelRunRequest, opt
 	return out, nil
 }
 
+func (c *adapterClient) StreamInput(ctx context.Context, in *StreamInputRequest, opts ...grpc.CallOption) (*StreamInputResponse, error) {
+	out := new(StreamInputResponse)
+	err := c.cc.Invoke(ctx, MethodStreamInput, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adapterClient) ExtendRun(ctx context.Context, in *ExtendRunRequest, opts ...grpc.CallOption) (*ExtendRunResponse, error) {
+	out := new(ExtendRunResponse)
+	err := c.cc.Invoke(ctx, MethodExtendRun, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adapterClient) PauseRun(ctx context.Context, in *PauseRunRequest, opts ...grpc.CallOption) (*PauseRunResponse, error) {
+	out := new(PauseRunResponse)
+	err := c.cc.Invoke(ctx, MethodPauseRun, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adapterClient) ResumeRun(ctx context.Context, in *ResumeRunRequest, opts ...grpc.CallOption) (*ResumeRunResponse, error) {
+	out := new(ResumeRunResponse)
+	err := c.cc.Invoke(ctx, MethodResumeRun, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *adapterClient) Health(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error) {
 	out := new(HealthResponse)
 	err := c.cc.Invoke(ctx, MethodHealth, in, out, opts...)
@@ -273,6 +562,24 @@ func (c *adapterClient) Capabilities(ctx context.Context, in *CapabilitiesReques
 	return out, nil
 }
 
+func (c *adapterClient) ListModels(ctx context.Context, in *ListModelsRequest, opts ...grpc.CallOption) (*ListModelsResponse, error) {
+	out := new(ListModelsResponse)
+	err := c.cc.Invoke(ctx, MethodListModels, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adapterClient) GetRawLog(ctx context.Context, in *GetRawLogRequest, opts ...grpc.CallOption) (*GetRawLogResponse, error) {
+	out := new(GetRawLogResponse)
+	err := c.cc.Invoke(ctx, MethodGetRawLog, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 type AdapterStreamEventsClient interface {
 	Recv() (*AgentEvent, error)
 	grpc.ClientStream
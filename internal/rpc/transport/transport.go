@@ -0,0 +1,165 @@
+package transport
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"echohelix/internal/tracing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TokenMetadataKey carries the shared adapter secret on every RPC.
+const TokenMetadataKey = "x-elix-adapter-token"
+
+// Security describes how the bridge and an adapter authenticate each other.
+// The zero value keeps the plaintext, unauthenticated localhost behaviour.
+type Security struct {
+	// CertFile/KeyFile: the server certificate on the adapter side, the
+	// client certificate (for mTLS) on the driver side.
+	CertFile string
+	KeyFile  string
+	// CAFile verifies the peer: client certificates on the adapter side,
+	// the adapter certificate on the driver side.
+	CAFile     string
+	ServerName string
+	Token      string
+}
+
+func (s Security) clientTLSEnabled() bool {
+	return s.CAFile != "" || s.CertFile != ""
+}
+
+func (s Security) serverTLSEnabled() bool {
+	return s.CertFile != "" || s.KeyFile != ""
+}
+
+func (s Security) DialOptions() ([]grpc.DialOption, error) {
+	creds := insecure.NewCredentials()
+	if s.clientTLSEnabled() {
+		cfg := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: s.ServerName}
+		if s.CAFile != "" {
+			pool, err := loadCertPool(s.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			cfg.RootCAs = pool
+		}
+		if s.CertFile != "" {
+			cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("load adapter client certificate: %w", err)
+			}
+			cfg.Certificates = []tls.Certificate{cert}
+		}
+		creds = credentials.NewTLS(cfg)
+	}
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithChainUnaryInterceptor(tracing.UnaryClientInterceptor),
+		grpc.WithChainStreamInterceptor(tracing.StreamClientInterceptor),
+	}
+	if token := strings.TrimSpace(s.Token); token != "" {
+		opts = append(opts,
+			grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+				return invoker(metadata.AppendToOutgoingContext(ctx, TokenMetadataKey, token), method, req, reply, cc, callOpts...)
+			}),
+			grpc.WithChainStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+				return streamer(metadata.AppendToOutgoingContext(ctx, TokenMetadataKey, token), desc, cc, method, callOpts...)
+			}),
+		)
+	}
+	return opts, nil
+}
+
+func (s Security) ServerOptions() ([]grpc.ServerOption, error) {
+	var opts []grpc.ServerOption
+	if s.serverTLSEnabled() {
+		cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load adapter server certificate: %w", err)
+		}
+		cfg := &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}
+		if s.CAFile != "" {
+			pool, err := loadCertPool(s.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			cfg.ClientCAs = pool
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(cfg)))
+	}
+	if token := strings.TrimSpace(s.Token); token != "" {
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				if err := checkToken(ctx, token); err != nil {
+					return nil, err
+				}
+				return handler(ctx, req)
+			}),
+			grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				if err := checkToken(ss.Context(), token); err != nil {
+					return err
+				}
+				return handler(srv, ss)
+			}),
+		)
+	}
+	return opts, nil
+}
+
+// Env returns the variables that hand this configuration to a locally
+// spawned adapter process (see FromEnv).
+func (s Security) Env() []string {
+	var out []string
+	add := func(k, v string) {
+		if v != "" {
+			out = append(out, k+"="+v)
+		}
+	}
+	add("ADAPTER_AUTH_TOKEN", s.Token)
+	return out
+}
+
+// FromEnv reads the adapter-side configuration.
+func FromEnv() Security {
+	return Security{
+		CertFile: strings.TrimSpace(os.Getenv("ADAPTER_TLS_CERT")),
+		KeyFile:  strings.TrimSpace(os.Getenv("ADAPTER_TLS_KEY")),
+		CAFile:   strings.TrimSpace(os.Getenv("ADAPTER_TLS_CLIENT_CA")),
+		Token:    strings.TrimSpace(os.Getenv("ADAPTER_AUTH_TOKEN")),
+	}
+}
+
+func checkToken(ctx context.Context, want string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, got := range md.Get(TokenMetadataKey) {
+		if subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid adapter token")
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read adapter CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("adapter CA %s contains no certificates", path)
+	}
+	return pool, nil
+}
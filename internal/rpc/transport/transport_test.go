@@ -0,0 +1,207 @@
+package transport
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	adapterrpc "echohelix/internal/rpc/adapter"
+	"echohelix/internal/rpc/codec"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type healthOnlyAdapter struct{}
+
+func (healthOnlyAdapter) StartRun(context.Context, *adapterrpc.StartRunRequest) (*adapterrpc.StartRunResponse, error) {
+	return &adapterrpc.StartRunResponse{}, nil
+}
+
+func (healthOnlyAdapter) StreamEvents(*adapterrpc.StreamEventsRequest, adapterrpc.AdapterStreamEventsServer) error {
+	return nil
+}
+
+func (healthOnlyAdapter) CancelRun(context.Context, *adapterrpc.CancelRunRequest) (*adapterrpc.CancelRunResponse, error) {
+	return &adapterrpc.CancelRunResponse{}, nil
+}
+
+func (healthOnlyAdapter) StreamInput(context.Context, *adapterrpc.StreamInputRequest) (*adapterrpc.StreamInputResponse, error) {
+	return &adapterrpc.StreamInputResponse{}, nil
+}
+
+func (healthOnlyAdapter) ExtendRun(context.Context, *adapterrpc.ExtendRunRequest) (*adapterrpc.ExtendRunResponse, error) {
+	return &adapterrpc.ExtendRunResponse{}, nil
+}
+
+func (healthOnlyAdapter) PauseRun(context.Context, *adapterrpc.PauseRunRequest) (*adapterrpc.PauseRunResponse, error) {
+	return &adapterrpc.PauseRunResponse{}, nil
+}
+
+func (healthOnlyAdapter) ResumeRun(context.Context, *adapterrpc.ResumeRunRequest) (*adapterrpc.ResumeRunResponse, error) {
+	return &adapterrpc.ResumeRunResponse{}, nil
+}
+
+func (healthOnlyAdapter) Health(context.Context, *adapterrpc.HealthRequest) (*adapterrpc.HealthResponse, error) {
+	return &adapterrpc.HealthResponse{OK: true, Message: "ok"}, nil
+}
+
+func (healthOnlyAdapter) Capabilities(context.Context, *adapterrpc.CapabilitiesRequest) (*adapterrpc.CapabilitiesResponse, error) {
+	return &adapterrpc.CapabilitiesResponse{}, nil
+}
+
+func (healthOnlyAdapter) ListModels(context.Context, *adapterrpc.ListModelsRequest) (*adapterrpc.ListModelsResponse, error) {
+	return &adapterrpc.ListModelsResponse{}, nil
+}
+
+func (healthOnlyAdapter) GetRawLog(context.Context, *adapterrpc.GetRawLogRequest) (*adapterrpc.GetRawLogResponse, error) {
+	return &adapterrpc.GetRawLogResponse{}, nil
+}
+
+func serveAdapter(t *testing.T, sec Security) string {
+	t.Helper()
+	opts, err := sec.ServerOptions()
+	if err != nil {
+		t.Fatalf("server options: %v", err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := grpc.NewServer(append(opts, grpc.ForceServerCodec(codec.JSONCodec{}))...)
+	adapterrpc.RegisterAdapterServer(srv, healthOnlyAdapter{})
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+func callHealth(t *testing.T, addr string, sec Security) error {
+	t.Helper()
+	opts, err := sec.DialOptions()
+	if err != nil {
+		t.Fatalf("dial options: %v", err)
+	}
+	opts = append(opts, grpc.WithDefaultCallOptions(grpc.ForceCodec(codec.JSONCodec{})))
+	conn, err := grpc.NewClient(addr, opts...)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err = adapterrpc.NewAdapterClient(conn).Health(ctx, &adapterrpc.HealthRequest{})
+	return err
+}
+
+func TestTokenInterceptorRejectsMissingOrWrongToken(t *testing.T) {
+	addr := serveAdapter(t, Security{Token: "s3cret"})
+
+	if err := callHealth(t, addr, Security{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected unauthenticated without token, got %v", err)
+	}
+	if err := callHealth(t, addr, Security{Token: "wrong"}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected unauthenticated with wrong token, got %v", err)
+	}
+	if err := callHealth(t, addr, Security{Token: "s3cret"}); err != nil {
+		t.Fatalf("expected success with token, got %v", err)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir)
+	serverCert, serverKey := ca.issue(t, dir, "adapter", true)
+	clientCert, clientKey := ca.issue(t, dir, "bridge", false)
+
+	addr := serveAdapter(t, Security{CertFile: serverCert, KeyFile: serverKey, CAFile: ca.certFile, Token: "s3cret"})
+
+	if err := callHealth(t, addr, Security{CAFile: ca.certFile, ServerName: "adapter", Token: "s3cret"}); err == nil {
+		t.Fatalf("expected handshake failure without client certificate")
+	}
+	if err := callHealth(t, addr, Security{CertFile: clientCert, KeyFile: clientKey, CAFile: ca.certFile, ServerName: "adapter", Token: "s3cret"}); err != nil {
+		t.Fatalf("expected mTLS call to succeed, got %v", err)
+	}
+}
+
+type testCA struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certFile string
+}
+
+func newTestCA(t *testing.T, dir string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate CA key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "elix-test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create CA cert: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	certFile := filepath.Join(dir, "ca.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	return &testCA{cert: cert, key: key, certFile: certFile}
+}
+
+func (ca *testCA) issue(t *testing.T, dir, name string, server bool) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	usage := x509.ExtKeyUsageClientAuth
+	if server {
+		usage = x509.ExtKeyUsageServerAuth
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("create cert: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	certFile := filepath.Join(dir, name+".pem")
+	keyFile := filepath.Join(dir, name+"-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
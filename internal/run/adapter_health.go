@@ -0,0 +1,73 @@
+package run
+
+import (
+	"context"
+	"errors"
+
+	"echohelix/internal/adapter/supervisor"
+	"echohelix/internal/driver"
+	"echohelix/internal/events"
+)
+
+// StartAdapterMonitors polls the health of every supervised adapter until ctx
+// is done. When an adapter turns unhealthy or recovers, a status event is
+// published to each active run on that backend. Backends registered later
+// are monitored under the same ctx.
+func (s *Service) StartAdapterMonitors(ctx context.Context) {
+	s.mu.Lock()
+	s.monitorCtx = ctx
+	s.mu.Unlock()
+	for _, d := range s.registry.All() {
+		if sup := adapterSupervisor(d); sup != nil {
+			s.monitorAdapter(ctx, d, sup)
+		}
+	}
+}
+
+func (s *Service) monitorAdapter(ctx context.Context, drv driver.Driver, sup *supervisor.Supervisor) {
+	sup.SetStateListener(func(healthy bool, message string) {
+		s.publishAdapterHealth(drv.Name(), healthy, message)
+	})
+	go sup.Monitor(ctx, func(ctx context.Context) error {
+		h, err := drv.Health(ctx)
+		if err != nil {
+			return err
+		}
+		if !h.OK {
+			return errors.New(h.Message)
+		}
+		return nil
+	})
+}
+
+func adapterSupervisor(d driver.Driver) *supervisor.Supervisor {
+	sv, ok := d.(driver.Supervised)
+	if !ok {
+		return nil
+	}
+	return sv.Supervisor()
+}
+
+func (s *Service) publishAdapterHealth(backend string, healthy bool, message string) {
+	type target struct{ runID, status string }
+	s.mu.Lock()
+	var targets []target
+	for runID, ar := range s.active {
+		if ar.backend == backend && !isTerminalStatus(ar.status) {
+			targets = append(targets, target{runID: runID, status: ar.status})
+		}
+	}
+	s.mu.Unlock()
+
+	adapter := "healthy"
+	if !healthy {
+		adapter = "unhealthy"
+	}
+	for _, t := range targets {
+		payload := map[string]any{"status": t.status, "adapter": adapter}
+		if message != "" {
+			payload["message"] = message
+		}
+		s.emit(context.Background(), t.runID, backend, "bridge", events.TypeStatus, payload)
+	}
+}
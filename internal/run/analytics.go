@@ -0,0 +1,185 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	AnalyticsGroupBackend   = "backend"
+	AnalyticsGroupModel     = "model"
+	AnalyticsGroupWorkspace = "workspace"
+	AnalyticsGroupDay       = "day"
+)
+
+func (s *Service) RunAnalytics(ctx context.Context, from, to time.Time, groupBy string) (RunAnalyticsSummary, error) {
+	if from.IsZero() || to.IsZero() || !from.Before(to) {
+		return RunAnalyticsSummary{}, fmt.Errorf("invalid time range")
+	}
+	groupBy = strings.ToLower(strings.TrimSpace(groupBy))
+	if groupBy == "" {
+		groupBy = AnalyticsGroupBackend
+	}
+	switch groupBy {
+	case AnalyticsGroupBackend, AnalyticsGroupModel, AnalyticsGroupWorkspace, AnalyticsGroupDay:
+	default:
+		return RunAnalyticsSummary{}, fmt.Errorf("invalid group_by %q", groupBy)
+	}
+
+	recs, err := s.ledger.ListRunOutcomes(ctx, from, to)
+	if err != nil {
+		return RunAnalyticsSummary{}, err
+	}
+	acc := map[string]*analyticsAccumulator{}
+	total := &analyticsAccumulator{}
+	for _, rec := range recs {
+		var key string
+		switch groupBy {
+		case AnalyticsGroupBackend:
+			key = rec.Backend
+		case AnalyticsGroupModel:
+			key = rec.Model
+			if key == "" {
+				key = "default"
+			}
+		case AnalyticsGroupWorkspace:
+			key = rec.WorkspaceID
+		case AnalyticsGroupDay:
+			key = rec.CreatedAt.UTC().Format("2006-01-02")
+		}
+		a := acc[key]
+		if a == nil {
+			a = &analyticsAccumulator{}
+			acc[key] = a
+		}
+		a.add(rec.Status, rec.UpdatedAt.Sub(rec.CreatedAt), rec.HasUsage, rec.InputTokens, rec.OutputTokens, rec.TotalTokens)
+		total.add(rec.Status, rec.UpdatedAt.Sub(rec.CreatedAt), rec.HasUsage, rec.InputTokens, rec.OutputTokens, rec.TotalTokens)
+		threshold := s.firstEventThreshold(rec.Backend)
+		a.addFirstEvent(rec.FirstEventMS, threshold)
+		total.addFirstEvent(rec.FirstEventMS, threshold)
+	}
+
+	keys := make([]string, 0, len(acc))
+	for k := range acc {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := RunAnalyticsSummary{
+		From:    from.UTC(),
+		To:      to.UTC(),
+		GroupBy: groupBy,
+		Totals:  total.result(""),
+		Groups:  make([]RunAnalyticsGroup, 0, len(keys)),
+	}
+	for _, k := range keys {
+		out.Groups = append(out.Groups, acc[k].result(k))
+	}
+	return out, nil
+}
+
+type analyticsAccumulator struct {
+	total, completed, failed, cancelled, inProgress int64
+	durations                                       []time.Duration
+	usageRuns                                       int64
+	input, output, tokens                           int64
+	firstEvents                                     []time.Duration
+	sloSamples, sloBreaches                         int64
+}
+
+func (a *analyticsAccumulator) add(status string, dur time.Duration, hasUsage bool, input, output, total int64) {
+	a.total++
+	switch status {
+	case StatusCompleted:
+		a.completed++
+	case StatusFailed:
+		a.failed++
+	case StatusCancelled:
+		a.cancelled++
+	default:
+		a.inProgress++
+	}
+	if isTerminalStatus(status) && dur >= 0 {
+		a.durations = append(a.durations, dur)
+	}
+	if hasUsage {
+		a.usageRuns++
+		a.input += input
+		a.output += output
+		a.tokens += total
+	}
+}
+
+// addFirstEvent counts a run against its backend's current first event
+// threshold; runs without a recorded latency or threshold are skipped.
+func (a *analyticsAccumulator) addFirstEvent(ms int64, threshold time.Duration) {
+	if ms < 0 {
+		return
+	}
+	a.firstEvents = append(a.firstEvents, time.Duration(ms)*time.Millisecond)
+	if threshold <= 0 {
+		return
+	}
+	a.sloSamples++
+	if ms > threshold.Milliseconds() {
+		a.sloBreaches++
+	}
+}
+
+func (a *analyticsAccumulator) result(key string) RunAnalyticsGroup {
+	out := RunAnalyticsGroup{
+		Key:        key,
+		RunCount:   a.total,
+		Completed:  a.completed,
+		Failed:     a.failed,
+		Cancelled:  a.cancelled,
+		InProgress: a.inProgress,
+	}
+	if finished := a.completed + a.failed + a.cancelled; finished > 0 {
+		out.SuccessRate = ratio(a.completed, finished)
+		out.FailureRate = ratio(a.failed, finished)
+		out.CancelRate = ratio(a.cancelled, finished)
+	}
+	sort.Slice(a.durations, func(i, j int) bool { return a.durations[i] < a.durations[j] })
+	out.P50DurationMS = percentile(a.durations, 0.50).Milliseconds()
+	out.P95DurationMS = percentile(a.durations, 0.95).Milliseconds()
+	sort.Slice(a.firstEvents, func(i, j int) bool { return a.firstEvents[i] < a.firstEvents[j] })
+	out.FirstEventP50MS = percentile(a.firstEvents, 0.50).Milliseconds()
+	out.FirstEventP95MS = percentile(a.firstEvents, 0.95).Milliseconds()
+	out.SLOSamples = a.sloSamples
+	out.SLOBreaches = a.sloBreaches
+	if a.sloSamples > 0 {
+		out.SLOCompliance = ratio(a.sloSamples-a.sloBreaches, a.sloSamples)
+	}
+	if a.usageRuns > 0 {
+		out.AvgInputTokens = float64(a.input) / float64(a.usageRuns)
+		out.AvgOutputTokens = float64(a.output) / float64(a.usageRuns)
+		out.AvgTotalTokens = float64(a.tokens) / float64(a.usageRuns)
+	}
+	return out
+}
+
+func ratio(n, d int64) float64 {
+	if d == 0 {
+		return 0
+	}
+	return math.Round(float64(n)/float64(d)*10000) / 10000
+}
+
+// percentile uses nearest-rank on an ascending slice.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
@@ -27,38 +27,55 @@ func (s *Service) prepareAttachments(
 	workspacePath string,
 	prompt string,
 	contextMap map[string]any,
-) (string, map[string]any, []RunAttachment, error) {
+	strictMentions bool,
+) (string, map[string]any, []RunAttachment, []string, error) {
 	refs, err := parseAttachmentRefs(contextMap)
 	if err != nil {
-		return "", nil, nil, err
-	}
-	if len(refs) == 0 {
-		return prompt, contextMap, nil, nil
+		return "", nil, nil, nil, err
 	}
 	absWorkspace, err := filepath.Abs(workspacePath)
 	if err != nil {
-		return "", nil, nil, fmt.Errorf("resolve workspace path: %w", err)
-	}
-	attachRoot := filepath.Join(absWorkspace, ".elix", "attachments")
-	if err := os.MkdirAll(attachRoot, 0o755); err != nil {
-		return "", nil, nil, fmt.Errorf("prepare attachment dir: %w", err)
+		return "", nil, nil, nil, fmt.Errorf("resolve workspace path: %w", err)
 	}
 
-	aliasToPath := map[string]string{}
+	// Aliases are settled before anything is copied so a strict mention
+	// check can reject the run without leaving files behind.
+	type resolvedRef struct {
+		file  ledger.FileRecord
+		alias string
+	}
+	resolvedRefs := make([]resolvedRef, 0, len(refs))
 	usedAlias := map[string]struct{}{}
-	attachments := make([]RunAttachment, 0, len(refs))
 	for _, ref := range refs {
 		fileRec, err := s.ledger.GetFile(ctx, ref.FileID)
 		if err != nil {
-			return "", nil, nil, err
+			return "", nil, nil, nil, err
 		}
 		alias := chooseAlias(ref.Alias, fileRec.OriginalName, fileRec.FileID, usedAlias)
 		usedAlias[alias] = struct{}{}
+		resolvedRefs = append(resolvedRefs, resolvedRef{file: fileRec, alias: alias})
+	}
+	unresolved := unresolvedMentions(prompt, usedAlias, absWorkspace)
+	if strictMentions && len(unresolved) > 0 {
+		return "", nil, nil, nil, &UnresolvedMentionsError{Mentions: unresolved}
+	}
+	if len(refs) == 0 {
+		return prompt, contextMap, nil, unresolved, nil
+	}
+	attachRoot := filepath.Join(absWorkspace, ".elix", "attachments")
+	if err := os.MkdirAll(attachRoot, 0o755); err != nil {
+		return "", nil, nil, nil, fmt.Errorf("prepare attachment dir: %w", err)
+	}
+
+	aliasToPath := map[string]string{}
+	attachments := make([]RunAttachment, 0, len(refs))
+	for _, ref := range resolvedRefs {
+		fileRec, alias := ref.file, ref.alias
 
 		relPath := filepath.ToSlash(filepath.Join(".elix", "attachments", alias))
 		dst := filepath.Join(absWorkspace, filepath.FromSlash(relPath))
 		if err := copyFile(filepath.Join(s.fileStoreDir, fileRec.StorageKey), dst); err != nil {
-			return "", nil, nil, err
+			return "", nil, nil, nil, err
 		}
 		if err := s.ledger.CreateRunAttachment(ctx, ledger.RunAttachmentRecord{
 			RunID:            runID,
@@ -67,7 +84,7 @@ func (s *Service) prepareAttachments(
 			MaterializedPath: relPath,
 			CreatedAt:        time.Now().UTC(),
 		}); err != nil {
-			return "", nil, nil, err
+			return "", nil, nil, nil, err
 		}
 		target := "./" + relPath
 		aliasToPath[alias] = target
@@ -100,7 +117,7 @@ func (s *Service) prepareAttachments(
 	if len(mentionMap) > 0 {
 		contextMap["mention_replacements"] = mentionMap
 	}
-	return rewrittenPrompt, contextMap, attachments, nil
+	return rewrittenPrompt, contextMap, attachments, unresolved, nil
 }
 
 func parseAttachmentRefs(contextMap map[string]any) ([]attachmentRef, error) {
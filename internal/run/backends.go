@@ -0,0 +1,161 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"echohelix/internal/adapter/supervisor"
+	"echohelix/internal/driver/external"
+	"echohelix/internal/rpc/transport"
+)
+
+var (
+	ErrBackendExists      = errors.New("backend is already registered")
+	ErrBackendNotFound    = errors.New("backend is not registered")
+	ErrBackendNotExternal = errors.New("backend was not registered at runtime")
+	ErrBackendBusy        = errors.New("backend has active runs")
+	ErrBackendUnhealthy   = errors.New("backend failed its health probe")
+)
+
+// backendProbeTimeout bounds how long a new backend may take to answer its
+// first health and capabilities calls, including a supervised adapter's
+// start-up.
+const backendProbeTimeout = 15 * time.Second
+
+var backendNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// ExternalBackend describes an adapter attached to a running bridge. With
+// BinaryPath set the bridge starts and supervises the adapter itself,
+// listening on GRPCAddr; otherwise the adapter is expected to be running.
+type ExternalBackend struct {
+	Name       string
+	GRPCAddr   string
+	BinaryPath string
+	Env        []string
+	Security   transport.Security
+}
+
+type externalBackend struct {
+	cfg          ExternalBackend
+	driver       *external.Driver
+	registeredAt time.Time
+	stopMonitor  context.CancelFunc
+}
+
+// RegisterBackend attaches an external adapter. The backend only becomes
+// visible to submits once it has answered a health check and reported its
+// capabilities; a supervised adapter that fails the probe is stopped again.
+func (s *Service) RegisterBackend(ctx context.Context, cfg ExternalBackend) error {
+	if !backendNamePattern.MatchString(cfg.Name) {
+		return fmt.Errorf("invalid backend name %q", cfg.Name)
+	}
+	if cfg.GRPCAddr == "" {
+		return fmt.Errorf("grpc_addr is required")
+	}
+	if cfg.BinaryPath != "" && !filepath.IsAbs(cfg.BinaryPath) {
+		return fmt.Errorf("binary_path must be absolute")
+	}
+	if _, err := s.registry.Get(cfg.Name); err == nil {
+		return ErrBackendExists
+	}
+
+	var sup *supervisor.Supervisor
+	if cfg.BinaryPath != "" {
+		sup = supervisor.New(supervisor.Config{
+			Name:       cfg.Name,
+			BinaryPath: cfg.BinaryPath,
+			GRPCAddr:   cfg.GRPCAddr,
+			Env:        append(append([]string(nil), cfg.Env...), cfg.Security.Env()...),
+		})
+	}
+	drv := external.New(cfg.Name, cfg.GRPCAddr, sup)
+	drv.SetTransportSecurity(cfg.Security)
+	if err := probeBackend(ctx, drv); err != nil {
+		_ = drv.Close()
+		return fmt.Errorf("%w: %v", ErrBackendUnhealthy, err)
+	}
+	if !s.registry.RegisterNew(drv) {
+		_ = drv.Close()
+		return ErrBackendExists
+	}
+
+	ext := &externalBackend{cfg: cfg, driver: drv, registeredAt: time.Now().UTC()}
+	s.mu.Lock()
+	if s.externalBackends == nil {
+		s.externalBackends = map[string]*externalBackend{}
+	}
+	s.externalBackends[cfg.Name] = ext
+	monitorCtx := s.monitorCtx
+	s.mu.Unlock()
+	if sup != nil && monitorCtx != nil {
+		mctx, cancel := context.WithCancel(monitorCtx)
+		ext.stopMonitor = cancel
+		s.monitorAdapter(mctx, drv, sup)
+	}
+	return nil
+}
+
+// probeBackend polls the adapter until it reports healthy and answers a
+// capabilities call, giving a freshly started adapter time to listen.
+func probeBackend(ctx context.Context, drv *external.Driver) error {
+	ctx, cancel := context.WithTimeout(ctx, backendProbeTimeout)
+	defer cancel()
+	var lastErr error
+	for {
+		h, err := drv.Health(ctx)
+		if err == nil && !h.OK {
+			err = errors.New(h.Message)
+		}
+		if err == nil {
+			_, err = drv.Capabilities(ctx)
+		}
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		select {
+		case <-ctx.Done():
+			return lastErr
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+// UnregisterBackend detaches a backend added with RegisterBackend once it
+// has no active runs, stopping its adapter if the bridge supervises it.
+func (s *Service) UnregisterBackend(ctx context.Context, name string) error {
+	s.mu.Lock()
+	ext, ok := s.externalBackends[name]
+	if !ok {
+		s.mu.Unlock()
+		if _, err := s.registry.Get(name); err == nil {
+			return ErrBackendNotExternal
+		}
+		return ErrBackendNotFound
+	}
+	for _, ar := range s.active {
+		if ar.backend == name && !isTerminalStatus(ar.status) {
+			s.mu.Unlock()
+			return ErrBackendBusy
+		}
+	}
+	delete(s.externalBackends, name)
+	s.mu.Unlock()
+
+	s.registry.Unregister(name)
+	if ext.stopMonitor != nil {
+		ext.stopMonitor()
+	}
+	return ext.driver.Close()
+}
+
+func (s *Service) externalBackend(name string) (*externalBackend, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ext, ok := s.externalBackends[name]
+	return ext, ok
+}
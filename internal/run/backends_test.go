@@ -0,0 +1,119 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"echohelix/internal/adapter/runtime"
+	"echohelix/internal/driver"
+	adapterrpc "echohelix/internal/rpc/adapter"
+	"echohelix/internal/rpc/codec"
+
+	"google.golang.org/grpc"
+)
+
+func TestRegisterBackendProbesAndUnregisters(t *testing.T) {
+	svc := setupService(t, newFakeDriver("codex", false))
+	ctx := context.Background()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(grpc.ForceServerCodec(codec.JSONCodec{}))
+	adapterrpc.RegisterAdapterServer(srv, runtime.NewServer(runtime.Config{Backend: "aider", Tools: []string{"shell"}}))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	short, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	err = svc.RegisterBackend(short, ExternalBackend{Name: "dead", GRPCAddr: "127.0.0.1:1"})
+	if !errors.Is(err, ErrBackendUnhealthy) {
+		t.Fatalf("expected unhealthy probe error, got %v", err)
+	}
+	if _, err := svc.registry.Get("dead"); err == nil {
+		t.Fatalf("backend that failed its probe must not be registered")
+	}
+
+	if err := svc.RegisterBackend(ctx, ExternalBackend{Name: "aider", GRPCAddr: lis.Addr().String()}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := svc.RegisterBackend(ctx, ExternalBackend{Name: "aider", GRPCAddr: lis.Addr().String()}); !errors.Is(err, ErrBackendExists) {
+		t.Fatalf("expected duplicate registration to fail, got %v", err)
+	}
+	backends, err := svc.ListBackends(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, b := range backends {
+		if b["name"] == "aider" {
+			found = b["external"] != nil && b["capabilities"] != nil
+		}
+	}
+	if !found {
+		t.Fatalf("registered backend not listed as external: %#v", backends)
+	}
+
+	if err := svc.UnregisterBackend(ctx, "codex"); !errors.Is(err, ErrBackendNotExternal) {
+		t.Fatalf("expected built-in backend to be protected, got %v", err)
+	}
+	if err := svc.UnregisterBackend(ctx, "aider"); err != nil {
+		t.Fatalf("unregister: %v", err)
+	}
+	if _, err := svc.registry.Get("aider"); err == nil {
+		t.Fatalf("backend still registered after unregister")
+	}
+	if err := svc.UnregisterBackend(ctx, "aider"); !errors.Is(err, ErrBackendNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestListModelsPrefersStaticThenAdapter(t *testing.T) {
+	drv := newFakeDriver("codex", false)
+	drv.models = []string{"gpt-5"}
+	svc := setupService(t, drv)
+	ctx := context.Background()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(grpc.ForceServerCodec(codec.JSONCodec{}))
+	adapterrpc.RegisterAdapterServer(srv, runtime.NewServer(runtime.Config{
+		Backend:   "aider",
+		Models:    []string{"sonnet", "haiku"},
+		ModelInfo: []adapterrpc.ModelInfo{{ID: "sonnet", ContextWindow: 200000, Default: true}},
+	}))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	if err := svc.RegisterBackend(ctx, ExternalBackend{Name: "aider", GRPCAddr: lis.Addr().String()}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	list, err := svc.ListModels(ctx, "aider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Source != ModelSourceAdapter || len(list.Models) != 2 || !list.Models[0].Default || list.Models[0].ContextWindow != 200000 || list.Models[1].ID != "haiku" {
+		t.Fatalf("unexpected adapter models: %+v", list)
+	}
+
+	list, err = svc.ListModels(ctx, "codex")
+	if err != nil || list.Source != ModelSourceCapabilities || len(list.Models) != 1 || list.Models[0].ID != "gpt-5" {
+		t.Fatalf("expected capability models, got %+v err=%v", list, err)
+	}
+
+	svc.SetStaticModels(map[string][]driver.ModelInfo{"aider": {{ID: "opus", Default: true}}})
+	list, err = svc.ListModels(ctx, "aider")
+	if err != nil || list.Source != ModelSourceStatic || len(list.Models) != 1 || list.Models[0].ID != "opus" {
+		t.Fatalf("expected static models, got %+v err=%v", list, err)
+	}
+
+	if _, err := svc.ListModels(ctx, "missing"); !errors.Is(err, ErrBackendNotFound) {
+		t.Fatalf("expected backend not found, got %v", err)
+	}
+}
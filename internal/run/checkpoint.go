@@ -0,0 +1,168 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"echohelix/internal/ledger"
+)
+
+var (
+	ErrCheckpointNotFound = errors.New("run has no checkpoint")
+	ErrRunStillActive     = errors.New("run is still active")
+	// ErrWorkspaceHasLaterRuns guards a rollback that would also discard
+	// what newer runs did in the workspace.
+	ErrWorkspaceHasLaterRuns = errors.New("later runs changed the workspace")
+)
+
+type RunCheckpoint struct {
+	HeadCommit     string     `json:"head_commit"`
+	SnapshotCommit string     `json:"snapshot_commit"`
+	CreatedAt      time.Time  `json:"created_at"`
+	RestoredAt     *time.Time `json:"restored_at,omitempty"`
+}
+
+func toRunCheckpoint(rec ledger.RunCheckpointRecord) RunCheckpoint {
+	out := RunCheckpoint{
+		HeadCommit:     rec.HeadCommit,
+		SnapshotCommit: rec.SnapshotCommit,
+		CreatedAt:      rec.CreatedAt,
+	}
+	if !rec.RestoredAt.IsZero() {
+		restored := rec.RestoredAt
+		out.RestoredAt = &restored
+	}
+	return out
+}
+
+func checkpointRef(runID string) string {
+	return "refs/elix/checkpoints/" + runID
+}
+
+// checkpointAvailable reports why a workspace cannot be checkpointed.
+func checkpointAvailable(ctx context.Context, dir string) error {
+	if _, err := runGit(ctx, dir, "rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		return fmt.Errorf("checkpoint requires a git repository with at least one commit")
+	}
+	return nil
+}
+
+// createCheckpoint records the work tree of dir, untracked files included
+// but ignored files left out, as a commit on top of HEAD. It goes through a
+// temporary index so the workspace's own index and stash are untouched; a
+// ref keeps the commit from being garbage collected.
+func (s *Service) createCheckpoint(ctx context.Context, runID, dir string) (ledger.RunCheckpointRecord, error) {
+	head, err := runGit(ctx, dir, "rev-parse", "--verify", "HEAD")
+	if err != nil {
+		return ledger.RunCheckpointRecord{}, err
+	}
+	tmp, err := os.MkdirTemp("", "elix-checkpoint-")
+	if err != nil {
+		return ledger.RunCheckpointRecord{}, err
+	}
+	defer os.RemoveAll(tmp)
+	env := []string{
+		"GIT_INDEX_FILE=" + filepath.Join(tmp, "index"),
+		"GIT_AUTHOR_NAME=elix", "GIT_AUTHOR_EMAIL=elix@localhost",
+		"GIT_COMMITTER_NAME=elix", "GIT_COMMITTER_EMAIL=elix@localhost",
+	}
+	if _, err := runGitEnv(ctx, dir, env, "read-tree", "HEAD"); err != nil {
+		return ledger.RunCheckpointRecord{}, err
+	}
+	if _, err := runGitEnv(ctx, dir, env, "add", "-A"); err != nil {
+		return ledger.RunCheckpointRecord{}, err
+	}
+	tree, err := runGitEnv(ctx, dir, env, "write-tree")
+	if err != nil {
+		return ledger.RunCheckpointRecord{}, err
+	}
+	rec := ledger.RunCheckpointRecord{
+		RunID:         runID,
+		WorkspacePath: dir,
+		HeadCommit:    strings.TrimSpace(string(head)),
+		CreatedAt:     time.Now().UTC(),
+	}
+	snapshot, err := runGitEnv(ctx, dir, env, "commit-tree", strings.TrimSpace(string(tree)), "-p", rec.HeadCommit, "-m", "elix checkpoint before run "+runID)
+	if err != nil {
+		return ledger.RunCheckpointRecord{}, err
+	}
+	rec.SnapshotCommit = strings.TrimSpace(string(snapshot))
+	if _, err := runGit(ctx, dir, "update-ref", checkpointRef(runID), rec.SnapshotCommit); err != nil {
+		return ledger.RunCheckpointRecord{}, err
+	}
+	if err := s.ledger.CreateRunCheckpoint(ctx, rec); err != nil {
+		return ledger.RunCheckpointRecord{}, err
+	}
+	return rec, nil
+}
+
+// RollbackRun restores a finished run's workspace to its checkpoint: HEAD
+// goes back to the pre-run commit, files the run created are removed and
+// the pre-run work tree is restored. Ignored files are left alone, and
+// changes that were staged before the run come back unstaged. Without force
+// it refuses when a run created later used the same workspace.
+func (s *Service) RollbackRun(ctx context.Context, runID string, force bool) (RunCheckpoint, error) {
+	cp, err := s.ledger.GetRunCheckpoint(ctx, runID)
+	if err != nil {
+		if errors.Is(err, ledger.ErrCheckpointNotFound) {
+			return RunCheckpoint{}, ErrCheckpointNotFound
+		}
+		return RunCheckpoint{}, err
+	}
+	rec, err := s.ledger.GetRun(ctx, runID)
+	if err != nil {
+		return RunCheckpoint{}, err
+	}
+	if !isTerminalStatus(rec.Status) {
+		return RunCheckpoint{}, ErrRunStillActive
+	}
+	if err := s.policy.ValidateWorkspace(cp.WorkspacePath); err != nil {
+		return RunCheckpoint{}, fmt.Errorf("%w: %v", ErrWorkspaceNotAllowed, err)
+	}
+	if !force {
+		later, err := s.laterWorkspaceRuns(ctx, rec, cp.WorkspacePath)
+		if err != nil {
+			return RunCheckpoint{}, err
+		}
+		if len(later) > 0 {
+			return RunCheckpoint{}, fmt.Errorf("%w: %s", ErrWorkspaceHasLaterRuns, strings.Join(later, ", "))
+		}
+	}
+	steps := [][]string{
+		{"reset", "-q", "--hard", cp.HeadCommit},
+		{"clean", "-fdq"},
+		{"read-tree", "-u", "--reset", cp.SnapshotCommit},
+		{"reset", "-q"},
+	}
+	for _, args := range steps {
+		if _, err := runGit(ctx, cp.WorkspacePath, args...); err != nil {
+			return RunCheckpoint{}, fmt.Errorf("rollback: %w", err)
+		}
+	}
+	cp.RestoredAt = time.Now().UTC()
+	if err := s.ledger.MarkRunCheckpointRestored(ctx, runID, cp.RestoredAt); err != nil {
+		return RunCheckpoint{}, err
+	}
+	return toRunCheckpoint(cp), nil
+}
+
+// laterWorkspaceRuns returns the ids of runs created after rec in the same
+// workspace directory.
+func (s *Service) laterWorkspaceRuns(ctx context.Context, rec ledger.RunRecord, workspace string) ([]string, error) {
+	runs, err := s.ledger.ListRunsCreatedBetween(ctx, rec.CreatedAt, time.Now().UTC().Add(time.Second))
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, r := range runs {
+		if r.ID != rec.ID && filepath.Clean(r.Workspace) == filepath.Clean(workspace) {
+			ids = append(ids, r.ID)
+		}
+	}
+	return ids, nil
+}
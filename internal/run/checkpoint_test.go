@@ -0,0 +1,118 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRollbackRestoresCheckpoint(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return "<missing>"
+		}
+		return string(b)
+	}
+	git("init", "-q")
+	write("a.txt", "one\n")
+	write("gone.txt", "bye\n")
+	git("add", ".")
+	git("commit", "-qm", "init")
+	head := git("rev-parse", "HEAD")
+	// Pre-run state: an uncommitted edit, an untracked file and a deletion.
+	write("a.txt", "two\n")
+	write("notes.txt", "mine\n")
+	if err := os.Remove(filepath.Join(dir, "gone.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := setupService(t, newFakeDriver("codex", false))
+	r, err := svc.Submit(context.Background(), SubmitRequest{
+		WorkspaceID:   "ws-cp",
+		WorkspacePath: dir,
+		Backend:       "codex",
+		Prompt:        "make a mess",
+		Options:       RunOptions{Checkpoint: true},
+	})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, r.ID, StatusCompleted)
+	got, err := svc.GetRun(context.Background(), r.ID)
+	if err != nil || got.Checkpoint == nil || got.Checkpoint.HeadCommit != head {
+		t.Fatalf("expected checkpoint on run: %#v %v", got.Checkpoint, err)
+	}
+	if git("status", "--porcelain") == "" || read("a.txt") != "two\n" {
+		t.Fatalf("checkpoint must not change the workspace")
+	}
+
+	// What the agent did.
+	write("a.txt", "three\n")
+	write("new.txt", "agent\n")
+	if err := os.Remove(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "-A")
+	git("commit", "-qm", "agent commit")
+
+	if _, err := svc.RollbackRun(context.Background(), r.ID, false); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if git("rev-parse", "HEAD") != head {
+		t.Fatalf("HEAD not restored")
+	}
+	if read("a.txt") != "two\n" || read("notes.txt") != "mine\n" || read("new.txt") != "<missing>" || read("gone.txt") != "<missing>" {
+		t.Fatalf("workspace not restored: a=%q notes=%q new=%q gone=%q", read("a.txt"), read("notes.txt"), read("new.txt"), read("gone.txt"))
+	}
+	got, _ = svc.GetRun(context.Background(), r.ID)
+	if got.Checkpoint == nil || got.Checkpoint.RestoredAt == nil {
+		t.Fatalf("expected restored_at: %#v", got.Checkpoint)
+	}
+
+	plain, err := svc.Submit(context.Background(), SubmitRequest{WorkspaceID: "ws-cp", WorkspacePath: dir, Backend: "codex", Prompt: "no checkpoint"})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, plain.ID, StatusCompleted)
+	if _, err := svc.RollbackRun(context.Background(), plain.ID, false); !errors.Is(err, ErrCheckpointNotFound) {
+		t.Fatalf("expected ErrCheckpointNotFound, got %v", err)
+	}
+	// The later run's work would be lost, so it takes force.
+	write("plain.txt", "later run\n")
+	if _, err := svc.RollbackRun(context.Background(), r.ID, false); !errors.Is(err, ErrWorkspaceHasLaterRuns) || !strings.Contains(err.Error(), plain.ID) {
+		t.Fatalf("expected ErrWorkspaceHasLaterRuns naming %s, got %v", plain.ID, err)
+	}
+	if read("plain.txt") != "later run\n" {
+		t.Fatalf("refused rollback changed the workspace")
+	}
+	if _, err := svc.RollbackRun(context.Background(), r.ID, true); err != nil || read("plain.txt") != "<missing>" {
+		t.Fatalf("forced rollback: %v, plain.txt=%q", err, read("plain.txt"))
+	}
+	if _, err := svc.Submit(context.Background(), SubmitRequest{WorkspacePath: t.TempDir(), Backend: "codex", Prompt: "x", Options: RunOptions{Checkpoint: true}}); err == nil {
+		t.Fatalf("expected checkpoint submit outside a git repository to fail")
+	}
+}
@@ -0,0 +1,128 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"echohelix/internal/events"
+	"echohelix/internal/ledger"
+)
+
+type EventDiagnostics struct {
+	DuplicateSeq    int64 `json:"duplicate_seq"`
+	Resequenced     int64 `json:"resequenced"`
+	PersistFailures int64 `json:"persist_failures"`
+	// Persist* report the async persistence queues (EVENT_PERSIST_WORKERS).
+	PersistQueued         int64 `json:"persist_queued"`
+	PersistQueueHighWater int64 `json:"persist_queue_high_water"`
+	PersistDropped        int64 `json:"persist_dropped"`
+	PersistOverflowInline int64 `json:"persist_overflow_inline"`
+	PersistBatches        int64 `json:"persist_batches"`
+	// Hub and SessionHub report live subscriber backpressure.
+	Hub        events.BackpressureStats  `json:"hub"`
+	SessionHub *events.BackpressureStats `json:"session_hub,omitempty"`
+}
+
+type eventCounters struct {
+	duplicateSeq    atomic.Int64
+	resequenced     atomic.Int64
+	persistFailures atomic.Int64
+
+	persistQueued         atomic.Int64
+	persistHighWater      atomic.Int64
+	persistDropped        atomic.Int64
+	persistOverflowInline atomic.Int64
+	persistBatches        atomic.Int64
+}
+
+// SetResequenceOnDuplicateSeq controls whether events rejected by the ledger
+// for an already-used seq are assigned a fresh bridge-side seq and retried.
+func (s *Service) SetResequenceOnDuplicateSeq(enabled bool) {
+	s.mu.Lock()
+	s.resequenceDuplicates = enabled
+	s.mu.Unlock()
+}
+
+func (s *Service) EventDiagnostics() EventDiagnostics {
+	return EventDiagnostics{
+		DuplicateSeq:    s.diag.duplicateSeq.Load(),
+		Resequenced:     s.diag.resequenced.Load(),
+		PersistFailures: s.diag.persistFailures.Load(),
+
+		PersistQueued:         s.diag.persistQueued.Load(),
+		PersistQueueHighWater: s.diag.persistHighWater.Load(),
+		PersistDropped:        s.diag.persistDropped.Load(),
+		PersistOverflowInline: s.diag.persistOverflowInline.Load(),
+		PersistBatches:        s.diag.persistBatches.Load(),
+
+		Hub: s.hub.Stats(),
+	}
+}
+
+// appendAndPublish persists ev and fans it out to subscribers. Subscribers
+// always receive the event, even when it could not be persisted. With async
+// persistence the event is published first and written by the run's queue.
+func (s *Service) appendAndPublish(ctx context.Context, ev events.Event) {
+	if s.enqueuePersist(ev) {
+		s.hub.Publish(ev)
+		return
+	}
+	s.persistEvent(ctx, ev)
+	s.hub.Publish(ev)
+}
+
+func (s *Service) persistEvent(ctx context.Context, ev events.Event) {
+	err := s.ledger.AppendEvent(ctx, ev)
+	if errors.Is(err, ledger.ErrDuplicateSeq) {
+		s.diag.duplicateSeq.Add(1)
+		s.mu.Lock()
+		resequence := s.resequenceDuplicates
+		s.mu.Unlock()
+		if resequence {
+			err = s.resequence(ctx, &ev)
+			if err == nil {
+				s.diag.resequenced.Add(1)
+			}
+		}
+	}
+	if err != nil {
+		s.diag.persistFailures.Add(1)
+		log.Printf("append event run=%s seq=%d type=%s: %v", ev.RunID, ev.Seq, ev.Type, err)
+	}
+}
+
+func (s *Service) resequence(ctx context.Context, ev *events.Event) error {
+	seq, err := s.ledger.NextSeq(ctx, ev.RunID)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	if ar := s.active[ev.RunID]; ar != nil {
+		if ar.seq <= seq {
+			ar.seq = seq + 1
+		} else {
+			seq = ar.seq
+			ar.seq++
+		}
+	}
+	s.mu.Unlock()
+	ev.Seq = seq
+	return s.ledger.AppendEvent(ctx, *ev)
+}
+
+// VerifyLedger runs the ledger integrity checks, including whether uploaded
+// file contents still exist in the file store.
+func (s *Service) VerifyLedger(ctx context.Context, staleAfter time.Duration) (ledger.IntegrityReport, error) {
+	return s.ledger.Verify(ctx, ledger.VerifyOptions{
+		StaleAfter: staleAfter,
+		BlobExists: func(storageKey string) bool {
+			_, err := os.Stat(filepath.Join(s.fileStoreDir, storageKey))
+			return err == nil
+		},
+	})
+}
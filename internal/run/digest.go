@@ -0,0 +1,259 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"echohelix/internal/ledger"
+	"echohelix/internal/signing"
+)
+
+const (
+	// DigestAllPrincipals is the bridge-wide digest covering every principal.
+	DigestAllPrincipals = "*"
+	// digestUnattributed keys runs submitted with the bootstrap token, which
+	// carry no device address; the audit log records the same actor.
+	digestUnattributed = "static"
+	maxDigestErrors    = 5
+	digestDayLayout    = "2006-01-02"
+)
+
+// digestApprovalEvents are the audit events counted as resolved approvals.
+var digestApprovalEvents = map[string]bool{
+	"session_approval_resolved": true,
+	"session_request_resolved":  true,
+	"scope_request_approve":     true,
+	"scope_request_deny":        true,
+}
+
+// Digest summarizes one principal's activity over a UTC day. Runs are
+// counted by the day they were submitted, tokens by the day they were
+// recorded.
+type Digest struct {
+	Day               string        `json:"day"`
+	Principal         string        `json:"principal"`
+	RunsSubmitted     int           `json:"runs_submitted"`
+	RunsCompleted     int           `json:"runs_completed"`
+	RunsFailed        int           `json:"runs_failed"`
+	RunsCancelled     int           `json:"runs_cancelled"`
+	ApprovalsResolved int           `json:"approvals_resolved"`
+	InputTokens       int64         `json:"input_tokens"`
+	OutputTokens      int64         `json:"output_tokens"`
+	TotalTokens       int64         `json:"total_tokens"`
+	CostUSD           *float64      `json:"cost_usd,omitempty"`
+	NotableErrors     []DigestError `json:"notable_errors,omitempty"`
+	GeneratedAt       time.Time     `json:"generated_at"`
+}
+
+type DigestError struct {
+	RunID   string `json:"run_id"`
+	Backend string `json:"backend"`
+	Error   string `json:"error"`
+}
+
+type DigestQuery struct {
+	Principal string
+	From, To  string
+	Limit     int
+}
+
+// DigestSink delivers a generated digest, e.g. to a webhook.
+type DigestSink func(ctx context.Context, digest Digest)
+
+type digestState struct {
+	mu   sync.Mutex
+	sink DigestSink
+}
+
+// SetDigestSink receives every digest GenerateDailyDigests produces.
+func (s *Service) SetDigestSink(sink DigestSink) {
+	s.digest.mu.Lock()
+	s.digest.sink = sink
+	s.digest.mu.Unlock()
+}
+
+// GenerateDailyDigests compiles, stores and delivers the digests for the
+// UTC day containing day: one per active principal plus the bridge-wide
+// DigestAllPrincipals digest, which is stored even for an idle day so the
+// scheduler does not generate it twice.
+func (s *Service) GenerateDailyDigests(ctx context.Context, day time.Time) ([]Digest, error) {
+	day = day.UTC()
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+	dayKey := from.Format(digestDayLayout)
+
+	runs, err := s.ledger.ListRunsCreatedBetween(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("list runs: %w", err)
+	}
+	usage, err := s.ledger.ListTokenUsage(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("list token usage: %w", err)
+	}
+	audit, err := s.ledger.ListAudit(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("list audit: %w", err)
+	}
+
+	now := time.Now().UTC()
+	all := &Digest{Day: dayKey, Principal: DigestAllPrincipals, GeneratedAt: now}
+	digests := map[string]*Digest{DigestAllPrincipals: all}
+	get := func(principal string) []*Digest {
+		if principal == "" {
+			principal = digestUnattributed
+		}
+		d, ok := digests[principal]
+		if !ok {
+			d = &Digest{Day: dayKey, Principal: principal, GeneratedAt: now}
+			digests[principal] = d
+		}
+		return []*Digest{d, all}
+	}
+
+	models := map[string]string{}
+	for _, rec := range runs {
+		models[rec.ID] = rec.Options.Model
+		for _, d := range get(rec.SubmittedBy) {
+			d.RunsSubmitted++
+			switch rec.Status {
+			case StatusCompleted:
+				d.RunsCompleted++
+			case StatusFailed:
+				d.RunsFailed++
+				if rec.Error != "" && len(d.NotableErrors) < maxDigestErrors {
+					d.NotableErrors = append(d.NotableErrors, DigestError{RunID: rec.ID, Backend: rec.Backend, Error: rec.Error})
+				}
+			case StatusCancelled:
+				d.RunsCancelled++
+			}
+		}
+	}
+	priced := s.pricingConfigured()
+	for _, rec := range usage {
+		var cost float64
+		price, hasPrice := s.priceFor(rec.Backend, models[rec.RunID])
+		if hasPrice {
+			cost = price.cost(rec.InputTokens, rec.OutputTokens)
+		}
+		for _, d := range get(rec.SubmittedBy) {
+			d.InputTokens += rec.InputTokens
+			d.OutputTokens += rec.OutputTokens
+			d.TotalTokens += rec.TotalTokens
+			if priced {
+				if d.CostUSD == nil {
+					d.CostUSD = new(float64)
+				}
+				*d.CostUSD = roundUSD(*d.CostUSD + cost)
+			}
+		}
+	}
+	for _, rec := range audit {
+		if !digestApprovalEvents[rec.Event] {
+			continue
+		}
+		for _, d := range get(rec.Actor) {
+			d.ApprovalsResolved++
+		}
+	}
+
+	out := make([]Digest, 0, len(digests))
+	for _, d := range digests {
+		out = append(out, *d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Principal < out[j].Principal })
+
+	s.digest.mu.Lock()
+	sink := s.digest.sink
+	s.digest.mu.Unlock()
+	for _, d := range out {
+		payload, err := json.Marshal(d)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.ledger.SaveDigest(ctx, ledger.DigestRecord{Day: dayKey, Principal: d.Principal, Payload: string(payload), CreatedAt: now}); err != nil {
+			return nil, fmt.Errorf("save digest: %w", err)
+		}
+		if sink != nil {
+			sink(ctx, d)
+		}
+	}
+	return out, nil
+}
+
+// ListDigests returns stored digests, newest day first. From and To are
+// inclusive YYYY-MM-DD days.
+func (s *Service) ListDigests(ctx context.Context, q DigestQuery) ([]Digest, error) {
+	for _, day := range []string{q.From, q.To} {
+		if day == "" {
+			continue
+		}
+		if _, err := time.Parse(digestDayLayout, day); err != nil {
+			return nil, fmt.Errorf("invalid day %q, want YYYY-MM-DD", day)
+		}
+	}
+	recs, err := s.ledger.ListDigests(ctx, ledger.DigestQuery{Principal: q.Principal, FromDay: q.From, ToDay: q.To, Limit: q.Limit})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Digest, 0, len(recs))
+	for _, rec := range recs {
+		var d Digest
+		if err := json.Unmarshal([]byte(rec.Payload), &d); err != nil {
+			return nil, fmt.Errorf("decode digest %s/%s: %w", rec.Day, rec.Principal, err)
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// StartDigestScheduler generates the previous UTC day's digests once the
+// day is over, checking every interval. Only the leader generates them.
+func (s *Service) StartDigestScheduler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if s.isLeader() {
+				yesterday := time.Now().UTC().AddDate(0, 0, -1)
+				done, err := s.ledger.HasDigestDay(ctx, yesterday.Format(digestDayLayout))
+				if err != nil {
+					log.Printf("warn: check daily digests: %v", err)
+				} else if !done {
+					if digests, err := s.GenerateDailyDigests(ctx, yesterday); err != nil {
+						log.Printf("warn: generate daily digests: %v", err)
+					} else {
+						log.Printf("daily digests generated day=%s count=%d", yesterday.Format(digestDayLayout), len(digests))
+					}
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// NewWebhookDigestSink POSTs each digest as JSON to url, signed with signer
+// when one is configured. Email or push delivery can hang off the receiver.
+func NewWebhookDigestSink(url string, signer *signing.Signer) DigestSink {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return func(ctx context.Context, digest Digest) {
+		if err := signing.PostJSON(ctx, client, url, signer, map[string]any{
+			"event":  "daily_digest",
+			"digest": digest,
+		}); err != nil {
+			log.Printf("digest notify: %v", err)
+		}
+	}
+}
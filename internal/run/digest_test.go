@@ -0,0 +1,95 @@
+package run
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"echohelix/internal/events"
+	"echohelix/internal/ledger"
+)
+
+func TestGenerateDailyDigestsPerPrincipal(t *testing.T) {
+	drv := newFakeDriver("codex", false)
+	drv.script = []events.Event{{
+		Type: events.TypeDone,
+		Payload: map[string]any{
+			"status": "completed",
+			"usage":  map[string]any{"input_tokens": 600000, "output_tokens": 100000, "total_tokens": 700000},
+		},
+		Source: "fake",
+	}}
+	svc := setupService(t, drv)
+	svc.SetTokenPricing(map[string]TokenPrice{"codex": {InputPerMTok: 1, OutputPerMTok: 10}})
+	ctx := context.Background()
+
+	ok, err := svc.Submit(ctx, SubmitRequest{WorkspaceID: "ws-1", WorkspacePath: "/tmp", Prompt: "one", SubmittedBy: "dev-1"})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, ok.ID, StatusCompleted)
+	now := time.Now().UTC()
+	if err := svc.ledger.CreateRun(ctx, ledger.RunRecord{ID: "failed-1", WorkspaceID: "ws-1", Workspace: "/tmp", Backend: "codex", Status: StatusQueued, SubmittedBy: "dev-1", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("create run: %v", err)
+	}
+	if err := svc.ledger.UpdateRunStatus(ctx, "failed-1", StatusFailed, "adapter crashed"); err != nil {
+		t.Fatalf("fail run: %v", err)
+	}
+	if err := svc.RecordAudit(ctx, ledger.AuditRecord{Event: "session_approval_resolved", Actor: "dev-2"}); err != nil {
+		t.Fatalf("record audit: %v", err)
+	}
+	if err := svc.RecordAudit(ctx, ledger.AuditRecord{Event: "device_revoke", Actor: "dev-2"}); err != nil {
+		t.Fatalf("record audit: %v", err)
+	}
+
+	var mu sync.Mutex
+	delivered := map[string]Digest{}
+	svc.SetDigestSink(func(_ context.Context, d Digest) {
+		mu.Lock()
+		delivered[d.Principal] = d
+		mu.Unlock()
+	})
+	digests, err := svc.GenerateDailyDigests(ctx, now)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if len(digests) != 3 || len(delivered) != 3 {
+		t.Fatalf("expected digests for *, dev-1 and dev-2, got %#v", digests)
+	}
+	dev1 := delivered["dev-1"]
+	if dev1.RunsSubmitted != 2 || dev1.RunsCompleted != 1 || dev1.RunsFailed != 1 || dev1.TotalTokens != 700000 {
+		t.Fatalf("unexpected dev-1 digest: %#v", dev1)
+	}
+	if dev1.CostUSD == nil || *dev1.CostUSD != 1.6 {
+		t.Fatalf("unexpected dev-1 cost: %#v", dev1.CostUSD)
+	}
+	if len(dev1.NotableErrors) != 1 || dev1.NotableErrors[0].Error != "adapter crashed" {
+		t.Fatalf("unexpected notable errors: %#v", dev1.NotableErrors)
+	}
+	if dev2 := delivered["dev-2"]; dev2.ApprovalsResolved != 1 || dev2.RunsSubmitted != 0 {
+		t.Fatalf("unexpected dev-2 digest: %#v", dev2)
+	}
+	if all := delivered[DigestAllPrincipals]; all.RunsSubmitted != 2 || all.ApprovalsResolved != 1 {
+		t.Fatalf("unexpected bridge-wide digest: %#v", all)
+	}
+
+	day := now.Format("2006-01-02")
+	if done, err := svc.ledger.HasDigestDay(ctx, day); err != nil || !done {
+		t.Fatalf("expected day to be marked generated: %v %v", done, err)
+	}
+	// Regenerating replaces the stored digests instead of adding rows.
+	if _, err := svc.GenerateDailyDigests(ctx, now); err != nil {
+		t.Fatalf("regenerate: %v", err)
+	}
+	stored, err := svc.ListDigests(ctx, DigestQuery{Principal: "dev-1", From: day, To: day})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(stored) != 1 || stored[0].RunsFailed != 1 {
+		t.Fatalf("unexpected stored digests: %#v", stored)
+	}
+	if _, err := svc.ListDigests(ctx, DigestQuery{From: "yesterday"}); err == nil {
+		t.Fatalf("expected malformed day to be rejected")
+	}
+}
@@ -0,0 +1,101 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"echohelix/internal/envprofile"
+	"echohelix/internal/ledger"
+)
+
+var ErrEnvProfileNotFound = errors.New("env profile not found")
+
+type WorkspaceEnvProfile struct {
+	WorkspaceID string `json:"workspace_id"`
+	envprofile.Profile
+	UpdatedBy string    `json:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (s *Service) PutEnvProfile(ctx context.Context, workspaceID string, p envprofile.Profile, updatedBy string) (WorkspaceEnvProfile, error) {
+	workspaceID = strings.TrimSpace(workspaceID)
+	if workspaceID == "" {
+		return WorkspaceEnvProfile{}, fmt.Errorf("workspace_id is required")
+	}
+	if err := p.Validate(); err != nil {
+		return WorkspaceEnvProfile{}, err
+	}
+	rec := ledger.EnvProfileRecord{
+		WorkspaceID: workspaceID,
+		Env:         p.Env,
+		PathPrepend: p.PathPrepend,
+		ShellInit:   strings.TrimSpace(p.ShellInit),
+		UpdatedBy:   strings.TrimSpace(updatedBy),
+		UpdatedAt:   time.Now().UTC(),
+	}
+	if err := s.ledger.UpsertEnvProfile(ctx, rec); err != nil {
+		return WorkspaceEnvProfile{}, err
+	}
+	return envProfileFromRecord(rec), nil
+}
+
+func (s *Service) GetEnvProfile(ctx context.Context, workspaceID string) (WorkspaceEnvProfile, error) {
+	rec, err := s.ledger.GetEnvProfile(ctx, strings.TrimSpace(workspaceID))
+	if err != nil {
+		if errors.Is(err, ledger.ErrEnvProfileNotFound) {
+			return WorkspaceEnvProfile{}, ErrEnvProfileNotFound
+		}
+		return WorkspaceEnvProfile{}, err
+	}
+	return envProfileFromRecord(rec), nil
+}
+
+func (s *Service) ListEnvProfiles(ctx context.Context) ([]WorkspaceEnvProfile, error) {
+	recs, err := s.ledger.ListEnvProfiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]WorkspaceEnvProfile, 0, len(recs))
+	for _, rec := range recs {
+		out = append(out, envProfileFromRecord(rec))
+	}
+	return out, nil
+}
+
+func (s *Service) DeleteEnvProfile(ctx context.Context, workspaceID string) error {
+	err := s.ledger.DeleteEnvProfile(ctx, strings.TrimSpace(workspaceID))
+	if errors.Is(err, ledger.ErrEnvProfileNotFound) {
+		return ErrEnvProfileNotFound
+	}
+	return err
+}
+
+// ResolveEnvProfile returns the launch profile for a workspace, or the zero
+// profile when none is configured. Session services use it as their resolver.
+func (s *Service) ResolveEnvProfile(ctx context.Context, workspaceID string) envprofile.Profile {
+	workspaceID = strings.TrimSpace(workspaceID)
+	if workspaceID == "" {
+		return envprofile.Profile{}
+	}
+	rec, err := s.ledger.GetEnvProfile(ctx, workspaceID)
+	if err != nil {
+		return envprofile.Profile{}
+	}
+	return envProfileFromRecord(rec).Profile
+}
+
+func envProfileFromRecord(rec ledger.EnvProfileRecord) WorkspaceEnvProfile {
+	return WorkspaceEnvProfile{
+		WorkspaceID: rec.WorkspaceID,
+		Profile: envprofile.Profile{
+			Env:         rec.Env,
+			PathPrepend: rec.PathPrepend,
+			ShellInit:   rec.ShellInit,
+		},
+		UpdatedBy: rec.UpdatedBy,
+		UpdatedAt: rec.UpdatedAt,
+	}
+}
@@ -0,0 +1,140 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// imageTokenEstimate is charged per image attachment; vision models bill
+// images by tile, not by byte.
+const imageTokenEstimate = 1000
+
+// charsPerToken approximates each backend's tokenizer on ASCII text. Other
+// runes (CJK, emoji, accented letters) are counted as one token each.
+var charsPerToken = map[string]float64{
+	"codex":  4.0,
+	"gemini": 4.0,
+	"claude": 3.5,
+}
+
+const defaultCharsPerToken = 4.0
+
+// Estimate is the pre-submit input size of a run request.
+type Estimate struct {
+	Backend          string               `json:"backend"`
+	Model            string               `json:"model,omitempty"`
+	Tokenizer        string               `json:"tokenizer"`
+	PromptTokens     int64                `json:"prompt_tokens"`
+	ContextTokens    int64                `json:"context_tokens"`
+	AttachmentTokens int64                `json:"attachment_tokens"`
+	Attachments      []AttachmentEstimate `json:"attachments,omitempty"`
+	InputTokens      int64                `json:"input_tokens"`
+	// CostUSD prices InputTokens only; it is omitted when no pricing
+	// matches the backend and model.
+	CostUSD *float64 `json:"cost_usd,omitempty"`
+}
+
+type AttachmentEstimate struct {
+	FileID    string `json:"file_id"`
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"size_bytes"`
+	Tokens    int64  `json:"tokens"`
+	// Binary attachments other than images are not read by the CLIs as
+	// text and count as zero tokens.
+	Binary bool `json:"binary,omitempty"`
+}
+
+// EstimateInput approximates the input tokens and cost of req without
+// submitting it. The prompt is measured as Submit would send it: with
+// included prior runs and the workspace prompt injection applied.
+func (s *Service) EstimateInput(ctx context.Context, req SubmitRequest) (Estimate, error) {
+	if req.Backend == "" {
+		req.Backend = "codex"
+	}
+	if req.Prompt == "" {
+		return Estimate{}, fmt.Errorf("prompt is required")
+	}
+	if err := s.policy.ValidateWorkspace(req.WorkspacePath); err != nil {
+		return Estimate{}, err
+	}
+	if _, err := s.registry.Get(req.Backend); err != nil {
+		return Estimate{}, err
+	}
+	refs, err := parseAttachmentRefs(req.Context)
+	if err != nil {
+		return Estimate{}, err
+	}
+	priorRuns, err := s.resolveIncludedRuns(ctx, req.WorkspaceID, req.Context)
+	if err != nil {
+		return Estimate{}, err
+	}
+	prompt, contextMap := applyIncludedRuns(req.Prompt, req.Context, priorRuns)
+	prompt = s.policy.PromptInjectionFor(req.WorkspaceID, req.WorkspacePath).Wrap(prompt)
+
+	ratio, ok := charsPerToken[req.Backend]
+	if !ok {
+		ratio = defaultCharsPerToken
+	}
+	out := Estimate{
+		Backend:      req.Backend,
+		Model:        req.Options.Model,
+		Tokenizer:    fmt.Sprintf("approx:%.1f_chars_per_token", ratio),
+		PromptTokens: estimateTokens(prompt, ratio),
+	}
+	if len(contextMap) > 0 {
+		raw, err := json.Marshal(contextMap)
+		if err != nil {
+			return Estimate{}, fmt.Errorf("encode context: %w", err)
+		}
+		out.ContextTokens = estimateTokens(string(raw), ratio)
+	}
+	for _, ref := range refs {
+		fileRec, err := s.ledger.GetFile(ctx, ref.FileID)
+		if err != nil {
+			return Estimate{}, err
+		}
+		item := AttachmentEstimate{FileID: fileRec.FileID, Name: fileRec.OriginalName, SizeBytes: fileRec.SizeBytes}
+		switch {
+		case strings.HasPrefix(fileRec.MIMEType, "image/"):
+			item.Tokens = imageTokenEstimate
+		default:
+			data, err := os.ReadFile(filepath.Join(s.fileStoreDir, fileRec.StorageKey))
+			if err != nil {
+				return Estimate{}, fmt.Errorf("read attachment %s: %w", fileRec.FileID, err)
+			}
+			if utf8.Valid(data) {
+				item.Tokens = estimateTokens(string(data), ratio)
+			} else {
+				item.Binary = true
+			}
+		}
+		out.AttachmentTokens += item.Tokens
+		out.Attachments = append(out.Attachments, item)
+	}
+	out.InputTokens = out.PromptTokens + out.ContextTokens + out.AttachmentTokens
+	if price, ok := s.priceFor(req.Backend, req.Options.Model); ok {
+		cost := roundUSD(price.cost(out.InputTokens, 0))
+		out.CostUSD = &cost
+	}
+	return out, nil
+}
+
+// estimateTokens counts ASCII bytes at ratio characters per token and every
+// other rune as one token.
+func estimateTokens(text string, ratio float64) int64 {
+	var ascii, other int64
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return int64(math.Ceil(float64(ascii)/ratio)) + other
+}
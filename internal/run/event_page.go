@@ -0,0 +1,82 @@
+package run
+
+import (
+	"context"
+	"fmt"
+
+	"echohelix/internal/events"
+	"echohelix/internal/ledger"
+)
+
+const (
+	DefaultEventPageLimit = 200
+	MaxEventPageLimit     = 2000
+)
+
+// EventPageRequest pages through a run's stored events. FromSeq is the
+// cursor in ascending order and BeforeSeq in descending order; Offset skips
+// events past the cursor.
+type EventPageRequest struct {
+	FromSeq   int64
+	BeforeSeq int64
+	Offset    int
+	Limit     int
+	Desc      bool
+}
+
+// EventPage is one page of events with the totals clients need to load the
+// rest lazily. NextFromSeq or NextBeforeSeq is set while more events follow
+// in the requested order.
+type EventPage struct {
+	RunID         string         `json:"run_id"`
+	Events        []events.Event `json:"events"`
+	Total         int64          `json:"total"`
+	MaxSeq        int64          `json:"max_seq"`
+	NextFromSeq   int64          `json:"next_from_seq,omitempty"`
+	NextBeforeSeq int64          `json:"next_before_seq,omitempty"`
+}
+
+func (s *Service) ListEventsPage(ctx context.Context, runID string, req EventPageRequest) (EventPage, error) {
+	if req.FromSeq < 0 || req.BeforeSeq < 0 || req.Offset < 0 || req.Limit < 0 {
+		return EventPage{}, fmt.Errorf("from_seq, before_seq, offset and limit must not be negative")
+	}
+	if req.Limit > MaxEventPageLimit {
+		return EventPage{}, fmt.Errorf("limit must be at most %d", MaxEventPageLimit)
+	}
+	if req.BeforeSeq > 0 && !req.Desc {
+		return EventPage{}, fmt.Errorf("before_seq requires order=desc")
+	}
+	if _, err := s.GetRun(ctx, runID); err != nil {
+		return EventPage{}, err
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = DefaultEventPageLimit
+	}
+	// One extra event tells whether another page follows.
+	evs, err := s.ledger.ListEventsPage(ctx, runID, ledger.EventPageQuery{
+		FromSeq:   req.FromSeq,
+		BeforeSeq: req.BeforeSeq,
+		Offset:    int64(req.Offset),
+		Limit:     int64(limit) + 1,
+		Desc:      req.Desc,
+	})
+	if err != nil {
+		return EventPage{}, err
+	}
+	total, maxSeq, err := s.ledger.EventStats(ctx, runID)
+	if err != nil {
+		return EventPage{}, err
+	}
+	page := EventPage{RunID: runID, Events: evs, Total: total, MaxSeq: maxSeq}
+	if len(evs) > limit {
+		page.Events = evs[:limit]
+		last := page.Events[limit-1].Seq
+		if req.Desc {
+			page.NextBeforeSeq = last
+		} else {
+			page.NextFromSeq = last + 1
+		}
+	}
+	return page, nil
+}
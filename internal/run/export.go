@@ -0,0 +1,172 @@
+package run
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"echohelix/internal/events"
+	"echohelix/internal/policy"
+)
+
+// ExportRecord is one NDJSON line of a run export: a leading "run" record
+// followed by one "event" record per ledger event in seq order.
+type ExportRecord struct {
+	Record string        `json:"record"`
+	Run    *Run          `json:"run,omitempty"`
+	Event  *events.Event `json:"event,omitempty"`
+}
+
+// ExportRunNDJSON writes the run metadata and full event history of r as
+// NDJSON, streaming from a ledger cursor so long runs are not held in memory.
+// All exports apply the redaction level of the run's workspace.
+func (s *Service) ExportRunNDJSON(ctx context.Context, r Run, w io.Writer) error {
+	level := s.exportRedaction(r)
+	r = redactRun(r, level)
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(ExportRecord{Record: "run", Run: &r}); err != nil {
+		return err
+	}
+	return s.forEachEvent(ctx, r.ID, func(ev events.Event) error {
+		ev = redactEvent(ev, level)
+		return enc.Encode(ExportRecord{Record: "event", Event: &ev})
+	})
+}
+
+// ExportRunArchive writes a .tar.gz with run.json, events.ndjson and the
+// run's attachments under attachments/<alias>. Attachments are left out at
+// the content redaction level.
+func (s *Service) ExportRunArchive(ctx context.Context, r Run, w io.Writer) error {
+	level := s.exportRedaction(r)
+	r = redactRun(r, level)
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now().UTC()
+
+	meta, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeTarBytes(tw, "run.json", meta, now); err != nil {
+		return err
+	}
+
+	// Events are buffered to a temp file because tar needs the size up front.
+	tmp, err := os.CreateTemp("", "elix-export-*.ndjson")
+	if err != nil {
+		return fmt.Errorf("prepare export: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	enc := json.NewEncoder(tmp)
+	if err := s.forEachEvent(ctx, r.ID, func(ev events.Event) error { return enc.Encode(redactEvent(ev, level)) }); err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, "events.ndjson", tmp, now); err != nil {
+		return err
+	}
+
+	for _, att := range r.Attachments {
+		if level == policy.RedactionContent {
+			break
+		}
+		rec, err := s.ledger.GetFile(ctx, att.FileID)
+		if err != nil {
+			return fmt.Errorf("attachment %s: %w", att.Alias, err)
+		}
+		f, err := os.Open(filepath.Join(s.fileStoreDir, rec.StorageKey))
+		if err != nil {
+			return fmt.Errorf("attachment %s: %w", att.Alias, err)
+		}
+		err = writeTarFile(tw, "attachments/"+att.Alias, f, rec.CreatedAt)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// ExportRunEvents writes the events of r with seq >= fromSeq as bare NDJSON
+// events, one per line, for archiving runs before they are pruned.
+func (s *Service) ExportRunEvents(ctx context.Context, r Run, fromSeq int64, w io.Writer) error {
+	level := s.exportRedaction(r)
+	enc := json.NewEncoder(w)
+	return s.ledger.StreamEvents(ctx, r.ID, fromSeq, func(ev events.Event) error { return enc.Encode(redactEvent(ev, level)) })
+}
+
+func (s *Service) forEachEvent(ctx context.Context, runID string, fn func(ev events.Event) error) error {
+	return s.ledger.StreamEvents(ctx, runID, 0, fn)
+}
+
+const redactedText = "[redacted]"
+
+func (s *Service) exportRedaction(r Run) string {
+	return s.policy.RetentionFor(r.WorkspaceID, r.Workspace).Redaction
+}
+
+func redactRun(r Run, level string) Run {
+	if level != policy.RedactionPrompts && level != policy.RedactionContent {
+		return r
+	}
+	r.Prompt = redactedText
+	r.Context = nil
+	if level == policy.RedactionContent && r.Error != "" {
+		r.Error = redactedText
+	}
+	return r
+}
+
+// redactEvent keeps only the status fields of an event's payload at the
+// content level.
+func redactEvent(ev events.Event, level string) events.Event {
+	if level != policy.RedactionContent {
+		return ev
+	}
+	payload := map[string]any{"redacted": true}
+	for _, key := range []string{"status", "reason_code"} {
+		if v, ok := ev.Payload[key]; ok {
+			payload[key] = v
+		}
+	}
+	ev.Payload = payload
+	if ev.Compat != nil {
+		compat := *ev.Compat
+		compat.Text = ""
+		ev.Compat = &compat
+	}
+	return ev
+}
+
+func writeTarBytes(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: modTime}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func writeTarFile(tw *tar.Writer, name string, f *os.File, modTime time.Time) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: info.Size(), ModTime: modTime}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,108 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"echohelix/internal/driver"
+	"echohelix/internal/events"
+)
+
+var (
+	ErrExtensionDenied      = errors.New("run deadline extension denied")
+	ErrExtensionUnsupported = errors.New("backend does not support deadline extension")
+)
+
+type RunDeadline struct {
+	RunID         string    `json:"run_id"`
+	Deadline      time.Time `json:"deadline"`
+	ExtendedBy    int64     `json:"extended_by_seconds"`
+	ExtendedTotal int64     `json:"extended_total_seconds"`
+}
+
+// ExtendRun pushes an active run's deadline back by `by`, within the policy
+// limits, and records the extension as a status event. The backend adapter
+// is extended first so it does not stop the CLI at the old deadline.
+func (s *Service) ExtendRun(ctx context.Context, runID string, by time.Duration) (RunDeadline, error) {
+	s.mu.Lock()
+	ar := s.active[runID]
+	if ar == nil || isTerminalStatus(ar.status) {
+		s.mu.Unlock()
+		return RunDeadline{}, ErrRunNotActive
+	}
+	if ar.status == StatusPaused {
+		s.mu.Unlock()
+		return RunDeadline{}, ErrRunPaused
+	}
+	extender, ok := ar.driver.(driver.DeadlineExtender)
+	if !ok {
+		s.mu.Unlock()
+		return RunDeadline{}, ErrExtensionUnsupported
+	}
+	if err := s.policy.ValidateRunExtension(by, ar.extended); err != nil {
+		s.mu.Unlock()
+		return RunDeadline{}, fmt.Errorf("%w: %v", ErrExtensionDenied, err)
+	}
+	// Reserve the extension so concurrent requests cannot exceed the total.
+	ar.extended += by
+	deadline := ar.deadline.Add(by)
+	s.mu.Unlock()
+
+	if err := extender.ExtendRun(ctx, runID, time.Until(deadline)); err != nil {
+		s.mu.Lock()
+		ar.extended -= by
+		s.mu.Unlock()
+		return RunDeadline{}, err
+	}
+
+	s.mu.Lock()
+	if !ar.timer.Stop() {
+		s.mu.Unlock()
+		return RunDeadline{}, ErrRunNotActive
+	}
+	ar.deadline = ar.deadline.Add(by)
+	ar.timer.Reset(time.Until(ar.deadline))
+	out := RunDeadline{
+		RunID:         runID,
+		Deadline:      ar.deadline.UTC(),
+		ExtendedBy:    int64(by / time.Second),
+		ExtendedTotal: int64(ar.extended / time.Second),
+	}
+	status, backend := ar.status, ar.backend
+	s.mu.Unlock()
+
+	s.emit(context.Background(), runID, backend, "bridge", events.TypeStatus, map[string]any{
+		"status":  status,
+		"reason":  "deadline_extended",
+		"message": fmt.Sprintf("deadline extended by %s to %s", by, out.Deadline.Format(time.RFC3339)),
+	})
+	return out, nil
+}
+
+// timeoutFor is r's timeout: its own override or the bridge's run timeout.
+func (s *Service) timeoutFor(r Run) time.Duration {
+	if r.Options.TimeoutSeconds > 0 {
+		return time.Duration(r.Options.TimeoutSeconds) * time.Second
+	}
+	return s.runTimeout
+}
+
+// runDeadline reports an active run's deadline and the whole seconds left
+// before it, or the frozen remainder of a paused run.
+func (s *Service) runDeadline(runID string) (*time.Time, *int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ar := s.active[runID]
+	if ar == nil || ar.deadline.IsZero() || isTerminalStatus(ar.status) {
+		return nil, nil
+	}
+	if ar.status == StatusPaused {
+		remaining := int64(ar.remaining / time.Second)
+		return nil, &remaining
+	}
+	deadline := ar.deadline.UTC()
+	remaining := int64(max(time.Until(deadline), 0) / time.Second)
+	return &deadline, &remaining
+}
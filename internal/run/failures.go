@@ -0,0 +1,84 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"echohelix/internal/notify"
+)
+
+// RunFailure is sent to the failure sink once a run has ended failed.
+type RunFailure struct {
+	RunID       string    `json:"run_id"`
+	Backend     string    `json:"backend"`
+	Workspace   string    `json:"workspace"`
+	SubmittedBy string    `json:"submitted_by,omitempty"`
+	ReasonCode  string    `json:"reason_code"`
+	Error       string    `json:"error"`
+	FailedAt    time.Time `json:"failed_at"`
+}
+
+type FailureSink func(ctx context.Context, failure RunFailure)
+
+type failureState struct {
+	mu   sync.Mutex
+	sink FailureSink
+}
+
+// SetFailureSink receives every run that ends with status failed.
+func (s *Service) SetFailureSink(sink FailureSink) {
+	s.failures.mu.Lock()
+	s.failures.sink = sink
+	s.failures.mu.Unlock()
+}
+
+func (s *Service) notifyIfFailed(runID string) {
+	s.failures.mu.Lock()
+	sink := s.failures.sink
+	s.failures.mu.Unlock()
+	if sink == nil {
+		return
+	}
+	rec, err := s.ledger.GetRun(context.Background(), runID)
+	if err != nil || rec.Status != StatusFailed {
+		return
+	}
+	failure := RunFailure{
+		RunID:       rec.ID,
+		Backend:     rec.Backend,
+		Workspace:   rec.Workspace,
+		SubmittedBy: rec.SubmittedBy,
+		ReasonCode:  classifyFailureCode(rec.Error),
+		Error:       rec.Error,
+		FailedAt:    rec.UpdatedAt,
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		sink(ctx, failure)
+	}()
+}
+
+// NewEmailFailureSink mails each failed run through mailer.
+func NewEmailFailureSink(mailer *notify.Mailer) FailureSink {
+	return func(ctx context.Context, f RunFailure) {
+		var b strings.Builder
+		fmt.Fprintf(&b, "Run %s on %s failed (%s).\n\n", f.RunID, f.Backend, f.ReasonCode)
+		fmt.Fprintf(&b, "Workspace: %s\n", f.Workspace)
+		if f.SubmittedBy != "" {
+			fmt.Fprintf(&b, "Submitted by: %s\n", f.SubmittedBy)
+		}
+		fmt.Fprintf(&b, "Failed at: %s\n", f.FailedAt.UTC().Format(time.RFC3339))
+		if f.Error != "" {
+			fmt.Fprintf(&b, "\nError:\n%s\n", f.Error)
+		}
+		fmt.Fprintf(&b, "\nEvents: /api/v3/runs/%s/events\n", f.RunID)
+		if err := mailer.Send(ctx, "Run failed: "+f.Backend+" "+f.RunID, b.String()); err != nil {
+			log.Printf("run failure notify: %v", err)
+		}
+	}
+}
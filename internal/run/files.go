@@ -140,3 +140,78 @@ func (s *Service) GetUploadedFile(ctx context.Context, fileID string) (UploadedF
 		CreatedAt:    rec.CreatedAt,
 	}, nil
 }
+
+// FileUsage is an uploaded file with how many runs attach it.
+type FileUsage struct {
+	UploadedFile
+	RefCount   int64 `json:"ref_count"`
+	Orphaned   bool  `json:"orphaned"`
+	AgeSeconds int64 `json:"age_seconds"`
+}
+
+type FileUsageQuery struct {
+	CreatedBy    string
+	MinAge       time.Duration
+	OrphanedOnly bool
+	Limit        int
+}
+
+type FileStorageSummary struct {
+	FileCount     int64 `json:"file_count"`
+	TotalBytes    int64 `json:"total_bytes"`
+	OrphanedCount int64 `json:"orphaned_count"`
+	OrphanedBytes int64 `json:"orphaned_bytes"`
+}
+
+type FileUsageReport struct {
+	Files   []FileUsage        `json:"files"`
+	Summary FileStorageSummary `json:"summary"`
+}
+
+// ListFileUsage lists uploads matching q, newest first, with reference
+// counts. The summary covers all uploads regardless of q.
+func (s *Service) ListFileUsage(ctx context.Context, q FileUsageQuery) (FileUsageReport, error) {
+	now := time.Now().UTC()
+	filter := ledger.FileUsageFilter{
+		CreatedBy:    strings.TrimSpace(q.CreatedBy),
+		OrphanedOnly: q.OrphanedOnly,
+		Limit:        q.Limit,
+	}
+	if q.MinAge > 0 {
+		filter.CreatedBefore = now.Add(-q.MinAge)
+	}
+	recs, err := s.ledger.ListFileUsage(ctx, filter)
+	if err != nil {
+		return FileUsageReport{}, err
+	}
+	sum, err := s.ledger.SummarizeFileStorage(ctx)
+	if err != nil {
+		return FileUsageReport{}, err
+	}
+	out := FileUsageReport{
+		Files: make([]FileUsage, 0, len(recs)),
+		Summary: FileStorageSummary{
+			FileCount:     sum.FileCount,
+			TotalBytes:    sum.TotalBytes,
+			OrphanedCount: sum.OrphanedCount,
+			OrphanedBytes: sum.OrphanedBytes,
+		},
+	}
+	for _, rec := range recs {
+		out.Files = append(out.Files, FileUsage{
+			UploadedFile: UploadedFile{
+				FileID:       rec.FileID,
+				OriginalName: rec.OriginalName,
+				MIMEType:     rec.MIMEType,
+				SizeBytes:    rec.SizeBytes,
+				SHA256:       rec.SHA256,
+				CreatedBy:    rec.CreatedBy,
+				CreatedAt:    rec.CreatedAt,
+			},
+			RefCount:   rec.RefCount,
+			Orphaned:   rec.RefCount == 0,
+			AgeSeconds: int64(now.Sub(rec.CreatedAt).Seconds()),
+		})
+	}
+	return out, nil
+}
@@ -1,11 +1,19 @@
 package run
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
 	"errors"
+	"io"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"echohelix/internal/policy"
 )
 
 func TestUploadAndGetFile(t *testing.T) {
@@ -48,3 +56,221 @@ func TestUploadFileTooLarge(t *testing.T) {
 		t.Fatalf("expected ErrFileTooLarge, got %v", err)
 	}
 }
+
+func TestExportRunNDJSONAndArchive(t *testing.T) {
+	svc := setupService(t, newFakeDriver("codex", false))
+	svc.SetFileStorage(filepath.Join(t.TempDir(), "files"), 1024)
+
+	uploaded, err := svc.UploadFile(context.Background(), UploadFileRequest{
+		Reader:       bytes.NewReader([]byte("attached notes")),
+		OriginalName: "notes.txt",
+		CreatedBy:    "test",
+	})
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	r, err := svc.Submit(context.Background(), SubmitRequest{
+		WorkspaceID:   "ws-1",
+		WorkspacePath: t.TempDir(),
+		Backend:       "codex",
+		Prompt:        "export me",
+		Context:       map[string]any{"attachments": []any{uploaded.FileID}},
+	})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, r.ID, StatusCompleted)
+	got, err := svc.GetRun(context.Background(), r.ID)
+	if err != nil {
+		t.Fatalf("get run: %v", err)
+	}
+	history, err := svc.ListEvents(context.Background(), r.ID, 0)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+
+	var ndjson bytes.Buffer
+	if err := svc.ExportRunNDJSON(context.Background(), got, &ndjson); err != nil {
+		t.Fatalf("export ndjson: %v", err)
+	}
+	dec := json.NewDecoder(&ndjson)
+	var records []ExportRecord
+	for dec.More() {
+		var rec ExportRecord
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatalf("decode export line: %v", err)
+		}
+		records = append(records, rec)
+	}
+	if len(records) != len(history)+1 || records[0].Record != "run" || records[0].Run.ID != r.ID {
+		t.Fatalf("unexpected ndjson export: %d records for %d events", len(records), len(history))
+	}
+	if records[len(records)-1].Event.Seq != history[len(history)-1].Seq {
+		t.Fatalf("expected events in seq order")
+	}
+
+	var archive bytes.Buffer
+	if err := svc.ExportRunArchive(context.Background(), got, &archive); err != nil {
+		t.Fatalf("export archive: %v", err)
+	}
+	gz, err := gzip.NewReader(&archive)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	files := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("tar: %v", err)
+		}
+		data, _ := io.ReadAll(tr)
+		files[hdr.Name] = string(data)
+	}
+	if _, ok := files["run.json"]; !ok {
+		t.Fatalf("archive missing run.json: %v", files)
+	}
+	if n := strings.Count(files["events.ndjson"], "\n"); n != len(history) {
+		t.Fatalf("expected %d archived events, got %d", len(history), n)
+	}
+	if files["attachments/notes.txt"] != "attached notes" {
+		t.Fatalf("archive missing attachment: %v", files)
+	}
+}
+
+func TestSubmitFlagsUnresolvedMentions(t *testing.T) {
+	svc := setupService(t, newFakeDriver("codex", false))
+	svc.SetFileStorage(filepath.Join(t.TempDir(), "files"), 1024)
+
+	uploaded, err := svc.UploadFile(context.Background(), UploadFileRequest{
+		Reader:       bytes.NewReader([]byte("spec")),
+		OriginalName: "spec.md",
+		CreatedBy:    "test",
+	})
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	req := SubmitRequest{
+		WorkspaceID:   "ws-1",
+		WorkspacePath: t.TempDir(),
+		Backend:       "codex",
+		Prompt:        "read @spec.md. compare with @missing and @missing, then mail me@example.com",
+		Context:       map[string]any{"attachments": []any{uploaded.FileID}},
+	}
+	r, err := svc.Submit(context.Background(), req)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if strings.Join(r.UnresolvedMentions, ",") != "missing" {
+		t.Fatalf("unexpected unresolved mentions: %#v", r.UnresolvedMentions)
+	}
+	waitStatus(t, svc, r.ID, StatusCompleted)
+
+	req.StrictMentions = true
+	req.WorkspacePath = t.TempDir()
+	req.Context = map[string]any{"attachments": []any{uploaded.FileID}}
+	_, err = svc.Submit(context.Background(), req)
+	var mentionErr *UnresolvedMentionsError
+	if !errors.As(err, &mentionErr) || len(mentionErr.Mentions) != 1 {
+		t.Fatalf("expected UnresolvedMentionsError, got %v", err)
+	}
+	if _, statErr := os.Stat(filepath.Join(req.WorkspacePath, ".elix")); !os.IsNotExist(statErr) {
+		t.Fatalf("strict rejection should not copy attachments: %v", statErr)
+	}
+}
+
+func TestExportAppliesWorkspaceRedaction(t *testing.T) {
+	svc := setupService(t, newFakeDriver("codex", false))
+	svc.policy.SetWorkspaceRetention(map[string]policy.WorkspaceRetention{
+		"ws-secret": {Redaction: policy.RedactionContent},
+	})
+	r, err := svc.Submit(context.Background(), SubmitRequest{
+		WorkspaceID:   "ws-secret",
+		WorkspacePath: t.TempDir(),
+		Backend:       "codex",
+		Prompt:        "classified prompt",
+	})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, r.ID, StatusCompleted)
+	got, err := svc.GetRun(context.Background(), r.ID)
+	if err != nil {
+		t.Fatalf("get run: %v", err)
+	}
+
+	var ndjson bytes.Buffer
+	if err := svc.ExportRunNDJSON(context.Background(), got, &ndjson); err != nil {
+		t.Fatalf("export ndjson: %v", err)
+	}
+	if strings.Contains(ndjson.String(), "classified prompt") || !strings.Contains(ndjson.String(), redactedText) {
+		t.Fatalf("expected prompt redacted: %s", ndjson.String())
+	}
+	dec := json.NewDecoder(&ndjson)
+	for dec.More() {
+		var rec ExportRecord
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatalf("decode export line: %v", err)
+		}
+		if rec.Event == nil {
+			continue
+		}
+		for key := range rec.Event.Payload {
+			if key != "redacted" && key != "status" && key != "reason_code" {
+				t.Fatalf("event %d payload not redacted: %v", rec.Event.Seq, rec.Event.Payload)
+			}
+		}
+	}
+}
+
+func TestEstimateInputCountsPromptAttachmentsAndCost(t *testing.T) {
+	svc := setupService(t, newFakeDriver("codex", false))
+	svc.SetFileStorage(filepath.Join(t.TempDir(), "files"), 1<<20)
+	svc.SetTokenPricing(map[string]TokenPrice{"codex": {InputPerMTok: 2, OutputPerMTok: 8}})
+
+	text, err := svc.UploadFile(context.Background(), UploadFileRequest{
+		Reader:       strings.NewReader(strings.Repeat("a", 400)),
+		OriginalName: "notes.txt",
+		MIMEType:     "text/plain",
+	})
+	if err != nil {
+		t.Fatalf("upload text: %v", err)
+	}
+	image, err := svc.UploadFile(context.Background(), UploadFileRequest{
+		Reader:       bytes.NewReader([]byte{0x89, 'P', 'N', 'G', 0xff}),
+		OriginalName: "shot.png",
+		MIMEType:     "image/png",
+	})
+	if err != nil {
+		t.Fatalf("upload image: %v", err)
+	}
+
+	est, err := svc.EstimateInput(context.Background(), SubmitRequest{
+		WorkspaceID:   "ws-1",
+		WorkspacePath: "/tmp",
+		Prompt:        strings.Repeat("b", 40) + "日本",
+		Context:       map[string]any{"attachments": []any{text.FileID, image.FileID}},
+	})
+	if err != nil {
+		t.Fatalf("estimate: %v", err)
+	}
+	if est.Backend != "codex" || est.PromptTokens != 12 {
+		t.Fatalf("unexpected prompt estimate: %#v", est)
+	}
+	if len(est.Attachments) != 2 || est.Attachments[0].Tokens != 100 || est.Attachments[1].Tokens != imageTokenEstimate {
+		t.Fatalf("unexpected attachment estimates: %#v", est.Attachments)
+	}
+	if est.InputTokens != est.PromptTokens+est.ContextTokens+est.AttachmentTokens || est.ContextTokens == 0 {
+		t.Fatalf("inconsistent totals: %#v", est)
+	}
+	if est.CostUSD == nil || *est.CostUSD != roundUSD(float64(est.InputTokens)*2/1e6) {
+		t.Fatalf("unexpected cost: %#v", est.CostUSD)
+	}
+
+	if _, err := svc.EstimateInput(context.Background(), SubmitRequest{WorkspacePath: "/tmp", Backend: "nope", Prompt: "x"}); err == nil {
+		t.Fatalf("expected unknown backend to be rejected")
+	}
+}
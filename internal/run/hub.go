@@ -2,51 +2,122 @@ package run
 
 import (
 	"sync"
+	"time"
 
 	"echohelix/internal/events"
 )
 
+// Hub fans run events out to live subscribers. A subscriber whose buffer is
+// full misses events instead of blocking the run; once it drains it gets a
+// status event with the missed seq range, and a subscriber that stays
+// saturated for evictAfter is closed so the client reconnects and replays.
 type Hub struct {
-	mu   sync.RWMutex
-	subs map[string]map[chan events.Event]struct{}
+	mu         sync.Mutex
+	subs       map[string]map[chan events.Event]*events.Backlog
+	evictAfter time.Duration
+	counters   events.BackpressureCounters
 }
 
 func NewHub() *Hub {
 	return &Hub{
-		subs: map[string]map[chan events.Event]struct{}{},
+		subs:       map[string]map[chan events.Event]*events.Backlog{},
+		evictAfter: events.DefaultEvictAfter,
 	}
 }
 
+// SetEvictAfter sets how long a subscriber may drop every event before it
+// is evicted; zero never evicts.
+func (h *Hub) SetEvictAfter(d time.Duration) {
+	h.mu.Lock()
+	h.evictAfter = max(d, 0)
+	h.mu.Unlock()
+}
+
 func (h *Hub) Subscribe(runID string, buf int) (<-chan events.Event, func()) {
 	ch := make(chan events.Event, buf)
 	h.mu.Lock()
 	if _, ok := h.subs[runID]; !ok {
-		h.subs[runID] = map[chan events.Event]struct{}{}
+		h.subs[runID] = map[chan events.Event]*events.Backlog{}
 	}
-	h.subs[runID][ch] = struct{}{}
+	h.subs[runID][ch] = &events.Backlog{}
 	h.mu.Unlock()
 
 	unsub := func() {
 		h.mu.Lock()
 		defer h.mu.Unlock()
-		if runSubs, ok := h.subs[runID]; ok {
-			delete(runSubs, ch)
-			close(ch)
-			if len(runSubs) == 0 {
-				delete(h.subs, runID)
-			}
-		}
+		h.removeLocked(runID, ch)
 	}
 	return ch, unsub
 }
 
+func (h *Hub) removeLocked(runID string, ch chan events.Event) {
+	runSubs, ok := h.subs[runID]
+	if !ok {
+		return
+	}
+	if _, ok := runSubs[ch]; !ok {
+		return
+	}
+	delete(runSubs, ch)
+	close(ch)
+	if len(runSubs) == 0 {
+		delete(h.subs, runID)
+	}
+}
+
 func (h *Hub) Publish(ev events.Event) {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
-	for ch := range h.subs[ev.RunID] {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	for ch, backlog := range h.subs[ev.RunID] {
+		if h.deliver(ch, backlog, ev) {
+			continue
+		}
+		if backlog.Drop(ev.Seq, now, h.evictAfter) {
+			h.counters.Evicted.Add(1)
+			h.removeLocked(ev.RunID, ch)
+		}
+		h.counters.Dropped.Add(1)
+	}
+}
+
+// deliver sends the pending gap marker, if any, ahead of ev.
+func (h *Hub) deliver(ch chan events.Event, backlog *events.Backlog, ev events.Event) bool {
+	if payload, ok := backlog.PendingGap(); ok {
+		marker := events.Event{
+			RunID:         ev.RunID,
+			TS:            time.Now().UTC(),
+			SchemaVersion: ev.SchemaVersion,
+			Type:          events.TypeGap,
+			Payload:       payload,
+			Backend:       ev.Backend,
+			Source:        "bridge",
+		}
+		events.NormalizeEvent(&marker)
 		select {
-		case ch <- ev:
+		case ch <- marker:
+			backlog.ClearGap()
+			h.counters.GapMarkers.Add(1)
 		default:
+			return false
 		}
 	}
+	select {
+	case ch <- ev:
+		backlog.Delivered()
+		return true
+	default:
+		return false
+	}
+}
+
+// Stats reports live subscribers and backpressure totals.
+func (h *Hub) Stats() events.BackpressureStats {
+	h.mu.Lock()
+	n := 0
+	for _, runSubs := range h.subs {
+		n += len(runSubs)
+	}
+	h.mu.Unlock()
+	return h.counters.Stats(n)
 }
@@ -0,0 +1,62 @@
+package run
+
+import (
+	"context"
+	"time"
+
+	"echohelix/internal/ledger"
+)
+
+// SetIdempotencyWindow sets how long a client_request_id is remembered;
+// zero or less restores ledger.DefaultIdempotencyWindow.
+func (s *Service) SetIdempotencyWindow(window time.Duration) {
+	if window <= 0 {
+		window = ledger.DefaultIdempotencyWindow
+	}
+	s.mu.Lock()
+	s.idempotencyWindow = window
+	s.mu.Unlock()
+}
+
+// submitIdempotent submits req once per ClientRequestID and submitter.
+// The key is claimed in the ledger before the run is created, so
+// concurrent retries get ErrIdempotencyKeyInProgress rather than a second
+// run, and later ones get the original run back.
+func (s *Service) submitIdempotent(ctx context.Context, req SubmitRequest) (Run, error) {
+	key := req.ClientRequestID
+	if key == "" {
+		return s.submit(ctx, req)
+	}
+	s.mu.Lock()
+	window := s.idempotencyWindow
+	s.mu.Unlock()
+	scope := "runs:" + req.SubmittedBy
+	fingerprint := ledger.IdempotencyFingerprint(req)
+	rec, claimed, err := s.ledger.ClaimIdempotencyKey(ctx, scope, key, fingerprint, time.Now().UTC(), window)
+	if err != nil {
+		return Run{}, err
+	}
+	if !claimed {
+		switch {
+		case rec.Fingerprint != fingerprint:
+			return Run{}, ledger.ErrIdempotencyKeyReused
+		case rec.Result == "":
+			return Run{}, ledger.ErrIdempotencyKeyInProgress
+		}
+		r, err := s.GetRun(ctx, rec.Result)
+		if err != nil {
+			return Run{}, err
+		}
+		r.Replayed = true
+		return r, nil
+	}
+	r, err := s.submit(ctx, req)
+	if err != nil {
+		_ = s.ledger.ReleaseIdempotencyKey(context.WithoutCancel(ctx), scope, key)
+		return Run{}, err
+	}
+	// The run is already started; a failed write only leaves retries
+	// answered with ErrIdempotencyKeyInProgress until the window ends.
+	_ = s.ledger.CompleteIdempotencyKey(context.WithoutCancel(ctx), scope, key, r.ID)
+	return r, nil
+}
@@ -0,0 +1,41 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"echohelix/internal/driver"
+)
+
+var (
+	ErrRunNotActive     = errors.New("run is not active")
+	ErrInputUnsupported = errors.New("backend does not support run input")
+)
+
+// SendInput writes a line (and/or EOF) to the stdin of an interactive run's
+// CLI. The run must have been submitted with options.interactive.
+func (s *Service) SendInput(ctx context.Context, runID, data string, eof bool) error {
+	rec, err := s.ledger.GetRun(ctx, runID)
+	if err != nil {
+		return err
+	}
+	if !rec.Options.Interactive {
+		return fmt.Errorf("run was not submitted with options.interactive")
+	}
+	s.mu.Lock()
+	ar := s.active[runID]
+	var drv driver.Driver
+	if ar != nil && !isTerminalStatus(ar.status) {
+		drv = ar.driver
+	}
+	s.mu.Unlock()
+	if drv == nil {
+		return ErrRunNotActive
+	}
+	sender, ok := drv.(driver.InputSender)
+	if !ok {
+		return ErrInputUnsupported
+	}
+	return sender.SendInput(ctx, runID, data, eof)
+}
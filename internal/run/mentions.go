@@ -0,0 +1,60 @@
+package run
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// UnresolvedMentionsError rejects a strict_mentions submit whose prompt
+// mentions @aliases that match no attachment.
+type UnresolvedMentionsError struct {
+	Mentions []string
+}
+
+func (e *UnresolvedMentionsError) Error() string {
+	return "prompt mentions unknown attachments: @" + strings.Join(e.Mentions, ", @")
+}
+
+// unresolvedMentions lists the @aliases in prompt that match neither an
+// attachment alias nor an existing path in the workspace (CLIs resolve
+// "@file" themselves). Text like user@example.com is not a mention.
+func unresolvedMentions(prompt string, aliases map[string]struct{}, workspace string) []string {
+	var out []string
+	seen := map[string]struct{}{}
+	for _, m := range mentionAliasPattern.FindAllStringSubmatchIndex(prompt, -1) {
+		if m[0] > 0 && isMentionWordByte(prompt[m[0]-1]) {
+			continue
+		}
+		if m[1] < len(prompt) && prompt[m[1]] == '/' {
+			continue
+		}
+		name := prompt[m[2]:m[3]]
+		// Sentence punctuation after a mention is not part of the alias.
+		trimmed := strings.TrimRight(name, "._-")
+		if mentionResolves(name, aliases, workspace) || mentionResolves(trimmed, aliases, workspace) {
+			continue
+		}
+		if _, ok := seen[trimmed]; ok {
+			continue
+		}
+		seen[trimmed] = struct{}{}
+		out = append(out, trimmed)
+	}
+	return out
+}
+
+func mentionResolves(name string, aliases map[string]struct{}, workspace string) bool {
+	if name == "" {
+		return true
+	}
+	if _, ok := aliases[strings.ToLower(name)]; ok {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(workspace, name))
+	return err == nil
+}
+
+func isMentionWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '.' || b == '_' || b == '-' || b == '@'
+}
@@ -6,6 +6,7 @@ const (
 	StatusQueued     = "queued"
 	StatusRunning    = "running"
 	StatusStreaming  = "streaming"
+	StatusPaused     = "paused"
 	StatusCancelling = "cancelling"
 	StatusCancelled  = "cancelled"
 	StatusCompleted  = "completed"
@@ -24,8 +25,33 @@ type Run struct {
 	Status      string          `json:"status"`
 	Error       string          `json:"error,omitempty"`
 	Terminal    TerminalInfo    `json:"terminal"`
+	Usage       *RunUsage       `json:"usage,omitempty"`
+	Checkpoint  *RunCheckpoint  `json:"checkpoint,omitempty"`
+	SubmittedBy string          `json:"submitted_by,omitempty"`
+	ParentRunID string          `json:"parent_run_id,omitempty"`
 	CreatedAt   time.Time       `json:"created_at"`
 	UpdatedAt   time.Time       `json:"updated_at"`
+	// Deadline and RemainingSeconds are reported while the run is active.
+	// A paused run has no deadline; its remaining time does not count down.
+	Deadline         *time.Time `json:"deadline,omitempty"`
+	RemainingSeconds *int64     `json:"remaining_seconds,omitempty"`
+	// QuotaWarning is set on submit when a soft-enforced quota is exceeded.
+	QuotaWarning string `json:"quota_warning,omitempty"`
+	// UnresolvedMentions lists prompt @mentions that matched no attachment;
+	// it is only set on submit.
+	UnresolvedMentions []string `json:"unresolved_mentions,omitempty"`
+	// Replayed is set when Submit returned the run an earlier request with
+	// the same ClientRequestID created.
+	Replayed bool `json:"replayed,omitempty"`
+}
+
+type RunUsage struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+	TotalTokens  int64 `json:"total_tokens"`
+	// CostUSD is an estimate from the configured pricing table; it is omitted
+	// when no rate matches the run's backend/model.
+	CostUSD *float64 `json:"cost_usd,omitempty"`
 }
 
 type TerminalInfo struct {
@@ -42,6 +68,16 @@ type SubmitRequest struct {
 	Prompt        string         `json:"prompt"`
 	Context       map[string]any `json:"context,omitempty"`
 	Options       RunOptions     `json:"options,omitempty"`
+	// StrictMentions rejects prompts with @mentions that match no attachment
+	// instead of reporting them in UnresolvedMentions.
+	StrictMentions bool `json:"strict_mentions,omitempty"`
+	// ClientRequestID (or the Idempotency-Key header) makes retries of the
+	// same submit return the original run instead of starting another.
+	ClientRequestID string `json:"client_request_id,omitempty"`
+	// SubmittedBy is filled from the authenticated principal, never the body.
+	SubmittedBy string `json:"-"`
+	// ParentRunID is only set by RetryRun.
+	ParentRunID string `json:"-"`
 }
 
 type RunOptions struct {
@@ -49,6 +85,24 @@ type RunOptions struct {
 	Profile       string `json:"profile,omitempty"`
 	Sandbox       string `json:"sandbox,omitempty"`
 	SchemaVersion string `json:"schema_version,omitempty"`
+	// Interactive keeps the CLI's stdin open for POST /runs/{id}/input.
+	Interactive bool `json:"interactive,omitempty"`
+	// PTY runs the CLI on a pseudo-terminal (default 120x40) on backends
+	// that report supports_pty. RawOutput adds the raw terminal output as
+	// token events with source "pty".
+	PTY       bool `json:"pty,omitempty"`
+	PTYCols   int  `json:"pty_cols,omitempty"`
+	PTYRows   int  `json:"pty_rows,omitempty"`
+	RawOutput bool `json:"raw_output,omitempty"`
+	// Checkpoint snapshots the workspace (a git repository) before the run
+	// starts so POST /runs/{id}/rollback can restore it.
+	Checkpoint bool `json:"checkpoint,omitempty"`
+	// RequiredTools rejects the submit unless the backend declares every
+	// listed tool (shell, web, editor).
+	RequiredTools []string `json:"required_tools,omitempty"`
+	// TimeoutSeconds overrides the bridge's run timeout for this run, up to
+	// the policy maximum (RUN_TIMEOUT_MAX_SECONDS).
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
 }
 
 type RunAttachment struct {
@@ -64,6 +118,9 @@ type TokenUsageTotals struct {
 	InputTokens  int64 `json:"input_tokens"`
 	OutputTokens int64 `json:"output_tokens"`
 	TotalTokens  int64 `json:"total_tokens"`
+	// CostUSD and UnpricedRuns are only reported when pricing is configured.
+	CostUSD      *float64 `json:"cost_usd,omitempty"`
+	UnpricedRuns int64    `json:"unpriced_runs,omitempty"`
 }
 
 type TokenUsageByBackend struct {
@@ -71,11 +128,20 @@ type TokenUsageByBackend struct {
 	TokenUsageTotals
 }
 
+// TokenUsageGroup keys usage by device address or workspace id; Key is
+// empty for runs recorded without one.
+type TokenUsageGroup struct {
+	Key string `json:"key"`
+	TokenUsageTotals
+}
+
 type TokenUsageSummary struct {
 	From      time.Time             `json:"from"`
 	To        time.Time             `json:"to"`
+	GroupBy   string                `json:"group_by"`
 	Totals    TokenUsageTotals      `json:"totals"`
 	ByBackend []TokenUsageByBackend `json:"by_backend"`
+	Groups    []TokenUsageGroup     `json:"groups,omitempty"`
 }
 
 type TokenQuotaItem struct {
@@ -94,3 +160,35 @@ type EmergencyState struct {
 	Reason    string    `json:"reason,omitempty"`
 	Activated time.Time `json:"activated_at,omitempty"`
 }
+
+type RunAnalyticsGroup struct {
+	Key             string  `json:"key,omitempty"`
+	RunCount        int64   `json:"run_count"`
+	Completed       int64   `json:"completed"`
+	Failed          int64   `json:"failed"`
+	Cancelled       int64   `json:"cancelled"`
+	InProgress      int64   `json:"in_progress"`
+	SuccessRate     float64 `json:"success_rate"`
+	FailureRate     float64 `json:"failure_rate"`
+	CancelRate      float64 `json:"cancel_rate"`
+	P50DurationMS   int64   `json:"p50_duration_ms"`
+	P95DurationMS   int64   `json:"p95_duration_ms"`
+	AvgInputTokens  float64 `json:"avg_input_tokens"`
+	AvgOutputTokens float64 `json:"avg_output_tokens"`
+	AvgTotalTokens  float64 `json:"avg_total_tokens"`
+	FirstEventP50MS int64   `json:"first_event_p50_ms"`
+	FirstEventP95MS int64   `json:"first_event_p95_ms"`
+	// SLOCompliance is the share of SLOSamples that met the first event
+	// threshold of their backend; it is only meaningful when SLOSamples > 0.
+	SLOSamples    int64   `json:"slo_samples"`
+	SLOBreaches   int64   `json:"slo_breaches"`
+	SLOCompliance float64 `json:"slo_compliance"`
+}
+
+type RunAnalyticsSummary struct {
+	From    time.Time           `json:"from"`
+	To      time.Time           `json:"to"`
+	GroupBy string              `json:"group_by"`
+	Totals  RunAnalyticsGroup   `json:"totals"`
+	Groups  []RunAnalyticsGroup `json:"groups"`
+}
@@ -0,0 +1,94 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"echohelix/internal/driver"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	ModelSourceStatic       = "static"
+	ModelSourceAdapter      = "adapter"
+	ModelSourceCapabilities = "capabilities"
+)
+
+// ModelList is what a client needs to populate a model picker for one
+// backend. Source says whether it came from BACKEND_MODELS_FILE, the
+// adapter's ListModels, or the bare model names in its capabilities.
+type ModelList struct {
+	Backend string             `json:"backend"`
+	Source  string             `json:"source"`
+	Models  []driver.ModelInfo `json:"models"`
+}
+
+// LoadModelCatalog reads a JSON object mapping backend names to model lists.
+func LoadModelCatalog(path string) (map[string][]driver.ModelInfo, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read backend models: %w", err)
+	}
+	var catalog map[string][]driver.ModelInfo
+	if err := json.Unmarshal(raw, &catalog); err != nil {
+		return nil, fmt.Errorf("parse backend models: %w", err)
+	}
+	for backend, models := range catalog {
+		for _, m := range models {
+			if strings.TrimSpace(m.ID) == "" {
+				return nil, fmt.Errorf("parse backend models: %s has a model without id", backend)
+			}
+		}
+	}
+	return catalog, nil
+}
+
+func (s *Service) SetStaticModels(catalog map[string][]driver.ModelInfo) {
+	s.mu.Lock()
+	s.staticModels = catalog
+	s.mu.Unlock()
+}
+
+// ListModels returns the models of a backend: the static list when one is
+// configured, otherwise the adapter's, falling back to the model names in
+// its capabilities for adapters without ListModels.
+func (s *Service) ListModels(ctx context.Context, backend string) (ModelList, error) {
+	drv, err := s.registry.Get(backend)
+	if err != nil {
+		return ModelList{}, ErrBackendNotFound
+	}
+	out := ModelList{Backend: backend, Models: []driver.ModelInfo{}}
+	s.mu.Lock()
+	static, ok := s.staticModels[backend]
+	s.mu.Unlock()
+	if ok {
+		out.Source = ModelSourceStatic
+		out.Models = append(out.Models, static...)
+		return out, nil
+	}
+	if lister, ok := drv.(driver.ModelLister); ok {
+		models, err := lister.ListModels(ctx)
+		if err == nil {
+			out.Source = ModelSourceAdapter
+			out.Models = append(out.Models, models...)
+			return out, nil
+		}
+		if status.Code(err) != codes.Unimplemented {
+			return ModelList{}, fmt.Errorf("list backend models: %w", err)
+		}
+	}
+	caps, err := drv.Capabilities(ctx)
+	if err != nil {
+		return ModelList{}, fmt.Errorf("resolve backend capabilities: %w", err)
+	}
+	out.Source = ModelSourceCapabilities
+	for _, id := range caps.Models {
+		out.Models = append(out.Models, driver.ModelInfo{ID: id})
+	}
+	return out, nil
+}
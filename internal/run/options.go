@@ -0,0 +1,43 @@
+package run
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"echohelix/internal/driver"
+)
+
+// UnsupportedOptionError rejects a run option value the backend does not
+// advertise in its capabilities.
+type UnsupportedOptionError struct {
+	Backend   string
+	Option    string
+	Value     string
+	Supported []string
+}
+
+func (e *UnsupportedOptionError) Error() string {
+	return fmt.Sprintf("backend %s does not support %s %q (supported: %s)", e.Backend, e.Option, e.Value, strings.Join(e.Supported, ", "))
+}
+
+// validateBackendOptions checks model, profile and sandbox against the lists
+// the backend advertises. A backend that declares no list accepts any value.
+func validateBackendOptions(backend string, opts RunOptions, caps driver.CapabilitySet) error {
+	checks := []struct {
+		option    string
+		value     string
+		supported []string
+	}{
+		{"model", opts.Model, caps.Models},
+		{"profile", opts.Profile, caps.Profiles},
+		{"sandbox", opts.Sandbox, caps.SandboxModes},
+	}
+	for _, c := range checks {
+		if c.value == "" || len(c.supported) == 0 || slices.Contains(c.supported, c.value) {
+			continue
+		}
+		return &UnsupportedOptionError{Backend: backend, Option: c.option, Value: c.value, Supported: c.supported}
+	}
+	return nil
+}
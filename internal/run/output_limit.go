@@ -0,0 +1,117 @@
+package run
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"echohelix/internal/events"
+)
+
+const (
+	// OutputLimitTruncate drops token output past the limit and lets the
+	// run finish; OutputLimitCancel also fails the run.
+	OutputLimitTruncate = "truncate"
+	OutputLimitCancel   = "cancel"
+)
+
+var ErrOutputLimitExceeded = errors.New("run output exceeded max output bytes")
+
+func NormalizeOutputLimitAction(action string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(action)) {
+	case "", OutputLimitTruncate:
+		return OutputLimitTruncate, nil
+	case OutputLimitCancel:
+		return OutputLimitCancel, nil
+	default:
+		return "", fmt.Errorf("invalid max output action %q", action)
+	}
+}
+
+// SetOutputLimit caps the payload bytes a run may produce; zero disables
+// the cap.
+func (s *Service) SetOutputLimit(maxBytes int64, action string) error {
+	normalized, err := NormalizeOutputLimitAction(action)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.maxOutputBytes = maxBytes
+	s.outputLimitAction = normalized
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Service) newOutputBudget() *outputBudget {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxOutputBytes <= 0 {
+		return nil
+	}
+	return &outputBudget{max: s.maxOutputBytes, cancel: s.outputLimitAction == OutputLimitCancel}
+}
+
+// outputBudget counts the payload bytes of one run's backend events.
+type outputBudget struct {
+	max       int64
+	used      int64
+	cancel    bool
+	truncated bool
+}
+
+// admit counts ev and reports whether to keep it. The token that crosses
+// the limit is cut to fit and later tokens are dropped; crossed is true for
+// that one event. Other events are always kept so the run can still report
+// its outcome.
+func (b *outputBudget) admit(ev *events.Event) (keep, crossed bool) {
+	isToken := ev.Type == "" || ev.Type == events.TypeToken
+	if isToken && b.truncated {
+		return false, false
+	}
+	var size int64
+	text, _ := ev.Payload["text"].(string)
+	if isToken {
+		size = int64(len(text))
+	} else if raw, err := json.Marshal(ev.Payload); err == nil {
+		size = int64(len(raw))
+	}
+	b.used += size
+	if b.used <= b.max || b.truncated {
+		return true, false
+	}
+	b.truncated = true
+	if !isToken {
+		return true, true
+	}
+	keepBytes := len(text) - int(b.used-b.max)
+	for keepBytes > 0 && !utf8.RuneStart(text[keepBytes]) {
+		keepBytes--
+	}
+	if keepBytes <= 0 {
+		return false, true
+	}
+	payload := make(map[string]any, len(ev.Payload))
+	for k, v := range ev.Payload {
+		payload[k] = v
+	}
+	payload["text"] = text[:keepBytes]
+	ev.Payload = payload
+	if ev.Compat != nil {
+		ev.Compat.Text = ""
+	}
+	return true, true
+}
+
+func (b *outputBudget) notice() map[string]any {
+	msg := fmt.Sprintf("run output exceeded %d bytes; further token output is dropped", b.max)
+	if b.cancel {
+		msg = fmt.Sprintf("run output exceeded %d bytes; the run is cancelled", b.max)
+	}
+	return map[string]any{
+		"status":  StatusStreaming,
+		"reason":  "output_truncated",
+		"message": msg,
+	}
+}
@@ -0,0 +1,136 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"echohelix/internal/driver"
+	"echohelix/internal/events"
+)
+
+var (
+	ErrPauseUnsupported = errors.New("backend does not support pausing runs")
+	ErrRunPaused        = errors.New("run is paused")
+	ErrRunNotPaused     = errors.New("run is not paused")
+)
+
+// RunPause reports a run's state after PauseRun or ResumeRun. Remaining is
+// the timeout left on the run; it does not count down while paused.
+type RunPause struct {
+	RunID            string     `json:"run_id"`
+	Status           string     `json:"status"`
+	RemainingSeconds int64      `json:"remaining_seconds"`
+	Deadline         *time.Time `json:"deadline,omitempty"`
+}
+
+// PauseRun suspends a streaming run on a backend that reports
+// supports_pause and stops its timeout clock until ResumeRun.
+func (s *Service) PauseRun(ctx context.Context, runID string) (RunPause, error) {
+	s.mu.Lock()
+	ar := s.active[runID]
+	if ar == nil || isTerminalStatus(ar.status) || ar.status == StatusCancelling {
+		s.mu.Unlock()
+		return RunPause{}, ErrRunNotActive
+	}
+	if ar.status == StatusPaused {
+		s.mu.Unlock()
+		return RunPause{}, ErrRunPaused
+	}
+	if ar.status != StatusStreaming {
+		// Queued and starting runs have no process to suspend yet.
+		s.mu.Unlock()
+		return RunPause{}, ErrRunNotActive
+	}
+	drv, backend := ar.driver, ar.backend
+	s.mu.Unlock()
+
+	pauser, err := pauserFor(ctx, drv)
+	if err != nil {
+		return RunPause{}, err
+	}
+	if err := pauser.PauseRun(ctx, runID); err != nil {
+		return RunPause{}, err
+	}
+
+	s.mu.Lock()
+	if ar.status != StatusStreaming || !ar.timer.Stop() {
+		// Cancelled or timed out meanwhile. The adapter already suspended
+		// the process, so let it run again (or kill it) to end the run.
+		s.mu.Unlock()
+		if err := pauser.ResumeRun(context.Background(), runID); err != nil {
+			_ = drv.Cancel(context.Background(), runID)
+		}
+		return RunPause{}, ErrRunNotActive
+	}
+	ar.remaining = time.Until(ar.deadline)
+	ar.status = StatusPaused
+	out := RunPause{RunID: runID, Status: StatusPaused, RemainingSeconds: int64(ar.remaining / time.Second)}
+	s.mu.Unlock()
+
+	_ = s.ledger.UpdateRunStatus(context.Background(), runID, StatusPaused, "")
+	s.emit(context.Background(), runID, backend, "bridge", events.TypeStatus, map[string]any{
+		"status": StatusPaused,
+		"reason": "paused",
+	})
+	return out, nil
+}
+
+// ResumeRun continues a paused run; its deadline moves out by however long
+// it was paused.
+func (s *Service) ResumeRun(ctx context.Context, runID string) (RunPause, error) {
+	s.mu.Lock()
+	ar := s.active[runID]
+	if ar == nil || isTerminalStatus(ar.status) {
+		s.mu.Unlock()
+		return RunPause{}, ErrRunNotActive
+	}
+	if ar.status != StatusPaused {
+		s.mu.Unlock()
+		return RunPause{}, ErrRunNotPaused
+	}
+	drv, backend := ar.driver, ar.backend
+	s.mu.Unlock()
+
+	pauser, err := pauserFor(ctx, drv)
+	if err != nil {
+		return RunPause{}, err
+	}
+	if err := pauser.ResumeRun(ctx, runID); err != nil {
+		return RunPause{}, err
+	}
+
+	s.mu.Lock()
+	if ar.status != StatusPaused {
+		s.mu.Unlock()
+		return RunPause{}, ErrRunNotPaused
+	}
+	ar.deadline = time.Now().Add(ar.remaining)
+	ar.timer.Reset(ar.remaining)
+	ar.status = StatusStreaming
+	deadline := ar.deadline.UTC()
+	out := RunPause{RunID: runID, Status: StatusStreaming, RemainingSeconds: int64(ar.remaining / time.Second), Deadline: &deadline}
+	s.mu.Unlock()
+
+	_ = s.ledger.UpdateRunStatus(context.Background(), runID, StatusStreaming, "")
+	s.emit(context.Background(), runID, backend, "bridge", events.TypeStatus, map[string]any{
+		"status": StatusStreaming,
+		"reason": "resumed",
+	})
+	return out, nil
+}
+
+func pauserFor(ctx context.Context, drv driver.Driver) (driver.Pauser, error) {
+	pauser, ok := drv.(driver.Pauser)
+	if !ok {
+		return nil, ErrPauseUnsupported
+	}
+	caps, err := drv.Capabilities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !caps.SupportsPause {
+		return nil, ErrPauseUnsupported
+	}
+	return pauser, nil
+}
@@ -0,0 +1,227 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"echohelix/internal/events"
+)
+
+const (
+	// PersistOverflowBlock makes the stream wait for queue space.
+	PersistOverflowBlock = "block"
+	// PersistOverflowInline writes the event on the stream goroutine.
+	PersistOverflowInline = "inline"
+	// PersistOverflowDrop publishes the event without persisting it.
+	PersistOverflowDrop = "drop"
+)
+
+// PersistOptions configures asynchronous event persistence. With Workers <= 0
+// events are written inline before they are published, as before.
+type PersistOptions struct {
+	Workers   int
+	QueueSize int
+	Overflow  string
+	// BatchSize caps how many queued events of a run are written in one
+	// transaction; FlushInterval is how long a partial batch may wait for
+	// more events. A finishing run flushes immediately.
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// persistPool writes events through a bounded queue per active run, drained
+// in order by one goroutine per run; Workers caps concurrent ledger writes.
+type persistPool struct {
+	sem           chan struct{}
+	queueSize     int
+	overflow      string
+	batchSize     int
+	flushInterval time.Duration
+
+	mu     sync.Mutex
+	queues map[string]*persistQueue
+}
+
+type persistQueue struct {
+	mu     sync.RWMutex
+	closed bool
+	ch     chan events.Event
+	done   chan struct{}
+}
+
+func (s *Service) SetEventPersistence(opts PersistOptions) error {
+	if opts.Workers <= 0 {
+		s.mu.Lock()
+		s.persist = nil
+		s.mu.Unlock()
+		return nil
+	}
+	overflow := strings.ToLower(strings.TrimSpace(opts.Overflow))
+	switch overflow {
+	case "":
+		overflow = PersistOverflowBlock
+	case PersistOverflowBlock, PersistOverflowInline, PersistOverflowDrop:
+	default:
+		return fmt.Errorf("invalid persist overflow policy %q", opts.Overflow)
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1
+	}
+	if opts.FlushInterval < 0 {
+		opts.FlushInterval = 0
+	}
+	s.mu.Lock()
+	s.persist = &persistPool{
+		sem:           make(chan struct{}, opts.Workers),
+		queueSize:     opts.QueueSize,
+		overflow:      overflow,
+		batchSize:     opts.BatchSize,
+		flushInterval: opts.FlushInterval,
+		queues:        map[string]*persistQueue{},
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// openPersistQueue starts async persistence for runID and returns a func
+// that waits until every queued event has been written.
+func (s *Service) openPersistQueue(runID string) func() {
+	s.mu.Lock()
+	p := s.persist
+	s.mu.Unlock()
+	if p == nil {
+		return func() {}
+	}
+	q := &persistQueue{ch: make(chan events.Event, p.queueSize), done: make(chan struct{})}
+	p.mu.Lock()
+	p.queues[runID] = q
+	p.mu.Unlock()
+
+	go func() {
+		defer close(q.done)
+		batch := make([]events.Event, 0, p.batchSize)
+		for ev := range q.ch {
+			batch = p.fillBatch(q.ch, append(batch[:0], ev))
+			p.sem <- struct{}{}
+			s.persistBatch(context.Background(), batch)
+			<-p.sem
+			s.diag.persistQueued.Add(-int64(len(batch)))
+		}
+	}()
+
+	return func() {
+		p.mu.Lock()
+		delete(p.queues, runID)
+		p.mu.Unlock()
+		q.mu.Lock()
+		q.closed = true
+		close(q.ch)
+		q.mu.Unlock()
+		<-q.done
+	}
+}
+
+// fillBatch adds queued events to batch until it is full, the queue is
+// closed, or the flush interval passes without the batch filling up.
+func (p *persistPool) fillBatch(ch <-chan events.Event, batch []events.Event) []events.Event {
+	var timeout <-chan time.Time
+	if p.flushInterval > 0 && len(batch) < p.batchSize {
+		timer := time.NewTimer(p.flushInterval)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for len(batch) < p.batchSize {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, ev)
+			continue
+		default:
+		}
+		if timeout == nil {
+			return batch
+		}
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, ev)
+		case <-timeout:
+			return batch
+		}
+	}
+	return batch
+}
+
+// persistBatch writes batch in one transaction, falling back to per-event
+// writes (with duplicate seq handling) when the transaction fails.
+func (s *Service) persistBatch(ctx context.Context, batch []events.Event) {
+	if len(batch) == 1 {
+		s.persistEvent(ctx, batch[0])
+		return
+	}
+	if err := s.ledger.AppendEvents(ctx, batch); err == nil {
+		s.diag.persistBatches.Add(1)
+		return
+	}
+	for _, ev := range batch {
+		s.persistEvent(ctx, ev)
+	}
+}
+
+// enqueuePersist hands ev to the run's queue. It returns false when the run
+// has no open queue and the caller must persist inline.
+func (s *Service) enqueuePersist(ev events.Event) bool {
+	s.mu.Lock()
+	p := s.persist
+	s.mu.Unlock()
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	q := p.queues[ev.RunID]
+	p.mu.Unlock()
+	if q == nil {
+		return false
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return false
+	}
+	depth := s.diag.persistQueued.Add(1)
+	for {
+		high := s.diag.persistHighWater.Load()
+		if depth <= high || s.diag.persistHighWater.CompareAndSwap(high, depth) {
+			break
+		}
+	}
+	select {
+	case q.ch <- ev:
+		return true
+	default:
+	}
+	switch p.overflow {
+	case PersistOverflowDrop:
+		s.diag.persistQueued.Add(-1)
+		s.diag.persistDropped.Add(1)
+		return true
+	case PersistOverflowInline:
+		s.diag.persistQueued.Add(-1)
+		s.diag.persistOverflowInline.Add(1)
+		return false
+	default:
+		q.ch <- ev
+		return true
+	}
+}
@@ -0,0 +1,557 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"echohelix/internal/ledger"
+
+	"github.com/google/uuid"
+)
+
+const (
+	PipelineRunning   = "running"
+	PipelineCompleted = "completed"
+	PipelineFailed    = "failed"
+	PipelineCancelled = "cancelled"
+
+	StepPending   = "pending"
+	StepRunning   = "running"
+	StepCompleted = "completed"
+	StepFailed    = "failed"
+	StepCancelled = "cancelled"
+	// StepSkipped marks a step whose dependency did not complete.
+	StepSkipped = "skipped"
+
+	maxPipelineSteps = 32
+)
+
+var (
+	pipelineStepIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+	// pipelineRefPattern matches {{steps.<id>.<field>}} in step prompts.
+	pipelineRefPattern = regexp.MustCompile(`\{\{\s*steps\.([A-Za-z0-9_-]+)\.([a-z_]+)\s*\}\}`)
+)
+
+// Fields a step prompt can reference on an earlier step.
+const (
+	PipelineRefFinalText = "final_text"
+	PipelineRefOutput    = "output"
+	PipelineRefRunID     = "run_id"
+)
+
+type PipelineRequest struct {
+	Name          string `json:"name,omitempty"`
+	WorkspaceID   string `json:"workspace_id"`
+	WorkspacePath string `json:"workspace_path"`
+	// Backend is the default for steps that do not set one.
+	Backend string                `json:"backend,omitempty"`
+	Steps   []PipelineStepRequest `json:"steps"`
+	// SubmittedBy is filled from the authenticated principal, never the body.
+	SubmittedBy string `json:"-"`
+}
+
+type PipelineStepRequest struct {
+	ID        string         `json:"id"`
+	Backend   string         `json:"backend,omitempty"`
+	Prompt    string         `json:"prompt"`
+	Context   map[string]any `json:"context,omitempty"`
+	Options   RunOptions     `json:"options,omitempty"`
+	DependsOn []string       `json:"depends_on,omitempty"`
+}
+
+// Pipeline is a DAG of runs. Each step is submitted as a run once all of
+// its dependencies completed, with {{steps.<id>.<field>}} references in its
+// prompt replaced by the dependency's output.
+type Pipeline struct {
+	ID            string         `json:"pipeline_id"`
+	Name          string         `json:"name,omitempty"`
+	WorkspaceID   string         `json:"workspace_id"`
+	WorkspacePath string         `json:"workspace_path,omitempty"`
+	Status        string         `json:"status"`
+	Steps         []PipelineStep `json:"steps"`
+	SubmittedBy   string         `json:"submitted_by,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+type PipelineStep struct {
+	ID        string         `json:"id"`
+	Backend   string         `json:"backend"`
+	Prompt    string         `json:"prompt"`
+	Context   map[string]any `json:"context,omitempty"`
+	Options   RunOptions     `json:"options,omitempty"`
+	DependsOn []string       `json:"depends_on,omitempty"`
+	Status    string         `json:"status"`
+	RunID     string         `json:"run_id,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+type PipelineQuery struct {
+	Status      string
+	SubmittedBy string
+	Limit       int
+}
+
+// pipelineState tracks which runs belong to unfinished pipelines. mu also
+// serializes pipeline advances, so a run that finishes while its step is
+// still being submitted is only looked up once the step is recorded.
+type pipelineState struct {
+	mu     sync.Mutex
+	byRun  map[string]string
+	active int
+}
+
+// SubmitPipeline validates the DAG, stores the pipeline and submits its
+// root steps. Steps that reference another step's output depend on it even
+// when depends_on does not list it.
+func (s *Service) SubmitPipeline(ctx context.Context, req PipelineRequest) (Pipeline, error) {
+	if s.isEmergencyActive() {
+		return Pipeline{}, ErrEmergencyStopActive
+	}
+	if err := s.policy.ValidateWorkspace(req.WorkspacePath); err != nil {
+		return Pipeline{}, err
+	}
+	steps, err := buildPipelineSteps(req)
+	if err != nil {
+		return Pipeline{}, err
+	}
+	now := time.Now().UTC()
+	p := Pipeline{
+		ID:            uuid.NewString(),
+		Name:          strings.TrimSpace(req.Name),
+		WorkspaceID:   req.WorkspaceID,
+		WorkspacePath: req.WorkspacePath,
+		Status:        PipelineRunning,
+		Steps:         steps,
+		SubmittedBy:   req.SubmittedBy,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	s.pipelines.mu.Lock()
+	defer s.pipelines.mu.Unlock()
+	// Root steps are submitted before the pipeline is saved, so a request
+	// the run service refuses fails here rather than being accepted as an
+	// already failed pipeline.
+	for i := range p.Steps {
+		st := &p.Steps[i]
+		if len(st.DependsOn) > 0 {
+			continue
+		}
+		if err := s.startPipelineStep(ctx, &p, st); err != nil {
+			for _, started := range p.Steps[:i] {
+				if started.RunID != "" {
+					delete(s.pipelines.byRun, started.RunID)
+					_ = s.Cancel(context.Background(), started.RunID)
+				}
+			}
+			return Pipeline{}, fmt.Errorf("step %q: %w", st.ID, err)
+		}
+	}
+	if err := s.savePipeline(ctx, p); err != nil {
+		return Pipeline{}, err
+	}
+	s.pipelines.active++
+	s.advancePipelineLocked(ctx, &p)
+	return p, nil
+}
+
+func buildPipelineSteps(req PipelineRequest) ([]PipelineStep, error) {
+	if len(req.Steps) == 0 {
+		return nil, fmt.Errorf("steps is required")
+	}
+	if len(req.Steps) > maxPipelineSteps {
+		return nil, fmt.Errorf("a pipeline has at most %d steps", maxPipelineSteps)
+	}
+	index := make(map[string]int, len(req.Steps))
+	for i, st := range req.Steps {
+		if !pipelineStepIDPattern.MatchString(st.ID) {
+			return nil, fmt.Errorf("steps[%d].id must be 1-64 letters, digits, '-' or '_'", i)
+		}
+		if _, dup := index[st.ID]; dup {
+			return nil, fmt.Errorf("duplicate step id %q", st.ID)
+		}
+		index[st.ID] = i
+	}
+	out := make([]PipelineStep, 0, len(req.Steps))
+	for _, st := range req.Steps {
+		if strings.TrimSpace(st.Prompt) == "" {
+			return nil, fmt.Errorf("step %q: prompt is required", st.ID)
+		}
+		deps := map[string]struct{}{}
+		for _, dep := range st.DependsOn {
+			deps[dep] = struct{}{}
+		}
+		for _, m := range pipelineRefPattern.FindAllStringSubmatch(st.Prompt, -1) {
+			switch m[2] {
+			case PipelineRefFinalText, PipelineRefOutput, PipelineRefRunID:
+			default:
+				return nil, fmt.Errorf("step %q: unknown field %q in %s", st.ID, m[2], m[0])
+			}
+			deps[m[1]] = struct{}{}
+		}
+		dependsOn := make([]string, 0, len(deps))
+		for dep := range deps {
+			if _, ok := index[dep]; !ok {
+				return nil, fmt.Errorf("step %q depends on unknown step %q", st.ID, dep)
+			}
+			if dep == st.ID {
+				return nil, fmt.Errorf("step %q depends on itself", st.ID)
+			}
+			dependsOn = append(dependsOn, dep)
+		}
+		sort.Strings(dependsOn)
+		backend := st.Backend
+		if backend == "" {
+			backend = req.Backend
+		}
+		if backend == "" {
+			backend = "codex"
+		}
+		out = append(out, PipelineStep{
+			ID:        st.ID,
+			Backend:   backend,
+			Prompt:    st.Prompt,
+			Context:   st.Context,
+			Options:   st.Options,
+			DependsOn: dependsOn,
+			Status:    StepPending,
+		})
+	}
+	if cycle := pipelineCycle(out); cycle != "" {
+		return nil, fmt.Errorf("steps form a cycle through %q", cycle)
+	}
+	return out, nil
+}
+
+// pipelineCycle returns a step on a dependency cycle, or "" for a DAG.
+func pipelineCycle(steps []PipelineStep) string {
+	deps := make(map[string][]string, len(steps))
+	for _, st := range steps {
+		deps[st.ID] = st.DependsOn
+	}
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var visit func(id string) string
+	visit = func(id string) string {
+		switch state[id] {
+		case visiting:
+			return id
+		case done:
+			return ""
+		}
+		state[id] = visiting
+		for _, dep := range deps[id] {
+			if c := visit(dep); c != "" {
+				return c
+			}
+		}
+		state[id] = done
+		return ""
+	}
+	for _, st := range steps {
+		if c := visit(st.ID); c != "" {
+			return c
+		}
+	}
+	return ""
+}
+
+// advancePipelineLocked settles steps whose runs finished, skips steps
+// behind a failed dependency and submits steps that are ready, until
+// nothing changes. Callers hold s.pipelines.mu.
+func (s *Service) advancePipelineLocked(ctx context.Context, p *Pipeline) {
+	if p.Status != PipelineRunning {
+		return
+	}
+	for changed := true; changed; {
+		changed = false
+		for i := range p.Steps {
+			st := &p.Steps[i]
+			switch st.Status {
+			case StepRunning:
+				rec, err := s.ledger.GetRun(ctx, st.RunID)
+				if err != nil || !isTerminalStatus(rec.Status) {
+					continue
+				}
+				delete(s.pipelines.byRun, st.RunID)
+				st.Status, st.Error = rec.Status, rec.Error
+				changed = true
+			case StepPending:
+				ready, blocked := pipelineDepsState(p, st)
+				if blocked != "" {
+					st.Status = StepSkipped
+					st.Error = "dependency " + blocked + " did not complete"
+					changed = true
+					continue
+				}
+				if !ready {
+					continue
+				}
+				_ = s.startPipelineStep(ctx, p, st)
+				changed = true
+			}
+		}
+	}
+	settled := true
+	cancelled, failed := false, false
+	for _, st := range p.Steps {
+		switch st.Status {
+		case StepPending, StepRunning:
+			settled = false
+		case StepCancelled:
+			cancelled = true
+		case StepFailed, StepSkipped:
+			failed = true
+		}
+	}
+	if settled {
+		switch {
+		case cancelled:
+			p.Status = PipelineCancelled
+		case failed:
+			p.Status = PipelineFailed
+		default:
+			p.Status = PipelineCompleted
+		}
+		s.pipelines.active--
+	}
+	p.UpdatedAt = time.Now().UTC()
+	if err := s.savePipeline(ctx, *p); err != nil {
+		log.Printf("warn: save pipeline %s: %v", p.ID, err)
+	}
+}
+
+// pipelineDepsState reports whether every dependency of st completed, or
+// the first one that ended otherwise.
+func pipelineDepsState(p *Pipeline, st *PipelineStep) (bool, string) {
+	ready := true
+	for _, dep := range st.DependsOn {
+		for _, other := range p.Steps {
+			if other.ID != dep {
+				continue
+			}
+			switch other.Status {
+			case StepCompleted:
+			case StepPending, StepRunning:
+				ready = false
+			default:
+				return false, dep
+			}
+		}
+	}
+	return ready, ""
+}
+
+// startPipelineStep submits the step's run, or marks the step failed and
+// returns why it could not be submitted.
+func (s *Service) startPipelineStep(ctx context.Context, p *Pipeline, st *PipelineStep) error {
+	prompt, err := s.renderPipelinePrompt(ctx, p, st.Prompt)
+	if err == nil {
+		var r Run
+		r, err = s.Submit(ctx, SubmitRequest{
+			WorkspaceID:   p.WorkspaceID,
+			WorkspacePath: p.WorkspacePath,
+			Backend:       st.Backend,
+			Prompt:        prompt,
+			Context:       cloneContext(st.Context),
+			Options:       st.Options,
+			SubmittedBy:   p.SubmittedBy,
+		})
+		if err == nil {
+			st.Status, st.RunID = StepRunning, r.ID
+			s.pipelines.byRun[r.ID] = p.ID
+			return nil
+		}
+	}
+	st.Status, st.Error = StepFailed, err.Error()
+	return err
+}
+
+// renderPipelinePrompt replaces step references with the referenced run's
+// id or assistant output, condensed like included runs.
+func (s *Service) renderPipelinePrompt(ctx context.Context, p *Pipeline, prompt string) (string, error) {
+	var renderErr error
+	out := pipelineRefPattern.ReplaceAllStringFunc(prompt, func(ref string) string {
+		m := pipelineRefPattern.FindStringSubmatch(ref)
+		var runID string
+		for _, st := range p.Steps {
+			if st.ID == m[1] {
+				runID = st.RunID
+			}
+		}
+		if m[2] == PipelineRefRunID {
+			return runID
+		}
+		parts := IncludePartsAll
+		if m[2] == PipelineRefFinalText {
+			parts = IncludePartsFinal
+		}
+		text, err := s.runOutputText(ctx, runID, parts)
+		if err != nil {
+			renderErr = fmt.Errorf("read output of step %s: %w", m[1], err)
+			return ""
+		}
+		text, _ = condenseText(text, s.includeRunMaxBytes)
+		return text
+	})
+	return out, renderErr
+}
+
+// pipelineRunFinished advances the pipeline of runID, if any, once the run
+// reached a terminal status.
+func (s *Service) pipelineRunFinished(runID string) {
+	s.pipelines.mu.Lock()
+	idle := s.pipelines.active == 0
+	s.pipelines.mu.Unlock()
+	if idle {
+		return
+	}
+	go func() {
+		s.pipelines.mu.Lock()
+		defer s.pipelines.mu.Unlock()
+		pipelineID, ok := s.pipelines.byRun[runID]
+		if !ok {
+			return
+		}
+		ctx := context.Background()
+		p, err := s.loadPipeline(ctx, pipelineID)
+		if err != nil {
+			log.Printf("warn: load pipeline %s: %v", pipelineID, err)
+			return
+		}
+		s.advancePipelineLocked(ctx, &p)
+	}()
+}
+
+// CancelPipeline cancels the running steps of a pipeline and every step
+// that has not started.
+func (s *Service) CancelPipeline(ctx context.Context, pipelineID string) (Pipeline, error) {
+	s.pipelines.mu.Lock()
+	defer s.pipelines.mu.Unlock()
+	p, err := s.loadPipeline(ctx, pipelineID)
+	if err != nil {
+		return Pipeline{}, err
+	}
+	if p.Status != PipelineRunning {
+		return p, nil
+	}
+	for i := range p.Steps {
+		st := &p.Steps[i]
+		switch st.Status {
+		case StepPending:
+			st.Status = StepCancelled
+		case StepRunning:
+			if err := s.Cancel(ctx, st.RunID); err != nil {
+				log.Printf("warn: cancel pipeline %s step %s: %v", p.ID, st.ID, err)
+			}
+		}
+	}
+	s.advancePipelineLocked(ctx, &p)
+	return p, nil
+}
+
+// ResumePipelines picks up pipelines left running by an earlier process.
+// Steps whose run is still tracked by this process keep running; runs the
+// process no longer tracks cannot report back, so their steps fail. The
+// API server calls it from Start, before it begins serving.
+func (s *Service) ResumePipelines(ctx context.Context) (int, error) {
+	recs, err := s.ledger.ListPipelines(ctx, PipelineRunning, "", 0)
+	if err != nil {
+		return 0, err
+	}
+	s.pipelines.mu.Lock()
+	defer s.pipelines.mu.Unlock()
+	for _, rec := range recs {
+		p, err := decodePipeline(rec)
+		if err != nil {
+			return 0, err
+		}
+		for i := range p.Steps {
+			st := &p.Steps[i]
+			if st.Status != StepRunning {
+				continue
+			}
+			if _, known := s.pipelines.byRun[st.RunID]; known {
+				continue
+			}
+			s.mu.Lock()
+			_, active := s.active[st.RunID]
+			_, queued := s.queued[st.RunID]
+			s.mu.Unlock()
+			if active || queued {
+				s.pipelines.byRun[st.RunID] = p.ID
+				continue
+			}
+			if rec, err := s.ledger.GetRun(ctx, st.RunID); err == nil && isTerminalStatus(rec.Status) {
+				continue
+			}
+			st.Status, st.Error = StepFailed, "interrupted by bridge restart"
+		}
+		s.pipelines.active++
+		s.advancePipelineLocked(ctx, &p)
+	}
+	return len(recs), nil
+}
+
+func (s *Service) GetPipeline(ctx context.Context, pipelineID string) (Pipeline, error) {
+	return s.loadPipeline(ctx, pipelineID)
+}
+
+func (s *Service) ListPipelines(ctx context.Context, q PipelineQuery) ([]Pipeline, error) {
+	recs, err := s.ledger.ListPipelines(ctx, q.Status, q.SubmittedBy, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Pipeline, 0, len(recs))
+	for _, rec := range recs {
+		p, err := decodePipeline(rec)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func (s *Service) loadPipeline(ctx context.Context, pipelineID string) (Pipeline, error) {
+	rec, err := s.ledger.GetPipeline(ctx, pipelineID)
+	if err != nil {
+		return Pipeline{}, err
+	}
+	return decodePipeline(rec)
+}
+
+func (s *Service) savePipeline(ctx context.Context, p Pipeline) error {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return s.ledger.UpsertPipeline(ctx, ledger.PipelineRecord{
+		ID:          p.ID,
+		WorkspaceID: p.WorkspaceID,
+		Status:      p.Status,
+		SubmittedBy: p.SubmittedBy,
+		Payload:     string(payload),
+		CreatedAt:   p.CreatedAt,
+		UpdatedAt:   p.UpdatedAt,
+	})
+}
+
+func decodePipeline(rec ledger.PipelineRecord) (Pipeline, error) {
+	var p Pipeline
+	if err := json.Unmarshal([]byte(rec.Payload), &p); err != nil {
+		return Pipeline{}, fmt.Errorf("decode pipeline %s: %w", rec.ID, err)
+	}
+	return p, nil
+}
@@ -0,0 +1,81 @@
+package run
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TokenPrice is a per-million-token rate in USD.
+type TokenPrice struct {
+	InputPerMTok  float64 `json:"input_per_mtok"`
+	OutputPerMTok float64 `json:"output_per_mtok"`
+}
+
+func (p TokenPrice) cost(input, output int64) float64 {
+	return (float64(input)*p.InputPerMTok + float64(output)*p.OutputPerMTok) / 1e6
+}
+
+// ParseTokenPricing parses comma-separated "backend[/model]:input/output"
+// entries, e.g. "codex:1.25/10,codex/gpt-5-mini:0.25/2". Rates are USD per
+// million tokens; a backend-only entry is the fallback for its models.
+func ParseTokenPricing(spec string) (map[string]TokenPrice, error) {
+	out := map[string]TokenPrice{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		idx := strings.LastIndex(item, ":")
+		if idx <= 0 {
+			return nil, fmt.Errorf("pricing entry %q must be backend[/model]:input/output", item)
+		}
+		key := strings.TrimSpace(item[:idx])
+		rates := strings.Split(item[idx+1:], "/")
+		if len(rates) != 2 {
+			return nil, fmt.Errorf("pricing entry %q must be backend[/model]:input/output", item)
+		}
+		in, errIn := strconv.ParseFloat(strings.TrimSpace(rates[0]), 64)
+		outRate, errOut := strconv.ParseFloat(strings.TrimSpace(rates[1]), 64)
+		if errIn != nil || errOut != nil || in < 0 || outRate < 0 {
+			return nil, fmt.Errorf("pricing entry %q has invalid rates", item)
+		}
+		out[key] = TokenPrice{InputPerMTok: in, OutputPerMTok: outRate}
+	}
+	return out, nil
+}
+
+func (s *Service) SetTokenPricing(pricing map[string]TokenPrice) {
+	next := make(map[string]TokenPrice, len(pricing))
+	for k, v := range pricing {
+		if key := strings.TrimSpace(k); key != "" {
+			next[key] = v
+		}
+	}
+	s.mu.Lock()
+	s.tokenPricing = next
+	s.mu.Unlock()
+}
+
+func (s *Service) pricingConfigured() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.tokenPricing) > 0
+}
+
+func (s *Service) priceFor(backend, model string) (TokenPrice, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if model != "" {
+		if p, ok := s.tokenPricing[backend+"/"+model]; ok {
+			return p, true
+		}
+	}
+	p, ok := s.tokenPricing[backend]
+	return p, ok
+}
+
+func roundUSD(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
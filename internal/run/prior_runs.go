@@ -0,0 +1,214 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"echohelix/internal/events"
+)
+
+const (
+	IncludePartsFinal = "final"
+	IncludePartsAll   = "all"
+
+	maxIncludedRuns = 8
+)
+
+type includeRunRef struct {
+	RunID string
+	Parts string
+}
+
+type includedRun struct {
+	RunID     string
+	Parts     string
+	Status    string
+	Text      string
+	Bytes     int
+	Truncated bool
+}
+
+// SetIncludeRunsLimits caps how much prior-run output context.include_runs
+// may inject: perRun bytes per referenced run and total bytes overall.
+func (s *Service) SetIncludeRunsLimits(perRun, total int) {
+	if perRun > 0 {
+		s.includeRunMaxBytes = perRun
+	}
+	if total > 0 {
+		s.includeRunsMaxTotal = total
+	}
+}
+
+func parseIncludeRunRefs(contextMap map[string]any) ([]includeRunRef, error) {
+	if contextMap == nil {
+		return nil, nil
+	}
+	raw, ok := contextMap["include_runs"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("context.include_runs must be an array")
+	}
+	if len(list) > maxIncludedRuns {
+		return nil, fmt.Errorf("context.include_runs supports at most %d runs", maxIncludedRuns)
+	}
+	out := make([]includeRunRef, 0, len(list))
+	seen := map[string]struct{}{}
+	for i, item := range list {
+		ref := includeRunRef{Parts: IncludePartsFinal}
+		switch v := item.(type) {
+		case string:
+			ref.RunID = strings.TrimSpace(v)
+		case map[string]any:
+			ref.RunID = strings.TrimSpace(anyString(v["run_id"]))
+			if parts := strings.ToLower(strings.TrimSpace(anyString(v["parts"]))); parts != "" {
+				ref.Parts = parts
+			}
+		default:
+			return nil, fmt.Errorf("context.include_runs[%d] must be string or object", i)
+		}
+		if ref.RunID == "" {
+			return nil, fmt.Errorf("context.include_runs[%d].run_id is required", i)
+		}
+		switch ref.Parts {
+		case IncludePartsFinal, IncludePartsAll:
+		default:
+			return nil, fmt.Errorf("context.include_runs[%d].parts must be %q or %q", i, IncludePartsFinal, IncludePartsAll)
+		}
+		if _, dup := seen[ref.RunID]; dup {
+			continue
+		}
+		seen[ref.RunID] = struct{}{}
+		out = append(out, ref)
+	}
+	return out, nil
+}
+
+// resolveIncludedRuns loads the output of each referenced run. Only finished
+// runs from the same workspace can be referenced.
+func (s *Service) resolveIncludedRuns(ctx context.Context, workspaceID string, contextMap map[string]any) ([]includedRun, error) {
+	refs, err := parseIncludeRunRefs(contextMap)
+	if err != nil || len(refs) == 0 {
+		return nil, err
+	}
+	budget := s.includeRunsMaxTotal
+	out := make([]includedRun, 0, len(refs))
+	for _, ref := range refs {
+		rec, err := s.ledger.GetRun(ctx, ref.RunID)
+		if err != nil {
+			return nil, fmt.Errorf("include run %s: %w", ref.RunID, err)
+		}
+		if rec.WorkspaceID != workspaceID {
+			return nil, fmt.Errorf("include run %s: run belongs to a different workspace", ref.RunID)
+		}
+		if !isTerminalStatus(rec.Status) {
+			return nil, fmt.Errorf("include run %s: run is still %s", ref.RunID, rec.Status)
+		}
+		text, err := s.runOutputText(ctx, ref.RunID, ref.Parts)
+		if err != nil {
+			return nil, fmt.Errorf("include run %s: %w", ref.RunID, err)
+		}
+		if text == "" && rec.Error != "" {
+			text = "error: " + rec.Error
+		}
+		limit := s.includeRunMaxBytes
+		if budget < limit {
+			limit = budget
+		}
+		item := includedRun{RunID: ref.RunID, Parts: ref.Parts, Status: rec.Status, Bytes: len(text)}
+		item.Text, item.Truncated = condenseText(text, limit)
+		budget -= len(item.Text)
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+// FinalOutput returns the run's final-channel assistant text, or all of its
+// assistant text when it produced nothing on the final channel.
+func (s *Service) FinalOutput(ctx context.Context, runID string) (string, error) {
+	return s.runOutputText(ctx, runID, IncludePartsFinal)
+}
+
+func (s *Service) runOutputText(ctx context.Context, runID, parts string) (string, error) {
+	var final, all strings.Builder
+	fromSeq := int64(0)
+	for {
+		page, err := s.ledger.ListEvents(ctx, runID, fromSeq, 1000)
+		if err != nil {
+			return "", err
+		}
+		for _, ev := range page {
+			if ev.Type != events.TypeToken || ev.Role != events.RoleAssistant {
+				continue
+			}
+			text := payloadString(ev.Payload, "text")
+			if ev.Channel == events.ChannelFinal {
+				final.WriteString(text)
+			}
+			all.WriteString(text)
+		}
+		if len(page) < 1000 {
+			break
+		}
+		fromSeq = page[len(page)-1].Seq + 1
+	}
+	if parts == IncludePartsFinal && final.Len() > 0 {
+		return strings.TrimSpace(final.String()), nil
+	}
+	return strings.TrimSpace(all.String()), nil
+}
+
+// condenseText keeps the head and tail of text within limit bytes, favouring
+// the tail where agents usually put their conclusion.
+func condenseText(text string, limit int) (string, bool) {
+	if len(text) <= limit {
+		return text, false
+	}
+	if limit <= 0 {
+		return "", true
+	}
+	marker := fmt.Sprintf("\n[... %d bytes omitted ...]\n", len(text)-limit)
+	keep := limit - len(marker)
+	if keep <= 0 {
+		return strings.ToValidUTF8(text[len(text)-limit:], ""), true
+	}
+	head := keep * 2 / 5
+	tail := keep - head
+	return strings.ToValidUTF8(text[:head], "") + marker + strings.ToValidUTF8(text[len(text)-tail:], ""), true
+}
+
+func applyIncludedRuns(prompt string, contextMap map[string]any, runs []includedRun) (string, map[string]any) {
+	if len(runs) == 0 {
+		return prompt, contextMap
+	}
+	lines := []string{"[bridge prior runs]"}
+	resolved := make([]map[string]any, 0, len(runs))
+	for _, item := range runs {
+		lines = append(lines, fmt.Sprintf("### run %s (%s, %s)", item.RunID, item.Status, item.Parts))
+		if item.Text == "" {
+			lines = append(lines, "(no output)")
+		} else {
+			lines = append(lines, item.Text)
+		}
+		resolved = append(resolved, map[string]any{
+			"run_id":    item.RunID,
+			"parts":     item.Parts,
+			"status":    item.Status,
+			"bytes":     item.Bytes,
+			"truncated": item.Truncated,
+		})
+	}
+	lines = append(lines, "[/bridge prior runs]")
+	block := strings.Join(lines, "\n")
+	if contextMap == nil {
+		contextMap = map[string]any{}
+	}
+	contextMap["resolved_runs"] = resolved
+	if strings.TrimSpace(prompt) == "" {
+		return block, contextMap
+	}
+	return prompt + "\n\n" + block, contextMap
+}
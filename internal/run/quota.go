@@ -0,0 +1,150 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	QuotaEnforcementOff  = "off"
+	QuotaEnforcementSoft = "soft"
+	QuotaEnforcementHard = "hard"
+
+	// DeviceQuotaDefaultKey applies to every device without its own entry.
+	DeviceQuotaDefaultKey = "*"
+)
+
+var ErrQuotaExceeded = errors.New("quota_exceeded")
+
+// QuotaExceededError describes the daily quota that blocked a submit.
+type QuotaExceededError struct {
+	Scope      string // "backend" or "device"
+	Key        string
+	UsedTokens int64
+	Limit      int64
+	ResetAt    time.Time
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("daily token quota exceeded for %s %q: used %d of %d", e.Scope, e.Key, e.UsedTokens, e.Limit)
+}
+
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+func NormalizeQuotaEnforcement(mode string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", QuotaEnforcementOff:
+		return QuotaEnforcementOff, nil
+	case QuotaEnforcementSoft:
+		return QuotaEnforcementSoft, nil
+	case QuotaEnforcementHard:
+		return QuotaEnforcementHard, nil
+	default:
+		return "", fmt.Errorf("invalid quota enforcement mode %q", mode)
+	}
+}
+
+func (s *Service) SetQuotaEnforcement(mode string) error {
+	normalized, err := NormalizeQuotaEnforcement(mode)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.quotaEnforcement = normalized
+	s.mu.Unlock()
+	return nil
+}
+
+// SetDeviceDailyTokenQuota sets per-device daily token limits keyed by
+// principal address; DeviceQuotaDefaultKey covers unlisted devices.
+func (s *Service) SetDeviceDailyTokenQuota(quotas map[string]int64) {
+	next := make(map[string]int64, len(quotas))
+	for k, v := range quotas {
+		name := strings.TrimSpace(k)
+		if name == "" || v <= 0 {
+			continue
+		}
+		next[name] = v
+	}
+	s.mu.Lock()
+	s.deviceTokenQuota = next
+	s.mu.Unlock()
+}
+
+// checkQuota returns a *QuotaExceededError when backend or device usage for
+// the current UTC day has reached its limit and enforcement is not off.
+func (s *Service) checkQuota(ctx context.Context, backend, submitter string) error {
+	s.mu.Lock()
+	mode := s.quotaEnforcement
+	backendLimit, backendLimited := s.dailyTokenQuota[backend]
+	deviceLimit, deviceLimited := s.deviceTokenQuota[submitter]
+	if !deviceLimited {
+		deviceLimit, deviceLimited = s.deviceTokenQuota[DeviceQuotaDefaultKey]
+	}
+	s.mu.Unlock()
+	if mode == "" || mode == QuotaEnforcementOff {
+		return nil
+	}
+	if submitter == "" {
+		deviceLimited = false
+	}
+	if !backendLimited && !deviceLimited {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	to := now.Add(time.Nanosecond)
+	reset := from.Add(24 * time.Hour)
+
+	if backendLimited {
+		aggs, err := s.ledger.AggregateTokenUsage(ctx, from, to, backend)
+		if err != nil {
+			return fmt.Errorf("check token quota: %w", err)
+		}
+		var used int64
+		for _, agg := range aggs {
+			used += agg.TotalTokens
+		}
+		if used >= backendLimit {
+			return &QuotaExceededError{Scope: "backend", Key: backend, UsedTokens: used, Limit: backendLimit, ResetAt: reset}
+		}
+	}
+	if deviceLimited {
+		used, err := s.ledger.SumTokenUsageBySubmitter(ctx, from, to, submitter)
+		if err != nil {
+			return fmt.Errorf("check device token quota: %w", err)
+		}
+		if used >= deviceLimit {
+			return &QuotaExceededError{Scope: "device", Key: submitter, UsedTokens: used, Limit: deviceLimit, ResetAt: reset}
+		}
+	}
+	return nil
+}
+
+// enforceQuota rejects the submit in hard mode; soft mode only returns a
+// warning for the caller to surface.
+func (s *Service) enforceQuota(ctx context.Context, backend, submitter string) (string, error) {
+	err := s.checkQuota(ctx, backend, submitter)
+	if err == nil {
+		return "", nil
+	}
+	var exceeded *QuotaExceededError
+	if !errors.As(err, &exceeded) {
+		return "", err
+	}
+	s.mu.Lock()
+	mode := s.quotaEnforcement
+	s.mu.Unlock()
+	if mode == QuotaEnforcementHard {
+		return "", err
+	}
+	log.Printf("quota warning: %v", err)
+	return err.Error(), nil
+}
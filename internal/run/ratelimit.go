@@ -0,0 +1,18 @@
+package run
+
+import (
+	"context"
+	"time"
+
+	"echohelix/internal/ledger"
+)
+
+// HitRateWindow and ResetRateWindow keep the API's rate limit windows in
+// the ledger.
+func (s *Service) HitRateWindow(ctx context.Context, scope, key string, now time.Time, window time.Duration, limit int) (ledger.RateWindow, bool, error) {
+	return s.ledger.HitRateWindow(ctx, scope, key, now, window, limit)
+}
+
+func (s *Service) ResetRateWindow(ctx context.Context, scope, key string) error {
+	return s.ledger.ResetRateWindow(ctx, scope, key)
+}
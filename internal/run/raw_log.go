@@ -0,0 +1,48 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"echohelix/internal/driver"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ErrRawLogUnsupported = errors.New("backend does not capture raw run logs")
+	ErrRawLogNotFound    = errors.New("raw run log not found")
+)
+
+// RawLog fetches the tail of a run's raw CLI output from the adapter that
+// ran it, at most maxBytes of it (zero lets the adapter choose).
+func (s *Service) RawLog(ctx context.Context, runID string, maxBytes int64) (driver.RawLog, error) {
+	r, err := s.GetRun(ctx, runID)
+	if err != nil {
+		return driver.RawLog{}, err
+	}
+	drv, err := s.registry.Get(r.Backend)
+	if err != nil {
+		return driver.RawLog{}, ErrRawLogUnsupported
+	}
+	reader, ok := drv.(driver.RawLogReader)
+	if !ok {
+		return driver.RawLog{}, ErrRawLogUnsupported
+	}
+	out, err := reader.RawLog(ctx, runID, maxBytes)
+	if status.Code(err) == codes.Unimplemented {
+		return driver.RawLog{}, ErrRawLogUnsupported
+	}
+	if err != nil {
+		return driver.RawLog{}, fmt.Errorf("read raw log: %w", err)
+	}
+	if out.Disabled {
+		return driver.RawLog{}, ErrRawLogUnsupported
+	}
+	if !out.Found {
+		return driver.RawLog{}, ErrRawLogNotFound
+	}
+	return out, nil
+}
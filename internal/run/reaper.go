@@ -0,0 +1,72 @@
+package run
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"echohelix/internal/events"
+)
+
+const orphanedRunError = "orphaned: run was queued but never started"
+
+// ReapOrphanedRuns fails queued runs last updated before olderThan ago that
+// this process is not tracking, e.g. rows left behind when the bridge died
+// between CreateRun and executeRun. It returns the number of runs reaped.
+func (s *Service) ReapOrphanedRuns(ctx context.Context, olderThan time.Duration) (int, error) {
+	stale, err := s.ledger.ListStaleRuns(ctx, StatusQueued, time.Now().UTC().Add(-olderThan))
+	if err != nil {
+		return 0, err
+	}
+	reaped := 0
+	for _, rec := range stale {
+		s.mu.Lock()
+		_, active := s.active[rec.ID]
+		_, queued := s.queued[rec.ID]
+		s.mu.Unlock()
+		if active || queued {
+			continue
+		}
+		updated, err := s.setStatusIfNotTerminal(ctx, rec.ID, StatusFailed, orphanedRunError)
+		if err != nil {
+			return reaped, err
+		}
+		if !updated {
+			continue
+		}
+		s.emit(ctx, rec.ID, rec.Backend, "bridge", events.TypeDone, map[string]any{
+			"status":      StatusFailed,
+			"reason_code": "orphaned",
+			"message":     orphanedRunError,
+		})
+		s.notifyIfFailed(rec.ID)
+		s.pipelineRunFinished(rec.ID)
+		reaped++
+	}
+	return reaped, nil
+}
+
+// StartOrphanReaper runs ReapOrphanedRuns every interval until ctx is done.
+func (s *Service) StartOrphanReaper(ctx context.Context, interval, olderThan time.Duration) {
+	if interval <= 0 || olderThan <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if s.isLeader() {
+				if n, err := s.ReapOrphanedRuns(ctx, olderThan); err != nil {
+					log.Printf("reap orphaned runs: %v", err)
+				} else if n > 0 {
+					log.Printf("reaped %d orphaned queued runs", n)
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
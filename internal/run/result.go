@@ -0,0 +1,83 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"echohelix/internal/ledger"
+)
+
+// RunResult is a finished run's final answer with what a client needs to
+// show it, without reading the event stream.
+type RunResult struct {
+	RunID       string          `json:"run_id"`
+	Status      string          `json:"status"`
+	Terminal    TerminalInfo    `json:"terminal"`
+	FinalText   string          `json:"final_text"`
+	Format      string          `json:"format"`
+	Error       string          `json:"error,omitempty"`
+	Usage       *RunUsage       `json:"usage,omitempty"`
+	Attachments []RunAttachment `json:"attachments,omitempty"`
+	ComputedAt  time.Time       `json:"computed_at"`
+}
+
+// GetRunResult returns the cached result of a finished run, assembling and
+// caching it first for runs that ended without one (e.g. cancelled before
+// they started, or finished before results were recorded).
+func (s *Service) GetRunResult(ctx context.Context, runID string) (RunResult, error) {
+	r, err := s.GetRun(ctx, runID)
+	if err != nil {
+		return RunResult{}, err
+	}
+	if !isTerminalStatus(r.Status) {
+		return RunResult{}, ErrRunStillActive
+	}
+	rec, err := s.ledger.GetRunResult(ctx, runID)
+	if errors.Is(err, ledger.ErrRunResultNotFound) || (err == nil && rec.Status != r.Status) {
+		rec, err = s.computeRunResult(ctx, runID, r.Status, r.Error)
+	}
+	if err != nil {
+		return RunResult{}, err
+	}
+	return RunResult{
+		RunID:       r.ID,
+		Status:      rec.Status,
+		Terminal:    deriveTerminalInfo(rec.Status, rec.Error),
+		FinalText:   rec.FinalText,
+		Format:      "markdown",
+		Error:       rec.Error,
+		Usage:       r.Usage,
+		Attachments: r.Attachments,
+		ComputedAt:  rec.ComputedAt,
+	}, nil
+}
+
+// recordRunResult caches the result once executeRun has flushed the run's
+// events.
+func (s *Service) recordRunResult(runID string) {
+	ctx := context.Background()
+	rec, err := s.ledger.GetRun(ctx, runID)
+	if err != nil || !isTerminalStatus(rec.Status) {
+		return
+	}
+	if _, err := s.computeRunResult(ctx, runID, rec.Status, rec.Error); err != nil {
+		log.Printf("warn: record result of run %s: %v", runID, err)
+	}
+}
+
+func (s *Service) computeRunResult(ctx context.Context, runID, status, errText string) (ledger.RunResultRecord, error) {
+	text, err := s.runOutputText(ctx, runID, IncludePartsFinal)
+	if err != nil {
+		return ledger.RunResultRecord{}, err
+	}
+	rec := ledger.RunResultRecord{
+		RunID:      runID,
+		FinalText:  text,
+		Status:     status,
+		Error:      errText,
+		ComputedAt: time.Now().UTC(),
+	}
+	return rec, s.ledger.UpsertRunResult(ctx, rec)
+}
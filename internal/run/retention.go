@@ -0,0 +1,81 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"echohelix/internal/ledger"
+)
+
+func (s *Service) SetLedgerRetention(p ledger.RetentionPolicy) {
+	s.mu.Lock()
+	s.retention = p
+	s.mu.Unlock()
+}
+
+func (s *Service) LedgerRetention() ledger.RetentionPolicy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.retention
+}
+
+// CompactLedger applies p, or the configured policy when p is zero.
+// Workspace retention overrides in the policy config take precedence.
+func (s *Service) CompactLedger(ctx context.Context, p ledger.RetentionPolicy, dryRun bool) (ledger.CompactResult, error) {
+	if !p.Enabled() {
+		p = s.LedgerRetention()
+	}
+	if s.policy.HasRetentionOverrides() {
+		base := p
+		p.ForWorkspace = func(workspaceID, workspacePath string) ledger.RetentionPolicy {
+			return s.workspaceRetention(base, workspaceID, workspacePath)
+		}
+	}
+	if !p.Enabled() {
+		return ledger.CompactResult{}, fmt.Errorf("no ledger retention policy configured")
+	}
+	return s.ledger.Compact(ctx, p, time.Now().UTC(), dryRun)
+}
+
+// workspaceRetention is base with the workspace's overrides applied.
+func (s *Service) workspaceRetention(base ledger.RetentionPolicy, workspaceID, workspacePath string) ledger.RetentionPolicy {
+	rule := s.policy.RetentionFor(workspaceID, workspacePath)
+	if rule.EventRetentionDays > 0 {
+		base.MaxAge = rule.EventRetention()
+	}
+	if rule.MaxEventsPerRun > 0 {
+		base.MaxEventsPerRun = rule.MaxEventsPerRun
+	}
+	if rule.RunRetentionDays > 0 {
+		base.MaxRunAge = rule.RunRetention()
+	}
+	return base
+}
+
+func (s *Service) StartLedgerCompactor(ctx context.Context, interval time.Duration) {
+	if interval <= 0 || (!s.LedgerRetention().Enabled() && !s.policy.HasRetentionOverrides()) {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if !s.isLeader() {
+				continue
+			}
+			res, err := s.CompactLedger(ctx, ledger.RetentionPolicy{}, false)
+			if err != nil {
+				log.Printf("compact ledger: %v", err)
+			} else if res.RemovedEvents > 0 || res.DeletedRuns > 0 {
+				log.Printf("compacted ledger: removed %d events (%d expired runs, %d trimmed runs, %d deleted runs)", res.RemovedEvents, res.ExpiredRuns, res.TrimmedRuns, res.DeletedRuns)
+			}
+		}
+	}()
+}
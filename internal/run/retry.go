@@ -0,0 +1,79 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+var ErrRetryUnavailable = errors.New("run has no recorded inputs to retry")
+
+// RetryOverrides replaces parts of the original run on retry. Changing the
+// backend drops the original model and schema version unless Model is set,
+// since both are backend specific.
+type RetryOverrides struct {
+	Backend string `json:"backend,omitempty"`
+	Model   string `json:"model,omitempty"`
+}
+
+// RetryRun submits a new run with the prompt, context, attachments and
+// options of a finished run, linked to it through ParentRunID. Attachments
+// are materialized again from the file store, so nothing is re-uploaded.
+func (s *Service) RetryRun(ctx context.Context, runID string, overrides RetryOverrides, submittedBy string) (Run, error) {
+	rec, err := s.ledger.GetRun(ctx, runID)
+	if err != nil {
+		return Run{}, err
+	}
+	if !isTerminalStatus(rec.Status) {
+		return Run{}, ErrRunStillActive
+	}
+	if rec.Input == nil {
+		return Run{}, ErrRetryUnavailable
+	}
+	req := SubmitRequest{
+		WorkspaceID:   rec.WorkspaceID,
+		WorkspacePath: rec.Workspace,
+		Backend:       rec.Backend,
+		Prompt:        rec.Input.Prompt,
+		Context:       cloneContext(rec.Input.Context),
+		Options: RunOptions{
+			Model:          rec.Options.Model,
+			Profile:        rec.Options.Profile,
+			Sandbox:        rec.Options.Sandbox,
+			SchemaVersion:  rec.Options.SchemaVersion,
+			Interactive:    rec.Options.Interactive,
+			PTY:            rec.Options.PTY,
+			PTYCols:        rec.Options.PTYCols,
+			PTYRows:        rec.Options.PTYRows,
+			RawOutput:      rec.Options.RawOutput,
+			Checkpoint:     rec.Options.Checkpoint,
+			RequiredTools:  rec.Input.RequiredTools,
+			TimeoutSeconds: rec.Options.TimeoutSeconds,
+		},
+		StrictMentions: rec.Input.StrictMentions,
+		SubmittedBy:    submittedBy,
+		ParentRunID:    rec.ID,
+	}
+	if backend := strings.TrimSpace(overrides.Backend); backend != "" && backend != req.Backend {
+		req.Backend = backend
+		req.Options.Model = ""
+		req.Options.SchemaVersion = ""
+	}
+	if model := strings.TrimSpace(overrides.Model); model != "" {
+		req.Options.Model = model
+	}
+	return s.Submit(ctx, req)
+}
+
+// cloneContext copies the top level of a run context; submit only ever adds
+// keys to it.
+func cloneContext(in map[string]any) map[string]any {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]any, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
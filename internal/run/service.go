@@ -15,8 +15,11 @@ import (
 	"echohelix/internal/events"
 	"echohelix/internal/ledger"
 	"echohelix/internal/policy"
+	"echohelix/internal/tracing"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Service struct {
@@ -30,11 +33,46 @@ type Service struct {
 
 	mu     sync.Mutex
 	active map[string]*activeRun
-
-	dailyTokenQuota map[string]int64
-	fileStoreDir    string
-	maxUploadBytes  int64
-	emergency       EmergencyState
+	// queued holds runs owned by this process from Submit until executeRun
+	// returns, so the orphan reaper can tell them apart from leftovers.
+	queued map[string]struct{}
+
+	dailyTokenQuota  map[string]int64
+	deviceTokenQuota map[string]int64
+	quotaEnforcement string
+	tokenPricing     map[string]TokenPrice
+	fileStoreDir     string
+	maxUploadBytes   int64
+	emergency        EmergencyState
+	retention        ledger.RetentionPolicy
+	persist          *persistPool
+	warehouse        warehouseExports
+	slo              sloTracker
+	digest           digestState
+	failures         failureState
+	pipelines        pipelineState
+	leaderCheck      func() bool
+	stall            StallPolicy
+
+	resequenceDuplicates bool
+	diag                 eventCounters
+
+	includeRunMaxBytes  int
+	includeRunsMaxTotal int
+
+	maxOutputBytes    int64
+	outputLimitAction string
+
+	idempotencyWindow time.Duration
+
+	// staticModels is the operator's model list per backend
+	// (BACKEND_MODELS_FILE); it takes precedence over the adapter's.
+	staticModels map[string][]driver.ModelInfo
+
+	// externalBackends are the backends attached with RegisterBackend;
+	// monitorCtx is the context StartAdapterMonitors runs under.
+	externalBackends map[string]*externalBackend
+	monitorCtx       context.Context
 }
 
 type activeRun struct {
@@ -44,6 +82,13 @@ type activeRun struct {
 	status        string
 	schemaVersion string
 	backend       string
+	// deadline is when timer fails the run; extended is the total that
+	// ExtendRun has added to it.
+	deadline time.Time
+	timer    *time.Timer
+	extended time.Duration
+	// remaining is what was left of the timeout when the run was paused.
+	remaining time.Duration
 }
 
 var ErrEmergencyStopActive = errors.New("bridge emergency stop is active")
@@ -69,16 +114,51 @@ func NewService(
 		maxConcurrent:   maxConcurrent,
 		slots:           make(chan struct{}, maxConcurrent),
 		active:          map[string]*activeRun{},
+		queued:          map[string]struct{}{},
 		dailyTokenQuota: map[string]int64{},
-		fileStoreDir:    defaultFileStoreDir,
-		maxUploadBytes:  20 * 1024 * 1024,
+
+		deviceTokenQuota: map[string]int64{},
+		quotaEnforcement: QuotaEnforcementOff,
+		fileStoreDir:     defaultFileStoreDir,
+		maxUploadBytes:   20 * 1024 * 1024,
+		pipelines:        pipelineState{byRun: map[string]string{}},
+
+		resequenceDuplicates: true,
+		includeRunMaxBytes:   16 * 1024,
+		includeRunsMaxTotal:  48 * 1024,
+		idempotencyWindow:    ledger.DefaultIdempotencyWindow,
 	}
 }
 
+// SetLeaderCheck makes singleton background jobs (ledger compaction, orphan
+// reaping) run only while leader reports true. Nil means always run.
+func (s *Service) SetLeaderCheck(leader func() bool) {
+	s.mu.Lock()
+	s.leaderCheck = leader
+	s.mu.Unlock()
+}
+
+func (s *Service) isLeader() bool {
+	s.mu.Lock()
+	leader := s.leaderCheck
+	s.mu.Unlock()
+	return leader == nil || leader()
+}
+
 func (s *Service) Submit(ctx context.Context, req SubmitRequest) (Run, error) {
 	if req.Backend == "" {
 		req.Backend = "codex"
 	}
+	ctx, span := tracing.Start(ctx, "run.submit", trace.WithAttributes(attribute.String("run.backend", req.Backend)))
+	r, err := s.submitIdempotent(ctx, req)
+	if err == nil {
+		span.SetAttributes(attribute.String("run.id", r.ID))
+	}
+	tracing.End(span, err)
+	return r, err
+}
+
+func (s *Service) submit(ctx context.Context, req SubmitRequest) (Run, error) {
 	if s.isEmergencyActive() {
 		return Run{}, ErrEmergencyStopActive
 	}
@@ -96,6 +176,16 @@ func (s *Service) Submit(ctx context.Context, req SubmitRequest) (Run, error) {
 	}); err != nil {
 		return Run{}, err
 	}
+	enforced, err := s.policy.EnforceWorkspaceRule(ctx, policy.WorkspaceRequest{
+		WorkspaceID:   req.WorkspaceID,
+		WorkspacePath: req.WorkspacePath,
+		Backend:       req.Backend,
+		Sandbox:       req.Options.Sandbox,
+	})
+	if err != nil {
+		return Run{}, err
+	}
+	req.Options.Sandbox = enforced.Sandbox
 	drv, err := s.registry.Get(req.Backend)
 	if err != nil {
 		return Run{}, err
@@ -104,18 +194,58 @@ func (s *Service) Submit(ctx context.Context, req SubmitRequest) (Run, error) {
 	if err != nil {
 		return Run{}, fmt.Errorf("resolve backend capabilities: %w", err)
 	}
+	if req.Options.Interactive && !caps.SupportsInput {
+		return Run{}, ErrInputUnsupported
+	}
+	if req.Options.PTY && !caps.SupportsPTY {
+		return Run{}, fmt.Errorf("backend %s does not support pty", req.Backend)
+	}
+	if req.Options.PTYCols < 0 || req.Options.PTYRows < 0 {
+		return Run{}, fmt.Errorf("pty_cols and pty_rows must not be negative")
+	}
+	if err := s.policy.ValidateRunTimeout(time.Duration(req.Options.TimeoutSeconds) * time.Second); err != nil {
+		return Run{}, err
+	}
+	if err := s.policy.ValidateRequiredTools(req.Backend, req.Options.RequiredTools, caps.Tools); err != nil {
+		return Run{}, err
+	}
+	if err := validateBackendOptions(req.Backend, req.Options, caps); err != nil {
+		return Run{}, err
+	}
+	if req.Options.Checkpoint {
+		if err := checkpointAvailable(ctx, req.WorkspacePath); err != nil {
+			return Run{}, err
+		}
+	}
 	negotiated, err := negotiateSchemaVersion(req.Backend, req.Options.SchemaVersion, caps)
 	if err != nil {
 		return Run{}, err
 	}
 	req.Options.SchemaVersion = negotiated
+	quotaWarning, err := s.enforceQuota(ctx, req.Backend, req.SubmittedBy)
+	if err != nil {
+		return Run{}, err
+	}
+	priorRuns, err := s.resolveIncludedRuns(ctx, req.WorkspaceID, req.Context)
+	if err != nil {
+		return Run{}, err
+	}
 	runID := uuid.NewString()
-	rewrittenPrompt, rewrittenContext, attachments, err := s.prepareAttachments(ctx, runID, req.WorkspacePath, req.Prompt, req.Context)
+	// Keep the inputs as submitted; attachment handling rewrites both.
+	input := &ledger.RunInputRecord{
+		Prompt:         req.Prompt,
+		Context:        cloneContext(req.Context),
+		StrictMentions: req.StrictMentions,
+		RequiredTools:  req.Options.RequiredTools,
+	}
+	rewrittenPrompt, rewrittenContext, attachments, unresolved, err := s.prepareAttachments(ctx, runID, req.WorkspacePath, req.Prompt, req.Context, req.StrictMentions)
 	if err != nil {
 		return Run{}, err
 	}
-	req.Prompt = rewrittenPrompt
-	req.Context = rewrittenContext
+	req.Prompt, req.Context = applyIncludedRuns(rewrittenPrompt, rewrittenContext, priorRuns)
+	// Operator preamble wraps everything else so the persisted prompt shows
+	// exactly what was injected.
+	req.Prompt = s.policy.PromptInjectionFor(req.WorkspaceID, req.WorkspacePath).Wrap(req.Prompt)
 
 	now := time.Now().UTC()
 	r := Run{
@@ -129,8 +259,13 @@ func (s *Service) Submit(ctx context.Context, req SubmitRequest) (Run, error) {
 		Attachments: attachments,
 		Status:      StatusQueued,
 		Terminal:    deriveTerminalInfo(StatusQueued, ""),
+		SubmittedBy: req.SubmittedBy,
+		ParentRunID: req.ParentRunID,
 		CreatedAt:   now,
 		UpdatedAt:   now,
+
+		QuotaWarning:       quotaWarning,
+		UnresolvedMentions: unresolved,
 	}
 	if err := s.ledger.CreateRun(ctx, ledger.RunRecord{
 		ID:          r.ID,
@@ -140,33 +275,69 @@ func (s *Service) Submit(ctx context.Context, req SubmitRequest) (Run, error) {
 		Prompt:      r.Prompt,
 		Context:     r.Context,
 		Options: ledger.RunOptionsRecord{
-			Model:         r.Options.Model,
-			Profile:       r.Options.Profile,
-			Sandbox:       r.Options.Sandbox,
-			SchemaVersion: r.Options.SchemaVersion,
+			Model:          r.Options.Model,
+			Profile:        r.Options.Profile,
+			Sandbox:        r.Options.Sandbox,
+			SchemaVersion:  r.Options.SchemaVersion,
+			Interactive:    r.Options.Interactive,
+			PTY:            r.Options.PTY,
+			PTYCols:        r.Options.PTYCols,
+			PTYRows:        r.Options.PTYRows,
+			RawOutput:      r.Options.RawOutput,
+			Checkpoint:     r.Options.Checkpoint,
+			TimeoutSeconds: r.Options.TimeoutSeconds,
 		},
-		Status:    r.Status,
-		CreatedAt: r.CreatedAt,
-		UpdatedAt: r.UpdatedAt,
+		Status:      r.Status,
+		SubmittedBy: r.SubmittedBy,
+		ParentRunID: r.ParentRunID,
+		Input:       input,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
 	}); err != nil {
 		return Run{}, err
 	}
 
-	go s.executeRun(r, drv)
+	s.mu.Lock()
+	s.queued[r.ID] = struct{}{}
+	s.mu.Unlock()
+	go s.executeRun(tracing.Detach(ctx), r, drv)
 	return r, nil
 }
 
-func (s *Service) executeRun(r Run, drv driver.Driver) {
+// executeRun runs r to a terminal status. parent only carries the submit
+// span, so the run.execute span joins the submitting request's trace.
+func (s *Service) executeRun(parent context.Context, r Run, drv driver.Driver) {
+	traceCtx, span := tracing.Start(parent, "run.execute", trace.WithAttributes(
+		attribute.String("run.id", r.ID),
+		attribute.String("run.backend", r.Backend),
+	))
+	defer s.endRunSpan(span, r.ID)
+	defer s.pipelineRunFinished(r.ID)
+	defer func() {
+		s.mu.Lock()
+		delete(s.queued, r.ID)
+		s.mu.Unlock()
+	}()
 	s.slots <- struct{}{}
 	defer func() { <-s.slots }()
+	span.AddEvent("slot acquired")
 
 	// Run may be cancelled before worker gets a slot.
 	if rec, err := s.ledger.GetRun(context.Background(), r.ID); err == nil && isTerminalStatus(rec.Status) {
 		return
 	}
+	defer s.notifyIfFailed(r.ID)
+	defer s.recordRunResult(r.ID)
 
-	runCtx, cancel := context.WithTimeout(context.Background(), s.runTimeout)
+	// The run timeout is a timer rather than a context deadline so
+	// ExtendRun can move it; it cancels with DeadlineExceeded as the cause.
+	runCtx, cancelCause := context.WithCancelCause(traceCtx)
+	cancel := func() { cancelCause(context.Canceled) }
 	defer cancel()
+	timeout := s.timeoutFor(r)
+	deadline := time.Now().Add(timeout)
+	timer := time.AfterFunc(timeout, func() { cancelCause(context.DeadlineExceeded) })
+	defer timer.Stop()
 
 	s.mu.Lock()
 	s.active[r.ID] = &activeRun{
@@ -176,6 +347,8 @@ func (s *Service) executeRun(r Run, drv driver.Driver) {
 		status:        StatusQueued,
 		schemaVersion: r.Options.SchemaVersion,
 		backend:       r.Backend,
+		deadline:      deadline,
+		timer:         timer,
 	}
 	s.mu.Unlock()
 	defer func() {
@@ -183,10 +356,24 @@ func (s *Service) executeRun(r Run, drv driver.Driver) {
 		delete(s.active, r.ID)
 		s.mu.Unlock()
 	}()
+	defer s.openPersistQueue(r.ID)()
 
 	s.setStatus(runCtx, r.ID, StatusRunning, "")
 	s.emit(runCtx, r.ID, r.Backend, "bridge", events.TypeStatus, map[string]any{"status": StatusRunning})
 
+	if r.Options.Checkpoint {
+		// Without its checkpoint the run would have no undo, so it does not
+		// start.
+		if _, err := s.createCheckpoint(runCtx, r.ID, r.Workspace); err != nil {
+			msg := "create checkpoint: " + err.Error()
+			s.setStatus(runCtx, r.ID, StatusFailed, msg)
+			s.emit(runCtx, r.ID, r.Backend, "bridge", events.TypeError, map[string]any{"message": msg})
+			return
+		}
+	}
+
+	probe := s.startFirstEventProbe(r.ID, r.Backend)
+	defer probe.finish()
 	stream, err := drv.StartRun(runCtx, driver.StartRequest{
 		RunID:         r.ID,
 		WorkspaceID:   r.WorkspaceID,
@@ -198,7 +385,14 @@ func (s *Service) executeRun(r Run, drv driver.Driver) {
 			Profile:       r.Options.Profile,
 			Sandbox:       r.Options.Sandbox,
 			SchemaVersion: r.Options.SchemaVersion,
+			Interactive:   r.Options.Interactive,
+			PTY:           r.Options.PTY,
+			PTYCols:       r.Options.PTYCols,
+			PTYRows:       r.Options.PTYRows,
+			RawOutput:     r.Options.RawOutput,
 		},
+		EnvProfile: s.ResolveEnvProfile(runCtx, r.WorkspaceID),
+		Deadline:   deadline,
 	})
 	if err != nil {
 		s.setStatus(runCtx, r.ID, StatusFailed, err.Error())
@@ -209,17 +403,34 @@ func (s *Service) executeRun(r Run, drv driver.Driver) {
 	s.setStatus(runCtx, r.ID, StatusStreaming, "")
 	s.emit(runCtx, r.ID, r.Backend, "bridge", events.TypeStatus, map[string]any{"status": StatusStreaming})
 
+	budget := s.newOutputBudget()
+	limitReached := func() {
+		s.emit(runCtx, r.ID, r.Backend, "bridge", events.TypeStatus, budget.notice())
+		if budget.cancel {
+			cancelCause(ErrOutputLimitExceeded)
+		}
+	}
+	stall := s.newStallWatch(r)
+	defer stall.stop()
 	sawDone := false
 	sawError := false
 	doneReceived := false
 	var doneErr error
 	for {
 		if doneReceived && stream.Events == nil {
+			if cause := context.Cause(runCtx); cause != nil && errors.Is(doneErr, driver.ErrRunCancelled) {
+				// Report the bridge's own reason, as when runCtx ends first.
+				doneErr = cause
+			}
 			if doneErr != nil {
 				st := s.currentStatus(r.ID)
 				if st != StatusCancelled && st != StatusCancelling {
+					payload := map[string]any{"message": doneErr.Error()}
+					if errors.Is(doneErr, driver.ErrAdapterDisconnected) {
+						payload["code"] = "adapter_disconnected"
+					}
 					s.setStatus(runCtx, r.ID, StatusFailed, doneErr.Error())
-					s.emit(runCtx, r.ID, r.Backend, "bridge", events.TypeError, map[string]any{"message": doneErr.Error()})
+					s.emit(runCtx, r.ID, r.Backend, "bridge", events.TypeError, payload)
 				}
 				return
 			}
@@ -242,18 +453,36 @@ func (s *Service) executeRun(r Run, drv driver.Driver) {
 
 		select {
 		case <-runCtx.Done():
-			errText := runCtx.Err().Error()
+			errText := context.Cause(runCtx).Error()
 			st := s.currentStatus(r.ID)
 			if st != StatusCancelled && st != StatusCancelling {
 				s.setStatus(context.Background(), r.ID, StatusFailed, errText)
 				s.emit(context.Background(), r.ID, r.Backend, "bridge", events.TypeError, map[string]any{"message": errText})
 			}
 			return
+		case <-stall.C():
+			if s.stallFired(runCtx, stall, r, drv) {
+				cancelCause(ErrRunStalled)
+			}
 		case ev, ok := <-stream.Events:
 			if !ok {
 				stream.Events = nil
 				continue
 			}
+			probe.observe()
+			if stall.observe() {
+				s.emit(runCtx, r.ID, r.Backend, "bridge", events.TypeStatus, map[string]any{"status": s.currentStatus(r.ID), "reason": "stall_recovered"})
+			}
+			keep, crossed := true, false
+			if budget != nil {
+				keep, crossed = budget.admit(&ev)
+			}
+			if !keep {
+				if crossed {
+					limitReached()
+				}
+				continue
+			}
 			ev.RunID = r.ID
 			ev.Backend = r.Backend
 			if ev.TS.IsZero() {
@@ -282,11 +511,13 @@ func (s *Service) executeRun(r Run, drv driver.Driver) {
 						s.setStatus(runCtx, r.ID, status, errText)
 					}
 				}
-				s.recordTokenUsage(runCtx, r.ID, r.Backend, ev.Payload)
+				s.recordTokenUsage(runCtx, r, ev.Payload)
 			}
 
-			_ = s.ledger.AppendEvent(runCtx, ev)
-			s.hub.Publish(ev)
+			s.appendAndPublish(runCtx, ev)
+			if crossed {
+				limitReached()
+			}
 		case dErr, ok := <-stream.Done:
 			if !ok {
 				doneReceived = true
@@ -348,10 +579,12 @@ func (s *Service) Cancel(ctx context.Context, runID string) error {
 		return s.cancelTerminalConflict(runID)
 	}
 	s.emit(storageCtx, runID, rec.Backend, "bridge", events.TypeStatus, map[string]any{"status": StatusCancelling})
+	// Cancelling the run context first tears down the event stream without
+	// waiting on the adapter's CancelRun.
+	ar.cancel()
 	if err := ar.driver.Cancel(ctx, runID); err != nil {
 		log.Printf("cancel driver run=%s: %v", runID, err)
 	}
-	ar.cancel()
 
 	updated, err = s.setStatusIfNotTerminal(storageCtx, runID, StatusCancelled, "")
 	if err != nil {
@@ -377,16 +610,40 @@ func (s *Service) GetRun(ctx context.Context, runID string) (Run, error) {
 		Prompt:      rec.Prompt,
 		Context:     rec.Context,
 		Options: RunOptions{
-			Model:         rec.Options.Model,
-			Profile:       rec.Options.Profile,
-			Sandbox:       rec.Options.Sandbox,
-			SchemaVersion: rec.Options.SchemaVersion,
+			Model:          rec.Options.Model,
+			Profile:        rec.Options.Profile,
+			Sandbox:        rec.Options.Sandbox,
+			SchemaVersion:  rec.Options.SchemaVersion,
+			Interactive:    rec.Options.Interactive,
+			PTY:            rec.Options.PTY,
+			PTYCols:        rec.Options.PTYCols,
+			PTYRows:        rec.Options.PTYRows,
+			RawOutput:      rec.Options.RawOutput,
+			Checkpoint:     rec.Options.Checkpoint,
+			TimeoutSeconds: rec.Options.TimeoutSeconds,
 		},
-		Status:    rec.Status,
-		Error:     rec.Error,
-		Terminal:  deriveTerminalInfo(rec.Status, rec.Error),
-		CreatedAt: rec.CreatedAt,
-		UpdatedAt: rec.UpdatedAt,
+		Status:      rec.Status,
+		Error:       rec.Error,
+		Terminal:    deriveTerminalInfo(rec.Status, rec.Error),
+		SubmittedBy: rec.SubmittedBy,
+		ParentRunID: rec.ParentRunID,
+		CreatedAt:   rec.CreatedAt,
+		UpdatedAt:   rec.UpdatedAt,
+	}
+	if usage, ok, err := s.ledger.GetTokenUsage(ctx, runID); err == nil && ok {
+		out.Usage = &RunUsage{
+			InputTokens:  usage.InputTokens,
+			OutputTokens: usage.OutputTokens,
+			TotalTokens:  usage.TotalTokens,
+		}
+		if price, ok := s.priceFor(rec.Backend, rec.Options.Model); ok {
+			cost := roundUSD(price.cost(usage.InputTokens, usage.OutputTokens))
+			out.Usage.CostUSD = &cost
+		}
+	}
+	if cp, err := s.ledger.GetRunCheckpoint(ctx, runID); err == nil {
+		checkpoint := toRunCheckpoint(cp)
+		out.Checkpoint = &checkpoint
 	}
 	atts, err := s.ledger.ListRunAttachments(ctx, runID)
 	if err == nil && len(atts) > 0 {
@@ -399,6 +656,7 @@ func (s *Service) GetRun(ctx context.Context, runID string) (Run, error) {
 			})
 		}
 	}
+	out.Deadline, out.RemainingSeconds = s.runDeadline(runID)
 	return out, nil
 }
 
@@ -414,7 +672,7 @@ func negotiateSchemaVersion(backend string, requested string, caps driver.Capabi
 		}
 	}
 	switch selected {
-	case events.SchemaVersionV1, events.SchemaVersionV2:
+	case events.SchemaVersionV1, events.SchemaVersionV2, events.SchemaVersionV3:
 	default:
 		return "", fmt.Errorf("invalid schema_version %q", selected)
 	}
@@ -429,6 +687,45 @@ func negotiateSchemaVersion(backend string, requested string, caps driver.Capabi
 	return "", fmt.Errorf("schema_version %q is not supported by backend %q", selected, backend)
 }
 
+// RunQuery filters ListRuns. Limit defaults to 20 and is capped at 100.
+type RunQuery struct {
+	Status      string
+	WorkspaceID string
+	Limit       int
+}
+
+// ListRuns returns the newest runs as summaries; GetRun has the details.
+func (s *Service) ListRuns(ctx context.Context, q RunQuery) ([]Run, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	recs, err := s.ledger.ListRuns(ctx, ledger.RunListQuery{
+		Status:      strings.TrimSpace(q.Status),
+		WorkspaceID: strings.TrimSpace(q.WorkspaceID),
+		Limit:       min(limit, 100),
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Run, 0, len(recs))
+	for _, rec := range recs {
+		out = append(out, Run{
+			ID:          rec.ID,
+			WorkspaceID: rec.WorkspaceID,
+			Workspace:   rec.Workspace,
+			Backend:     rec.Backend,
+			Prompt:      rec.Prompt,
+			Status:      rec.Status,
+			Error:       rec.Error,
+			Terminal:    deriveTerminalInfo(rec.Status, rec.Error),
+			CreatedAt:   rec.CreatedAt,
+			UpdatedAt:   rec.UpdatedAt,
+		})
+	}
+	return out, nil
+}
+
 func (s *Service) ListEvents(ctx context.Context, runID string, fromSeq int64) ([]events.Event, error) {
 	return s.ledger.ListEvents(ctx, runID, fromSeq, 2000)
 }
@@ -450,6 +747,16 @@ func (s *Service) ListBackends(ctx context.Context) ([]map[string]any, error) {
 		if hErr != nil {
 			entry["health"] = map[string]any{"ok": false, "message": hErr.Error()}
 		}
+		if sup := adapterSupervisor(d); sup != nil {
+			entry["adapter"] = sup.Status()
+		}
+		if ext, ok := s.externalBackend(d.Name()); ok {
+			entry["external"] = map[string]any{
+				"grpc_addr":     ext.cfg.GRPCAddr,
+				"binary_path":   ext.cfg.BinaryPath,
+				"registered_at": ext.registeredAt,
+			}
+		}
 		if cErr == nil {
 			entry["capabilities"] = caps
 		} else {
@@ -547,14 +854,14 @@ func (s *Service) emit(ctx context.Context, runID, backend, source, typ string,
 	}
 	events.NormalizeEvent(&ev)
 	if err := events.ValidateEvent(ev); err != nil {
-		ev.Type = events.TypeError
+		fallback := events.NewEvent(events.ErrorPayload{Message: "invalid event contract in bridge emit", Detail: err.Error()})
+		ev.Type = fallback.Type
 		ev.Channel = events.ChannelSystem
 		ev.Format = events.FormatPlain
 		ev.Role = events.RoleSystem
-		ev.Payload = map[string]any{"message": "invalid event contract in bridge emit", "detail": err.Error()}
+		ev.Payload = fallback.Payload
 	}
-	_ = s.ledger.AppendEvent(ctx, ev)
-	s.hub.Publish(ev)
+	s.appendAndPublish(ctx, ev)
 }
 
 func (s *Service) runSchemaVersion(ctx context.Context, runID string) string {
@@ -4,11 +4,13 @@ import (
 	"context"
 	"errors"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"echohelix/internal/driver"
+	"echohelix/internal/envprofile"
 	"echohelix/internal/events"
 	"echohelix/internal/ledger"
 	"echohelix/internal/policy"
@@ -24,6 +26,8 @@ type fakeDriver struct {
 	lastStart       driver.StartRequest
 	schemaVersions  []string
 	preferredSchema string
+	tools           []string
+	models          []string
 }
 
 func newFakeDriver(name string, block bool) *fakeDriver {
@@ -78,7 +82,7 @@ func (d *fakeDriver) StartRun(ctx context.Context, req driver.StartRequest) (*dr
 		if block {
 			select {
 			case <-ctx.Done():
-				doneCh <- ctx.Err()
+				doneCh <- driver.StreamError(ctx, ctx.Err())
 				return
 			case <-stop:
 				doneCh <- nil
@@ -123,6 +127,8 @@ func (d *fakeDriver) Capabilities(context.Context) (driver.CapabilitySet, error)
 		SupportsCancel:         true,
 		SchemaVersions:         d.schemaVersions,
 		PreferredSchemaVersion: d.preferredSchema,
+		Tools:                  d.tools,
+		Models:                 d.models,
 	}, nil
 }
 
@@ -338,6 +344,66 @@ func TestSubmitRejectsInvalidOptions(t *testing.T) {
 	}
 }
 
+func TestSubmitRequiresDeclaredTools(t *testing.T) {
+	drv := newFakeDriver("codex", false)
+	drv.tools = []string{"shell", "editor"}
+	svc := setupService(t, drv)
+	submit := func(tools ...string) error {
+		_, err := svc.Submit(context.Background(), SubmitRequest{
+			WorkspaceID:   "ws-1",
+			WorkspacePath: "/tmp",
+			Backend:       "codex",
+			Prompt:        "needs tools",
+			Options:       RunOptions{RequiredTools: tools},
+		})
+		return err
+	}
+
+	if err := submit("shell", "editor"); err != nil {
+		t.Fatalf("declared tools should be accepted: %v", err)
+	}
+	err := submit("shell", "web")
+	if err == nil || !strings.Contains(err.Error(), "lacks required tools: web") {
+		t.Fatalf("expected missing web tool error, got %v", err)
+	}
+	if err := submit("browser"); err == nil || !strings.Contains(err.Error(), "invalid required tool") {
+		t.Fatalf("expected unknown tool error, got %v", err)
+	}
+
+	drv.tools = nil
+	if err := submit("shell"); err == nil || !strings.Contains(err.Error(), "does not declare") {
+		t.Fatalf("expected undeclared inventory error, got %v", err)
+	}
+}
+
+func TestSubmitRejectsModelNotAdvertisedByBackend(t *testing.T) {
+	drv := newFakeDriver("codex", false)
+	drv.models = []string{"gpt-5", "gpt-5-mini"}
+	svc := setupService(t, drv)
+	submit := func(model string) error {
+		_, err := svc.Submit(context.Background(), SubmitRequest{
+			WorkspaceID:   "ws-1",
+			WorkspacePath: "/tmp",
+			Backend:       "codex",
+			Prompt:        "pick a model",
+			Options:       RunOptions{Model: model},
+		})
+		return err
+	}
+
+	if err := submit("gpt-5-mini"); err != nil {
+		t.Fatalf("advertised model should be accepted: %v", err)
+	}
+	var optionErr *UnsupportedOptionError
+	if err := submit("o3"); !errors.As(err, &optionErr) || optionErr.Option != "model" || optionErr.Value != "o3" {
+		t.Fatalf("expected unsupported model error, got %v", err)
+	}
+	drv.models = nil
+	if err := submit("o3"); err != nil {
+		t.Fatalf("backend without a model list should accept any model: %v", err)
+	}
+}
+
 func TestSchemaNegotiationRequestedV1(t *testing.T) {
 	drv := newFakeDriver("codex", false)
 	drv.schemaVersions = []string{events.SchemaVersionV1, events.SchemaVersionV2}
@@ -655,3 +721,1401 @@ func TestEmergencyStopAndResume(t *testing.T) {
 	}
 	waitStatus(t, svc, r2.ID, StatusCompleted)
 }
+
+func TestWorkspaceEnvProfileAppliedToDriver(t *testing.T) {
+	drv := newFakeDriver("codex", false)
+	svc := setupService(t, drv)
+
+	if _, err := svc.PutEnvProfile(context.Background(), "ws-env", envprofile.Profile{
+		Env:         map[string]string{"NODE_ENV": "development"},
+		PathPrepend: []string{"/opt/node/bin"},
+		ShellInit:   "source ~/.nvm/nvm.sh && nvm use 20",
+	}, "test"); err != nil {
+		t.Fatalf("put env profile: %v", err)
+	}
+	if _, err := svc.PutEnvProfile(context.Background(), "ws-env", envprofile.Profile{
+		Env: map[string]string{"PATH": "/tmp"},
+	}, "test"); err == nil {
+		t.Fatalf("expected PATH override to be rejected")
+	}
+
+	r, err := svc.Submit(context.Background(), SubmitRequest{
+		WorkspaceID:   "ws-env",
+		WorkspacePath: "/tmp",
+		Backend:       "codex",
+		Prompt:        "which node",
+	})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, r.ID, StatusCompleted)
+
+	drv.cancelMu.Lock()
+	got := drv.lastStart.EnvProfile
+	drv.cancelMu.Unlock()
+	if got.Env["NODE_ENV"] != "development" || len(got.PathPrepend) != 1 || got.ShellInit == "" {
+		t.Fatalf("expected env profile forwarded to driver, got %#v", got)
+	}
+
+	if err := svc.DeleteEnvProfile(context.Background(), "ws-env"); err != nil {
+		t.Fatalf("delete env profile: %v", err)
+	}
+	if _, err := svc.GetEnvProfile(context.Background(), "ws-env"); !errors.Is(err, ErrEnvProfileNotFound) {
+		t.Fatalf("expected ErrEnvProfileNotFound after delete, got %v", err)
+	}
+}
+
+func TestPromptInjectionPersistedWithRun(t *testing.T) {
+	drv := newFakeDriver("codex", false)
+	svc := setupService(t, drv)
+	svc.policy.SetPromptInjections(map[string]policy.PromptInjection{
+		"ws-rules": {Prefix: "Follow the style guide.", Suffix: "Never push to main."},
+	})
+
+	r, err := svc.Submit(context.Background(), SubmitRequest{
+		WorkspaceID:   "ws-rules",
+		WorkspacePath: "/tmp",
+		Backend:       "codex",
+		Prompt:        "fix the build",
+	})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	final := waitStatus(t, svc, r.ID, StatusCompleted)
+	want := "<policy-injection position=\"prefix\" source=\"ws-rules\">\nFollow the style guide.\n</policy-injection>\n\n" +
+		"fix the build\n\n" +
+		"<policy-injection position=\"suffix\" source=\"ws-rules\">\nNever push to main.\n</policy-injection>"
+	if final.Prompt != want {
+		t.Fatalf("unexpected persisted prompt:\n%s", final.Prompt)
+	}
+	drv.cancelMu.Lock()
+	sent := drv.lastStart.Prompt
+	drv.cancelMu.Unlock()
+	if sent != want {
+		t.Fatalf("expected driver to receive injected prompt, got:\n%s", sent)
+	}
+}
+
+func TestRunAnalyticsGroupsOutcomes(t *testing.T) {
+	okDrv := newFakeDriver("codex", false)
+	okDrv.script = []events.Event{
+		{
+			Type: events.TypeDone,
+			Payload: map[string]any{
+				"status": "completed",
+				"usage":  map[string]any{"input_tokens": 10, "output_tokens": 4},
+			},
+			Source: "fake",
+		},
+	}
+	failDrv := newFakeDriver("gemini", false)
+	failDrv.script = []events.Event{
+		{Type: events.TypeDone, Payload: map[string]any{"status": "failed", "message": "boom"}, Source: "fake"},
+	}
+	svc := setupServiceWithDrivers(t, okDrv, failDrv)
+
+	for _, backend := range []string{"codex", "codex", "gemini"} {
+		r, err := svc.Submit(context.Background(), SubmitRequest{
+			WorkspaceID:   "ws-" + backend,
+			WorkspacePath: "/tmp",
+			Backend:       backend,
+			Prompt:        "analytics",
+		})
+		if err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+		waitStatus(t, svc, r.ID, StatusCompleted, StatusFailed)
+	}
+
+	now := time.Now().UTC()
+	summary, err := svc.RunAnalytics(context.Background(), now.Add(-time.Hour), now.Add(time.Minute), "backend")
+	if err != nil {
+		t.Fatalf("run analytics: %v", err)
+	}
+	if summary.Totals.RunCount != 3 || len(summary.Groups) != 2 {
+		t.Fatalf("unexpected analytics summary: %#v", summary)
+	}
+	codex := summary.Groups[0]
+	if codex.Key != "codex" || codex.Completed != 2 || codex.SuccessRate != 1 || codex.AvgTotalTokens != 14 {
+		t.Fatalf("unexpected codex group: %#v", codex)
+	}
+	gemini := summary.Groups[1]
+	if gemini.Key != "gemini" || gemini.Failed != 1 || gemini.FailureRate != 1 {
+		t.Fatalf("unexpected gemini group: %#v", gemini)
+	}
+
+	if _, err := svc.RunAnalytics(context.Background(), now.Add(-time.Hour), now, "hour"); err == nil {
+		t.Fatalf("expected invalid group_by to be rejected")
+	}
+}
+
+func TestFirstEventSLOAlertsAndAnalytics(t *testing.T) {
+	fast := newFakeDriver("codex", false)
+	silent := newFakeDriver("gemini", true)
+	svc := setupServiceWithDrivers(t, fast, silent)
+	svc.SetSLO(SLOConfig{
+		FirstEvent:      map[string]time.Duration{SLODefaultKey: time.Hour, "gemini": 50 * time.Millisecond},
+		AlertBreachRate: 0.5,
+		AlertMinSamples: 1,
+	})
+	alerts := make(chan SLOAlert, 4)
+	svc.SetAlertSink(func(_ context.Context, a SLOAlert) { alerts <- a })
+
+	ok, err := svc.Submit(context.Background(), SubmitRequest{WorkspacePath: "/tmp", Backend: "codex", Prompt: "fast"})
+	if err != nil {
+		t.Fatalf("submit fast: %v", err)
+	}
+	waitStatus(t, svc, ok.ID, StatusCompleted)
+
+	slow, err := svc.Submit(context.Background(), SubmitRequest{WorkspacePath: "/tmp", Backend: "gemini", Prompt: "silent"})
+	if err != nil {
+		t.Fatalf("submit silent: %v", err)
+	}
+	select {
+	case a := <-alerts:
+		if a.Backend != "gemini" || a.Breaches != 1 || a.Samples != 1 || a.ThresholdMS != 50 {
+			t.Fatalf("unexpected alert: %#v", a)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("expected slo alert for silent backend")
+	}
+	if err := svc.Cancel(context.Background(), slow.ID); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	waitStatus(t, svc, slow.ID, StatusCancelled)
+
+	now := time.Now().UTC()
+	var summary RunAnalyticsSummary
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		summary, err = svc.RunAnalytics(context.Background(), now.Add(-time.Hour), now.Add(time.Minute), "backend")
+		if err != nil {
+			t.Fatalf("run analytics: %v", err)
+		}
+		if len(summary.Groups) == 2 && summary.Groups[1].SLOSamples == 1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	codex, gemini := summary.Groups[0], summary.Groups[1]
+	if codex.SLOSamples != 1 || codex.SLOBreaches != 0 || codex.SLOCompliance != 1 {
+		t.Fatalf("unexpected codex slo stats: %#v", codex)
+	}
+	if gemini.SLOSamples != 1 || gemini.SLOBreaches != 1 || gemini.SLOCompliance != 0 || gemini.FirstEventP50MS < 50 {
+		t.Fatalf("unexpected gemini slo stats: %#v", gemini)
+	}
+	if summary.Totals.SLOSamples != 2 || summary.Totals.SLOCompliance != 0.5 {
+		t.Fatalf("unexpected slo totals: %#v", summary.Totals)
+	}
+}
+
+func TestDuplicateSeqIsResequenced(t *testing.T) {
+	svc := setupService(t, newFakeDriver("codex", true))
+
+	r, err := svc.Submit(context.Background(), SubmitRequest{
+		WorkspaceID:   "ws-1",
+		WorkspacePath: "/tmp",
+		Backend:       "codex",
+		Prompt:        "hello",
+	})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, r.ID, StatusStreaming)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		evs, _ := svc.ListEvents(context.Background(), r.ID, 0)
+		if len(evs) >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for bridge events")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// Occupy the seqs the bridge will assign next.
+	for seq := int64(3); seq <= 4; seq++ {
+		if err := svc.ledger.AppendEvent(context.Background(), events.Event{
+			RunID:         r.ID,
+			Seq:           seq,
+			TS:            time.Now().UTC(),
+			SchemaVersion: events.SchemaVersionV2,
+			Type:          events.TypeToken,
+			Channel:       events.ChannelWorking,
+			Format:        events.FormatPlain,
+			Role:          events.RoleAssistant,
+			Payload:       map[string]any{"text": "replayed"},
+			Backend:       "codex",
+			Source:        "fake",
+		}); err != nil {
+			t.Fatalf("seed event seq=%d: %v", seq, err)
+		}
+	}
+	if err := svc.Cancel(context.Background(), r.ID); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	waitStatus(t, svc, r.ID, StatusCancelled)
+
+	evs, err := svc.ListEvents(context.Background(), r.ID, 0)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	var statuses []string
+	for i, ev := range evs {
+		if ev.Seq != int64(i+1) {
+			t.Fatalf("expected contiguous seq, event %d has seq=%d", i, ev.Seq)
+		}
+		if ev.Type == events.TypeStatus {
+			statuses = append(statuses, payloadString(ev.Payload, "status"))
+		}
+	}
+	if len(statuses) != 4 || statuses[3] != StatusCancelled {
+		t.Fatalf("expected cancel status events to be persisted, got %v", statuses)
+	}
+	diag := svc.EventDiagnostics()
+	if diag.DuplicateSeq == 0 || diag.Resequenced != diag.DuplicateSeq || diag.PersistFailures != 0 {
+		t.Fatalf("unexpected diagnostics: %+v", diag)
+	}
+}
+
+func TestIncludeRunsInjectsPriorOutput(t *testing.T) {
+	drv := newFakeDriver("codex", false)
+	drv.script = []events.Event{
+		{Type: events.TypeToken, Channel: events.ChannelWorking, Payload: map[string]any{"text": "thinking..."}},
+		{Type: events.TypeToken, Channel: events.ChannelFinal, Payload: map[string]any{"text": "prior answer"}},
+		{Type: events.TypeDone, Payload: map[string]any{"status": "completed"}},
+	}
+	svc := setupService(t, drv)
+
+	first, err := svc.Submit(context.Background(), SubmitRequest{
+		WorkspaceID:   "ws-1",
+		WorkspacePath: "/tmp",
+		Backend:       "codex",
+		Prompt:        "first",
+	})
+	if err != nil {
+		t.Fatalf("submit first: %v", err)
+	}
+	waitStatus(t, svc, first.ID, StatusCompleted)
+
+	if _, err := svc.Submit(context.Background(), SubmitRequest{
+		WorkspaceID:   "ws-other",
+		WorkspacePath: "/tmp",
+		Backend:       "codex",
+		Prompt:        "cross workspace",
+		Context:       map[string]any{"include_runs": []any{first.ID}},
+	}); err == nil {
+		t.Fatalf("expected cross-workspace include to be rejected")
+	}
+
+	second, err := svc.Submit(context.Background(), SubmitRequest{
+		WorkspaceID:   "ws-1",
+		WorkspacePath: "/tmp",
+		Backend:       "codex",
+		Prompt:        "follow up",
+		Context: map[string]any{"include_runs": []any{
+			map[string]any{"run_id": first.ID, "parts": "final"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("submit second: %v", err)
+	}
+	waitStatus(t, svc, second.ID, StatusCompleted)
+
+	drv.cancelMu.Lock()
+	prompt := drv.lastStart.Prompt
+	drv.cancelMu.Unlock()
+	if !strings.HasPrefix(prompt, "follow up\n\n[bridge prior runs]") || !strings.Contains(prompt, "prior answer") {
+		t.Fatalf("expected prior output in prompt, got %q", prompt)
+	}
+	if strings.Contains(prompt, "thinking...") {
+		t.Fatalf("expected only final output to be included, got %q", prompt)
+	}
+	resolved, ok := second.Context["resolved_runs"].([]map[string]any)
+	if !ok || len(resolved) != 1 || resolved[0]["run_id"] != first.ID {
+		t.Fatalf("unexpected resolved_runs: %#v", second.Context["resolved_runs"])
+	}
+}
+
+func TestCondenseTextKeepsHeadAndTail(t *testing.T) {
+	text := strings.Repeat("a", 500) + strings.Repeat("z", 500)
+	out, truncated := condenseText(text, 200)
+	if !truncated || len(out) > 200 {
+		t.Fatalf("expected truncation to <=200 bytes, got %d truncated=%v", len(out), truncated)
+	}
+	if !strings.HasPrefix(out, "a") || !strings.HasSuffix(out, "z") || !strings.Contains(out, "bytes omitted") {
+		t.Fatalf("unexpected condensed text: %q", out)
+	}
+	if out, truncated := condenseText("short", 200); truncated || out != "short" {
+		t.Fatalf("short text should be unchanged, got %q", out)
+	}
+}
+
+func TestAdapterHealthChangePublishesStatusToActiveRuns(t *testing.T) {
+	svc := setupService(t, newFakeDriver("codex", true))
+	r, err := svc.Submit(context.Background(), SubmitRequest{
+		WorkspaceID:   "ws-1",
+		WorkspacePath: "/tmp",
+		Backend:       "codex",
+		Prompt:        "long running",
+	})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, r.ID, StatusStreaming)
+
+	svc.publishAdapterHealth("gemini", false, "ignored")
+	svc.publishAdapterHealth("codex", false, "health probe timed out")
+
+	evs, err := svc.ListEvents(context.Background(), r.ID, 0)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	var found []events.Event
+	for _, ev := range evs {
+		if ev.Type == events.TypeStatus && ev.Payload["adapter"] != nil {
+			found = append(found, ev)
+		}
+	}
+	if len(found) != 1 {
+		t.Fatalf("expected one adapter health event, got %d", len(found))
+	}
+	if found[0].Payload["adapter"] != "unhealthy" || found[0].Payload["status"] != StatusStreaming || found[0].Payload["message"] != "health probe timed out" {
+		t.Fatalf("unexpected adapter health payload: %#v", found[0].Payload)
+	}
+	_ = svc.Cancel(context.Background(), r.ID)
+}
+
+func TestReapOrphanedRunsFailsOnlyUntrackedQueuedRuns(t *testing.T) {
+	svc := setupService(t, newFakeDriver("codex", true))
+	old := time.Now().UTC().Add(-time.Hour)
+	if err := svc.ledger.CreateRun(context.Background(), ledger.RunRecord{
+		ID:        "orphan-1",
+		Workspace: "/tmp",
+		Backend:   "codex",
+		Prompt:    "left behind",
+		Status:    StatusQueued,
+		CreatedAt: old,
+		UpdatedAt: old,
+	}); err != nil {
+		t.Fatalf("create orphan run: %v", err)
+	}
+	live, err := svc.Submit(context.Background(), SubmitRequest{
+		WorkspaceID:   "ws-1",
+		WorkspacePath: "/tmp",
+		Backend:       "codex",
+		Prompt:        "still running",
+	})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, live.ID, StatusStreaming)
+
+	n, err := svc.ReapOrphanedRuns(context.Background(), time.Minute)
+	if err != nil {
+		t.Fatalf("reap: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected one reaped run, got %d", n)
+	}
+	got, err := svc.GetRun(context.Background(), "orphan-1")
+	if err != nil {
+		t.Fatalf("get orphan: %v", err)
+	}
+	if got.Status != StatusFailed || got.Terminal.ReasonCode != "orphaned" {
+		t.Fatalf("unexpected orphan state: %s %#v", got.Status, got.Terminal)
+	}
+	evs, _ := svc.ListEvents(context.Background(), "orphan-1", 0)
+	if len(evs) != 1 || evs[0].Type != events.TypeDone || evs[0].Payload["reason_code"] != "orphaned" {
+		t.Fatalf("expected terminal orphaned event, got %#v", evs)
+	}
+	if n, _ := svc.ReapOrphanedRuns(context.Background(), 0); n != 0 {
+		t.Fatalf("expected tracked runs to be left alone, reaped %d", n)
+	}
+	_ = svc.Cancel(context.Background(), live.ID)
+}
+
+func TestTokenCostEstimation(t *testing.T) {
+	pricing, err := ParseTokenPricing("codex:1/10, codex/gpt-5-mini:0.25/2")
+	if err != nil {
+		t.Fatalf("parse pricing: %v", err)
+	}
+	if _, err := ParseTokenPricing("codex:1"); err == nil {
+		t.Fatalf("expected malformed pricing entry to be rejected")
+	}
+
+	drv := newFakeDriver("codex", false)
+	drv.script = []events.Event{{
+		Type: events.TypeDone,
+		Payload: map[string]any{
+			"status": "completed",
+			"usage":  map[string]any{"input_tokens": 1000000, "output_tokens": 100000},
+		},
+		Source: "fake",
+	}}
+	svc := setupService(t, drv)
+	svc.SetTokenPricing(pricing)
+
+	var runIDs []string
+	for _, model := range []string{"", "gpt-5-mini"} {
+		r, err := svc.Submit(context.Background(), SubmitRequest{
+			WorkspaceID:   "ws-1",
+			WorkspacePath: "/tmp",
+			Backend:       "codex",
+			Prompt:        "priced",
+			Options:       RunOptions{Model: model},
+		})
+		if err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+		waitStatus(t, svc, r.ID, StatusCompleted)
+		runIDs = append(runIDs, r.ID)
+	}
+
+	for i, want := range []float64{2, 0.45} {
+		got, err := svc.GetRun(context.Background(), runIDs[i])
+		if err != nil {
+			t.Fatalf("get run: %v", err)
+		}
+		if got.Usage == nil || got.Usage.CostUSD == nil || *got.Usage.CostUSD != want {
+			t.Fatalf("run %d: expected cost %v, got %#v", i, want, got.Usage)
+		}
+	}
+
+	now := time.Now().UTC()
+	summary, err := svc.TokenUsage(context.Background(), now.Add(-time.Hour), now.Add(time.Minute), "")
+	if err != nil {
+		t.Fatalf("token usage: %v", err)
+	}
+	if summary.Totals.CostUSD == nil || *summary.Totals.CostUSD != 2.45 || summary.Totals.RunCount != 2 {
+		t.Fatalf("unexpected priced totals: %#v", summary.Totals)
+	}
+	if len(summary.ByBackend) != 1 || summary.ByBackend[0].CostUSD == nil || *summary.ByBackend[0].CostUSD != 2.45 {
+		t.Fatalf("unexpected priced backend rows: %#v", summary.ByBackend)
+	}
+}
+
+func TestQuotaEnforcementBlocksSubmits(t *testing.T) {
+	drv := newFakeDriver("codex", false)
+	drv.script = []events.Event{{
+		Type: events.TypeDone,
+		Payload: map[string]any{
+			"status": "completed",
+			"usage":  map[string]any{"input_tokens": 80, "output_tokens": 20},
+		},
+		Source: "fake",
+	}}
+	svc := setupService(t, drv)
+	if err := svc.SetQuotaEnforcement("bogus"); err == nil {
+		t.Fatalf("expected invalid enforcement mode to be rejected")
+	}
+	svc.SetDeviceDailyTokenQuota(map[string]int64{"*": 100})
+
+	submit := func(address string) (Run, error) {
+		return svc.Submit(context.Background(), SubmitRequest{
+			WorkspaceID:   "ws-1",
+			WorkspacePath: "/tmp",
+			Backend:       "codex",
+			Prompt:        "quota",
+			SubmittedBy:   address,
+		})
+	}
+
+	first, err := submit("device-a")
+	if err != nil {
+		t.Fatalf("first submit: %v", err)
+	}
+	waitStatus(t, svc, first.ID, StatusCompleted)
+	if got, _ := svc.GetRun(context.Background(), first.ID); got.SubmittedBy != "device-a" {
+		t.Fatalf("expected submitted_by to persist, got %q", got.SubmittedBy)
+	}
+
+	// Enforcement is off by default.
+	if _, err := submit("device-a"); err != nil {
+		t.Fatalf("submit with enforcement off: %v", err)
+	}
+
+	if err := svc.SetQuotaEnforcement(QuotaEnforcementSoft); err != nil {
+		t.Fatalf("set soft: %v", err)
+	}
+	soft, err := submit("device-a")
+	if err != nil {
+		t.Fatalf("soft submit: %v", err)
+	}
+	if soft.QuotaWarning == "" {
+		t.Fatalf("expected soft mode to return a quota warning")
+	}
+
+	if err := svc.SetQuotaEnforcement(QuotaEnforcementHard); err != nil {
+		t.Fatalf("set hard: %v", err)
+	}
+	_, err = submit("device-a")
+	var quotaErr *QuotaExceededError
+	if !errors.As(err, &quotaErr) || !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected quota exceeded error, got %v", err)
+	}
+	if quotaErr.Scope != "device" || quotaErr.Key != "device-a" || quotaErr.Limit != 100 {
+		t.Fatalf("unexpected quota error: %#v", quotaErr)
+	}
+	if _, err := submit("device-b"); err != nil {
+		t.Fatalf("other device should not be blocked: %v", err)
+	}
+
+	svc.SetDailyTokenQuota(map[string]int64{"codex": 100})
+	_, err = submit("device-b")
+	if !errors.As(err, &quotaErr) || quotaErr.Scope != "backend" {
+		t.Fatalf("expected backend quota error, got %v", err)
+	}
+}
+
+func TestTokenUsageGroupedByDeviceAndWorkspace(t *testing.T) {
+	drv := newFakeDriver("codex", false)
+	drv.script = []events.Event{{
+		Type: events.TypeDone,
+		Payload: map[string]any{
+			"status": "completed",
+			"usage":  map[string]any{"input_tokens": 30, "output_tokens": 10},
+		},
+		Source: "fake",
+	}}
+	svc := setupService(t, drv)
+
+	for _, sub := range []struct{ workspace, device string }{
+		{"ws-1", "device-a"},
+		{"ws-1", "device-b"},
+		{"ws-2", "device-a"},
+	} {
+		r, err := svc.Submit(context.Background(), SubmitRequest{
+			WorkspaceID:   sub.workspace,
+			WorkspacePath: "/tmp",
+			Backend:       "codex",
+			Prompt:        "attribute",
+			SubmittedBy:   sub.device,
+		})
+		if err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+		waitStatus(t, svc, r.ID, StatusCompleted)
+	}
+
+	now := time.Now().UTC()
+	want := map[string]map[string]int64{
+		"device":    {"device-a": 80, "device-b": 40},
+		"workspace": {"ws-1": 80, "ws-2": 40},
+	}
+	for groupBy, expected := range want {
+		summary, err := svc.TokenUsageBy(context.Background(), now.Add(-time.Hour), now.Add(time.Minute), "", groupBy)
+		if err != nil {
+			t.Fatalf("usage by %s: %v", groupBy, err)
+		}
+		if summary.GroupBy != groupBy || summary.Totals.TotalTokens != 120 {
+			t.Fatalf("unexpected %s summary: %+v", groupBy, summary)
+		}
+		got := map[string]int64{}
+		for _, g := range summary.Groups {
+			got[g.Key] = g.TotalTokens
+		}
+		if len(got) != len(expected) {
+			t.Fatalf("unexpected %s groups: %+v", groupBy, summary.Groups)
+		}
+		for key, tokens := range expected {
+			if got[key] != tokens {
+				t.Fatalf("%s %s: expected %d tokens, got %d", groupBy, key, tokens, got[key])
+			}
+		}
+	}
+	if _, err := svc.TokenUsageBy(context.Background(), now.Add(-time.Hour), now, "", "model"); err == nil {
+		t.Fatalf("expected unsupported group_by to be rejected")
+	}
+}
+
+func TestAsyncEventPersistenceDrainsQueue(t *testing.T) {
+	drv := newFakeDriver("codex", false)
+	script := make([]events.Event, 0, 201)
+	for i := 0; i < 200; i++ {
+		script = append(script, events.Event{Type: events.TypeToken, Payload: map[string]any{"text": "x"}, Source: "fake"})
+	}
+	drv.script = append(script, events.Event{Type: events.TypeDone, Payload: map[string]any{"status": "completed"}, Source: "fake"})
+	svc := setupService(t, drv)
+	if err := svc.SetEventPersistence(PersistOptions{Workers: 2, QueueSize: 8, Overflow: "bogus"}); err == nil {
+		t.Fatalf("expected invalid overflow policy to be rejected")
+	}
+	if err := svc.SetEventPersistence(PersistOptions{Workers: 2, QueueSize: 8, BatchSize: 16, FlushInterval: 5 * time.Millisecond}); err != nil {
+		t.Fatalf("set persistence: %v", err)
+	}
+
+	r, err := svc.Submit(context.Background(), SubmitRequest{
+		WorkspaceID:   "ws-1",
+		WorkspacePath: "/tmp",
+		Backend:       "codex",
+		Prompt:        "hello",
+	})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, r.ID, StatusCompleted)
+	deadline := time.Now().Add(5 * time.Second)
+	for svc.EventDiagnostics().PersistQueued != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("persist queue did not drain: %+v", svc.EventDiagnostics())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	evs, err := svc.ListEvents(context.Background(), r.ID, 0)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	tokens := 0
+	for i, ev := range evs {
+		if ev.Seq != int64(i+1) {
+			t.Fatalf("expected contiguous seq, event %d has seq=%d", i, ev.Seq)
+		}
+		if ev.Type == events.TypeToken {
+			tokens++
+		}
+	}
+	if tokens != 200 {
+		t.Fatalf("expected 200 persisted token events, got %d", tokens)
+	}
+	diag := svc.EventDiagnostics()
+	if diag.PersistQueueHighWater == 0 || diag.PersistBatches == 0 || diag.PersistDropped != 0 || diag.PersistFailures != 0 {
+		t.Fatalf("unexpected diagnostics: %+v", diag)
+	}
+}
+
+type inputFakeDriver struct {
+	*fakeDriver
+	mu     sync.Mutex
+	inputs []string
+}
+
+func (d *inputFakeDriver) Capabilities(ctx context.Context) (driver.CapabilitySet, error) {
+	caps, err := d.fakeDriver.Capabilities(ctx)
+	caps.SupportsInput = true
+	return caps, err
+}
+
+func (d *inputFakeDriver) SendInput(_ context.Context, runID, data string, eof bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.inputs = append(d.inputs, data)
+	return nil
+}
+
+func TestSendInputToInteractiveRun(t *testing.T) {
+	plain := setupService(t, newFakeDriver("codex", true))
+	if _, err := plain.Submit(context.Background(), SubmitRequest{
+		WorkspacePath: "/tmp",
+		Backend:       "codex",
+		Prompt:        "ask me",
+		Options:       RunOptions{Interactive: true},
+	}); !errors.Is(err, ErrInputUnsupported) {
+		t.Fatalf("expected ErrInputUnsupported, got %v", err)
+	}
+
+	drv := &inputFakeDriver{fakeDriver: newFakeDriver("codex", true)}
+	svc := setupService(t, drv)
+	r, err := svc.Submit(context.Background(), SubmitRequest{
+		WorkspacePath: "/tmp",
+		Backend:       "codex",
+		Prompt:        "ask me",
+		Options:       RunOptions{Interactive: true},
+	})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, r.ID, StatusStreaming)
+	drv.cancelMu.Lock()
+	interactive := drv.lastStart.Options.Interactive
+	drv.cancelMu.Unlock()
+	if !interactive {
+		t.Fatal("interactive option not passed to driver")
+	}
+	if err := svc.SendInput(context.Background(), r.ID, "yes", false); err != nil {
+		t.Fatalf("send input: %v", err)
+	}
+	drv.mu.Lock()
+	got := append([]string(nil), drv.inputs...)
+	drv.mu.Unlock()
+	if len(got) != 1 || got[0] != "yes" {
+		t.Fatalf("inputs = %v", got)
+	}
+
+	if err := svc.Cancel(context.Background(), r.ID); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	waitStatus(t, svc, r.ID, StatusCancelled)
+	if err := svc.SendInput(context.Background(), r.ID, "late", false); !errors.Is(err, ErrRunNotActive) {
+		t.Fatalf("expected ErrRunNotActive after cancel, got %v", err)
+	}
+}
+
+type extendFakeDriver struct {
+	*fakeDriver
+	mu       sync.Mutex
+	timeouts []time.Duration
+}
+
+func (d *extendFakeDriver) ExtendRun(_ context.Context, runID string, timeout time.Duration) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.timeouts = append(d.timeouts, timeout)
+	return nil
+}
+
+func TestExtendRunMovesDeadlineWithinPolicy(t *testing.T) {
+	drv := &extendFakeDriver{fakeDriver: newFakeDriver("codex", true)}
+	svc := setupService(t, drv)
+	svc.runTimeout = 300 * time.Millisecond
+	r, err := svc.Submit(context.Background(), SubmitRequest{WorkspacePath: "/tmp", Backend: "codex", Prompt: "long"})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, r.ID, StatusStreaming)
+
+	if _, err := svc.ExtendRun(context.Background(), r.ID, time.Second); !errors.Is(err, ErrExtensionDenied) {
+		t.Fatalf("expected extensions disabled by default, got %v", err)
+	}
+	svc.policy.SetRunExtensionLimits(policy.RunExtensionLimits{MaxExtension: time.Second, MaxTotal: 1500 * time.Millisecond})
+	if _, err := svc.ExtendRun(context.Background(), r.ID, 2*time.Second); !errors.Is(err, ErrExtensionDenied) {
+		t.Fatalf("expected per-request cap, got %v", err)
+	}
+	out, err := svc.ExtendRun(context.Background(), r.ID, time.Second)
+	if err != nil {
+		t.Fatalf("extend: %v", err)
+	}
+	if out.ExtendedBy != 1 || out.ExtendedTotal != 1 || !out.Deadline.After(time.Now().Add(900*time.Millisecond)) {
+		t.Fatalf("unexpected deadline %+v", out)
+	}
+	if _, err := svc.ExtendRun(context.Background(), r.ID, time.Second); !errors.Is(err, ErrExtensionDenied) {
+		t.Fatalf("expected total cap, got %v", err)
+	}
+	drv.mu.Lock()
+	timeouts := append([]time.Duration(nil), drv.timeouts...)
+	drv.mu.Unlock()
+	if len(timeouts) != 1 || timeouts[0] < time.Second || timeouts[0] > 1300*time.Millisecond {
+		t.Fatalf("adapter timeouts = %v", timeouts)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	if got := svc.currentStatus(r.ID); got != StatusStreaming {
+		t.Fatalf("run should outlive its original deadline, status=%s", got)
+	}
+	failed := waitStatus(t, svc, r.ID, StatusFailed)
+	if failed.Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("unexpected error %q", failed.Error)
+	}
+	evs, err := svc.ListEvents(context.Background(), r.ID, 0)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	found := false
+	for _, ev := range evs {
+		if ev.Type == events.TypeStatus && ev.Payload["reason"] == "deadline_extended" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("missing deadline_extended status event")
+	}
+	if _, err := svc.ExtendRun(context.Background(), r.ID, time.Second); !errors.Is(err, ErrRunNotActive) {
+		t.Fatalf("expected ErrRunNotActive after timeout, got %v", err)
+	}
+}
+
+func TestRunTimeoutOverrideIsBoundedAndReported(t *testing.T) {
+	drv := newFakeDriver("codex", true)
+	svc := setupService(t, drv)
+	svc.runTimeout = time.Hour
+	req := SubmitRequest{WorkspacePath: "/tmp", Backend: "codex", Prompt: "long", Options: RunOptions{TimeoutSeconds: 2}}
+	if _, err := svc.Submit(context.Background(), req); !errors.Is(err, policy.ErrViolation) {
+		t.Fatalf("expected overrides disabled by default, got %v", err)
+	}
+	svc.policy.SetMaxRunTimeout(time.Minute)
+	req.Options.TimeoutSeconds = 120
+	if _, err := svc.Submit(context.Background(), req); !errors.Is(err, policy.ErrViolation) {
+		t.Fatalf("expected timeout above the maximum to be rejected, got %v", err)
+	}
+	req.Options.TimeoutSeconds = 2
+	r, err := svc.Submit(context.Background(), req)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, r.ID, StatusStreaming)
+
+	drv.cancelMu.Lock()
+	adapterDeadline := drv.lastStart.Deadline
+	drv.cancelMu.Unlock()
+	if until := time.Until(adapterDeadline); until <= 0 || until > 2*time.Second {
+		t.Fatalf("adapter deadline %v is not the run's timeout", adapterDeadline)
+	}
+	got, err := svc.GetRun(context.Background(), r.ID)
+	if err != nil {
+		t.Fatalf("get run: %v", err)
+	}
+	if got.Options.TimeoutSeconds != 2 || got.Deadline == nil || !got.Deadline.Equal(adapterDeadline.UTC()) || got.RemainingSeconds == nil || *got.RemainingSeconds > 2 {
+		t.Fatalf("unexpected deadline report %+v", got)
+	}
+
+	failed := waitStatus(t, svc, r.ID, StatusFailed)
+	if failed.Error != context.DeadlineExceeded.Error() || failed.Deadline != nil || failed.RemainingSeconds != nil {
+		t.Fatalf("expected the run to time out after its own timeout, got %+v", failed)
+	}
+}
+
+func TestStallWatchdogWarnsThenFailsSilentRun(t *testing.T) {
+	svc := setupService(t, newFakeDriver("codex", true))
+	svc.SetStallPolicy(StallPolicy{WarnAfter: 150 * time.Millisecond, CancelAfter: 600 * time.Millisecond, ProbeHealth: true})
+	r, err := svc.Submit(context.Background(), SubmitRequest{WorkspacePath: "/tmp", Backend: "codex", Prompt: "hang"})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	failed := waitStatus(t, svc, r.ID, StatusFailed)
+	if failed.Terminal.ReasonCode != "stalled" {
+		t.Fatalf("expected reason_code stalled, got %+v", failed.Terminal)
+	}
+	evs, err := svc.ListEvents(context.Background(), r.ID, 0)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	var warning map[string]any
+	for _, ev := range evs {
+		if ev.Type == events.TypeStatus && ev.Payload["reason"] == "stalled" {
+			if warning != nil {
+				t.Fatalf("expected a single stall warning, got another %v", ev.Payload)
+			}
+			warning = ev.Payload
+		}
+	}
+	if warning == nil {
+		t.Fatal("missing stalled status event")
+	}
+	if health, _ := warning["adapter_health"].(map[string]any); health == nil || warning["cancel_at"] == nil {
+		t.Fatalf("stall warning lacks adapter_health or cancel_at: %v", warning)
+	}
+	if err := events.ValidatePayload(events.TypeStatus, warning); err != nil {
+		t.Fatalf("stall warning does not match the status payload: %v", err)
+	}
+}
+
+type pauseFakeDriver struct {
+	*fakeDriver
+	mu    sync.Mutex
+	calls []string
+	// pauseDelay holds PauseRun back, to let the run time out meanwhile.
+	pauseDelay time.Duration
+}
+
+func (d *pauseFakeDriver) Capabilities(ctx context.Context) (driver.CapabilitySet, error) {
+	caps, err := d.fakeDriver.Capabilities(ctx)
+	caps.SupportsPause = true
+	return caps, err
+}
+
+func (d *pauseFakeDriver) PauseRun(_ context.Context, runID string) error {
+	time.Sleep(d.pauseDelay)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls = append(d.calls, "pause")
+	return nil
+}
+
+func (d *pauseFakeDriver) ResumeRun(_ context.Context, runID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls = append(d.calls, "resume")
+	return nil
+}
+
+func TestPauseRunStopsTimeoutClock(t *testing.T) {
+	drv := &pauseFakeDriver{fakeDriver: newFakeDriver("codex", true)}
+	svc := setupService(t, drv)
+	svc.runTimeout = 300 * time.Millisecond
+	r, err := svc.Submit(context.Background(), SubmitRequest{WorkspacePath: "/tmp", Backend: "codex", Prompt: "long"})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, r.ID, StatusStreaming)
+
+	paused, err := svc.PauseRun(context.Background(), r.ID)
+	if err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if paused.Status != StatusPaused {
+		t.Fatalf("unexpected pause result %+v", paused)
+	}
+	if _, err := svc.PauseRun(context.Background(), r.ID); !errors.Is(err, ErrRunPaused) {
+		t.Fatalf("expected ErrRunPaused, got %v", err)
+	}
+	if _, err := svc.ExtendRun(context.Background(), r.ID, time.Second); !errors.Is(err, ErrRunPaused) {
+		t.Fatalf("expected extension of paused run to be rejected, got %v", err)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	if rec, err := svc.GetRun(context.Background(), r.ID); err != nil || rec.Status != StatusPaused {
+		t.Fatalf("paused run should outlive its timeout, got %+v %v", rec, err)
+	}
+
+	resumed, err := svc.ResumeRun(context.Background(), r.ID)
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if resumed.Status != StatusStreaming || resumed.Deadline == nil {
+		t.Fatalf("unexpected resume result %+v", resumed)
+	}
+	if _, err := svc.ResumeRun(context.Background(), r.ID); !errors.Is(err, ErrRunNotPaused) {
+		t.Fatalf("expected ErrRunNotPaused, got %v", err)
+	}
+	failed := waitStatus(t, svc, r.ID, StatusFailed)
+	if failed.Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("resumed run should time out with its remaining time, got %q", failed.Error)
+	}
+
+	drv.mu.Lock()
+	calls := strings.Join(drv.calls, ",")
+	drv.mu.Unlock()
+	if calls != "pause,resume" {
+		t.Fatalf("driver calls = %s", calls)
+	}
+	evs, err := svc.ListEvents(context.Background(), r.ID, 0)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	var reasons []string
+	for _, ev := range evs {
+		if reason, ok := ev.Payload["reason"].(string); ok && ev.Type == events.TypeStatus {
+			reasons = append(reasons, reason)
+		}
+	}
+	if strings.Join(reasons, ",") != "paused,resumed" {
+		t.Fatalf("status reasons = %v", reasons)
+	}
+}
+
+func TestPauseRunResumesAdapterWhenRunTimedOutMeanwhile(t *testing.T) {
+	drv := &pauseFakeDriver{fakeDriver: newFakeDriver("codex", true), pauseDelay: 400 * time.Millisecond}
+	svc := setupService(t, drv)
+	svc.runTimeout = 200 * time.Millisecond
+	r, err := svc.Submit(context.Background(), SubmitRequest{WorkspacePath: "/tmp", Backend: "codex", Prompt: "long"})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, r.ID, StatusStreaming)
+	if _, err := svc.PauseRun(context.Background(), r.ID); !errors.Is(err, ErrRunNotActive) {
+		t.Fatalf("expected ErrRunNotActive, got %v", err)
+	}
+	drv.mu.Lock()
+	calls := strings.Join(drv.calls, ",")
+	drv.mu.Unlock()
+	if calls != "pause,resume" {
+		t.Fatalf("adapter left paused after the run timed out: calls = %s", calls)
+	}
+	waitStatus(t, svc, r.ID, StatusFailed)
+}
+
+func TestPauseRunRequiresCapability(t *testing.T) {
+	svc := setupService(t, newFakeDriver("codex", true))
+	r, err := svc.Submit(context.Background(), SubmitRequest{WorkspacePath: "/tmp", Backend: "codex", Prompt: "long"})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, r.ID, StatusStreaming)
+	if _, err := svc.PauseRun(context.Background(), r.ID); !errors.Is(err, ErrPauseUnsupported) {
+		t.Fatalf("expected ErrPauseUnsupported, got %v", err)
+	}
+	_ = svc.Cancel(context.Background(), r.ID)
+}
+
+func TestRetryRunClonesInputsAndLinksParent(t *testing.T) {
+	drv := newFakeDriver("codex", false)
+	svc := setupService(t, drv)
+	svc.SetFileStorage(filepath.Join(t.TempDir(), "files"), 1024)
+	uploaded, err := svc.UploadFile(context.Background(), UploadFileRequest{
+		Reader:       strings.NewReader("notes"),
+		OriginalName: "notes.txt",
+		CreatedBy:    "test",
+	})
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	parent, err := svc.Submit(context.Background(), SubmitRequest{
+		WorkspaceID:   "ws-1",
+		WorkspacePath: t.TempDir(),
+		Backend:       "codex",
+		Prompt:        "summarize @notes.txt",
+		Context:       map[string]any{"attachments": []any{uploaded.FileID}},
+		Options:       RunOptions{Model: "gpt-5", Sandbox: "workspace-write"},
+	})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, parent.ID, StatusCompleted)
+	firstPrompt := drv.lastStart.Prompt
+
+	child, err := svc.RetryRun(context.Background(), parent.ID, RetryOverrides{Model: "gpt-5-mini"}, "retrier")
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	waitStatus(t, svc, child.ID, StatusCompleted)
+	got, err := svc.GetRun(context.Background(), child.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.ParentRunID != parent.ID || got.SubmittedBy != "retrier" {
+		t.Fatalf("unexpected lineage: parent=%q submitted_by=%q", got.ParentRunID, got.SubmittedBy)
+	}
+	if got.Options.Model != "gpt-5-mini" || got.Options.Sandbox != "workspace-write" {
+		t.Fatalf("unexpected options: %#v", got.Options)
+	}
+	if len(got.Attachments) != 1 || got.Attachments[0].FileID != uploaded.FileID {
+		t.Fatalf("attachments not cloned: %#v", got.Attachments)
+	}
+	if drv.lastStart.Prompt != firstPrompt {
+		t.Fatalf("retry prompt differs:\n%s\nvs\n%s", drv.lastStart.Prompt, firstPrompt)
+	}
+}
+
+func TestRetryRunRejectsActiveRun(t *testing.T) {
+	svc := setupService(t, newFakeDriver("codex", true))
+	r, err := svc.Submit(context.Background(), SubmitRequest{WorkspacePath: "/tmp", Backend: "codex", Prompt: "long"})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, r.ID, StatusStreaming)
+	if _, err := svc.RetryRun(context.Background(), r.ID, RetryOverrides{}, ""); !errors.Is(err, ErrRunStillActive) {
+		t.Fatalf("expected ErrRunStillActive, got %v", err)
+	}
+	_ = svc.Cancel(context.Background(), r.ID)
+}
+
+func waitPipeline(t *testing.T, svc *Service, id string) Pipeline {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		p, err := svc.GetPipeline(context.Background(), id)
+		if err == nil && p.Status != PipelineRunning {
+			return p
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("pipeline %s did not finish", id)
+	return Pipeline{}
+}
+
+func TestPipelineRunsStepsInDependencyOrder(t *testing.T) {
+	drv := newFakeDriver("codex", false)
+	drv.script = []events.Event{
+		{Type: events.TypeToken, Channel: events.ChannelFinal, Payload: map[string]any{"text": "the plan"}},
+		{Type: events.TypeDone, Payload: map[string]any{"status": "completed"}},
+	}
+	svc := setupService(t, drv)
+
+	p, err := svc.SubmitPipeline(context.Background(), PipelineRequest{
+		WorkspaceID:   "ws-1",
+		WorkspacePath: "/tmp",
+		Steps: []PipelineStepRequest{
+			{ID: "build", Prompt: "Implement: {{steps.plan.final_text}} (from {{ steps.plan.run_id }})"},
+			{ID: "plan", Prompt: "Make a plan"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("submit pipeline: %v", err)
+	}
+	if p.Steps[0].Status != StepPending || len(p.Steps[0].DependsOn) != 1 || p.Steps[1].RunID == "" {
+		t.Fatalf("unexpected initial steps: %+v", p.Steps)
+	}
+	done := waitPipeline(t, svc, p.ID)
+	if done.Status != PipelineCompleted {
+		t.Fatalf("expected completed pipeline, got %+v", done)
+	}
+	build := done.Steps[0]
+	if build.Status != StepCompleted || build.RunID == "" {
+		t.Fatalf("unexpected build step: %+v", build)
+	}
+	got, err := svc.GetRun(context.Background(), build.RunID)
+	if err != nil {
+		t.Fatalf("get build run: %v", err)
+	}
+	want := "Implement: the plan (from " + done.Steps[1].RunID + ")"
+	if !strings.Contains(got.Prompt, want) {
+		t.Fatalf("expected %q in prompt, got %q", want, got.Prompt)
+	}
+}
+
+func TestPipelineSkipsStepsBehindFailure(t *testing.T) {
+	drv := newFakeDriver("codex", false)
+	drv.script = []events.Event{
+		{Type: events.TypeDone, Payload: map[string]any{"status": "failed", "message": "boom"}},
+	}
+	svc := setupService(t, drv)
+	if _, err := svc.SubmitPipeline(context.Background(), PipelineRequest{
+		WorkspacePath: "/tmp",
+		Steps: []PipelineStepRequest{
+			{ID: "a", Prompt: "a", DependsOn: []string{"b"}},
+			{ID: "b", Prompt: "b", DependsOn: []string{"a"}},
+		},
+	}); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+
+	p, err := svc.SubmitPipeline(context.Background(), PipelineRequest{
+		WorkspacePath: "/tmp",
+		Steps: []PipelineStepRequest{
+			{ID: "first", Prompt: "first"},
+			{ID: "second", Prompt: "second", DependsOn: []string{"first"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("submit pipeline: %v", err)
+	}
+	done := waitPipeline(t, svc, p.ID)
+	if done.Status != PipelineFailed || done.Steps[0].Status != StepFailed || done.Steps[1].Status != StepSkipped {
+		t.Fatalf("unexpected pipeline: %+v", done)
+	}
+}
+
+func TestGetRunResultCachesFinalText(t *testing.T) {
+	drv := newFakeDriver("codex", false)
+	drv.script = []events.Event{
+		{Type: events.TypeToken, Channel: events.ChannelWorking, Payload: map[string]any{"text": "thinking..."}},
+		{Type: events.TypeToken, Channel: events.ChannelFinal, Payload: map[string]any{"text": "## Done\n"}},
+		{Type: events.TypeToken, Channel: events.ChannelFinal, Payload: map[string]any{"text": "All tests pass."}},
+		{Type: events.TypeDone, Payload: map[string]any{"status": "completed", "usage": map[string]any{"input_tokens": 3, "output_tokens": 4}}},
+	}
+	svc := setupService(t, drv)
+	r, err := svc.Submit(context.Background(), SubmitRequest{WorkspacePath: "/tmp", Backend: "codex", Prompt: "finish"})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, r.ID, StatusCompleted)
+
+	var cached ledger.RunResultRecord
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if cached, err = svc.ledger.GetRunResult(context.Background(), r.ID); err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil || cached.FinalText != "## Done\nAll tests pass." {
+		t.Fatalf("expected cached final text, got %#v (%v)", cached, err)
+	}
+	res, err := svc.GetRunResult(context.Background(), r.ID)
+	if err != nil {
+		t.Fatalf("result: %v", err)
+	}
+	if res.FinalText != cached.FinalText || !res.Terminal.IsTerminal || res.Format != "markdown" || res.Usage == nil || res.Usage.TotalTokens != 7 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestOutputLimitTruncatesTokens(t *testing.T) {
+	drv := newFakeDriver("codex", false)
+	drv.script = []events.Event{
+		{Type: events.TypeToken, Channel: events.ChannelFinal, Payload: map[string]any{"text": "0123456789"}},
+		{Type: events.TypeToken, Channel: events.ChannelFinal, Payload: map[string]any{"text": "abcdefghij"}},
+		{Type: events.TypeToken, Channel: events.ChannelFinal, Payload: map[string]any{"text": "dropped"}},
+		{Type: events.TypeDone, Payload: map[string]any{"status": "completed"}},
+	}
+	svc := setupService(t, drv)
+	if err := svc.SetOutputLimit(15, ""); err != nil {
+		t.Fatalf("set output limit: %v", err)
+	}
+	r, err := svc.Submit(context.Background(), SubmitRequest{WorkspacePath: "/tmp", Backend: "codex", Prompt: "spam"})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, r.ID, StatusCompleted)
+	evs, err := svc.ListEvents(context.Background(), r.ID, 0)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	var text strings.Builder
+	notices := 0
+	for _, ev := range evs {
+		if ev.Type == events.TypeToken {
+			text.WriteString(payloadString(ev.Payload, "text"))
+		}
+		if ev.Type == events.TypeStatus && ev.Payload["reason"] == "output_truncated" {
+			notices++
+		}
+	}
+	if text.String() != "0123456789abcde" || notices != 1 {
+		t.Fatalf("unexpected output %q with %d notices", text.String(), notices)
+	}
+}
+
+// floodDriver streams tokens until its run is cancelled.
+type floodDriver struct{ *fakeDriver }
+
+func (d floodDriver) StartRun(ctx context.Context, req driver.StartRequest) (*driver.Stream, error) {
+	eventsCh := make(chan events.Event)
+	doneCh := make(chan error, 1)
+	go func() {
+		defer close(eventsCh)
+		defer close(doneCh)
+		for {
+			select {
+			case <-ctx.Done():
+				doneCh <- driver.StreamError(ctx, ctx.Err())
+				return
+			case eventsCh <- events.Event{Type: events.TypeToken, Payload: map[string]any{"text": "xxxxxxxx"}}:
+			}
+		}
+	}()
+	return &driver.Stream{Events: eventsCh, Done: doneCh}, nil
+}
+
+func TestOutputLimitCancelFailsRun(t *testing.T) {
+	svc := setupService(t, floodDriver{newFakeDriver("codex", false)})
+	if err := svc.SetOutputLimit(16, OutputLimitCancel); err != nil {
+		t.Fatalf("set output limit: %v", err)
+	}
+	if err := svc.SetOutputLimit(16, "explode"); err == nil {
+		t.Fatalf("expected invalid action to be rejected")
+	}
+	r, err := svc.Submit(context.Background(), SubmitRequest{WorkspacePath: "/tmp", Backend: "codex", Prompt: "spam"})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, r.ID, StatusFailed)
+	got, _ := svc.GetRun(context.Background(), r.ID)
+	if got.Error != ErrOutputLimitExceeded.Error() {
+		t.Fatalf("unexpected error %q", got.Error)
+	}
+}
+
+func TestHubSendsGapMarkerAndEvictsSaturatedSubscriber(t *testing.T) {
+	hub := NewHub()
+	ch, unsub := hub.Subscribe("run-1", 2)
+	defer unsub()
+
+	for seq := int64(1); seq <= 5; seq++ {
+		hub.Publish(events.Event{RunID: "run-1", Seq: seq, Type: events.TypeToken})
+	}
+	for want := int64(1); want <= 2; want++ {
+		if ev := <-ch; ev.Seq != want {
+			t.Fatalf("expected seq %d, got %d", want, ev.Seq)
+		}
+	}
+	hub.Publish(events.Event{RunID: "run-1", Seq: 6, Type: events.TypeToken})
+	marker := <-ch
+	if marker.Type != events.TypeGap {
+		t.Fatalf("expected gap marker, got %+v", marker)
+	}
+	if err := events.ValidatePayload(events.TypeGap, marker.Payload); err != nil {
+		t.Fatalf("gap marker does not match the gap payload: %v", err)
+	}
+	if marker.Payload["from_seq"] != int64(3) || marker.Payload["to_seq"] != int64(5) || marker.Payload["dropped"] != int64(3) {
+		t.Fatalf("unexpected gap range: %+v", marker.Payload)
+	}
+	if ev := <-ch; ev.Seq != 6 {
+		t.Fatalf("expected seq 6 after gap marker, got %d", ev.Seq)
+	}
+
+	hub.SetEvictAfter(time.Nanosecond)
+	for seq := int64(7); seq <= 12; seq++ {
+		hub.Publish(events.Event{RunID: "run-1", Seq: seq, Type: events.TypeToken})
+		time.Sleep(time.Millisecond)
+	}
+	for range ch {
+	}
+	stats := hub.Stats()
+	if stats.Evicted != 1 || stats.Subscribers != 0 || stats.GapMarkers != 1 {
+		t.Fatalf("unexpected hub stats: %+v", stats)
+	}
+	unsub()
+}
+
+func TestPipelineRootSubmitErrorsFailTheRequest(t *testing.T) {
+	svc := setupService(t, newFakeDriver("codex", true))
+	_, err := svc.SubmitPipeline(context.Background(), PipelineRequest{
+		WorkspacePath: "/tmp",
+		Steps: []PipelineStepRequest{
+			{ID: "ok", Prompt: "ok"},
+			{ID: "bad", Backend: "nope", Prompt: "bad"},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), `step "bad"`) {
+		t.Fatalf("expected the refused root step to fail the submit, got %v", err)
+	}
+	if items, err := svc.ListPipelines(context.Background(), PipelineQuery{}); err != nil || len(items) != 0 {
+		t.Fatalf("refused pipeline was saved: %+v %v", items, err)
+	}
+	runs, err := svc.ListRuns(context.Background(), RunQuery{})
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("expected the started root run only, got %+v %v", runs, err)
+	}
+	waitStatus(t, svc, runs[0].ID, StatusCancelled)
+}
+
+func TestResumePipelinesFailsStepsOfUntrackedRuns(t *testing.T) {
+	svc := setupService(t, newFakeDriver("codex", false))
+	ctx := context.Background()
+	now := time.Now().UTC()
+	if err := svc.savePipeline(ctx, Pipeline{
+		ID:            "p-restart",
+		WorkspacePath: "/tmp",
+		Status:        PipelineRunning,
+		Steps: []PipelineStep{
+			{ID: "first", Backend: "codex", Prompt: "first", Status: StepRunning, RunID: "gone"},
+			{ID: "second", Backend: "codex", Prompt: "second", DependsOn: []string{"first"}, Status: StepPending},
+		},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}); err != nil {
+		t.Fatalf("save pipeline: %v", err)
+	}
+	if n, err := svc.ResumePipelines(ctx); err != nil || n != 1 {
+		t.Fatalf("resume pipelines: %d %v", n, err)
+	}
+	p, err := svc.GetPipeline(ctx, "p-restart")
+	if err != nil || p.Status != PipelineFailed || p.Steps[0].Error != "interrupted by bridge restart" || p.Steps[1].Status != StepSkipped {
+		t.Fatalf("unexpected resumed pipeline: %+v %v", p, err)
+	}
+}
+
+func TestEmitReplacesInvalidEventsWithAnErrorEvent(t *testing.T) {
+	svc := setupService(t, newFakeDriver("codex", false))
+	ch, unsubscribe := svc.hub.Subscribe("run-invalid", 1)
+	defer unsubscribe()
+	svc.emit(context.Background(), "run-invalid", "codex", "bridge", "bogus", map[string]any{"x": 1})
+	select {
+	case ev := <-ch:
+		if ev.Type != events.TypeError || ev.Channel != events.ChannelSystem || ev.Payload["message"] != "invalid event contract in bridge emit" || ev.Payload["detail"] == "" {
+			t.Fatalf("unexpected fallback event: %+v", ev)
+		}
+		if _, err := events.DecodePayload[events.ErrorPayload](ev.Payload); err != nil {
+			t.Fatalf("fallback payload is not a typed error payload: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event published")
+	}
+}
+
+// Whichever of the run context and the driver's ErrRunCancelled stream
+// error the run loop sees first, the error event carries the cause.
+func TestRunTimeoutReportsTheCauseNotTheStreamError(t *testing.T) {
+	svc := setupService(t, newFakeDriver("codex", true))
+	svc.runTimeout = 50 * time.Millisecond
+	for i := 0; i < 5; i++ {
+		r, err := svc.Submit(context.Background(), SubmitRequest{WorkspaceID: "ws", WorkspacePath: "/tmp", Backend: "codex", Prompt: "wait"})
+		if err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+		final := waitStatus(t, svc, r.ID, StatusFailed)
+		if final.Terminal.ReasonCode != "timeout" {
+			t.Fatalf("unexpected terminal info: %#v", final.Terminal)
+		}
+		evs, err := svc.ListEvents(context.Background(), r.ID, 0)
+		if err != nil {
+			t.Fatalf("list events: %v", err)
+		}
+		last := evs[len(evs)-1]
+		if last.Type != events.TypeError || last.Payload["message"] != context.DeadlineExceeded.Error() {
+			t.Fatalf("unexpected final event: %+v", last)
+		}
+	}
+}
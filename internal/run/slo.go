@@ -0,0 +1,248 @@
+package run
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"echohelix/internal/signing"
+)
+
+// SLODefaultKey sets the first event threshold of backends without their
+// own entry.
+const SLODefaultKey = "*"
+
+// SLOConfig defines first event latency objectives. A run breaches its
+// backend's threshold when the first backend event arrives later than
+// FirstEvent after the run starts, or not at all. An alert fires when the
+// breach rate over Window reaches AlertBreachRate with at least
+// AlertMinSamples runs, at most once per Window per backend.
+type SLOConfig struct {
+	FirstEvent      map[string]time.Duration
+	Window          time.Duration
+	AlertBreachRate float64
+	AlertMinSamples int
+}
+
+// SLOAlert is sent to the alert sink when a backend's breach rate is over
+// the configured limit.
+type SLOAlert struct {
+	Event       string    `json:"event"`
+	Backend     string    `json:"backend"`
+	ThresholdMS int64     `json:"threshold_ms"`
+	Samples     int       `json:"samples"`
+	Breaches    int       `json:"breaches"`
+	BreachRate  float64   `json:"breach_rate"`
+	WindowSec   int       `json:"window_sec"`
+	TS          time.Time `json:"ts"`
+}
+
+type AlertSink func(ctx context.Context, alert SLOAlert)
+
+type sloTracker struct {
+	mu        sync.Mutex
+	cfg       SLOConfig
+	sink      AlertSink
+	samples   map[string][]sloSample
+	lastAlert map[string]time.Time
+}
+
+type sloSample struct {
+	at     time.Time
+	breach bool
+}
+
+func (s *Service) SetSLO(cfg SLOConfig) {
+	if cfg.Window <= 0 {
+		cfg.Window = 15 * time.Minute
+	}
+	if cfg.AlertMinSamples <= 0 {
+		cfg.AlertMinSamples = 10
+	}
+	s.slo.mu.Lock()
+	s.slo.cfg = cfg
+	s.slo.mu.Unlock()
+}
+
+// SetAlertSink receives SLO alerts in addition to the slo_alert log line.
+func (s *Service) SetAlertSink(sink AlertSink) {
+	s.slo.mu.Lock()
+	s.slo.sink = sink
+	s.slo.mu.Unlock()
+}
+
+func (s *Service) firstEventThreshold(backend string) time.Duration {
+	s.slo.mu.Lock()
+	defer s.slo.mu.Unlock()
+	if d, ok := s.slo.cfg.FirstEvent[backend]; ok {
+		return d
+	}
+	return s.slo.cfg.FirstEvent[SLODefaultKey]
+}
+
+// recordSLOSample adds one run outcome to the backend's window and alerts
+// when the breach rate crosses the limit.
+func (s *Service) recordSLOSample(backend string, breach bool, now time.Time) {
+	s.slo.mu.Lock()
+	cfg := s.slo.cfg
+	if s.slo.samples == nil {
+		s.slo.samples = map[string][]sloSample{}
+		s.slo.lastAlert = map[string]time.Time{}
+	}
+	cutoff := now.Add(-cfg.Window)
+	window := s.slo.samples[backend]
+	drop := 0
+	for drop < len(window) && window[drop].at.Before(cutoff) {
+		drop++
+	}
+	window = append(window[drop:], sloSample{at: now, breach: breach})
+	s.slo.samples[backend] = window
+
+	breaches := 0
+	for _, sample := range window {
+		if sample.breach {
+			breaches++
+		}
+	}
+	rate := float64(breaches) / float64(len(window))
+	fire := cfg.AlertBreachRate > 0 &&
+		len(window) >= cfg.AlertMinSamples &&
+		rate >= cfg.AlertBreachRate &&
+		now.Sub(s.slo.lastAlert[backend]) >= cfg.Window
+	if fire {
+		s.slo.lastAlert[backend] = now
+	}
+	sink := s.slo.sink
+	s.slo.mu.Unlock()
+	if !fire {
+		return
+	}
+
+	alert := SLOAlert{
+		Event:       "first_event_slo_breach",
+		Backend:     backend,
+		ThresholdMS: s.firstEventThreshold(backend).Milliseconds(),
+		Samples:     len(window),
+		Breaches:    breaches,
+		BreachRate:  math.Round(rate*10000) / 10000,
+		WindowSec:   int(cfg.Window.Seconds()),
+		TS:          now.UTC(),
+	}
+	log.Printf(
+		"slo_alert event=%s backend=%s breaches=%d samples=%d breach_rate=%.4f threshold_ms=%d window_sec=%d",
+		alert.Event, backend, breaches, alert.Samples, alert.BreachRate, alert.ThresholdMS, alert.WindowSec,
+	)
+	if sink != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+			sink(ctx, alert)
+		}()
+	}
+}
+
+// firstEventProbe times a run's first backend event. The SLO sample is
+// taken when the event arrives or when the threshold passes without one,
+// whichever comes first.
+type firstEventProbe struct {
+	s         *Service
+	runID     string
+	backend   string
+	start     time.Time
+	threshold time.Duration
+
+	mu       sync.Mutex
+	seen     bool
+	sampled  bool
+	timer    *time.Timer
+	finished bool
+}
+
+func (s *Service) startFirstEventProbe(runID, backend string) *firstEventProbe {
+	p := &firstEventProbe{
+		s:         s,
+		runID:     runID,
+		backend:   backend,
+		start:     time.Now(),
+		threshold: s.firstEventThreshold(backend),
+	}
+	if p.threshold > 0 {
+		p.mu.Lock()
+		p.timer = time.AfterFunc(p.threshold, p.expire)
+		p.mu.Unlock()
+	}
+	return p
+}
+
+func (p *firstEventProbe) expire() {
+	p.mu.Lock()
+	if p.seen || p.sampled || p.finished {
+		p.mu.Unlock()
+		return
+	}
+	p.sampled = true
+	p.mu.Unlock()
+	p.s.recordSLOSample(p.backend, true, time.Now().UTC())
+}
+
+// observe is called for every backend event; only the first one counts.
+func (p *firstEventProbe) observe() {
+	p.mu.Lock()
+	if p.seen {
+		p.mu.Unlock()
+		return
+	}
+	p.seen = true
+	latency := time.Since(p.start)
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	sample := p.threshold > 0 && !p.sampled
+	p.sampled = p.sampled || sample
+	p.mu.Unlock()
+
+	p.persist(latency)
+	if sample {
+		p.s.recordSLOSample(p.backend, latency > p.threshold, time.Now().UTC())
+	}
+}
+
+// finish ends the probe with the run. A run that breached its threshold
+// without any backend event stores its elapsed time as a lower bound.
+func (p *firstEventProbe) finish() {
+	p.mu.Lock()
+	p.finished = true
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	breachedSilently := !p.seen && p.sampled
+	p.mu.Unlock()
+	if breachedSilently {
+		p.persist(time.Since(p.start))
+	}
+}
+
+func (p *firstEventProbe) persist(latency time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.s.ledger.SetRunFirstEventLatency(ctx, p.runID, latency); err != nil {
+		log.Printf("warn: record first event latency run=%s: %v", p.runID, err)
+	}
+}
+
+// NewWebhookAlertSink POSTs each alert as JSON to url, signed with signer
+// when one is configured.
+func NewWebhookAlertSink(url string, signer *signing.Signer) AlertSink {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return func(ctx context.Context, alert SLOAlert) {
+		if err := signing.PostJSON(ctx, client, url, signer, map[string]any{
+			"event": alert.Event,
+			"alert": alert,
+		}); err != nil {
+			log.Printf("slo alert notify: %v", err)
+		}
+	}
+}
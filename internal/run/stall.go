@@ -0,0 +1,137 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"echohelix/internal/driver"
+	"echohelix/internal/events"
+)
+
+var ErrRunStalled = errors.New("stalled: no events from the backend")
+
+// StallPolicy configures the watchdog for runs that stop producing events.
+// After WarnAfter without an event the run gets a status event with
+// reason=stalled (with the adapter's health when ProbeHealth is set); after
+// CancelAfter it fails with ErrRunStalled. Zero disables either step.
+type StallPolicy struct {
+	WarnAfter   time.Duration
+	CancelAfter time.Duration
+	ProbeHealth bool
+}
+
+func (s *Service) SetStallPolicy(p StallPolicy) {
+	s.mu.Lock()
+	s.stall = p
+	s.mu.Unlock()
+}
+
+// stallWatch tracks one run's time since its last event. Paused and
+// interactive runs are legitimately quiet and are never flagged.
+type stallWatch struct {
+	policy  StallPolicy
+	timer   *time.Timer
+	last    time.Time
+	warned  bool
+	enabled bool
+}
+
+func (s *Service) newStallWatch(r Run) *stallWatch {
+	s.mu.Lock()
+	p := s.stall
+	s.mu.Unlock()
+	w := &stallWatch{policy: p, last: time.Now()}
+	if r.Options.Interactive || (p.WarnAfter <= 0 && p.CancelAfter <= 0) {
+		return w
+	}
+	w.enabled = true
+	w.timer = time.NewTimer(w.next())
+	return w
+}
+
+// C fires when the run reaches its next threshold; nil when disabled.
+func (w *stallWatch) C() <-chan time.Time {
+	if !w.enabled {
+		return nil
+	}
+	return w.timer.C
+}
+
+func (w *stallWatch) stop() {
+	if w.enabled {
+		w.timer.Stop()
+	}
+}
+
+// next is the wait until the next threshold counted from the last event.
+func (w *stallWatch) next() time.Duration {
+	at := w.policy.CancelAfter
+	if !w.warned && w.policy.WarnAfter > 0 && (at <= 0 || w.policy.WarnAfter < at) {
+		at = w.policy.WarnAfter
+	}
+	return time.Until(w.last.Add(at))
+}
+
+func (w *stallWatch) rearm() {
+	if !w.timer.Stop() {
+		select {
+		case <-w.timer.C:
+		default:
+		}
+	}
+	w.timer.Reset(w.next())
+}
+
+// observe records an event and reports whether the run had been flagged
+// as stalled.
+func (w *stallWatch) observe() bool {
+	if !w.enabled {
+		return false
+	}
+	recovered := w.warned
+	w.last = time.Now()
+	w.warned = false
+	w.rearm()
+	return recovered
+}
+
+// stallFired handles the timer for run r and reports whether the run
+// should be cancelled.
+func (s *Service) stallFired(ctx context.Context, w *stallWatch, r Run, drv driver.Driver) bool {
+	idle := time.Since(w.last)
+	if s.currentStatus(r.ID) == StatusPaused {
+		// The clock restarts once the run resumes.
+		w.last = time.Now()
+		w.warned = false
+		w.timer.Reset(w.next())
+		return false
+	}
+	if w.policy.CancelAfter > 0 && idle >= w.policy.CancelAfter {
+		return true
+	}
+	w.warned = true
+	warning := events.StatusPayload{
+		Status:      s.currentStatus(r.ID),
+		Reason:      "stalled",
+		IdleSeconds: int64(idle / time.Second),
+	}
+	if w.policy.CancelAfter > 0 {
+		cancelAt := w.last.Add(w.policy.CancelAfter).UTC()
+		warning.CancelAt = &cancelAt
+	}
+	if w.policy.ProbeHealth {
+		hctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		h, err := drv.Health(hctx)
+		cancel()
+		warning.AdapterHealth = &events.AdapterHealth{OK: err == nil && h.OK, Message: h.Message}
+		if err != nil {
+			warning.AdapterHealth.Message = err.Error()
+		}
+	}
+	s.emit(ctx, r.ID, r.Backend, "bridge", events.TypeStatus, events.PayloadMap(warning))
+	if w.policy.CancelAfter > 0 {
+		w.timer.Reset(w.next())
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package run
+
+import (
+	"context"
+	"time"
+
+	"echohelix/internal/driver"
+)
+
+const (
+	BackendHealthy  = "healthy"
+	BackendDegraded = "degraded"
+	BackendDown     = "down"
+)
+
+// BackendState is the coarse health of one backend, without messages or
+// adapter details.
+type BackendState struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// StatusSummary is an anonymized snapshot of the bridge for status pages.
+type StatusSummary struct {
+	Backends      []BackendState `json:"backends"`
+	EmergencyStop bool           `json:"emergency_stop"`
+	QueuedRuns    int            `json:"queued_runs"`
+	ActiveRuns    int            `json:"active_runs"`
+}
+
+// StatusSummary probes every backend and reports queue depth. A backend
+// whose health check passes but whose adapter has recent failures is
+// reported degraded.
+func (s *Service) StatusSummary(ctx context.Context) StatusSummary {
+	var out StatusSummary
+	for _, d := range s.registry.All() {
+		state, _ := gradeBackend(ctx, d)
+		out.Backends = append(out.Backends, BackendState{Name: d.Name(), State: state})
+	}
+	s.mu.Lock()
+	out.EmergencyStop = s.emergency.Active
+	s.mu.Unlock()
+	out.ActiveRuns, out.QueuedRuns = s.runCounts()
+	return out
+}
+
+// gradeBackend checks d's health with a short timeout and grades it.
+func gradeBackend(ctx context.Context, d driver.Driver) (string, driver.Health) {
+	hctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	h, err := d.Health(hctx)
+	cancel()
+	if err != nil {
+		h = driver.Health{OK: false, Message: err.Error()}
+	}
+	state := BackendHealthy
+	if !h.OK {
+		state = BackendDown
+	} else if sup := adapterSupervisor(d); sup != nil {
+		st := sup.Status()
+		if (!st.Healthy && st.LastCheckAt != nil) || st.CrashLoop || st.ConsecutiveFailures > 0 {
+			state = BackendDegraded
+		}
+	}
+	return state, h
+}
+
+// runCounts reports runs executing and runs waiting for a slot.
+func (s *Service) runCounts() (active, queued int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ar := range s.active {
+		if !isTerminalStatus(ar.status) {
+			active++
+		}
+	}
+	for id := range s.queued {
+		if _, ok := s.active[id]; !ok {
+			queued++
+		}
+	}
+	return active, queued
+}
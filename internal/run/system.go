@@ -0,0 +1,114 @@
+package run
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"echohelix/internal/adapter/supervisor"
+)
+
+// BackendHealth is one backend's health for operators, with the adapter's
+// message and supervisor state that the public status page leaves out.
+type BackendHealth struct {
+	Name    string             `json:"name"`
+	State   string             `json:"state"`
+	OK      bool               `json:"ok"`
+	Message string             `json:"message,omitempty"`
+	Adapter *supervisor.Status `json:"adapter,omitempty"`
+}
+
+type LedgerStatus struct {
+	Backend   string `json:"backend"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+// StorageStatus is the file store's on-disk size next to what the ledger
+// records for uploads and the attachments copied into workspaces.
+type StorageStatus struct {
+	FileStoreDir    string             `json:"file_store_dir"`
+	FileStoreBytes  int64              `json:"file_store_bytes"`
+	Files           FileStorageSummary `json:"files"`
+	AttachmentCount int64              `json:"attachment_count"`
+	AttachmentBytes int64              `json:"attachment_bytes"`
+}
+
+type QuotaStatus struct {
+	Enforcement string           `json:"enforcement"`
+	Items       []TokenQuotaItem `json:"items"`
+}
+
+// SystemStatus is the run side of GET /api/v3/system/status.
+type SystemStatus struct {
+	ActiveRuns int             `json:"active_runs"`
+	QueuedRuns int             `json:"queued_runs"`
+	Backends   []BackendHealth `json:"backends"`
+	Emergency  EmergencyState  `json:"emergency"`
+	Quotas     QuotaStatus     `json:"quotas"`
+	Ledger     LedgerStatus    `json:"ledger"`
+	Storage    StorageStatus   `json:"storage"`
+}
+
+func (s *Service) SystemStatus(ctx context.Context) (SystemStatus, error) {
+	var out SystemStatus
+	for _, d := range s.registry.All() {
+		state, h := gradeBackend(ctx, d)
+		entry := BackendHealth{Name: d.Name(), State: state, OK: h.OK, Message: h.Message}
+		if sup := adapterSupervisor(d); sup != nil {
+			st := sup.Status()
+			entry.Adapter = &st
+		}
+		out.Backends = append(out.Backends, entry)
+	}
+	out.ActiveRuns, out.QueuedRuns = s.runCounts()
+	out.Emergency = s.EmergencyStatus()
+
+	s.mu.Lock()
+	out.Quotas.Enforcement = s.quotaEnforcement
+	out.Storage.FileStoreDir = s.fileStoreDir
+	s.mu.Unlock()
+	items, err := s.TokenQuota(ctx, time.Now().UTC(), "")
+	if err != nil {
+		return SystemStatus{}, err
+	}
+	out.Quotas.Items = items
+
+	out.Ledger.Backend = s.ledger.Backend()
+	if out.Ledger.SizeBytes, err = s.ledger.SizeBytes(ctx); err != nil {
+		return SystemStatus{}, err
+	}
+	files, err := s.ledger.SummarizeFileStorage(ctx)
+	if err != nil {
+		return SystemStatus{}, err
+	}
+	out.Storage.Files = FileStorageSummary{
+		FileCount:     files.FileCount,
+		TotalBytes:    files.TotalBytes,
+		OrphanedCount: files.OrphanedCount,
+		OrphanedBytes: files.OrphanedBytes,
+	}
+	if out.Storage.AttachmentCount, out.Storage.AttachmentBytes, err = s.ledger.SummarizeAttachments(ctx); err != nil {
+		return SystemStatus{}, err
+	}
+	out.Storage.FileStoreBytes = dirSize(out.Storage.FileStoreDir)
+	return out, nil
+}
+
+// dirSize sums the regular files under dir; unreadable entries and a
+// missing dir count as zero.
+func dirSize(dir string) int64 {
+	var total int64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
@@ -44,6 +44,10 @@ func classifyFailureCode(errText string) string {
 	switch {
 	case s == "":
 		return "backend_error"
+	case strings.HasPrefix(s, "orphaned"):
+		return "orphaned"
+	case strings.HasPrefix(s, "stalled"):
+		return "stalled"
 	case strings.Contains(s, "deadline exceeded"), strings.Contains(s, "timeout"):
 		return "timeout"
 	case strings.Contains(s, "cancelled"), strings.Contains(s, "canceled"):
@@ -67,6 +71,10 @@ func defaultFailureReason(code string) string {
 		return "adapter emitted invalid event contract"
 	case "policy_denied":
 		return "run blocked by bridge policy"
+	case "orphaned":
+		return "run was queued but never started"
+	case "stalled":
+		return "run stopped producing events"
 	default:
 		return "backend run failed"
 	}
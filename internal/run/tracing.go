@@ -0,0 +1,27 @@
+package run
+
+import (
+	"context"
+	"errors"
+
+	"echohelix/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// endRunSpan tags the run.execute span with the terminal status from the
+// ledger and marks failed runs as errors.
+func (s *Service) endRunSpan(span trace.Span, runID string) {
+	rec, err := s.ledger.GetRun(context.Background(), runID)
+	if err != nil {
+		tracing.End(span, err)
+		return
+	}
+	span.SetAttributes(attribute.String("run.status", rec.Status))
+	if rec.Status == StatusFailed {
+		tracing.End(span, errors.New(rec.Error))
+		return
+	}
+	span.End()
+}
@@ -27,37 +27,109 @@ func (s *Service) SetDailyTokenQuota(quotas map[string]int64) {
 }
 
 func (s *Service) TokenUsage(ctx context.Context, from, to time.Time, backend string) (TokenUsageSummary, error) {
+	return s.TokenUsageBy(ctx, from, to, backend, "")
+}
+
+// TokenUsageBy reports usage per backend and, for groupBy "device" or
+// "workspace", additionally per submitting device or workspace.
+func (s *Service) TokenUsageBy(ctx context.Context, from, to time.Time, backend, groupBy string) (TokenUsageSummary, error) {
 	if from.IsZero() || to.IsZero() || !from.Before(to) {
 		return TokenUsageSummary{}, fmt.Errorf("invalid time range")
 	}
-	aggs, err := s.ledger.AggregateTokenUsage(ctx, from, to, backend)
+	groupBy = strings.ToLower(strings.TrimSpace(groupBy))
+	switch groupBy {
+	case "", "backend":
+		groupBy = "backend"
+	case "device", "workspace":
+	default:
+		return TokenUsageSummary{}, fmt.Errorf("invalid group_by %q", groupBy)
+	}
+	aggs, err := s.ledger.AggregateTokenUsageByModel(ctx, from, to, backend)
 	if err != nil {
 		return TokenUsageSummary{}, err
 	}
+	priced := s.pricingConfigured()
 	out := TokenUsageSummary{
 		From:      from.UTC(),
 		To:        to.UTC(),
+		GroupBy:   groupBy,
 		ByBackend: make([]TokenUsageByBackend, 0, len(aggs)),
 	}
 	for _, agg := range aggs {
-		item := TokenUsageByBackend{
-			Backend: agg.Backend,
-			TokenUsageTotals: TokenUsageTotals{
-				RunCount:     agg.RunCount,
-				InputTokens:  agg.InputTokens,
-				OutputTokens: agg.OutputTokens,
-				TotalTokens:  agg.TotalTokens,
-			},
+		n := len(out.ByBackend)
+		if n == 0 || out.ByBackend[n-1].Backend != agg.Backend {
+			out.ByBackend = append(out.ByBackend, TokenUsageByBackend{Backend: agg.Backend})
+			n++
+		}
+		s.addUsage(&out.ByBackend[n-1].TokenUsageTotals, agg, priced)
+	}
+	for i := range out.ByBackend {
+		item := &out.ByBackend[i]
+		roundUsageCost(item.TokenUsageTotals.CostUSD)
+		addTotals(&out.Totals, item.TokenUsageTotals)
+	}
+	if groupBy == "backend" {
+		return out, nil
+	}
+
+	grouped, err := s.ledger.AggregateTokenUsageByGroup(ctx, from, to, backend, groupBy)
+	if err != nil {
+		return TokenUsageSummary{}, err
+	}
+	out.Groups = make([]TokenUsageGroup, 0, len(grouped))
+	for _, agg := range grouped {
+		n := len(out.Groups)
+		if n == 0 || out.Groups[n-1].Key != agg.Group {
+			out.Groups = append(out.Groups, TokenUsageGroup{Key: agg.Group})
+			n++
 		}
-		out.ByBackend = append(out.ByBackend, item)
-		out.Totals.RunCount += agg.RunCount
-		out.Totals.InputTokens += agg.InputTokens
-		out.Totals.OutputTokens += agg.OutputTokens
-		out.Totals.TotalTokens += agg.TotalTokens
+		s.addUsage(&out.Groups[n-1].TokenUsageTotals, agg, priced)
+	}
+	for i := range out.Groups {
+		roundUsageCost(out.Groups[i].CostUSD)
 	}
 	return out, nil
 }
 
+func (s *Service) addUsage(t *TokenUsageTotals, agg ledger.TokenUsageAggregate, priced bool) {
+	t.RunCount += agg.RunCount
+	t.InputTokens += agg.InputTokens
+	t.OutputTokens += agg.OutputTokens
+	t.TotalTokens += agg.TotalTokens
+	if !priced {
+		return
+	}
+	if t.CostUSD == nil {
+		t.CostUSD = new(float64)
+	}
+	if price, ok := s.priceFor(agg.Backend, agg.Model); ok {
+		*t.CostUSD += price.cost(agg.InputTokens, agg.OutputTokens)
+	} else {
+		t.UnpricedRuns += agg.RunCount
+	}
+}
+
+func addTotals(dst *TokenUsageTotals, src TokenUsageTotals) {
+	dst.RunCount += src.RunCount
+	dst.InputTokens += src.InputTokens
+	dst.OutputTokens += src.OutputTokens
+	dst.TotalTokens += src.TotalTokens
+	if src.CostUSD == nil {
+		return
+	}
+	if dst.CostUSD == nil {
+		dst.CostUSD = new(float64)
+	}
+	*dst.CostUSD = roundUSD(*dst.CostUSD + *src.CostUSD)
+	dst.UnpricedRuns += src.UnpricedRuns
+}
+
+func roundUsageCost(cost *float64) {
+	if cost != nil {
+		*cost = roundUSD(*cost)
+	}
+}
+
 func (s *Service) TokenQuota(ctx context.Context, now time.Time, backend string) ([]TokenQuotaItem, error) {
 	if now.IsZero() {
 		now = time.Now().UTC()
@@ -132,14 +204,16 @@ func (s *Service) snapshotDailyTokenQuota() map[string]int64 {
 	return out
 }
 
-func (s *Service) recordTokenUsage(ctx context.Context, runID, backend string, payload map[string]any) {
+func (s *Service) recordTokenUsage(ctx context.Context, r Run, payload map[string]any) {
 	usage, ok := parseTokenUsage(payload)
 	if !ok {
 		return
 	}
 	_ = s.ledger.UpsertTokenUsage(ctx, ledger.TokenUsageRecord{
-		RunID:        runID,
-		Backend:      backend,
+		RunID:        r.ID,
+		Backend:      r.Backend,
+		WorkspaceID:  r.WorkspaceID,
+		SubmittedBy:  r.SubmittedBy,
 		InputTokens:  usage.InputTokens,
 		OutputTokens: usage.OutputTokens,
 		TotalTokens:  usage.TotalTokens,
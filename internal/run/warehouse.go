@@ -0,0 +1,333 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/parquet-go/parquet-go"
+
+	"echohelix/internal/ledger"
+)
+
+const (
+	WarehouseExportRunning   = "running"
+	WarehouseExportSucceeded = "succeeded"
+	WarehouseExportFailed    = "failed"
+
+	maxWarehouseExportJobs = 50
+)
+
+// WarehouseExportConfig says where Parquet exports go. Files are always
+// written under Dir/<job_id>/; with UploadURL set each file is also PUT to
+// UploadURL/<job_id>/<name>, e.g. an object storage bucket endpoint or
+// gateway, with UploadToken as bearer token when present.
+type WarehouseExportConfig struct {
+	Dir         string
+	UploadURL   string
+	UploadToken string
+}
+
+type WarehouseExportJob struct {
+	ID         string                `json:"export_id"`
+	Status     string                `json:"status"`
+	From       time.Time             `json:"from"`
+	To         time.Time             `json:"to"`
+	Files      []WarehouseExportFile `json:"files,omitempty"`
+	Error      string                `json:"error,omitempty"`
+	CreatedAt  time.Time             `json:"created_at"`
+	FinishedAt time.Time             `json:"finished_at,omitempty"`
+}
+
+type WarehouseExportFile struct {
+	Table    string `json:"table"`
+	Path     string `json:"path"`
+	Location string `json:"location,omitempty"`
+	Rows     int    `json:"rows"`
+	Bytes    int64  `json:"bytes"`
+}
+
+type warehouseExports struct {
+	mu   sync.Mutex
+	cfg  WarehouseExportConfig
+	jobs map[string]*WarehouseExportJob
+}
+
+type warehouseRunRow struct {
+	RunID         string    `parquet:"run_id"`
+	WorkspaceID   string    `parquet:"workspace_id"`
+	WorkspacePath string    `parquet:"workspace_path"`
+	Backend       string    `parquet:"backend"`
+	Model         string    `parquet:"model"`
+	Profile       string    `parquet:"profile"`
+	Sandbox       string    `parquet:"sandbox"`
+	Status        string    `parquet:"status"`
+	Error         string    `parquet:"error"`
+	SubmittedBy   string    `parquet:"submitted_by"`
+	CreatedAt     time.Time `parquet:"created_at,timestamp(millisecond)"`
+	UpdatedAt     time.Time `parquet:"updated_at,timestamp(millisecond)"`
+}
+
+type warehouseUsageRow struct {
+	RunID        string    `parquet:"run_id"`
+	Backend      string    `parquet:"backend"`
+	WorkspaceID  string    `parquet:"workspace_id"`
+	SubmittedBy  string    `parquet:"submitted_by"`
+	InputTokens  int64     `parquet:"input_tokens"`
+	OutputTokens int64     `parquet:"output_tokens"`
+	TotalTokens  int64     `parquet:"total_tokens"`
+	RecordedAt   time.Time `parquet:"recorded_at,timestamp(millisecond)"`
+}
+
+type warehouseAuditRow struct {
+	TS     time.Time `parquet:"ts,timestamp(millisecond)"`
+	Event  string    `parquet:"event"`
+	Actor  string    `parquet:"actor"`
+	IP     string    `parquet:"ip"`
+	Method string    `parquet:"method"`
+	Path   string    `parquet:"path"`
+	Detail string    `parquet:"detail"`
+}
+
+func (s *Service) SetWarehouseExport(cfg WarehouseExportConfig) {
+	cfg.UploadURL = strings.TrimRight(strings.TrimSpace(cfg.UploadURL), "/")
+	s.warehouse.mu.Lock()
+	s.warehouse.cfg = cfg
+	s.warehouse.mu.Unlock()
+}
+
+// RecordAudit stores an audit event so it can be exported later.
+func (s *Service) RecordAudit(ctx context.Context, rec ledger.AuditRecord) error {
+	return s.ledger.AppendAudit(ctx, rec)
+}
+
+// StartWarehouseExport writes runs created, usage recorded and audit events
+// logged in [from, to) to Parquet files in the background.
+func (s *Service) StartWarehouseExport(from, to time.Time) (WarehouseExportJob, error) {
+	if !to.After(from) {
+		return WarehouseExportJob{}, fmt.Errorf("to must be after from")
+	}
+	s.warehouse.mu.Lock()
+	cfg := s.warehouse.cfg
+	if strings.TrimSpace(cfg.Dir) == "" {
+		s.warehouse.mu.Unlock()
+		return WarehouseExportJob{}, fmt.Errorf("warehouse export directory is not configured")
+	}
+	job := &WarehouseExportJob{
+		ID:        uuid.NewString(),
+		Status:    WarehouseExportRunning,
+		From:      from.UTC(),
+		To:        to.UTC(),
+		CreatedAt: time.Now().UTC(),
+	}
+	if s.warehouse.jobs == nil {
+		s.warehouse.jobs = map[string]*WarehouseExportJob{}
+	}
+	s.warehouse.jobs[job.ID] = job
+	s.pruneWarehouseJobsLocked()
+	out := *job
+	s.warehouse.mu.Unlock()
+
+	go func() {
+		files, err := s.runWarehouseExport(context.Background(), cfg, out)
+		s.warehouse.mu.Lock()
+		defer s.warehouse.mu.Unlock()
+		job.Files = files
+		job.FinishedAt = time.Now().UTC()
+		if err != nil {
+			log.Printf("warehouse export id=%s: %v", job.ID, err)
+			job.Status = WarehouseExportFailed
+			job.Error = err.Error()
+			return
+		}
+		job.Status = WarehouseExportSucceeded
+	}()
+	return out, nil
+}
+
+func (s *Service) GetWarehouseExport(id string) (WarehouseExportJob, bool) {
+	s.warehouse.mu.Lock()
+	defer s.warehouse.mu.Unlock()
+	job, ok := s.warehouse.jobs[id]
+	if !ok {
+		return WarehouseExportJob{}, false
+	}
+	return copyWarehouseJob(job), true
+}
+
+// ListWarehouseExports returns retained jobs, newest first.
+func (s *Service) ListWarehouseExports() []WarehouseExportJob {
+	s.warehouse.mu.Lock()
+	defer s.warehouse.mu.Unlock()
+	out := make([]WarehouseExportJob, 0, len(s.warehouse.jobs))
+	for _, job := range s.warehouse.jobs {
+		out = append(out, copyWarehouseJob(job))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out
+}
+
+func copyWarehouseJob(job *WarehouseExportJob) WarehouseExportJob {
+	out := *job
+	out.Files = append([]WarehouseExportFile(nil), job.Files...)
+	return out
+}
+
+// pruneWarehouseJobsLocked forgets the oldest finished jobs beyond the
+// retention cap. Exported files are left in place.
+func (s *Service) pruneWarehouseJobsLocked() {
+	if len(s.warehouse.jobs) <= maxWarehouseExportJobs {
+		return
+	}
+	finished := make([]*WarehouseExportJob, 0, len(s.warehouse.jobs))
+	for _, job := range s.warehouse.jobs {
+		if job.Status != WarehouseExportRunning {
+			finished = append(finished, job)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].CreatedAt.Before(finished[j].CreatedAt) })
+	for _, job := range finished {
+		if len(s.warehouse.jobs) <= maxWarehouseExportJobs {
+			return
+		}
+		delete(s.warehouse.jobs, job.ID)
+	}
+}
+
+func (s *Service) runWarehouseExport(ctx context.Context, cfg WarehouseExportConfig, job WarehouseExportJob) ([]WarehouseExportFile, error) {
+	dir := filepath.Join(cfg.Dir, job.ID)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("prepare export dir: %w", err)
+	}
+
+	runs, err := s.ledger.ListRunsCreatedBetween(ctx, job.From, job.To)
+	if err != nil {
+		return nil, fmt.Errorf("list runs: %w", err)
+	}
+	runRows := make([]warehouseRunRow, 0, len(runs))
+	for _, r := range runs {
+		runRows = append(runRows, warehouseRunRow{
+			RunID:         r.ID,
+			WorkspaceID:   r.WorkspaceID,
+			WorkspacePath: r.Workspace,
+			Backend:       r.Backend,
+			Model:         r.Options.Model,
+			Profile:       r.Options.Profile,
+			Sandbox:       r.Options.Sandbox,
+			Status:        r.Status,
+			Error:         r.Error,
+			SubmittedBy:   r.SubmittedBy,
+			CreatedAt:     r.CreatedAt,
+			UpdatedAt:     r.UpdatedAt,
+		})
+	}
+	usage, err := s.ledger.ListTokenUsage(ctx, job.From, job.To)
+	if err != nil {
+		return nil, fmt.Errorf("list usage: %w", err)
+	}
+	usageRows := make([]warehouseUsageRow, 0, len(usage))
+	for _, u := range usage {
+		usageRows = append(usageRows, warehouseUsageRow{
+			RunID:        u.RunID,
+			Backend:      u.Backend,
+			WorkspaceID:  u.WorkspaceID,
+			SubmittedBy:  u.SubmittedBy,
+			InputTokens:  u.InputTokens,
+			OutputTokens: u.OutputTokens,
+			TotalTokens:  u.TotalTokens,
+			RecordedAt:   u.RecordedAt,
+		})
+	}
+	audit, err := s.ledger.ListAudit(ctx, job.From, job.To)
+	if err != nil {
+		return nil, fmt.Errorf("list audit events: %w", err)
+	}
+	auditRows := make([]warehouseAuditRow, 0, len(audit))
+	for _, a := range audit {
+		auditRows = append(auditRows, warehouseAuditRow(a))
+	}
+
+	files := make([]WarehouseExportFile, 0, 3)
+	for _, write := range []func() (WarehouseExportFile, error){
+		func() (WarehouseExportFile, error) { return writeParquetTable(dir, "runs", runRows) },
+		func() (WarehouseExportFile, error) { return writeParquetTable(dir, "usage", usageRows) },
+		func() (WarehouseExportFile, error) { return writeParquetTable(dir, "audit", auditRows) },
+	} {
+		f, err := write()
+		if err != nil {
+			return files, err
+		}
+		if cfg.UploadURL != "" {
+			loc := cfg.UploadURL + "/" + job.ID + "/" + filepath.Base(f.Path)
+			if err := uploadExportFile(ctx, f.Path, loc, cfg.UploadToken); err != nil {
+				return files, fmt.Errorf("upload %s: %w", f.Table, err)
+			}
+			f.Location = loc
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+func writeParquetTable[T any](dir, table string, rows []T) (WarehouseExportFile, error) {
+	path := filepath.Join(dir, table+".parquet")
+	out := WarehouseExportFile{Table: table, Path: path, Rows: len(rows)}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return out, err
+	}
+	defer f.Close()
+	w := parquet.NewGenericWriter[T](f, parquet.Compression(&parquet.Zstd))
+	if _, err := w.Write(rows); err != nil {
+		return out, fmt.Errorf("write %s: %w", table, err)
+	}
+	if err := w.Close(); err != nil {
+		return out, fmt.Errorf("write %s: %w", table, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return out, err
+	}
+	out.Bytes = info.Size()
+	return out, f.Close()
+}
+
+func uploadExportFile(ctx context.Context, path, location, token string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, location, f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/vnd.apache.parquet")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("object storage returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package run
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"echohelix/internal/events"
+	"echohelix/internal/ledger"
+)
+
+func TestWarehouseExportWritesParquetTables(t *testing.T) {
+	drv := newFakeDriver("codex", false)
+	drv.script = []events.Event{
+		{
+			Type: events.TypeDone,
+			Payload: map[string]any{
+				"status": "completed",
+				"usage":  map[string]any{"input_tokens": 3, "output_tokens": 2, "total_tokens": 5},
+			},
+			Source: "fake",
+		},
+	}
+	svc := setupService(t, drv)
+
+	var mu sync.Mutex
+	uploads := map[string]int{}
+	objectStore := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPut || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		uploads[r.URL.Path] = len(body)
+		mu.Unlock()
+	}))
+	defer objectStore.Close()
+	svc.SetWarehouseExport(WarehouseExportConfig{Dir: t.TempDir(), UploadURL: objectStore.URL + "/bucket/", UploadToken: "secret"})
+
+	r, err := svc.Submit(context.Background(), SubmitRequest{
+		WorkspaceID:   "ws-1",
+		WorkspacePath: "/tmp",
+		Backend:       "codex",
+		Prompt:        "export me",
+		Options:       RunOptions{Model: "gpt-5"},
+	})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, r.ID, StatusCompleted)
+	if err := svc.RecordAudit(context.Background(), ledger.AuditRecord{Event: "device_revoke", Actor: "admin", Detail: "address=x"}); err != nil {
+		t.Fatalf("record audit: %v", err)
+	}
+
+	now := time.Now().UTC()
+	job, err := svc.StartWarehouseExport(now.Add(-time.Hour), now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("start export: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Status == WarehouseExportRunning && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		job, _ = svc.GetWarehouseExport(job.ID)
+	}
+	if job.Status != WarehouseExportSucceeded || len(job.Files) != 3 {
+		t.Fatalf("unexpected export job: %#v", job)
+	}
+
+	runs, err := parquet.ReadFile[warehouseRunRow](job.Files[0].Path)
+	if err != nil {
+		t.Fatalf("read runs: %v", err)
+	}
+	if len(runs) != 1 || runs[0].RunID != r.ID || runs[0].Model != "gpt-5" || runs[0].Status != StatusCompleted {
+		t.Fatalf("unexpected run rows: %#v", runs)
+	}
+	usage, err := parquet.ReadFile[warehouseUsageRow](job.Files[1].Path)
+	if err != nil {
+		t.Fatalf("read usage: %v", err)
+	}
+	if len(usage) != 1 || usage[0].TotalTokens != 5 || usage[0].WorkspaceID != "ws-1" {
+		t.Fatalf("unexpected usage rows: %#v", usage)
+	}
+	audit, err := parquet.ReadFile[warehouseAuditRow](job.Files[2].Path)
+	if err != nil {
+		t.Fatalf("read audit: %v", err)
+	}
+	if len(audit) != 1 || audit[0].Event != "device_revoke" || audit[0].Actor != "admin" {
+		t.Fatalf("unexpected audit rows: %#v", audit)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, f := range job.Files {
+		if size, ok := uploads["/bucket/"+job.ID+"/"+f.Table+".parquet"]; !ok || int64(size) != f.Bytes {
+			t.Fatalf("file %s not uploaded intact: %v", f.Table, uploads)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package run
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrWorkspaceFileNotFound = errors.New("workspace file not found")
+
+// maxWorkspaceFileBytes bounds the content returned for one file; longer
+// files are cut and marked truncated.
+const maxWorkspaceFileBytes = 1 << 20
+
+// WorkspaceFile is one file read from a workspace. Text is returned as
+// utf-8, anything else base64 encoded.
+type WorkspaceFile struct {
+	WorkspaceID string `json:"workspace_id"`
+	Path        string `json:"path"`
+	Size        int64  `json:"size"`
+	Encoding    string `json:"encoding"`
+	Content     string `json:"content"`
+	Truncated   bool   `json:"truncated"`
+}
+
+// ReadWorkspaceFile reads a file by its path relative to the workspace.
+// Symlinks are resolved and must stay inside the workspace.
+func (s *Service) ReadWorkspaceFile(ctx context.Context, workspaceID, relPath string) (WorkspaceFile, error) {
+	rel, err := cleanGitPath(relPath)
+	if err != nil {
+		return WorkspaceFile{}, err
+	}
+	if rel == "" || rel == "." {
+		return WorkspaceFile{}, ErrGitPathOutsideWorkspace
+	}
+	dir, err := s.workspaceDir(ctx, workspaceID)
+	if err != nil {
+		return WorkspaceFile{}, err
+	}
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return WorkspaceFile{}, ErrWorkspaceNotFound
+	}
+	full, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(rel)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return WorkspaceFile{}, ErrWorkspaceFileNotFound
+	}
+	if err != nil {
+		return WorkspaceFile{}, err
+	}
+	if inside, err := filepath.Rel(root, full); err != nil || inside == ".." || strings.HasPrefix(inside, ".."+string(filepath.Separator)) {
+		return WorkspaceFile{}, ErrGitPathOutsideWorkspace
+	}
+	f, err := os.Open(full)
+	if err != nil {
+		return WorkspaceFile{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return WorkspaceFile{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return WorkspaceFile{}, ErrWorkspaceFileNotFound
+	}
+	data, err := io.ReadAll(io.LimitReader(f, maxWorkspaceFileBytes))
+	if err != nil {
+		return WorkspaceFile{}, err
+	}
+	out := WorkspaceFile{
+		WorkspaceID: workspaceID,
+		Path:        rel,
+		Size:        info.Size(),
+		Encoding:    "utf-8",
+		Truncated:   info.Size() > int64(len(data)),
+	}
+	if utf8.Valid(data) {
+		out.Content = string(data)
+	} else {
+		out.Encoding = "base64"
+		out.Content = base64.StdEncoding.EncodeToString(data)
+	}
+	return out, nil
+}
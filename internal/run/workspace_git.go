@@ -0,0 +1,409 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"echohelix/internal/events"
+	"echohelix/internal/ledger"
+)
+
+var (
+	ErrWorkspaceNotFound       = errors.New("workspace not found")
+	ErrWorkspaceNotAllowed     = errors.New("workspace not allowed by policy")
+	ErrNotGitRepository        = errors.New("workspace is not a git repository")
+	ErrGitPathOutsideWorkspace = errors.New("path must be relative to the workspace")
+)
+
+const (
+	gitCommandTimeout = 15 * time.Second
+	// maxGitDiffBytes bounds the diff text of one response; files past it
+	// are left out and the response is marked truncated.
+	maxGitDiffBytes = 2 << 20
+	// emptyTreeHash is git's well-known empty tree, the diff base for
+	// repositories without commits.
+	emptyTreeHash = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+)
+
+type GitFileStatus struct {
+	Path     string `json:"path"`
+	OrigPath string `json:"orig_path,omitempty"`
+	// Index and Worktree are the porcelain status letters.
+	Index    string `json:"index"`
+	Worktree string `json:"worktree"`
+	Status   string `json:"status"`
+}
+
+type WorkspaceGitStatus struct {
+	WorkspaceID string          `json:"workspace_id"`
+	Branch      string          `json:"branch,omitempty"`
+	Upstream    string          `json:"upstream,omitempty"`
+	Ahead       int             `json:"ahead"`
+	Behind      int             `json:"behind"`
+	Clean       bool            `json:"clean"`
+	Files       []GitFileStatus `json:"files"`
+}
+
+// GitFileDiff is one file's unified diff, shaped like a patch event
+// payload (format "diff").
+type GitFileDiff struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	Format string `json:"format"`
+	Diff   string `json:"diff"`
+	Binary bool   `json:"binary,omitempty"`
+}
+
+type GitDiffQuery struct {
+	// Path limits the diff to a file or directory inside the workspace.
+	Path string
+	// Staged diffs the index against HEAD instead of the work tree.
+	Staged bool
+	// Untracked adds new-file diffs for untracked files (work tree only).
+	Untracked bool
+}
+
+type WorkspaceGitDiff struct {
+	WorkspaceID string        `json:"workspace_id"`
+	Staged      bool          `json:"staged"`
+	Files       []GitFileDiff `json:"files"`
+	Truncated   bool          `json:"truncated"`
+}
+
+// workspaceRepo resolves a workspace id to the path of its latest run and
+// checks it against the workspace policy and git.
+func (s *Service) workspaceRepo(ctx context.Context, workspaceID string) (string, error) {
+	path, err := s.workspaceDir(ctx, workspaceID)
+	if err != nil {
+		return "", err
+	}
+	if _, err := runGit(ctx, path, "rev-parse", "--is-inside-work-tree"); err != nil {
+		return "", ErrNotGitRepository
+	}
+	return path, nil
+}
+
+// workspaceDir resolves a workspace id to the path of its latest run and
+// checks it against the workspace policy.
+func (s *Service) workspaceDir(ctx context.Context, workspaceID string) (string, error) {
+	path, err := s.ledger.WorkspacePath(ctx, strings.TrimSpace(workspaceID))
+	if err != nil {
+		if errors.Is(err, ledger.ErrWorkspaceNotFound) {
+			return "", ErrWorkspaceNotFound
+		}
+		return "", err
+	}
+	// The policy may have changed since the run; re-check before reading.
+	if err := s.policy.ValidateWorkspace(path); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrWorkspaceNotAllowed, err)
+	}
+	return path, nil
+}
+
+func (s *Service) WorkspaceGitStatus(ctx context.Context, workspaceID string) (WorkspaceGitStatus, error) {
+	dir, err := s.workspaceRepo(ctx, workspaceID)
+	if err != nil {
+		return WorkspaceGitStatus{}, err
+	}
+	out, err := runGit(ctx, dir, "status", "--porcelain=v1", "--branch", "-z", "--untracked-files=all")
+	if err != nil {
+		return WorkspaceGitStatus{}, err
+	}
+	st := parseGitStatus(out)
+	st.WorkspaceID = workspaceID
+	return st, nil
+}
+
+func (s *Service) WorkspaceGitDiff(ctx context.Context, workspaceID string, q GitDiffQuery) (WorkspaceGitDiff, error) {
+	pathspec, err := cleanGitPath(q.Path)
+	if err != nil {
+		return WorkspaceGitDiff{}, err
+	}
+	dir, err := s.workspaceRepo(ctx, workspaceID)
+	if err != nil {
+		return WorkspaceGitDiff{}, err
+	}
+	base := "HEAD"
+	if _, err := runGit(ctx, dir, "rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		base = emptyTreeHash
+	}
+	args := []string{"diff", "--no-color", "--no-ext-diff", "--no-textconv", "--find-renames"}
+	if q.Staged {
+		args = append(args, "--cached")
+	}
+	args = append(args, base, "--")
+	if pathspec != "" {
+		args = append(args, pathspec)
+	}
+	out, err := runGit(ctx, dir, args...)
+	if err != nil {
+		return WorkspaceGitDiff{}, err
+	}
+	res := WorkspaceGitDiff{WorkspaceID: workspaceID, Staged: q.Staged, Files: []GitFileDiff{}}
+	size := 0
+	add := func(d GitFileDiff) bool {
+		if size+len(d.Diff) > maxGitDiffBytes {
+			res.Truncated = true
+			return false
+		}
+		size += len(d.Diff)
+		res.Files = append(res.Files, d)
+		return true
+	}
+	for _, d := range splitGitDiff(string(out)) {
+		if !add(d) {
+			return res, nil
+		}
+	}
+	if q.Staged || !q.Untracked {
+		return res, nil
+	}
+	lsArgs := []string{"ls-files", "--others", "--exclude-standard", "-z", "--"}
+	if pathspec != "" {
+		lsArgs = append(lsArgs, pathspec)
+	}
+	untracked, err := runGit(ctx, dir, lsArgs...)
+	if err != nil {
+		return WorkspaceGitDiff{}, err
+	}
+	for _, name := range strings.Split(strings.TrimRight(string(untracked), "\x00"), "\x00") {
+		if name == "" {
+			continue
+		}
+		// --no-index exits 1 when the files differ, which they always do.
+		out, err := runGit(ctx, dir, "diff", "--no-color", "--no-ext-diff", "--no-textconv", "--no-index", "--", os.DevNull, name)
+		var exitErr *exec.ExitError
+		if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+			return WorkspaceGitDiff{}, err
+		}
+		for _, d := range splitGitDiff(string(out)) {
+			d.Path, d.Status = name, "untracked"
+			if !add(d) {
+				return res, nil
+			}
+		}
+	}
+	return res, nil
+}
+
+func runGit(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	return runGitEnv(ctx, dir, nil, args...)
+}
+
+// gitHardening keeps the bridge's own git calls from running commands that
+// the agent can plant in the workspace's .git/config: no fsmonitor, hooks or
+// external diff. Filter drivers are switched off per name by gitFilterOverrides.
+var gitHardening = []string{
+	"-c", "core.quotepath=off",
+	"-c", "core.fsmonitor=false",
+	"-c", "core.hooksPath=" + os.DevNull,
+	"-c", "diff.external=",
+}
+
+// gitEnv leaves out system and global config, which the agent's host user
+// may also control, and keeps git from prompting. Status would otherwise
+// refresh the index and race the agent's own git commands for index.lock.
+var gitEnv = []string{
+	"GIT_CONFIG_NOSYSTEM=1",
+	"GIT_CONFIG_GLOBAL=" + os.DevNull,
+	"GIT_OPTIONAL_LOCKS=0",
+	"GIT_TERMINAL_PROMPT=0",
+}
+
+func runGitEnv(ctx context.Context, dir string, env []string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, gitCommandTimeout)
+	defer cancel()
+	overrides, err := gitFilterOverrides(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	argv := append(append(append([]string{"-C", dir}, gitHardening...), overrides...), args...)
+	return execGit(ctx, env, args[0], argv)
+}
+
+// gitFilterOverrides blanks every filter driver the repository configures,
+// since clean and smudge commands run on add, status, diff and checkout.
+// Reading the config runs nothing.
+func gitFilterOverrides(ctx context.Context, dir string) ([]string, error) {
+	out, err := execGit(ctx, nil, "config", []string{"-C", dir, "config", "--name-only", "--get-regexp", `^filter\.`})
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			// No filter keys.
+			return nil, nil
+		}
+		return nil, err
+	}
+	var overrides []string
+	seen := map[string]bool{}
+	for _, key := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		i := strings.LastIndexByte(key, '.')
+		if i <= len("filter.") {
+			continue
+		}
+		name := key[len("filter."):i]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		overrides = append(overrides,
+			"-c", "filter."+name+".clean=",
+			"-c", "filter."+name+".smudge=",
+			"-c", "filter."+name+".process=",
+			"-c", "filter."+name+".required=false",
+		)
+	}
+	return overrides, nil
+}
+
+func execGit(ctx context.Context, env []string, name string, argv []string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", argv...)
+	cmd.Env = append(append(os.Environ(), gitEnv...), env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return out, fmt.Errorf("git %s: %w: %s", name, err, msg)
+		}
+		return out, fmt.Errorf("git %s: %w", name, err)
+	}
+	return out, nil
+}
+
+func cleanGitPath(p string) (string, error) {
+	p = strings.TrimSpace(p)
+	if p == "" {
+		return "", nil
+	}
+	if filepath.IsAbs(p) || strings.HasPrefix(p, ":") {
+		return "", ErrGitPathOutsideWorkspace
+	}
+	p = filepath.ToSlash(filepath.Clean(p))
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return "", ErrGitPathOutsideWorkspace
+	}
+	return p, nil
+}
+
+// parseGitStatus reads `git status --porcelain=v1 --branch -z` output.
+func parseGitStatus(out []byte) WorkspaceGitStatus {
+	st := WorkspaceGitStatus{Files: []GitFileStatus{}}
+	fields := strings.Split(strings.TrimRight(string(out), "\x00"), "\x00")
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if strings.HasPrefix(f, "## ") {
+			parseGitBranch(&st, strings.TrimPrefix(f, "## "))
+			continue
+		}
+		if len(f) < 4 {
+			continue
+		}
+		x, y := f[0], f[1]
+		file := GitFileStatus{Path: f[3:], Index: string(x), Worktree: string(y), Status: gitStatusName(x, y)}
+		if (x == 'R' || x == 'C') && i+1 < len(fields) {
+			i++
+			file.OrigPath = fields[i]
+		}
+		st.Files = append(st.Files, file)
+	}
+	st.Clean = len(st.Files) == 0
+	return st
+}
+
+// parseGitBranch reads the "## " header, e.g. "main...origin/main [ahead 1,
+// behind 2]", "No commits yet on main" or "HEAD (no branch)".
+func parseGitBranch(st *WorkspaceGitStatus, h string) {
+	if rest, ok := strings.CutPrefix(h, "No commits yet on "); ok {
+		st.Branch = rest
+		return
+	}
+	if i := strings.Index(h, " ["); i >= 0 {
+		for _, part := range strings.Split(strings.TrimSuffix(h[i+2:], "]"), ", ") {
+			if n, ok := strings.CutPrefix(part, "ahead "); ok {
+				st.Ahead, _ = strconv.Atoi(n)
+			}
+			if n, ok := strings.CutPrefix(part, "behind "); ok {
+				st.Behind, _ = strconv.Atoi(n)
+			}
+		}
+		h = h[:i]
+	}
+	if h == "HEAD (no branch)" {
+		return
+	}
+	st.Branch, st.Upstream, _ = strings.Cut(h, "...")
+}
+
+func gitStatusName(x, y byte) string {
+	switch {
+	case x == '?':
+		return "untracked"
+	case x == 'U' || y == 'U' || (x == 'A' && y == 'A') || (x == 'D' && y == 'D'):
+		return "conflicted"
+	}
+	c := y
+	if c == ' ' {
+		c = x
+	}
+	switch c {
+	case 'A':
+		return "added"
+	case 'D':
+		return "deleted"
+	case 'R':
+		return "renamed"
+	case 'C':
+		return "copied"
+	case 'T':
+		return "type_changed"
+	default:
+		return "modified"
+	}
+}
+
+// splitGitDiff cuts unified diff output into one entry per file.
+func splitGitDiff(out string) []GitFileDiff {
+	var files []GitFileDiff
+	for _, chunk := range strings.SplitAfter(out, "\n") {
+		if strings.HasPrefix(chunk, "diff --git ") || len(files) == 0 {
+			files = append(files, GitFileDiff{Format: events.FormatDiff, Status: "modified"})
+		}
+		files[len(files)-1].Diff += chunk
+	}
+	out2 := files[:0]
+	for _, f := range files {
+		if strings.TrimSpace(f.Diff) == "" {
+			continue
+		}
+		lines := strings.Split(f.Diff, "\n")
+		if i := strings.LastIndex(lines[0], " b/"); i >= 0 {
+			f.Path = lines[0][i+3:]
+		}
+		for _, line := range lines[1:] {
+			switch {
+			case strings.HasPrefix(line, "new file mode"):
+				f.Status = "added"
+			case strings.HasPrefix(line, "deleted file mode"):
+				f.Status = "deleted"
+			case strings.HasPrefix(line, "rename to "):
+				f.Status = "renamed"
+				f.Path = strings.TrimPrefix(line, "rename to ")
+			case strings.HasPrefix(line, "+++ b/"):
+				f.Path = strings.TrimPrefix(line, "+++ b/")
+			case strings.HasPrefix(line, "Binary files "):
+				f.Binary = true
+			}
+		}
+		out2 = append(out2, f)
+	}
+	return out2
+}
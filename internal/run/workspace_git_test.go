@@ -0,0 +1,155 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWorkspaceGitStatusAndDiff(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "-q")
+	write("main.go", "package main\n")
+	write("old.txt", "old\n")
+	git("add", ".")
+	git("commit", "-qm", "init")
+	write("main.go", "package main\n\nfunc main() {}\n")
+	write("new.txt", "hello\n")
+	git("mv", "old.txt", "renamed.txt")
+
+	svc := setupService(t, newFakeDriver("codex", false))
+	r, err := svc.Submit(context.Background(), SubmitRequest{
+		WorkspaceID:   "ws-git",
+		WorkspacePath: dir,
+		Backend:       "codex",
+		Prompt:        "edit",
+	})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, r.ID, StatusCompleted)
+
+	st, err := svc.WorkspaceGitStatus(context.Background(), "ws-git")
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	got := map[string]string{}
+	for _, f := range st.Files {
+		got[f.Path] = f.Status
+	}
+	if st.Clean || got["main.go"] != "modified" || got["new.txt"] != "untracked" || got["renamed.txt"] != "renamed" {
+		t.Fatalf("unexpected status: %#v", st)
+	}
+
+	diff, err := svc.WorkspaceGitDiff(context.Background(), "ws-git", GitDiffQuery{Untracked: true})
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	byPath := map[string]GitFileDiff{}
+	for _, f := range diff.Files {
+		if f.Format != "diff" {
+			t.Fatalf("unexpected format: %#v", f)
+		}
+		byPath[f.Path] = f
+	}
+	if !strings.Contains(byPath["main.go"].Diff, "+func main() {}") {
+		t.Fatalf("main.go diff missing change: %#v", diff.Files)
+	}
+	if byPath["renamed.txt"].Status != "renamed" || byPath["new.txt"].Status != "untracked" {
+		t.Fatalf("unexpected diff entries: %#v", diff.Files)
+	}
+
+	only, err := svc.WorkspaceGitDiff(context.Background(), "ws-git", GitDiffQuery{Path: "main.go", Untracked: true})
+	if err != nil || len(only.Files) != 1 {
+		t.Fatalf("path filter: %#v %v", only, err)
+	}
+	if _, err := svc.WorkspaceGitDiff(context.Background(), "ws-git", GitDiffQuery{Path: "../etc"}); !errors.Is(err, ErrGitPathOutsideWorkspace) {
+		t.Fatalf("expected ErrGitPathOutsideWorkspace, got %v", err)
+	}
+	if _, err := svc.WorkspaceGitStatus(context.Background(), "missing"); !errors.Is(err, ErrWorkspaceNotFound) {
+		t.Fatalf("expected ErrWorkspaceNotFound, got %v", err)
+	}
+}
+
+func TestWorkspaceGitIgnoresPlantedConfigCommands(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	marker := filepath.Join(t.TempDir(), "ran")
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "-q")
+	write(".gitattributes", "*.txt filter=evil diff=evil\n")
+	write("a.txt", "one\n")
+	git("add", ".")
+	git("commit", "-qm", "init")
+	// What an agent could write into the workspace's own config.
+	planted := "touch " + marker + "; cat"
+	git("config", "core.fsmonitor", "touch "+marker)
+	git("config", "core.hooksPath", filepath.Join(dir, "hooks"))
+	git("config", "diff.external", "touch "+marker)
+	git("config", "diff.evil.textconv", planted)
+	git("config", "filter.evil.clean", planted)
+	git("config", "filter.evil.smudge", planted)
+	git("config", "filter.evil.required", "true")
+	write("a.txt", "two\n")
+	write("b.txt", "new\n")
+
+	svc := setupService(t, newFakeDriver("codex", false))
+	r, err := svc.Submit(context.Background(), SubmitRequest{
+		WorkspaceID:   "ws-planted",
+		WorkspacePath: dir,
+		Backend:       "codex",
+		Prompt:        "edit",
+		Options:       RunOptions{Checkpoint: true},
+	})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitStatus(t, svc, r.ID, StatusCompleted)
+	if _, err := svc.WorkspaceGitStatus(context.Background(), "ws-planted"); err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	diff, err := svc.WorkspaceGitDiff(context.Background(), "ws-planted", GitDiffQuery{Untracked: true})
+	if err != nil || len(diff.Files) != 2 {
+		t.Fatalf("diff: %#v %v", diff, err)
+	}
+	if _, err := svc.RollbackRun(context.Background(), r.ID, false); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatalf("a command from the workspace config ran: %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"echohelix/internal/ledger"
+	"echohelix/internal/policy"
+)
+
+var ErrWorkspacePolicyNotFound = errors.New("workspace policy not found")
+
+// WorkspacePolicy is a stored workspace rule. It applies to every run and
+// session whose workspace path is within WorkspacePath, whatever workspace
+// ID the client sends.
+type WorkspacePolicy struct {
+	WorkspaceID   string `json:"workspace_id"`
+	WorkspacePath string `json:"workspace_path,omitempty"`
+	policy.WorkspaceRule
+	UpdatedBy string    `json:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PutWorkspacePolicy stores rule for the workspace root workspacePath. An
+// empty path binds the rule to the path of the workspace's latest run.
+func (s *Service) PutWorkspacePolicy(ctx context.Context, workspaceID, workspacePath string, rule policy.WorkspaceRule, updatedBy string) (WorkspacePolicy, error) {
+	workspaceID = strings.TrimSpace(workspaceID)
+	if workspaceID == "" {
+		return WorkspacePolicy{}, fmt.Errorf("workspace_id is required")
+	}
+	if err := rule.Validate(); err != nil {
+		return WorkspacePolicy{}, err
+	}
+	workspacePath = strings.TrimSpace(workspacePath)
+	if workspacePath == "" {
+		path, err := s.ledger.WorkspacePath(ctx, workspaceID)
+		if errors.Is(err, ledger.ErrWorkspaceNotFound) {
+			return WorkspacePolicy{}, fmt.Errorf("workspace_path is required: workspace %s has no runs", workspaceID)
+		}
+		if err != nil {
+			return WorkspacePolicy{}, err
+		}
+		workspacePath = path
+	}
+	if !filepath.IsAbs(workspacePath) {
+		return WorkspacePolicy{}, fmt.Errorf("workspace_path must be absolute")
+	}
+	if err := s.policy.ValidateWorkspace(workspacePath); err != nil {
+		return WorkspacePolicy{}, err
+	}
+	raw, _ := json.Marshal(rule)
+	rec := ledger.WorkspacePolicyRecord{
+		WorkspaceID:   workspaceID,
+		WorkspacePath: policy.CanonicalPath(workspacePath),
+		RuleJSON:      string(raw),
+		UpdatedBy:     strings.TrimSpace(updatedBy),
+		UpdatedAt:     time.Now().UTC(),
+	}
+	if err := s.ledger.UpsertWorkspacePolicy(ctx, rec); err != nil {
+		return WorkspacePolicy{}, err
+	}
+	return WorkspacePolicy{WorkspaceID: workspaceID, WorkspacePath: rec.WorkspacePath, WorkspaceRule: rule, UpdatedBy: rec.UpdatedBy, UpdatedAt: rec.UpdatedAt}, nil
+}
+
+func (s *Service) GetWorkspacePolicy(ctx context.Context, workspaceID string) (WorkspacePolicy, error) {
+	rec, err := s.ledger.GetWorkspacePolicy(ctx, strings.TrimSpace(workspaceID))
+	if err != nil {
+		if errors.Is(err, ledger.ErrWorkspacePolicyNotFound) {
+			return WorkspacePolicy{}, ErrWorkspacePolicyNotFound
+		}
+		return WorkspacePolicy{}, err
+	}
+	return decodeWorkspacePolicy(rec)
+}
+
+func decodeWorkspacePolicy(rec ledger.WorkspacePolicyRecord) (WorkspacePolicy, error) {
+	out := WorkspacePolicy{WorkspaceID: rec.WorkspaceID, WorkspacePath: rec.WorkspacePath, UpdatedBy: rec.UpdatedBy, UpdatedAt: rec.UpdatedAt}
+	if err := json.Unmarshal([]byte(rec.RuleJSON), &out.WorkspaceRule); err != nil {
+		return WorkspacePolicy{}, fmt.Errorf("decode workspace policy: %w", err)
+	}
+	return out, nil
+}
+
+func (s *Service) DeleteWorkspacePolicy(ctx context.Context, workspaceID string) error {
+	err := s.ledger.DeleteWorkspacePolicy(ctx, strings.TrimSpace(workspaceID))
+	if errors.Is(err, ledger.ErrWorkspacePolicyNotFound) {
+		return ErrWorkspacePolicyNotFound
+	}
+	return err
+}
+
+// StoredWorkspaceRules is the policy.WorkspaceRuleLookup for rules kept in
+// the ledger. Rows stored before rules were bound to paths fall back to the
+// path of their workspace's latest run, and are skipped when it has none.
+func (s *Service) StoredWorkspaceRules(ctx context.Context) (map[string]policy.WorkspaceRule, error) {
+	recs, err := s.ledger.ListWorkspacePolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]policy.WorkspaceRule, len(recs))
+	for _, rec := range recs {
+		obj, err := decodeWorkspacePolicy(rec)
+		if err != nil {
+			return nil, err
+		}
+		path := obj.WorkspacePath
+		if path == "" {
+			if path, err = s.ledger.WorkspacePath(ctx, obj.WorkspaceID); err != nil {
+				if errors.Is(err, ledger.ErrWorkspaceNotFound) {
+					continue
+				}
+				return nil, err
+			}
+		}
+		out[policy.CanonicalPath(path)] = obj.WorkspaceRule
+	}
+	return out, nil
+}
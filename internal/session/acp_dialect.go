@@ -0,0 +1,373 @@
+package session
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// acpDialect speaks the Agent Client Protocol used by `gemini
+// --experimental-acp`: threads map to ACP sessions and a turn is one
+// session/prompt call.
+type acpDialect struct {
+	cwd string
+
+	mu          sync.Mutex
+	calls       map[string]acpCall
+	turns       map[string]*acpTurn
+	current     *acpTurn
+	toolTypes   map[string]string
+	permissions map[string][]acpPermissionOption
+}
+
+type acpCall struct {
+	method   string
+	threadID string
+}
+
+type acpTurn struct {
+	id       string
+	threadID string
+	text     strings.Builder
+}
+
+type acpPermissionOption struct {
+	OptionID string `json:"optionId"`
+	Kind     string `json:"kind"`
+}
+
+type acpMessage struct {
+	JSONRPC string `json:"jsonrpc"`
+	rpcEnvelope
+}
+
+func newACPDialect(cwd string) *acpDialect {
+	return &acpDialect{
+		cwd:         cwd,
+		calls:       map[string]acpCall{},
+		turns:       map[string]*acpTurn{},
+		toolTypes:   map[string]string{},
+		permissions: map[string][]acpPermissionOption{},
+	}
+}
+
+func (d *acpDialect) name() string { return ProtocolACP }
+
+func acpWire(env rpcEnvelope) acpMessage {
+	return acpMessage{JSONRPC: "2.0", rpcEnvelope: env}
+}
+
+func (d *acpDialect) outgoing(env rpcEnvelope) ([]any, []rpcEnvelope, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch {
+	case env.Method != "" && env.ID != nil:
+		return d.outgoingCall(env)
+	case env.Method != "":
+		if env.Method == "initialized" {
+			return nil, nil, nil
+		}
+		return []any{acpWire(env)}, nil, nil
+	case env.ID != nil:
+		key := normalizeIDKey(env.ID)
+		if options, ok := d.permissions[key]; ok {
+			delete(d.permissions, key)
+			return []any{acpWire(rpcEnvelope{ID: env.ID, Result: mustMarshalRaw(acpPermissionOutcome(env, options))})}, nil, nil
+		}
+		return []any{acpWire(env)}, nil, nil
+	}
+	return nil, nil, nil
+}
+
+func (d *acpDialect) outgoingCall(env rpcEnvelope) ([]any, []rpcEnvelope, error) {
+	key := normalizeIDKey(env.ID)
+	params := decodeParams(env.Params)
+	threadID := stringField(params, "threadId")
+	switch env.Method {
+	case "initialize":
+		d.calls[key] = acpCall{method: env.Method}
+		return []any{acpWire(rpcEnvelope{ID: env.ID, Method: "initialize", Params: mustMarshalRaw(map[string]any{
+			"protocolVersion": 1,
+			"clientCapabilities": map[string]any{
+				"fs": map[string]any{"readTextFile": false, "writeTextFile": false},
+			},
+		})})}, nil, nil
+	case "thread/start":
+		cwd := stringField(params, "cwd")
+		if cwd == "" {
+			cwd = d.cwd
+		}
+		d.calls[key] = acpCall{method: env.Method}
+		return []any{acpWire(rpcEnvelope{ID: env.ID, Method: "session/new", Params: mustMarshalRaw(map[string]any{
+			"cwd":        cwd,
+			"mcpServers": []any{},
+		})})}, nil, nil
+	case "thread/resume":
+		d.calls[key] = acpCall{method: env.Method, threadID: threadID}
+		return []any{acpWire(rpcEnvelope{ID: env.ID, Method: "session/load", Params: mustMarshalRaw(map[string]any{
+			"sessionId":  threadID,
+			"cwd":        d.cwd,
+			"mcpServers": []any{},
+		})})}, nil, nil
+	case "turn/start":
+		turn := &acpTurn{id: uuid.NewString(), threadID: threadID}
+		promptID := "prompt-" + turn.id
+		d.turns[promptID] = turn
+		d.current = turn
+		wire := acpWire(rpcEnvelope{ID: promptID, Method: "session/prompt", Params: mustMarshalRaw(map[string]any{
+			"sessionId": threadID,
+			"prompt":    acpPrompt(params["input"]),
+		})})
+		turnObj := map[string]any{"id": turn.id, "threadId": threadID, "status": "inProgress"}
+		return []any{wire}, []rpcEnvelope{
+			localResult(env.ID, map[string]any{"turn": turnObj}),
+			localNotification("turn/started", map[string]any{"threadId": threadID, "turn": turnObj}),
+		}, nil
+	case "turn/interrupt":
+		wire := acpWire(rpcEnvelope{Method: "session/cancel", Params: mustMarshalRaw(map[string]any{"sessionId": threadID})})
+		return []any{wire}, []rpcEnvelope{localResult(env.ID, map[string]any{})}, nil
+	case "turn/steer":
+		return nil, []rpcEnvelope{localError(env.ID, rpcMethodNotFound, "turn/steer is not supported by the acp session protocol")}, nil
+	default:
+		return []any{acpWire(env)}, nil, nil
+	}
+}
+
+// acpPrompt converts app-server turn input into ACP content blocks.
+func acpPrompt(raw any) []map[string]any {
+	items, _ := raw.([]any)
+	out := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		m, _ := item.(map[string]any)
+		switch stringField(m, "type") {
+		case "text":
+			out = append(out, map[string]any{"type": "text", "text": stringField(m, "text")})
+		case "localImage":
+			path := stringField(m, "path")
+			out = append(out, map[string]any{"type": "resource_link", "uri": "file://" + path, "name": filepath.Base(path)})
+		}
+	}
+	return out
+}
+
+// acpPermissionOutcome picks the offered option matching the service's
+// approval decision; an error reply or no matching option cancels.
+func acpPermissionOutcome(env rpcEnvelope, options []acpPermissionOption) map[string]any {
+	cancelled := map[string]any{"outcome": map[string]any{"outcome": "cancelled"}}
+	if env.Error != nil {
+		return cancelled
+	}
+	accept, forSession := approvalDecision(env.Result)
+	kinds := []string{"reject_once", "reject_always"}
+	if accept {
+		kinds = []string{"allow_once", "allow_always"}
+		if forSession {
+			kinds = []string{"allow_always", "allow_once"}
+		}
+	}
+	for _, kind := range kinds {
+		for _, opt := range options {
+			if opt.Kind == kind {
+				return map[string]any{"outcome": map[string]any{"outcome": "selected", "optionId": opt.OptionID}}
+			}
+		}
+	}
+	return cancelled
+}
+
+func (d *acpDialect) incoming(line []byte) ([]rpcEnvelope, error) {
+	var msg struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+		Result json.RawMessage `json:"result"`
+		Error  *rpcError       `json:"error"`
+	}
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var wireID any
+	if len(msg.ID) > 0 {
+		wireID = unmarshalWireID(msg.ID)
+	}
+	switch {
+	case msg.Method == "session/update" && wireID == nil:
+		return d.sessionUpdate(decodeParams(msg.Params)), nil
+	case msg.Method == "session/request_permission" && wireID != nil:
+		return d.permissionRequest(wireID, msg.Params), nil
+	case msg.Method != "":
+		return []rpcEnvelope{{Method: msg.Method, ID: wireID, Params: msg.Params}}, nil
+	case wireID != nil:
+		return d.response(wireID, msg.Result, msg.Error), nil
+	}
+	return nil, nil
+}
+
+func (d *acpDialect) response(wireID any, result json.RawMessage, rpcErr *rpcError) []rpcEnvelope {
+	key := normalizeIDKey(wireID)
+	if turn, ok := d.turns[key]; ok {
+		delete(d.turns, key)
+		return d.completeTurn(turn, result, rpcErr)
+	}
+	call, ok := d.calls[key]
+	if !ok {
+		return []rpcEnvelope{{ID: wireID, Result: result, Error: rpcErr}}
+	}
+	delete(d.calls, key)
+	if rpcErr != nil {
+		return []rpcEnvelope{{ID: wireID, Error: rpcErr}}
+	}
+	switch call.method {
+	case "initialize":
+		var init struct {
+			AgentCapabilities struct {
+				LoadSession bool `json:"loadSession"`
+			} `json:"agentCapabilities"`
+		}
+		_ = json.Unmarshal(result, &init)
+		methods := []string{"thread/start", "turn/start", "turn/interrupt"}
+		if init.AgentCapabilities.LoadSession {
+			methods = append(methods, "thread/resume")
+		}
+		out := decodeParams(result)
+		out["methods"] = methods
+		return []rpcEnvelope{localResult(wireID, out)}
+	case "thread/start":
+		sessionID := decodeResultField(result, "sessionId")
+		return []rpcEnvelope{localResult(wireID, map[string]any{"thread": map[string]any{"id": sessionID}})}
+	default:
+		return []rpcEnvelope{localResult(wireID, map[string]any{"thread": map[string]any{"id": call.threadID}})}
+	}
+}
+
+func (d *acpDialect) completeTurn(turn *acpTurn, result json.RawMessage, rpcErr *rpcError) []rpcEnvelope {
+	if d.current == turn {
+		d.current = nil
+	}
+	turnObj := map[string]any{"id": turn.id, "status": "completed"}
+	switch {
+	case rpcErr != nil:
+		turnObj["status"] = "failed"
+		turnObj["error"] = map[string]any{"message": rpcErr.Message}
+	case decodeResultField(result, "stopReason") == "cancelled":
+		turnObj["status"] = "interrupted"
+	}
+	var out []rpcEnvelope
+	if text := turn.text.String(); text != "" {
+		out = append(out, localNotification("item/completed", map[string]any{
+			"threadId": turn.threadID,
+			"turnId":   turn.id,
+			"item":     map[string]any{"type": "agentMessage", "id": turn.id + "/message", "text": text},
+		}))
+	}
+	return append(out, localNotification("turn/completed", map[string]any{"threadId": turn.threadID, "turn": turnObj}))
+}
+
+func (d *acpDialect) turnIDs() (threadID, turnID string) {
+	if d.current == nil {
+		return "", ""
+	}
+	return d.current.threadID, d.current.id
+}
+
+func (d *acpDialect) sessionUpdate(params map[string]any) []rpcEnvelope {
+	update, _ := params["update"].(map[string]any)
+	threadID, turnID := d.turnIDs()
+	if threadID == "" {
+		threadID = stringField(params, "sessionId")
+	}
+	toolCallID := stringField(update, "toolCallId")
+	switch stringField(update, "sessionUpdate") {
+	case "agent_message_chunk":
+		content, _ := update["content"].(map[string]any)
+		delta := stringField(content, "text")
+		if delta == "" {
+			return nil
+		}
+		if d.current != nil {
+			d.current.text.WriteString(delta)
+		}
+		return []rpcEnvelope{localNotification("item/agentMessage/delta", map[string]any{
+			"threadId": threadID,
+			"turnId":   turnID,
+			"itemId":   turnID + "/message",
+			"delta":    delta,
+		})}
+	case "tool_call":
+		itemType := acpItemType(stringField(update, "kind"))
+		d.toolTypes[toolCallID] = itemType
+		item := map[string]any{"type": itemType, "id": toolCallID, "title": stringField(update, "title"), "status": "inProgress"}
+		if raw, ok := update["rawInput"].(map[string]any); ok {
+			item["input"] = raw
+			if cmd := stringField(raw, "command"); cmd != "" {
+				item["command"] = cmd
+			}
+		}
+		return []rpcEnvelope{localNotification("item/started", map[string]any{"threadId": threadID, "turnId": turnID, "item": item})}
+	case "tool_call_update":
+		status := stringField(update, "status")
+		if status != "completed" && status != "failed" {
+			break
+		}
+		itemType, ok := d.toolTypes[toolCallID]
+		if !ok {
+			itemType = "toolCall"
+		}
+		delete(d.toolTypes, toolCallID)
+		item := map[string]any{"type": itemType, "id": toolCallID, "status": status}
+		if content, ok := update["content"]; ok {
+			item["content"] = content
+		}
+		return []rpcEnvelope{localNotification("item/completed", map[string]any{"threadId": threadID, "turnId": turnID, "item": item})}
+	}
+	return []rpcEnvelope{localNotification("session/update", params)}
+}
+
+func (d *acpDialect) permissionRequest(wireID any, raw json.RawMessage) []rpcEnvelope {
+	var req struct {
+		SessionID string `json:"sessionId"`
+		ToolCall  struct {
+			ToolCallID string         `json:"toolCallId"`
+			Title      string         `json:"title"`
+			Kind       string         `json:"kind"`
+			RawInput   map[string]any `json:"rawInput"`
+		} `json:"toolCall"`
+		Options []acpPermissionOption `json:"options"`
+	}
+	_ = json.Unmarshal(raw, &req)
+	d.permissions[normalizeIDKey(wireID)] = req.Options
+	threadID, turnID := d.turnIDs()
+	if threadID == "" {
+		threadID = req.SessionID
+	}
+	params := map[string]any{
+		"threadId": threadID,
+		"turnId":   turnID,
+		"itemId":   req.ToolCall.ToolCallID,
+		"reason":   req.ToolCall.Title,
+		"cwd":      d.cwd,
+	}
+	method := "item/commandExecution/requestApproval"
+	if acpItemType(req.ToolCall.Kind) == "fileChange" {
+		method = "item/fileChange/requestApproval"
+	} else if cmd := stringField(req.ToolCall.RawInput, "command"); cmd != "" {
+		params["command"] = cmd
+	}
+	return []rpcEnvelope{{Method: method, ID: wireID, Params: mustMarshalRaw(params)}}
+}
+
+func acpItemType(kind string) string {
+	switch kind {
+	case "execute":
+		return "commandExecution"
+	case "edit", "delete", "move":
+		return "fileChange"
+	default:
+		return "toolCall"
+	}
+}
@@ -0,0 +1,126 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// rpcCodeMethodNotFound is the JSON-RPC 2.0 "method not found" error code.
+const rpcCodeMethodNotFound = -32601
+
+// MethodNotSupportedError reports a backend/call method that the session's
+// app-server does not implement. Supported lists the methods the app-server
+// advertised during initialize; it is empty when none were advertised.
+type MethodNotSupportedError struct {
+	Method    string
+	Backend   string
+	Supported []string
+}
+
+func (e *MethodNotSupportedError) Error() string {
+	return fmt.Sprintf("method %q is not supported by %s app-server", e.Method, e.Backend)
+}
+
+func isMethodNotFound(err error) bool {
+	var rpcErr *rpcCallError
+	return errors.As(err, &rpcErr) && rpcErr.code == rpcCodeMethodNotFound
+}
+
+// parseAdvertisedMethods extracts a method registry from an initialize
+// result. App-servers expose it as capabilities.methods, serverCapabilities.methods
+// or a top-level methods field, either as a list of names or an object keyed by
+// name. A nil result means the app-server did not advertise its methods.
+func parseAdvertisedMethods(raw json.RawMessage) map[string]string {
+	var result struct {
+		Methods      json.RawMessage `json:"methods"`
+		Capabilities struct {
+			Methods json.RawMessage `json:"methods"`
+		} `json:"capabilities"`
+		ServerCapabilities struct {
+			Methods json.RawMessage `json:"methods"`
+		} `json:"serverCapabilities"`
+	}
+	if len(raw) == 0 || json.Unmarshal(raw, &result) != nil {
+		return nil
+	}
+	for _, candidate := range []json.RawMessage{result.Capabilities.Methods, result.ServerCapabilities.Methods, result.Methods} {
+		if methods := decodeMethodList(candidate); methods != nil {
+			return methods
+		}
+	}
+	return nil
+}
+
+func decodeMethodList(raw json.RawMessage) map[string]string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(raw, &names); err != nil {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return nil
+		}
+		for name := range obj {
+			names = append(names, name)
+		}
+	}
+	out := make(map[string]string, len(names))
+	for _, name := range names {
+		if key := normalizeMethod(name); key != "" {
+			out[key] = strings.TrimSpace(name)
+		}
+	}
+	return out
+}
+
+// supportedMethodList returns the advertised methods a client may call through
+// backend/call, i.e. without the ones the bridge manages itself.
+func (s *Service) supportedMethodList(methods map[string]string) []string {
+	if methods == nil {
+		return nil
+	}
+	out := make([]string, 0, len(methods))
+	for key, name := range methods {
+		if s.methodBlocked(key) {
+			continue
+		}
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// checkMethodSupported fails fast for methods the app-server did not advertise
+// or previously rejected with "method not found".
+func (s *Service) checkMethodSupported(st *sessionState, method string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	_, rejected := st.unsupported[method]
+	_, advertised := st.methods[method]
+	if rejected || (st.methods != nil && !advertised) {
+		return &MethodNotSupportedError{
+			Method:    method,
+			Backend:   st.session.Backend,
+			Supported: s.supportedMethodList(st.methods),
+		}
+	}
+	return nil
+}
+
+func (s *Service) markMethodUnsupported(st *sessionState, method string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.unsupported == nil {
+		st.unsupported = map[string]struct{}{}
+	}
+	st.unsupported[method] = struct{}{}
+	return &MethodNotSupportedError{
+		Method:    method,
+		Backend:   st.session.Backend,
+		Supported: s.supportedMethodList(st.methods),
+	}
+}
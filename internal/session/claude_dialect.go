@@ -0,0 +1,319 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// claudeDialect speaks the stream-json protocol of `claude -p
+// --input-format stream-json`: the thread is the Claude session bound at
+// launch, each turn is one user message ended by a result line, and tool
+// permissions arrive as can_use_tool control requests.
+type claudeDialect struct {
+	sessionID string
+
+	mu          sync.Mutex
+	turnID      string
+	interrupted bool
+	controls    map[string]any
+	toolTypes   map[string]string
+	permissions map[string]map[string]any
+}
+
+func newClaudeDialect(sessionID string) *claudeDialect {
+	return &claudeDialect{
+		sessionID:   sessionID,
+		controls:    map[string]any{},
+		toolTypes:   map[string]string{},
+		permissions: map[string]map[string]any{},
+	}
+}
+
+func (d *claudeDialect) name() string { return ProtocolStreamJSON }
+
+func (d *claudeDialect) controlRequest(request map[string]any) (string, map[string]any) {
+	requestID := uuid.NewString()
+	return requestID, map[string]any{"type": "control_request", "request_id": requestID, "request": request}
+}
+
+func (d *claudeDialect) outgoing(env rpcEnvelope) ([]any, []rpcEnvelope, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch {
+	case env.Method != "" && env.ID != nil:
+		return d.outgoingCall(env)
+	case env.Method != "":
+		// stream-json has no client notifications.
+		return nil, nil, nil
+	case env.ID != nil:
+		key := normalizeIDKey(env.ID)
+		input, ok := d.permissions[key]
+		if !ok {
+			return nil, nil, nil
+		}
+		delete(d.permissions, key)
+		return []any{claudePermissionResponse(key, env, input)}, nil, nil
+	}
+	return nil, nil, nil
+}
+
+func (d *claudeDialect) outgoingCall(env rpcEnvelope) ([]any, []rpcEnvelope, error) {
+	params := decodeParams(env.Params)
+	thread := map[string]any{"thread": map[string]any{"id": d.sessionID}}
+	switch env.Method {
+	case "initialize":
+		return nil, []rpcEnvelope{localResult(env.ID, map[string]any{
+			"methods": []string{"thread/start", "thread/resume", "turn/start", "turn/interrupt"},
+		})}, nil
+	case "thread/start":
+		var wire []any
+		if model := stringField(params, "model"); model != "" {
+			_, req := d.controlRequest(map[string]any{"subtype": "set_model", "model": model})
+			wire = append(wire, req)
+		}
+		return wire, []rpcEnvelope{localResult(env.ID, thread)}, nil
+	case "thread/resume":
+		return nil, []rpcEnvelope{localResult(env.ID, thread)}, nil
+	case "turn/start":
+		d.turnID = uuid.NewString()
+		d.interrupted = false
+		wire := map[string]any{
+			"type":               "user",
+			"session_id":         d.sessionID,
+			"parent_tool_use_id": nil,
+			"message":            map[string]any{"role": "user", "content": claudeContent(params["input"])},
+		}
+		turnObj := map[string]any{"id": d.turnID, "threadId": d.sessionID, "status": "inProgress"}
+		return []any{wire}, []rpcEnvelope{
+			localResult(env.ID, map[string]any{"turn": turnObj}),
+			localNotification("turn/started", map[string]any{"threadId": d.sessionID, "turn": turnObj}),
+		}, nil
+	case "turn/interrupt":
+		requestID, req := d.controlRequest(map[string]any{"subtype": "interrupt"})
+		d.controls[requestID] = env.ID
+		d.interrupted = true
+		return []any{req}, nil, nil
+	default:
+		msg := fmt.Sprintf("method %q is not supported by the stream-json session protocol", env.Method)
+		return nil, []rpcEnvelope{localError(env.ID, rpcMethodNotFound, msg)}, nil
+	}
+}
+
+// claudeContent converts app-server turn input into message content blocks.
+func claudeContent(raw any) []map[string]any {
+	items, _ := raw.([]any)
+	out := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		m, _ := item.(map[string]any)
+		if stringField(m, "type") == "text" {
+			out = append(out, map[string]any{"type": "text", "text": stringField(m, "text")})
+		}
+	}
+	return out
+}
+
+// claudePermissionResponse answers a can_use_tool request. Accepted edits
+// from the service replace the matching keys of the tool input.
+func claudePermissionResponse(requestID string, env rpcEnvelope, input map[string]any) map[string]any {
+	response := map[string]any{"behavior": "deny", "message": "declined by the bridge"}
+	if env.Error != nil {
+		response["message"] = env.Error.Message
+	} else if accept, _ := approvalDecision(env.Result); accept {
+		updated := make(map[string]any, len(input))
+		for k, v := range input {
+			updated[k] = v
+		}
+		for k, v := range decodeParams(env.Result) {
+			if _, ok := input[k]; ok {
+				updated[k] = v
+			}
+		}
+		response = map[string]any{"behavior": "allow", "updatedInput": updated}
+	}
+	return map[string]any{
+		"type": "control_response",
+		"response": map[string]any{
+			"subtype":    "success",
+			"request_id": requestID,
+			"response":   response,
+		},
+	}
+}
+
+type claudeLine struct {
+	Type      string `json:"type"`
+	Subtype   string `json:"subtype"`
+	RequestID string `json:"request_id"`
+	Message   struct {
+		ID      string            `json:"id"`
+		Content []json.RawMessage `json:"content"`
+	} `json:"message"`
+	Request struct {
+		Subtype   string         `json:"subtype"`
+		ToolName  string         `json:"tool_name"`
+		ToolUseID string         `json:"tool_use_id"`
+		Input     map[string]any `json:"input"`
+	} `json:"request"`
+	Response struct {
+		Subtype   string `json:"subtype"`
+		RequestID string `json:"request_id"`
+		Error     string `json:"error"`
+	} `json:"response"`
+	IsError bool   `json:"is_error"`
+	Result  string `json:"result"`
+}
+
+type claudeBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text"`
+	Thinking  string          `json:"thinking"`
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Input     map[string]any  `json:"input"`
+	ToolUseID string          `json:"tool_use_id"`
+	Content   json.RawMessage `json:"content"`
+	IsError   bool            `json:"is_error"`
+}
+
+func (d *claudeDialect) incoming(line []byte) ([]rpcEnvelope, error) {
+	var msg claudeLine
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch msg.Type {
+	case "assistant":
+		return d.assistant(msg), nil
+	case "user":
+		return d.toolResults(msg), nil
+	case "result":
+		return d.result(msg), nil
+	case "control_request":
+		if msg.Request.Subtype == "can_use_tool" {
+			return d.permissionRequest(msg), nil
+		}
+	case "control_response":
+		id, ok := d.controls[msg.Response.RequestID]
+		if !ok {
+			return nil, nil
+		}
+		delete(d.controls, msg.Response.RequestID)
+		if msg.Response.Subtype == "error" {
+			return []rpcEnvelope{localError(id, -32000, msg.Response.Error)}, nil
+		}
+		return []rpcEnvelope{localResult(id, map[string]any{})}, nil
+	}
+	params := map[string]any{}
+	_ = json.Unmarshal(line, &params)
+	return []rpcEnvelope{localNotification("claude/"+msg.Type, params)}, nil
+}
+
+func (d *claudeDialect) itemEvent(method string, item map[string]any) rpcEnvelope {
+	return localNotification(method, map[string]any{"threadId": d.sessionID, "turnId": d.turnID, "item": item})
+}
+
+func (d *claudeDialect) assistant(msg claudeLine) []rpcEnvelope {
+	var out []rpcEnvelope
+	for i, raw := range msg.Message.Content {
+		var block claudeBlock
+		if json.Unmarshal(raw, &block) != nil {
+			continue
+		}
+		switch block.Type {
+		case "text":
+			out = append(out, d.itemEvent("item/completed", map[string]any{
+				"type": "agentMessage",
+				"id":   fmt.Sprintf("%s/%d", msg.Message.ID, i),
+				"text": block.Text,
+			}))
+		case "thinking":
+			out = append(out, d.itemEvent("item/completed", map[string]any{
+				"type": "reasoning",
+				"id":   fmt.Sprintf("%s/%d", msg.Message.ID, i),
+				"text": block.Thinking,
+			}))
+		case "tool_use":
+			itemType := claudeItemType(block.Name)
+			d.toolTypes[block.ID] = itemType
+			item := map[string]any{"type": itemType, "id": block.ID, "name": block.Name, "input": block.Input, "status": "inProgress"}
+			if cmd := stringField(block.Input, "command"); cmd != "" {
+				item["command"] = cmd
+			}
+			out = append(out, d.itemEvent("item/started", item))
+		}
+	}
+	return out
+}
+
+func (d *claudeDialect) toolResults(msg claudeLine) []rpcEnvelope {
+	var out []rpcEnvelope
+	for _, raw := range msg.Message.Content {
+		var block claudeBlock
+		if json.Unmarshal(raw, &block) != nil || block.Type != "tool_result" {
+			continue
+		}
+		itemType, ok := d.toolTypes[block.ToolUseID]
+		if !ok {
+			itemType = "toolCall"
+		}
+		delete(d.toolTypes, block.ToolUseID)
+		status := "completed"
+		if block.IsError {
+			status = "failed"
+		}
+		item := map[string]any{"type": itemType, "id": block.ToolUseID, "status": status}
+		if len(block.Content) > 0 {
+			item["output"] = block.Content
+		}
+		out = append(out, d.itemEvent("item/completed", item))
+	}
+	return out
+}
+
+func (d *claudeDialect) result(msg claudeLine) []rpcEnvelope {
+	turnObj := map[string]any{"id": d.turnID, "status": "completed"}
+	switch {
+	case d.interrupted:
+		turnObj["status"] = "interrupted"
+	case msg.IsError:
+		turnObj["status"] = "failed"
+		turnObj["error"] = map[string]any{"message": msg.Result}
+	}
+	d.turnID = ""
+	d.interrupted = false
+	return []rpcEnvelope{localNotification("turn/completed", map[string]any{"threadId": d.sessionID, "turn": turnObj})}
+}
+
+func (d *claudeDialect) permissionRequest(msg claudeLine) []rpcEnvelope {
+	d.permissions[msg.RequestID] = msg.Request.Input
+	params := map[string]any{
+		"threadId": d.sessionID,
+		"turnId":   d.turnID,
+		"itemId":   msg.Request.ToolUseID,
+		"reason":   "claude requests to use " + msg.Request.ToolName,
+		"tool":     msg.Request.ToolName,
+		"input":    msg.Request.Input,
+	}
+	method := "item/commandExecution/requestApproval"
+	if claudeItemType(msg.Request.ToolName) == "fileChange" {
+		method = "item/fileChange/requestApproval"
+	} else if cmd := stringField(msg.Request.Input, "command"); cmd != "" {
+		params["command"] = cmd
+	}
+	return []rpcEnvelope{{Method: method, ID: msg.RequestID, Params: mustMarshalRaw(params)}}
+}
+
+func claudeItemType(tool string) string {
+	switch tool {
+	case "Bash":
+		return "commandExecution"
+	case "Edit", "MultiEdit", "Write", "NotebookEdit":
+		return "fileChange"
+	default:
+		return "toolCall"
+	}
+}
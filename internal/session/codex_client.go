@@ -11,7 +11,12 @@ import (
 	"sync"
 	"time"
 
+	"echohelix/internal/envprofile"
+	"echohelix/internal/tracing"
+
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type rpcEnvelope struct {
@@ -28,6 +33,18 @@ type rpcError struct {
 	Data    json.RawMessage `json:"data,omitempty"`
 }
 
+// rpcCallError is returned by Call when the app-server answers with a
+// JSON-RPC error object.
+type rpcCallError struct {
+	method  string
+	code    int
+	message string
+}
+
+func (e *rpcCallError) Error() string {
+	return fmt.Sprintf("rpc %s failed (%d): %s", e.method, e.code, e.message)
+}
+
 type rpcResult struct {
 	result json.RawMessage
 	err    *rpcError
@@ -42,6 +59,15 @@ type appServerClient struct {
 	mu      sync.Mutex
 	pending map[string]chan rpcResult
 	closed  bool
+	// killErr replaces the exit error reported to onClose after the
+	// process was killed for breaking a resource limit.
+	killErr  error
+	lifetime *time.Timer
+	// group is set when the process leads its own process group.
+	group bool
+	// dialect translates to the backend's native protocol; nil speaks the
+	// codex app-server protocol as is.
+	dialect dialect
 
 	onNotification func(method string, params map[string]any)
 	onRequest      func(idKey string, wireID any, method string, params map[string]any)
@@ -49,10 +75,13 @@ type appServerClient struct {
 	onStderr       func(line string)
 }
 
-func newAppServerClient(bin string, args []string, workdir string) (*appServerClient, error) {
+func newAppServerClient(bin string, args []string, workdir string, profile envprofile.Profile, limits ResourceLimits, d dialect) (*appServerClient, error) {
 	childCtx, cancel := context.WithCancel(context.Background())
-	cmd := exec.CommandContext(childCtx, bin, args...)
+	cmd := envprofile.Command(childCtx, bin, args, profile)
 	cmd.Dir = workdir
+	if limits.enabled() {
+		prepareLimitedCommand(cmd)
+	}
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -79,6 +108,18 @@ func newAppServerClient(bin string, args []string, workdir string) (*appServerCl
 		stdin:   stdin,
 		cancel:  cancel,
 		pending: map[string]chan rpcResult{},
+		group:   limits.enabled(),
+		dialect: d,
+	}
+	if err := applySpawnLimits(cmd.Process.Pid, limits); err != nil {
+		c.kill(fmt.Errorf("apply resource limits: %w", err))
+		_ = cmd.Wait()
+		return nil, fmt.Errorf("apply resource limits: %w", err)
+	}
+	if limits.MaxLifetime > 0 {
+		c.lifetime = time.AfterFunc(limits.MaxLifetime, func() {
+			c.kill(fmt.Errorf("%w: lifetime of %s reached", ErrResourceLimit, limits.MaxLifetime))
+		})
 	}
 	go c.readStdout(stdout)
 	go c.readStderr(stderr)
@@ -86,7 +127,20 @@ func newAppServerClient(bin string, args []string, workdir string) (*appServerCl
 	return c, nil
 }
 
+// Call sends one JSON-RPC request to the app-server under a client span.
 func (c *appServerClient) Call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	ctx, span := tracing.Start(ctx, "session.rpc "+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("rpc.system", "jsonrpc"), attribute.String("rpc.method", method)))
+	result, err := c.call(ctx, method, params)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (c *appServerClient) call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	if c == nil {
+		return nil, errNoAppServer
+	}
 	id := uuid.NewString()
 	idKey := normalizeIDKey(id)
 	ch := make(chan rpcResult, 1)
@@ -114,7 +168,7 @@ func (c *appServerClient) Call(ctx context.Context, method string, params any) (
 		return nil, ctx.Err()
 	case out := <-ch:
 		if out.err != nil {
-			return nil, fmt.Errorf("rpc %s failed (%d): %s", method, out.err.Code, out.err.Message)
+			return nil, &rpcCallError{method: method, code: out.err.Code, message: out.err.Message}
 		}
 		return out.result, nil
 	}
@@ -163,7 +217,29 @@ func (c *appServerClient) Close() error {
 }
 
 func (c *appServerClient) writeEnvelope(env rpcEnvelope) error {
-	payload, err := json.Marshal(env)
+	if c == nil {
+		return errNoAppServer
+	}
+	if c.dialect == nil {
+		return c.writeLine(env)
+	}
+	wire, local, err := c.dialect.outgoing(env)
+	if err != nil {
+		return err
+	}
+	for _, msg := range wire {
+		if err := c.writeLine(msg); err != nil {
+			return err
+		}
+	}
+	for _, out := range local {
+		c.dispatchEnvelope(out)
+	}
+	return nil
+}
+
+func (c *appServerClient) writeLine(msg any) error {
+	payload, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
@@ -178,8 +254,38 @@ func (c *appServerClient) writeEnvelope(env rpcEnvelope) error {
 	return nil
 }
 
+func (c *appServerClient) pid() int {
+	if c == nil || c.cmd.Process == nil {
+		return 0
+	}
+	return c.cmd.Process.Pid
+}
+
+// kill stops the process and everything it spawned, reporting err as the
+// exit error. Only the first reason is kept.
+func (c *appServerClient) kill(err error) {
+	c.mu.Lock()
+	if c.closed || c.killErr != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.killErr = err
+	c.mu.Unlock()
+	if !c.group || killProcessTree(c.cmd.Process.Pid) != nil {
+		c.cancel()
+	}
+}
+
 func (c *appServerClient) waitExit() {
 	err := c.cmd.Wait()
+	c.mu.Lock()
+	if c.killErr != nil {
+		err = c.killErr
+	}
+	if c.lifetime != nil {
+		c.lifetime.Stop()
+	}
+	c.mu.Unlock()
 	if c.onClose != nil {
 		c.onClose(err)
 	}
@@ -208,61 +314,89 @@ func (c *appServerClient) readStdout(stdout io.Reader) {
 		if line == "" {
 			continue
 		}
-		var raw map[string]json.RawMessage
-		if err := json.Unmarshal([]byte(line), &raw); err != nil {
+		if c.dialect == nil {
+			c.dispatchLine(line)
+			continue
+		}
+		envs, err := c.dialect.incoming([]byte(line))
+		if err != nil {
 			if c.onStderr != nil {
-				c.onStderr("invalid json-rpc line: " + line)
+				c.onStderr("invalid " + c.dialect.name() + " line: " + line)
 			}
 			continue
 		}
+		for _, env := range envs {
+			c.dispatchEnvelope(env)
+		}
+	}
+}
 
-		methodRaw, hasMethod := raw["method"]
-		idRaw, hasID := raw["id"]
-		if hasMethod {
-			var method string
-			_ = json.Unmarshal(methodRaw, &method)
-			params := map[string]any{}
-			if paramsRaw, ok := raw["params"]; ok && len(paramsRaw) > 0 {
-				_ = json.Unmarshal(paramsRaw, &params)
-			}
-			if hasID {
-				wireID := unmarshalWireID(idRaw)
-				if c.onRequest != nil {
-					c.onRequest(normalizeIDKey(wireID), wireID, method, params)
-				}
-			} else if c.onNotification != nil {
-				c.onNotification(method, params)
-			}
-			continue
+func (c *appServerClient) dispatchEnvelope(env rpcEnvelope) {
+	payload, err := json.Marshal(env)
+	if err != nil {
+		return
+	}
+	c.dispatchLine(string(payload))
+}
+
+// dispatchLine routes one app-server message to a pending call or to the
+// notification and request callbacks.
+func (c *appServerClient) dispatchLine(line string) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(line), &raw); err != nil {
+		if c.onStderr != nil {
+			c.onStderr("invalid json-rpc line: " + line)
 		}
+		return
+	}
 
+	methodRaw, hasMethod := raw["method"]
+	idRaw, hasID := raw["id"]
+	if hasMethod {
+		var method string
+		_ = json.Unmarshal(methodRaw, &method)
+		params := map[string]any{}
+		if paramsRaw, ok := raw["params"]; ok && len(paramsRaw) > 0 {
+			_ = json.Unmarshal(paramsRaw, &params)
+		}
 		if hasID {
 			wireID := unmarshalWireID(idRaw)
-			idKey := normalizeIDKey(wireID)
-			var out rpcResult
-			if resultRaw, ok := raw["result"]; ok {
-				out.result = resultRaw
-			}
-			if errRaw, ok := raw["error"]; ok {
-				var rpcErr rpcError
-				if err := json.Unmarshal(errRaw, &rpcErr); err == nil {
-					out.err = &rpcErr
-				} else {
-					out.err = &rpcError{Code: -1, Message: string(errRaw)}
-				}
-			}
-			c.mu.Lock()
-			ch, ok := c.pending[idKey]
-			if ok {
-				delete(c.pending, idKey)
-			}
-			c.mu.Unlock()
-			if ok {
-				ch <- out
-				close(ch)
+			if c.onRequest != nil {
+				c.onRequest(normalizeIDKey(wireID), wireID, method, params)
 			}
+		} else if c.onNotification != nil {
+			c.onNotification(method, params)
+		}
+		return
+	}
+
+	if !hasID {
+		return
+	}
+	wireID := unmarshalWireID(idRaw)
+	idKey := normalizeIDKey(wireID)
+	var out rpcResult
+	if resultRaw, ok := raw["result"]; ok {
+		out.result = resultRaw
+	}
+	if errRaw, ok := raw["error"]; ok {
+		var rpcErr rpcError
+		if err := json.Unmarshal(errRaw, &rpcErr); err == nil {
+			out.err = &rpcErr
+		} else {
+			out.err = &rpcError{Code: -1, Message: string(errRaw)}
 		}
 	}
+	c.mu.Lock()
+	ch, ok := c.pending[idKey]
+	if ok {
+		delete(c.pending, idKey)
+	}
+	c.mu.Unlock()
+	if ok {
+		ch <- out
+		close(ch)
+	}
 }
 
 func mustMarshalRaw(v any) json.RawMessage {
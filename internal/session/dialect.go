@@ -0,0 +1,114 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Session protocols a backend binary can speak. The service itself always
+// speaks the codex app-server protocol; the other protocols are translated
+// by a dialect inside the client.
+const (
+	ProtocolAppServer  = "app-server"
+	ProtocolACP        = "acp"
+	ProtocolStreamJSON = "stream-json"
+)
+
+// dialect translates between the app-server messages the service sends and
+// receives and a backend's native session protocol.
+type dialect interface {
+	name() string
+	// outgoing translates one message written by the service into lines
+	// for the backend and envelopes answered locally to the service.
+	outgoing(env rpcEnvelope) (wire []any, local []rpcEnvelope, err error)
+	// incoming translates one line from the backend into app-server
+	// messages.
+	incoming(line []byte) ([]rpcEnvelope, error)
+}
+
+const rpcMethodNotFound = -32601
+
+func defaultProtocol(backend string) string {
+	switch backend {
+	case BackendGemini:
+		return ProtocolACP
+	case BackendClaude:
+		return ProtocolStreamJSON
+	default:
+		return ProtocolAppServer
+	}
+}
+
+func normalizeProtocol(v, backend string) string {
+	switch p := strings.ToLower(strings.TrimSpace(v)); p {
+	case "":
+		return defaultProtocol(backend)
+	case "appserver", "app_server":
+		return ProtocolAppServer
+	case "stream_json", "streamjson":
+		return ProtocolStreamJSON
+	default:
+		return p
+	}
+}
+
+// command returns the arguments and dialect for one launch. threadID is the
+// thread being resumed, if any; stream-json binds the session id on the
+// command line.
+func (l backendLaunch) command(threadID, workspacePath string) ([]string, dialect, error) {
+	switch l.protocol {
+	case ProtocolAppServer:
+		return l.args, nil, nil
+	case ProtocolACP:
+		args := append([]string{"--experimental-acp"}, l.args...)
+		return args, newACPDialect(workspacePath), nil
+	case ProtocolStreamJSON:
+		args := []string{"-p", "--input-format", "stream-json", "--output-format", "stream-json", "--verbose", "--permission-prompt-tool", "stdio"}
+		sessionID := strings.TrimSpace(threadID)
+		if sessionID != "" {
+			args = append(args, "--resume", sessionID)
+		} else {
+			sessionID = uuid.NewString()
+			args = append(args, "--session-id", sessionID)
+		}
+		return append(args, l.args...), newClaudeDialect(sessionID), nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported session protocol %q", l.protocol)
+	}
+}
+
+func localResult(id any, result any) rpcEnvelope {
+	return rpcEnvelope{ID: id, Result: mustMarshalRaw(result)}
+}
+
+func localError(id any, code int, message string) rpcEnvelope {
+	return rpcEnvelope{ID: id, Error: &rpcError{Code: code, Message: message}}
+}
+
+func localNotification(method string, params any) rpcEnvelope {
+	return rpcEnvelope{Method: method, Params: mustMarshalRaw(params)}
+}
+
+func decodeParams(raw json.RawMessage) map[string]any {
+	out := map[string]any{}
+	if len(raw) > 0 {
+		_ = json.Unmarshal(raw, &out)
+	}
+	return out
+}
+
+// approvalDecision reads the result the service sends for an approval
+// request.
+func approvalDecision(result json.RawMessage) (accept, forSession bool) {
+	var decision struct {
+		Decision       string `json:"decision"`
+		AcceptSettings struct {
+			ForSession bool `json:"forSession"`
+		} `json:"acceptSettings"`
+	}
+	_ = json.Unmarshal(result, &decision)
+	return decision.Decision == "accept", decision.AcceptSettings.ForSession
+}
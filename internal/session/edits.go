@@ -0,0 +1,104 @@
+package session
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// identityParams tie a server request to its thread/turn/item and can never be
+// edited by the resolver.
+var identityParams = map[string]struct{}{
+	"threadId": {},
+	"turnId":   {},
+	"itemId":   {},
+	"callId":   {},
+}
+
+// applyParamEdits validates resolver edits against the original request
+// params and returns only the accepted edited values. Edits may change
+// existing params (keeping their JSON type); path-like params must stay inside
+// the policy's workspace roots.
+func (s *Service) applyParamEdits(workspacePath string, params, edits map[string]any) (map[string]any, error) {
+	if len(edits) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]any, len(edits))
+	for key, value := range edits {
+		if _, ok := identityParams[key]; ok {
+			return nil, fmt.Errorf("param %q cannot be edited", key)
+		}
+		orig, ok := params[key]
+		if !ok {
+			return nil, fmt.Errorf("param %q is not present in the request", key)
+		}
+		if orig != nil && jsonKind(orig) != jsonKind(value) {
+			return nil, fmt.Errorf("param %q must stay a %s", key, jsonKind(orig))
+		}
+		switch {
+		case key == "command":
+			if err := validateCommandEdit(value); err != nil {
+				return nil, err
+			}
+		case isPathParam(key):
+			path, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("param %q must be a string", key)
+			}
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(workspacePath, path)
+			}
+			if err := s.policy.ValidateWorkspace(path); err != nil {
+				return nil, fmt.Errorf("param %q: %w", key, err)
+			}
+		}
+		out[key] = value
+	}
+	return out, nil
+}
+
+func validateCommandEdit(value any) error {
+	switch v := value.(type) {
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return fmt.Errorf("param \"command\" cannot be empty")
+		}
+	case []any:
+		if len(v) == 0 {
+			return fmt.Errorf("param \"command\" cannot be empty")
+		}
+		for _, arg := range v {
+			if _, ok := arg.(string); !ok {
+				return fmt.Errorf("param \"command\" must contain only strings")
+			}
+		}
+	}
+	return nil
+}
+
+func isPathParam(key string) bool {
+	switch key {
+	case "cwd", "path", "grantRoot":
+		return true
+	}
+	return strings.HasSuffix(key, "Path")
+}
+
+func jsonKind(v any) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64, int, int64:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
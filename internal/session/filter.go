@@ -0,0 +1,98 @@
+package session
+
+import "strings"
+
+// EventFilter selects the session events a subscriber receives. Types and
+// Methods, when set, are allow lists; the Exclude lists win over them. A
+// method ending in "*" matches by prefix, e.g. "item/*". The
+// session/compacted marker always passes, as do the hub's gap markers, so
+// clients can tell what they missed.
+type EventFilter struct {
+	Types          []string
+	Methods        []string
+	ExcludeTypes   []string
+	ExcludeMethods []string
+}
+
+// ParseEventFilter builds a filter from comma-separated lists.
+func ParseEventFilter(types, methods, excludeTypes, excludeMethods string) EventFilter {
+	return EventFilter{
+		Types:          splitFilterList(types),
+		Methods:        splitFilterList(methods),
+		ExcludeTypes:   splitFilterList(excludeTypes),
+		ExcludeMethods: splitFilterList(excludeMethods),
+	}
+}
+
+func (f EventFilter) Empty() bool {
+	return len(f.Types) == 0 && len(f.Methods) == 0 && len(f.ExcludeTypes) == 0 && len(f.ExcludeMethods) == 0
+}
+
+func (f EventFilter) Match(ev Event) bool {
+	if f.Empty() || isHistoryMarker(ev) {
+		return true
+	}
+	if containsFold(f.ExcludeTypes, ev.Type) || matchMethod(f.ExcludeMethods, ev.Method) {
+		return false
+	}
+	if len(f.Types) > 0 && !containsFold(f.Types, ev.Type) {
+		return false
+	}
+	if len(f.Methods) > 0 && !matchMethod(f.Methods, ev.Method) {
+		return false
+	}
+	return true
+}
+
+// Apply returns the events f matches.
+func (f EventFilter) Apply(evs []Event) []Event {
+	if f.Empty() {
+		return evs
+	}
+	out := make([]Event, 0, len(evs))
+	for _, ev := range evs {
+		if f.Match(ev) {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+func isHistoryMarker(ev Event) bool {
+	return ev.Type == "status" && ev.Method == methodHistoryCompacted
+}
+
+func matchMethod(patterns []string, method string) bool {
+	if method == "" {
+		return false
+	}
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(method, prefix) {
+				return true
+			}
+		} else if p == method {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, v string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitFilterList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,257 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"echohelix/internal/auth"
+)
+
+// GuardrailResolver is the resolved_by of approvals the guard declined.
+const GuardrailResolver = "guardrail"
+
+// CommandGuardRule denies approval requests whose command matches Pattern,
+// a regular expression.
+type CommandGuardRule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// DefaultCommandGuardRules catch commands no agent should run on the host.
+var DefaultCommandGuardRules = []CommandGuardRule{
+	{Name: "rm_root", Pattern: `\brm\s+(-\S+\s+)*-\S*[rR]\S*\s+(-\S+\s+)*(--no-preserve-root\s+)?("|')?(/|/\*|~/?|\$HOME/?)("|')?(\s|;|&|\||$)`},
+	{Name: "pipe_to_shell", Pattern: `\b(curl|wget|fetch)\b[^|;&]*\|\s*(sudo\s+)?(env\s+)?(ba|z|da|k|fi)?sh\b`},
+	{Name: "mkfs", Pattern: `\bmkfs(\.\w+)?\b`},
+	{Name: "dd_device", Pattern: `\bdd\b.*\bof=/dev/`},
+	{Name: "fork_bomb", Pattern: `:\(\)\s*\{\s*:\s*\|\s*:\s*&\s*\}\s*;\s*:`},
+	{Name: "chmod_root", Pattern: `\bchmod\s+(-\S+\s+)*[0-7]*777\s+/(\s|$)`},
+}
+
+// LoadCommandGuardRules reads a JSON array of {name, pattern} rules.
+func LoadCommandGuardRules(path string) ([]CommandGuardRule, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read command denylist: %w", err)
+	}
+	var rules []CommandGuardRule
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return nil, fmt.Errorf("parse command denylist: %w", err)
+	}
+	return rules, nil
+}
+
+// CommandGuard declines approval requests for denied commands, and with
+// WorkspaceOnly for commands or file changes aimed outside the session
+// workspace, before any device can accept them.
+type CommandGuard struct {
+	rules         []guardRule
+	workspaceOnly bool
+}
+
+type guardRule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// GuardMatch names the rule a request broke and what matched.
+type GuardMatch struct {
+	Rule   string `json:"rule"`
+	Match  string `json:"match"`
+	Method string `json:"method"`
+}
+
+func NewCommandGuard(rules []CommandGuardRule, workspaceOnly bool) (*CommandGuard, error) {
+	g := &CommandGuard{workspaceOnly: workspaceOnly}
+	for _, r := range rules {
+		name := strings.TrimSpace(r.Name)
+		if name == "" {
+			return nil, fmt.Errorf("command guard rule needs a name")
+		}
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("command guard rule %s: %w", name, err)
+		}
+		g.rules = append(g.rules, guardRule{name: name, re: re})
+	}
+	return g, nil
+}
+
+// SetCommandGuard installs the approval guard; nil disables it.
+func (s *Service) SetCommandGuard(g *CommandGuard) {
+	s.mu.Lock()
+	s.guard = g
+	s.mu.Unlock()
+}
+
+// SetSecurityAlertNotifier receives guardrail alerts in addition to the
+// security_alert log line and session event.
+func (s *Service) SetSecurityAlertNotifier(fn auth.SecurityAlertNotifier) {
+	s.mu.Lock()
+	s.alertNotifier = fn
+	s.mu.Unlock()
+}
+
+// check reports the first rule params break for an approval request in
+// workspace; nil when the request may go to a device.
+func (g *CommandGuard) check(method, workspace string, params map[string]any) *GuardMatch {
+	if g == nil || requestKind(method) != "approval" {
+		return nil
+	}
+	input, _ := params["input"].(map[string]any)
+	if cmd := approvalCommand(params, input); cmd != "" {
+		for _, r := range g.rules {
+			if m := r.re.FindString(cmd); m != "" {
+				return &GuardMatch{Rule: r.name, Match: m, Method: method}
+			}
+		}
+	}
+	if !g.workspaceOnly || workspace == "" {
+		return nil
+	}
+	base := approvalDir(workspace, params, input)
+	if !isWithinDir(workspace, base) {
+		return &GuardMatch{Rule: "outside_workspace", Match: base, Method: method}
+	}
+	for _, p := range approvalPaths(params, input) {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(base, p)
+		}
+		if !isWithinDir(workspace, p) {
+			return &GuardMatch{Rule: "outside_workspace", Match: p, Method: method}
+		}
+	}
+	return nil
+}
+
+// approvalDir is the directory relative paths of a request resolve against:
+// its cwd, itself relative to the workspace, or else the workspace.
+func approvalDir(workspace string, params, input map[string]any) string {
+	for _, src := range []map[string]any{params, input} {
+		if cwd, ok := src["cwd"].(string); ok && cwd != "" {
+			if filepath.IsAbs(cwd) {
+				return cwd
+			}
+			return filepath.Join(workspace, cwd)
+		}
+	}
+	return workspace
+}
+
+func approvalCommand(params, input map[string]any) string {
+	for _, v := range []any{params["command"], input["command"]} {
+		switch c := v.(type) {
+		case string:
+			if c != "" {
+				return c
+			}
+		case []any:
+			parts := make([]string, 0, len(c))
+			for _, arg := range c {
+				if s, ok := arg.(string); ok {
+					parts = append(parts, s)
+				}
+			}
+			return strings.Join(parts, " ")
+		}
+	}
+	return ""
+}
+
+func approvalPaths(params, input map[string]any) []string {
+	var out []string
+	for _, src := range []map[string]any{params, input} {
+		for key, v := range src {
+			if s, ok := v.(string); ok && s != "" && (isPathParam(key) || key == "file_path" || key == "notebook_path") {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+func isWithinDir(dir, path string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, filepath.Clean(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func (s *Service) commandGuard() (*CommandGuard, auth.SecurityAlertNotifier) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.guard, s.alertNotifier
+}
+
+// guardRequest declines a server request the guard denies and reports
+// whether it did.
+func (s *Service) guardRequest(st *sessionState, reqIDKey string, wireID any, obj PendingRequest) bool {
+	guard, _ := s.commandGuard()
+	st.mu.Lock()
+	sess := st.session
+	st.mu.Unlock()
+	match := guard.check(obj.Method, sess.WorkspacePath, obj.Params)
+	if match == nil {
+		return false
+	}
+	obj.Resolved = true
+	obj.ResolvedAt = time.Now().UTC()
+	obj.ResolvedBy = GuardrailResolver
+	st.mu.Lock()
+	st.pending[reqIDKey] = &pendingRequestState{obj: obj, wireID: wireID}
+	st.mu.Unlock()
+	s.publish(st, "request", obj.Method, map[string]any{
+		"request_id": reqIDKey,
+		"method":     obj.Method,
+		"kind":       obj.Kind,
+		"params":     obj.Params,
+	})
+	result := map[string]any{"decision": "decline"}
+	if err := st.rpc().ReplyResult(wireID, result); err != nil {
+		s.publish(st, "status", "guardrail/reply_failed", map[string]any{"request_id": reqIDKey, "error": err.Error()})
+	}
+	s.raiseGuardAlert(st, sess, reqIDKey, *match)
+	s.publish(st, "request_resolved", obj.Method, map[string]any{
+		"request_id":  reqIDKey,
+		"result":      result,
+		"resolved_by": GuardrailResolver,
+		"guardrail":   match,
+	})
+	return true
+}
+
+// editsBlocked checks an approval as the resolver's edits would change
+// it.
+func (g *CommandGuard) editsBlocked(workspace string, pending PendingRequest, edited map[string]any) *GuardMatch {
+	if len(edited) == 0 {
+		return nil
+	}
+	merged := make(map[string]any, len(pending.Params)+len(edited))
+	for k, v := range pending.Params {
+		merged[k] = v
+	}
+	for k, v := range edited {
+		merged[k] = v
+	}
+	return g.check(pending.Method, workspace, merged)
+}
+
+func (s *Service) raiseGuardAlert(st *sessionState, sess Session, requestID string, match GuardMatch) {
+	_, notifier := s.commandGuard()
+	s.publish(st, "security_alert", "guardrail/blocked", map[string]any{
+		"request_id": requestID,
+		"rule":       match.Rule,
+		"match":      match.Match,
+		"method":     match.Method,
+	})
+	auth.DispatchSecurityAlert(notifier, auth.SecurityAlert{
+		Event:  "command_guardrail",
+		Detail: fmt.Sprintf("session_id=%s backend=%s workspace=%s rule=%s match=%q", sess.ID, sess.Backend, sess.WorkspacePath, match.Rule, match.Match),
+	})
+}
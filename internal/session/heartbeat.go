@@ -0,0 +1,234 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HeartbeatPolicy controls background pings to app-server processes. A ping
+// answered with a JSON-RPC error still proves the process is responsive;
+// only timeouts and transport errors count as failures.
+type HeartbeatPolicy struct {
+	Interval         time.Duration
+	Timeout          time.Duration
+	FailureThreshold int
+	// Method is the JSON-RPC method used as a ping, "status" by default.
+	Method string
+	// AutoRestart relaunches a degraded session's process and resumes its
+	// thread.
+	AutoRestart bool
+}
+
+func (s *Service) SetHeartbeatPolicy(p HeartbeatPolicy) {
+	if p.Timeout <= 0 {
+		p.Timeout = 5 * time.Second
+	}
+	if p.FailureThreshold <= 0 {
+		p.FailureThreshold = 3
+	}
+	if strings.TrimSpace(p.Method) == "" {
+		p.Method = "status"
+	}
+	s.mu.Lock()
+	s.heartbeat = p
+	s.mu.Unlock()
+}
+
+func (s *Service) heartbeatPolicy() HeartbeatPolicy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.heartbeat
+}
+
+// StartHeartbeat pings every live session each policy interval until ctx is
+// done. It does nothing when no interval is configured.
+func (s *Service) StartHeartbeat(ctx context.Context) {
+	interval := s.heartbeatPolicy().Interval
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			s.HeartbeatOnce(ctx)
+		}
+	}()
+}
+
+// HeartbeatOnce pings all ready and degraded sessions concurrently and waits
+// for the results.
+func (s *Service) HeartbeatOnce(ctx context.Context) {
+	p := s.heartbeatPolicy()
+	if p.FailureThreshold <= 0 {
+		return
+	}
+	s.mu.Lock()
+	states := make([]*sessionState, 0, len(s.sessions))
+	for _, st := range s.sessions {
+		states = append(states, st)
+	}
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, st := range states {
+		st.mu.Lock()
+		live := st.session.Status == StatusReady || st.session.Status == StatusDegraded
+		st.mu.Unlock()
+		if !live {
+			continue
+		}
+		wg.Add(1)
+		go func(st *sessionState) {
+			defer wg.Done()
+			s.pingSession(ctx, st, p)
+		}(st)
+	}
+	wg.Wait()
+}
+
+func (s *Service) pingSession(ctx context.Context, st *sessionState, p HeartbeatPolicy) {
+	client := st.rpc()
+	if client == nil {
+		return
+	}
+	callCtx, cancel := context.WithTimeout(ctx, p.Timeout)
+	// Untraced: heartbeats would add a root span per session per interval.
+	_, err := client.call(callCtx, p.Method, nil)
+	cancel()
+	var callErr *rpcCallError
+	if errors.As(err, &callErr) && callErr.code != -1 {
+		err = nil
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	st.mu.Lock()
+	if st.client != client || isTerminalSessionStatus(st.session.Status) {
+		st.mu.Unlock()
+		return
+	}
+	if err == nil {
+		recovered := st.session.Status == StatusDegraded
+		st.session.HeartbeatFailures = 0
+		if recovered {
+			st.session.Status = StatusReady
+			st.session.Error = ""
+		}
+		st.mu.Unlock()
+		if recovered {
+			s.publish(st, "status", "session/recovered", nil)
+		}
+		return
+	}
+	st.session.HeartbeatFailures++
+	failures := st.session.HeartbeatFailures
+	degraded := failures >= p.FailureThreshold && st.session.Status != StatusDegraded
+	if degraded {
+		st.session.Status = StatusDegraded
+		st.session.Error = fmt.Sprintf("heartbeat failed: %v", err)
+	}
+	st.mu.Unlock()
+
+	if !degraded {
+		return
+	}
+	s.publish(st, "status", "session/degraded", map[string]any{
+		"failures": failures,
+		"error":    err.Error(),
+	})
+	if p.AutoRestart {
+		if err := s.restartSession(ctx, st); err != nil {
+			log.Printf("session restart id=%s: %v", st.session.ID, err)
+			s.publish(st, "status", "session/restart_failed", map[string]any{"error": err.Error()})
+		}
+	}
+}
+
+// restartSession replaces a session's app-server process and resumes its
+// thread. Pending server requests of the old process are dropped.
+func (s *Service) restartSession(ctx context.Context, st *sessionState) error {
+	threadID, err := s.relaunchSession(ctx, st, false)
+	if err != nil {
+		return err
+	}
+	st.mu.Lock()
+	restarts := st.session.Restarts
+	st.mu.Unlock()
+	s.saveSession(st)
+	s.publish(st, "status", "session/restarted", map[string]any{"thread_id": threadID, "restarts": restarts})
+	return nil
+}
+
+// relaunchSession starts a new app-server for st, resumes its thread and
+// swaps the client in. With reopen it also revives a locally closed session;
+// otherwise it counts as a restart.
+func (s *Service) relaunchSession(ctx context.Context, st *sessionState, reopen bool) (string, error) {
+	st.mu.Lock()
+	backend := st.session.Backend
+	workspacePath := st.session.WorkspacePath
+	workspaceID := st.session.WorkspaceID
+	threadID := st.session.ThreadID
+	st.mu.Unlock()
+	launcher, ok := s.launchers[backend]
+	if !ok {
+		return "", fmt.Errorf("unsupported backend %q", backend)
+	}
+
+	startCtx, cancel := requestTimeout(ctx, s.cfg.StartTimeout)
+	defer cancel()
+	client, methods, err := s.launchClient(startCtx, st, launcher, workspacePath, workspaceID, threadID)
+	if err != nil {
+		return "", err
+	}
+	result, err := client.Call(startCtx, "thread/resume", map[string]any{"threadId": threadID})
+	if err != nil {
+		_ = client.Close()
+		return "", err
+	}
+	if id := decodeResultField(result, "thread", "id"); id != "" {
+		threadID = id
+	}
+
+	st.mu.Lock()
+	if st.closedLocally && !reopen {
+		st.mu.Unlock()
+		_ = client.Close()
+		return "", ErrSessionClosed
+	}
+	old := st.client
+	st.client = client
+	st.closedLocally = false
+	st.methods = methods
+	st.unsupported = nil
+	st.pending = map[string]*pendingRequestState{}
+	st.activeTurnID = ""
+	dropped := st.dropTurnsLocked()
+	st.session.ThreadID = threadID
+	st.session.SupportedMethods = s.supportedMethodList(methods)
+	st.session.Status = StatusReady
+	st.session.Error = ""
+	st.session.HeartbeatFailures = 0
+	st.lastTurnAt = time.Now().UTC()
+	if !reopen {
+		st.session.Restarts++
+	}
+	st.mu.Unlock()
+
+	if old != nil {
+		_ = old.Close()
+	}
+	s.publishDroppedTurns(st, dropped, "session relaunched")
+	return threadID, nil
+}
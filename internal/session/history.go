@@ -0,0 +1,84 @@
+package session
+
+const (
+	// historyCap bounds a session's in-memory event history. Once exceeded,
+	// the oldest historyCompactBatch events are folded into one
+	// session/compacted marker instead of being dropped silently.
+	historyCap          = 4000
+	historyCompactBatch = 500
+
+	methodHistoryCompacted = "session/compacted"
+)
+
+// appendHistoryLocked adds ev to the history, compacting the oldest events
+// when the cap is exceeded, and keeps Session.EarliestSeq current. The
+// caller holds st.mu.
+func (st *sessionState) appendHistoryLocked(ev Event) {
+	st.history = append(st.history, ev)
+	if len(st.history) > historyCap {
+		st.history = compactHistory(st.history, historyCompactBatch)
+	}
+	st.session.EarliestSeq = earliestSeq(st.history)
+}
+
+// compactHistory replaces the first n events with a status event carrying
+// the dropped seq range and per-type counts. A marker already at the front
+// is merged, so the history holds at most one. The marker takes the seq of
+// the last dropped event, so replay from any dropped seq starts with it.
+func compactHistory(history []Event, n int) []Event {
+	if n >= len(history) {
+		n = len(history) - 1
+	}
+	if n <= 0 {
+		return history
+	}
+	dropped := history[:n]
+	last := dropped[len(dropped)-1]
+	fromSeq := dropped[0].Seq
+	total := 0
+	counts := map[string]int{}
+	for _, ev := range dropped {
+		if isCompactionMarker(ev) {
+			fromSeq, _ = ev.Payload["from_seq"].(int64)
+			prior, _ := ev.Payload["dropped"].(int)
+			total += prior
+			priorCounts, _ := ev.Payload["counts"].(map[string]int)
+			for typ, c := range priorCounts {
+				counts[typ] += c
+			}
+			continue
+		}
+		total++
+		counts[ev.Type]++
+	}
+	marker := Event{
+		SessionID: last.SessionID,
+		Seq:       last.Seq,
+		TS:        last.TS,
+		Type:      "status",
+		Method:    methodHistoryCompacted,
+		Payload: map[string]any{
+			"from_seq": fromSeq,
+			"to_seq":   last.Seq,
+			"dropped":  total,
+			"counts":   counts,
+		},
+	}
+	out := make([]Event, 0, len(history)-n+1)
+	out = append(out, marker)
+	return append(out, history[n:]...)
+}
+
+func isCompactionMarker(ev Event) bool {
+	return ev.Type == "status" && ev.Method == methodHistoryCompacted
+}
+
+// earliestSeq is the seq of the oldest event still held in full.
+func earliestSeq(history []Event) int64 {
+	for _, ev := range history {
+		if !isCompactionMarker(ev) {
+			return ev.Seq
+		}
+	}
+	return 0
+}
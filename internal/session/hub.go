@@ -1,45 +1,127 @@
 package session
 
-import "sync"
+import (
+	"sync"
+	"time"
 
+	"echohelix/internal/events"
+)
+
+// Hub applies the same backpressure policy as the run hub: full subscribers
+// miss events, get a status gap marker once they drain, and are evicted when
+// they stay saturated.
 type Hub struct {
-	mu   sync.RWMutex
-	subs map[string]map[chan Event]struct{}
+	mu         sync.Mutex
+	subs       map[string]map[chan Event]*hubSub
+	evictAfter time.Duration
+	counters   events.BackpressureCounters
 }
 
 func NewHub() *Hub {
-	return &Hub{subs: map[string]map[chan Event]struct{}{}}
+	return &Hub{
+		subs:       map[string]map[chan Event]*hubSub{},
+		evictAfter: events.DefaultEvictAfter,
+	}
+}
+
+func (h *Hub) SetEvictAfter(d time.Duration) {
+	h.mu.Lock()
+	h.evictAfter = max(d, 0)
+	h.mu.Unlock()
+}
+
+// hubSub is one subscriber's backlog and the filter events must pass
+// before they take up room in its buffer.
+type hubSub struct {
+	backlog events.Backlog
+	filter  EventFilter
 }
 
 func (h *Hub) Subscribe(sessionID string, buf int) (<-chan Event, func()) {
+	return h.SubscribeFiltered(sessionID, buf, EventFilter{})
+}
+
+func (h *Hub) SubscribeFiltered(sessionID string, buf int, filter EventFilter) (<-chan Event, func()) {
 	ch := make(chan Event, buf)
 	h.mu.Lock()
 	if _, ok := h.subs[sessionID]; !ok {
-		h.subs[sessionID] = map[chan Event]struct{}{}
+		h.subs[sessionID] = map[chan Event]*hubSub{}
 	}
-	h.subs[sessionID][ch] = struct{}{}
+	h.subs[sessionID][ch] = &hubSub{filter: filter}
 	h.mu.Unlock()
 	unsub := func() {
 		h.mu.Lock()
 		defer h.mu.Unlock()
-		if sessionSubs, ok := h.subs[sessionID]; ok {
-			delete(sessionSubs, ch)
-			close(ch)
-			if len(sessionSubs) == 0 {
-				delete(h.subs, sessionID)
-			}
-		}
+		h.removeLocked(sessionID, ch)
 	}
 	return ch, unsub
 }
 
+func (h *Hub) removeLocked(sessionID string, ch chan Event) {
+	sessionSubs, ok := h.subs[sessionID]
+	if !ok {
+		return
+	}
+	if _, ok := sessionSubs[ch]; !ok {
+		return
+	}
+	delete(sessionSubs, ch)
+	close(ch)
+	if len(sessionSubs) == 0 {
+		delete(h.subs, sessionID)
+	}
+}
+
 func (h *Hub) Publish(ev Event) {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
-	for ch := range h.subs[ev.SessionID] {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	for ch, sub := range h.subs[ev.SessionID] {
+		if !sub.filter.Match(ev) {
+			continue
+		}
+		if h.deliver(ch, &sub.backlog, ev) {
+			continue
+		}
+		if sub.backlog.Drop(ev.Seq, now, h.evictAfter) {
+			h.counters.Evicted.Add(1)
+			h.removeLocked(ev.SessionID, ch)
+		}
+		h.counters.Dropped.Add(1)
+	}
+}
+
+func (h *Hub) deliver(ch chan Event, backlog *events.Backlog, ev Event) bool {
+	if payload, ok := backlog.PendingGap(); ok {
+		marker := Event{
+			SessionID: ev.SessionID,
+			TS:        time.Now().UTC(),
+			Type:      events.TypeGap,
+			Payload:   payload,
+		}
 		select {
-		case ch <- ev:
+		case ch <- marker:
+			backlog.ClearGap()
+			h.counters.GapMarkers.Add(1)
 		default:
+			return false
 		}
 	}
+	select {
+	case ch <- ev:
+		backlog.Delivered()
+		return true
+	default:
+		return false
+	}
+}
+
+func (h *Hub) Stats() events.BackpressureStats {
+	h.mu.Lock()
+	n := 0
+	for _, sessionSubs := range h.subs {
+		n += len(sessionSubs)
+	}
+	h.mu.Unlock()
+	return h.counters.Stats(n)
 }
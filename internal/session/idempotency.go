@@ -0,0 +1,62 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"echohelix/internal/ledger"
+)
+
+// SetIdempotencyWindow sets how long a turn's client_request_id is
+// remembered; zero or less restores ledger.DefaultIdempotencyWindow.
+func (s *Service) SetIdempotencyWindow(window time.Duration) {
+	s.mu.Lock()
+	s.idempotencyWindow = window
+	s.mu.Unlock()
+}
+
+// StartTurn starts (or queues) a turn. With a ClientRequestID and a ledger,
+// the key is claimed per session and actor first, so a retried request
+// gets the original result back instead of a second turn.
+func (s *Service) StartTurn(ctx context.Context, sessionID string, req StartTurnRequest) (StartTurnResult, error) {
+	store := s.sessionLedger()
+	key := req.ClientRequestID
+	if key == "" || store == nil {
+		return s.startTurn(ctx, sessionID, req)
+	}
+	s.mu.Lock()
+	window := s.idempotencyWindow
+	s.mu.Unlock()
+	if window <= 0 {
+		window = ledger.DefaultIdempotencyWindow
+	}
+	scope := "turns:" + sessionID + ":" + ActorFrom(ctx)
+	fingerprint := ledger.IdempotencyFingerprint(req)
+	rec, claimed, err := store.ClaimIdempotencyKey(ctx, scope, key, fingerprint, time.Now().UTC(), window)
+	if err != nil {
+		return StartTurnResult{}, err
+	}
+	if !claimed {
+		switch {
+		case rec.Fingerprint != fingerprint:
+			return StartTurnResult{}, ledger.ErrIdempotencyKeyReused
+		case rec.Result == "":
+			return StartTurnResult{}, ledger.ErrIdempotencyKeyInProgress
+		}
+		var out StartTurnResult
+		if err := json.Unmarshal([]byte(rec.Result), &out); err != nil {
+			return StartTurnResult{}, err
+		}
+		out.Replayed = true
+		return out, nil
+	}
+	out, err := s.startTurn(ctx, sessionID, req)
+	if err != nil {
+		_ = store.ReleaseIdempotencyKey(context.WithoutCancel(ctx), scope, key)
+		return StartTurnResult{}, err
+	}
+	raw, _ := json.Marshal(out)
+	_ = store.CompleteIdempotencyKey(context.WithoutCancel(ctx), scope, key, string(raw))
+	return out, nil
+}
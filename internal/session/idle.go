@@ -0,0 +1,116 @@
+package session
+
+import (
+	"context"
+	"time"
+)
+
+// SetIdleSuspend stops the app-server of a ready session after it has gone
+// idleAfter without a turn. The session keeps its record and thread and is
+// relaunched on the next turn. Zero disables suspension.
+func (s *Service) SetIdleSuspend(idleAfter time.Duration) {
+	s.mu.Lock()
+	s.idleAfter = idleAfter
+	s.mu.Unlock()
+}
+
+func (s *Service) idleSuspendAfter() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.idleAfter
+}
+
+// StartIdleSuspender checks for idle sessions until ctx is done.
+func (s *Service) StartIdleSuspender(ctx context.Context) {
+	idleAfter := s.idleSuspendAfter()
+	if idleAfter <= 0 {
+		return
+	}
+	interval := idleAfter / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			s.SuspendIdle(time.Now().UTC())
+		}
+	}()
+}
+
+// SuspendIdle suspends ready sessions whose last turn started or finished
+// before now minus the idle timeout. Sessions with a running turn or
+// unanswered server requests are left alone. It returns how many were
+// suspended.
+func (s *Service) SuspendIdle(now time.Time) int {
+	idleAfter := s.idleSuspendAfter()
+	if idleAfter <= 0 {
+		return 0
+	}
+	cutoff := now.Add(-idleAfter)
+	s.mu.Lock()
+	states := make([]*sessionState, 0, len(s.sessions))
+	for _, st := range s.sessions {
+		states = append(states, st)
+	}
+	s.mu.Unlock()
+
+	suspended := 0
+	for _, st := range states {
+		st.mu.Lock()
+		idle := st.session.Status == StatusReady && !st.turnBusy && st.activeTurnID == "" && len(st.pending) == 0 && st.lastTurnAt.Before(cutoff)
+		var client *appServerClient
+		if idle {
+			client = st.client
+			st.closedLocally = true
+			st.session.Status = StatusSuspended
+			st.session.UpdatedAt = now
+		}
+		st.mu.Unlock()
+		if !idle {
+			continue
+		}
+		if client != nil {
+			_ = client.Close()
+		}
+		s.saveSession(st)
+		s.publish(st, "status", "session/suspended", map[string]any{"idle_seconds": int(now.Sub(st.idleSince()).Seconds())})
+		suspended++
+	}
+	return suspended
+}
+
+func (st *sessionState) idleSince() time.Time {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.lastTurnAt
+}
+
+// wake relaunches a suspended session before a turn. Concurrent callers
+// wait for the first relaunch instead of starting their own.
+func (s *Service) wake(ctx context.Context, st *sessionState) error {
+	st.wakeMu.Lock()
+	defer st.wakeMu.Unlock()
+	st.mu.Lock()
+	suspended := st.session.Status == StatusSuspended
+	st.mu.Unlock()
+	if !suspended {
+		return nil
+	}
+	threadID, err := s.relaunchSession(ctx, st, true)
+	if err != nil {
+		return err
+	}
+	s.saveSession(st)
+	s.publish(st, "status", "session/resumed", map[string]any{"thread_id": threadID, "from": StatusSuspended})
+	return nil
+}
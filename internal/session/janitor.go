@@ -0,0 +1,108 @@
+package session
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"echohelix/internal/ledger"
+)
+
+func (s *Service) runJanitor() {
+	defer close(s.janitorDone)
+	ticker := time.NewTicker(s.cfg.SessionCleanupPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopJanitor:
+			return
+		case <-ticker.C:
+			s.cleanupSessions(time.Now().UTC())
+		}
+	}
+}
+
+// cleanupSessions drops closed, failed and detached sessions last updated
+// before the retention window, both from memory and from the ledger.
+// Suspended sessions are kept until they are closed. A workspace's
+// transcript retention override replaces the window for its sessions.
+func (s *Service) cleanupSessions(now time.Time) {
+	if s.cfg.SessionRetention <= 0 {
+		return
+	}
+	cutoff := now.Add(-s.cfg.SessionRetention)
+	overrides := s.policy.HasRetentionOverrides()
+	cutoffFor := func(workspaceID, workspacePath string) time.Time {
+		if overrides {
+			if d := s.policy.RetentionFor(workspaceID, workspacePath).TranscriptRetention(); d > 0 {
+				return now.Add(-d)
+			}
+		}
+		return cutoff
+	}
+
+	var expired []*sessionState
+	s.mu.Lock()
+	for id, st := range s.sessions {
+		st.mu.Lock()
+		status := st.session.Status
+		updatedAt := st.session.UpdatedAt
+		workspaceID, workspacePath := st.session.WorkspaceID, st.session.WorkspacePath
+		st.mu.Unlock()
+		if !isTerminalSessionStatus(status) || updatedAt.After(cutoffFor(workspaceID, workspacePath)) {
+			continue
+		}
+		delete(s.sessions, id)
+		s.dropSessionToolsLocked(id)
+		expired = append(expired, st)
+	}
+	store := s.ledger
+	s.mu.Unlock()
+
+	if store == nil {
+		return
+	}
+	// Persist the final state first so a record whose last save failed
+	// is still expired with the right status.
+	for _, st := range expired {
+		s.saveSession(st)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if overrides {
+		s.expirePersistedSessions(ctx, store, cutoffFor)
+		return
+	}
+	n, err := store.DeleteAgentSessionsBefore(ctx, cutoff, []string{StatusClosed, StatusFailed, StatusDetached})
+	if err != nil {
+		log.Printf("warn: expire persisted sessions: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("session cleanup: expired %d persisted sessions", n)
+	}
+}
+
+// expirePersistedSessions deletes terminal sessions one by one, each against
+// its workspace's cutoff.
+func (s *Service) expirePersistedSessions(ctx context.Context, store *ledger.Store, cutoffFor func(workspaceID, workspacePath string) time.Time) {
+	recs, err := store.ListAgentSessionsUpdatedSince(ctx, time.Time{})
+	if err != nil {
+		log.Printf("warn: expire persisted sessions: %v", err)
+		return
+	}
+	n := 0
+	for _, rec := range recs {
+		if !isTerminalSessionStatus(rec.Status) || !rec.UpdatedAt.Before(cutoffFor(rec.WorkspaceID, rec.WorkspacePath)) {
+			continue
+		}
+		if err := store.DeleteAgentSession(ctx, rec.ID); err != nil {
+			log.Printf("warn: expire persisted session %s: %v", rec.ID, err)
+			continue
+		}
+		n++
+	}
+	if n > 0 {
+		log.Printf("session cleanup: expired %d persisted sessions", n)
+	}
+}
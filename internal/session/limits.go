@@ -0,0 +1,134 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrResourceLimit is wrapped by the error of a session whose app-server was
+// killed for exceeding its ResourceLimits.
+var ErrResourceLimit = errors.New("resource limit exceeded")
+
+// ResourceLimits bound each spawned app-server process. Niceness and the
+// lifetime timer are applied at spawn; memory and child process counts are
+// sampled by the watchdog and cover the whole process tree. Zero values
+// disable a limit.
+type ResourceLimits struct {
+	MaxRSSBytes  int64
+	Nice         int
+	MaxProcesses int
+	MaxLifetime  time.Duration
+	// CheckInterval is how often the watchdog samples, 5s by default.
+	CheckInterval time.Duration
+}
+
+func (l ResourceLimits) enabled() bool {
+	return l.MaxRSSBytes > 0 || l.Nice != 0 || l.MaxProcesses > 0 || l.MaxLifetime > 0
+}
+
+func (l ResourceLimits) sampled() bool {
+	return l.MaxRSSBytes > 0 || l.MaxProcesses > 0
+}
+
+// processUsage is the footprint of an app-server and its descendants.
+type processUsage struct {
+	RSSBytes int64
+	Children int
+}
+
+func (s *Service) SetResourceLimits(l ResourceLimits) {
+	if l.CheckInterval <= 0 {
+		l.CheckInterval = 5 * time.Second
+	}
+	s.mu.Lock()
+	s.limits = l
+	s.mu.Unlock()
+}
+
+func (s *Service) resourceLimits() ResourceLimits {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.limits
+}
+
+// StartResourceWatchdog samples live app-servers until ctx is done. It does
+// nothing unless a memory or process limit is set.
+func (s *Service) StartResourceWatchdog(ctx context.Context) {
+	l := s.resourceLimits()
+	if !l.sampled() {
+		return
+	}
+	if !processSamplingSupported {
+		log.Printf("warn: session memory and process limits are not enforced on this platform")
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(l.CheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			s.EnforceResourceLimits()
+		}
+	}()
+}
+
+// EnforceResourceLimits samples every running app-server once and kills
+// those over a limit. The session fails with an ErrResourceLimit error. It
+// returns how many processes were killed.
+func (s *Service) EnforceResourceLimits() int {
+	l := s.resourceLimits()
+	if !l.sampled() || !processSamplingSupported {
+		return 0
+	}
+	s.mu.Lock()
+	states := make([]*sessionState, 0, len(s.sessions))
+	for _, st := range s.sessions {
+		states = append(states, st)
+	}
+	s.mu.Unlock()
+
+	killed := 0
+	for _, st := range states {
+		client := st.rpc()
+		if client == nil {
+			continue
+		}
+		pid := client.pid()
+		if pid <= 0 {
+			continue
+		}
+		usage, err := sampleProcessTree(pid)
+		if err != nil {
+			continue
+		}
+		if err := l.check(usage); err != nil {
+			log.Printf("session id=%s pid=%d: %v", st.sessionID(), pid, err)
+			client.kill(err)
+			killed++
+		}
+	}
+	return killed
+}
+
+func (l ResourceLimits) check(u processUsage) error {
+	if l.MaxRSSBytes > 0 && u.RSSBytes > l.MaxRSSBytes {
+		return fmt.Errorf("%w: rss %d MiB over limit of %d MiB", ErrResourceLimit, u.RSSBytes>>20, l.MaxRSSBytes>>20)
+	}
+	if l.MaxProcesses > 0 && u.Children > l.MaxProcesses {
+		return fmt.Errorf("%w: %d child processes over limit of %d", ErrResourceLimit, u.Children, l.MaxProcesses)
+	}
+	return nil
+}
+
+func (st *sessionState) sessionID() string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.session.ID
+}
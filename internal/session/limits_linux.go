@@ -0,0 +1,90 @@
+package session
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+const processSamplingSupported = true
+
+// prepareLimitedCommand starts the app-server in its own process group so a
+// violation kills everything it spawned.
+func prepareLimitedCommand(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+func applySpawnLimits(pid int, l ResourceLimits) error {
+	if l.Nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, pid, l.Nice); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func killProcessTree(pid int) error {
+	return syscall.Kill(-pid, syscall.SIGKILL)
+}
+
+// sampleProcessTree sums resident memory over pid and its descendants
+// from /proc.
+func sampleProcessTree(root int) (processUsage, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return processUsage{}, err
+	}
+	children := map[int][]int{}
+	rss := map[int]int64{}
+	pageSize := int64(os.Getpagesize())
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		raw, err := os.ReadFile(filepath.Join("/proc", e.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// The command name may contain spaces and parentheses; fields are
+		// counted from after its closing parenthesis.
+		end := bytes.LastIndexByte(raw, ')')
+		if end < 0 {
+			continue
+		}
+		fields := bytes.Fields(raw[end+1:])
+		if len(fields) < 22 {
+			continue
+		}
+		ppid, _ := strconv.Atoi(string(fields[1]))
+		pages, _ := strconv.ParseInt(string(fields[21]), 10, 64)
+		children[ppid] = append(children[ppid], pid)
+		rss[pid] = pages * pageSize
+	}
+	if _, ok := rss[root]; !ok {
+		return processUsage{}, os.ErrNotExist
+	}
+	var usage processUsage
+	queue := []int{root}
+	seen := map[int]bool{root: true}
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		usage.RSSBytes += rss[pid]
+		for _, child := range children[pid] {
+			if seen[child] {
+				continue
+			}
+			seen[child] = true
+			usage.Children++
+			queue = append(queue, child)
+		}
+	}
+	return usage, nil
+}
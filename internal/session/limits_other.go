@@ -0,0 +1,30 @@
+//go:build !linux
+
+package session
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// Only the lifetime limit is enforced outside Linux.
+const processSamplingSupported = false
+
+func prepareLimitedCommand(*exec.Cmd) {}
+
+func applySpawnLimits(int, ResourceLimits) error {
+	return nil
+}
+
+func killProcessTree(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
+
+func sampleProcessTree(int) (processUsage, error) {
+	return processUsage{}, errors.New("process sampling is not supported on this platform")
+}
@@ -9,20 +9,38 @@ const (
 
 	StatusStarting = "starting"
 	StatusReady    = "ready"
+	StatusDegraded = "degraded"
 	StatusClosed   = "closed"
 	StatusFailed   = "failed"
+	// StatusDetached marks a persisted session without an app-server
+	// process, e.g. after a bridge restart, until it is resumed.
+	StatusDetached = "detached"
+	// StatusSuspended marks an idle session whose app-server was stopped;
+	// the next turn relaunches it.
+	StatusSuspended = "suspended"
 )
 
 type Session struct {
-	ID            string    `json:"session_id"`
-	Backend       string    `json:"backend"`
-	WorkspaceID   string    `json:"workspace_id,omitempty"`
-	WorkspacePath string    `json:"workspace_path"`
-	ThreadID      string    `json:"thread_id,omitempty"`
-	Status        string    `json:"status"`
-	Error         string    `json:"error,omitempty"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID                string   `json:"session_id"`
+	Backend           string   `json:"backend"`
+	WorkspaceID       string   `json:"workspace_id,omitempty"`
+	WorkspacePath     string   `json:"workspace_path"`
+	ThreadID          string   `json:"thread_id,omitempty"`
+	Status            string   `json:"status"`
+	Error             string   `json:"error,omitempty"`
+	SupportedMethods  []string `json:"supported_methods,omitempty"`
+	HeartbeatFailures int      `json:"heartbeat_failures,omitempty"`
+	Restarts          int      `json:"restarts,omitempty"`
+	QueuedTurns       int      `json:"queued_turns,omitempty"`
+	// EarliestSeq is the oldest event seq still replayable in full; older
+	// events are summarized by a session/compacted marker. Zero until the
+	// first event.
+	EarliestSeq int64 `json:"earliest_seq"`
+	// CreatedBy is the device address or token that created the session;
+	// only it registers tools for the session without operator access.
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type Event struct {
@@ -35,13 +53,18 @@ type Event struct {
 }
 
 type PendingRequest struct {
-	RequestID  string         `json:"request_id"`
-	Method     string         `json:"method"`
-	Kind       string         `json:"kind"`
-	Params     map[string]any `json:"params,omitempty"`
-	CreatedAt  time.Time      `json:"created_at"`
-	ResolvedAt time.Time      `json:"resolved_at,omitempty"`
-	Resolved   bool           `json:"resolved"`
+	RequestID string         `json:"request_id"`
+	Method    string         `json:"method"`
+	Kind      string         `json:"kind"`
+	Params    map[string]any `json:"params,omitempty"`
+	// EditedParams holds the values the resolver changed before accepting;
+	// Params keeps the request as the backend originally sent it.
+	EditedParams map[string]any `json:"edited_params,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	ResolvedAt   time.Time      `json:"resolved_at,omitempty"`
+	Resolved     bool           `json:"resolved"`
+	// ResolvedBy is the device address that resolved the request.
+	ResolvedBy string `json:"resolved_by,omitempty"`
 }
 
 type Approval struct {
@@ -78,6 +101,9 @@ type StartTurnRequest struct {
 	OutputSchema   map[string]any   `json:"output_schema,omitempty"`
 	ExpectedTurnID string           `json:"expected_turn_id,omitempty"`
 	Steer          bool             `json:"steer,omitempty"`
+	// ClientRequestID (or the Idempotency-Key header) makes a retried
+	// request return the original turn instead of starting another.
+	ClientRequestID string `json:"client_request_id,omitempty"`
 }
 
 type StartTurnResult struct {
@@ -85,6 +111,13 @@ type StartTurnResult struct {
 	ThreadID  string `json:"thread_id,omitempty"`
 	TurnID    string `json:"turn_id,omitempty"`
 	Status    string `json:"status,omitempty"`
+	// QueueID and QueuePosition are set instead of TurnID when the turn
+	// waits behind a running one (Status "queued").
+	QueueID       string `json:"queue_id,omitempty"`
+	QueuePosition int    `json:"queue_position,omitempty"`
+	// Replayed is set when the result is that of an earlier request with
+	// the same ClientRequestID.
+	Replayed bool `json:"replayed,omitempty"`
 }
 
 type BackendStatus struct {
@@ -113,6 +146,9 @@ type BackendCallResult struct {
 type ResolveRequestInput struct {
 	Result map[string]any `json:"result,omitempty"`
 	Error  *ResolveError  `json:"error,omitempty"`
+	// Edits replaces request params before accepting; the edited values are
+	// merged into the result sent to the backend.
+	Edits map[string]any `json:"edits,omitempty"`
 }
 
 type ResolveError struct {
@@ -122,6 +158,7 @@ type ResolveError struct {
 }
 
 type ApprovalDecision struct {
-	Decision   string `json:"decision"`
-	ForSession bool   `json:"for_session,omitempty"`
+	Decision   string         `json:"decision"`
+	ForSession bool           `json:"for_session,omitempty"`
+	Edits      map[string]any `json:"edits,omitempty"`
 }
@@ -0,0 +1,223 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultLockTTL is how long an exclusive-control lock is held when the
+// caller does not ask for a duration.
+const DefaultLockTTL = 10 * time.Minute
+
+// MaxLockTTL bounds a lock's duration, so an abandoned lock lapses.
+const MaxLockTTL = time.Hour
+
+// Viewer is one device watching a session's events; Streams counts its
+// open subscriptions.
+type Viewer struct {
+	Address  string    `json:"address"`
+	JoinedAt time.Time `json:"joined_at"`
+	Streams  int       `json:"streams"`
+}
+
+// ControlLock gives one device exclusive control of a session's turns,
+// interrupts and request resolution until it is released or expires.
+type ControlLock struct {
+	Holder     string    `json:"holder"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type Presence struct {
+	SessionID string       `json:"session_id"`
+	Viewers   []Viewer     `json:"viewers"`
+	Lock      *ControlLock `json:"lock,omitempty"`
+}
+
+// SessionLockedError rejects a change from a device that does not hold the
+// session's control lock.
+type SessionLockedError struct {
+	Holder    string
+	ExpiresAt time.Time
+}
+
+func (e *SessionLockedError) Error() string {
+	return fmt.Sprintf("session is locked by %s until %s", e.Holder, e.ExpiresAt.Format(time.RFC3339))
+}
+
+type actorContextKey struct{}
+
+// WithActor records the device acting on a session, for presence, lock
+// checks and resolved_by.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// ActorFrom returns the actor recorded by WithActor.
+func ActorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorContextKey{}).(string)
+	return actor
+}
+
+// Join adds actor to the session's viewers for one subscription. The
+// first subscription of a device publishes presence/joined and the
+// returned leave publishes presence/left after its last one.
+func (s *Service) Join(sessionID, actor string) (func(), error) {
+	st, err := s.state(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if actor == "" {
+		return func() {}, nil
+	}
+	st.mu.Lock()
+	if st.viewers == nil {
+		st.viewers = map[string]*Viewer{}
+	}
+	v, ok := st.viewers[actor]
+	if !ok {
+		v = &Viewer{Address: actor, JoinedAt: time.Now().UTC()}
+		st.viewers[actor] = v
+	}
+	v.Streams++
+	viewers := viewerAddressesLocked(st)
+	st.mu.Unlock()
+	if !ok {
+		s.publish(st, "presence", "presence/joined", map[string]any{"address": actor, "viewers": viewers})
+	}
+
+	left := false
+	return func() {
+		st.mu.Lock()
+		if left {
+			st.mu.Unlock()
+			return
+		}
+		left = true
+		v.Streams--
+		gone := v.Streams <= 0
+		if gone {
+			delete(st.viewers, actor)
+		}
+		viewers := viewerAddressesLocked(st)
+		st.mu.Unlock()
+		if gone {
+			s.publish(st, "presence", "presence/left", map[string]any{"address": actor, "viewers": viewers})
+		}
+	}, nil
+}
+
+func viewerAddressesLocked(st *sessionState) []string {
+	out := make([]string, 0, len(st.viewers))
+	for addr := range st.viewers {
+		out = append(out, addr)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (s *Service) Presence(sessionID string) (Presence, error) {
+	st, err := s.state(sessionID)
+	if err != nil {
+		return Presence{}, err
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	out := Presence{SessionID: sessionID, Viewers: make([]Viewer, 0, len(st.viewers))}
+	for _, v := range st.viewers {
+		out.Viewers = append(out.Viewers, *v)
+	}
+	sort.Slice(out.Viewers, func(i, j int) bool {
+		return out.Viewers[i].JoinedAt.Before(out.Viewers[j].JoinedAt)
+	})
+	if lock := liveLockLocked(st); lock != nil {
+		cp := *lock
+		out.Lock = &cp
+	}
+	return out, nil
+}
+
+// AcquireLock takes or renews the session's control lock for the actor in
+// ctx. ttl <= 0 uses DefaultLockTTL; longer than MaxLockTTL is capped.
+func (s *Service) AcquireLock(ctx context.Context, sessionID string, ttl time.Duration) (ControlLock, error) {
+	actor := ActorFrom(ctx)
+	if actor == "" {
+		return ControlLock{}, fmt.Errorf("lock requires an identified device")
+	}
+	st, err := s.state(sessionID)
+	if err != nil {
+		return ControlLock{}, err
+	}
+	if ttl <= 0 {
+		ttl = DefaultLockTTL
+	}
+	if ttl > MaxLockTTL {
+		ttl = MaxLockTTL
+	}
+	now := time.Now().UTC()
+	st.mu.Lock()
+	if lock := liveLockLocked(st); lock != nil && lock.Holder != actor {
+		st.mu.Unlock()
+		return ControlLock{}, &SessionLockedError{Holder: lock.Holder, ExpiresAt: lock.ExpiresAt}
+	}
+	renewed := st.lock != nil
+	if !renewed {
+		st.lock = &ControlLock{Holder: actor, AcquiredAt: now}
+	}
+	st.lock.ExpiresAt = now.Add(ttl)
+	out := *st.lock
+	st.mu.Unlock()
+	if !renewed {
+		s.publish(st, "presence", "lock/acquired", map[string]any{"holder": actor, "expires_at": out.ExpiresAt})
+	}
+	return out, nil
+}
+
+// ReleaseLock drops the control lock. Only the holder may release it
+// unless force is set.
+func (s *Service) ReleaseLock(ctx context.Context, sessionID string, force bool) error {
+	st, err := s.state(sessionID)
+	if err != nil {
+		return err
+	}
+	actor := ActorFrom(ctx)
+	st.mu.Lock()
+	lock := liveLockLocked(st)
+	if lock == nil {
+		st.mu.Unlock()
+		return nil
+	}
+	if lock.Holder != actor && !force {
+		st.mu.Unlock()
+		return &SessionLockedError{Holder: lock.Holder, ExpiresAt: lock.ExpiresAt}
+	}
+	st.lock = nil
+	st.mu.Unlock()
+	s.publish(st, "presence", "lock/released", map[string]any{"holder": lock.Holder, "released_by": actor})
+	return nil
+}
+
+// checkLock rejects actors other than the lock holder. Calls without an
+// actor come from the bridge itself and are not checked.
+func (s *Service) checkLock(ctx context.Context, st *sessionState) error {
+	actor := ActorFrom(ctx)
+	if actor == "" {
+		return nil
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if lock := liveLockLocked(st); lock != nil && lock.Holder != actor {
+		return &SessionLockedError{Holder: lock.Holder, ExpiresAt: lock.ExpiresAt}
+	}
+	return nil
+}
+
+// liveLockLocked returns the current lock, dropping it once expired.
+func liveLockLocked(st *sessionState) *ControlLock {
+	if st.lock != nil && !time.Now().Before(st.lock.ExpiresAt) {
+		st.lock = nil
+	}
+	return st.lock
+}
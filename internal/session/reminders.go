@@ -0,0 +1,133 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"echohelix/internal/notify"
+)
+
+// StaleApproval is an approval request that stayed unanswered past the
+// reminder delay.
+type StaleApproval struct {
+	SessionID     string        `json:"session_id"`
+	Backend       string        `json:"backend"`
+	WorkspacePath string        `json:"workspace_path"`
+	Approval      Approval      `json:"approval"`
+	Waiting       time.Duration `json:"-"`
+}
+
+type ApprovalReminderSink func(ctx context.Context, stale StaleApproval)
+
+type approvalReminder struct {
+	after time.Duration
+	sink  ApprovalReminderSink
+}
+
+// SetApprovalReminder sends each approval request still pending after
+// after to sink, once. Zero after or a nil sink disables reminders.
+func (s *Service) SetApprovalReminder(after time.Duration, sink ApprovalReminderSink) {
+	s.mu.Lock()
+	s.reminder = approvalReminder{after: after, sink: sink}
+	s.mu.Unlock()
+}
+
+func (s *Service) approvalReminder() approvalReminder {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reminder
+}
+
+// StartApprovalReminder checks for stale approvals until ctx is done.
+func (s *Service) StartApprovalReminder(ctx context.Context) {
+	cfg := s.approvalReminder()
+	if cfg.after <= 0 || cfg.sink == nil {
+		return
+	}
+	interval := cfg.after / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			s.RemindStaleApprovals(ctx, time.Now().UTC())
+		}
+	}()
+}
+
+// RemindStaleApprovals passes approvals pending since before now minus the
+// reminder delay to the sink, skipping ones already reminded. It returns
+// how many were sent.
+func (s *Service) RemindStaleApprovals(ctx context.Context, now time.Time) int {
+	cfg := s.approvalReminder()
+	if cfg.after <= 0 || cfg.sink == nil {
+		return 0
+	}
+	cutoff := now.Add(-cfg.after)
+	s.mu.Lock()
+	states := make([]*sessionState, 0, len(s.sessions))
+	for _, st := range s.sessions {
+		states = append(states, st)
+	}
+	s.mu.Unlock()
+
+	var stale []StaleApproval
+	for _, st := range states {
+		st.mu.Lock()
+		for _, item := range st.pending {
+			if item.reminded || item.obj.Resolved || item.obj.Kind != "approval" || item.obj.CreatedAt.After(cutoff) {
+				continue
+			}
+			item.reminded = true
+			stale = append(stale, StaleApproval{
+				SessionID:     st.session.ID,
+				Backend:       st.session.Backend,
+				WorkspacePath: st.session.WorkspacePath,
+				Approval:      approvalFrom(item.obj),
+				Waiting:       now.Sub(item.obj.CreatedAt),
+			})
+		}
+		st.mu.Unlock()
+	}
+	for _, item := range stale {
+		cfg.sink(ctx, item)
+	}
+	return len(stale)
+}
+
+// NewEmailApprovalReminderSink mails each stale approval through mailer.
+func NewEmailApprovalReminderSink(mailer *notify.Mailer) ApprovalReminderSink {
+	return func(ctx context.Context, stale StaleApproval) {
+		ap := stale.Approval
+		var b strings.Builder
+		fmt.Fprintf(&b, "An approval request in session %s (%s) has waited %s.\n\n", stale.SessionID, stale.Backend, stale.Waiting.Round(time.Minute))
+		fmt.Fprintf(&b, "Workspace: %s\n", stale.WorkspacePath)
+		fmt.Fprintf(&b, "Request: %s (%s)\n", ap.RequestID, ap.Method)
+		if ap.Command != "" {
+			fmt.Fprintf(&b, "Command: %s\n", ap.Command)
+		}
+		if ap.Cwd != "" {
+			fmt.Fprintf(&b, "Cwd: %s\n", ap.Cwd)
+		}
+		if ap.Reason != "" {
+			fmt.Fprintf(&b, "Reason: %s\n", ap.Reason)
+		}
+		fmt.Fprintf(&b, "\nResolve: POST /api/v3/sessions/%s/approvals/%s\n", stale.SessionID, ap.RequestID)
+		if err := mailer.Send(ctx, "Approval pending: session "+stale.SessionID, b.String()); err != nil {
+			log.Printf("approval reminder notify: %v", err)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"echohelix/internal/ledger"
+)
+
+// errNoAppServer is returned for calls on a detached session, which has no
+// app-server process until it is resumed.
+var errNoAppServer = errors.New("session has no running app-server; resume it first")
+
+// SetLedger persists session metadata to store so sessions can be listed
+// and resumed after the bridge restarts.
+func (s *Service) SetLedger(store *ledger.Store) {
+	s.mu.Lock()
+	s.ledger = store
+	s.mu.Unlock()
+}
+
+func (s *Service) sessionLedger() *ledger.Store {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ledger
+}
+
+func (s *Service) saveSession(st *sessionState) {
+	store := s.sessionLedger()
+	if store == nil {
+		return
+	}
+	st.mu.Lock()
+	sess := st.session
+	st.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := store.UpsertAgentSession(ctx, ledger.AgentSessionRecord{
+		ID:            sess.ID,
+		Backend:       sess.Backend,
+		WorkspaceID:   sess.WorkspaceID,
+		WorkspacePath: sess.WorkspacePath,
+		ThreadID:      sess.ThreadID,
+		Status:        sess.Status,
+		Error:         sess.Error,
+		CreatedBy:     sess.CreatedBy,
+		CreatedAt:     sess.CreatedAt,
+		UpdatedAt:     sess.UpdatedAt,
+	})
+	if err != nil {
+		log.Printf("warn: persist session id=%s: %v", sess.ID, err)
+	}
+}
+
+// RestoreSessions loads sessions persisted within the retention window that
+// are not in memory. Sessions whose app-server was still running when the
+// bridge stopped come back as detached until they are resumed; suspended
+// sessions stay suspended and relaunch on their next turn.
+func (s *Service) RestoreSessions(ctx context.Context) (int, error) {
+	store := s.sessionLedger()
+	if store == nil {
+		return 0, nil
+	}
+	recs, err := store.ListAgentSessionsUpdatedSince(ctx, time.Now().UTC().Add(-s.cfg.SessionRetention))
+	if err != nil {
+		return 0, err
+	}
+	restored := 0
+	for _, rec := range recs {
+		s.mu.Lock()
+		_, exists := s.sessions[rec.ID]
+		s.mu.Unlock()
+		if exists {
+			continue
+		}
+		st := restoredSessionState(rec)
+		s.mu.Lock()
+		s.sessions[rec.ID] = st
+		s.mu.Unlock()
+		if st.session.Status != rec.Status {
+			s.saveSession(st)
+		}
+		restored++
+	}
+	return restored, nil
+}
+
+func restoredSessionState(rec ledger.AgentSessionRecord) *sessionState {
+	status := rec.Status
+	if status != StatusSuspended && !isTerminalSessionStatus(status) {
+		status = StatusDetached
+	}
+	return &sessionState{
+		session: Session{
+			ID:            rec.ID,
+			Backend:       rec.Backend,
+			WorkspaceID:   rec.WorkspaceID,
+			WorkspacePath: rec.WorkspacePath,
+			ThreadID:      rec.ThreadID,
+			Status:        status,
+			Error:         rec.Error,
+			CreatedBy:     rec.CreatedBy,
+			CreatedAt:     rec.CreatedAt,
+			UpdatedAt:     rec.UpdatedAt,
+		},
+		history:       make([]Event, 0, 256),
+		pending:       map[string]*pendingRequestState{},
+		closedLocally: true,
+	}
+}
+
+// Resume relaunches the app-server of a detached, suspended, closed or
+// failed session and resumes its thread. Sessions no longer in memory are loaded from the
+// ledger.
+func (s *Service) Resume(ctx context.Context, sessionID string) (Session, error) {
+	st, err := s.state(sessionID)
+	if err != nil {
+		if st, err = s.loadSession(ctx, sessionID); err != nil {
+			return Session{}, err
+		}
+	}
+
+	st.mu.Lock()
+	prevStatus := st.session.Status
+	if prevStatus == StatusSuspended {
+		st.mu.Unlock()
+		if err := s.wake(ctx, st); err != nil {
+			return Session{}, err
+		}
+		return s.Get(sessionID)
+	}
+	if !isTerminalSessionStatus(prevStatus) {
+		st.mu.Unlock()
+		return Session{}, fmt.Errorf("session is %s", prevStatus)
+	}
+	if st.session.ThreadID == "" {
+		st.mu.Unlock()
+		return Session{}, fmt.Errorf("session has no thread to resume")
+	}
+	workspacePath := st.session.WorkspacePath
+	st.session.Status = StatusStarting
+	st.mu.Unlock()
+
+	if err := s.policy.ValidateWorkspace(workspacePath); err != nil {
+		s.revertResume(st, prevStatus)
+		return Session{}, err
+	}
+	threadID, err := s.relaunchSession(ctx, st, true)
+	if err != nil {
+		s.revertResume(st, prevStatus)
+		return Session{}, err
+	}
+	s.saveSession(st)
+	s.publish(st, "status", "session/resumed", map[string]any{"thread_id": threadID})
+	return s.Get(sessionID)
+}
+
+func (s *Service) revertResume(st *sessionState, status string) {
+	st.mu.Lock()
+	if st.session.Status == StatusStarting {
+		st.session.Status = status
+	}
+	st.mu.Unlock()
+}
+
+func (s *Service) loadSession(ctx context.Context, sessionID string) (*sessionState, error) {
+	store := s.sessionLedger()
+	if store == nil {
+		return nil, ErrSessionNotFound
+	}
+	rec, err := store.GetAgentSession(ctx, sessionID)
+	if errors.Is(err, ledger.ErrAgentSessionNotFound) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	st := restoredSessionState(rec)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.sessions[sessionID]; ok {
+		return existing, nil
+	}
+	s.sessions[sessionID] = st
+	return st, nil
+}
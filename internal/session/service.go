@@ -3,24 +3,35 @@ package session
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"echohelix/internal/auth"
+	"echohelix/internal/envprofile"
+	"echohelix/internal/events"
+	"echohelix/internal/ledger"
 	"echohelix/internal/policy"
+	"echohelix/internal/signing"
 
 	"github.com/google/uuid"
 )
 
 type Config struct {
-	CodexBin             string
-	CodexArgs            []string
-	GeminiBin            string
-	GeminiArgs           []string
-	ClaudeBin            string
-	ClaudeArgs           []string
+	CodexBin   string
+	CodexArgs  []string
+	GeminiBin  string
+	GeminiArgs []string
+	ClaudeBin  string
+	ClaudeArgs []string
+	// GeminiProtocol and ClaudeProtocol select the session protocol the
+	// binaries speak: "acp" and "stream-json" by default, or "app-server"
+	// for wrappers that speak the codex protocol.
+	GeminiProtocol       string
+	ClaudeProtocol       string
 	StartTimeout         time.Duration
 	RequestTimeout       time.Duration
 	SessionRetention     time.Duration
@@ -29,20 +40,43 @@ type Config struct {
 }
 
 type backendLaunch struct {
-	bin  string
-	args []string
+	bin      string
+	args     []string
+	protocol string
 }
 
+type EnvProfileResolver func(ctx context.Context, workspaceID string) envprofile.Profile
+
 type Service struct {
 	cfg            Config
 	policy         *policy.Policy
 	hub            *Hub
+	blockedMu      sync.RWMutex
 	blockedMethods map[string]struct{}
 	launchers      map[string]backendLaunch
-	lastCleanup    time.Time
+	envProfiles    EnvProfileResolver
+	heartbeat      HeartbeatPolicy
+	ledger         *ledger.Store
+	idleAfter      time.Duration
+	limits         ResourceLimits
+	turnQueueMax   int
+	tools          map[toolKey]RegisteredTool
+	toolSigner     *signing.Signer
+	reminder       approvalReminder
+	guard          *CommandGuard
+	alertNotifier  auth.SecurityAlertNotifier
+	// idempotencyWindow is how long a turn's client_request_id is
+	// remembered; zero means ledger.DefaultIdempotencyWindow.
+	idempotencyWindow time.Duration
 
 	mu       sync.Mutex
 	sessions map[string]*sessionState
+
+	// The janitor goroutine expires sessions every SessionCleanupPeriod
+	// until Shutdown closes stopJanitor.
+	stopJanitor chan struct{}
+	janitorDone chan struct{}
+	stopOnce    sync.Once
 }
 
 type sessionState struct {
@@ -55,11 +89,27 @@ type sessionState struct {
 	pending       map[string]*pendingRequestState
 	activeTurnID  string
 	closedLocally bool
+	methods       map[string]string
+	unsupported   map[string]struct{}
+	// turnBusy is held from turn/start until turn/completed; turns started
+	// meanwhile wait in turnQueue.
+	turnBusy  bool
+	turnQueue []queuedTurn
+	// lastTurnAt is when a turn last started or completed, for idle
+	// suspension; wakeMu serializes relaunching a suspended session.
+	lastTurnAt time.Time
+	wakeMu     sync.Mutex
+	// viewers are the devices subscribed to the session's events; lock is
+	// the optional exclusive-control lock.
+	viewers map[string]*Viewer
+	lock    *ControlLock
 }
 
 type pendingRequestState struct {
 	obj    PendingRequest
 	wireID any
+	// reminded is set once the approval reminder sink was told about it.
+	reminded bool
 }
 
 func NewService(cfg Config, p *policy.Policy) *Service {
@@ -87,43 +137,85 @@ func NewService(cfg Config, p *policy.Policy) *Service {
 	if cfg.SessionCleanupPeriod <= 0 {
 		cfg.SessionCleanupPeriod = 5 * time.Minute
 	}
-	blocked := make(map[string]struct{}, len(cfg.BlockedMethods))
-	for _, m := range cfg.BlockedMethods {
-		if key := normalizeMethod(m); key != "" {
-			blocked[key] = struct{}{}
-		}
-	}
-	if len(blocked) == 0 {
-		blocked[normalizeMethod("initialize")] = struct{}{}
-		blocked[normalizeMethod("initialized")] = struct{}{}
-	}
+	blocked := blockedMethodSet(cfg.BlockedMethods)
 	launchers := map[string]backendLaunch{
 		BackendCodex: {
-			bin:  cfg.CodexBin,
-			args: buildCodexArgs(cfg.CodexArgs),
+			bin:      cfg.CodexBin,
+			args:     buildCodexArgs(cfg.CodexArgs),
+			protocol: ProtocolAppServer,
 		},
 		BackendGemini: {
-			bin:  cfg.GeminiBin,
-			args: append([]string(nil), cfg.GeminiArgs...),
+			bin:      cfg.GeminiBin,
+			args:     append([]string(nil), cfg.GeminiArgs...),
+			protocol: normalizeProtocol(cfg.GeminiProtocol, BackendGemini),
 		},
 		BackendClaude: {
-			bin:  cfg.ClaudeBin,
-			args: append([]string(nil), cfg.ClaudeArgs...),
+			bin:      cfg.ClaudeBin,
+			args:     append([]string(nil), cfg.ClaudeArgs...),
+			protocol: normalizeProtocol(cfg.ClaudeProtocol, BackendClaude),
 		},
 	}
-	return &Service{
+	s := &Service{
 		cfg:            cfg,
 		policy:         p,
 		hub:            NewHub(),
 		blockedMethods: blocked,
 		launchers:      launchers,
 		sessions:       map[string]*sessionState{},
-		lastCleanup:    time.Now().UTC(),
+		stopJanitor:    make(chan struct{}),
+		janitorDone:    make(chan struct{}),
 	}
+	go s.runJanitor()
+	return s
+}
+
+func blockedMethodSet(methods []string) map[string]struct{} {
+	blocked := make(map[string]struct{}, len(methods))
+	for _, m := range methods {
+		if key := normalizeMethod(m); key != "" {
+			blocked[key] = struct{}{}
+		}
+	}
+	if len(blocked) == 0 {
+		blocked[normalizeMethod("initialize")] = struct{}{}
+		blocked[normalizeMethod("initialized")] = struct{}{}
+	}
+	return blocked
+}
+
+// SetBlockedMethods replaces the methods backend/call rejects, for a config
+// reload. An empty list restores the initialize/initialized default.
+func (s *Service) SetBlockedMethods(methods []string) {
+	blocked := blockedMethodSet(methods)
+	s.blockedMu.Lock()
+	s.blockedMethods = blocked
+	s.blockedMu.Unlock()
+}
+
+func (s *Service) methodBlocked(key string) bool {
+	s.blockedMu.RLock()
+	defer s.blockedMu.RUnlock()
+	_, blocked := s.blockedMethods[key]
+	return blocked
+}
+
+func (s *Service) SetEnvProfileResolver(resolve EnvProfileResolver) {
+	s.mu.Lock()
+	s.envProfiles = resolve
+	s.mu.Unlock()
+}
+
+func (s *Service) resolveEnvProfile(ctx context.Context, workspaceID string) envprofile.Profile {
+	s.mu.Lock()
+	resolve := s.envProfiles
+	s.mu.Unlock()
+	if resolve == nil || strings.TrimSpace(workspaceID) == "" {
+		return envprofile.Profile{}
+	}
+	return resolve(ctx, workspaceID)
 }
 
 func (s *Service) Create(ctx context.Context, req CreateRequest) (Session, error) {
-	s.maybeCleanup(time.Now().UTC())
 	backend := normalizeBackend(req.Backend)
 	if backend == "" {
 		backend = BackendCodex
@@ -138,6 +230,17 @@ func (s *Service) Create(ctx context.Context, req CreateRequest) (Session, error
 	if err := s.policy.ValidateRunOptions(policy.RunOptions{Model: req.Model, Sandbox: req.Sandbox}); err != nil {
 		return Session{}, err
 	}
+	enforced, err := s.policy.EnforceWorkspaceRule(ctx, policy.WorkspaceRequest{
+		WorkspaceID:   req.WorkspaceID,
+		WorkspacePath: req.WorkspacePath,
+		Backend:       backend,
+		Sandbox:       req.Sandbox,
+		Approval:      req.Approval,
+	})
+	if err != nil {
+		return Session{}, err
+	}
+	req.Sandbox, req.Approval = enforced.Sandbox, enforced.Approval
 
 	sessionID := uuid.NewString()
 	now := time.Now().UTC()
@@ -148,6 +251,7 @@ func (s *Service) Create(ctx context.Context, req CreateRequest) (Session, error
 			WorkspaceID:   req.WorkspaceID,
 			WorkspacePath: req.WorkspacePath,
 			Status:        StatusStarting,
+			CreatedBy:     ActorFrom(ctx),
 			CreatedAt:     now,
 			UpdatedAt:     now,
 		},
@@ -159,47 +263,16 @@ func (s *Service) Create(ctx context.Context, req CreateRequest) (Session, error
 	s.sessions[sessionID] = state
 	s.mu.Unlock()
 
-	client, err := newAppServerClient(launcher.bin, launcher.args, req.WorkspacePath)
-	if err != nil {
-		s.deleteSession(sessionID)
-		return Session{}, err
-	}
-	state.client = client
-
-	client.onNotification = func(method string, params map[string]any) {
-		s.handleNotification(state, method, params)
-	}
-	client.onRequest = func(reqIDKey string, wireID any, method string, params map[string]any) {
-		s.handleServerRequest(state, reqIDKey, wireID, method, params)
-	}
-	client.onStderr = func(line string) {
-		s.publish(state, "stderr", "stderr", map[string]any{"line": line})
-	}
-	client.onClose = func(exitErr error) {
-		s.handleClientClosed(state, exitErr)
-	}
-
 	startCtx, cancel := requestTimeout(ctx, s.cfg.StartTimeout)
 	defer cancel()
-	if _, err := client.Call(startCtx, "initialize", map[string]any{
-		"clientInfo": map[string]any{
-			"name":    "echohelix_bridge",
-			"title":   "EchoHelix Bridge",
-			"version": "0.1.0",
-		},
-		"capabilities": map[string]any{
-			"experimentalApi": true,
-		},
-	}); err != nil {
-		_ = client.Close()
-		s.deleteSession(sessionID)
-		return Session{}, err
-	}
-	if err := client.Notify("initialized", nil); err != nil {
-		_ = client.Close()
+	client, methods, err := s.launchClient(startCtx, state, launcher, req.WorkspacePath, req.WorkspaceID, strings.TrimSpace(req.ThreadID))
+	if err != nil {
 		s.deleteSession(sessionID)
 		return Session{}, err
 	}
+	state.mu.Lock()
+	state.client = client
+	state.mu.Unlock()
 
 	threadMethod := "thread/start"
 	threadParams := map[string]any{"cwd": req.WorkspacePath}
@@ -235,17 +308,65 @@ func (s *Service) Create(ctx context.Context, req CreateRequest) (Session, error
 
 	state.mu.Lock()
 	state.session.ThreadID = threadID
+	state.session.SupportedMethods = s.supportedMethodList(methods)
+	state.methods = methods
 	state.session.Status = StatusReady
 	state.session.UpdatedAt = time.Now().UTC()
+	state.lastTurnAt = state.session.UpdatedAt
 	out := state.session
 	state.mu.Unlock()
 
+	s.saveSession(state)
 	s.publish(state, "status", "session/ready", map[string]any{"thread_id": threadID})
 	return out, nil
 }
 
+// launchClient starts the backend process for st and completes the
+// initialize handshake. The client is closed again on failure.
+func (s *Service) launchClient(ctx context.Context, st *sessionState, launcher backendLaunch, workspacePath, workspaceID, threadID string) (*appServerClient, map[string]string, error) {
+	args, d, err := launcher.command(threadID, workspacePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := newAppServerClient(launcher.bin, args, workspacePath, s.resolveEnvProfile(ctx, workspaceID), s.resourceLimits(), d)
+	if err != nil {
+		return nil, nil, err
+	}
+	client.onNotification = func(method string, params map[string]any) {
+		s.handleNotification(st, method, params)
+	}
+	client.onRequest = func(reqIDKey string, wireID any, method string, params map[string]any) {
+		s.handleServerRequest(st, reqIDKey, wireID, method, params)
+	}
+	client.onStderr = func(line string) {
+		s.publish(st, "stderr", "stderr", map[string]any{"line": line})
+	}
+	client.onClose = func(exitErr error) {
+		s.handleClientClosed(st, client, exitErr)
+	}
+
+	initResult, err := client.Call(ctx, "initialize", map[string]any{
+		"clientInfo": map[string]any{
+			"name":    "echohelix_bridge",
+			"title":   "EchoHelix Bridge",
+			"version": "0.1.0",
+		},
+		"capabilities": map[string]any{
+			"experimentalApi": true,
+		},
+	})
+	if err != nil {
+		_ = client.Close()
+		return nil, nil, err
+	}
+	if err := client.Notify("initialized", nil); err != nil {
+		_ = client.Close()
+		return nil, nil, err
+	}
+	return client, parseAdvertisedMethods(initResult), nil
+}
+
 func (s *Service) List() []Session {
-	s.maybeCleanup(time.Now().UTC())
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	out := make([]Session, 0, len(s.sessions))
@@ -281,15 +402,20 @@ func (s *Service) Close(sessionID string) error {
 	st.session.UpdatedAt = time.Now().UTC()
 	st.mu.Unlock()
 
-	if st.client != nil {
-		_ = st.client.Close()
+	if client := st.rpc(); client != nil {
+		_ = client.Close()
 	}
+	s.saveSession(st)
 	s.publish(st, "status", "session/closed", nil)
 	return nil
 }
 
 func (s *Service) Shutdown(ctx context.Context) error {
-	_ = ctx
+	s.stopOnce.Do(func() { close(s.stopJanitor) })
+	select {
+	case <-s.janitorDone:
+	case <-ctx.Done():
+	}
 	s.mu.Lock()
 	ids := make([]string, 0, len(s.sessions))
 	for id := range s.sessions {
@@ -297,12 +423,38 @@ func (s *Service) Shutdown(ctx context.Context) error {
 	}
 	s.mu.Unlock()
 	for _, id := range ids {
-		_ = s.Close(id)
+		_ = s.detach(id)
+	}
+	return nil
+}
+
+// detach stops a session's app-server for a bridge shutdown. Running
+// sessions are persisted as detached so they can be resumed later.
+func (s *Service) detach(sessionID string) error {
+	st, err := s.state(sessionID)
+	if err != nil {
+		return err
+	}
+	st.mu.Lock()
+	running := !isTerminalSessionStatus(st.session.Status) && st.session.Status != StatusSuspended
+	st.closedLocally = true
+	if running {
+		st.session.Status = StatusDetached
+		st.session.UpdatedAt = time.Now().UTC()
+	}
+	st.mu.Unlock()
+
+	if client := st.rpc(); client != nil {
+		_ = client.Close()
+	}
+	if running {
+		s.saveSession(st)
+		s.publish(st, "status", "session/detached", nil)
 	}
 	return nil
 }
 
-func (s *Service) StartTurn(ctx context.Context, sessionID string, req StartTurnRequest) (StartTurnResult, error) {
+func (s *Service) startTurn(ctx context.Context, sessionID string, req StartTurnRequest) (StartTurnResult, error) {
 	st, err := s.state(sessionID)
 	if err != nil {
 		return StartTurnResult{}, err
@@ -313,14 +465,74 @@ func (s *Service) StartTurn(ctx context.Context, sessionID string, req StartTurn
 	if err := s.policy.ValidateRunOptions(policy.RunOptions{Model: req.Model, Sandbox: req.Sandbox}); err != nil {
 		return StartTurnResult{}, err
 	}
+	st.mu.Lock()
+	scope := policy.WorkspaceRequest{
+		WorkspaceID:   st.session.WorkspaceID,
+		WorkspacePath: st.session.WorkspacePath,
+		Backend:       st.session.Backend,
+		Sandbox:       req.Sandbox,
+		Approval:      req.Approval,
+	}
+	st.mu.Unlock()
+	enforced, err := s.policy.EnforceWorkspaceRule(ctx, scope)
+	if err != nil {
+		return StartTurnResult{}, err
+	}
+	// A turn without a sandbox keeps the one its thread was started with.
+	if req.Sandbox != "" {
+		req.Sandbox = enforced.Sandbox
+	}
+	req.Approval = enforced.Approval
+	if err := s.checkLock(ctx, st); err != nil {
+		return StartTurnResult{}, err
+	}
+
+	if err := s.wake(ctx, st); err != nil {
+		return StartTurnResult{}, fmt.Errorf("relaunch suspended session: %w", err)
+	}
 
+	queueDepth := s.turnQueueDepth()
 	st.mu.Lock()
-	if st.session.Status == StatusClosed {
+	if st.session.Status == StatusClosed || st.session.Status == StatusDetached {
+		status := st.session.Status
+		st.mu.Unlock()
+		if status == StatusDetached {
+			return StartTurnResult{}, ErrSessionDetached
+		}
+		return StartTurnResult{}, ErrSessionClosed
+	}
+	if !req.Steer && st.turnBusy {
+		queued, err := s.enqueueTurnLocked(st, req, queueDepth)
 		st.mu.Unlock()
-		return StartTurnResult{}, fmt.Errorf("session is closed")
+		if err != nil {
+			return StartTurnResult{}, err
+		}
+		s.publish(st, "status", "turn/queued", map[string]any{"queue_id": queued.QueueID, "position": queued.QueuePosition})
+		return queued, nil
+	}
+	if !req.Steer {
+		st.turnBusy = true
 	}
+	// Count the turn as activity before calling out so the idle suspender
+	// does not stop the process underneath it.
+	st.lastTurnAt = time.Now().UTC()
+	st.mu.Unlock()
+
+	result, err := s.sendTurn(ctx, st, req)
+	if err != nil && !req.Steer {
+		s.releaseTurn(st)
+	}
+	return result, err
+}
+
+// sendTurn starts or steers a turn on the session's app-server and echoes
+// the accepted input as an event.
+func (s *Service) sendTurn(ctx context.Context, st *sessionState, req StartTurnRequest) (StartTurnResult, error) {
+	st.mu.Lock()
+	sessionID := st.session.ID
 	threadID := st.session.ThreadID
 	activeTurnID := st.activeTurnID
+	workspaceID, workspacePath := st.session.WorkspaceID, st.session.WorkspacePath
 	st.mu.Unlock()
 
 	method := "turn/start"
@@ -342,6 +554,7 @@ func (s *Service) StartTurn(ctx context.Context, sessionID string, req StartTurn
 	if len(input) == 0 {
 		input = []map[string]any{{"type": "text", "text": req.Prompt}}
 	}
+	input = injectPromptPolicy(input, s.policy.PromptInjectionFor(workspaceID, workspacePath))
 	params["input"] = input
 	if req.Model != "" {
 		params["model"] = req.Model
@@ -358,7 +571,7 @@ func (s *Service) StartTurn(ctx context.Context, sessionID string, req StartTurn
 
 	callCtx, cancel := requestTimeout(ctx, s.cfg.RequestTimeout)
 	defer cancel()
-	resultRaw, err := st.client.Call(callCtx, method, params)
+	resultRaw, err := st.rpc().Call(callCtx, method, params)
 	if err != nil {
 		return StartTurnResult{}, err
 	}
@@ -375,8 +588,15 @@ func (s *Service) StartTurn(ctx context.Context, sessionID string, req StartTurn
 		st.activeTurnID = turnID
 	}
 	st.session.UpdatedAt = time.Now().UTC()
+	st.lastTurnAt = st.session.UpdatedAt
 	st.mu.Unlock()
 
+	inputTurnID := turnID
+	if req.Steer {
+		inputTurnID, _ = params["expectedTurnId"].(string)
+	}
+	s.publish(st, "input", method, map[string]any{"turn_id": inputTurnID, "input": input})
+
 	return StartTurnResult{
 		SessionID: sessionID,
 		ThreadID:  threadResultID,
@@ -385,11 +605,31 @@ func (s *Service) StartTurn(ctx context.Context, sessionID string, req StartTurn
 	}, nil
 }
 
+// injectPromptPolicy adds the workspace's marked prefix and suffix as
+// separate text items around the caller's input.
+func injectPromptPolicy(input []map[string]any, in policy.InjectedPrompt) []map[string]any {
+	if in.Empty() {
+		return input
+	}
+	out := make([]map[string]any, 0, len(input)+2)
+	if in.Prefix != "" {
+		out = append(out, map[string]any{"type": "text", "text": in.Prefix})
+	}
+	out = append(out, input...)
+	if in.Suffix != "" {
+		out = append(out, map[string]any{"type": "text", "text": in.Suffix})
+	}
+	return out
+}
+
 func (s *Service) InterruptTurn(ctx context.Context, sessionID string, turnID string) error {
 	st, err := s.state(sessionID)
 	if err != nil {
 		return err
 	}
+	if err := s.checkLock(ctx, st); err != nil {
+		return err
+	}
 	st.mu.Lock()
 	threadID := st.session.ThreadID
 	if strings.TrimSpace(turnID) == "" {
@@ -401,7 +641,7 @@ func (s *Service) InterruptTurn(ctx context.Context, sessionID string, turnID st
 	}
 	callCtx, cancel := requestTimeout(ctx, s.cfg.RequestTimeout)
 	defer cancel()
-	_, err = st.client.Call(callCtx, "turn/interrupt", map[string]any{
+	_, err = st.rpc().Call(callCtx, "turn/interrupt", map[string]any{
 		"threadId": threadID,
 		"turnId":   turnID,
 	})
@@ -422,13 +662,23 @@ func (s *Service) BackendStatus(ctx context.Context, sessionID string) (BackendS
 	}, nil
 }
 
+// ruledMethods carry a sandbox or approval policy, so BackendCall refuses
+// them in a workspace with a rule the bridge has to enforce.
+var ruledMethods = map[string]struct{}{
+	"thread/start":  {},
+	"thread/resume": {},
+	"thread/fork":   {},
+	"turn/start":    {},
+	"turn/steer":    {},
+}
+
 func (s *Service) BackendCall(ctx context.Context, sessionID string, in BackendCallRequest) (BackendCallResult, error) {
 	method := strings.TrimSpace(in.Method)
 	methodKey := normalizeMethod(method)
 	if methodKey == "" {
 		return BackendCallResult{}, fmt.Errorf("method is required")
 	}
-	if _, blocked := s.blockedMethods[methodKey]; blocked {
+	if s.methodBlocked(methodKey) {
 		return BackendCallResult{}, fmt.Errorf("method %q is managed by bridge", method)
 	}
 	st, err := s.state(sessionID)
@@ -439,11 +689,27 @@ func (s *Service) BackendCall(ctx context.Context, sessionID string, in BackendC
 	st.mu.Lock()
 	if st.session.Status == StatusClosed {
 		st.mu.Unlock()
-		return BackendCallResult{}, fmt.Errorf("session is closed")
+		return BackendCallResult{}, ErrSessionClosed
 	}
 	backend := st.session.Backend
 	threadID := st.session.ThreadID
+	workspaceID, workspacePath := st.session.WorkspaceID, st.session.WorkspacePath
 	st.mu.Unlock()
+	if _, ok := ruledMethods[methodKey]; ok {
+		source, _, err := s.policy.WorkspaceRuleFor(ctx, workspaceID, workspacePath)
+		if err != nil {
+			return BackendCallResult{}, err
+		}
+		if source != "" {
+			return BackendCallResult{}, fmt.Errorf("%w: %s would bypass this workspace's policy; use the session thread and turn endpoints", policy.ErrViolation, method)
+		}
+	}
+	if err := s.checkLock(ctx, st); err != nil {
+		return BackendCallResult{}, err
+	}
+	if err := s.checkMethodSupported(st, methodKey); err != nil {
+		return BackendCallResult{}, err
+	}
 
 	timeout := s.cfg.RequestTimeout
 	if in.TimeoutMS > 0 {
@@ -454,8 +720,11 @@ func (s *Service) BackendCall(ctx context.Context, sessionID string, in BackendC
 	}
 	callCtx, cancel := requestTimeout(ctx, timeout)
 	defer cancel()
-	raw, err := st.client.Call(callCtx, method, in.Params)
+	raw, err := st.rpc().Call(callCtx, method, in.Params)
 	if err != nil {
+		if isMethodNotFound(err) {
+			return BackendCallResult{}, s.markMethodUnsupported(st, methodKey)
+		}
 		return BackendCallResult{}, err
 	}
 
@@ -508,6 +777,20 @@ func (s *Service) Subscribe(sessionID string) (<-chan Event, func(), error) {
 	return ch, unsub, nil
 }
 
+// SubscribeFiltered is Subscribe delivering only the events filter matches.
+func (s *Service) SubscribeFiltered(sessionID string, filter EventFilter) (<-chan Event, func(), error) {
+	if _, err := s.state(sessionID); err != nil {
+		return nil, nil, err
+	}
+	ch, unsub := s.hub.SubscribeFiltered(sessionID, 256, filter)
+	return ch, unsub, nil
+}
+
+// HubStats reports backpressure on live session subscribers.
+func (s *Service) HubStats() events.BackpressureStats {
+	return s.hub.Stats()
+}
+
 func (s *Service) ListPendingRequests(sessionID string) ([]PendingRequest, error) {
 	st, err := s.state(sessionID)
 	if err != nil {
@@ -532,14 +815,35 @@ func (s *Service) ResolvePendingRequest(ctx context.Context, sessionID, requestI
 	if err != nil {
 		return err
 	}
+	if in.Error != nil && len(in.Edits) > 0 {
+		return fmt.Errorf("edits can only be applied when accepting a request")
+	}
+	if err := s.checkLock(ctx, st); err != nil {
+		return err
+	}
+	resolvedBy := ActorFrom(ctx)
+	guard, _ := s.commandGuard()
 	st.mu.Lock()
 	pending, ok := st.pending[requestID]
 	if !ok || pending.obj.Resolved {
 		st.mu.Unlock()
 		return fmt.Errorf("pending request not found")
 	}
+	edited, err := s.applyParamEdits(st.session.WorkspacePath, pending.obj.Params, in.Edits)
+	if err != nil {
+		st.mu.Unlock()
+		return err
+	}
+	if match := guard.editsBlocked(st.session.WorkspacePath, pending.obj, edited); match != nil {
+		sess := st.session
+		st.mu.Unlock()
+		s.raiseGuardAlert(st, sess, requestID, *match)
+		return fmt.Errorf("edited request is blocked by guardrail %s", match.Rule)
+	}
 	pending.obj.Resolved = true
 	pending.obj.ResolvedAt = time.Now().UTC()
+	pending.obj.EditedParams = edited
+	pending.obj.ResolvedBy = resolvedBy
 	st.mu.Unlock()
 
 	if in.Error != nil {
@@ -547,20 +851,39 @@ func (s *Service) ResolvePendingRequest(ctx context.Context, sessionID, requestI
 		if in.Error.Data != nil {
 			data = in.Error.Data
 		}
-		if err := st.client.ReplyError(pending.wireID, in.Error.Code, in.Error.Message, data); err != nil {
+		if err := st.rpc().ReplyError(pending.wireID, in.Error.Code, in.Error.Message, data); err != nil {
 			return err
 		}
-		s.publish(st, "request_resolved", pending.obj.Method, map[string]any{"request_id": requestID, "error": in.Error})
+		payload := map[string]any{"request_id": requestID, "error": in.Error}
+		if resolvedBy != "" {
+			payload["resolved_by"] = resolvedBy
+		}
+		s.publish(st, "request_resolved", pending.obj.Method, payload)
 		return nil
 	}
 	result := map[string]any{}
-	if in.Result != nil {
-		result = in.Result
+	for k, v := range in.Result {
+		result[k] = v
 	}
-	if err := st.client.ReplyResult(pending.wireID, result); err != nil {
+	for k, v := range edited {
+		result[k] = v
+	}
+	if err := st.rpc().ReplyResult(pending.wireID, result); err != nil {
 		return err
 	}
-	s.publish(st, "request_resolved", pending.obj.Method, map[string]any{"request_id": requestID, "result": result})
+	payload := map[string]any{"request_id": requestID, "result": result}
+	if edited != nil {
+		original := make(map[string]any, len(edited))
+		for k := range edited {
+			original[k] = pending.obj.Params[k]
+		}
+		payload["original_params"] = original
+		payload["edited_params"] = edited
+	}
+	if resolvedBy != "" {
+		payload["resolved_by"] = resolvedBy
+	}
+	s.publish(st, "request_resolved", pending.obj.Method, payload)
 	return nil
 }
 
@@ -574,36 +897,40 @@ func (s *Service) ListApprovals(sessionID string) ([]Approval, error) {
 		if item.Kind != "approval" {
 			continue
 		}
-		ap := Approval{
-			RequestID: item.RequestID,
-			Method:    item.Method,
-			Payload:   item.Params,
-			CreatedAt: item.CreatedAt,
-			Resolved:  item.Resolved,
-		}
-		if v, ok := item.Params["threadId"].(string); ok {
-			ap.ThreadID = v
-		}
-		if v, ok := item.Params["turnId"].(string); ok {
-			ap.TurnID = v
-		}
-		if v, ok := item.Params["itemId"].(string); ok {
-			ap.ItemID = v
-		}
-		if v, ok := item.Params["reason"].(string); ok {
-			ap.Reason = v
-		}
-		if v, ok := item.Params["command"].(string); ok {
-			ap.Command = v
-		}
-		if v, ok := item.Params["cwd"].(string); ok {
-			ap.Cwd = v
-		}
-		out = append(out, ap)
+		out = append(out, approvalFrom(item))
 	}
 	return out, nil
 }
 
+func approvalFrom(item PendingRequest) Approval {
+	ap := Approval{
+		RequestID: item.RequestID,
+		Method:    item.Method,
+		Payload:   item.Params,
+		CreatedAt: item.CreatedAt,
+		Resolved:  item.Resolved,
+	}
+	if v, ok := item.Params["threadId"].(string); ok {
+		ap.ThreadID = v
+	}
+	if v, ok := item.Params["turnId"].(string); ok {
+		ap.TurnID = v
+	}
+	if v, ok := item.Params["itemId"].(string); ok {
+		ap.ItemID = v
+	}
+	if v, ok := item.Params["reason"].(string); ok {
+		ap.Reason = v
+	}
+	if v, ok := item.Params["command"].(string); ok {
+		ap.Command = v
+	}
+	if v, ok := item.Params["cwd"].(string); ok {
+		ap.Cwd = v
+	}
+	return ap
+}
+
 func (s *Service) ResolveApproval(ctx context.Context, sessionID, requestID string, decision ApprovalDecision) error {
 	d := strings.ToLower(strings.TrimSpace(decision.Decision))
 	if d == "" {
@@ -612,11 +939,14 @@ func (s *Service) ResolveApproval(ctx context.Context, sessionID, requestID stri
 	if d != "accept" && d != "decline" {
 		return fmt.Errorf("decision must be accept or decline")
 	}
+	if d != "accept" && len(decision.Edits) > 0 {
+		return fmt.Errorf("edits can only be applied when accepting a request")
+	}
 	result := map[string]any{"decision": d}
 	if d == "accept" {
 		result["acceptSettings"] = map[string]any{"forSession": decision.ForSession}
 	}
-	return s.ResolvePendingRequest(ctx, sessionID, requestID, ResolveRequestInput{Result: result})
+	return s.ResolvePendingRequest(ctx, sessionID, requestID, ResolveRequestInput{Result: result, Edits: decision.Edits})
 }
 
 func (s *Service) handleNotification(st *sessionState, method string, params map[string]any) {
@@ -632,7 +962,10 @@ func (s *Service) handleNotification(st *sessionState, method string, params map
 	if method == "turn/completed" {
 		st.mu.Lock()
 		st.activeTurnID = ""
+		st.lastTurnAt = time.Now().UTC()
 		st.mu.Unlock()
+		// Start the next queued turn after the completion is published.
+		defer s.releaseTurn(st)
 	}
 	s.publish(st, "notification", method, params)
 }
@@ -647,6 +980,9 @@ func (s *Service) handleServerRequest(st *sessionState, reqIDKey string, wireID
 		Params:    params,
 		CreatedAt: created,
 	}
+	if kind == "approval" && s.guardRequest(st, reqIDKey, wireID, obj) {
+		return
+	}
 
 	st.mu.Lock()
 	st.pending[reqIDKey] = &pendingRequestState{obj: obj, wireID: wireID}
@@ -658,8 +994,15 @@ func (s *Service) handleServerRequest(st *sessionState, reqIDKey string, wireID
 		"params":     params,
 	})
 
+	if kind == "dynamic_tool" {
+		name, _ := params["tool"].(string)
+		if tool, ok := s.lookupTool(st.sessionID(), name); ok {
+			go s.invokeTool(st, reqIDKey, tool, params)
+		}
+		return
+	}
 	if kind == "unsupported" {
-		_ = st.client.ReplyError(wireID, -32601, "unsupported server request method", nil)
+		_ = st.rpc().ReplyError(wireID, -32601, "unsupported server request method", nil)
 		st.mu.Lock()
 		if item, ok := st.pending[reqIDKey]; ok {
 			item.obj.Resolved = true
@@ -669,10 +1012,19 @@ func (s *Service) handleServerRequest(st *sessionState, reqIDKey string, wireID
 	}
 }
 
-func (s *Service) handleClientClosed(st *sessionState, exitErr error) {
+func (s *Service) handleClientClosed(st *sessionState, client *appServerClient, exitErr error) {
 	st.mu.Lock()
-	if st.closedLocally {
-		st.session.Status = StatusClosed
+	if st.client != client {
+		// A replaced process exiting after a restart, or one that never
+		// finished launching.
+		st.mu.Unlock()
+		return
+	}
+	closedLocally := st.closedLocally
+	if closedLocally {
+		if st.session.Status != StatusDetached && st.session.Status != StatusSuspended {
+			st.session.Status = StatusClosed
+		}
 	} else if exitErr != nil {
 		st.session.Status = StatusFailed
 		st.session.Error = exitErr.Error()
@@ -680,7 +1032,12 @@ func (s *Service) handleClientClosed(st *sessionState, exitErr error) {
 		st.session.Status = StatusClosed
 	}
 	st.session.UpdatedAt = time.Now().UTC()
+	dropped := st.dropTurnsLocked()
 	st.mu.Unlock()
+	if !closedLocally {
+		s.saveSession(st)
+	}
+	s.publishDroppedTurns(st, dropped, "session exited")
 	payload := map[string]any{}
 	if exitErr != nil {
 		payload["error"] = exitErr.Error()
@@ -699,22 +1056,30 @@ func (s *Service) publish(st *sessionState, typ, method string, payload map[stri
 		Method:    method,
 		Payload:   payload,
 	}
-	st.history = append(st.history, ev)
-	if len(st.history) > 4000 {
-		st.history = st.history[len(st.history)-4000:]
-	}
+	st.appendHistoryLocked(ev)
 	st.session.UpdatedAt = ev.TS
 	st.mu.Unlock()
 	s.hub.Publish(ev)
 }
 
+func (st *sessionState) rpc() *appServerClient {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.client
+}
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionClosed   = errors.New("session is closed")
+	ErrSessionDetached = errors.New("session is detached")
+)
+
 func (s *Service) state(sessionID string) (*sessionState, error) {
-	s.maybeCleanup(time.Now().UTC())
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	st, ok := s.sessions[sessionID]
 	if !ok {
-		return nil, fmt.Errorf("session not found")
+		return nil, ErrSessionNotFound
 	}
 	return st, nil
 }
@@ -725,37 +1090,9 @@ func (s *Service) deleteSession(sessionID string) {
 	s.mu.Unlock()
 }
 
-func (s *Service) maybeCleanup(now time.Time) {
-	if s.cfg.SessionRetention <= 0 {
-		return
-	}
-
-	s.mu.Lock()
-	if !s.lastCleanup.IsZero() && now.Sub(s.lastCleanup) < s.cfg.SessionCleanupPeriod {
-		s.mu.Unlock()
-		return
-	}
-	s.lastCleanup = now
-	cutoff := now.Add(-s.cfg.SessionRetention)
-	for id, st := range s.sessions {
-		st.mu.Lock()
-		status := st.session.Status
-		updatedAt := st.session.UpdatedAt
-		st.mu.Unlock()
-		if !isTerminalSessionStatus(status) {
-			continue
-		}
-		if updatedAt.After(cutoff) {
-			continue
-		}
-		delete(s.sessions, id)
-	}
-	s.mu.Unlock()
-}
-
 func isTerminalSessionStatus(status string) bool {
 	switch status {
-	case StatusClosed, StatusFailed:
+	case StatusClosed, StatusFailed, StatusDetached:
 		return true
 	default:
 		return false
@@ -0,0 +1,356 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"echohelix/internal/signing"
+)
+
+const (
+	defaultToolTimeout = 30 * time.Second
+	maxToolTimeout     = 10 * time.Minute
+	maxToolOutputBytes = 1 << 20
+)
+
+var (
+	ErrToolNotFound = errors.New("tool not found")
+	ErrToolNotOwned = errors.New("tool is registered by another caller")
+	toolNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]{1,128}$`)
+)
+
+// RegisteredTool fulfills item/tool/call requests for Name without a client
+// in the loop, either by POSTing the call to CallbackURL or by running
+// Script with sh in the session workspace. Tools with a SessionID apply to
+// that session only and take precedence over global tools of the same name.
+type RegisteredTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	SessionID   string `json:"session_id,omitempty"`
+	CallbackURL string `json:"callback_url,omitempty"`
+	Script      string `json:"script,omitempty"`
+	TimeoutMS   int    `json:"timeout_ms,omitempty"`
+	// RegisteredBy is the caller that registered the tool; only it may
+	// replace the tool.
+	RegisteredBy string    `json:"registered_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (t RegisteredTool) Via() string {
+	if t.Script != "" {
+		return "script"
+	}
+	return "callback"
+}
+
+func (t RegisteredTool) timeout() time.Duration {
+	if t.TimeoutMS > 0 {
+		return time.Duration(t.TimeoutMS) * time.Millisecond
+	}
+	return defaultToolTimeout
+}
+
+type toolKey struct {
+	sessionID string
+	name      string
+}
+
+// ToolCall is the JSON body sent to a tool callback or a script's stdin.
+type ToolCall struct {
+	Event     string         `json:"event"`
+	SessionID string         `json:"session_id"`
+	ThreadID  string         `json:"thread_id,omitempty"`
+	TurnID    string         `json:"turn_id,omitempty"`
+	CallID    string         `json:"call_id,omitempty"`
+	Tool      string         `json:"tool"`
+	Arguments any            `json:"arguments,omitempty"`
+	Params    map[string]any `json:"params,omitempty"`
+}
+
+// SetToolCallbackSigner signs tool callback requests like other outgoing
+// webhooks.
+func (s *Service) SetToolCallbackSigner(signer *signing.Signer) {
+	s.mu.Lock()
+	s.toolSigner = signer
+	s.mu.Unlock()
+}
+
+func (s *Service) RegisterTool(t RegisteredTool) (RegisteredTool, error) {
+	t.Name = strings.TrimSpace(t.Name)
+	t.SessionID = strings.TrimSpace(t.SessionID)
+	t.CallbackURL = strings.TrimSpace(t.CallbackURL)
+	if !toolNamePattern.MatchString(t.Name) {
+		return RegisteredTool{}, fmt.Errorf("name must be 1-128 letters, digits or _.:-")
+	}
+	if (t.CallbackURL == "") == (strings.TrimSpace(t.Script) == "") {
+		return RegisteredTool{}, fmt.Errorf("exactly one of callback_url or script is required")
+	}
+	if t.CallbackURL != "" {
+		u, err := url.Parse(t.CallbackURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return RegisteredTool{}, fmt.Errorf("callback_url must be an absolute http(s) URL")
+		}
+	}
+	if t.TimeoutMS < 0 || time.Duration(t.TimeoutMS)*time.Millisecond > maxToolTimeout {
+		return RegisteredTool{}, fmt.Errorf("timeout_ms must be between 0 and %d", maxToolTimeout.Milliseconds())
+	}
+	if t.SessionID != "" {
+		if _, err := s.state(t.SessionID); err != nil {
+			return RegisteredTool{}, err
+		}
+	}
+	t.CreatedAt = time.Now().UTC()
+	key := toolKey{sessionID: t.SessionID, name: t.Name}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if prev, ok := s.tools[key]; ok && prev.RegisteredBy != t.RegisteredBy {
+		return RegisteredTool{}, ErrToolNotOwned
+	}
+	if s.tools == nil {
+		s.tools = map[toolKey]RegisteredTool{}
+	}
+	s.tools[key] = t
+	return t, nil
+}
+
+// UnregisterTool removes a tool. With by set, only a tool registered by
+// that caller is removed.
+func (s *Service) UnregisterTool(name, sessionID, by string) error {
+	key := toolKey{sessionID: strings.TrimSpace(sessionID), name: strings.TrimSpace(name)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tools[key]
+	if !ok {
+		return ErrToolNotFound
+	}
+	if by != "" && t.RegisteredBy != by {
+		return ErrToolNotOwned
+	}
+	delete(s.tools, key)
+	return nil
+}
+
+// ListTools returns global tools and, when sessionID is set, the tools of
+// that session, sorted by name.
+func (s *Service) ListTools(sessionID string) []RegisteredTool {
+	s.mu.Lock()
+	out := make([]RegisteredTool, 0, len(s.tools))
+	for key, t := range s.tools {
+		if key.sessionID == "" || (sessionID != "" && key.sessionID == sessionID) {
+			out = append(out, t)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].SessionID < out[j].SessionID
+	})
+	return out
+}
+
+func (s *Service) lookupTool(sessionID, name string) (RegisteredTool, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.tools[toolKey{sessionID: sessionID, name: name}]; ok {
+		return t, true
+	}
+	t, ok := s.tools[toolKey{name: name}]
+	return t, ok
+}
+
+// dropSessionToolsLocked forgets the tools of a session that is gone.
+func (s *Service) dropSessionToolsLocked(sessionID string) {
+	for key := range s.tools {
+		if key.sessionID == sessionID {
+			delete(s.tools, key)
+		}
+	}
+}
+
+// invokeTool runs a registered tool for a pending item/tool/call request and
+// replies to the app-server with its output.
+func (s *Service) invokeTool(st *sessionState, requestID string, tool RegisteredTool, params map[string]any) {
+	st.mu.Lock()
+	call := ToolCall{
+		Event:     "tool_call",
+		SessionID: st.session.ID,
+		ThreadID:  st.session.ThreadID,
+		Tool:      tool.Name,
+		Params:    params,
+	}
+	workspace := st.session.WorkspacePath
+	st.mu.Unlock()
+	if v, ok := params["threadId"].(string); ok && v != "" {
+		call.ThreadID = v
+	}
+	call.TurnID, _ = params["turnId"].(string)
+	call.CallID, _ = params["callId"].(string)
+	call.Arguments = params["arguments"]
+
+	s.publish(st, "tool", "tool/invoked", map[string]any{
+		"request_id": requestID,
+		"tool":       tool.Name,
+		"call_id":    call.CallID,
+		"via":        tool.Via(),
+	})
+	started := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), tool.timeout())
+	defer cancel()
+	var (
+		result map[string]any
+		err    error
+	)
+	if tool.Script != "" {
+		result, err = runToolScript(ctx, tool, workspace, call)
+	} else {
+		s.mu.Lock()
+		signer := s.toolSigner
+		s.mu.Unlock()
+		result, err = postToolCallback(ctx, tool, signer, call)
+	}
+	if err != nil {
+		result = toolResult(err.Error(), false)
+	}
+	success, _ := result["success"].(bool)
+
+	st.mu.Lock()
+	pending, ok := st.pending[requestID]
+	if !ok || pending.obj.Resolved {
+		// A client answered first.
+		st.mu.Unlock()
+		return
+	}
+	pending.obj.Resolved = true
+	pending.obj.ResolvedAt = time.Now().UTC()
+	wireID := pending.wireID
+	method := pending.obj.Method
+	st.mu.Unlock()
+
+	payload := map[string]any{
+		"request_id":  requestID,
+		"tool":        tool.Name,
+		"call_id":     call.CallID,
+		"success":     success,
+		"duration_ms": time.Since(started).Milliseconds(),
+	}
+	if err != nil {
+		payload["error"] = err.Error()
+	}
+	if client := st.rpc(); client != nil {
+		if replyErr := client.ReplyResult(wireID, result); replyErr != nil {
+			payload["error"] = replyErr.Error()
+		}
+	}
+	s.publish(st, "tool", "tool/completed", payload)
+	s.publish(st, "request_resolved", method, map[string]any{"request_id": requestID, "result": result, "tool": tool.Name})
+}
+
+// toolResult builds an item/tool/call response carrying text output.
+func toolResult(text string, success bool) map[string]any {
+	return map[string]any{
+		"contentItems": []any{map[string]any{"type": "inputText", "text": text}},
+		"success":      success,
+	}
+}
+
+// parseToolOutput accepts either a JSON object with contentItems or output
+// (plus an optional success flag), or plain text.
+func parseToolOutput(raw []byte, success bool) map[string]any {
+	var obj map[string]any
+	if err := json.Unmarshal(raw, &obj); err == nil && obj != nil {
+		if v, ok := obj["success"].(bool); ok {
+			success = success && v
+		}
+		if items, ok := obj["contentItems"].([]any); ok {
+			return map[string]any{"contentItems": items, "success": success}
+		}
+		if text, ok := obj["output"].(string); ok {
+			return toolResult(text, success)
+		}
+	}
+	return toolResult(strings.TrimRight(string(raw), "\n"), success)
+}
+
+func postToolCallback(ctx context.Context, tool RegisteredTool, signer *signing.Signer, call ToolCall) (map[string]any, error) {
+	body, err := json.Marshal(call)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tool.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if signer != nil {
+		signer.SignRequest(req, body)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("tool callback: %w", err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxToolOutputBytes))
+	if err != nil {
+		return nil, fmt.Errorf("tool callback: %w", err)
+	}
+	return parseToolOutput(raw, resp.StatusCode/100 == 2), nil
+}
+
+func runToolScript(ctx context.Context, tool RegisteredTool, workspace string, call ToolCall) (map[string]any, error) {
+	input, err := json.Marshal(call)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", tool.Script)
+	cmd.Dir = workspace
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(cmd.Environ(), "ELIX_TOOL_NAME="+tool.Name, "ELIX_SESSION_ID="+call.SessionID)
+	var stdout, stderr limitedBuffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("tool script timed out after %s", tool.timeout())
+	}
+	if runErr != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(runErr, &exitErr) {
+			return nil, fmt.Errorf("tool script: %w", runErr)
+		}
+		out := stdout.Bytes()
+		if len(bytes.TrimSpace(out)) == 0 {
+			out = stderr.Bytes()
+		}
+		return parseToolOutput(out, false), nil
+	}
+	return parseToolOutput(stdout.Bytes(), true), nil
+}
+
+// limitedBuffer keeps the first maxToolOutputBytes written to it.
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := maxToolOutputBytes - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
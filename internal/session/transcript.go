@@ -0,0 +1,343 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	TranscriptUser      = "user"
+	TranscriptAssistant = "assistant"
+	TranscriptToolCall  = "tool_call"
+	TranscriptRequest   = "request"
+
+	defaultTranscriptTurns = 50
+	maxTranscriptTurns     = 200
+)
+
+// Transcript is a session's retained event history folded into turns.
+type Transcript struct {
+	SessionID  string           `json:"session_id"`
+	ThreadID   string           `json:"thread_id,omitempty"`
+	TotalTurns int              `json:"total_turns"`
+	FromTurn   int              `json:"from_turn"`
+	NextTurn   int              `json:"next_turn,omitempty"`
+	Turns      []TranscriptTurn `json:"turns"`
+}
+
+type TranscriptTurn struct {
+	Index       int                 `json:"index"`
+	TurnID      string              `json:"turn_id,omitempty"`
+	Status      string              `json:"status,omitempty"`
+	StartedAt   time.Time           `json:"started_at"`
+	CompletedAt time.Time           `json:"completed_at,omitempty"`
+	Messages    []TranscriptMessage `json:"messages"`
+}
+
+// TranscriptMessage is one entry of a turn. Kind is user, assistant,
+// tool_call or request (approvals and other server requests).
+type TranscriptMessage struct {
+	Kind       string         `json:"kind"`
+	ItemID     string         `json:"item_id,omitempty"`
+	ItemType   string         `json:"item_type,omitempty"`
+	RequestID  string         `json:"request_id,omitempty"`
+	Method     string         `json:"method,omitempty"`
+	Text       string         `json:"text,omitempty"`
+	Status     string         `json:"status,omitempty"`
+	Params     map[string]any `json:"params,omitempty"`
+	Resolution map[string]any `json:"resolution,omitempty"`
+	TS         time.Time      `json:"ts"`
+}
+
+// Transcript assembles the session's retained events into turns and returns
+// up to limit turns starting at fromTurn.
+func (s *Service) Transcript(sessionID string, fromTurn, limit int) (Transcript, error) {
+	st, err := s.state(sessionID)
+	if err != nil {
+		return Transcript{}, err
+	}
+	st.mu.Lock()
+	threadID := st.session.ThreadID
+	history := append([]Event(nil), st.history...)
+	st.mu.Unlock()
+
+	turns := buildTranscriptTurns(history)
+	if fromTurn < 0 {
+		fromTurn = 0
+	}
+	if limit <= 0 {
+		limit = defaultTranscriptTurns
+	}
+	if limit > maxTranscriptTurns {
+		limit = maxTranscriptTurns
+	}
+	out := Transcript{
+		SessionID:  sessionID,
+		ThreadID:   threadID,
+		TotalTurns: len(turns),
+		FromTurn:   fromTurn,
+		Turns:      []TranscriptTurn{},
+	}
+	if fromTurn < len(turns) {
+		end := fromTurn + limit
+		if end > len(turns) {
+			end = len(turns)
+		}
+		out.Turns = turns[fromTurn:end]
+		if end < len(turns) {
+			out.NextTurn = end
+		}
+	}
+	return out, nil
+}
+
+type transcriptBuilder struct {
+	turns    []*TranscriptTurn
+	byID     map[string]*TranscriptTurn
+	current  *TranscriptTurn
+	items    map[string]messageRef
+	requests map[string]messageRef
+}
+
+// messageRef locates a message by index since appends move the slice.
+type messageRef struct {
+	turn  *TranscriptTurn
+	index int
+}
+
+func (r messageRef) get() *TranscriptMessage {
+	return &r.turn.Messages[r.index]
+}
+
+func buildTranscriptTurns(history []Event) []TranscriptTurn {
+	b := &transcriptBuilder{
+		byID:     map[string]*TranscriptTurn{},
+		items:    map[string]messageRef{},
+		requests: map[string]messageRef{},
+	}
+	for _, ev := range history {
+		b.add(ev)
+	}
+	out := make([]TranscriptTurn, len(b.turns))
+	for i, t := range b.turns {
+		out[i] = *t
+	}
+	return out
+}
+
+func (b *transcriptBuilder) turn(id string, ts time.Time) *TranscriptTurn {
+	if id == "" {
+		if b.current != nil {
+			return b.current
+		}
+	} else if t, ok := b.byID[id]; ok {
+		return t
+	} else if b.current != nil && b.current.TurnID == "" {
+		// Adopt the turn opened by events that arrived before turn/started.
+		b.current.TurnID = id
+		b.byID[id] = b.current
+		return b.current
+	}
+	t := &TranscriptTurn{Index: len(b.turns), TurnID: id, StartedAt: ts, Messages: []TranscriptMessage{}}
+	b.turns = append(b.turns, t)
+	if id != "" {
+		b.byID[id] = t
+	}
+	b.current = t
+	return t
+}
+
+func (b *transcriptBuilder) message(t *TranscriptTurn, m TranscriptMessage) {
+	t.Messages = append(t.Messages, m)
+	b.index(t, len(t.Messages)-1)
+}
+
+func (b *transcriptBuilder) index(t *TranscriptTurn, i int) {
+	m := t.Messages[i]
+	if m.ItemID != "" {
+		b.items[m.ItemID] = messageRef{turn: t, index: i}
+	}
+	if m.RequestID != "" {
+		b.requests[m.RequestID] = messageRef{turn: t, index: i}
+	}
+}
+
+// prepend puts the prompt that started a turn ahead of output that was
+// streamed before the turn/start call returned.
+func (b *transcriptBuilder) prepend(t *TranscriptTurn, m TranscriptMessage) {
+	t.Messages = append([]TranscriptMessage{m}, t.Messages...)
+	for i := range t.Messages {
+		b.index(t, i)
+	}
+}
+
+func (b *transcriptBuilder) add(ev Event) {
+	p := ev.Payload
+	switch ev.Type {
+	case "input":
+		m := TranscriptMessage{Kind: TranscriptUser, Method: ev.Method, Text: inputText(p["input"]), TS: ev.TS}
+		t := b.turn(stringField(p, "turn_id"), ev.TS)
+		if ev.Method == "turn/start" {
+			b.prepend(t, m)
+		} else {
+			b.message(t, m)
+		}
+	case "request":
+		params, _ := p["params"].(map[string]any)
+		t := b.turn(stringField(params, "turnId"), ev.TS)
+		b.message(t, TranscriptMessage{
+			Kind:      TranscriptRequest,
+			RequestID: stringField(p, "request_id"),
+			ItemType:  stringField(p, "kind"),
+			Method:    ev.Method,
+			Status:    "pending",
+			Params:    params,
+			TS:        ev.TS,
+		})
+	case "request_resolved":
+		if ref, ok := b.requests[stringField(p, "request_id")]; ok {
+			m := ref.get()
+			m.Status = "resolved"
+			res := map[string]any{}
+			for k, v := range p {
+				if k != "request_id" {
+					res[k] = v
+				}
+			}
+			m.Resolution = res
+		}
+	case "notification":
+		b.addNotification(ev)
+	}
+}
+
+func (b *transcriptBuilder) addNotification(ev Event) {
+	p := ev.Payload
+	switch ev.Method {
+	case "turn/started":
+		turn, _ := p["turn"].(map[string]any)
+		t := b.turn(stringField(turn, "id"), ev.TS)
+		t.Status = stringField(turn, "status")
+	case "turn/completed":
+		turn, _ := p["turn"].(map[string]any)
+		t := b.turn(stringField(turn, "id"), ev.TS)
+		t.Status = stringField(turn, "status")
+		t.CompletedAt = ev.TS
+		if b.current == t {
+			b.current = nil
+		}
+	case "item/agentMessage/delta":
+		itemID := stringField(p, "itemId")
+		if ref, ok := b.items[itemID]; ok && itemID != "" {
+			ref.get().Text += stringField(p, "delta")
+			return
+		}
+		t := b.turn(stringField(p, "turnId"), ev.TS)
+		b.message(t, TranscriptMessage{Kind: TranscriptAssistant, ItemID: itemID, ItemType: "agentMessage", Text: stringField(p, "delta"), TS: ev.TS})
+	case "item/started", "item/completed":
+		item, _ := p["item"].(map[string]any)
+		itemType := stringField(item, "type")
+		itemID := stringField(item, "id")
+		ref, seen := b.items[itemID]
+		seen = seen && itemID != ""
+		switch itemType {
+		case "", "userMessage", "reasoning":
+			return
+		case "agentMessage":
+			if seen {
+				// The completed item carries the full text; prefer it over
+				// the concatenated deltas.
+				if text := stringField(item, "text"); text != "" {
+					ref.get().Text = text
+				}
+				return
+			}
+			t := b.turn(stringField(p, "turnId"), ev.TS)
+			b.message(t, TranscriptMessage{Kind: TranscriptAssistant, ItemID: itemID, ItemType: itemType, Text: stringField(item, "text"), TS: ev.TS})
+		default:
+			if seen {
+				m := ref.get()
+				m.Status = stringField(item, "status")
+				m.Params = item
+				return
+			}
+			t := b.turn(stringField(p, "turnId"), ev.TS)
+			b.message(t, TranscriptMessage{Kind: TranscriptToolCall, ItemID: itemID, ItemType: itemType, Status: stringField(item, "status"), Params: item, TS: ev.TS})
+		}
+	}
+}
+
+func stringField(m map[string]any, key string) string {
+	if m == nil {
+		return ""
+	}
+	v, _ := m[key].(string)
+	return v
+}
+
+func inputText(raw any) string {
+	var parts []string
+	switch items := raw.(type) {
+	case []map[string]any:
+		for _, item := range items {
+			if text := stringField(item, "text"); text != "" {
+				parts = append(parts, text)
+			}
+		}
+	case []any:
+		for _, item := range items {
+			m, _ := item.(map[string]any)
+			if text := stringField(m, "text"); text != "" {
+				parts = append(parts, text)
+			}
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// RenderTranscriptMarkdown renders t as a readable markdown document.
+func RenderTranscriptMarkdown(t Transcript) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Session %s\n", t.SessionID)
+	for _, turn := range t.Turns {
+		fmt.Fprintf(&b, "\n## Turn %d", turn.Index+1)
+		if turn.TurnID != "" || turn.Status != "" {
+			fmt.Fprintf(&b, " (%s)", strings.Trim(strings.Join([]string{turn.TurnID, turn.Status}, ", "), ", "))
+		}
+		b.WriteString("\n")
+		for _, m := range turn.Messages {
+			b.WriteString("\n")
+			switch m.Kind {
+			case TranscriptUser:
+				fmt.Fprintf(&b, "**User:** %s\n", m.Text)
+			case TranscriptAssistant:
+				fmt.Fprintf(&b, "**Assistant:** %s\n", m.Text)
+			case TranscriptToolCall:
+				fmt.Fprintf(&b, "> Tool `%s`", m.ItemType)
+				if m.Status != "" {
+					fmt.Fprintf(&b, " (%s)", m.Status)
+				}
+				if cmd := stringField(m.Params, "command"); cmd != "" {
+					fmt.Fprintf(&b, ": `%s`", cmd)
+				}
+				b.WriteString("\n")
+			case TranscriptRequest:
+				fmt.Fprintf(&b, "> Request `%s` (%s)", m.Method, m.Status)
+				if cmd := stringField(m.Params, "command"); cmd != "" {
+					fmt.Fprintf(&b, ": `%s`", cmd)
+				}
+				if res, ok := m.Resolution["result"].(map[string]any); ok {
+					if decision := stringField(res, "decision"); decision != "" {
+						fmt.Fprintf(&b, " -> %s", decision)
+					}
+				}
+				b.WriteString("\n")
+			}
+		}
+	}
+	if t.NextTurn > 0 {
+		fmt.Fprintf(&b, "\n_More turns from %d._\n", t.NextTurn)
+	}
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package session
+
+// TurnFilter picks the events of one turn out of a session event stream.
+// Request resolutions carry no turn id, so the filter remembers the requests
+// it has passed and lets their resolutions through.
+type TurnFilter struct {
+	TurnID   string
+	requests map[string]struct{}
+}
+
+func NewTurnFilter(turnID string) *TurnFilter {
+	return &TurnFilter{TurnID: turnID, requests: map[string]struct{}{}}
+}
+
+// Match reports whether ev belongs to the turn.
+func (f *TurnFilter) Match(ev Event) bool {
+	if ev.Type == "request_resolved" {
+		_, ok := f.requests[stringField(ev.Payload, "request_id")]
+		return ok
+	}
+	if f.TurnID == "" || EventTurnID(ev) != f.TurnID {
+		return false
+	}
+	if ev.Type == "request" {
+		f.requests[stringField(ev.Payload, "request_id")] = struct{}{}
+	}
+	return true
+}
+
+// EventTurnID returns the turn an event belongs to, or "" when the event is
+// not tied to a turn.
+func EventTurnID(ev Event) string {
+	p := ev.Payload
+	switch ev.Type {
+	case "input", "status":
+		return stringField(p, "turn_id")
+	case "request":
+		params, _ := p["params"].(map[string]any)
+		return stringField(params, "turnId")
+	case "notification":
+		if turn, ok := p["turn"].(map[string]any); ok {
+			return stringField(turn, "id")
+		}
+		return stringField(p, "turnId")
+	}
+	return ""
+}
+
+// TurnCompletion reports the final status when ev is the turn/completed
+// notification of a turn.
+func TurnCompletion(ev Event) (turnID, status string, ok bool) {
+	if ev.Type != "notification" || ev.Method != "turn/completed" {
+		return "", "", false
+	}
+	turn, _ := ev.Payload["turn"].(map[string]any)
+	return stringField(turn, "id"), stringField(turn, "status"), true
+}
@@ -0,0 +1,128 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const TurnStatusQueued = "queued"
+
+var ErrTurnConflict = errors.New("turn_conflict")
+
+// TurnConflictError is returned when a turn is started while another one is
+// running and the queue is disabled or full.
+type TurnConflictError struct {
+	ActiveTurnID string
+	QueuedTurns  int
+	MaxQueue     int
+}
+
+func (e *TurnConflictError) Error() string {
+	if e.MaxQueue > 0 {
+		return fmt.Sprintf("a turn is already running and the turn queue is full (%d)", e.MaxQueue)
+	}
+	return "a turn is already running on this session"
+}
+
+func (e *TurnConflictError) Is(target error) bool {
+	return target == ErrTurnConflict
+}
+
+type queuedTurn struct {
+	id         string
+	req        StartTurnRequest
+	enqueuedAt time.Time
+}
+
+// SetTurnQueue lets up to maxDepth turns wait per session while a turn is
+// running; they start in order as turns complete. Zero rejects overlapping
+// turns with a TurnConflictError.
+func (s *Service) SetTurnQueue(maxDepth int) {
+	if maxDepth < 0 {
+		maxDepth = 0
+	}
+	s.mu.Lock()
+	s.turnQueueMax = maxDepth
+	s.mu.Unlock()
+}
+
+func (s *Service) turnQueueDepth() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.turnQueueMax
+}
+
+func (s *Service) enqueueTurnLocked(st *sessionState, req StartTurnRequest, maxDepth int) (StartTurnResult, error) {
+	if len(st.turnQueue) >= maxDepth {
+		return StartTurnResult{}, &TurnConflictError{
+			ActiveTurnID: st.activeTurnID,
+			QueuedTurns:  len(st.turnQueue),
+			MaxQueue:     maxDepth,
+		}
+	}
+	item := queuedTurn{id: uuid.NewString(), req: req, enqueuedAt: time.Now().UTC()}
+	st.turnQueue = append(st.turnQueue, item)
+	st.session.QueuedTurns = len(st.turnQueue)
+	return StartTurnResult{
+		SessionID:     st.session.ID,
+		ThreadID:      st.session.ThreadID,
+		Status:        TurnStatusQueued,
+		QueueID:       item.id,
+		QueuePosition: len(st.turnQueue),
+	}, nil
+}
+
+// releaseTurn ends the running turn and hands the session to the next
+// queued turn, if any.
+func (s *Service) releaseTurn(st *sessionState) {
+	st.mu.Lock()
+	if len(st.turnQueue) == 0 || isTerminalSessionStatus(st.session.Status) || st.session.Status == StatusSuspended {
+		st.turnBusy = false
+		st.mu.Unlock()
+		return
+	}
+	next := st.turnQueue[0]
+	st.turnQueue = st.turnQueue[1:]
+	st.session.QueuedTurns = len(st.turnQueue)
+	st.turnBusy = true
+	st.lastTurnAt = time.Now().UTC()
+	st.mu.Unlock()
+	go s.runQueuedTurn(st, next)
+}
+
+func (s *Service) runQueuedTurn(st *sessionState, item queuedTurn) {
+	result, err := s.sendTurn(context.Background(), st, item.req)
+	if err != nil {
+		log.Printf("session id=%s: start queued turn %s: %v", st.sessionID(), item.id, err)
+		s.publish(st, "status", "turn/queue_failed", map[string]any{"queue_id": item.id, "error": err.Error()})
+		s.releaseTurn(st)
+		return
+	}
+	s.publish(st, "status", "turn/dequeued", map[string]any{
+		"queue_id":    item.id,
+		"turn_id":     result.TurnID,
+		"waited_secs": int(time.Since(item.enqueuedAt).Seconds()),
+	})
+}
+
+// dropTurnsLocked clears the turn lock and queue when the app-server goes
+// away or is replaced. It returns the dropped queue entries so the caller
+// can publish them after unlocking.
+func (st *sessionState) dropTurnsLocked() []queuedTurn {
+	dropped := st.turnQueue
+	st.turnBusy = false
+	st.turnQueue = nil
+	st.session.QueuedTurns = 0
+	return dropped
+}
+
+func (s *Service) publishDroppedTurns(st *sessionState, dropped []queuedTurn, reason string) {
+	for _, item := range dropped {
+		s.publish(st, "status", "turn/queue_dropped", map[string]any{"queue_id": item.id, "reason": reason})
+	}
+}
@@ -0,0 +1,321 @@
+// Package signing signs outbound bridge payloads (webhooks, chat-ops
+// notifications, event exports) and verifies them on the receiving side.
+//
+// The signature header has the form
+//
+//	t=<unix seconds>,v1=<key id>:<alg>:<base64 signature>[,v1=...]
+//
+// where each signature covers "<t>.<body>". A signer emits one v1 entry per
+// configured key, so a new key can be added ahead of retiring the old one and
+// receivers accept any entry whose key id they know.
+package signing
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderSignature = "X-Elix-Signature"
+
+	AlgHMACSHA256 = "hmac-sha256"
+	AlgEd25519    = "ed25519"
+
+	DefaultTolerance = 5 * time.Minute
+)
+
+var (
+	ErrNoSignature       = errors.New("signature header missing")
+	ErrMalformedHeader   = errors.New("malformed signature header")
+	ErrTimestampExpired  = errors.New("signature timestamp outside tolerance")
+	ErrSignatureMismatch = errors.New("no signature matched a known key")
+)
+
+type Key struct {
+	ID        string
+	Algorithm string
+
+	secret     []byte
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+}
+
+func NewHMACKey(id string, secret []byte) (Key, error) {
+	id = strings.TrimSpace(id)
+	if err := validateKeyID(id); err != nil {
+		return Key{}, err
+	}
+	if len(secret) < 16 {
+		return Key{}, fmt.Errorf("hmac key %q: secret must be at least 16 bytes", id)
+	}
+	return Key{ID: id, Algorithm: AlgHMACSHA256, secret: append([]byte(nil), secret...)}, nil
+}
+
+// NewEd25519Key accepts a 32-byte seed or a 64-byte private key.
+func NewEd25519Key(id string, private []byte) (Key, error) {
+	id = strings.TrimSpace(id)
+	if err := validateKeyID(id); err != nil {
+		return Key{}, err
+	}
+	var priv ed25519.PrivateKey
+	switch len(private) {
+	case ed25519.SeedSize:
+		priv = ed25519.NewKeyFromSeed(private)
+	case ed25519.PrivateKeySize:
+		priv = append(ed25519.PrivateKey(nil), private...)
+	default:
+		return Key{}, fmt.Errorf("ed25519 key %q: expected %d-byte seed or %d-byte private key", id, ed25519.SeedSize, ed25519.PrivateKeySize)
+	}
+	return Key{ID: id, Algorithm: AlgEd25519, privateKey: priv, publicKey: priv.Public().(ed25519.PublicKey)}, nil
+}
+
+// NewEd25519PublicKey builds a verify-only key for receivers.
+func NewEd25519PublicKey(id string, public []byte) (Key, error) {
+	id = strings.TrimSpace(id)
+	if err := validateKeyID(id); err != nil {
+		return Key{}, err
+	}
+	if len(public) != ed25519.PublicKeySize {
+		return Key{}, fmt.Errorf("ed25519 public key %q: expected %d bytes", id, ed25519.PublicKeySize)
+	}
+	return Key{ID: id, Algorithm: AlgEd25519, publicKey: append(ed25519.PublicKey(nil), public...)}, nil
+}
+
+// ParseKeys reads "id:alg:base64" entries separated by commas. alg is
+// hmac-sha256, ed25519 (seed or private key) or ed25519-pub (verify only).
+func ParseKeys(spec string) ([]Key, error) {
+	var out []Key
+	seen := map[string]struct{}{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		fields := strings.SplitN(part, ":", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid signing key entry %q: expected id:alg:base64", part)
+		}
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(fields[2]))
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: decode: %w", fields[0], err)
+		}
+		var key Key
+		switch strings.ToLower(strings.TrimSpace(fields[1])) {
+		case AlgHMACSHA256, "hmac":
+			key, err = NewHMACKey(fields[0], raw)
+		case AlgEd25519:
+			key, err = NewEd25519Key(fields[0], raw)
+		case AlgEd25519 + "-pub":
+			key, err = NewEd25519PublicKey(fields[0], raw)
+		default:
+			err = fmt.Errorf("signing key %q: unsupported algorithm %q", fields[0], fields[1])
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := seen[key.ID]; dup {
+			return nil, fmt.Errorf("duplicate signing key id %q", key.ID)
+		}
+		seen[key.ID] = struct{}{}
+		out = append(out, key)
+	}
+	return out, nil
+}
+
+// PublicKey returns the base64 ed25519 public key, or "" for hmac keys.
+func (k Key) PublicKey() string {
+	if k.Algorithm != AlgEd25519 {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(k.publicKey)
+}
+
+func (k Key) canSign() bool {
+	return len(k.secret) > 0 || len(k.privateKey) > 0
+}
+
+func (k Key) sign(msg []byte) []byte {
+	if k.Algorithm == AlgEd25519 {
+		return ed25519.Sign(k.privateKey, msg)
+	}
+	mac := hmac.New(sha256.New, k.secret)
+	mac.Write(msg)
+	return mac.Sum(nil)
+}
+
+func (k Key) verify(msg, sig []byte) bool {
+	if k.Algorithm == AlgEd25519 {
+		return len(k.publicKey) == ed25519.PublicKeySize && ed25519.Verify(k.publicKey, msg, sig)
+	}
+	mac := hmac.New(sha256.New, k.secret)
+	mac.Write(msg)
+	return subtle.ConstantTimeCompare(mac.Sum(nil), sig) == 1
+}
+
+type Signer struct {
+	keys []Key
+	now  func() time.Time
+}
+
+// NewSigner signs with every key, in order; list the new key alongside the
+// old one while receivers roll over, then drop the old one.
+func NewSigner(keys ...Key) (*Signer, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one signing key is required")
+	}
+	for _, k := range keys {
+		if !k.canSign() {
+			return nil, fmt.Errorf("signing key %q has no private material", k.ID)
+		}
+	}
+	return &Signer{keys: append([]Key(nil), keys...), now: time.Now}, nil
+}
+
+func (s *Signer) Keys() []Key {
+	return append([]Key(nil), s.keys...)
+}
+
+// Sign returns the signature header value for body.
+func (s *Signer) Sign(body []byte) string {
+	ts := strconv.FormatInt(s.now().Unix(), 10)
+	msg := signedMessage(ts, body)
+	parts := make([]string, 0, len(s.keys)+1)
+	parts = append(parts, "t="+ts)
+	for _, k := range s.keys {
+		parts = append(parts, "v1="+k.ID+":"+k.Algorithm+":"+base64.StdEncoding.EncodeToString(k.sign(msg)))
+	}
+	return strings.Join(parts, ",")
+}
+
+// SignRequest sets the signature header; body must be the exact bytes sent.
+func (s *Signer) SignRequest(req *http.Request, body []byte) {
+	req.Header.Set(HeaderSignature, s.Sign(body))
+}
+
+// PostJSON POSTs v as JSON to url, signed with signer when it is not nil,
+// and fails on transport errors and non-2xx responses.
+func PostJSON(ctx context.Context, client *http.Client, url string, signer *Signer, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if signer != nil {
+		signer.SignRequest(req, body)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+type Verifier struct {
+	keys      map[string]Key
+	tolerance time.Duration
+	now       func() time.Time
+}
+
+// NewVerifier accepts signatures from any of keys within tolerance of the
+// receiver clock; tolerance <= 0 uses DefaultTolerance.
+func NewVerifier(tolerance time.Duration, keys ...Key) *Verifier {
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	v := &Verifier{keys: make(map[string]Key, len(keys)), tolerance: tolerance, now: time.Now}
+	for _, k := range keys {
+		v.keys[k.ID] = k
+	}
+	return v
+}
+
+func (v *Verifier) Verify(header string, body []byte) error {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return ErrNoSignature
+	}
+	var ts string
+	var sigs []string
+	for _, part := range strings.Split(header, ",") {
+		k, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return ErrMalformedHeader
+		}
+		switch k {
+		case "t":
+			ts = val
+		case "v1":
+			sigs = append(sigs, val)
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return ErrMalformedHeader
+	}
+	skew := v.now().Sub(time.Unix(unix, 0))
+	if skew < -v.tolerance || skew > v.tolerance {
+		return ErrTimestampExpired
+	}
+	msg := signedMessage(ts, body)
+	for _, entry := range sigs {
+		fields := strings.SplitN(entry, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		key, ok := v.keys[fields[0]]
+		if !ok || key.Algorithm != fields[1] {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(fields[2])
+		if err != nil {
+			continue
+		}
+		if key.verify(msg, sig) {
+			return nil
+		}
+	}
+	return ErrSignatureMismatch
+}
+
+// VerifyRequest checks the signature header of an incoming request against
+// body, which the caller has already read from r.Body.
+func (v *Verifier) VerifyRequest(r *http.Request, body []byte) error {
+	return v.Verify(r.Header.Get(HeaderSignature), body)
+}
+
+func signedMessage(ts string, body []byte) []byte {
+	msg := make([]byte, 0, len(ts)+1+len(body))
+	msg = append(msg, ts...)
+	msg = append(msg, '.')
+	return append(msg, body...)
+}
+
+func validateKeyID(id string) error {
+	if id == "" {
+		return fmt.Errorf("signing key id is required")
+	}
+	if strings.ContainsAny(id, ":,= ") {
+		return fmt.Errorf("signing key id %q must not contain ':', ',', '=' or spaces", id)
+	}
+	return nil
+}
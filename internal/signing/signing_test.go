@@ -0,0 +1,100 @@
+package signing
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerifyWithRotation(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	spec := "old:hmac-sha256:" + base64.StdEncoding.EncodeToString([]byte("0123456789abcdef")) +
+		",new:ed25519:" + base64.StdEncoding.EncodeToString(seed)
+	keys, err := ParseKeys(spec)
+	if err != nil {
+		t.Fatalf("parse keys: %v", err)
+	}
+	signer, err := NewSigner(keys...)
+	if err != nil {
+		t.Fatalf("new signer: %v", err)
+	}
+	body := []byte(`{"event":"run.completed"}`)
+	header := signer.Sign(body)
+	if strings.Count(header, "v1=") != 2 {
+		t.Fatalf("expected one signature per key, got %q", header)
+	}
+
+	// A receiver that only knows the new public key still verifies.
+	pub, err := base64.StdEncoding.DecodeString(keys[1].PublicKey())
+	if err != nil {
+		t.Fatalf("decode public key: %v", err)
+	}
+	pubKey, err := NewEd25519PublicKey("new", pub)
+	if err != nil {
+		t.Fatalf("public key: %v", err)
+	}
+	if err := NewVerifier(0, pubKey).Verify(header, body); err != nil {
+		t.Fatalf("verify with rotated key: %v", err)
+	}
+	if err := NewVerifier(0, keys[0]).Verify(header, body); err != nil {
+		t.Fatalf("verify with old key: %v", err)
+	}
+
+	if err := NewVerifier(0, pubKey).Verify(header, []byte(`{"event":"tampered"}`)); !errors.Is(err, ErrSignatureMismatch) {
+		t.Fatalf("expected mismatch for tampered body, got %v", err)
+	}
+	if _, err := NewSigner(pubKey); err == nil {
+		t.Fatalf("expected verify-only key to be rejected by signer")
+	}
+
+	late := NewVerifier(time.Minute, pubKey)
+	late.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if err := late.Verify(header, body); !errors.Is(err, ErrTimestampExpired) {
+		t.Fatalf("expected expired timestamp, got %v", err)
+	}
+	if err := late.Verify("", body); !errors.Is(err, ErrNoSignature) {
+		t.Fatalf("expected missing signature, got %v", err)
+	}
+}
+
+func TestPostJSONSignsTheBodyAndReportsFailures(t *testing.T) {
+	key, err := NewHMACKey("k1", []byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewSigner(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := NewVerifier(0, key)
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Content-Type") != "application/json" || string(body) != `{"event":"x"}` {
+			t.Errorf("unexpected request %s: %s", r.Header.Get("Content-Type"), body)
+		}
+		if err := verifier.VerifyRequest(r, body); err != nil {
+			t.Errorf("verify: %v", err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	if err := PostJSON(context.Background(), srv.Client(), srv.URL, signer, map[string]string{"event": "x"}); err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	status = http.StatusBadGateway
+	if err := PostJSON(context.Background(), srv.Client(), srv.URL, signer, map[string]string{"event": "x"}); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Fatalf("expected the 502 to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestInterceptorsPropagateTraceAcrossAdapterBoundary(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	ctx, root := Start(context.Background(), "run.execute")
+	var outgoing metadata.MD
+	err := UnaryClientInterceptor(ctx, "/echohelix.adapter.Adapter/StartRun", nil, nil, nil, func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		outgoing, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("client call: %v", err)
+	}
+	if len(outgoing.Get("traceparent")) != 1 {
+		t.Fatalf("traceparent not injected: %v", outgoing)
+	}
+
+	serverCtx := metadata.NewIncomingContext(context.Background(), outgoing)
+	info := &grpc.UnaryServerInfo{FullMethod: "/echohelix.adapter.Adapter/StartRun"}
+	if _, err := UnaryServerInterceptor(serverCtx, nil, info, func(ctx context.Context, req any) (any, error) { return nil, nil }); err != nil {
+		t.Fatalf("server call: %v", err)
+	}
+	if err := UnaryClientInterceptor(ctx, "/echohelix.adapter.Adapter/Health", nil, nil, nil, func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error { return nil }); err != nil {
+		t.Fatalf("health call: %v", err)
+	}
+	root.End()
+
+	spans := rec.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected client, server and root spans (health untraced), got %d", len(spans))
+	}
+	traceID := root.SpanContext().TraceID()
+	for _, s := range spans {
+		if s.SpanContext().TraceID() != traceID {
+			t.Fatalf("span %s not in the run trace", s.Name())
+		}
+	}
+	if spans[1].Parent().SpanID() != spans[0].SpanContext().SpanID() {
+		t.Fatalf("server span should be a child of the client span")
+	}
+}